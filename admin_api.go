@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultAdminAPITimeout = 60
+
+// AdminAPIConfig 通过监控程序的 HTTP 接口转发子进程自己的管理接口：运维人员只需访问一个受保护的地址，
+// 不必记住每个服务内部的管理端口
+type AdminAPIConfig struct {
+	URL     string       `yaml:"url"`     // 子进程管理接口的地址，如 http://127.0.0.1:9001
+	Routes  []AdminRoute `yaml:"routes"`  // 允许转发的路径（白名单），其他路径返回404
+	Timeout int          `yaml:"timeout"` // 单次请求超时（秒，默认60）
+}
+
+// AdminRoute maps a path under /processes/{name}/admin to a path of the
+// child's admin API. A path ending in /* matches everything below it.
+type AdminRoute struct {
+	Path    string   `yaml:"path"`    // 监控程序上的路径（相对 /processes/{name}/admin），如 /stats 或 /debug/pprof/*
+	Target  string   `yaml:"target"`  // 子进程上的路径（默认与 path 相同；前缀路由同样以 /* 结尾）
+	Methods []string `yaml:"methods"` // 允许的 HTTP 方法（默认 GET）
+}
+
+// UnmarshalYAML accepts a plain path as well as the structured form
+func (r *AdminRoute) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*r = AdminRoute{Path: node.Value}
+		return nil
+	}
+	type plain AdminRoute
+	return node.Decode((*plain)(r))
+}
+
+func (c AdminAPIConfig) validate() error {
+	if c.URL == "" && len(c.Routes) == 0 {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("admin_api.url must be an http:// or https:// URL, got %q", c.URL)
+	}
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("admin_api requires routes")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("admin_api.timeout must not be negative")
+	}
+	for _, r := range c.Routes {
+		if !strings.HasPrefix(r.Path, "/") || strings.Contains(strings.TrimSuffix(r.Path, "/*"), "*") {
+			return fmt.Errorf("admin_api route %q must be an absolute path, optionally ending in /*", r.Path)
+		}
+		if r.Target != "" && (!strings.HasPrefix(r.Target, "/") || strings.HasSuffix(r.Path, "/*") != strings.HasSuffix(r.Target, "/*")) {
+			return fmt.Errorf("admin_api route %q: target %q must be an absolute path, ending in /* exactly when path does", r.Path, r.Target)
+		}
+		for _, m := range r.Methods {
+			if m != strings.ToUpper(m) || m == "" {
+				return fmt.Errorf("admin_api route %q: invalid method %q", r.Path, m)
+			}
+		}
+	}
+	return nil
+}
+
+// match returns the path of the child for p (already cleaned) and whether
+// the route allows it
+func (r AdminRoute) match(p string) (string, bool) {
+	target := r.Target
+	if target == "" {
+		target = r.Path
+	}
+	prefix, ok := strings.CutSuffix(r.Path, "*")
+	if !ok {
+		return target, p == r.Path
+	}
+	rest, ok := strings.CutPrefix(p, prefix)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(target, "*") + rest, true
+}
+
+func (r AdminRoute) allows(method string) bool {
+	if len(r.Methods) == 0 {
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	for _, m := range r.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// handleProcessAdmin serves /processes/{name}/admin/...: the request is
+// forwarded to the child's admin API when api.admin_token is presented and
+// a route of admin_api allows the path and method
+func (s *APIServer) handleProcessAdmin(w http.ResponseWriter, r *http.Request, sup *ProcessSupervisor, sub string) {
+	config := sup.config.AdminAPI
+	if config.URL == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "process " + sup.config.Name + " has no admin_api"})
+		return
+	}
+	if !s.authorized(r) {
+		s.unauthorized(w)
+		return
+	}
+
+	// 先规范化路径，不允许通过 .. 离开前缀路由；保留结尾的 /（如 /debug/pprof/）
+	trailing := strings.HasSuffix(sub, "/")
+	sub = path.Clean("/" + sub)
+	if trailing && sub != "/" {
+		sub += "/"
+	}
+	var route *AdminRoute
+	var target string
+	for i := range config.Routes {
+		if t, ok := config.Routes[i].match(sub); ok {
+			route, target = &config.Routes[i], t
+			break
+		}
+	}
+	if route == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "admin path not allowed: " + sub})
+		return
+	}
+	if !route.allows(r.Method) {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	base, _ := url.Parse(config.URL)
+	logrus.Infof("Admin API %s %s of %s from %s", r.Method, sub, sup.config.Name, r.RemoteAddr)
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = base.Scheme
+			req.URL.Host = base.Host
+			req.URL.Path = strings.TrimSuffix(base.Path, "/") + target
+			req.URL.RawPath = ""
+			req.Host = base.Host
+			// 监控程序的令牌不转发给子进程
+			req.Header.Del("Authorization")
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("admin API of %s: %v", sup.config.Name, err)})
+		},
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(defaultInt(config.Timeout, defaultAdminAPITimeout))*time.Second)
+	defer cancel()
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
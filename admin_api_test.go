@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminRouteMatch(t *testing.T) {
+	tests := []struct {
+		route  AdminRoute
+		path   string
+		target string
+		ok     bool
+	}{
+		{AdminRoute{Path: "/stats"}, "/stats", "/stats", true},
+		{AdminRoute{Path: "/stats"}, "/stats/all", "", false},
+		{AdminRoute{Path: "/stats", Target: "/internal/admin/stats"}, "/stats", "/internal/admin/stats", true},
+		{AdminRoute{Path: "/debug/pprof/*"}, "/debug/pprof/heap", "/debug/pprof/heap", true},
+		{AdminRoute{Path: "/debug/pprof/*"}, "/debug/pprof/", "/debug/pprof/", true},
+		{AdminRoute{Path: "/debug/pprof/*"}, "/debug/vars", "", false},
+		{AdminRoute{Path: "/cache/*", Target: "/v2/cache/*"}, "/cache/users", "/v2/cache/users", true},
+	}
+	for _, tt := range tests {
+		target, ok := tt.route.match(tt.path)
+		if ok != tt.ok || (ok && target != tt.target) {
+			t.Errorf("%+v match %q = %q, %v; want %q, %v", tt.route, tt.path, target, ok, tt.target, tt.ok)
+		}
+	}
+
+	for _, c := range []AdminAPIConfig{
+		{URL: "127.0.0.1:9001", Routes: []AdminRoute{{Path: "/stats"}}},
+		{URL: "http://127.0.0.1:9001"},
+		{URL: "http://127.0.0.1:9001", Routes: []AdminRoute{{Path: "stats"}}},
+		{URL: "http://127.0.0.1:9001", Routes: []AdminRoute{{Path: "/a*b"}}},
+		{URL: "http://127.0.0.1:9001", Routes: []AdminRoute{{Path: "/cache/*", Target: "/cache"}}},
+		{URL: "http://127.0.0.1:9001", Routes: []AdminRoute{{Path: "/flush", Methods: []string{"post"}}}},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v was accepted", c)
+		}
+	}
+}
+
+func TestAdminAPIProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("admin token forwarded to the child")
+		}
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI())
+	}))
+	defer backend.Close()
+
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{
+		Name:   "web.exe",
+		Enable: true,
+		AdminAPI: AdminAPIConfig{URL: backend.URL + "/admin", Routes: []AdminRoute{
+			{Path: "/stats"},
+			{Path: "/cache/flush", Target: "/internal/flush", Methods: []string{http.MethodPost}},
+			{Path: "/debug/*"},
+		}},
+	}}})
+	server := NewAPIServer(APIConfig{AdminToken: "s3cret"}, manager)
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/processes/web.exe/admin/stats", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/processes/web.exe/admin/stats", "wrong", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/processes/web.exe/admin/stats?format=json", "s3cret", http.StatusOK, "GET /admin/stats?format=json"},
+		{http.MethodPost, "/processes/web.exe/admin/stats", "s3cret", http.StatusMethodNotAllowed, ""},
+		{http.MethodPost, "/processes/web.exe/admin/cache/flush", "s3cret", http.StatusOK, "POST /admin/internal/flush"},
+		{http.MethodGet, "/processes/web.exe/admin/debug/vars", "s3cret", http.StatusOK, "GET /admin/debug/vars"},
+		{http.MethodGet, "/processes/web.exe/admin/shutdown", "s3cret", http.StatusNotFound, ""},
+		{http.MethodGet, "/processes/web.exe/admin/debug/../shutdown", "s3cret", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		server.handleProcesses(rec, req)
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, rec.Code, rec.Body.String(), tt.status, tt.body)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 分页参数
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// nextCursorHeader carries the cursor of the next page; absent on the last page
+const nextCursorHeader = "X-Next-Cursor"
+
+// encodeCursor makes an opaque cursor from a kind prefix and a position
+func encodeCursor(kind, position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(kind + ":" + position))
+}
+
+// decodeCursor returns the position stored in cursor, checking its kind
+func decodeCursor(kind, cursor string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(data), kind+":") {
+		return "", fmt.Errorf("invalid cursor")
+	}
+	return strings.TrimPrefix(string(data), kind+":"), nil
+}
+
+// queryList returns a comma separated and/or repeated query parameter
+func queryList(query url.Values, key string) []string {
+	var values []string
+	for _, v := range query[key] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// queryLimit parses ?limit=, returning def when absent
+func queryLimit(query url.Values, def int) (int, error) {
+	v := query.Get("limit")
+	if v == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit: %s", v)
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
+
+// queryTime parses an RFC3339 time or a relative duration such as "24h" (meaning that long ago)
+func queryTime(query url.Values, key string) (time.Time, error) {
+	v := query.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s: %s (use RFC3339 or a duration like 24h)", key, v)
+}
+
+// parseEventFilter builds an EventFilter from the query of GET /events
+func parseEventFilter(query url.Values) (EventFilter, error) {
+	filter := EventFilter{
+		Processes:  queryList(query, "process"),
+		Severities: queryList(query, "severity"),
+		Types:      queryList(query, "type"),
+	}
+	var err error
+	if filter.Since, err = queryTime(query, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = queryTime(query, "until"); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = queryLimit(query, defaultPageLimit); err != nil {
+		return filter, err
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		position, err := decodeCursor("e", cursor)
+		if err != nil {
+			return filter, err
+		}
+		if filter.Before, err = strconv.ParseUint(position, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid cursor")
+		}
+	}
+	return filter, nil
+}
+
+// parseTimelineFilter builds a TimelineFilter from the query of
+// GET /processes/{name}/timeline
+func parseTimelineFilter(query url.Values) (TimelineFilter, error) {
+	filter := TimelineFilter{Check: query.Get("check")}
+	var err error
+	if filter.Since, err = queryTime(query, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = queryLimit(query, defaultPageLimit); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// filterProcesses applies the filters of GET /processes to statuses sorted by
+// name and returns one page plus the cursor of the next page.
+// Without limit or cursor every matching process is returned.
+func filterProcesses(statuses []ProcessStatus, configs map[string]ProcessConfig, query url.Values) ([]ProcessStatus, string, error) {
+	names := queryList(query, "process")
+	states := queryList(query, "state")
+	health := queryList(query, "health")
+	selector := make(map[string]string)
+	for _, label := range queryList(query, "label") {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid label filter: %s (use key=value)", label)
+		}
+		selector[k] = v
+	}
+
+	limit, err := queryLimit(query, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	after := ""
+	if cursor := query.Get("cursor"); cursor != "" {
+		if after, err = decodeCursor("p", cursor); err != nil {
+			return nil, "", err
+		}
+		if limit == 0 {
+			limit = defaultPageLimit
+		}
+	}
+
+	result := []ProcessStatus{}
+	for _, st := range statuses {
+		if after != "" && st.Name <= after {
+			continue
+		}
+		if !matchesAny(names, st.Name) || !matchesAny(states, st.State) || !matchesAny(health, st.Health) {
+			continue
+		}
+		if len(selector) > 0 && !labelsMatch(configs[st.Name].Labels, selector) {
+			continue
+		}
+		if limit > 0 && len(result) == limit {
+			return result, encodeCursor("p", result[len(result)-1].Name), nil
+		}
+		result = append(result, st)
+	}
+	return result, "", nil
+}
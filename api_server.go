@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// APIConfig 内置HTTP服务配置
+type APIConfig struct {
+	Listen      string `yaml:"listen"`       // 监听地址，如 "127.0.0.1:9500"（为空则不启动）
+	EventBuffer int    `yaml:"event_buffer"` // /events 在内存中保留的最近事件数量（默认10000）
+	AdminToken  string `yaml:"admin_token"`  // 修改状态的请求（GET/HEAD 以外的方法）和子进程管理接口代理所需的 Bearer 令牌
+}
+
+// monitorStartTime 记录监控程序启动时间，用于计算运行时长
+var monitorStartTime = time.Now()
+
+// HealthzResponse is the body returned by GET /healthz
+type HealthzResponse struct {
+	Status        string         `json:"status"`
+	Version       string         `json:"version"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Self          SelfUsage      `json:"self"`
+	Container     *ContainerInfo `json:"container,omitempty"`
+	// 超过截止时间被放弃的操作及次数
+	TimedOutOperations map[string]int64 `json:"timed_out_operations,omitempty"`
+	// 正在使用安全模式配置的原因
+	SafeMode string `json:"safe_mode,omitempty"`
+}
+
+// APIServer is the embedded HTTP server of the monitor
+type APIServer struct {
+	config  APIConfig
+	manager *ProcessManager
+	events  EventStore
+	mux     *http.ServeMux
+	jobs    *jobTracker
+	reload  func(ctx context.Context) error // 重新加载配置，reload 任务使用（为空则不可用）
+}
+
+// NewAPIServer creates the HTTP server and registers its routes
+func NewAPIServer(config APIConfig, manager *ProcessManager) *APIServer {
+	s := &APIServer{
+		config:  config,
+		manager: manager,
+		events:  eventHistory,
+		mux:     http.NewServeMux(),
+		jobs:    newJobTracker(),
+	}
+	s.handle("/healthz", s.handleHealthz)
+	s.handle("/status", s.handleStatus)
+	s.handle("/processes", s.handleProcessList)
+	s.handle("/groups/", s.handleGroups)
+	s.handle("/jobs", s.handleJobs)
+	s.handle("/jobs/", s.handleJobs)
+	s.handle("/processes/", s.handleProcesses)
+	s.handle("/metrics", s.handleMetrics)
+	s.handle("/events", s.handleEvents)
+	s.handle("/annotations", s.handleAnnotations)
+	s.handle("/badge/", s.handleBadge)
+	s.handle("/registry", s.handleRegistry)
+	s.handle("/stream", s.handleStream)
+	s.handle("/", s.handleDashboard)
+	promMetricsOnce.Do(func() { registerMetricsSink(promMetrics) })
+	dashboardUpdatesOnce.Do(func() { registerEventSink(dashboardUpdates) })
+	return s
+}
+
+// handle registers a route. Every method other than GET and HEAD changes
+// state and needs api.admin_token; without a token the API is read-only.
+func (s *APIServer) handle(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !s.authorized(r) {
+			s.unauthorized(w)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// loopbackListen reports whether listen only accepts connections from this host
+func loopbackListen(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Run serves HTTP until ctx is cancelled
+func (s *APIServer) Run(ctx context.Context) {
+	if s.config.AdminToken == "" && !loopbackListen(s.config.Listen) {
+		logrus.Errorf("HTTP API not started: api.listen %s is not a loopback address and api.admin_token is not set", s.config.Listen)
+		return
+	}
+	server := &http.Server{
+		Addr:              s.config.Listen,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// 停止时取消请求的上下文，仪表盘的 /stream 连接随之结束
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.jobs.cancelAll()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logrus.Infof("HTTP API listening on %s", s.config.Listen)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logrus.Errorf("HTTP API server failed: %v", err)
+	}
+}
+
+// authorized reports whether r carries api.admin_token as bearer token. A
+// browser cannot add the header to a cross-origin request without a CORS
+// preflight, which the API never allows, so this also stops CSRF.
+func (s *APIServer) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) == 1
+}
+
+// unauthorized rejects a request that lacks a valid bearer token
+func (s *APIServer) unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="processmonitor"`)
+	if s.config.AdminToken == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "request requires api.admin_token, which is not configured (the API is read-only)"})
+		return
+	}
+	writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "request requires a valid bearer token"})
+}
+
+func (s *APIServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, buildHealthz())
+}
+
+// StatusResponse is the body returned by GET /status
+type StatusResponse struct {
+	Monitor   HealthzResponse `json:"monitor"`
+	Summary   map[string]int  `json:"summary"` // 各状态的进程数量
+	Processes []ProcessStatus `json:"processes"`
+}
+
+// handleStatus serves GET /status: monitor health plus every process
+func (s *APIServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, buildStatus(s.manager))
+}
+
+// buildStatus assembles the monitor health and the status of every process
+func buildStatus(manager *ProcessManager) StatusResponse {
+	statuses := manager.Statuses()
+	summary := make(map[string]int)
+	for _, st := range statuses {
+		summary[st.State]++
+	}
+	return StatusResponse{
+		Monitor:   buildHealthz(),
+		Summary:   summary,
+		Processes: statuses,
+	}
+}
+
+// handleProcessList serves GET /processes with optional filters
+// (?process=, ?state=, ?health=, ?label=key=value) and cursor pagination
+func (s *APIServer) handleProcessList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	statuses, next, err := filterProcesses(s.manager.Statuses(), s.manager.ConfigsByName(), r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if next != "" {
+		w.Header().Set(nextCursorHeader, next)
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// handleEvents serves GET /events, newest first, filtered by
+// ?process=, ?severity=, ?type=, ?since=, ?until= and paginated by ?cursor=
+func (s *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	events, more := s.events.Query(filter)
+	if events == nil {
+		events = []Event{}
+	}
+	if more {
+		w.Header().Set(nextCursorHeader, encodeCursor("e", strconv.FormatUint(events[len(events)-1].ID, 10)))
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// handleProcesses serves GET /processes/{name}, POST /processes/{name}/{operation}
+// and the admin API proxy under /processes/{name}/admin/
+func (s *APIServer) handleProcesses(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/processes/"), "/"), "/")
+	name := parts[0]
+	sup, ok := s.manager.Get(name)
+	if !ok || name == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown process: " + name})
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, sup.Status())
+		return
+	}
+	if parts[1] == "admin" {
+		s.handleProcessAdmin(w, r, sup, strings.TrimPrefix(r.URL.Path, "/processes/"+name+"/admin"))
+		return
+	}
+	if len(parts) != 2 {
+		http.Error(w, "usage: POST /processes/{name}/{start|stop|restart|pause|resume|stdin} or GET /processes/{name}/{schedule|timeline}", http.StatusBadRequest)
+		return
+	}
+
+	switch parts[1] {
+	case "schedule":
+		s.handleProcessSchedule(w, r, sup)
+	case "timeline":
+		s.handleProcessTimeline(w, r, sup)
+	case "stdin":
+		s.handleProcessStdin(w, r, name)
+	case "start", "stop", "restart", "pause", "resume":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+		var err error
+		if profile := r.URL.Query().Get("profile"); parts[1] == "restart" && profile != "" {
+			err = sup.Restart(ctx, profile, "API request from "+r.RemoteAddr)
+		} else {
+			err = sup.Send(ctx, parts[1], "API request from "+r.RemoteAddr)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, sup.Status())
+	default:
+		http.Error(w, "unknown operation: "+parts[1], http.StatusNotFound)
+	}
+}
+
+// handleProcessSchedule serves GET /processes/{name}/schedule?days=N: the
+// run windows of the next N days (default 7) with holidays applied
+func (s *APIServer) handleProcessSchedule(w http.ResponseWriter, r *http.Request, sup *ProcessSupervisor) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days := defaultScheduleDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxScheduleDays {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("days must be between 1 and %d", maxScheduleDays)})
+			return
+		}
+		days = n
+	}
+	writeJSON(w, http.StatusOK, scheduleResponse(sup.config, time.Now(), days))
+}
+
+// handleProcessTimeline serves GET /processes/{name}/timeline: the recent
+// check rounds, newest first, filtered by ?since=, ?check= and ?limit=
+func (s *APIServer) handleProcessTimeline(w http.ResponseWriter, r *http.Request, sup *ProcessSupervisor) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter, err := parseTimelineFilter(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, sup.Timeline(filter))
+}
+
+// buildHealthz assembles the health summary of the monitor itself
+func buildHealthz() HealthzResponse {
+	resp := HealthzResponse{
+		Status:        "ok",
+		Version:       version,
+		UptimeSeconds: int64(time.Since(monitorStartTime).Seconds()),
+		Self:          currentSelfUsage(),
+	}
+	if reason := currentSafeMode(); reason != "" {
+		resp.Status = "safe_mode"
+		resp.SafeMode = reason
+	}
+	if ops := timedOutOperations(); len(ops) > 0 {
+		resp.TimedOutOperations = ops
+	}
+	if info := detectContainer(); info.InContainer {
+		resp.Container = &info
+	}
+	return resp
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Debugf("Failed to write JSON response: %v", err)
+	}
+}
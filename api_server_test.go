@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAdminToken is the api.admin_token of test servers that accept changes
+const testAdminToken = "s3cret"
+
+// apiRequest builds a request that carries testAdminToken
+func apiRequest(method, path string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, path, body)
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	return r
+}
+
+func TestAPIProcessEndpoints(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{
+		{Name: "web.exe", Enable: true},
+		{Name: "worker.exe", Enable: true},
+		{Name: "disabled.exe", Enable: false},
+	}})
+	server := NewAPIServer(APIConfig{AdminToken: testAdminToken}, manager)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/processes", http.StatusOK},
+		{http.MethodGet, "/processes/web.exe", http.StatusOK},
+		{http.MethodGet, "/processes/disabled.exe", http.StatusNotFound},
+		{http.MethodGet, "/processes/web.exe/restart", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/processes/web.exe/explode", http.StatusNotFound},
+		// 在线更新只通过控制通道提供
+		{http.MethodPost, "/processes/web.exe/update", http.StatusNotFound},
+		{http.MethodGet, "/processes/web.exe/schedule", http.StatusOK},
+		{http.MethodGet, "/processes/web.exe/schedule?days=0", http.StatusBadRequest},
+		{http.MethodPost, "/processes/web.exe/schedule", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/processes/web.exe/timeline?since=1h&check=port:80", http.StatusOK},
+		{http.MethodGet, "/processes/web.exe/timeline?limit=x", http.StatusBadRequest},
+		{http.MethodGet, "/status", http.StatusOK},
+		{http.MethodPost, "/status", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, apiRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/processes", nil))
+	var statuses []ProcessStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Name != "web.exe" || statuses[1].Name != "worker.exe" {
+		t.Errorf("unexpected process list: %+v", statuses)
+	}
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true, KeepStdin: true}}})
+	sup, _ := manager.Get("web.exe")
+	requests := []struct{ method, path, body string }{
+		{http.MethodPost, "/processes/web.exe/stop", ""},
+		{http.MethodPost, "/processes/web.exe/restart?profile=failover", ""},
+		{http.MethodPost, "/processes/web.exe/stdin", "stop"},
+		{http.MethodPost, "/groups/all/stop", ""},
+		{http.MethodPost, "/jobs", `{"operation": "reload"}`},
+		{http.MethodDelete, "/jobs/1", ""},
+		{http.MethodPost, "/annotations", `{"message": "hi"}`},
+		{http.MethodPut, "/processes/web.exe", ""},
+	}
+	tokens := []struct {
+		configured, sent string
+	}{
+		{"", ""},
+		{"", "Bearer "},
+		{testAdminToken, ""},
+		{testAdminToken, "Bearer guessed"},
+		{testAdminToken, testAdminToken},
+	}
+	for _, tok := range tokens {
+		server := NewAPIServer(APIConfig{AdminToken: tok.configured}, manager)
+		server.reload = func(context.Context) error { t.Error("reload ran without a token"); return nil }
+		for _, req := range requests {
+			r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+			// 跨站表单也只能发送这类请求
+			r.Header.Set("Content-Type", "text/plain")
+			if tok.sent != "" {
+				r.Header.Set("Authorization", tok.sent)
+			}
+			rec := httptest.NewRecorder()
+			server.mux.ServeHTTP(rec, r)
+			if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("token %q/%q: %s %s = %d", tok.configured, tok.sent, req.method, req.path, rec.Code)
+			}
+		}
+		// 读取不需要令牌
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/processes/web.exe", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("token %q: GET = %d", tok.configured, rec.Code)
+		}
+	}
+	if state := sup.Status().State; state == StateStopped {
+		t.Error("unauthenticated stop was executed")
+	}
+}
+
+func TestLoopbackListen(t *testing.T) {
+	tests := []struct {
+		listen string
+		want   bool
+	}{
+		{"127.0.0.1:9500", true},
+		{"[::1]:9500", true},
+		{"localhost:9500", true},
+		{":9500", false},
+		{"0.0.0.0:9500", false},
+		{"10.0.0.5:9500", false},
+		{"monitor:9500", false},
+		{"9500", false},
+	}
+	for _, tt := range tests {
+		if got := loopbackListen(tt.listen); got != tt.want {
+			t.Errorf("loopbackListen(%q) = %v, want %v", tt.listen, got, tt.want)
+		}
+	}
+
+	// 对外监听且没有令牌时不启动
+	done := make(chan struct{})
+	go func() {
+		NewAPIServer(APIConfig{Listen: "0.0.0.0:0"}, NewProcessManager(Config{})).Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("API started on a non-loopback address without admin_token")
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}})
+	server := NewAPIServer(APIConfig{}, manager)
+
+	emitCount("restarts", 1, processTags("web.exe"))
+	emitCount("restarts", 1, processTags("web.exe"))
+	emitTiming("check.latency", 250*time.Millisecond, processTags("web.exe"))
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE restart_total counter",
+		`restart_total{process="web.exe"} 2`,
+		`health_check_duration_seconds_count{process="web.exe"} 1`,
+		`process_up{process="web.exe"} 0`,
+		"# TYPE monitor_uptime_seconds gauge",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestEventsPagination(t *testing.T) {
+	server := NewAPIServer(APIConfig{}, NewProcessManager(Config{}))
+	store := newMemoryEventStore(8)
+	server.events = store
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		severity := SeverityInfo
+		if i%2 == 1 {
+			severity = SeverityCritical
+		}
+		store.HandleEvent(Event{Time: base.Add(time.Duration(i) * time.Minute), Severity: severity, Type: "test", Process: "web.exe"})
+	}
+
+	get := func(path string) ([]Event, string, int) {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var events []Event
+		json.Unmarshal(rec.Body.Bytes(), &events)
+		return events, rec.Header().Get(nextCursorHeader), rec.Code
+	}
+
+	// 容量为8，最早的两个事件已被覆盖；按ID从新到旧返回
+	var ids []uint64
+	path := "/events?limit=3"
+	for {
+		events, next, code := get(path)
+		if code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, code)
+		}
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		if next == "" {
+			break
+		}
+		path = "/events?limit=3&cursor=" + next
+	}
+	want := []uint64{10, 9, 8, 7, 6, 5, 4, 3}
+	if len(ids) != len(want) {
+		t.Fatalf("paged ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("paged ids = %v, want %v", ids, want)
+		}
+	}
+
+	// 新事件不影响已有游标
+	server.events = newMemoryEventStore(100)
+	for i := 0; i < 4; i++ {
+		server.events.(*memoryEventStore).HandleEvent(Event{Time: time.Now(), Type: "test"})
+	}
+	first, next, _ := get("/events?limit=2")
+	server.events.(*memoryEventStore).HandleEvent(Event{Time: time.Now(), Type: "test"})
+	second, _, _ := get("/events?limit=2&cursor=" + next)
+	if len(first) != 2 || len(second) != 2 || first[1].ID != 3 || second[0].ID != 2 || second[1].ID != 1 {
+		t.Errorf("pages after new event: %+v %+v", first, second)
+	}
+	server.events = store
+
+	events, _, _ := get("/events?severity=critical&process=web.exe&until=" + base.Add(6*time.Minute).Format(time.RFC3339))
+	if len(events) != 2 || events[0].ID != 6 || events[1].ID != 4 {
+		t.Errorf("filtered events = %+v", events)
+	}
+	for _, path := range []string{"/events?cursor=bogus", "/events?since=yesterday", "/events?limit=0"} {
+		if _, _, code := get(path); code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, code)
+		}
+	}
+}
+
+func TestProcessListFilters(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{
+		{Name: "a.exe", Enable: true, Labels: map[string]string{"tier": "web"}},
+		{Name: "b.exe", Enable: true, Labels: map[string]string{"tier": "db"}},
+		{Name: "c.exe", Enable: true, Labels: map[string]string{"tier": "web"}},
+	}})
+	server := NewAPIServer(APIConfig{}, manager)
+
+	tests := []struct {
+		path  string
+		names string
+		next  bool
+	}{
+		{"/processes", "a.exe,b.exe,c.exe", false},
+		{"/processes?label=tier=web", "a.exe,c.exe", false},
+		{"/processes?process=b.exe,c.exe", "b.exe,c.exe", false},
+		{"/processes?state=running", "", false},
+		{"/processes?limit=2", "a.exe,b.exe", true},
+		{"/processes?limit=2&cursor=" + encodeCursor("p", "b.exe"), "c.exe", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		var statuses []ProcessStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		var names []string
+		for _, st := range statuses {
+			names = append(names, st.Name)
+		}
+		if got := strings.Join(names, ","); got != tt.names {
+			t.Errorf("GET %s = %q, want %q", tt.path, got, tt.names)
+		}
+		if next := rec.Header().Get(nextCursorHeader) != ""; next != tt.next {
+			t.Errorf("GET %s next cursor present = %v, want %v", tt.path, next, tt.next)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	server := NewAPIServer(APIConfig{AdminToken: testAdminToken}, NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}}))
+	store := newMemoryEventStore(100)
+	server.events = store
+	registerEventSink(store)
+	store.HandleEvent(Event{Time: time.Now(), Severity: SeverityInfo, Type: "process_restarted", Process: "web.exe"})
+
+	post := func(body string) int {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, apiRequest(http.MethodPost, "/annotations", strings.NewReader(body)))
+		return rec.Code
+	}
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"message": "deploy v2.3 started", "process": "web.exe", "author": "ops", "tags": {"version": "v2.3"}}`, http.StatusCreated},
+		{`{"message": "maintenance window"}`, http.StatusCreated},
+		{`{"message": "  "}`, http.StatusBadRequest},
+		{`{"message": "typo", "process": "wbe.exe"}`, http.StatusNotFound},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := post(tt.body); code != tt.status {
+			t.Errorf("POST %s = %d, want %d", tt.body, code, tt.status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/annotations?process=web.exe", nil))
+	var events []Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Message != "deploy v2.3 started" ||
+		events[0].Details["author"] != "ops" || events[0].Details["version"] != "v2.3" {
+		t.Errorf("unexpected annotations: %+v", events)
+	}
+
+	// 注释与其他事件一起出现在 /events 中
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?process=web.exe", nil))
+	json.Unmarshal(rec.Body.Bytes(), &events)
+	if len(events) != 2 || events[0].Type != annotationEvent || events[1].Type != "process_restarted" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
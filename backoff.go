@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = ""          // 正常自动重启
+	BreakerOpen     = "open"      // 停止自动重启
+	BreakerHalfOpen = "half_open" // 熔断后试探性重启一次
+)
+
+// RestartPolicyConfig 自动重启的指数退避与熔断
+type RestartPolicyConfig struct {
+	Backoff            bool    `yaml:"backoff"`               // 连续失败时指数退避
+	InitialDelay       int     `yaml:"initial_delay"`         // 连续第二次重启前的等待（秒，默认5）
+	MaxDelay           int     `yaml:"max_delay"`             // 最长等待（秒，默认300）
+	Multiplier         float64 `yaml:"multiplier"`            // 每次失败等待时间的倍数（默认2）
+	Jitter             float64 `yaml:"jitter"`                // 随机抖动比例 0-1（默认0.2）
+	ResetAfter         int     `yaml:"reset_after"`           // 稳定运行多久后重置退避（秒，默认300）
+	MaxRestartsPerHour int     `yaml:"max_restarts_per_hour"` // 每小时最多自动重启次数，超过后熔断（0表示不限制）
+	FlapThreshold      int     `yaml:"flap_threshold"`        // 连续多少次启动后立即退出触发熔断（0表示不检测）
+	FlapWindow         int     `yaml:"flap_window"`           // 启动后多久内退出视为立即退出（秒，默认10）
+	BreakerReset       int     `yaml:"breaker_reset"`         // 熔断多久后试探性重启一次（秒，0表示只能手动启动）
+	EscalationCommand  string  `yaml:"escalation_command"`    // 熔断时执行的升级命令
+}
+
+func defaultInt(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// restartTracker applies the restart policy of one supervisor. Only used
+// from the Run goroutine.
+type restartTracker struct {
+	policy RestartPolicyConfig
+	random func() float64
+
+	pending     bool        // 已记录本次失败，等待退避结束
+	failures    int         // 连续失败次数
+	quickExits  int         // 连续启动后立即退出的次数
+	restarts    []time.Time // 最近一小时的自动重启
+	nextAttempt time.Time
+	breaker     string
+	openedAt    time.Time
+	tripReason  string
+}
+
+func newRestartTracker(policy RestartPolicyConfig) *restartTracker {
+	return &restartTracker{policy: policy, random: rand.Float64}
+}
+
+// delay returns the backoff before the restart following the given number
+// of consecutive failures. The first restart after a stable run is immediate.
+func (t *restartTracker) delay(failures int) time.Duration {
+	if !t.policy.Backoff || failures <= 1 {
+		return 0
+	}
+	multiplier := t.policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	initial := float64(defaultInt(t.policy.InitialDelay, 5))
+	max := float64(defaultInt(t.policy.MaxDelay, 300))
+	d := math.Min(initial*math.Pow(multiplier, float64(failures-2)), max)
+
+	jitter := t.policy.Jitter
+	if jitter == 0 {
+		jitter = 0.2
+	}
+	if jitter > 0 && jitter <= 1 {
+		// 在 [d*(1-jitter), d*(1+jitter)] 内随机，避免多个进程同时重启
+		d *= 1 + jitter*(2*t.random()-1)
+	}
+	return time.Duration(d * float64(time.Second))
+}
+
+// allow decides whether a restart needed at now may happen. startedAt is the
+// start time of the failed instance. tripped is non-empty when this failure
+// opened the circuit breaker.
+func (t *restartTracker) allow(now, startedAt time.Time) (allowed bool, tripped string) {
+	switch t.breaker {
+	case BreakerOpen:
+		if t.policy.BreakerReset <= 0 || now.Sub(t.openedAt) < time.Duration(t.policy.BreakerReset)*time.Second {
+			return false, ""
+		}
+		t.breaker = BreakerHalfOpen
+		t.pending = false
+		t.restarts = append(t.restarts, now)
+		return true, ""
+	case BreakerHalfOpen:
+		return false, t.trip(now, "restart after circuit breaker reset failed")
+	}
+
+	if !t.pending {
+		t.pending = true
+		ran := now.Sub(startedAt)
+		if startedAt.IsZero() || ran >= time.Duration(defaultInt(t.policy.ResetAfter, 300))*time.Second {
+			t.failures = 0
+		}
+		t.failures++
+		if !startedAt.IsZero() && ran < time.Duration(defaultInt(t.policy.FlapWindow, 10))*time.Second {
+			t.quickExits++
+		} else {
+			t.quickExits = 0
+		}
+		t.nextAttempt = now.Add(t.delay(t.failures))
+
+		if t.policy.FlapThreshold > 0 && t.quickExits >= t.policy.FlapThreshold {
+			return false, t.trip(now, fmt.Sprintf("exited within %ds of starting %d times in a row",
+				defaultInt(t.policy.FlapWindow, 10), t.quickExits))
+		}
+		if max := t.policy.MaxRestartsPerHour; max > 0 {
+			recent := t.restarts[:0]
+			for _, r := range t.restarts {
+				if now.Sub(r) < time.Hour {
+					recent = append(recent, r)
+				}
+			}
+			t.restarts = recent
+			if len(t.restarts) >= max {
+				return false, t.trip(now, fmt.Sprintf("max_restarts_per_hour (%d) exceeded", max))
+			}
+		}
+	}
+
+	if now.Before(t.nextAttempt) {
+		return false, ""
+	}
+	t.pending = false
+	t.restarts = append(t.restarts, now)
+	return true, ""
+}
+
+func (t *restartTracker) trip(now time.Time, reason string) string {
+	t.breaker = BreakerOpen
+	t.openedAt = now
+	t.pending = false
+	t.tripReason = reason
+	return reason
+}
+
+// healthy is called when checks pass; a half-open breaker closes once the
+// trial instance has outlived the flap window.
+func (t *restartTracker) healthy(now, startedAt time.Time) bool {
+	if t.breaker != BreakerHalfOpen || now.Sub(startedAt) < time.Duration(defaultInt(t.policy.FlapWindow, 10))*time.Second {
+		return false
+	}
+	t.reset()
+	return true
+}
+
+// reset closes the breaker and forgets previous failures (manual start/restart)
+func (t *restartTracker) reset() {
+	t.breaker = BreakerClosed
+	t.pending = false
+	t.failures = 0
+	t.quickExits = 0
+	t.restarts = nil
+	t.nextAttempt = time.Time{}
+	t.tripReason = ""
+}
+
+// allowRestart applies the restart policy before an automatic restart and
+// updates the status while the restart is postponed or the breaker is open
+func (s *ProcessSupervisor) allowRestart(reason string) bool {
+	status := s.Status()
+	wasOpen := s.backoff.breaker == BreakerOpen
+	allowed, tripped := s.backoff.allow(time.Now(), status.StartedAt)
+
+	if tripped != "" {
+		s.updateStatus(func(st *ProcessStatus) {
+			st.State = StateFailed
+			st.Breaker = BreakerOpen
+		})
+		emitCount("circuit.open", 1, processTags(s.config.Name))
+		emitEvent(Event{
+			Severity: SeverityCritical,
+			Type:     "circuit_open",
+			Process:  s.config.Name,
+			Message:  fmt.Sprintf("Process %s stopped restarting: %s", s.config.Name, tripped),
+			Details: map[string]string{
+				"reason":        tripped,
+				"last_failure":  reason,
+				"restarts":      strconv.Itoa(status.Restarts),
+				"breaker_reset": strconv.Itoa(s.config.RestartPolicy.BreakerReset),
+			},
+		})
+		if command := s.config.RestartPolicy.EscalationCommand; command != "" {
+			config := s.config
+			go func() {
+				if err := runHook(config, "escalation", command, tripped, status.PID); err != nil {
+					logrus.Errorf("Process %s: %v", config.Name, err)
+				}
+			}()
+		}
+		return false
+	}
+
+	if allowed {
+		if wasOpen {
+			s.updateStatus(func(st *ProcessStatus) { st.Breaker = BreakerHalfOpen })
+			emitEvent(Event{
+				Severity: SeverityWarning,
+				Type:     "circuit_half_open",
+				Process:  s.config.Name,
+				Message:  fmt.Sprintf("Circuit breaker of %s half-open, trying one restart", s.config.Name),
+				Details:  map[string]string{"reason": reason},
+			})
+		}
+		return true
+	}
+	if s.backoff.breaker == BreakerOpen {
+		return false
+	}
+	logrus.Debugf("Backing off restart of %s until %s (%d consecutive failures)",
+		s.config.Name, s.backoff.nextAttempt.Format("15:04:05"), s.backoff.failures)
+	s.updateStatus(func(st *ProcessStatus) { st.State = StateDown })
+	return false
+}
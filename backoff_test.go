@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartBackoffDelay(t *testing.T) {
+	tracker := newRestartTracker(RestartPolicyConfig{Backoff: true, InitialDelay: 5, MaxDelay: 60})
+	tracker.random = func() float64 { return 0.5 } // 抖动为0
+
+	want := []time.Duration{0, 5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, 60 * time.Second, 60 * time.Second}
+	for i, expected := range want {
+		if got := tracker.delay(i + 1); got != expected {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, expected)
+		}
+	}
+
+	tracker.random = func() float64 { return 1 }
+	if got := tracker.delay(2); got != 6*time.Second {
+		t.Errorf("delay with max jitter = %v, want 6s", got)
+	}
+}
+
+func TestRestartTracker(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		policy  RestartPolicyConfig
+		uptime  time.Duration // 每次失败前进程运行的时间
+		step    time.Duration // 两次检查之间的间隔
+		steps   int
+		allowed int    // 期望允许的重启次数
+		breaker string // 期望的熔断器状态
+	}{
+		{"no policy restarts every time", RestartPolicyConfig{}, time.Second, time.Second, 5, 5, BreakerClosed},
+		{"flapping trips breaker", RestartPolicyConfig{FlapThreshold: 3}, time.Second, time.Second, 5, 2, BreakerOpen},
+		{"stable runs do not flap", RestartPolicyConfig{FlapThreshold: 3}, time.Minute, time.Second, 5, 5, BreakerClosed},
+		{"hourly limit trips breaker", RestartPolicyConfig{MaxRestartsPerHour: 3}, time.Minute, time.Minute, 6, 3, BreakerOpen},
+		{"backoff postpones restarts", RestartPolicyConfig{Backoff: true, InitialDelay: 2, Jitter: -1}, time.Second, time.Second, 10, 3, BreakerClosed}, // 0s、2s、4s,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newRestartTracker(tt.policy)
+			now := base
+			startedAt := now.Add(-tt.uptime)
+			allowed := 0
+			for i := 0; i < tt.steps; i++ {
+				if ok, _ := tracker.allow(now, startedAt); ok {
+					allowed++
+					startedAt = now.Add(-tt.uptime + tt.step)
+				}
+				now = now.Add(tt.step)
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d restarts, want %d", allowed, tt.allowed)
+			}
+			if tracker.breaker != tt.breaker {
+				t.Errorf("breaker = %q, want %q", tracker.breaker, tt.breaker)
+			}
+		})
+	}
+}
+
+func TestRestartTrackerBreakerReset(t *testing.T) {
+	tracker := newRestartTracker(RestartPolicyConfig{FlapThreshold: 1, BreakerReset: 60, FlapWindow: 10})
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if ok, tripped := tracker.allow(now, now.Add(-time.Second)); ok || tripped == "" {
+		t.Fatalf("first quick exit should trip the breaker, got allowed=%v tripped=%q", ok, tripped)
+	}
+	if ok, _ := tracker.allow(now.Add(30*time.Second), now); ok {
+		t.Fatal("restart allowed while breaker is open")
+	}
+	if ok, _ := tracker.allow(now.Add(61*time.Second), now); !ok || tracker.breaker != BreakerHalfOpen {
+		t.Fatalf("expected half-open trial restart, got allowed=%v breaker=%q", ok, tracker.breaker)
+	}
+	started := now.Add(61 * time.Second)
+	if tracker.healthy(started.Add(5*time.Second), started) {
+		t.Fatal("breaker closed before the flap window passed")
+	}
+	if !tracker.healthy(started.Add(11*time.Second), started) || tracker.breaker != BreakerClosed {
+		t.Fatalf("breaker should close after a stable trial, got %q", tracker.breaker)
+	}
+
+	// 试探性重启再次失败时重新熔断
+	tracker.allow(now, now.Add(-time.Second))
+	tracker.allow(now.Add(61*time.Second), now)
+	if ok, tripped := tracker.allow(now.Add(63*time.Second), now.Add(61*time.Second)); ok || tripped == "" {
+		t.Fatalf("failed trial should reopen the breaker, got allowed=%v tripped=%q", ok, tripped)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 状态徽章的颜色
+const (
+	badgeGreen  = "#4c1"
+	badgeYellow = "#dfb317"
+	badgeRed    = "#e05d44"
+	badgeGrey   = "#9f9f9f"
+)
+
+// badgeState returns the text and color of the status badge of a process
+func badgeState(status ProcessStatus, now time.Time) (string, string) {
+	switch status.State {
+	case StateRunning:
+		if status.Health == HealthUnhealthy {
+			return "unhealthy", badgeRed
+		}
+		if status.StartedAt.IsZero() {
+			return "up", badgeGreen
+		}
+		return "up " + formatUptime(now.Sub(status.StartedAt)), badgeGreen
+	case StateStarting, StateRestarting, StateWaiting:
+		return status.State, badgeYellow
+	case StateStopped, StatePaused, StateNoSession:
+		return status.State, badgeGrey
+	default:
+		return status.State, badgeRed
+	}
+}
+
+// formatUptime renders d with its two largest units, e.g. "3d 4h" or "12m"
+func formatUptime(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// badgeTextWidth estimates the rendered width of s in 11px Verdana;
+// wide (CJK) characters take about twice the width of Latin ones
+func badgeTextWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if utf8.RuneLen(r) >= 3 {
+			width += 12
+		} else {
+			width += 7
+		}
+	}
+	return width + 10
+}
+
+// renderBadge draws a two-part badge in the common flat style
+func renderBadge(label, message, color string) string {
+	lw, mw := badgeTextWidth(label), badgeTextWidth(message)
+	label, message = html.EscapeString(label), html.EscapeString(message)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, lw+mw, label, message)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, label, message)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, lw+mw)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`, lw, lw, mw, color, lw+mw)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw/2, label, lw/2, label)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw+mw/2, message, lw+mw/2, message)
+	b.WriteString(`</g></svg>`)
+	return b.String()
+}
+
+// handleBadge serves GET /badge/{process}.svg, a status badge for embedding
+// in wiki pages; ?label= replaces the process name on the left
+func (s *APIServer) handleBadge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/badge/")
+	if !strings.HasSuffix(name, ".svg") {
+		http.Error(w, "usage: GET /badge/{process}.svg", http.StatusNotFound)
+		return
+	}
+	name = strings.TrimSuffix(name, ".svg")
+	label := name
+	if l := r.URL.Query().Get("label"); l != "" {
+		label = l
+	}
+
+	status := http.StatusOK
+	message, color := "unknown", badgeGrey
+	if sup, ok := s.manager.Get(name); ok && name != "" {
+		message, color = badgeState(sup.Status(), time.Now())
+	} else {
+		status = http.StatusNotFound
+	}
+	// 徽章嵌入在页面中，禁止 wiki 或代理缓存旧状态
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(status)
+	fmt.Fprint(w, renderBadge(label, message, color))
+}
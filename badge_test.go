@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBadgeState(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status  ProcessStatus
+		message string
+		color   string
+	}{
+		{ProcessStatus{State: StateRunning, StartedAt: now.Add(-(76*time.Hour + 5*time.Minute))}, "up 3d 4h", badgeGreen},
+		{ProcessStatus{State: StateRunning, StartedAt: now.Add(-(2*time.Hour + 7*time.Minute))}, "up 2h 7m", badgeGreen},
+		{ProcessStatus{State: StateRunning, StartedAt: now.Add(-12 * time.Minute)}, "up 12m", badgeGreen},
+		{ProcessStatus{State: StateRunning, StartedAt: now.Add(-30 * time.Second)}, "up 30s", badgeGreen},
+		{ProcessStatus{State: StateRunning, Health: HealthUnhealthy, StartedAt: now}, "unhealthy", badgeRed},
+		{ProcessStatus{State: StateDown}, "down", badgeRed},
+		{ProcessStatus{State: StateFailed}, "failed", badgeRed},
+		{ProcessStatus{State: StateRestarting}, "restarting", badgeYellow},
+		{ProcessStatus{State: StatePaused}, "paused", badgeGrey},
+	}
+	for _, tt := range tests {
+		message, color := badgeState(tt.status, now)
+		if message != tt.message || color != tt.color {
+			t.Errorf("badgeState(%+v) = %q %s, want %q %s", tt.status, message, color, tt.message, tt.color)
+		}
+	}
+}
+
+func TestBadgeEndpoint(t *testing.T) {
+	server := NewAPIServer(APIConfig{}, NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}}))
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/badge/web.exe.svg", http.StatusOK, ">web.exe</text>"},
+		{"/badge/web.exe.svg?label=a%26b", http.StatusOK, ">a&amp;b</text>"},
+		{"/badge/missing.exe.svg", http.StatusNotFound, ">unknown</text>"},
+		{"/badge/web.exe", http.StatusNotFound, "usage"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("GET %s = %d %s, want %d containing %q", tt.path, rec.Code, rec.Body.String(), tt.status, tt.want)
+		}
+	}
+}
@@ -13,14 +13,21 @@ if ($LASTEXITCODE -ne 0) {
 # 组合版本信息
 $version = "$buildTime-$commitId"
 
-# 构建可执行文件
-$buildCmd = "go build -ldflags ""-X main.version=$version"" -o ProcessMonitor.exe"
+# 构建可执行文件（编译产物不纳入版本库）
+$buildCmd = "go build -ldflags ""-X main.version=$version"" -o processmonitor.exe ."
 Write-Host "Building with command: $buildCmd"
 Invoke-Expression $buildCmd
 
-if ($LASTEXITCODE -eq 0) {
-    Write-Host "Build successful! Version: $commitId"
-} else {
+if ($LASTEXITCODE -ne 0) {
     Write-Host "Build failed!"
     exit 1
-}
\ No newline at end of file
+}
+
+# 构建演示用的测试应用（见 demo.md）
+go build -o test_app.exe ./test_app_src
+if ($LASTEXITCODE -ne 0) {
+    Write-Host "Build of test_app.exe failed!"
+    exit 1
+}
+
+Write-Host "Build successful! Version: $commitId"
\ No newline at end of file
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 平台能力
+const (
+	CapRegistry   = "registry"    // Windows 注册表 API
+	CapServices   = "services"    // 服务管理（Windows 服务控制管理器或 systemd）
+	CapICMP       = "icmp"        // 发送 ICMP 回显（ping）
+	CapJobObjects = "job_objects" // Windows 作业对象
+	CapNetNS      = "netns"       // Linux 网络命名空间
+)
+
+// Capability is the result of detecting one platform capability
+type Capability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Detail    string `json:"detail"` // 检测依据或不可用的原因
+}
+
+// Capabilities 本机检测到的平台能力
+type Capabilities []Capability
+
+// hostCapabilities 启动时检测一次；为 nil 表示尚未检测（不降级任何功能）
+var hostCapabilities Capabilities
+
+// get returns the detection result of name
+func (c Capabilities) get(name string) (Capability, bool) {
+	for _, capability := range c {
+		if capability.Name == name {
+			return capability, true
+		}
+	}
+	return Capability{}, false
+}
+
+// available reports whether name was detected; capabilities that were not
+// detected at all are assumed to be available
+func (c Capabilities) available(name string) bool {
+	capability, ok := c.get(name)
+	return !ok || capability.Available
+}
+
+// detectCapabilities probes the capabilities of this host
+func detectCapabilities() Capabilities {
+	caps := detectPlatformCapabilities()
+	return append(caps, netnsCapability())
+}
+
+// degradeConfig disables the configured features that cannot work with caps
+// and returns a note for each of them
+func degradeConfig(config *Config, caps Capabilities) []string {
+	var notes []string
+	if reg, _ := caps.get(CapRegistry); !caps.available(CapRegistry) {
+		for i := range config.RegistryMonitors {
+			rm := &config.RegistryMonitors[i]
+			if rm.Enable {
+				rm.Enable = false
+				notes = append(notes, fmt.Sprintf("registry monitor %q disabled: %s", rm.Name, reg.Detail))
+			}
+		}
+		if config.RegistryStatus.Enable {
+			config.RegistryStatus.Enable = false
+			notes = append(notes, "registry_status disabled: "+reg.Detail)
+		}
+	}
+	if netns, _ := caps.get(CapNetNS); !caps.available(CapNetNS) {
+		for i := range config.Processes {
+			p := &config.Processes[i]
+			// 没有独立网络命名空间时进程无法启动，不监控比反复启动失败更清楚
+			if p.Enable && p.NetNS.Enable {
+				p.Enable = false
+				notes = append(notes, fmt.Sprintf("process %s disabled: netns requested but %s", p.Name, netns.Detail))
+			}
+		}
+	}
+	return notes
+}
+
+// reportCapabilities detects the capabilities of this host, logs a summary
+// and disables the features of config that cannot work here, so they are
+// reported once at startup instead of failing on every check.
+func reportCapabilities(config *Config) {
+	hostCapabilities = detectCapabilities()
+
+	summary := make([]string, 0, len(hostCapabilities))
+	for _, capability := range hostCapabilities {
+		state := "yes"
+		if !capability.Available {
+			state = "no"
+		}
+		summary = append(summary, capability.Name+"="+state)
+	}
+	logrus.Infof("Host capabilities: %s", strings.Join(summary, " "))
+	for _, capability := range hostCapabilities {
+		logrus.Debugf("  - %s: %s", capability.Name, capability.Detail)
+	}
+
+	notes := degradeConfig(config, hostCapabilities)
+	if len(notes) == 0 {
+		return
+	}
+	logrus.Warnf("Capability check disabled %d configured feature(s) that cannot work on this host:", len(notes))
+	for _, note := range notes {
+		logrus.Warnf("  - %s", note)
+	}
+}
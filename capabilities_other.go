@@ -0,0 +1,39 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// detectPlatformCapabilities probes the capabilities available on Unix-like systems
+func detectPlatformCapabilities() Capabilities {
+	caps := Capabilities{
+		{Name: CapRegistry, Detail: "the registry is only available on Windows"},
+		{Name: CapServices, Detail: "no supported service manager (systemd) found"},
+		{Name: CapICMP},
+		{Name: CapJobObjects, Detail: "job objects are only available on Windows"},
+	}
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		caps[1] = Capability{Name: CapServices, Available: true, Detail: "systemd"}
+	}
+	caps[2].Available, caps[2].Detail = icmpCapability()
+	return caps
+}
+
+// icmpCapability tries to open the sockets used for ping: unprivileged ICMP
+// datagram sockets (net.ipv4.ping_group_range on Linux) or raw sockets
+func icmpCapability() (bool, string) {
+	if fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP); err == nil {
+		syscall.Close(fd)
+		return true, "unprivileged ICMP sockets"
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+	if err != nil {
+		return false, "cannot open ICMP sockets: " + err.Error() +
+			" (run as root, grant CAP_NET_RAW or include the group in net.ipv4.ping_group_range)"
+	}
+	syscall.Close(fd)
+	return true, "raw ICMP sockets"
+}
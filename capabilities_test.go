@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDegradeConfig(t *testing.T) {
+	newConfig := func() Config {
+		return Config{
+			Processes: []ProcessConfig{
+				{Name: "api", Enable: true},
+				{Name: "isolated", Enable: true, NetNS: NetNSConfig{Enable: true}},
+			},
+			RegistryMonitors: []RegistryMonitor{{Name: "proxy", Enable: true}, {Name: "off"}},
+			RegistryStatus:   RegistryStatusConfig{Enable: true},
+		}
+	}
+
+	// 全部可用，或者没有检测结果时不降级
+	for _, caps := range []Capabilities{nil, {{Name: CapRegistry, Available: true}, {Name: CapNetNS, Available: true}}} {
+		config := newConfig()
+		if notes := degradeConfig(&config, caps); len(notes) != 0 {
+			t.Errorf("caps %v: unexpected notes %q", caps, notes)
+		}
+	}
+
+	config := newConfig()
+	notes := degradeConfig(&config, Capabilities{
+		{Name: CapRegistry, Detail: "the registry is only available on Windows"},
+		{Name: CapNetNS, Detail: "the monitor lacks CAP_SYS_ADMIN"},
+		{Name: CapICMP},
+	})
+	if len(notes) != 3 {
+		t.Fatalf("notes = %q, want 3", notes)
+	}
+	for i, want := range []string{`registry monitor "proxy" disabled: the registry`, "registry_status disabled", "process isolated disabled: netns requested but the monitor lacks CAP_SYS_ADMIN"} {
+		if !strings.Contains(notes[i], want) {
+			t.Errorf("note %d = %q, want it to contain %q", i, notes[i], want)
+		}
+	}
+	if config.RegistryMonitors[0].Enable || config.RegistryStatus.Enable || config.Processes[1].Enable {
+		t.Errorf("features were not disabled: %+v", config)
+	}
+	if !config.Processes[0].Enable {
+		t.Errorf("process api should stay enabled")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	modIphlpapi         = windows.NewLazySystemDLL("iphlpapi.dll")
+	procIcmpCreateFile  = modIphlpapi.NewProc("IcmpCreateFile")
+	procIcmpCloseHandle = modIphlpapi.NewProc("IcmpCloseHandle")
+)
+
+// detectPlatformCapabilities probes the Windows APIs used by config features
+func detectPlatformCapabilities() Capabilities {
+	caps := Capabilities{
+		{Name: CapRegistry, Available: true, Detail: "registry API"},
+		{Name: CapServices, Available: true, Detail: "service control manager"},
+		{Name: CapICMP, Available: true, Detail: "ICMP helper API (iphlpapi.dll)"},
+		{Name: CapJobObjects, Available: true, Detail: "job objects"},
+	}
+
+	if k, err := registry.OpenKey(registry.LOCAL_MACHINE, "SOFTWARE", registry.QUERY_VALUE); err != nil {
+		caps[0] = Capability{Name: CapRegistry, Detail: fmt.Sprintf("cannot read HKLM\\SOFTWARE: %v", err)}
+	} else {
+		k.Close()
+	}
+
+	if scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT); err != nil {
+		caps[1] = Capability{Name: CapServices, Detail: fmt.Sprintf("cannot connect to the service control manager: %v", err)}
+	} else {
+		windows.CloseServiceHandle(scm)
+	}
+
+	if err := procIcmpCreateFile.Find(); err != nil {
+		caps[2] = Capability{Name: CapICMP, Detail: fmt.Sprintf("ICMP helper API not available: %v", err)}
+	} else if h, _, err := procIcmpCreateFile.Call(); windows.Handle(h) == windows.InvalidHandle {
+		caps[2] = Capability{Name: CapICMP, Detail: fmt.Sprintf("IcmpCreateFile failed: %v", err)}
+	} else {
+		procIcmpCloseHandle.Call(h)
+	}
+
+	// 在不允许嵌套作业的旧系统上，被放入作业中的监控程序无法再创建作业
+	if job, err := windows.CreateJobObject(nil, nil); err != nil {
+		caps[3] = Capability{Name: CapJobObjects, Detail: fmt.Sprintf("cannot create a job object: %v", err)}
+	} else {
+		windows.CloseHandle(job)
+	}
+	return caps
+}
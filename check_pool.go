@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultCheckConcurrency 同时执行的端口和健康检查数量
+const defaultCheckConcurrency = 4
+
+// namedCheck is one port or health check of a cycle
+type namedCheck struct {
+	name string
+	run  func() error
+}
+
+// checkResult is the outcome of a namedCheck
+type checkResult struct {
+	name    string
+	err     error
+	latency time.Duration
+}
+
+// runChecks runs checks with at most workers at a time and returns their
+// results in the order of checks. Checks that have not finished when the
+// deadline passes are reported as failed; the ones already running are
+// abandoned and finish on their own (every check has its own timeout), the
+// ones not yet started are skipped.
+func runChecks(checks []namedCheck, workers int, deadline time.Duration) []checkResult {
+	if workers <= 0 {
+		workers = defaultCheckConcurrency
+	}
+	type indexed struct {
+		index  int
+		result checkResult
+	}
+	done := make(chan indexed, len(checks))
+	expired := make(chan struct{})
+	slots := make(chan struct{}, workers)
+	for i, check := range checks {
+		go func(i int, check namedCheck) {
+			select {
+			case slots <- struct{}{}:
+			case <-expired:
+				return
+			}
+			defer func() { <-slots }()
+			started := time.Now()
+			err := check.run()
+			done <- indexed{i, checkResult{name: check.name, err: err, latency: time.Since(started)}}
+		}(i, check)
+	}
+
+	results := make([]checkResult, len(checks))
+	finished := make([]bool, len(checks))
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+	for remaining := len(checks); remaining > 0; remaining-- {
+		select {
+		case r := <-done:
+			results[r.index] = r.result
+			finished[r.index] = true
+		case <-timer.C:
+			close(expired)
+			for i, check := range checks {
+				if !finished[i] {
+					results[i] = checkResult{name: check.name, err: fmt.Errorf("did not finish within the %v check deadline", deadline), latency: deadline}
+				}
+			}
+			return results
+		}
+	}
+	return results
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunChecksBoundedConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	var checks []namedCheck
+	for i := 0; i < 8; i++ {
+		i := i
+		checks = append(checks, namedCheck{name: fmt.Sprintf("check%d", i), run: func() error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			if i == 5 {
+				return fmt.Errorf("failed")
+			}
+			return nil
+		}})
+	}
+
+	started := time.Now()
+	results := runChecks(checks, 4, 5*time.Second)
+	elapsed := time.Since(started)
+	if p := peak.Load(); p > 4 {
+		t.Errorf("%d checks ran at once, want at most 4", p)
+	}
+	// 8个50ms的检查，4个一组应约100ms完成
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("checks took %v, they did not run concurrently", elapsed)
+	}
+	for i, r := range results {
+		if r.name != fmt.Sprintf("check%d", i) {
+			t.Errorf("result %d is %s, results must keep the order of the checks", i, r.name)
+		}
+		if (r.err != nil) != (i == 5) {
+			t.Errorf("%s: err = %v", r.name, r.err)
+		}
+		if r.latency < 50*time.Millisecond {
+			t.Errorf("%s: latency %v too short", r.name, r.latency)
+		}
+	}
+}
+
+func TestRunChecksDeadline(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	checks := []namedCheck{
+		{name: "fast", run: func() error { return nil }},
+		{name: "hung", run: func() error { <-block; return nil }},
+		{name: "queued", run: func() error { <-block; return nil }},
+	}
+	// 截止时间留有余量，满负载（如 -race）时快速检查也能按时完成
+	started := time.Now()
+	results := runChecks(checks, 2, 500*time.Millisecond)
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("runChecks did not return at the deadline (%v)", elapsed)
+	}
+	if results[0].err != nil {
+		t.Errorf("fast check failed: %v", results[0].err)
+	}
+	for _, r := range results[1:] {
+		if r.err == nil || !strings.Contains(r.err.Error(), "check deadline") {
+			t.Errorf("%s: err = %v, want deadline error", r.name, r.err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// rotatingFile is a size-limited log file with numbered backups
+// (app.log.1 is the newest backup, app.log.<backups> the oldest).
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSizeMB, backups int) *rotatingFile {
+	if maxSizeMB <= 0 {
+		maxSizeMB = 10
+	}
+	if backups <= 0 {
+		backups = 5
+	}
+	return &rotatingFile{path: path, maxSize: int64(maxSizeMB) * 1024 * 1024, backups: backups}
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil && f.size+int64(len(p)) > f.maxSize {
+		f.rotate()
+	}
+	if f.file == nil {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return 0, err
+		}
+		file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return 0, err
+		}
+		f.file = file
+		if info, err := file.Stat(); err == nil {
+			f.size = info.Size()
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts app.log.N-1 -> app.log.N ... app.log -> app.log.1
+func (f *rotatingFile) rotate() {
+	f.file.Close()
+	f.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.backups))
+	for i := f.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	os.Rename(f.path, f.path+".1")
+	f.size = 0
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// linePrefixer splits child output into lines and writes each line with a
+// timestamp and prefix, so output of several streams never interleaves
+// mid-line in the shared file.
+type linePrefixer struct {
+	out    io.Writer
+	prefix string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// maxChildLogLine 超过该长度且没有换行的输出会被强制写出
+const maxChildLogLine = 64 * 1024
+
+func (l *linePrefixer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			if len(l.buf) >= maxChildLogLine {
+				l.writeLine(l.buf)
+				l.buf = l.buf[:0]
+			}
+			return len(p), nil
+		}
+		l.writeLine(bytes.TrimRight(l.buf[:i], "\r"))
+		l.buf = l.buf[i+1:]
+	}
+}
+
+func (l *linePrefixer) writeLine(line []byte) {
+	fmt.Fprintf(l.out, "%s %s %s\n", time.Now().Format("2006-01-02 15:04:05.000"), l.prefix, line)
+}
+
+// Flush writes a trailing partial line, e.g. after the child exited
+func (l *linePrefixer) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) > 0 {
+		l.writeLine(l.buf)
+		l.buf = l.buf[:0]
+	}
+}
+
+// childLog captures stdout and stderr of one managed process into its log_file
+type childLog struct {
+	file   *rotatingFile
+	stdout *linePrefixer
+	stderr *linePrefixer
+}
+
+func newChildLog(config ProcessConfig) *childLog {
+	file := newRotatingFile(config.LogFile, config.LogMaxSize, config.LogBackups)
+	name := filepath.Base(config.Name)
+	return &childLog{
+		file:   file,
+		stdout: &linePrefixer{out: file, prefix: "[" + name + "]"},
+		stderr: &linePrefixer{out: file, prefix: "[" + name + ":stderr]"},
+	}
+}
+
+// Flush writes out partial lines left by the previous instance
+func (c *childLog) Flush() {
+	c.stdout.Flush()
+	c.stderr.Flush()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChildLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	log := newChildLog(ProcessConfig{Name: "/opt/app/server", LogFile: path, LogMaxSize: 1, LogBackups: 2})
+	defer log.file.Close()
+
+	// 写入约3.5MB，应产生两个备份，最旧的被删除
+	line := strings.Repeat("x", 1000)
+	for i := 0; i < 3500; i++ {
+		fmt.Fprintf(log.stdout, "%s\n", line)
+	}
+	log.stderr.Write([]byte("partial"))
+	log.Flush()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("expected %s to exist: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("more backups kept than configured")
+	}
+
+	log.file.Close()
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	last := lines[len(lines)-1]
+	if !strings.HasSuffix(last, "[server:stderr] partial") {
+		t.Errorf("unexpected last line %q", last)
+	}
+	if !strings.Contains(lines[0], "[server] xxx") {
+		t.Errorf("unexpected first line %q", lines[0])
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// CommandQueueConfig 基于文件的命令队列，用于禁止网络API的隔离环境
+type CommandQueueConfig struct {
+	Enable       bool   `yaml:"enable"`        // 是否启用
+	InboxDir     string `yaml:"inbox_dir"`     // 命令文件投放目录（默认 inbox）
+	OutboxDir    string `yaml:"outbox_dir"`    // 结果文件输出目录（默认 outbox）
+	PollInterval int    `yaml:"poll_interval"` // 扫描间隔（秒，默认5）
+}
+
+// queuedCommand is the content of a command file (JSON or YAML)
+type queuedCommand struct {
+	ID      string `yaml:"id" json:"id"`
+	Action  string `yaml:"action" json:"action"`   // start, stop, restart, pause, resume, stdin
+	Process string `yaml:"process" json:"process"` // 目标进程
+	Group   string `yaml:"group" json:"group"`     // 或目标进程组（仅 start/stop/restart）
+	Input   string `yaml:"input" json:"input"`     // stdin 动作写入的内容
+}
+
+// commandResult is written to the outbox for every processed command file
+type commandResult struct {
+	ID          string    `json:"id"`
+	File        string    `json:"file"`
+	Action      string    `json:"action"`
+	Target      string    `json:"target"`
+	Status      string    `json:"status"` // ok 或 error
+	Error       string    `json:"error,omitempty"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// runCommandQueue polls the inbox directory and executes dropped command files
+func runCommandQueue(config CommandQueueConfig, manager *ProcessManager, ctx context.Context) {
+	if config.InboxDir == "" {
+		config.InboxDir = "inbox"
+	}
+	if config.OutboxDir == "" {
+		config.OutboxDir = "outbox"
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 5
+	}
+
+	processedDir := filepath.Join(config.InboxDir, "processed")
+	for _, dir := range []string{config.InboxDir, config.OutboxDir, processedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			logrus.Errorf("Command queue: failed to create %s: %v", dir, err)
+			return
+		}
+	}
+
+	logrus.Infof("Command queue watching %s (results in %s)", config.InboxDir, config.OutboxDir)
+
+	ticker := time.NewTicker(time.Duration(config.PollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			entries, err := os.ReadDir(config.InboxDir)
+			if err != nil {
+				logrus.Errorf("Command queue: failed to read inbox: %v", err)
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || !isCommandFile(entry.Name()) {
+					continue
+				}
+				// 跳过刚写入的文件，避免读取到未写完的内容
+				if info, err := entry.Info(); err != nil || time.Since(info.ModTime()) < time.Second {
+					continue
+				}
+				processCommandFile(ctx, config, manager, entry.Name(), processedDir)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func isCommandFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".json" || ext == ".yaml" || ext == ".yml"
+}
+
+// processCommandFile executes one command file, writes its result and moves it aside
+func processCommandFile(ctx context.Context, config CommandQueueConfig, manager *ProcessManager, name, processedDir string) {
+	path := filepath.Join(config.InboxDir, name)
+	result := commandResult{File: name, ProcessedAt: time.Now()}
+
+	err := func() error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read command file: %v", err)
+		}
+		// YAML 是 JSON 的超集，两种格式都用 YAML 解析
+		var cmd queuedCommand
+		if err := yaml.Unmarshal(data, &cmd); err != nil {
+			return fmt.Errorf("invalid command file: %v", err)
+		}
+		result.ID = cmd.ID
+		result.Action = cmd.Action
+
+		reason := fmt.Sprintf("command file %s", name)
+		cmdCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+		defer cancel()
+
+		switch {
+		case cmd.Group != "":
+			result.Target = "group:" + cmd.Group
+			return manager.GroupAction(cmdCtx, cmd.Group, cmd.Action)
+		case cmd.Process != "" && cmd.Action == "stdin":
+			result.Target = cmd.Process
+			sup, ok := manager.Get(cmd.Process)
+			if !ok {
+				return fmt.Errorf("unknown process: %s", cmd.Process)
+			}
+			return sup.SendInput(cmd.Input)
+		case cmd.Process != "":
+			result.Target = cmd.Process
+			return manager.ProcessAction(cmdCtx, cmd.Process, cmd.Action, reason)
+		default:
+			return fmt.Errorf("command must specify process or group")
+		}
+	}()
+
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		logrus.Warnf("Command queue: %s failed: %v", name, err)
+	} else {
+		result.Status = "ok"
+		logrus.Infof("Command queue: executed %s %s from %s", result.Action, result.Target, name)
+	}
+
+	resultName := strings.TrimSuffix(name, filepath.Ext(name)) + ".result.json"
+	data, _ := json.MarshalIndent(result, "", "  ")
+	// 先写临时文件再重命名，保证读取方不会看到写了一半的结果
+	tmp := filepath.Join(config.OutboxDir, resultName+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logrus.Errorf("Command queue: failed to write result for %s: %v", name, err)
+	} else if err := os.Rename(tmp, filepath.Join(config.OutboxDir, resultName)); err != nil {
+		logrus.Errorf("Command queue: failed to publish result for %s: %v", name, err)
+	}
+
+	processedName := fmt.Sprintf("%s.%s", name, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, filepath.Join(processedDir, processedName)); err != nil {
+		logrus.Errorf("Command queue: failed to move %s out of inbox: %v", name, err)
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsCommandFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"restart.json", true},
+		{"pause.YAML", true},
+		{"resume.yml", true},
+		{"restart.json.tmp", false},
+		{"notes.txt", false},
+		{"restart", false},
+	}
+	for _, tt := range tests {
+		if got := isCommandFile(tt.name); got != tt.want {
+			t.Errorf("isCommandFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessCommandFile(t *testing.T) {
+	dir := t.TempDir()
+	config := CommandQueueConfig{InboxDir: filepath.Join(dir, "inbox"), OutboxDir: filepath.Join(dir, "outbox")}
+	processedDir := filepath.Join(config.InboxDir, "processed")
+	for _, d := range []string{config.InboxDir, config.OutboxDir, processedDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "api", Enable: true, CheckInterval: 10}}})
+	api, _ := manager.Get("api")
+
+	// 代替 Run 循环接收一个命令
+	received := make(chan supervisorCommand, 1)
+	go func() {
+		cmd := <-api.commands
+		received <- cmd
+		cmd.reply <- nil
+	}()
+
+	tests := []struct {
+		file    string
+		content string
+		status  string
+		target  string
+		err     string
+	}{
+		{"restart-api.json", `{"id": "42", "action": "restart", "process": "api"}`, "ok", "api", ""},
+		{"pause.yaml", "id: p1\naction: pause\nprocess: missing\n", "error", "missing", "unknown process: missing"},
+		{"stdin.yml", "action: stdin\nprocess: api\ninput: reload\n", "error", "api", "keep_stdin"},
+		{"group.yml", "action: restart\ngroup: backend\n", "error", "group:backend", "backend"},
+		{"empty.yml", "action: stop\n", "error", "", "must specify process or group"},
+		{"broken.json", `{"action": `, "error", "", "invalid command file"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(filepath.Join(config.InboxDir, tt.file), []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		processCommandFile(context.Background(), config, manager, tt.file, processedDir)
+
+		resultName := strings.TrimSuffix(tt.file, filepath.Ext(tt.file)) + ".result.json"
+		data, err := os.ReadFile(filepath.Join(config.OutboxDir, resultName))
+		if err != nil {
+			t.Errorf("%s: no result file: %v", tt.file, err)
+			continue
+		}
+		var result commandResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatalf("%s: invalid result: %v", tt.file, err)
+		}
+		if result.File != tt.file || result.Status != tt.status || result.Target != tt.target || !strings.Contains(result.Error, tt.err) {
+			t.Errorf("%s: result = %+v, want status %s target %q error %q", tt.file, result, tt.status, tt.target, tt.err)
+		}
+		if _, err := os.Stat(filepath.Join(config.InboxDir, tt.file)); !os.IsNotExist(err) {
+			t.Errorf("%s is still in the inbox", tt.file)
+		}
+	}
+
+	cmd := <-received
+	if cmd.action != "restart" || !strings.Contains(cmd.reason, "restart-api.json") {
+		t.Errorf("supervisor received %s (%s)", cmd.action, cmd.reason)
+	}
+	if processed, _ := os.ReadDir(processedDir); len(processed) != len(tests) {
+		t.Errorf("%d files moved to processed, want %d", len(processed), len(tests))
+	}
+	// 结果先写临时文件再重命名，不留下临时文件
+	if matches, _ := filepath.Glob(filepath.Join(config.OutboxDir, "*.tmp")); len(matches) != 0 {
+		t.Errorf("temporary result files left: %v", matches)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runSubcommand dispatches "processmonitor [flags] <command> [args...]"
+func runSubcommand(configFile string, args []string) error {
+	switch args[0] {
+	case "profile":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			logrus.Warnf("Error loading config, using defaults: %v", err)
+		}
+		return runProfileCommand(config, args[1:])
+	case "group":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		return runGroupCommand(config, args[1:])
+	case "send":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		return runSendCommand(config, args[1:])
+	case "update":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		return runUpdateCommand(config, args[1:])
+	case "annotate":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		return runAnnotateCommand(config, args[1:])
+	case "status", "restart", "reload", "tail":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		return runControlCommand(config, args[0], args[1:])
+	case "validate":
+		return runValidateCommand(configFile, args[1:])
+	case "replay-notifications":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		return runReplayNotificationsCommand(config, args[1:])
+	case "encrypt-config":
+		return runEncryptConfigCommand(configFile, args[1:])
+	case "gen-config":
+		return runGenConfigCommand(args[1:])
+	case "grafana-dashboard":
+		return runGrafanaDashboardCommand(args[1:])
+	case "netns-exec":
+		// 内部使用：在新网络命名空间中启用回环网卡后执行被监控程序
+		return runNetNSExec(args[1:])
+	case "helper":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		return runHelperCommand(config)
+	case "support-bundle":
+		config, err := loadConfig(configFile)
+		if err != nil {
+			logrus.Warnf("Error loading config, bundle will not include live status: %v", err)
+		}
+		return runSupportBundleCommand(configFile, config, args[1:])
+	case "install-service":
+		return installService(configFile, args[1:])
+	case "uninstall-service":
+		return uninstallService(args[1:])
+	case "start-service":
+		return startService(args[1:])
+	case "stop-service":
+		return stopService(args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
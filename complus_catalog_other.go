@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// comAdminCatalog COM+ 仅在Windows上可用
+type comAdminCatalog struct{}
+
+func (comAdminCatalog) application(name string) (complusApp, error) {
+	return complusApp{}, fmt.Errorf("COM+ is only supported on Windows")
+}
+
+func (comAdminCatalog) startApplication(name string) error {
+	return fmt.Errorf("COM+ is only supported on Windows")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// COMAdminActivation 的取值：1 表示服务器应用程序（在 dllhost.exe 中运行）
+const comAdminActivationLocal = 1
+
+// comAdminCatalog reads COM+ applications through COMAdmin.COMAdminCatalog
+type comAdminCatalog struct{}
+
+// withCatalog runs fn with the COM+ catalog on a thread initialized for COM
+func (comAdminCatalog) withCatalog(fn func(catalog *ole.IDispatch) error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		// S_FALSE：本线程已经初始化过
+		if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != 1 {
+			return fmt.Errorf("failed to initialize COM: %v", err)
+		}
+	}
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject("COMAdmin.COMAdminCatalog")
+	if err != nil {
+		return fmt.Errorf("failed to open the COM+ catalog: %v", err)
+	}
+	defer unknown.Release()
+	catalog, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return fmt.Errorf("failed to open the COM+ catalog: %v", err)
+	}
+	defer catalog.Release()
+	return fn(catalog)
+}
+
+// eachItem populates the catalog collection name and calls fn for each item
+func eachItem(catalog *ole.IDispatch, name string, fn func(item *ole.IDispatch) error) error {
+	result, err := oleutil.CallMethod(catalog, "GetCollection", name)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	collection := result.ToIDispatch()
+	defer collection.Release()
+	if _, err := oleutil.CallMethod(collection, "Populate"); err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	count, err := oleutil.GetProperty(collection, "Count")
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	for i := 0; i < int(count.Val); i++ {
+		v, err := oleutil.GetProperty(collection, "Item", i)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		item := v.ToIDispatch()
+		err = fn(item)
+		item.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itemValue reads a property of a catalog item through its Value property
+func itemValue(item *ole.IDispatch, name string) (interface{}, error) {
+	v, err := oleutil.GetProperty(item, "Value", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	defer v.Clear()
+	return v.Value(), nil
+}
+
+// itemString reads a string property of a catalog item, such as Name or Key
+func itemString(item *ole.IDispatch, name string) (string, error) {
+	v, err := oleutil.GetProperty(item, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	defer v.Clear()
+	return v.ToString(), nil
+}
+
+func (c comAdminCatalog) application(name string) (complusApp, error) {
+	var app complusApp
+	found := false
+	err := c.withCatalog(func(catalog *ole.IDispatch) error {
+		err := eachItem(catalog, "Applications", func(item *ole.IDispatch) error {
+			itemName, err := itemString(item, "Name")
+			if err != nil || !strings.EqualFold(itemName, name) {
+				return err
+			}
+			found = true
+			if app.ID, err = itemString(item, "Key"); err != nil {
+				return err
+			}
+			activation, err := itemValue(item, "Activation")
+			if err != nil {
+				return err
+			}
+			app.Server = fmt.Sprint(activation) == fmt.Sprint(comAdminActivationLocal)
+			enabled, err := itemValue(item, "IsEnabled")
+			if err != nil {
+				return err
+			}
+			app.Enabled = enabled == true
+			return nil
+		})
+		if err != nil || !found {
+			return err
+		}
+		// 正在运行的实例，每个实例是一个 dllhost.exe 进程
+		return eachItem(catalog, "ApplicationInstances", func(item *ole.IDispatch) error {
+			id, err := itemValue(item, "Application")
+			if err != nil || !strings.EqualFold(fmt.Sprint(id), app.ID) {
+				return err
+			}
+			pid, err := itemValue(item, "ProcessID")
+			if err != nil {
+				return err
+			}
+			var n int
+			fmt.Sscan(fmt.Sprint(pid), &n)
+			app.PIDs = append(app.PIDs, n)
+			return nil
+		})
+	})
+	if err != nil {
+		return complusApp{}, err
+	}
+	if !found {
+		return complusApp{}, fmt.Errorf("COM+ application %s does not exist", name)
+	}
+	return app, nil
+}
+
+func (c comAdminCatalog) startApplication(name string) error {
+	return c.withCatalog(func(catalog *ole.IDispatch) error {
+		_, err := oleutil.CallMethod(catalog, "StartApplication", name)
+		return err
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultCOMPlusCheckInterval = 30
+
+// COMPlusMonitor 监控一个 COM+ 服务器应用程序（在 dllhost.exe 中运行，无法按进程名区分），
+// 未运行时通过 COM+ 管理目录（COMAdmin.COMAdminCatalog）启动
+type COMPlusMonitor struct {
+	Name          string          `yaml:"name"`           // 应用程序名称（组件服务中显示的名称）
+	CheckInterval int             `yaml:"check_interval"` // 检查间隔（秒，默认30）
+	Restart       bool            `yaml:"restart"`        // 未运行时启动它
+	RestartDelay  int             `yaml:"restart_delay"`  // 发现未运行后等待多久再启动（秒，默认0）
+	Enforce       EnforcementGate `yaml:"enforce"`        // 何时启动应用程序，与服务监控相同
+}
+
+// validate checks one COM+ application monitor
+func (m COMPlusMonitor) validate() error {
+	if strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if m.CheckInterval < 0 || m.RestartDelay < 0 {
+		return fmt.Errorf("check_interval and restart_delay must not be negative")
+	}
+	return nil
+}
+
+// complusApp is what the catalog reports about an application
+type complusApp struct {
+	ID      string // 应用程序ID，dllhost.exe /Processid:{ID}
+	Server  bool   // 服务器应用程序；库应用程序在调用者进程中运行，无法单独启动
+	Enabled bool
+	PIDs    []int // 正在运行的实例的 dllhost.exe 进程
+}
+
+// complusCatalog reads and starts COM+ applications
+type complusCatalog interface {
+	application(name string) (complusApp, error)
+	startApplication(name string) error
+}
+
+// complusWatch holds what one COM+ monitor has already reported, so every
+// deviation is logged and notified once
+type complusWatch struct {
+	config          COMPlusMonitor
+	catalog         complusCatalog
+	problem         string // 已报告的无法监控的原因（不存在、库应用程序等）
+	stoppedReported bool
+	running         bool
+}
+
+// check looks up the application in the catalog and starts it when it is
+// not running and enforcement allows it
+func (w *complusWatch) check(ctx context.Context) {
+	config := w.config
+	app, err := w.catalog.application(config.Name)
+	if err == nil && !app.Server {
+		err = fmt.Errorf("%s is a library application, only server applications can be monitored", config.Name)
+	}
+	if err != nil {
+		if w.problem != err.Error() {
+			logrus.Errorf("COM+ monitor %s: %v", config.Name, err)
+			w.problem = err.Error()
+		}
+		return
+	}
+	if w.problem != "" {
+		logrus.Infof("COM+ application %s is available again, monitoring it", config.Name)
+		w.problem = ""
+	}
+
+	if len(app.PIDs) > 0 {
+		if !w.running {
+			logrus.Infof("COM+ application %s is running (PID: %v)", config.Name, app.PIDs)
+		}
+		w.running = true
+		w.stoppedReported = false
+		return
+	}
+	w.running = false
+
+	enforce, suspendReason := config.Enforce.Active(time.Now())
+	if !config.Restart || !enforce || !app.Enabled {
+		if !w.stoppedReported {
+			w.stoppedReported = true
+			state, reason := "stopped", "restart is off"
+			switch {
+			case !app.Enabled:
+				state, reason = "disabled", "application is disabled"
+			case config.Restart:
+				reason = "enforcement suspended: " + suspendReason
+			}
+			emitEvent(Event{
+				Severity: SeverityWarning,
+				Type:     "service_stopped",
+				Process:  config.Name,
+				Message:  fmt.Sprintf("COM+ application %s is not running (%s)", config.Name, reason),
+				Details:  map[string]string{"kind": "complus", "state": state, "reason": reason, "app_id": app.ID},
+			})
+		}
+		return
+	}
+
+	if config.RestartDelay > 0 {
+		select {
+		case <-time.After(time.Duration(config.RestartDelay) * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+	logrus.Warnf("COM+ application %s is not running, starting it", config.Name)
+	emitCount("restarts", 1, complusTags(config.Name))
+	reason := "application not running"
+	if err := w.catalog.startApplication(config.Name); err != nil {
+		emitEvent(Event{
+			Severity: SeverityWarning,
+			Type:     "restart_failed",
+			Process:  config.Name,
+			Message:  fmt.Sprintf("Failed to start COM+ application %s: %v", config.Name, err),
+			Details:  map[string]string{"kind": "complus", "reason": reason, "error": err.Error(), "app_id": app.ID},
+		})
+		return
+	}
+	w.stoppedReported = false
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "process_restarted",
+		Process:  config.Name,
+		Message:  fmt.Sprintf("Successfully started COM+ application %s", config.Name),
+		Details:  map[string]string{"kind": "complus", "reason": reason, "app_id": app.ID},
+	})
+}
+
+// complusTags returns the metric tags of a monitored COM+ application
+func complusTags(name string) map[string]string {
+	return map[string]string{"complus_app": name}
+}
+
+// MonitorCOMPlusApp 监控 COM+ 应用程序是否在运行，直到 ctx 取消
+func MonitorCOMPlusApp(config COMPlusMonitor, ctx context.Context) {
+	logrus.Infof("Starting COM+ monitor for %s", config.Name)
+	w := &complusWatch{config: config, catalog: comAdminCatalog{}}
+	ticker := time.NewTicker(time.Duration(defaultInt(config.CheckInterval, defaultCOMPlusCheckInterval)) * time.Second)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logrus.Infof("Stopping COM+ monitor %s", config.Name)
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fakeCatalog is a complusCatalog with a single application
+type fakeCatalog struct {
+	app    complusApp
+	err    error
+	starts int
+}
+
+func (f *fakeCatalog) application(name string) (complusApp, error) { return f.app, f.err }
+func (f *fakeCatalog) startApplication(name string) error {
+	f.starts++
+	f.app.PIDs = []int{4242}
+	return nil
+}
+
+func TestCOMPlusWatch(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+	events := func(name string) []string {
+		var types []string
+		for _, e := range sink.events {
+			if e.Process == name {
+				types = append(types, e.Type)
+			}
+		}
+		return types
+	}
+
+	catalog := &fakeCatalog{app: complusApp{ID: "{1}", Server: true, Enabled: true}}
+	w := &complusWatch{config: COMPlusMonitor{Name: "OrderBroker", Restart: true}, catalog: catalog}
+	w.check(context.Background())
+	w.check(context.Background())
+	if catalog.starts != 1 {
+		t.Errorf("application started %d times, want 1", catalog.starts)
+	}
+	if got := events("OrderBroker"); len(got) != 1 || got[0] != "process_restarted" {
+		t.Errorf("events = %v, want [process_restarted]", got)
+	}
+
+	// 禁用的应用程序不启动，只报告一次
+	catalog = &fakeCatalog{app: complusApp{ID: "{2}", Server: true, Enabled: false}}
+	w = &complusWatch{config: COMPlusMonitor{Name: "Billing", Restart: true}, catalog: catalog}
+	w.check(context.Background())
+	w.check(context.Background())
+	if catalog.starts != 0 {
+		t.Error("disabled application was started")
+	}
+	if got := events("Billing"); len(got) != 1 || got[0] != "service_stopped" {
+		t.Errorf("events = %v, want one service_stopped", got)
+	}
+
+	// 库应用程序和不存在的应用程序无法监控，不发出事件
+	for _, catalog := range []*fakeCatalog{
+		{app: complusApp{ID: "{3}", Server: false, Enabled: true}},
+		{err: fmt.Errorf("COM+ application Legacy does not exist")},
+	} {
+		w = &complusWatch{config: COMPlusMonitor{Name: "Legacy", Restart: true}, catalog: catalog}
+		w.check(context.Background())
+		if catalog.starts != 0 || w.problem == "" {
+			t.Errorf("starts = %d, problem = %q", catalog.starts, w.problem)
+		}
+	}
+	if got := events("Legacy"); len(got) != 0 {
+		t.Errorf("events = %v, want none", got)
+	}
+
+	err := validateConfig(Config{COMPlusApps: []COMPlusMonitor{{Name: "OrderBroker"}, {Name: "orderbroker"}, {Name: " "}}})
+	if err == nil {
+		t.Error("invalid complus_apps accepted")
+	}
+}
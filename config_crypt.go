@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 加密配置文件的第一行是 YAML 注释形式的标记，其余为 base64 编码的密文：
+//
+//	#processmonitor-encrypted v1 dpapi
+//	#processmonitor-encrypted v1 aes-256-gcm /etc/processmonitor/config.key
+const encryptedConfigMagic = "#processmonitor-encrypted v1"
+
+// 加密方式
+const (
+	configSchemeDPAPI   = "dpapi"       // Windows DPAPI（本机范围），只能在加密的计算机上解密
+	configSchemeKeyFile = "aes-256-gcm" // 密钥文件中的 256 位密钥
+)
+
+const configKeySize = 32
+
+// configEncryption describes how a config file is encrypted
+type configEncryption struct {
+	Scheme  string
+	KeyFile string // 仅 aes-256-gcm
+}
+
+// isEncryptedConfig reports whether data is an encrypted config file
+func isEncryptedConfig(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedConfigMagic))
+}
+
+// parseEncryptedConfig splits an encrypted config into its header and the
+// decoded ciphertext
+func parseEncryptedConfig(data []byte) (configEncryption, []byte, error) {
+	var enc configEncryption
+	header, body, _ := bytes.Cut(data, []byte("\n"))
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(string(header)), encryptedConfigMagic))
+	if len(fields) == 0 {
+		return enc, nil, fmt.Errorf("encrypted config has no scheme")
+	}
+	enc.Scheme = fields[0]
+	switch enc.Scheme {
+	case configSchemeDPAPI:
+	case configSchemeKeyFile:
+		if len(fields) < 2 {
+			return enc, nil, fmt.Errorf("encrypted config does not name its key file")
+		}
+		enc.KeyFile = strings.Join(fields[1:], " ")
+	default:
+		return enc, nil, fmt.Errorf("unknown config encryption %q", enc.Scheme)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return enc, nil, fmt.Errorf("invalid encrypted config: %v", err)
+	}
+	return enc, ciphertext, nil
+}
+
+// decryptConfig returns the plaintext of an encrypted config file
+func decryptConfig(data []byte) ([]byte, error) {
+	enc, ciphertext, err := parseEncryptedConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	switch enc.Scheme {
+	case configSchemeDPAPI:
+		return dpapiUnprotect(ciphertext)
+	default:
+		key, err := readConfigKey(enc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return openConfigAEAD(key, ciphertext)
+	}
+}
+
+// encryptConfig encrypts plain and formats it as an encrypted config file
+func encryptConfig(plain []byte, enc configEncryption) ([]byte, error) {
+	var ciphertext []byte
+	var err error
+	switch enc.Scheme {
+	case configSchemeDPAPI:
+		ciphertext, err = dpapiProtect(plain)
+	case configSchemeKeyFile:
+		var key []byte
+		if key, err = readConfigKey(enc.KeyFile); err == nil {
+			ciphertext, err = sealConfigAEAD(key, plain)
+		}
+	default:
+		err = fmt.Errorf("unknown config encryption %q", enc.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(encryptedConfigMagic + " " + enc.Scheme)
+	if enc.KeyFile != "" {
+		out.WriteString(" " + enc.KeyFile)
+	}
+	out.WriteString("\n")
+	encoded := base64.StdEncoding.EncodeToString(ciphertext)
+	for len(encoded) > 76 {
+		out.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	out.WriteString(encoded + "\n")
+	return out.Bytes(), nil
+}
+
+// plainConfig returns data decrypted when it is an encrypted config file,
+// and the encryption to use when writing it back
+func plainConfig(data []byte) ([]byte, *configEncryption, error) {
+	if !isEncryptedConfig(data) {
+		return data, nil, nil
+	}
+	enc, _, err := parseEncryptedConfig(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := decryptConfig(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plain, &enc, nil
+}
+
+// sealConfigAEAD encrypts plain with AES-256-GCM; the nonce is prepended
+func sealConfigAEAD(key, plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, []byte(encryptedConfigMagic)), nil
+}
+
+// openConfigAEAD decrypts the output of sealConfigAEAD
+func openConfigAEAD(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted config is truncated")
+	}
+	plain, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], []byte(encryptedConfigMagic))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config (wrong key file?): %v", err)
+	}
+	return plain, nil
+}
+
+// readConfigKey reads the 256-bit key from path
+func readConfigKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config key: %v", err)
+	}
+	if len(key) != configKeySize {
+		return nil, fmt.Errorf("config key %s must be %d bytes, got %d", path, configKeySize, len(key))
+	}
+	return key, nil
+}
+
+// createConfigKey writes a new random key to path, readable by the owner only
+func createConfigKey(path string) error {
+	key := make([]byte, configKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create config key: %v", err)
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runEncryptConfigCommand implements "processmonitor encrypt-config"
+func runEncryptConfigCommand(configFile string, args []string) error {
+	fs := flag.NewFlagSet("encrypt-config", flag.ContinueOnError)
+	keyFile := fs.String("key", defaultConfigKeyFile, "encrypt with this key file, created if missing (default on Windows: DPAPI)")
+	output := fs.String("o", "", "output file (default: the config file itself; standard output with -decrypt)")
+	decrypt := fs.Bool("decrypt", false, "print the decrypted config instead of encrypting it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	if *decrypt {
+		if !isEncryptedConfig(data) {
+			return fmt.Errorf("%s is not encrypted", configFile)
+		}
+		plain, err := decryptConfig(data)
+		if err != nil {
+			return err
+		}
+		if *output == "" {
+			_, err = os.Stdout.Write(plain)
+			return err
+		}
+		return os.WriteFile(*output, plain, 0600)
+	}
+
+	if isEncryptedConfig(data) {
+		return fmt.Errorf("%s is already encrypted", configFile)
+	}
+	var check Config
+	if err := yaml.Unmarshal(data, &check); err != nil {
+		return fmt.Errorf("error parsing config: %v", err)
+	}
+	enc := configEncryption{Scheme: configSchemeDPAPI}
+	if *keyFile != "" {
+		// 密钥文件的路径写入加密配置的第一行，监控程序从任何工作目录启动都能找到它
+		path, err := filepath.Abs(*keyFile)
+		if err != nil {
+			return err
+		}
+		enc = configEncryption{Scheme: configSchemeKeyFile, KeyFile: path}
+		if !fileExists(enc.KeyFile) {
+			if err := createConfigKey(enc.KeyFile); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "created config key %s\n", enc.KeyFile)
+		}
+	}
+	encrypted, err := encryptConfig(data, enc)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt config: %v", err)
+	}
+	// 确认能解密后再覆盖明文
+	if plain, err := decryptConfig(encrypted); err != nil || !bytes.Equal(plain, data) {
+		return fmt.Errorf("encrypted config could not be decrypted again: %v", err)
+	}
+
+	target := *output
+	if target == "" {
+		target = configFile
+	}
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	}
+	// 原地重写，保留配置文件的所有者和 ACL
+	if err := os.WriteFile(target, encrypted, mode); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "config encrypted with %s and written to %s\n", enc.Scheme, target)
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// defaultConfigKeyFile 非 Windows 平台默认使用的配置密钥文件
+const defaultConfigKeyFile = "/etc/processmonitor/config.key"
+
+func dpapiProtect(plain []byte) ([]byte, error) {
+	return nil, fmt.Errorf("DPAPI is only available on Windows")
+}
+
+func dpapiUnprotect(ciphertext []byte) ([]byte, error) {
+	return nil, fmt.Errorf("config was encrypted with DPAPI on a Windows computer")
+}
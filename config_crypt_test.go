@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const plainTestConfig = `processes:
+  - name: "api_server.exe"
+    health_checks: ["http://localhost:8080/health?token=secret"]
+`
+
+func TestEncryptConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	key := filepath.Join(dir, "keys", "config.key")
+	if err := os.WriteFile(path, []byte(plainTestConfig), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := runEncryptConfigCommand(path, []string{"-key", key}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedConfig(data) || bytes.Contains(data, []byte("secret")) {
+		t.Fatalf("config is not encrypted:\n%s", data)
+	}
+	if !strings.HasPrefix(string(data), encryptedConfigMagic+" aes-256-gcm "+key+"\n") {
+		t.Errorf("unexpected header: %q", strings.SplitN(string(data), "\n", 2)[0])
+	}
+	if info, err := os.Stat(key); err != nil || info.Size() != configKeySize {
+		t.Fatalf("key file: %v", err)
+	}
+
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Processes) != 1 || config.Processes[0].Name != "api_server.exe" {
+		t.Errorf("decrypted config = %+v", config.Processes)
+	}
+
+	if err := runEncryptConfigCommand(path, []string{"-key", key}); err == nil {
+		t.Error("encrypting an encrypted config succeeded")
+	}
+	out := filepath.Join(dir, "plain.yaml")
+	if err := runEncryptConfigCommand(path, []string{"-decrypt", "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+	if plain, _ := os.ReadFile(out); string(plain) != plainTestConfig {
+		t.Errorf("decrypted = %q", plain)
+	}
+}
+
+func TestDecryptConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "config.key")
+	if err := createConfigKey(key); err != nil {
+		t.Fatal(err)
+	}
+	enc := configEncryption{Scheme: configSchemeKeyFile, KeyFile: key}
+	data, err := encryptConfig([]byte(plainTestConfig), enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 修改一个密文字节
+	tampered := append([]byte(nil), data...)
+	body := bytes.IndexByte(tampered, '\n') + 10
+	if tampered[body] == 'A' {
+		tampered[body] = 'B'
+	} else {
+		tampered[body] = 'A'
+	}
+	other := filepath.Join(dir, "other.key")
+	if err := createConfigKey(other); err != nil {
+		t.Fatal(err)
+	}
+	wrongKey := bytes.Replace(data, []byte(key), []byte(other), 1)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"tampered", tampered},
+		{"wrong key", wrongKey},
+		{"missing key", bytes.Replace(data, []byte(key), []byte(key+".missing"), 1)},
+		{"unknown scheme", []byte(encryptedConfigMagic + " rot13\nAAAA\n")},
+	}
+	for _, tt := range tests {
+		if _, err := decryptConfig(tt.data); err == nil {
+			t.Errorf("%s: decrypted without error", tt.name)
+		}
+	}
+	if err := createConfigKey(key); err == nil {
+		t.Error("existing key was overwritten")
+	}
+}
+
+func TestConfigDriftEncryptedLocal(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	dir := t.TempDir()
+	key := filepath.Join(dir, "config.key")
+	if err := createConfigKey(key); err != nil {
+		t.Fatal(err)
+	}
+	enc := configEncryption{Scheme: configSchemeKeyFile, KeyFile: key}
+	path := filepath.Join(dir, "config.yaml")
+	local := []byte("reload_interval: 0\n")
+	encrypted, err := encryptConfig(local, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, encrypted, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := []byte("reload_interval: 30\n")
+	w := &configDriftWatch{
+		config: ConfigDriftConfig{Golden: "golden.yaml", Restore: true},
+		path:   path,
+		fetch:  func() ([]byte, error) { return local, nil },
+		now:    time.Now,
+	}
+	// 内容相同的加密配置不是偏差
+	w.check(context.Background())
+	if len(sink.events) != 0 {
+		t.Fatalf("encrypted config reported as drift: %+v", sink.events)
+	}
+
+	w.fetch = func() ([]byte, error) { return golden, nil }
+	w.check(context.Background())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedConfig(data) {
+		t.Fatal("restored config was written in plaintext")
+	}
+	if plain, err := decryptConfig(data); err != nil || string(plain) != string(golden) {
+		t.Errorf("restored config = %q, %v", plain, err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultConfigKeyFile 为空：Windows 上默认使用本机范围的 DPAPI，不需要单独保管密钥
+const defaultConfigKeyFile = ""
+
+// dpapiEntropy 附加熵，其他程序用本机 DPAPI 加密的数据不能冒充配置文件
+var dpapiEntropy = []byte("ProcessMonitor config")
+
+func dataBlob(b []byte) *windows.DataBlob {
+	blob := &windows.DataBlob{Size: uint32(len(b))}
+	if len(b) > 0 {
+		blob.Data = &b[0]
+	}
+	return blob
+}
+
+// blobBytes copies the output of a DPAPI call and frees it
+func blobBytes(blob windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	return append([]byte(nil), unsafe.Slice(blob.Data, blob.Size)...)
+}
+
+// dpapiProtect encrypts plain with the machine key, so any account on this
+// computer (such as the service account) can decrypt it, but no other computer
+func dpapiProtect(plain []byte) ([]byte, error) {
+	var out windows.DataBlob
+	flags := uint32(windows.CRYPTPROTECT_LOCAL_MACHINE | windows.CRYPTPROTECT_UI_FORBIDDEN)
+	if err := windows.CryptProtectData(dataBlob(plain), nil, dataBlob(dpapiEntropy), 0, nil, flags, &out); err != nil {
+		return nil, fmt.Errorf("CryptProtectData failed: %v", err)
+	}
+	return blobBytes(out), nil
+}
+
+// dpapiUnprotect decrypts the output of dpapiProtect
+func dpapiUnprotect(ciphertext []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(dataBlob(ciphertext), nil, dataBlob(dpapiEntropy), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, fmt.Errorf("CryptUnprotectData failed (config encrypted on another computer?): %v", err)
+	}
+	return blobBytes(out), nil
+}
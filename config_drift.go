@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConfigDriftInterval = 300
+	defaultConfigDriftTimeout  = 10
+	maxGoldenConfigSize        = 4 * 1024 * 1024
+)
+
+// ConfigDriftConfig 定期将本地配置文件与标准配置（golden）比较，发现被本地修改时告警，
+// 并可用标准配置覆盖本地配置后重新加载，防止监控程序自身的配置被篡改
+type ConfigDriftConfig struct {
+	Golden   string            `yaml:"golden"`   // 标准配置：文件路径或 http(s) URL（为空则不检查）
+	Interval int               `yaml:"interval"` // 比较间隔（秒，默认300）
+	Restore  bool              `yaml:"restore"`  // 发现偏差时用标准配置覆盖本地配置并重新加载
+	Headers  map[string]string `yaml:"headers"`  // 下载标准配置时附加的请求头，如 Authorization
+	Timeout  int               `yaml:"timeout"`  // 下载超时（秒，默认10）
+	Enforce  EnforcementGate   `yaml:"enforce"`  // 何时还原（时间段/标记文件），与文件监控相同
+}
+
+// validate checks the config drift settings
+func (c ConfigDriftConfig) validate() error {
+	if c.Golden == "" {
+		if c.Restore {
+			return fmt.Errorf("config_drift: restore requires golden")
+		}
+		return nil
+	}
+	if c.Interval < 0 || c.Timeout < 0 {
+		return fmt.Errorf("config_drift: interval and timeout must not be negative")
+	}
+	return nil
+}
+
+// goldenIsURL reports whether the golden config is downloaded
+func (c ConfigDriftConfig) goldenIsURL() bool {
+	lower := strings.ToLower(c.Golden)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fetchGoldenConfig reads the golden config from its file or URL
+func fetchGoldenConfig(ctx context.Context, config ConfigDriftConfig) ([]byte, error) {
+	if !config.goldenIsURL() {
+		return os.ReadFile(config.Golden)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaultInt(config.Timeout, defaultConfigDriftTimeout))*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Golden, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("golden config %s returned HTTP %d", config.Golden, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxGoldenConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxGoldenConfigSize {
+		return nil, fmt.Errorf("golden config %s is larger than %d bytes", config.Golden, maxGoldenConfigSize)
+	}
+	return data, nil
+}
+
+// normalizeConfigText ignores line ending differences, so a golden copy
+// checked out on another platform does not count as drift
+func normalizeConfigText(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
+// configHash returns the SHA-256 of the normalized config text
+func configHash(data []byte) string {
+	sum := sha256.Sum256(normalizeConfigText(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// configDiff returns the first line (1-based) where local differs from
+// golden and how many lines differ in total, comparing line by line
+func configDiff(local, golden []byte) (first, changed int) {
+	a := strings.Split(strings.TrimSuffix(string(normalizeConfigText(local)), "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(string(normalizeConfigText(golden)), "\n"), "\n")
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if i < len(a) && i < len(b) && a[i] == b[i] {
+			continue
+		}
+		if first == 0 {
+			first = i + 1
+		}
+		changed++
+	}
+	return first, changed
+}
+
+// validateGoldenConfig parses and validates the golden config the same way
+// a reload would, so an invalid golden copy is never written over the
+// local file
+func validateGoldenConfig(data []byte) error {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("error parsing golden config: %v", err)
+	}
+	applyConfigDefaults(&config)
+	return validateConfig(config)
+}
+
+// configDriftWatch compares one config file with its golden copy
+type configDriftWatch struct {
+	config ConfigDriftConfig
+	path   string                          // 本地配置文件
+	fetch  func() ([]byte, error)          // 读取标准配置
+	reload func(ctx context.Context) error // 还原后重新加载配置
+	last   string                          // 上一次报告的本地配置哈希，同一偏差只报告一次
+	now    func() time.Time
+}
+
+// check compares the local config with the golden copy once, reporting a
+// new drift and restoring the golden copy when enforcement allows it
+func (w *configDriftWatch) check(ctx context.Context) {
+	golden, err := w.fetch()
+	if err == nil {
+		golden, _, err = plainConfig(golden)
+	}
+	if err != nil {
+		logrus.Errorf("Config drift: failed to read golden config %s: %v", w.config.Golden, err)
+		return
+	}
+	raw, err := os.ReadFile(w.path)
+	if err != nil && !os.IsNotExist(err) {
+		logrus.Errorf("Config drift: failed to read %s: %v", w.path, err)
+		return
+	}
+	exists := err == nil
+	// 加密的配置解密后比较，还原时用同样的方式加密；无法解密的本地配置按偏差处理
+	local, enc, err := plainConfig(raw)
+	if err != nil {
+		logrus.Warnf("Config drift: failed to decrypt %s: %v", w.path, err)
+		local = raw
+	}
+	localHash, goldenHash := configHash(local), configHash(golden)
+	if exists && localHash == goldenHash {
+		if w.last != "" {
+			logrus.Infof("Config %s matches the golden config again", w.path)
+		}
+		w.last = ""
+		return
+	}
+	if !exists {
+		localHash = "missing"
+	}
+	isNew := localHash != w.last
+	w.last = localHash
+
+	enforce, suspendReason := w.config.Enforce.Active(w.now())
+	if isNew {
+		first, changed := configDiff(local, golden)
+		details := map[string]string{
+			"path":          w.path,
+			"golden":        w.config.Golden,
+			"sha256":        localHash,
+			"golden_sha256": goldenHash,
+		}
+		message := fmt.Sprintf("Config %s differs from the golden config (%d lines, first at line %d)", w.path, changed, first)
+		if !exists {
+			message = fmt.Sprintf("Config %s is missing, golden config is %s", w.path, w.config.Golden)
+		} else {
+			details["first_line"] = fmt.Sprintf("%d", first)
+			details["changed_lines"] = fmt.Sprintf("%d", changed)
+		}
+		logrus.Warn(message)
+		emitEvent(Event{Severity: SeverityWarning, Type: "config_drift", Message: message, Details: details})
+		if w.config.Restore && !enforce {
+			logrus.Warnf("Config restore suspended: %s", suspendReason)
+		}
+	}
+	if !w.config.Restore || !enforce {
+		return
+	}
+	if err := validateGoldenConfig(golden); err != nil {
+		// 同一份无效的标准配置只报告一次
+		if isNew {
+			logrus.Errorf("Config drift: not restoring %s, the golden config is invalid: %v", w.path, err)
+		}
+		return
+	}
+	backup, err := w.restore(raw, exists, golden, enc)
+	if err != nil {
+		logrus.Errorf("Config drift: failed to restore %s from the golden config: %v", w.path, err)
+		return
+	}
+	w.last = ""
+	details := map[string]string{
+		"path":          w.path,
+		"golden":        w.config.Golden,
+		"golden_sha256": goldenHash,
+	}
+	if backup != "" {
+		details["backup"] = backup
+	}
+	logrus.Infof("Restored %s from the golden config %s", w.path, w.config.Golden)
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "config_restored",
+		Message:  fmt.Sprintf("Config %s was restored from the golden config %s", w.path, w.config.Golden),
+		Details:  details,
+	})
+	if w.reload != nil {
+		if err := w.reload(ctx); err != nil {
+			logrus.Errorf("Config drift: reload after restore failed: %v", err)
+		}
+	}
+}
+
+// restore keeps the modified config next to the original for review and
+// writes the golden copy over it, encrypted like the local config was. The
+// file is rewritten in place rather than replaced, so its owner and ACL are kept.
+func (w *configDriftWatch) restore(local []byte, exists bool, golden []byte, enc *configEncryption) (string, error) {
+	if enc != nil {
+		encrypted, err := encryptConfig(golden, *enc)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt the golden config: %v", err)
+		}
+		golden = encrypted
+	}
+	backup := ""
+	mode := os.FileMode(0600)
+	if exists {
+		if info, err := os.Stat(w.path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		backup = fmt.Sprintf("%s.drift-%s", w.path, w.now().Format("20060102T150405"))
+		if err := os.WriteFile(backup, local, 0600); err != nil {
+			return "", fmt.Errorf("failed to back up the modified config: %v", err)
+		}
+	} else if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(w.path, golden, mode); err != nil {
+		return backup, err
+	}
+	return backup, nil
+}
+
+// runConfigDrift compares the config file at path with the golden config
+// until ctx is cancelled
+func runConfigDrift(config ConfigDriftConfig, path string, reload func(ctx context.Context) error, ctx context.Context) {
+	logrus.Infof("Comparing %s with the golden config %s", path, config.Golden)
+	w := &configDriftWatch{
+		config: config,
+		path:   path,
+		fetch:  func() ([]byte, error) { return fetchGoldenConfig(ctx, config) },
+		reload: reload,
+		now:    time.Now,
+	}
+	ticker := time.NewTicker(time.Duration(defaultInt(config.Interval, defaultConfigDriftInterval)) * time.Second)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, golden string
+		first, count  int
+	}{
+		{"same", "a\nb\n", "a\nb\n", 0, 0},
+		{"line endings", "a\r\nb\r\n", "a\nb\n", 0, 0},
+		{"changed", "a\nx\nc\ny\n", "a\nb\nc\nd\n", 2, 2},
+		{"appended", "a\nb\nc\n", "a\nb\n", 3, 1},
+		{"missing", "", "a\nb", 1, 2},
+	}
+	for _, tt := range tests {
+		first, count := configDiff([]byte(tt.local), []byte(tt.golden))
+		if first != tt.first || count != tt.count {
+			t.Errorf("%s: configDiff = %d, %d, want %d, %d", tt.name, first, count, tt.first, tt.count)
+		}
+	}
+}
+
+func TestConfigDriftRestore(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	golden := []byte("reload_interval: 30\n")
+	if err := os.WriteFile(path, []byte("reload_interval: 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloads := 0
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	w := &configDriftWatch{
+		config: ConfigDriftConfig{Golden: "golden.yaml", Restore: true},
+		path:   path,
+		fetch:  func() ([]byte, error) { return golden, nil },
+		reload: func(ctx context.Context) error { reloads++; return nil },
+		now:    func() time.Time { return now },
+	}
+	w.check(context.Background())
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != string(golden) {
+		t.Fatalf("config = %q, %v, want the golden config", data, err)
+	}
+	backup := path + ".drift-20240501T100000"
+	if data, err := os.ReadFile(backup); err != nil || string(data) != "reload_interval: 0\n" {
+		t.Errorf("backup = %q, %v", data, err)
+	}
+	if reloads != 1 {
+		t.Errorf("reloads = %d, want 1", reloads)
+	}
+	var types []string
+	for _, e := range sink.events {
+		types = append(types, e.Type)
+	}
+	if len(types) != 2 || types[0] != "config_drift" || types[1] != "config_restored" {
+		t.Fatalf("events = %v, want config_drift and config_restored", types)
+	}
+	if sink.events[0].Details["first_line"] != "1" || sink.events[1].Details["backup"] != backup {
+		t.Errorf("unexpected details: %v, %v", sink.events[0].Details, sink.events[1].Details)
+	}
+
+	// 与标准配置一致时不再报告
+	w.check(context.Background())
+	if len(sink.events) != 2 || reloads != 1 {
+		t.Errorf("matching config reported again: %d events, %d reloads", len(sink.events), reloads)
+	}
+}
+
+func TestConfigDriftInvalidGolden(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	local := []byte("reload_interval: 0\n")
+	if err := os.WriteFile(path, local, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := &configDriftWatch{
+		config: ConfigDriftConfig{Golden: "golden.yaml", Restore: true},
+		path:   path,
+		fetch:  func() ([]byte, error) { return []byte("processes: [\n"), nil },
+		reload: func(ctx context.Context) error { t.Error("reloaded an invalid golden config"); return nil },
+		now:    time.Now,
+	}
+	w.check(context.Background())
+	w.check(context.Background())
+
+	if data, _ := os.ReadFile(path); string(data) != string(local) {
+		t.Errorf("invalid golden config was written: %q", data)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != "config_drift" {
+		t.Errorf("events = %+v, want one config_drift", sink.events)
+	}
+}
+
+func TestFetchGoldenConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("processes: []\n"))
+	}))
+	defer server.Close()
+
+	config := ConfigDriftConfig{Golden: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}
+	data, err := fetchGoldenConfig(context.Background(), config)
+	if err != nil || string(data) != "processes: []\n" {
+		t.Fatalf("fetchGoldenConfig = %q, %v", data, err)
+	}
+	config.Headers = nil
+	if _, err := fetchGoldenConfig(context.Background(), config); err == nil {
+		t.Error("HTTP 401 was not reported")
+	}
+}
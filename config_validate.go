@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+)
+
+// applyConfigDefaults 向后兼容处理：没有指定 enable 字段的进程和注册表监控默认启用
+func applyConfigDefaults(config *Config) {
+	for i := range config.Processes {
+		if !config.Processes[i].Enable {
+			config.Processes[i].Enable = true
+		}
+	}
+	for i := range config.RegistryMonitors {
+		if !config.RegistryMonitors[i].Enable {
+			config.RegistryMonitors[i].Enable = true
+		}
+	}
+}
+
+// validateConfig checks the process and group definitions for mistakes that
+// would make the monitor misbehave, returning every problem found
+func validateConfig(config Config) error {
+	if problems := configProblems(config); len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// configProblems returns the problems of validateConfig one by one; each
+// starts with the section or the name of the item it is about, which the
+// validate command uses to find its line
+func configProblems(config Config) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	names := make(map[string]bool)
+	for i, p := range config.Processes {
+		if strings.TrimSpace(p.Name) == "" {
+			add("processes[%d]: name is empty", i)
+			continue
+		}
+		if names[p.Name] {
+			add("process %s: defined more than once", p.Name)
+		}
+		names[p.Name] = true
+	}
+
+	for _, p := range config.Processes {
+		if p.Name == "" || !p.Enable {
+			continue
+		}
+		if p.CheckInterval <= 0 {
+			add("process %s: check_interval must be greater than 0", p.Name)
+		}
+		if p.RestartDelay < 0 {
+			add("process %s: restart_delay must not be negative", p.Name)
+		}
+		if p.CheckConcurrency < 0 || p.CheckDeadline < 0 {
+			add("process %s: check_concurrency and check_deadline must not be negative", p.Name)
+		}
+		for _, port := range p.Ports {
+			if port <= 0 || port > 65535 {
+				add("process %s: invalid port %d", p.Name, port)
+			}
+		}
+		for _, check := range p.PortChecks {
+			if err := check.validate(); err != nil {
+				add("process %s: %v", p.Name, err)
+			}
+		}
+		if p.OpenFirewall && len(p.Ports) == 0 {
+			add("process %s: open_firewall requires ports", p.Name)
+		}
+		if p.FailureThreshold < 0 || p.SuccessThreshold < 0 {
+			add("process %s: failure_threshold and success_threshold must not be negative", p.Name)
+		}
+		if p.StartupTimeout < 0 {
+			add("process %s: startup_timeout must not be negative", p.Name)
+		}
+		if len(p.ReadinessChecks) > 0 && p.StartupTimeout == 0 {
+			add("process %s: readiness_checks require startup_timeout", p.Name)
+		}
+		for _, check := range p.HealthChecks {
+			if err := check.validate(); err != nil {
+				add("process %s: %v", p.Name, err)
+			}
+		}
+		for _, check := range p.ReadinessChecks {
+			if err := check.validate(); err != nil {
+				add("process %s: readiness check: %v", p.Name, err)
+			}
+		}
+		if p.RunAsPassword != "" && p.RunAsUser == "" {
+			add("process %s: run_as_password requires run_as_user", p.Name)
+		}
+		switch strings.ToLower(p.RunAsLogon) {
+		case "", "interactive", "batch", "service":
+		default:
+			add("process %s: run_as_logon must be interactive, batch or service", p.Name)
+		}
+		if p.RunAsUser != "" && p.SessionMode == SessionModePerSession {
+			add("process %s: run_as_user cannot be used with session_mode per_session", p.Name)
+		}
+		if err := validateProcessGroup(p); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		if err := validateMonitorDeath(p); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		if err := p.PortReserve.validate(p); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		if err := p.PortConflict.validate(p); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		if err := p.Standby.validate(p); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		if err := p.AdminAPI.validate(); err != nil {
+			add("process %s: %v", p.Name, err)
+		} else if p.AdminAPI.URL != "" && config.API.AdminToken == "" {
+			add("process %s: admin_api requires api.admin_token", p.Name)
+		}
+		if err := p.Limits.validate(p); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		for _, err := range validateRestartProfiles(p.RestartProfiles) {
+			add("process %s: %v", p.Name, err)
+		}
+		for _, dep := range p.DependsOn {
+			if !names[dep] {
+				add("process %s: depends_on unknown process %s", p.Name, dep)
+			}
+		}
+		switch p.SessionMode {
+		case SessionModeAny, SessionModePerSession, SessionModeZero:
+		default:
+			add("process %s: invalid session_mode %q", p.Name, p.SessionMode)
+		}
+		if p.MaxCPUPercent < 0 || p.MaxMemoryMB < 0 || p.SustainedSeconds < 0 {
+			add("process %s: max_cpu_percent, max_memory_mb and sustained_seconds must not be negative", p.Name)
+		}
+		switch p.ResourceAction {
+		case "", ResourceActionRestart, ResourceActionAlert:
+		default:
+			add("process %s: invalid resource_action %q", p.Name, p.ResourceAction)
+		}
+		if p.NetNS.Enable {
+			if runtime.GOOS != "linux" {
+				add("process %s: netns is only supported on Linux", p.Name)
+			}
+			for _, forward := range p.NetNS.Ports {
+				if _, _, err := net.SplitHostPort(forward.Listen); err != nil {
+					add("process %s: invalid netns listen address %q", p.Name, forward.Listen)
+				}
+				if forward.Target <= 0 || forward.Target > 65535 {
+					add("process %s: invalid netns target port %d", p.Name, forward.Target)
+				}
+			}
+		}
+		for _, gate := range startGates(p) {
+			if gate.file == "" {
+				if err := gate.check.validate(); err != nil {
+					add("process %s: wait_for: %v", p.Name, err)
+				}
+			}
+		}
+		switch p.Protocol.Channel {
+		case "", ProtocolStdout, ProtocolPipe:
+		default:
+			add("process %s: invalid protocol channel %q (use stdout or pipe)", p.Name, p.Protocol.Channel)
+		}
+		switch p.WaitTimeoutAction {
+		case "", GateTimeoutStart, GateTimeoutStop:
+		default:
+			add("process %s: invalid wait_timeout_action %q", p.Name, p.WaitTimeoutAction)
+		}
+		for _, trigger := range p.LogTriggers {
+			if err := trigger.validate(); err != nil {
+				add("process %s: %v", p.Name, err)
+			}
+		}
+		if _, err := newProcessMatcher(p); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		if err := p.DiskQuota.validate(); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		if err := p.Schedule.validate(); err != nil {
+			add("process %s: %v", p.Name, err)
+		}
+		switch strings.ToLower(p.IntegrityLevel) {
+		case "", "low", "medium":
+		default:
+			add("process %s: invalid integrity_level %q", p.Name, p.IntegrityLevel)
+		}
+	}
+
+	groups := make(map[string]bool)
+	for i, g := range config.Groups {
+		if g.Name == "" {
+			add("groups[%d]: name is empty", i)
+		} else if groups[g.Name] {
+			add("group %s: defined more than once", g.Name)
+		}
+		groups[g.Name] = true
+		for _, member := range g.Members {
+			if !names[member] {
+				add("group %s: unknown member %s", g.Name, member)
+			}
+		}
+	}
+	if _, err := dependencyOrder(configNames(config.Processes), config.Processes); err != nil {
+		add("%v", err)
+	}
+
+	if err := config.DiskHealth.validate(); err != nil {
+		add("%v", err)
+	}
+	if err := config.ConfigDrift.validate(); err != nil {
+		add("%v", err)
+	}
+	if config.ProcessTable.Refresh < 0 {
+		add("process_table: refresh must not be negative")
+	}
+	if eh := config.EventHistory; eh.RetentionDays < 0 || eh.MaxEvents < 0 {
+		add("event_history: retention_days and max_events must not be negative")
+	}
+	if err := config.Storage.validate(); err != nil {
+		add("%v", err)
+	}
+	if config.Storage.Backend != "" && config.EventHistory.Path != "" {
+		add("event_history.path cannot be used with storage, set storage.backend: bolt and storage.path instead")
+	}
+	if lc := config.Logging; lc.MaxSizeMB < 0 || lc.MaxBackups < 0 || lc.MaxAgeDays < -1 {
+		add("logging: max_size_mb and max_backups must not be negative, max_age_days must be -1 or more")
+	}
+
+	for k := range config.Host.Tags {
+		switch {
+		case !hostTagName.MatchString(k):
+			add("host.tags: invalid tag name %q (use letters, digits and _)", k)
+		case k == "host" || k == "ip" || k == "os" || k == "os_version":
+			add("host.tags: %s is reserved", k)
+		}
+	}
+	if ip := config.Host.IP; ip != "" && net.ParseIP(ip) == nil {
+		add("host.ip: invalid IP address %q", ip)
+	}
+
+	services := make(map[string]bool)
+	for i, sm := range config.ServiceMonitors {
+		if err := sm.validate(); err != nil {
+			add("service_monitors[%d]: %v", i, err)
+			continue
+		}
+		// 服务名不区分大小写
+		if services[strings.ToLower(sm.Name)] {
+			add("service monitor %s: defined more than once", sm.Name)
+		}
+		services[strings.ToLower(sm.Name)] = true
+	}
+
+	pools := make(map[string]bool)
+	for i, pm := range config.IIS.AppPools {
+		if err := pm.validate(); err != nil {
+			add("iis.app_pools[%d]: %v", i, err)
+			continue
+		}
+		if pools[strings.ToLower(pm.Name)] {
+			add("iis app pool %s: defined more than once", pm.Name)
+		}
+		pools[strings.ToLower(pm.Name)] = true
+	}
+	complusApps := make(map[string]bool)
+	for i, cm := range config.COMPlusApps {
+		if err := cm.validate(); err != nil {
+			add("complus_apps[%d]: %v", i, err)
+			continue
+		}
+		// COM+ 应用程序名称不区分大小写
+		if complusApps[strings.ToLower(cm.Name)] {
+			add("COM+ monitor %s: defined more than once", cm.Name)
+		}
+		complusApps[strings.ToLower(cm.Name)] = true
+	}
+	if api := config.IIS.AdminAPI; api.URL != "" {
+		if !strings.HasPrefix(api.URL, "http://") && !strings.HasPrefix(api.URL, "https://") {
+			add("iis.admin_api: url must be an http:// or https:// URL")
+		}
+		if api.AccessToken == "" {
+			add("iis.admin_api: access_token is required")
+		}
+	}
+
+	tasks := make(map[string]bool)
+	for i, task := range config.Tasks {
+		if err := task.validate(); err != nil {
+			add("tasks[%d]: %v", i, err)
+			continue
+		}
+		if tasks[task.Name] {
+			add("task %s: defined more than once", task.Name)
+		}
+		tasks[task.Name] = true
+	}
+
+	monitorNames := make(map[string]bool)
+	baselines := make(map[string]string)
+	for i, fm := range config.FileMonitors {
+		if fm.Name == "" || fm.Path == "" {
+			add("file_monitors[%d]: name and path are required", i)
+			continue
+		}
+		if monitorNames[fm.Name] {
+			add("file monitor %s: defined more than once", fm.Name)
+		}
+		monitorNames[fm.Name] = true
+		if other, ok := baselines[fm.baselineFile()]; ok {
+			add("file monitor %s: baseline %s is also used by %s", fm.Name, fm.baselineFile(), other)
+		}
+		baselines[fm.baselineFile()] = fm.Name
+		if fm.CheckInterval < 0 {
+			add("file monitor %s: check_interval must not be negative", fm.Name)
+		}
+		if fm.ExecuteOnChange && fm.Command == "" {
+			add("file monitor %s: execute_on_change requires a command", fm.Name)
+		}
+	}
+
+	if sp := config.StatusPage; sp.Listen != "" {
+		if _, _, err := net.SplitHostPort(sp.Listen); err != nil {
+			add("status_page: invalid listen address %q", sp.Listen)
+		} else if sp.Listen == config.API.Listen {
+			add("status_page: listen must differ from api.listen")
+		}
+		if len(sp.Processes) == 0 {
+			add("status_page: no processes selected")
+		}
+		for _, p := range sp.Processes {
+			if !names[p.Name] {
+				add("status_page: unknown process %s", p.Name)
+			}
+		}
+		if sp.Refresh < 0 {
+			add("status_page: refresh must not be negative")
+		}
+	}
+
+	for i, w := range config.Notifications.Webhooks {
+		if !strings.HasPrefix(w.URL, "http://") && !strings.HasPrefix(w.URL, "https://") {
+			add("notifications.webhooks[%d]: url must be an http:// or https:// URL", i)
+		}
+		if _, err := parseWebhookTemplate(w); err != nil {
+			add("notifications.webhooks[%d]: invalid template: %v", i, err)
+		}
+	}
+	if err := config.Notifications.Queue.validate(); err != nil {
+		add("%v", err)
+	}
+	if mail := config.Notifications.SMTP; mail.Server != "" {
+		if mail.From == "" || len(mail.To) == 0 {
+			add("notifications.smtp: from and to are required")
+		}
+		switch mail.TLS {
+		case "", "starttls", "tls", "none":
+		default:
+			add("notifications.smtp: invalid tls %q (use starttls, tls or none)", mail.TLS)
+		}
+	}
+	if el := config.Notifications.EventLog; el.Enable {
+		switch el.MinSeverity {
+		case "", SeverityInfo, SeverityWarning, SeverityCritical:
+		default:
+			add("notifications.event_log: invalid min_severity %q (use info, warning or critical)", el.MinSeverity)
+		}
+	}
+
+	return problems
+}
+
+func configNames(processes []ProcessConfig) []string {
+	var names []string
+	for _, p := range processes {
+		names = append(names, p.Name)
+	}
+	return names
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	valid := func(name string) ProcessConfig {
+		return ProcessConfig{Name: name, Enable: true, CheckInterval: 5}
+	}
+	tests := []struct {
+		name    string
+		config  Config
+		problem string // 为空表示应当通过校验
+	}{
+		{"valid", Config{Processes: []ProcessConfig{valid("a.exe"), valid("b.exe")}}, ""},
+		{"duplicate name", Config{Processes: []ProcessConfig{valid("a.exe"), valid("a.exe")}}, "defined more than once"},
+		{"zero check interval", Config{Processes: []ProcessConfig{{Name: "a.exe", Enable: true}}}, "check_interval"},
+		{"unknown dependency", Config{Processes: []ProcessConfig{func() ProcessConfig {
+			p := valid("a.exe")
+			p.DependsOn = []string{"db.exe"}
+			return p
+		}()}}, "depends_on unknown process db.exe"},
+		{"bad health check", Config{Processes: []ProcessConfig{func() ProcessConfig {
+			p := valid("a.exe")
+			p.HealthChecks = []HealthCheck{parseHealthCheck("localhost:8080/health")}
+			return p
+		}()}}, "http://"},
+		{"regex without pattern", Config{Processes: []ProcessConfig{func() ProcessConfig {
+			p := valid("a.exe")
+			p.MatchMode = MatchRegex
+			return p
+		}()}}, "match_pattern"},
+		{"unknown group member", Config{
+			Processes: []ProcessConfig{valid("a.exe")},
+			Groups:    []GroupConfig{{Name: "web", Members: []string{"b.exe"}}},
+		}, "unknown member b.exe"},
+		{"notification queue without dir", Config{Notifications: NotificationsConfig{Queue: NotifyQueueConfig{MaxAge: 48}}}, "notifications.queue: dir is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(tt.config)
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestShippedConfigIsValid(t *testing.T) {
+	if _, err := loadValidConfig("config.yaml"); err != nil {
+		t.Errorf("config.yaml: %v", err)
+	}
+}
+
+func TestSafeModeSwitching(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "config.yaml")
+	safePath := filepath.Join(dir, "safe.yaml")
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(safePath, "processes: []\n")
+	write(primaryPath, "safe_mode:\n  config: "+safePath+"\nprocesses: []\n")
+
+	primary, err := loadValidConfig(primaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewProcessManager(primary)
+	c := newConfigController(primaryPath, primary, nil, manager)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer setSafeMode("")
+
+	// 重新加载得到无效配置：切换到安全模式
+	write(primaryPath, "safe_mode:\n  config: "+safePath+"\nprocesses:\n  - name: a.exe\n    enable: true\n")
+	c.reload()
+	c.apply(ctx)
+	if !c.activeSafe || !strings.Contains(currentSafeMode(), "check_interval") {
+		t.Fatalf("expected safe mode after invalid reload, reason %q", currentSafeMode())
+	}
+
+	// 配置修正后恢复主配置
+	write(primaryPath, "safe_mode:\n  config: "+safePath+"\nprocesses: []\n")
+	c.reload()
+	c.apply(ctx)
+	if c.activeSafe || currentSafeMode() != "" {
+		t.Fatalf("expected primary config after fix, reason %q", currentSafeMode())
+	}
+
+	// 资源压力同样触发安全模式
+	c.pressure = "host under resource pressure: [memory 97%]"
+	c.apply(ctx)
+	if !c.activeSafe {
+		t.Fatal("expected safe mode under resource pressure")
+	}
+}
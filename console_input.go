@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// stdinWriteTimeout 子进程不读取标准输入时，写入最多等待的时间
+const stdinWriteTimeout = 5 * time.Second
+
+// openStdin creates the stdin pipe for a new child when keep_stdin is set.
+// The returned reader is handed to the child; the writer is kept for SendInput.
+func (s *ProcessSupervisor) openStdin() (*os.File, error) {
+	s.closeStdin()
+	if !s.config.KeepStdin {
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	s.stdinMu.Lock()
+	s.stdin = w
+	s.stdinMu.Unlock()
+	return r, nil
+}
+
+// closeStdin closes the writer side of the child's stdin, if any
+func (s *ProcessSupervisor) closeStdin() {
+	s.stdinMu.Lock()
+	defer s.stdinMu.Unlock()
+	if s.stdin != nil {
+		s.stdin.Close()
+		s.stdin = nil
+	}
+}
+
+// SendInput writes lines to the child's stdin, e.g. "stop" or "reload" for
+// legacy console services controlled by typed commands.
+func (s *ProcessSupervisor) SendInput(input string) error {
+	if !s.config.KeepStdin {
+		return fmt.Errorf("process %s does not have keep_stdin enabled", s.config.Name)
+	}
+	if !strings.HasSuffix(input, "\n") {
+		input += "\n"
+	}
+	if runtime.GOOS == "windows" {
+		// Windows 控制台程序通常按 CRLF 读取一行
+		input = strings.ReplaceAll(strings.ReplaceAll(input, "\r\n", "\n"), "\n", "\r\n")
+	}
+
+	s.stdinMu.Lock()
+	defer s.stdinMu.Unlock()
+	if s.stdin == nil {
+		return fmt.Errorf("process %s is not running", s.config.Name)
+	}
+
+	done := make(chan error, 1)
+	go func(w io.Writer) {
+		_, err := io.WriteString(w, input)
+		done <- err
+	}(s.stdin)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to write to stdin of %s: %v", s.config.Name, err)
+		}
+		return nil
+	case <-time.After(stdinWriteTimeout):
+		return fmt.Errorf("timed out writing to stdin of %s (process is not reading input)", s.config.Name)
+	}
+}
+
+// handleProcessStdin serves POST /processes/{name}/stdin; the body is sent
+// as input. Like every POST it needs api.admin_token (see APIServer.handle).
+func (s *APIServer) handleProcessStdin(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sup, ok := s.manager.Get(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown process: " + name})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := sup.SendInput(string(body)); err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"result": "ok"})
+}
+
+// runSendCommand implements "processmonitor send <process> <text...>"
+// by asking the running monitor over its HTTP API.
+func runSendCommand(config Config, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: processmonitor send <process> <text>")
+	}
+	if config.API.Listen == "" {
+		return fmt.Errorf("send requires api.listen to be configured")
+	}
+
+	resp, err := postAPI(config.API, "/processes/"+args[0]+"/stdin", "text/plain", strings.NewReader(strings.Join(args[1:], " ")), 30*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to contact monitor: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("send to %s failed: %s", args[0], strings.TrimSpace(string(body)))
+	}
+	fmt.Printf("sent to %s\n", args[0])
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readInputLine reads the next line the child would see on stdin
+func readInputLine(t *testing.T, r *bufio.Reader) string {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read stdin: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func TestSendInput(t *testing.T) {
+	s := NewProcessSupervisor(ProcessConfig{Name: "console.exe"})
+	if err := s.SendInput("stop"); err == nil || !strings.Contains(err.Error(), "keep_stdin") {
+		t.Errorf("without keep_stdin: err = %v", err)
+	}
+
+	s = NewProcessSupervisor(ProcessConfig{Name: "console.exe", KeepStdin: true})
+	if err := s.SendInput("stop"); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("before start: err = %v", err)
+	}
+	stdin, err := s.openStdin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	r := bufio.NewReader(stdin)
+
+	// 没有换行时自动补上，一次可以发送多行
+	if err := s.SendInput("reload"); err != nil {
+		t.Fatal(err)
+	}
+	if line := readInputLine(t, r); line != "reload" {
+		t.Errorf("stdin = %q, want reload", line)
+	}
+	if err := s.SendInput("set level 2\nsave\n"); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := readInputLine(t, r), readInputLine(t, r); a != "set level 2" || b != "save" {
+		t.Errorf("stdin = %q, %q", a, b)
+	}
+
+	s.closeStdin()
+	if err := s.SendInput("stop"); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("after close: err = %v", err)
+	}
+}
+
+func TestSendCommand(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "console.exe", Enable: true, KeepStdin: true}}})
+	sup, _ := manager.Get("console.exe")
+	stdin, err := sup.openStdin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer sup.closeStdin()
+	srv := httptest.NewServer(NewAPIServer(APIConfig{AdminToken: "s3cret"}, manager).mux)
+	defer srv.Close()
+	config := Config{API: APIConfig{Listen: srv.Listener.Addr().String(), AdminToken: "s3cret"}}
+
+	if err := runSendCommand(config, []string{"console.exe", "say", "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if line := readInputLine(t, bufio.NewReader(stdin)); line != "say hello" {
+		t.Errorf("stdin = %q, want %q", line, "say hello")
+	}
+
+	tests := []struct {
+		config Config
+		args   []string
+		want   string
+	}{
+		{config, []string{"console.exe"}, "usage"},
+		{Config{}, []string{"console.exe", "stop"}, "api.listen"},
+		{config, []string{"missing.exe", "stop"}, "unknown process"},
+		{Config{API: APIConfig{Listen: config.API.Listen}}, []string{"console.exe", "stop"}, "bearer token"},
+		{Config{API: APIConfig{Listen: config.API.Listen, AdminToken: "guessed"}}, []string{"console.exe", "stop"}, "bearer token"},
+	}
+	for _, tt := range tests {
+		if err := runSendCommand(tt.config, tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("send %v: err = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
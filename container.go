@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// ContainerInfo describes the container environment the monitor runs in
+type ContainerInfo struct {
+	InContainer      bool    `json:"in_container"`
+	Runtime          string  `json:"runtime,omitempty"`        // docker, podman, kubernetes, containerd, lxc
+	CgroupVersion    int     `json:"cgroup_version,omitempty"` // 1 或 2
+	CPULimitCores    float64 `json:"cpu_limit_cores,omitempty"`
+	MemoryLimitMB    float64 `json:"memory_limit_mb,omitempty"`
+	PIDNamespace     bool    `json:"pid_namespace"`     // 是否处于独立的PID命名空间
+	VisibleProcesses int     `json:"visible_processes"` // 可见的进程数量
+}
+
+var (
+	containerInfoOnce sync.Once
+	containerInfo     ContainerInfo
+)
+
+// detectContainer returns (and caches) information about the container environment
+func detectContainer() ContainerInfo {
+	containerInfoOnce.Do(func() {
+		containerInfo = readContainerInfo("/")
+		if procs, err := process.Pids(); err == nil {
+			containerInfo.VisibleProcesses = len(procs)
+		}
+	})
+	return containerInfo
+}
+
+// readContainerInfo inspects marker files, cgroups and /proc below root
+func readContainerInfo(root string) ContainerInfo {
+	var info ContainerInfo
+	path := func(p string) string { return strings.TrimSuffix(root, "/") + p }
+
+	cgroup, _ := os.ReadFile(path("/proc/1/cgroup"))
+	switch {
+	case fileExists(path("/.dockerenv")):
+		info.Runtime = "docker"
+	case fileExists(path("/run/.containerenv")):
+		info.Runtime = "podman"
+	case strings.Contains(string(cgroup), "kubepods") || os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		info.Runtime = "kubernetes"
+	case strings.Contains(string(cgroup), "docker"):
+		info.Runtime = "docker"
+	case strings.Contains(string(cgroup), "containerd"):
+		info.Runtime = "containerd"
+	case strings.Contains(string(cgroup), "lxc"):
+		info.Runtime = "lxc"
+	}
+	info.InContainer = info.Runtime != ""
+
+	// cgroup v2: 统一层级，存在 cgroup.controllers
+	if fileExists(path("/sys/fs/cgroup/cgroup.controllers")) {
+		info.CgroupVersion = 2
+		if data, err := os.ReadFile(path("/sys/fs/cgroup/cpu.max")); err == nil {
+			fields := strings.Fields(string(data))
+			if len(fields) == 2 && fields[0] != "max" {
+				quota, err1 := strconv.ParseFloat(fields[0], 64)
+				period, err2 := strconv.ParseFloat(fields[1], 64)
+				if err1 == nil && err2 == nil && period > 0 {
+					info.CPULimitCores = quota / period
+				}
+			}
+		}
+		if data, err := os.ReadFile(path("/sys/fs/cgroup/memory.max")); err == nil {
+			if limit, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64); err == nil {
+				info.MemoryLimitMB = limit / 1024 / 1024
+			}
+		}
+	} else if fileExists(path("/sys/fs/cgroup/memory")) || fileExists(path("/sys/fs/cgroup/cpu")) {
+		info.CgroupVersion = 1
+		quota := readIntFile(path("/sys/fs/cgroup/cpu/cpu.cfs_quota_us"))
+		period := readIntFile(path("/sys/fs/cgroup/cpu/cpu.cfs_period_us"))
+		if quota > 0 && period > 0 {
+			info.CPULimitCores = float64(quota) / float64(period)
+		}
+		// cgroup v1 未限制内存时返回一个接近 int64 最大值的数
+		if limit := readIntFile(path("/sys/fs/cgroup/memory/memory.limit_in_bytes")); limit > 0 && limit < 1<<60 {
+			info.MemoryLimitMB = float64(limit) / 1024 / 1024
+		}
+	}
+
+	// NSpid 有多个值表示进程位于嵌套的PID命名空间中
+	if status, err := os.ReadFile(path("/proc/self/status")); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if strings.HasPrefix(line, "NSpid:") {
+				info.PIDNamespace = len(strings.Fields(strings.TrimPrefix(line, "NSpid:"))) > 1
+				break
+			}
+		}
+	}
+
+	return info
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func readIntFile(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// adjustCPUThreshold scales a per-core CPU percentage threshold down to what a
+// process can actually reach under a fractional cgroup CPU quota.
+func (c ContainerInfo) adjustCPUThreshold(threshold float64) float64 {
+	if c.CPULimitCores > 0 && c.CPULimitCores < 1 {
+		return threshold * c.CPULimitCores
+	}
+	return threshold
+}
+
+// adjustMemoryThreshold caps a memory threshold at 90% of the cgroup memory limit
+func (c ContainerInfo) adjustMemoryThreshold(thresholdMB float64) float64 {
+	if c.MemoryLimitMB > 0 && thresholdMB > c.MemoryLimitMB*0.9 {
+		return c.MemoryLimitMB * 0.9
+	}
+	return thresholdMB
+}
+
+// reportContainer logs the container environment and its effect on process visibility
+func reportContainer(config Config) {
+	info := detectContainer()
+	if !info.InContainer {
+		return
+	}
+
+	logrus.Infof("Running inside a %s container (cgroup v%d, CPU limit: %.2f cores, memory limit: %.0fMB)",
+		info.Runtime, info.CgroupVersion, info.CPULimitCores, info.MemoryLimitMB)
+
+	if info.PIDNamespace {
+		logrus.Warnf("Container has its own PID namespace: only %d processes inside the container are visible; "+
+			"host processes cannot be monitored unless the container runs with --pid=host", info.VisibleProcesses)
+		for _, p := range config.Processes {
+			if !p.Enable {
+				continue
+			}
+			if running, err := isProcessRunning(p.Name); err == nil && !running {
+				logrus.Warnf("Process %s is not visible in this PID namespace; it will be started inside the container", p.Name)
+			}
+		}
+	}
+}
+
+// processMatches reports whether e matches the configured process name.
+// When exe and cmdline cannot be read (e.g. /proc mounted with hidepid or
+// processes owned by other users inside a container) the short name from
+// /proc/<pid>/status is used instead of silently matching nothing.
+func processMatches(e processEntry, processName string) bool {
+	// Check both executable path and command line
+	if strings.Contains(e.exe, processName) || strings.Contains(e.cmdline, processName) {
+		return true
+	}
+	if e.exe == "" && e.cmdline == "" && e.name != "" {
+		// /proc/<pid>/status 中的名称最多15个字符
+		return e.name == processName || (len(e.name) >= 15 && strings.HasPrefix(processName, e.name))
+	}
+	return false
+}
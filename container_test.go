@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, root, path, content string) {
+	full := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestReadContainerInfoCgroupV2(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, ".dockerenv", "")
+	writeTestFile(t, root, "sys/fs/cgroup/cgroup.controllers", "cpu memory")
+	writeTestFile(t, root, "sys/fs/cgroup/cpu.max", "50000 100000\n")
+	writeTestFile(t, root, "sys/fs/cgroup/memory.max", "536870912\n")
+	writeTestFile(t, root, "proc/self/status", "Name:\tprocessmonitor\nNSpid:\t4242\t1\n")
+
+	info := readContainerInfo(root)
+	if !info.InContainer || info.Runtime != "docker" {
+		t.Errorf("expected docker container, got %+v", info)
+	}
+	if info.CgroupVersion != 2 {
+		t.Errorf("CgroupVersion = %d, want 2", info.CgroupVersion)
+	}
+	if info.CPULimitCores != 0.5 {
+		t.Errorf("CPULimitCores = %v, want 0.5", info.CPULimitCores)
+	}
+	if info.MemoryLimitMB != 512 {
+		t.Errorf("MemoryLimitMB = %v, want 512", info.MemoryLimitMB)
+	}
+	if !info.PIDNamespace {
+		t.Errorf("expected PID namespace to be detected")
+	}
+
+	if got := info.adjustCPUThreshold(90); got != 45 {
+		t.Errorf("adjustCPUThreshold(90) = %v, want 45", got)
+	}
+	if got := info.adjustMemoryThreshold(1000); got != 512*0.9 {
+		t.Errorf("adjustMemoryThreshold(1000) = %v, want %v", got, 512*0.9)
+	}
+}
+
+func TestReadContainerInfoCgroupV1Unlimited(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "proc/1/cgroup", "12:memory:/kubepods/burstable/pod1234\n")
+	writeTestFile(t, root, "sys/fs/cgroup/cpu/cpu.cfs_quota_us", "-1\n")
+	writeTestFile(t, root, "sys/fs/cgroup/cpu/cpu.cfs_period_us", "100000\n")
+	writeTestFile(t, root, "sys/fs/cgroup/memory/memory.limit_in_bytes", "9223372036854771712\n")
+
+	info := readContainerInfo(root)
+	if info.Runtime != "kubernetes" {
+		t.Errorf("Runtime = %q, want kubernetes", info.Runtime)
+	}
+	if info.CgroupVersion != 1 || info.CPULimitCores != 0 || info.MemoryLimitMB != 0 {
+		t.Errorf("expected unlimited cgroup v1, got %+v", info)
+	}
+	if got := info.adjustCPUThreshold(90); got != 90 {
+		t.Errorf("adjustCPUThreshold(90) = %v, want 90", got)
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// controlOperationTimeout restart 等操作的最长等待时间
+	controlOperationTimeout = 10 * time.Minute
+	// controlMaxRequest 请求行的最大长度
+	controlMaxRequest = 64 * 1024
+	// controlTailBatch tail 每次唤醒时最多读取的新事件数量
+	controlTailBatch = 1000
+	// controlHeartbeat tail 没有新事件时发送空响应的间隔，用于发现已断开的客户端
+	controlHeartbeat = 30 * time.Second
+)
+
+// ControlConfig 本机控制通道（Unix 域套接字或 Windows 命名管道），
+// processmonitor status|restart|reload|tail 通过它操作正在运行的监控程序，不需要开放TCP端口
+type ControlConfig struct {
+	Enable bool   `yaml:"enable"` // 是否启用
+	Path   string `yaml:"path"`   // 套接字路径（默认 /var/run/processmonitor.sock）或命名管道（默认 \\.\pipe\processmonitor）
+}
+
+// path returns the configured socket or pipe path or the platform default
+func (c ControlConfig) path() string {
+	if c.Path != "" {
+		return c.Path
+	}
+	return defaultControlPath
+}
+
+// controlRequest is the single JSON line a client sends after connecting
+type controlRequest struct {
+	Command string `json:"command"`           // status, restart, update, reload, tail
+	Process string `json:"process,omitempty"` // restart/update 的目标进程；tail 只显示该进程的事件
+	Lines   int    `json:"lines,omitempty"`   // tail 先显示的最近事件数量
+	Profile string `json:"profile,omitempty"` // restart 使用的重启配置（restart_profiles）
+	Binary  string `json:"binary,omitempty"`  // update 的新程序文件（监控程序所在主机上的绝对路径）
+}
+
+// controlResponse is one JSON line written back. tail writes one per event
+// and an empty one as heartbeat.
+type controlResponse struct {
+	Error   string          `json:"error,omitempty"`
+	Result  string          `json:"result,omitempty"`
+	Status  *StatusResponse `json:"status,omitempty"`
+	Process *ProcessStatus  `json:"process,omitempty"`
+	Event   *Event          `json:"event,omitempty"`
+}
+
+// controlServer answers requests on the control channel
+type controlServer struct {
+	manager *ProcessManager
+	events  EventStore
+	reload  func(ctx context.Context) error
+}
+
+// runControlServer serves the control channel until ctx is cancelled
+func runControlServer(config ControlConfig, manager *ProcessManager, reload func(context.Context) error, ctx context.Context) {
+	path := config.path()
+	listener, err := listenControl(path)
+	if err != nil {
+		logrus.Errorf("Control channel: %v", err)
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	// tail 在产生事件时被唤醒
+	dashboardUpdatesOnce.Do(func() { registerEventSink(dashboardUpdates) })
+
+	s := &controlServer{manager: manager, events: eventHistory, reload: reload}
+	logrus.Infof("Control channel listening on %s", path)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logrus.Errorf("Control channel: failed to accept connection: %v", err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			s.serve(ctx, conn)
+		}()
+	}
+}
+
+// serve reads one request from conn and writes the response(s)
+func (s *controlServer) serve(ctx context.Context, conn io.ReadWriter) {
+	enc := json.NewEncoder(conn)
+	line, err := bufio.NewReader(io.LimitReader(conn, controlMaxRequest)).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+	var req controlRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		enc.Encode(controlResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	switch req.Command {
+	case "status":
+		status := buildStatus(s.manager)
+		enc.Encode(controlResponse{Status: &status})
+	case "restart":
+		logrus.Infof("Control channel: restart %s requested", req.Process)
+		sup, ok := s.manager.Get(req.Process)
+		if !ok {
+			enc.Encode(controlResponse{Error: "unknown process: " + req.Process})
+			return
+		}
+		opCtx, cancel := context.WithTimeout(ctx, controlOperationTimeout)
+		defer cancel()
+		if err := sup.Restart(opCtx, req.Profile, "control channel request"); err != nil {
+			enc.Encode(controlResponse{Error: err.Error()})
+			return
+		}
+		status := sup.Status()
+		enc.Encode(controlResponse{Result: "ok", Process: &status})
+	case "update":
+		// 以监控程序的权限替换并启动程序文件，只通过仅限本机管理员的控制通道提供
+		logrus.Infof("Control channel: update %s from %s requested", req.Process, req.Binary)
+		sup, ok := s.manager.Get(req.Process)
+		if !ok {
+			enc.Encode(controlResponse{Error: "unknown process: " + req.Process})
+			return
+		}
+		if req.Binary == "" {
+			enc.Encode(controlResponse{Error: "binary is required"})
+			return
+		}
+		opCtx, cancel := context.WithTimeout(ctx, controlOperationTimeout)
+		defer cancel()
+		if err := sup.Update(opCtx, req.Binary, "control channel request"); err != nil {
+			enc.Encode(controlResponse{Error: err.Error()})
+			return
+		}
+		status := sup.Status()
+		enc.Encode(controlResponse{Result: "ok", Process: &status})
+	case "reload":
+		if s.reload == nil {
+			enc.Encode(controlResponse{Error: "reload is not available"})
+			return
+		}
+		opCtx, cancel := context.WithTimeout(ctx, controlOperationTimeout)
+		defer cancel()
+		if err := s.reload(opCtx); err != nil {
+			enc.Encode(controlResponse{Error: err.Error()})
+			return
+		}
+		enc.Encode(controlResponse{Result: "ok"})
+	case "tail":
+		s.tail(ctx, enc, req)
+	default:
+		enc.Encode(controlResponse{Error: "unknown command: " + req.Command})
+	}
+}
+
+// tail writes the last req.Lines events and then every new event until the
+// client disconnects or ctx is cancelled
+func (s *controlServer) tail(ctx context.Context, enc *json.Encoder, req controlRequest) {
+	var filter EventFilter
+	if req.Process != "" {
+		filter.Processes = []string{req.Process}
+	}
+	// 先订阅再查询，避免漏掉两者之间产生的事件
+	updates, cancel := dashboardUpdates.subscribe()
+	defer cancel()
+
+	var last uint64
+	if newest, _ := s.events.Query(EventFilter{Limit: 1}); len(newest) > 0 {
+		last = newest[0].ID
+	}
+	if req.Lines > 0 {
+		recent := filter
+		recent.Limit = req.Lines
+		recent.Before = last + 1
+		events, _ := s.events.Query(recent)
+		if !writeControlEvents(enc, events) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(controlHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-updates:
+		case <-heartbeat.C:
+			if enc.Encode(controlResponse{}) != nil {
+				return
+			}
+			continue
+		case <-ctx.Done():
+			return
+		}
+		batch := filter
+		batch.Limit = controlTailBatch
+		events, _ := s.events.Query(batch)
+		var fresh []Event
+		for _, e := range events {
+			if e.ID <= last {
+				break
+			}
+			fresh = append(fresh, e)
+		}
+		if len(fresh) == 0 {
+			continue
+		}
+		last = fresh[0].ID
+		if !writeControlEvents(enc, fresh) {
+			return
+		}
+	}
+}
+
+// writeControlEvents writes events (newest first, as returned by the store)
+// oldest first and reports whether the client is still there
+func writeControlEvents(enc *json.Encoder, events []Event) bool {
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		// 环境快照很大，tail 中不显示
+		e.Snapshot = nil
+		if err := enc.Encode(controlResponse{Event: &e}); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// controlCall sends req to the running monitor and returns the connection
+// and a decoder for its responses
+func controlCall(config Config, req controlRequest) (net.Conn, *json.Decoder, error) {
+	if !config.Control.Enable {
+		return nil, nil, fmt.Errorf("%s requires control.enable to be set", req.Command)
+	}
+	conn, err := dialControl(config.Control.path())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to contact monitor: %v", err)
+	}
+	data, _ := json.Marshal(req)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to contact monitor: %v", err)
+	}
+	return conn, json.NewDecoder(conn), nil
+}
+
+// controlRoundTrip sends req and returns the single response
+func controlRoundTrip(config Config, req controlRequest) (controlResponse, error) {
+	conn, dec, err := controlCall(config, req)
+	if err != nil {
+		return controlResponse{}, err
+	}
+	defer conn.Close()
+	var resp controlResponse
+	if err := dec.Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to read response from monitor: %v", err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("%s failed: %s", req.Command, resp.Error)
+	}
+	return resp, nil
+}
+
+// runControlCommand runs one of the control channel subcommands
+func runControlCommand(config Config, command string, args []string) error {
+	switch command {
+	case "status":
+		return runStatusCommand(config, args)
+	case "restart":
+		return runRestartCommand(config, args)
+	case "reload":
+		return runReloadCommand(config, args)
+	default:
+		return runTailCommand(config, args)
+	}
+}
+
+// runStatusCommand implements "processmonitor status [-json]"
+func runStatusCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the raw status as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	resp, err := controlRoundTrip(config, controlRequest{Command: "status"})
+	if err != nil {
+		return err
+	}
+	if resp.Status == nil {
+		return fmt.Errorf("status failed: empty response from monitor")
+	}
+	if *asJSON {
+		data, _ := json.MarshalIndent(resp.Status, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	printStatus(os.Stdout, *resp.Status)
+	return nil
+}
+
+// printStatus writes the monitor summary and one line per process
+func printStatus(w io.Writer, status StatusResponse) {
+	monitor := status.Monitor
+	fmt.Fprintf(w, "Process Monitor %s, %s, up %v\n", monitor.Version, monitor.Status,
+		(time.Duration(monitor.UptimeSeconds) * time.Second).String())
+	if monitor.SafeMode != "" {
+		fmt.Fprintf(w, "Safe mode: %s\n", monitor.SafeMode)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tPID\tRESTARTS\tHEALTH\tLAST RESTART")
+	for _, p := range status.Processes {
+		pid, health, lastRestart := "-", "-", "-"
+		if p.PID > 0 {
+			pid = strconv.Itoa(p.PID)
+		}
+		if p.Health != "" {
+			health = p.Health
+		}
+		if !p.LastRestart.IsZero() {
+			lastRestart = p.LastRestart.Local().Format("2006-01-02 15:04:05")
+			if p.LastRestartReason != "" {
+				lastRestart += " (" + p.LastRestartReason + ")"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", p.Name, p.State, pid, p.Restarts, health, lastRestart)
+	}
+	tw.Flush()
+}
+
+// runRestartCommand implements "processmonitor restart <process> [-profile name]"
+func runRestartCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("restart", flag.ContinueOnError)
+	profile := fs.String("profile", "", "restart profile to start the process with (\"default\" for none)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// 选项可以写在进程名之前或之后
+	rest := fs.Args()
+	if len(rest) > 1 {
+		process := rest[0]
+		if err := fs.Parse(rest[1:]); err != nil {
+			return err
+		}
+		rest = append([]string{process}, fs.Args()...)
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: processmonitor restart [-profile name] <process>")
+	}
+	process := rest[0]
+	resp, err := controlRoundTrip(config, controlRequest{Command: "restart", Process: process, Profile: *profile})
+	if err != nil {
+		return err
+	}
+	with := ""
+	if resp.Process != nil && resp.Process.RestartProfile != "" {
+		with = " with profile " + resp.Process.RestartProfile
+	}
+	if resp.Process != nil && resp.Process.PID > 0 {
+		fmt.Printf("restarted %s%s (PID %d)\n", process, with, resp.Process.PID)
+	} else {
+		fmt.Printf("restarted %s%s\n", process, with)
+	}
+	return nil
+}
+
+// runReloadCommand implements "processmonitor reload"
+func runReloadCommand(config Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: processmonitor reload")
+	}
+	if _, err := controlRoundTrip(config, controlRequest{Command: "reload"}); err != nil {
+		return err
+	}
+	fmt.Println("config reloaded")
+	return nil
+}
+
+// runTailCommand implements "processmonitor tail [-n lines] [process]"
+func runTailCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	lines := fs.Int("n", 20, "number of recent events to show first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: processmonitor tail [-n lines] [process]")
+	}
+	conn, dec, err := controlCall(config, controlRequest{Command: "tail", Process: fs.Arg(0), Lines: *lines})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		var resp controlResponse
+		if err := dec.Decode(&resp); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("connection to monitor lost: %v", err)
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("tail failed: %s", resp.Error)
+		}
+		if resp.Event != nil {
+			fmt.Println(formatControlEvent(*resp.Event))
+		}
+	}
+}
+
+// formatControlEvent formats an event as one line for tail
+func formatControlEvent(e Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-8s %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Severity, e.Type)
+	if e.Process != "" {
+		fmt.Fprintf(&b, " [%s]", e.Process)
+	}
+	fmt.Fprintf(&b, " %s", e.Message)
+	return b.String()
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// defaultControlPath 控制通道的默认 Unix 域套接字
+const defaultControlPath = "/var/run/processmonitor.sock"
+
+// listenControl listens on the Unix domain socket at path. A socket left
+// behind by a monitor that did not shut down cleanly is removed.
+func listenControl(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another running monitor", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %v", path, err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	// 只允许与监控程序相同的用户（通常是 root）连接
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict access to %s: %v", path, err)
+	}
+	return listener, nil
+}
+
+// dialControl connects to the control socket of the running monitor
+func dialControl(path string) (net.Conn, error) {
+	return net.DialTimeout("unix", path, 5*time.Second)
+}
@@ -0,0 +1,98 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pm.sock")
+	config := Config{Control: ControlConfig{Enable: true, Path: path}}
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	reloaded := make(chan struct{}, 1)
+	go func() {
+		defer close(done)
+		runControlServer(config.Control, manager, func(context.Context) error { reloaded <- struct{}{}; return nil }, ctx)
+	}()
+	// 等待套接字创建
+	for i := 0; i < 100; i++ {
+		if _, err := controlRoundTrip(config, controlRequest{Command: "status"}); err == nil {
+			break
+		}
+		if i == 99 {
+			t.Fatal("control socket did not come up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := listenControl(path); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("second listener: err = %v, want in use", err)
+	}
+	if _, err := controlRoundTrip(config, controlRequest{Command: "reload"}); err != nil {
+		t.Errorf("reload: %v", err)
+	}
+	select {
+	case <-reloaded:
+	default:
+		t.Error("reload was not requested")
+	}
+
+	cancel()
+	<-done
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket was not removed on shutdown: %v", err)
+	}
+}
+
+func TestControlStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+	// 异常退出留下的套接字文件可以被替换
+	stale := filepath.Join(dir, "stale.sock")
+	l, err := listenControl(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if _, err := os.Stat(stale); err != nil {
+		t.Fatalf("stale socket not left behind: %v", err)
+	}
+	l, err = listenControl(stale)
+	if err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	l.Close()
+
+	// 不是套接字的文件不能被删除
+	regular := filepath.Join(dir, "config.yaml")
+	os.WriteFile(regular, []byte("processes: []"), 0644)
+	if _, err := listenControl(regular); err == nil {
+		t.Error("listening on a regular file succeeded")
+	}
+	if _, err := os.Stat(regular); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
+
+func TestControlDisabled(t *testing.T) {
+	if _, err := controlRoundTrip(Config{}, controlRequest{Command: "status"}); err == nil || !strings.Contains(err.Error(), "control.enable") {
+		t.Errorf("err = %v, want a hint to control.enable", err)
+	}
+}
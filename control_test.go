@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// controlExchange sends req to s over an in-memory connection and returns
+// the decoder for the responses
+func controlExchange(t *testing.T, ctx context.Context, s *controlServer, req controlRequest) (*json.Decoder, net.Conn) {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		s.serve(ctx, server)
+	}()
+	data, _ := json.Marshal(req)
+	if _, err := client.Write(append(data, '\n')); err != nil {
+		t.Fatal(err)
+	}
+	return json.NewDecoder(client), client
+}
+
+func TestControlRequests(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}})
+	reloadErr := errors.New("config.yaml: processes[0]: name is empty")
+	s := &controlServer{manager: manager, events: newMemoryEventStore(10), reload: func(context.Context) error { return reloadErr }}
+
+	tests := []struct {
+		req       controlRequest
+		wantError string
+	}{
+		{controlRequest{Command: "status"}, ""},
+		{controlRequest{Command: "restart", Process: "missing.exe"}, "unknown process: missing.exe"},
+		{controlRequest{Command: "update", Process: "missing.exe", Binary: "/opt/new"}, "unknown process: missing.exe"},
+		{controlRequest{Command: "update", Process: "web.exe"}, "binary is required"},
+		{controlRequest{Command: "reload"}, "name is empty"},
+		{controlRequest{Command: "explode"}, "unknown command: explode"},
+	}
+	for _, tt := range tests {
+		dec, conn := controlExchange(t, context.Background(), s, tt.req)
+		var resp controlResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("%s: %v", tt.req.Command, err)
+		}
+		conn.Close()
+		if tt.wantError == "" {
+			if resp.Error != "" {
+				t.Errorf("%s: unexpected error %q", tt.req.Command, resp.Error)
+			}
+			continue
+		}
+		if !strings.Contains(resp.Error, tt.wantError) {
+			t.Errorf("%s: error = %q, want %q", tt.req.Command, resp.Error, tt.wantError)
+		}
+	}
+
+	dec, conn := controlExchange(t, context.Background(), s, controlRequest{Command: "status"})
+	defer conn.Close()
+	var resp controlResponse
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status == nil || len(resp.Status.Processes) != 1 || resp.Status.Processes[0].Name != "web.exe" {
+		t.Errorf("unexpected status: %+v", resp.Status)
+	}
+}
+
+func TestControlUpdate(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}})
+	web, _ := manager.Get("web.exe")
+	// 代替 Run 循环接收更新命令
+	received := make(chan supervisorCommand, 1)
+	go func() {
+		cmd := <-web.commands
+		received <- cmd
+		cmd.reply <- nil
+	}()
+
+	s := &controlServer{manager: manager, events: newMemoryEventStore(10)}
+	dec, conn := controlExchange(t, context.Background(), s, controlRequest{Command: "update", Process: "web.exe", Binary: "/opt/releases/web-2.4"})
+	defer conn.Close()
+	var resp controlResponse
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "" || resp.Result != "ok" {
+		t.Fatalf("update: %+v", resp)
+	}
+	if cmd := <-received; cmd.action != "update" || cmd.arg != "/opt/releases/web-2.4" {
+		t.Errorf("supervisor command = %s %q", cmd.action, cmd.arg)
+	}
+}
+
+func TestControlTail(t *testing.T) {
+	store := newMemoryEventStore(10)
+	emit := func(process, message string) {
+		e := Event{Time: time.Now(), Severity: SeverityWarning, Type: "process_restarted", Process: process, Message: message}
+		store.HandleEvent(e)
+		dashboardUpdates.HandleEvent(e)
+	}
+	emit("web.exe", "old 1")
+	emit("worker.exe", "old 2")
+	emit("web.exe", "old 3")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &controlServer{manager: NewProcessManager(Config{}), events: store}
+	dec, conn := controlExchange(t, ctx, s, controlRequest{Command: "tail", Process: "web.exe", Lines: 1})
+	defer conn.Close()
+
+	next := func() string {
+		t.Helper()
+		for {
+			var resp controlResponse
+			if err := dec.Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Event != nil {
+				return resp.Event.Message
+			}
+		}
+	}
+	if got := next(); got != "old 3" {
+		t.Fatalf("first event = %q, want the most recent one", got)
+	}
+	// 等待 tail 进入等待新事件的状态
+	time.Sleep(50 * time.Millisecond)
+	emit("worker.exe", "other process")
+	emit("web.exe", "new 1")
+	emit("web.exe", "new 2")
+	for _, want := range []string{"new 1", "new 2"} {
+		if got := next(); got != want {
+			t.Errorf("event = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestFormatControlEvent(t *testing.T) {
+	e := Event{Time: time.Now(), Severity: SeverityWarning, Type: "process_restarted", Process: "web.exe", Message: "Restarted web.exe"}
+	got := formatControlEvent(e)
+	if !strings.HasSuffix(got, "warning  process_restarted [web.exe] Restarted web.exe") {
+		t.Errorf("formatControlEvent = %q", got)
+	}
+}
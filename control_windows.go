@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultControlPath 控制通道的默认命名管道
+const defaultControlPath = `\\.\pipe\processmonitor`
+
+// controlPipeSDDL 只允许 SYSTEM、管理员和管道所有者（运行监控程序的账户）连接
+const controlPipeSDDL = "D:P(A;;GA;;;SY)(A;;GA;;;BA)(A;;GA;;;OW)"
+
+const controlPipeBuffer = 64 * 1024
+
+// pipeListener accepts connections on a named pipe. Each client gets its
+// own pipe instance; the requests are answered with blocking I/O, which is
+// enough because a connection never reads and writes at the same time.
+type pipeListener struct {
+	path string
+	sa   *windows.SecurityAttributes
+
+	mu     sync.Mutex
+	next   windows.Handle // 等待下一个客户端连接的管道实例
+	closed bool
+}
+
+// listenControl creates the named pipe at path. It fails when another
+// monitor already owns the pipe.
+func listenControl(path string) (net.Listener, error) {
+	if !strings.HasPrefix(strings.ToLower(path), `\\.\pipe\`) {
+		return nil, fmt.Errorf(`control path %s must be a named pipe (\\.\pipe\<name>)`, path)
+	}
+	sd, err := windows.SecurityDescriptorFromString(controlPipeSDDL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the pipe security descriptor: %v", err)
+	}
+	l := &pipeListener{
+		path: path,
+		sa:   &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{})), SecurityDescriptor: sd},
+	}
+	if l.next, err = l.create(true); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// create creates a new instance of the pipe
+func (l *pipeListener) create(first bool) (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(l.path)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	mode := uint32(windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS)
+	h, err := windows.CreateNamedPipe(name, flags, mode, windows.PIPE_UNLIMITED_INSTANCES, controlPipeBuffer, controlPipeBuffer, 0, l.sa)
+	if err != nil {
+		if first && err == windows.ERROR_ACCESS_DENIED {
+			return windows.InvalidHandle, fmt.Errorf("%s is in use by another running monitor", l.path)
+		}
+		return windows.InvalidHandle, fmt.Errorf("failed to create named pipe %s: %v", l.path, err)
+	}
+	return h, nil
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		if l.next != windows.InvalidHandle {
+			windows.CloseHandle(l.next)
+			l.next = windows.InvalidHandle
+		}
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	if l.next == windows.InvalidHandle {
+		h, err := l.create(false)
+		if err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		l.next = h
+	}
+	h := l.next
+	l.mu.Unlock()
+
+	err := windows.ConnectNamedPipe(h, nil)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.next = windows.InvalidHandle
+	if l.closed {
+		windows.CloseHandle(h)
+		return nil, net.ErrClosed
+	}
+	if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+		windows.CloseHandle(h)
+		return nil, fmt.Errorf("failed to accept connection on %s: %v", l.path, err)
+	}
+	// 立即创建下一个实例，客户端在两次 Accept 之间连接也不会失败
+	if next, err := l.create(false); err == nil {
+		l.next = next
+	}
+	return &pipeConn{handle: h, path: l.path, server: true}, nil
+}
+
+// Close stops Accept. ConnectNamedPipe cannot be cancelled, so the
+// listener connects to its own pipe to wake it up.
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+	if conn, err := dialControl(l.path); err == nil {
+		conn.Close()
+	}
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr(l.path) }
+
+// pipeAddr is the net.Addr of a named pipe
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn is one end of a named pipe connection. Deadlines are not
+// supported.
+type pipeConn struct {
+	handle    windows.Handle
+	path      string
+	server    bool
+	closeOnce sync.Once
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	var n uint32
+	err := windows.ReadFile(c.handle, b, &n, nil)
+	if err == windows.ERROR_BROKEN_PIPE || err == windows.ERROR_PIPE_NOT_CONNECTED {
+		return int(n), io.EOF
+	}
+	return int(n), err
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	var n uint32
+	err := windows.WriteFile(c.handle, b, &n, nil)
+	return int(n), err
+}
+
+func (c *pipeConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.server {
+			// 关闭前等待客户端读完响应，否则未读取的数据会被丢弃
+			windows.FlushFileBuffers(c.handle)
+		}
+		err = windows.CloseHandle(c.handle)
+	})
+	return err
+}
+
+func (c *pipeConn) LocalAddr() net.Addr                { return pipeAddr(c.path) }
+func (c *pipeConn) RemoteAddr() net.Addr               { return pipeAddr(c.path) }
+func (c *pipeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// dialControl connects to the named pipe of the running monitor, waiting
+// up to five seconds while all pipe instances are busy
+func dialControl(path string) (net.Conn, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+		if err == nil {
+			return &pipeConn{handle: h, path: path}, nil
+		}
+		if err != windows.ERROR_PIPE_BUSY || time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to open %s: %v", path, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
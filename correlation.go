@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// correlationEnv 传给子进程的环境变量，应用程序可以把它写入自己的日志，与监控事件关联
+const correlationEnv = "PM_CORRELATION_ID"
+
+var (
+	correlationMu  sync.RWMutex
+	correlationIDs = make(map[string]string) // 进程名 -> 当前启动/重启周期的关联ID
+)
+
+// newCorrelationID returns a random UUID (version 4)
+func newCorrelationID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+// beginCycle starts a new start/restart cycle of process: events of the
+// process are tagged with the returned ID until the next cycle begins
+func beginCycle(process string) string {
+	id := newCorrelationID()
+	correlationMu.Lock()
+	correlationIDs[process] = id
+	correlationMu.Unlock()
+	return id
+}
+
+// correlationID returns the ID of the current cycle of process, or ""
+func correlationID(process string) string {
+	correlationMu.RLock()
+	defer correlationMu.RUnlock()
+	return correlationIDs[process]
+}
+
+// monitorEnv returns the variables of env set by the monitor itself (PM_*),
+// which are kept when the rest of the environment is replaced
+func monitorEnv(env []string) []string {
+	var vars []string
+	for _, v := range env {
+		if strings.HasPrefix(v, "PM_") {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
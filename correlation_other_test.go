@@ -0,0 +1,26 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHookCorrelationID(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "id")
+	config := ProcessConfig{Name: "corr-hook.exe"}
+	id := beginCycle(config.Name)
+	if err := runHook(config, "pre_restart", "echo $PM_CORRELATION_ID > "+out, "test", 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != id {
+		t.Errorf("hook saw %q, want %q", data, id)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewCorrelationID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := newCorrelationID(), newCorrelationID()
+	if !uuid.MatchString(a) || a == b {
+		t.Errorf("correlation IDs %q and %q", a, b)
+	}
+}
+
+func TestEventCorrelationID(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	first := beginCycle("corr.exe")
+	emitEvent(Event{Severity: SeverityInfo, Type: "process_started", Process: "corr.exe", Message: "started"})
+	second := beginCycle("corr.exe")
+	emitEvent(Event{Severity: SeverityWarning, Type: "process_restarted", Process: "corr.exe", Message: "restarted"})
+	emitEvent(Event{Severity: SeverityInfo, Type: "config_reloaded", Message: "reloaded"})
+
+	if len(sink.events) != 3 {
+		t.Fatalf("got %d events", len(sink.events))
+	}
+	if sink.events[0].CorrelationID != first || sink.events[1].CorrelationID != second || first == second {
+		t.Errorf("correlation IDs = %q, %q, want %q, %q", sink.events[0].CorrelationID, sink.events[1].CorrelationID, first, second)
+	}
+	if sink.events[2].CorrelationID != "" {
+		t.Errorf("event without process got correlation ID %q", sink.events[2].CorrelationID)
+	}
+}
+
+func TestMonitorEnv(t *testing.T) {
+	env := []string{"PATH=/usr/bin", "PM_PROTOCOL_FD=3", "HOME=/root", "PM_CORRELATION_ID=abc"}
+	got := monitorEnv(env)
+	if len(got) != 2 || got[0] != "PM_PROTOCOL_FD=3" || got[1] != "PM_CORRELATION_ID=abc" {
+		t.Errorf("monitorEnv = %v", got)
+	}
+}
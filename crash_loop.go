@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CrashLoopConfig 检测崩溃循环，并在下一次重启前隔离可能导致崩溃的输入文件
+type CrashLoopConfig struct {
+	Restarts      int      `yaml:"restarts"`       // 时间窗口内自动重启多少次视为崩溃循环（0表示不检测）
+	Window        int      `yaml:"window"`         // 时间窗口（秒，默认300）
+	Quarantine    []string `yaml:"quarantine"`     // 要隔离的输入文件或spool目录
+	QuarantineDir string   `yaml:"quarantine_dir"` // 隔离文件存放目录（默认 quarantine）
+}
+
+// recordCrash remembers an automatic restart and reports whether the process
+// is now crash-looping. Only called from the Run goroutine.
+func (s *ProcessSupervisor) recordCrash() bool {
+	config := s.config.CrashLoop
+	if config.Restarts <= 0 {
+		return false
+	}
+	window := time.Duration(config.Window) * time.Second
+	if window <= 0 {
+		window = 300 * time.Second
+	}
+
+	now := time.Now()
+	recent := s.crashTimes[:0]
+	for _, t := range s.crashTimes {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	s.crashTimes = append(recent, now)
+
+	if len(s.crashTimes) < config.Restarts {
+		return false
+	}
+	s.crashTimes = nil
+	return true
+}
+
+// countCrash records an automatic restart or failed start and reports a
+// crash loop when it is one too many; the poison inputs are quarantined
+// before the next start
+func (s *ProcessSupervisor) countCrash(reason string) bool {
+	if !s.recordCrash() {
+		return false
+	}
+	config := s.config
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "crash_loop",
+		Process:  config.Name,
+		Message: fmt.Sprintf("Process %s is crash-looping (%d restarts within %ds)",
+			config.Name, config.CrashLoop.Restarts, config.CrashLoop.Window),
+		Details: map[string]string{"reason": reason},
+	})
+	s.quarantinePending = len(config.CrashLoop.Quarantine) > 0
+	return true
+}
+
+// quarantineInputs moves the configured poison input files aside. A spool
+// directory is emptied into the quarantine and recreated, so the process
+// finds the directory it expects but without the input it crashed on.
+func quarantineInputs(config ProcessConfig) {
+	dir := config.CrashLoop.QuarantineDir
+	if dir == "" {
+		dir = "quarantine"
+	}
+	target := filepath.Join(dir, fmt.Sprintf("%s_%s", filepath.Base(config.Name), time.Now().Format("20060102-150405")))
+
+	for _, path := range config.CrashLoop.Quarantine {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			logrus.Errorf("Crash loop quarantine: cannot access %s: %v", path, err)
+			continue
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			logrus.Errorf("Crash loop quarantine: failed to create %s: %v", target, err)
+			return
+		}
+
+		dest := filepath.Join(target, filepath.Base(path))
+		if err := moveAside(path, dest); err != nil {
+			logrus.Errorf("Crash loop quarantine: failed to move %s: %v", path, err)
+			continue
+		}
+		if info.IsDir() {
+			if err := os.MkdirAll(path, info.Mode().Perm()); err != nil {
+				logrus.Errorf("Crash loop quarantine: failed to recreate %s: %v", path, err)
+			}
+		}
+		logrus.Warnf("Crash loop quarantine: moved %s to %s", path, dest)
+		emitCount("quarantines", 1, processTags(config.Name))
+	}
+}
+
+// renameFile moves quarantined inputs; replaced in tests
+var renameFile = os.Rename
+
+// moveAside renames src to dest, falling back to copy and delete when they
+// are on different volumes.
+func moveAside(src, dest string) error {
+	if err := renameFile(src, dest); err == nil {
+		return nil
+	}
+	if err := copyTree(src, dest); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies a file or directory recursively
+func copyTree(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
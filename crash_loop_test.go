@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordCrash(t *testing.T) {
+	s := NewProcessSupervisor(ProcessConfig{Name: "worker.exe", CrashLoop: CrashLoopConfig{Restarts: 3, Window: 60}})
+	// 窗口外的旧记录不计入
+	s.crashTimes = []time.Time{time.Now().Add(-2 * time.Minute), time.Now().Add(-90 * time.Second)}
+	for i, want := range []bool{false, false, true, false} {
+		if got := s.recordCrash(); got != want {
+			t.Errorf("restart %d: crash loop = %v, want %v", i+1, got, want)
+		}
+	}
+
+	s = NewProcessSupervisor(ProcessConfig{Name: "worker.exe"})
+	for i := 0; i < 10; i++ {
+		if s.recordCrash() {
+			t.Fatal("crash loop detected with detection disabled")
+		}
+	}
+}
+
+// writeSpool creates a spool directory with one poison input
+func writeSpool(t *testing.T, dir string) string {
+	spool := filepath.Join(dir, "spool")
+	if err := os.MkdirAll(filepath.Join(spool, "batch"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(spool, "batch", "poison.xml"), []byte("<bad/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return spool
+}
+
+// quarantined returns the poison input in the single quarantine folder
+func quarantined(t *testing.T, dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("quarantine folders = %v, %v, want 1", entries, err)
+	}
+	return filepath.Join(dir, entries[0].Name(), "spool", "batch", "poison.xml")
+}
+
+func TestQuarantineInputs(t *testing.T) {
+	dir := t.TempDir()
+	spool := writeSpool(t, dir)
+	input := filepath.Join(dir, "input.dat")
+	if err := os.WriteFile(input, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	qdir := filepath.Join(dir, "quarantine")
+	quarantineInputs(ProcessConfig{Name: `C:\apps\worker.exe`, CrashLoop: CrashLoopConfig{
+		Quarantine:    []string{spool, input, filepath.Join(dir, "missing")},
+		QuarantineDir: qdir,
+	}})
+
+	if data, err := os.ReadFile(quarantined(t, qdir)); err != nil || string(data) != "<bad/>" {
+		t.Errorf("quarantined input = %q, %v", data, err)
+	}
+	// spool 目录被清空后重建，单个文件被移走
+	if entries, err := os.ReadDir(spool); err != nil || len(entries) != 0 {
+		t.Errorf("spool after quarantine = %v, %v, want empty directory", entries, err)
+	}
+	if _, err := os.Stat(input); !os.IsNotExist(err) {
+		t.Errorf("input file still present: %v", err)
+	}
+}
+
+func TestQuarantineAcrossVolumes(t *testing.T) {
+	// 跨卷时 rename 失败，改为复制后删除
+	defer func(orig func(string, string) error) { renameFile = orig }(renameFile)
+	renameFile = func(src, dest string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dest, Err: fmt.Errorf("not same device")}
+	}
+
+	dir := t.TempDir()
+	spool := writeSpool(t, dir)
+	qdir := filepath.Join(dir, "quarantine")
+	quarantineInputs(ProcessConfig{Name: "worker.exe", CrashLoop: CrashLoopConfig{Quarantine: []string{spool}, QuarantineDir: qdir}})
+
+	if data, err := os.ReadFile(quarantined(t, qdir)); err != nil || string(data) != "<bad/>" {
+		t.Errorf("copied input = %q, %v", data, err)
+	}
+	if entries, err := os.ReadDir(spool); err != nil || len(entries) != 0 {
+		t.Errorf("spool after quarantine = %v, %v, want empty directory", entries, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2024-03-01 是星期五
+	from := time.Date(2024, 3, 1, 10, 7, 30, 0, time.Local)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 10, 8, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2024, 3, 1, 10, 15, 0, 0, time.Local)},
+		{"30 2 * * *", time.Date(2024, 3, 2, 2, 30, 0, 0, time.Local)},
+		{"0 9-17 * * mon-fri", time.Date(2024, 3, 1, 11, 0, 0, 0, time.Local)},
+		{"0 9 * * mon", time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.Local)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)},
+		{"5/20 10 * * *", time.Date(2024, 3, 1, 10, 25, 0, 0, time.Local)},
+		// 日和星期都指定时满足其一即可：15日或星期一
+		{"0 0 15 * mon", time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.next(from); !got.Equal(tt.want) {
+			t.Errorf("next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	never, _ := parseCron("0 0 30 2 *")
+	if got := never.next(from); !got.IsZero() {
+		t.Errorf("February 30th matched %v", got)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) should fail", expr)
+		}
+	}
+}
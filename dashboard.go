@@ -0,0 +1,163 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+const (
+	dashboardEvents   = 20              // 仪表盘显示的最近事件数量
+	dashboardInterval = 2 * time.Second // 没有事件时推送状态的间隔
+	dashboardDebounce = 250 * time.Millisecond
+)
+
+// DashboardSnapshot is the state pushed to the web dashboard
+type DashboardSnapshot struct {
+	Monitor   HealthzResponse         `json:"monitor"`
+	Processes []ProcessStatus         `json:"processes"`
+	Registry  []RegistryMonitorStatus `json:"registry"`
+	Events    []Event                 `json:"events"`
+}
+
+// liveUpdates wakes the dashboard streams whenever an event is emitted, so
+// a restart shows up immediately instead of at the next interval
+type liveUpdates struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+var (
+	dashboardUpdates     = &liveUpdates{subscribers: make(map[chan struct{}]struct{})}
+	dashboardUpdatesOnce sync.Once
+)
+
+func (l *liveUpdates) HandleEvent(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel signalled on new events and its cancel function
+func (l *liveUpdates) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.subscribers[ch] = struct{}{}
+	l.mu.Unlock()
+	return ch, func() {
+		l.mu.Lock()
+		delete(l.subscribers, ch)
+		l.mu.Unlock()
+	}
+}
+
+// dashboardSnapshot collects the current state for the dashboard
+func (s *APIServer) dashboardSnapshot() DashboardSnapshot {
+	snapshot := DashboardSnapshot{
+		Monitor:   buildHealthz(),
+		Processes: s.manager.Statuses(),
+		Registry:  registryMonitors.list(),
+		Events:    []Event{},
+	}
+	if s.events != nil {
+		events, _ := s.events.Query(EventFilter{Limit: dashboardEvents})
+		for _, e := range events {
+			// 环境快照很大，仪表盘上不显示
+			e.Snapshot = nil
+			snapshot.Events = append(snapshot.Events, e)
+		}
+	}
+	if snapshot.Processes == nil {
+		snapshot.Processes = []ProcessStatus{}
+	}
+	return snapshot
+}
+
+// handleDashboard serves the web dashboard at /
+func (s *APIServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found: " + r.URL.Path})
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(dashboardHTML)
+}
+
+// handleRegistry serves GET /registry: the state of every registry monitor
+func (s *APIServer) handleRegistry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, registryMonitors.list())
+}
+
+// handleStream serves GET /stream, a Server-Sent Events stream of dashboard
+// snapshots: one on connect, one shortly after every event and one every
+// few seconds otherwise, so uptimes keep counting
+func (s *APIServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	updates, cancel := dashboardUpdates.subscribe()
+	defer cancel()
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+
+	for {
+		data, err := json.Marshal(s.dashboardSnapshot())
+		if err != nil {
+			logrus.Errorf("Failed to encode dashboard snapshot: %v", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-updates:
+			// 重启等操作会连续产生多个事件，稍等片刻合并为一次推送
+			select {
+			case <-time.After(dashboardDebounce):
+			case <-r.Context().Done():
+				return
+			}
+			select {
+			case <-updates:
+			default:
+			}
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
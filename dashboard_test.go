@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryMonitorTracker(t *testing.T) {
+	tracker := &registryMonitorTracker{monitors: make(map[string]*RegistryMonitorStatus)}
+	tracker.started(RegistryMonitor{Name: "proxy", RootKey: "HKCU", Path: `Software\Proxy`})
+	tracker.HandleEvent(Event{Type: "registry_value_restored", Time: time.Unix(100, 0), Details: map[string]string{"monitor": "proxy"}})
+	tracker.HandleEvent(Event{Type: "registry_key_deleted", Time: time.Unix(200, 0), Details: map[string]string{"monitor": "proxy"}})
+	tracker.HandleEvent(Event{Type: "registry_value_restored", Details: map[string]string{"monitor": "unknown"}})
+	tracker.HandleEvent(Event{Type: "process_restarted", Details: map[string]string{"monitor": "proxy"}})
+
+	list := tracker.list()
+	if len(list) != 1 {
+		t.Fatalf("list = %+v", list)
+	}
+	st := list[0]
+	if st.Key != `HKCU\Software\Proxy` || st.State != RegistryKeyMissing || st.Changes != 2 || st.LastChangeType != "registry_key_deleted" {
+		t.Errorf("after deletion: %+v", st)
+	}
+
+	tracker.HandleEvent(Event{Type: "registry_key_recreated", Details: map[string]string{"monitor": "proxy"}})
+	tracker.stopped("other")
+	if st := tracker.list()[0]; st.State != RegistryMonitoring || st.Changes != 2 {
+		t.Errorf("after recreation: %+v", st)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	server := NewAPIServer(APIConfig{}, NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}}))
+	server.events = newMemoryEventStore(8)
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/", http.StatusOK, "<title>Process Monitor</title>"},
+		{"/nothing", http.StatusNotFound, "not found"},
+		{"/registry", http.StatusOK, "["},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("GET %s = %d, want %d containing %q", tt.path, rec.Code, tt.status, tt.want)
+		}
+	}
+
+	srv := httptest.NewServer(server.mux)
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	var event string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			event = strings.TrimPrefix(line, "event: ")
+		}
+		if strings.HasPrefix(line, "data: ") {
+			var snapshot DashboardSnapshot
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if event != "snapshot" || len(snapshot.Processes) != 1 || snapshot.Processes[0].Name != "web.exe" {
+				t.Errorf("unexpected first message %s: %+v", event, snapshot)
+			}
+			return
+		}
+	}
+	t.Fatalf("stream ended without a snapshot: %v", scanner.Err())
+}
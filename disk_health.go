@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDiskHealthInterval = 3600
+	defaultDiskWearPercent    = 90
+	diskHealthCommandTimeout  = 2 * time.Minute
+)
+
+// DiskHealthConfig 磁盘硬件健康检查：SMART（smartctl）或 Windows 存储可靠性计数器，
+// 在硬盘故障导致服务中断之前发出告警
+type DiskHealthConfig struct {
+	Enable      bool     `yaml:"enable"`       // 是否启用
+	Interval    int      `yaml:"interval"`     // 检查间隔（秒，默认3600）
+	Smartctl    string   `yaml:"smartctl"`     // smartctl 路径（Linux 默认在 PATH 中查找；Windows 设置后使用 smartctl 代替存储可靠性计数器）
+	Devices     []string `yaml:"devices"`      // 只检查这些设备，如 /dev/sda（为空则检查 smartctl --scan 发现的全部设备）
+	WearPercent int      `yaml:"wear_percent"` // SSD 寿命已用百分比达到该值时告警（默认90）
+}
+
+// validate checks the disk health settings
+func (c DiskHealthConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("disk_health: interval must not be negative")
+	}
+	if c.WearPercent < 0 || c.WearPercent > 100 {
+		return fmt.Errorf("disk_health: wear_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// diskHealth is what one check found out about one disk
+type diskHealth struct {
+	Device   string
+	Model    string
+	Serial   string
+	Failed   bool     // 磁盘自检报告即将故障
+	Problems []string // 预示故障的迹象，为空表示正常
+}
+
+// diskHealthSource reads the health of all checked disks
+type diskHealthSource interface {
+	disks(ctx context.Context) ([]diskHealth, error)
+}
+
+// newDiskHealthSource picks smartctl or, on Windows without smartctl, the
+// storage reliability counters
+func newDiskHealthSource(config DiskHealthConfig) diskHealthSource {
+	wear := defaultInt(config.WearPercent, defaultDiskWearPercent)
+	if runtime.GOOS == "windows" && config.Smartctl == "" {
+		return storageCounterSource{wearPercent: wear}
+	}
+	path := config.Smartctl
+	if path == "" {
+		path = "smartctl"
+	}
+	return smartctlSource{path: path, devices: config.Devices, wearPercent: wear}
+}
+
+// smartctlSource reads SMART data with smartctl (smartmontools 7.0+ for JSON output)
+type smartctlSource struct {
+	path        string
+	devices     []string
+	wearPercent int
+}
+
+// smartctlDevice is one entry of "smartctl --scan -j"
+type smartctlDevice struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// smartctlReport is the part of "smartctl -j -H -A -i" that is evaluated
+type smartctlReport struct {
+	Smartctl struct {
+		Messages []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	Devices      []smartctlDevice `json:"devices"`
+	ModelName    string           `json:"model_name"`
+	SerialNumber string           `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	ATASmartAttributes struct {
+		Table []struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			WhenFailed string `json:"when_failed"`
+			Raw        struct {
+				Value int64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeLog *struct {
+		CriticalWarning         int   `json:"critical_warning"`
+		AvailableSpare          int   `json:"available_spare"`
+		AvailableSpareThreshold int   `json:"available_spare_threshold"`
+		PercentageUsed          int   `json:"percentage_used"`
+		MediaErrors             int64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// smartctlErrorBits 是 smartctl 退出码中表示命令本身失败的位（其余位报告磁盘状态）
+const smartctlErrorBits = 0x3
+
+// run runs smartctl and decodes its JSON output. smartctl reports disk
+// problems through the exit code, so only the command error bits count as
+// failure.
+func (s smartctlSource) run(ctx context.Context, args ...string) (smartctlReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, diskHealthCommandTimeout)
+	defer cancel()
+	var report smartctlReport
+	out, err := exec.CommandContext(ctx, s.path, append([]string{"-j"}, args...)...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode()&smartctlErrorBits == 0 {
+		err = nil
+	}
+	if jsonErr := json.Unmarshal(out, &report); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		return report, fmt.Errorf("smartctl %s: %v", strings.Join(args, " "), err)
+	}
+	if err != nil {
+		var messages []string
+		for _, m := range report.Smartctl.Messages {
+			messages = append(messages, m.String)
+		}
+		return report, fmt.Errorf("smartctl %s: %v: %s", strings.Join(args, " "), err, strings.Join(messages, "; "))
+	}
+	return report, nil
+}
+
+func (s smartctlSource) disks(ctx context.Context) ([]diskHealth, error) {
+	devices := make([]smartctlDevice, 0, len(s.devices))
+	for _, name := range s.devices {
+		devices = append(devices, smartctlDevice{Name: name})
+	}
+	if len(devices) == 0 {
+		scan, err := s.run(ctx, "--scan")
+		if err != nil {
+			return nil, err
+		}
+		devices = scan.Devices
+	}
+
+	var result []diskHealth
+	for _, dev := range devices {
+		args := []string{"-H", "-A", "-i"}
+		if dev.Type != "" {
+			args = append(args, "-d", dev.Type)
+		}
+		report, err := s.run(ctx, append(args, dev.Name)...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, evaluateSmartctl(dev.Name, report, s.wearPercent))
+	}
+	return result, nil
+}
+
+// smartctlCriticalAttributes ATA 属性中原始值大于0即预示故障的属性
+var smartctlCriticalAttributes = map[int]bool{
+	5:   true, // Reallocated_Sector_Ct
+	187: true, // Reported_Uncorrect
+	197: true, // Current_Pending_Sector
+	198: true, // Offline_Uncorrectable
+}
+
+// evaluateSmartctl turns a smartctl report into the problems found
+func evaluateSmartctl(device string, report smartctlReport, wearPercent int) diskHealth {
+	d := diskHealth{Device: device, Model: report.ModelName, Serial: report.SerialNumber}
+	if report.SmartStatus != nil && !report.SmartStatus.Passed {
+		d.Failed = true
+		d.Problems = append(d.Problems, "SMART overall health self-assessment failed")
+	}
+	for _, attr := range report.ATASmartAttributes.Table {
+		switch {
+		case attr.WhenFailed == "now":
+			d.Problems = append(d.Problems, fmt.Sprintf("%s is below its failure threshold", attr.Name))
+		case smartctlCriticalAttributes[attr.ID] && attr.Raw.Value > 0:
+			d.Problems = append(d.Problems, fmt.Sprintf("%s = %d", attr.Name, attr.Raw.Value))
+		}
+	}
+	if nvme := report.NVMeLog; nvme != nil {
+		if nvme.CriticalWarning != 0 {
+			d.Problems = append(d.Problems, fmt.Sprintf("NVMe critical warning 0x%02x", nvme.CriticalWarning))
+		}
+		if nvme.AvailableSpareThreshold > 0 && nvme.AvailableSpare < nvme.AvailableSpareThreshold {
+			d.Problems = append(d.Problems, fmt.Sprintf("available spare %d%% below threshold %d%%", nvme.AvailableSpare, nvme.AvailableSpareThreshold))
+		}
+		if nvme.MediaErrors > 0 {
+			d.Problems = append(d.Problems, fmt.Sprintf("%d media errors", nvme.MediaErrors))
+		}
+		if nvme.PercentageUsed >= wearPercent {
+			d.Problems = append(d.Problems, fmt.Sprintf("%d%% of rated endurance used", nvme.PercentageUsed))
+		}
+	}
+	return d
+}
+
+// storageCounterSource reads MSFT_PhysicalDisk and its storage reliability
+// counters through PowerShell (Windows 8 / Server 2012 and later)
+type storageCounterSource struct {
+	wearPercent int
+}
+
+// storageCounterScript 每块物理磁盘输出一个对象；计数器不可用的磁盘（如部分RAID卡）值为空
+const storageCounterScript = `@(Get-PhysicalDisk | ForEach-Object {
+  $r = $_ | Get-StorageReliabilityCounter -ErrorAction SilentlyContinue
+  [pscustomobject]@{
+    DeviceId = [string]$_.DeviceId; FriendlyName = $_.FriendlyName; SerialNumber = $_.SerialNumber
+    HealthStatus = [string]$_.HealthStatus; OperationalStatus = ($_.OperationalStatus -join ',')
+    Wear = $r.Wear; ReadErrorsUncorrected = $r.ReadErrorsUncorrected; WriteErrorsUncorrected = $r.WriteErrorsUncorrected
+  }
+}) | ConvertTo-Json -Compress`
+
+// storageCounters is one disk as printed by storageCounterScript
+type storageCounters struct {
+	DeviceID               string `json:"DeviceId"`
+	FriendlyName           string `json:"FriendlyName"`
+	SerialNumber           string `json:"SerialNumber"`
+	HealthStatus           string `json:"HealthStatus"`
+	OperationalStatus      string `json:"OperationalStatus"`
+	Wear                   *int   `json:"Wear"`
+	ReadErrorsUncorrected  *int64 `json:"ReadErrorsUncorrected"`
+	WriteErrorsUncorrected *int64 `json:"WriteErrorsUncorrected"`
+}
+
+func (s storageCounterSource) disks(ctx context.Context) ([]diskHealth, error) {
+	ctx, cancel := context.WithTimeout(ctx, diskHealthCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", storageCounterScript).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage reliability counters: %v", err)
+	}
+	return parseStorageCounters(out, s.wearPercent)
+}
+
+// parseStorageCounters evaluates the output of storageCounterScript.
+// ConvertTo-Json prints a single disk as an object instead of an array.
+func parseStorageCounters(out []byte, wearPercent int) ([]diskHealth, error) {
+	out = []byte(strings.TrimSpace(string(out)))
+	if len(out) == 0 {
+		return nil, nil
+	}
+	var counters []storageCounters
+	if out[0] == '{' {
+		counters = make([]storageCounters, 1)
+		if err := json.Unmarshal(out, &counters[0]); err != nil {
+			return nil, fmt.Errorf("invalid storage reliability counters: %v", err)
+		}
+	} else if err := json.Unmarshal(out, &counters); err != nil {
+		return nil, fmt.Errorf("invalid storage reliability counters: %v", err)
+	}
+
+	var result []diskHealth
+	for _, c := range counters {
+		d := diskHealth{Device: "PhysicalDisk" + c.DeviceID, Model: c.FriendlyName, Serial: strings.TrimSpace(c.SerialNumber)}
+		switch c.HealthStatus {
+		case "Unhealthy":
+			d.Failed = true
+			d.Problems = append(d.Problems, fmt.Sprintf("health status Unhealthy (%s)", c.OperationalStatus))
+		case "Warning":
+			d.Problems = append(d.Problems, fmt.Sprintf("health status Warning (%s)", c.OperationalStatus))
+		}
+		if c.Wear != nil && *c.Wear >= wearPercent {
+			d.Problems = append(d.Problems, fmt.Sprintf("%d%% of rated endurance used", *c.Wear))
+		}
+		if c.ReadErrorsUncorrected != nil && *c.ReadErrorsUncorrected > 0 {
+			d.Problems = append(d.Problems, fmt.Sprintf("%d uncorrected read errors", *c.ReadErrorsUncorrected))
+		}
+		if c.WriteErrorsUncorrected != nil && *c.WriteErrorsUncorrected > 0 {
+			d.Problems = append(d.Problems, fmt.Sprintf("%d uncorrected write errors", *c.WriteErrorsUncorrected))
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// diskHealthWatch reports each disk once when its problems change
+type diskHealthWatch struct {
+	source   diskHealthSource
+	reported map[string]string // 设备 -> 已报告的问题
+	problem  string            // 已记录的读取失败原因
+}
+
+// check reads the health of all disks once
+func (w *diskHealthWatch) check(ctx context.Context) {
+	disks, err := w.source.disks(ctx)
+	if err != nil {
+		if w.problem != err.Error() {
+			logrus.Errorf("Disk health check failed: %v", err)
+			w.problem = err.Error()
+		}
+		return
+	}
+	if w.problem != "" {
+		logrus.Infof("Disk health check is working again")
+		w.problem = ""
+	}
+
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Device < disks[j].Device })
+	for _, d := range disks {
+		tags := map[string]string{"device": d.Device}
+		problems := strings.Join(d.Problems, "; ")
+		if problems == "" {
+			emitGauge("disk.health_ok", 1, tags)
+		} else {
+			emitGauge("disk.health_ok", 0, tags)
+		}
+		if problems == w.reported[d.Device] {
+			continue
+		}
+		w.reported[d.Device] = problems
+		if problems == "" {
+			logrus.Infof("Disk %s (%s) reports no problems", d.Device, d.Model)
+			continue
+		}
+		severity := SeverityWarning
+		if d.Failed {
+			severity = SeverityCritical
+		}
+		emitEvent(Event{
+			Severity: severity,
+			Type:     "disk_health_warning",
+			Message:  fmt.Sprintf("Disk %s (%s) may fail soon: %s", d.Device, d.Model, problems),
+			Details: map[string]string{
+				"device":   d.Device,
+				"model":    d.Model,
+				"serial":   d.Serial,
+				"problems": problems,
+			},
+		})
+	}
+}
+
+// runDiskHealth checks the disks every interval until ctx is cancelled
+func runDiskHealth(config DiskHealthConfig, ctx context.Context) {
+	w := &diskHealthWatch{source: newDiskHealthSource(config), reported: make(map[string]string)}
+	interval := time.Duration(defaultInt(config.Interval, defaultDiskHealthInterval)) * time.Second
+	logrus.Infof("Disk health monitor started, checking every %v", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const smartctlATAReport = `{
+  "model_name": "WDC WD40EFRX",
+  "serial_number": "WD-123",
+  "smart_status": {"passed": true},
+  "ata_smart_attributes": {"table": [
+    {"id": 5, "name": "Reallocated_Sector_Ct", "when_failed": "", "raw": {"value": 8}},
+    {"id": 9, "name": "Power_On_Hours", "when_failed": "", "raw": {"value": 40000}},
+    {"id": 197, "name": "Current_Pending_Sector", "when_failed": "", "raw": {"value": 0}},
+    {"id": 3, "name": "Spin_Up_Time", "when_failed": "now", "raw": {"value": 9000}}
+  ]}
+}`
+
+const smartctlNVMeReport = `{
+  "model_name": "Samsung SSD 980",
+  "smart_status": {"passed": false},
+  "nvme_smart_health_information_log": {
+    "critical_warning": 4, "available_spare": 5, "available_spare_threshold": 10,
+    "percentage_used": 95, "media_errors": 0
+  }
+}`
+
+func TestEvaluateSmartctl(t *testing.T) {
+	tests := []struct {
+		name       string
+		report     string
+		wantFailed bool
+		want       []string
+	}{
+		{"ata", smartctlATAReport, false, []string{"Reallocated_Sector_Ct = 8", "Spin_Up_Time is below its failure threshold"}},
+		{"nvme", smartctlNVMeReport, true, []string{"self-assessment failed", "critical warning 0x04", "available spare 5% below threshold 10%", "95% of rated endurance"}},
+		{"healthy", `{"smart_status": {"passed": true}}`, false, nil},
+	}
+	for _, tt := range tests {
+		var report smartctlReport
+		if err := json.Unmarshal([]byte(tt.report), &report); err != nil {
+			t.Fatal(err)
+		}
+		d := evaluateSmartctl("/dev/sda", report, 90)
+		if d.Failed != tt.wantFailed {
+			t.Errorf("%s: failed = %v, want %v", tt.name, d.Failed, tt.wantFailed)
+		}
+		if len(d.Problems) != len(tt.want) {
+			t.Errorf("%s: problems = %q, want %d", tt.name, d.Problems, len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(d.Problems[i], want) {
+				t.Errorf("%s: problem %d = %q, want %q", tt.name, i, d.Problems[i], want)
+			}
+		}
+	}
+}
+
+func TestParseStorageCounters(t *testing.T) {
+	single := `{"DeviceId":"0","FriendlyName":"NVMe disk","SerialNumber":" S1 ","HealthStatus":"Healthy","OperationalStatus":"OK","Wear":3,"ReadErrorsUncorrected":0,"WriteErrorsUncorrected":null}`
+	disks, err := parseStorageCounters([]byte(single), 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disks) != 1 || disks[0].Device != "PhysicalDisk0" || disks[0].Serial != "S1" || len(disks[0].Problems) != 0 {
+		t.Errorf("unexpected result for a healthy disk: %+v", disks)
+	}
+
+	several := `[` + single + `,{"DeviceId":"1","FriendlyName":"HDD","HealthStatus":"Warning","OperationalStatus":"Predictive Failure","Wear":null,"ReadErrorsUncorrected":12}]`
+	disks, err = parseStorageCounters([]byte(several), 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disks) != 2 || disks[1].Failed || len(disks[1].Problems) != 2 || !strings.Contains(disks[1].Problems[0], "Predictive Failure") {
+		t.Errorf("unexpected result for a failing disk: %+v", disks)
+	}
+
+	if disks, err := parseStorageCounters([]byte("  \r\n"), 90); err != nil || len(disks) != 0 {
+		t.Errorf("no disks: %v, %v", disks, err)
+	}
+}
+
+type fakeDiskHealthSource struct{ result []diskHealth }
+
+func (f *fakeDiskHealthSource) disks(ctx context.Context) ([]diskHealth, error) {
+	return f.result, nil
+}
+
+func TestDiskHealthWatchReportsChanges(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+	reported := func() []Event {
+		var events []Event
+		for _, e := range sink.events {
+			if e.Type == "disk_health_warning" && e.Details["device"] == "/dev/test-sdz" {
+				events = append(events, e)
+			}
+		}
+		return events
+	}
+
+	source := &fakeDiskHealthSource{result: []diskHealth{{Device: "/dev/test-sdz"}}}
+	w := &diskHealthWatch{source: source, reported: make(map[string]string)}
+	w.check(context.Background())
+	if n := len(reported()); n != 0 {
+		t.Fatalf("healthy disk reported %d times", n)
+	}
+
+	source.result = []diskHealth{{Device: "/dev/test-sdz", Problems: []string{"Reallocated_Sector_Ct = 8"}}}
+	w.check(context.Background())
+	w.check(context.Background())
+	if n := len(reported()); n != 1 {
+		t.Fatalf("problem reported %d times, want once", n)
+	}
+
+	// 问题加重时再次报告
+	source.result = []diskHealth{{Device: "/dev/test-sdz", Failed: true, Problems: []string{"Reallocated_Sector_Ct = 40"}}}
+	w.check(context.Background())
+	events := reported()
+	if len(events) != 2 || events[1].Severity != SeverityCritical {
+		t.Errorf("worse problem not reported as critical: %+v", events)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultDiskQuotaInterval = 300
+
+// 磁盘占用级别
+const (
+	diskLevelOK       = ""
+	diskLevelWarning  = SeverityWarning
+	diskLevelCritical = SeverityCritical
+)
+
+// DiskQuotaConfig 检查进程数据/日志目录的总大小，防止日志无限增长占满磁盘
+type DiskQuotaConfig struct {
+	Paths          []string `yaml:"paths"`           // 要统计的目录或文件
+	WarningMB      float64  `yaml:"warning_mb"`      // 超过时告警（0表示不检查）
+	CriticalMB     float64  `yaml:"critical_mb"`     // 超过时告警并执行 cleanup_command（0表示不检查）
+	CleanupCommand string   `yaml:"cleanup_command"` // 超过 critical_mb 时执行的清理命令（与钩子相同，经过 shell，受 hook_timeout 限制）
+	CheckInterval  int      `yaml:"check_interval"`  // 检查间隔（秒，默认300）
+}
+
+// enabled reports whether the disk quota is checked
+func (c DiskQuotaConfig) enabled() bool {
+	return len(c.Paths) > 0 && (c.WarningMB > 0 || c.CriticalMB > 0)
+}
+
+// validate checks the thresholds of a disk quota
+func (c DiskQuotaConfig) validate() error {
+	if len(c.Paths) == 0 {
+		if c.WarningMB > 0 || c.CriticalMB > 0 || c.CleanupCommand != "" {
+			return fmt.Errorf("disk_quota: paths is empty")
+		}
+		return nil
+	}
+	if c.WarningMB < 0 || c.CriticalMB < 0 || c.CheckInterval < 0 {
+		return fmt.Errorf("disk_quota: warning_mb, critical_mb and check_interval must not be negative")
+	}
+	if c.WarningMB == 0 && c.CriticalMB == 0 {
+		return fmt.Errorf("disk_quota: warning_mb or critical_mb is required")
+	}
+	if c.WarningMB > 0 && c.CriticalMB > 0 && c.WarningMB >= c.CriticalMB {
+		return fmt.Errorf("disk_quota: warning_mb must be below critical_mb")
+	}
+	if c.CleanupCommand != "" && c.CriticalMB == 0 {
+		return fmt.Errorf("disk_quota: cleanup_command requires critical_mb")
+	}
+	return nil
+}
+
+// level returns the level of usageMB against the thresholds
+func (c DiskQuotaConfig) level(usageMB float64) string {
+	switch {
+	case c.CriticalMB > 0 && usageMB > c.CriticalMB:
+		return diskLevelCritical
+	case c.WarningMB > 0 && usageMB > c.WarningMB:
+		return diskLevelWarning
+	default:
+		return diskLevelOK
+	}
+}
+
+// diskUsage returns the total size in bytes of the regular files under
+// paths. Missing paths count as empty; unreadable entries are skipped.
+func diskUsage(paths []string) int64 {
+	var total int64
+	for _, root := range paths {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					total += info.Size()
+				}
+			}
+			return nil
+		})
+	}
+	return total
+}
+
+// diskQuotaWatch reports the disk usage of one process whenever its level
+// changes and runs the cleanup command when it becomes critical
+type diskQuotaWatch struct {
+	config ProcessConfig
+	level  string
+	status func(usageMB float64) // 更新进程状态中的磁盘占用
+}
+
+// check measures the usage once
+func (w *diskQuotaWatch) check() {
+	quota := w.config.DiskQuota
+	usageMB := float64(diskUsage(quota.Paths)) / (1024 * 1024)
+	emitGauge("disk.usage_mb", usageMB, processTags(w.config.Name))
+	if w.status != nil {
+		w.status(usageMB)
+	}
+
+	level := quota.level(usageMB)
+	if level == w.level {
+		return
+	}
+	previous := w.level
+	w.level = level
+	if level == diskLevelOK {
+		logrus.Infof("Disk usage of %s is back to %.1f MB", w.config.Name, usageMB)
+		return
+	}
+
+	limit := quota.WarningMB
+	if level == diskLevelCritical {
+		limit = quota.CriticalMB
+	}
+	// 从 critical 降到 warning（如清理后）只记录日志
+	if previous == diskLevelCritical {
+		logrus.Warnf("Disk usage of %s dropped to %.1f MB, still above %.0f MB", w.config.Name, usageMB, limit)
+		return
+	}
+	emitEvent(Event{
+		Severity: level,
+		Type:     "disk_quota_exceeded",
+		Process:  w.config.Name,
+		Message:  fmt.Sprintf("Data directories of %s use %.1f MB, above the %s limit of %.0f MB", w.config.Name, usageMB, level, limit),
+		Details: map[string]string{
+			"usage_mb": fmt.Sprintf("%.1f", usageMB),
+			"limit_mb": fmt.Sprintf("%.0f", limit),
+			"level":    level,
+			"paths":    strings.Join(quota.Paths, ", "),
+		},
+	})
+	if level == diskLevelCritical && quota.CleanupCommand != "" {
+		w.cleanup(usageMB)
+	}
+}
+
+// cleanup runs the cleanup command and measures the usage again
+func (w *diskQuotaWatch) cleanup(usageMB float64) {
+	quota := w.config.DiskQuota
+	reason := fmt.Sprintf("disk usage %.1f MB above %.0f MB", usageMB, quota.CriticalMB)
+	logrus.Warnf("Running disk cleanup command for %s: %s", w.config.Name, quota.CleanupCommand)
+	if err := runHook(w.config, "disk_cleanup", quota.CleanupCommand, reason, 0); err != nil {
+		logrus.Errorf("Process %s: %v", w.config.Name, err)
+		return
+	}
+	after := float64(diskUsage(quota.Paths)) / (1024 * 1024)
+	logrus.Infof("Disk cleanup for %s freed %.1f MB (now %.1f MB)", w.config.Name, usageMB-after, after)
+}
+
+// watchDiskQuota checks the disk quota of the process until ctx is cancelled
+func (s *ProcessSupervisor) watchDiskQuota(ctx context.Context) {
+	config := s.config
+	w := &diskQuotaWatch{
+		config: config,
+		status: func(usageMB float64) { s.updateStatus(func(st *ProcessStatus) { st.DiskUsageMB = usageMB }) },
+	}
+	ticker := time.NewTicker(time.Duration(defaultInt(config.DiskQuota.CheckInterval, defaultDiskQuotaInterval)) * time.Second)
+	defer ticker.Stop()
+	for {
+		w.check()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
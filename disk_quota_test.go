@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDiskQuotaValidate(t *testing.T) {
+	tests := []struct {
+		quota DiskQuotaConfig
+		ok    bool
+	}{
+		{DiskQuotaConfig{}, true},
+		{DiskQuotaConfig{Paths: []string{"logs"}, WarningMB: 500, CriticalMB: 1000, CleanupCommand: "cleanup.cmd"}, true},
+		{DiskQuotaConfig{Paths: []string{"logs"}, CriticalMB: 1000}, true},
+		{DiskQuotaConfig{WarningMB: 500}, false},
+		{DiskQuotaConfig{Paths: []string{"logs"}}, false},
+		{DiskQuotaConfig{Paths: []string{"logs"}, WarningMB: 1000, CriticalMB: 500}, false},
+		{DiskQuotaConfig{Paths: []string{"logs"}, WarningMB: 500, CleanupCommand: "cleanup.cmd"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.quota.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok=%v", tt.quota, err, tt.ok)
+		}
+	}
+}
+
+func TestDiskQuotaWatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cleanup command uses a POSIX shell")
+	}
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	dir := t.TempDir()
+	write := func(name string, size int) {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll(filepath.Join(dir, "old"), 0755)
+	write("app.log", 300*1024)
+
+	const mb = 1.0 / 1024
+	config := ProcessConfig{Name: "logger", DiskQuota: DiskQuotaConfig{
+		Paths:      []string{dir, filepath.Join(dir, "missing")},
+		WarningMB:  400 * mb,
+		CriticalMB: 800 * mb,
+		// 清理命令删除归档的日志
+		CleanupCommand: "rm -f " + filepath.Join(dir, "old", "*"),
+	}}
+	var usage float64
+	w := &diskQuotaWatch{config: config, status: func(mb float64) { usage = mb }}
+	w.check()
+	if w.level != diskLevelOK || usage <= 0 {
+		t.Fatalf("level = %q, usage = %v", w.level, usage)
+	}
+
+	write("old/app.1.log", 200*1024)
+	w.check()
+	w.check()
+	if w.level != diskLevelWarning {
+		t.Fatalf("level = %q, want warning", w.level)
+	}
+
+	write("old/app.2.log", 400*1024)
+	w.check()
+	if w.level != diskLevelCritical {
+		t.Fatalf("level = %q, want critical", w.level)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old", "app.2.log")); !os.IsNotExist(err) {
+		t.Error("cleanup command did not run")
+	}
+	w.check()
+	if w.level != diskLevelOK {
+		t.Errorf("level after cleanup = %q, want ok", w.level)
+	}
+
+	var levels []string
+	for _, e := range sink.events {
+		if e.Process == "logger" && e.Type == "disk_quota_exceeded" {
+			levels = append(levels, e.Severity)
+		}
+	}
+	if len(levels) != 2 || levels[0] != SeverityWarning || levels[1] != SeverityCritical {
+		t.Errorf("events = %v, want one warning and one critical", levels)
+	}
+}
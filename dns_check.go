@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// parseExpectIP parses an entry of expect_ips: an IP or a CIDR range
+func parseExpectIP(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither an IP nor a CIDR range", s)
+	}
+	return network, nil
+}
+
+// dnsServer returns the resolver address host:port of the check, port 53
+// when only a host is given
+func (c HealthCheck) dnsServer() string {
+	if _, _, err := net.SplitHostPort(c.Server); err == nil {
+		return c.Server
+	}
+	return net.JoinHostPort(strings.Trim(c.Server, "[]"), "53")
+}
+
+// resolver returns the system resolver, or one asking only the server of
+// the check
+func (c HealthCheck) resolver() *net.Resolver {
+	if c.Server == "" {
+		return net.DefaultResolver
+	}
+	server := c.dnsServer()
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// runDNS resolves the check host. With expect_ips every resolved address
+// must be one of them, so a record pointing elsewhere fails the check.
+func (c HealthCheck) runDNS(timeout time.Duration) error {
+	var expect []*net.IPNet
+	for _, s := range c.ExpectIPs {
+		network, err := parseExpectIP(s)
+		if err != nil {
+			return err
+		}
+		expect = append(expect, network)
+	}
+	var addrs []net.IPAddr
+	err := withCheckTimeout(timeout, func(ctx context.Context) error {
+		var err error
+		addrs, err = c.resolver().LookupIPAddr(ctx, c.Address)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("%s has no addresses", c.Address)
+	}
+	var unexpected []string
+	for _, addr := range addrs {
+		matched := len(expect) == 0
+		for _, network := range expect {
+			if network.Contains(addr.IP) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unexpected = append(unexpected, addr.IP.String())
+		}
+	}
+	if len(unexpected) > 0 {
+		return fmt.Errorf("%s resolved to unexpected address(es) %s (expected %s)", c.Address, strings.Join(unexpected, ", "), strings.Join(c.ExpectIPs, ", "))
+	}
+	return nil
+}
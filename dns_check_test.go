@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"gopkg.in/yaml.v3"
+)
+
+// dnsServer answers A queries for db.corp. with addrs over UDP
+func dnsServer(t *testing.T, addrs ...string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if q.Name.String() != "db.corp." {
+				resp.RCode = dnsmessage.RCodeNameError
+			} else if q.Type == dnsmessage.TypeA {
+				for _, addr := range addrs {
+					var a [4]byte
+					copy(a[:], net.ParseIP(addr).To4())
+					resp.Answers = append(resp.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+						Body:   &dnsmessage.AResource{A: a},
+					})
+				}
+			}
+			if data, err := resp.Pack(); err == nil {
+				conn.WriteTo(data, peer)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSCheck(t *testing.T) {
+	server := dnsServer(t, "10.0.0.5", "10.0.1.7")
+	tests := []struct {
+		check   HealthCheck
+		wantErr string
+	}{
+		{HealthCheck{Address: "db.corp"}, ""},
+		{HealthCheck{Address: "db.corp", ExpectIPs: []string{"10.0.0.5", "10.0.1.0/24"}}, ""},
+		{HealthCheck{Address: "db.corp", ExpectIPs: []string{"10.0.0.5"}}, "unexpected address(es) 10.0.1.7"},
+		{HealthCheck{Address: "cache.corp"}, "no such host"},
+	}
+	for _, tt := range tests {
+		tt.check.Type, tt.check.Server, tt.check.Timeout = CheckDNS, server, 2
+		err := tt.check.run(ProcessConfig{Name: "web.exe"}, 0)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s %v: err = %v, want %q", tt.check, tt.check.ExpectIPs, err, tt.wantErr)
+		}
+	}
+
+	// 不指定 server 时使用系统解析
+	check := HealthCheck{Type: CheckDNS, Address: "localhost", ExpectIPs: []string{"127.0.0.0/8", "::1"}}
+	if err := check.run(ProcessConfig{Name: "web.exe"}, 0); err != nil {
+		t.Errorf("localhost: %v", err)
+	}
+}
+
+func TestDNSServerAddress(t *testing.T) {
+	for server, want := range map[string]string{
+		"10.0.0.53":       "10.0.0.53:53",
+		"10.0.0.53:5353":  "10.0.0.53:5353",
+		"ns1.corp":        "ns1.corp:53",
+		"fd00::53":        "[fd00::53]:53",
+		"[fd00::53]":      "[fd00::53]:53",
+		"[fd00::53]:5353": "[fd00::53]:5353",
+	} {
+		if got := (HealthCheck{Server: server}).dnsServer(); got != want {
+			t.Errorf("dnsServer(%q) = %q, want %q", server, got, want)
+		}
+	}
+}
+
+func TestNetworkCheckValidate(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{`"ping:10.0.0.5"`, ""},
+		{`{type: ping, address: db.corp, count: 5, timeout: 3}`, ""},
+		{`{type: dns, address: db.corp, expect_ips: ["10.0.0.5", "fd00::/64"], server: "10.0.0.53"}`, ""},
+		{`"ping:"`, "host name or IP"},
+		{`"ping:db.corp:5432"`, "host name or IP"},
+		{`"ping:fd00::5"`, ""},
+		{`{type: dns, address: "10.0.0.5"}`, "must be a host name"},
+		{`{type: dns, address: db.corp, expect_ips: ["10.0.0"]}`, "neither an IP nor a CIDR"},
+		{`{type: dns, address: db.corp, count: 3}`, "only for ping"},
+		{`{type: ping, address: db.corp, count: -1}`, "must not be negative"},
+		{`{type: tcp, address: "db.corp:5432", server: "10.0.0.53"}`, "only for dns"},
+		{`{type: ping, address: db.corp, expect_ips: ["10.0.0.5"]}`, "only for dns"},
+	}
+	for _, tt := range tests {
+		var check HealthCheck
+		if err := yaml.Unmarshal([]byte(tt.yaml), &check); err != nil {
+			t.Fatalf("%s: %v", tt.yaml, err)
+		}
+		err := check.validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: err = %v, want %q", tt.yaml, err, tt.wantErr)
+		}
+	}
+}
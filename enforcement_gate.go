@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EnforcementGate 控制何时将期望值写回。不满足条件时只记录偏差，不做恢复，
+// 以便管理员在变更窗口内进行的手动修改不会被立即还原
+type EnforcementGate struct {
+	ActiveDuring  []TimeWindow `yaml:"active_during"`  // 只在这些时间段内执行（为空表示任何时间）
+	SuspendDuring []TimeWindow `yaml:"suspend_during"` // 这些时间段内暂停（如变更窗口）
+	SuspendMarker string       `yaml:"suspend_marker"` // 该文件存在时暂停
+}
+
+// TimeWindow is a daily period, optionally restricted to some weekdays.
+// End before Start means the window crosses midnight.
+type TimeWindow struct {
+	Days  []string `yaml:"days"`  // mon, tue, wed, thu, fri, sat, sun（为空表示每天）
+	Start string   `yaml:"start"` // HH:MM（本地时间）
+	End   string   `yaml:"end"`   // HH:MM
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock parses HH:MM into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether now falls inside the window. For windows crossing
+// midnight the weekday of the start applies, so "fri 22:00-02:00" includes
+// early Saturday morning.
+func (w TimeWindow) Contains(now time.Time) (bool, error) {
+	_, inside, err := w.occurrence(now)
+	return inside, err
+}
+
+// occurrence is Contains that also returns the day on which the window
+// containing now opened: the day before for the part after midnight
+func (w TimeWindow) occurrence(now time.Time) (time.Time, bool, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	minute := now.Hour()*60 + now.Minute()
+
+	opened := now
+	var inside bool
+	switch {
+	case start <= end:
+		inside = minute >= start && minute < end
+	case minute >= start:
+		inside = true
+	case minute < end:
+		// 跨午夜窗口的后半段属于前一天
+		inside = true
+		opened = now.AddDate(0, 0, -1)
+	}
+	if !inside {
+		return time.Time{}, false, nil
+	}
+	ok, err := w.matchesDay(opened.Weekday())
+	return opened, ok, err
+}
+
+func (w TimeWindow) matchesDay(day time.Weekday) (bool, error) {
+	if len(w.Days) == 0 {
+		return true, nil
+	}
+	for _, name := range w.Days {
+		// 接受 mon 或 monday
+		key := strings.ToLower(strings.TrimSpace(name))
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		d, ok := weekdayNames[key]
+		if !ok {
+			return false, fmt.Errorf("invalid weekday %q", name)
+		}
+		if d == day {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Active reports whether enforcement is allowed at now, and if not, why
+func (g EnforcementGate) Active(now time.Time) (bool, string) {
+	if reportOnlyMode() {
+		return false, "report-only security mode"
+	}
+	if g.SuspendMarker != "" && fileExists(g.SuspendMarker) {
+		return false, "marker file " + g.SuspendMarker + " exists"
+	}
+	for _, w := range g.SuspendDuring {
+		in, err := w.Contains(now)
+		if err != nil {
+			return false, "invalid suspend_during window: " + err.Error()
+		}
+		if in {
+			return false, fmt.Sprintf("inside suspend window %s-%s", w.Start, w.End)
+		}
+	}
+	if len(g.ActiveDuring) == 0 {
+		return true, ""
+	}
+	for _, w := range g.ActiveDuring {
+		in, err := w.Contains(now)
+		if err != nil {
+			return false, "invalid active_during window: " + err.Error()
+		}
+		if in {
+			return true, ""
+		}
+	}
+	return false, "outside active windows"
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeWindowContains(t *testing.T) {
+	// 2024-03-01 是星期五
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name   string
+		window TimeWindow
+		now    time.Time
+		want   bool
+	}{
+		{"inside daily window", TimeWindow{Start: "09:00", End: "17:00"}, at(1, 12, 0), true},
+		{"end is exclusive", TimeWindow{Start: "09:00", End: "17:00"}, at(1, 17, 0), false},
+		{"weekday mismatch", TimeWindow{Days: []string{"mon"}, Start: "09:00", End: "17:00"}, at(1, 12, 0), false},
+		{"full weekday name", TimeWindow{Days: []string{"Friday"}, Start: "09:00", End: "17:00"}, at(1, 12, 0), true},
+		{"overnight before midnight", TimeWindow{Days: []string{"fri"}, Start: "22:00", End: "02:00"}, at(1, 23, 0), true},
+		{"overnight after midnight uses start day", TimeWindow{Days: []string{"fri"}, Start: "22:00", End: "02:00"}, at(2, 1, 0), true},
+		{"overnight after midnight wrong day", TimeWindow{Days: []string{"sat"}, Start: "22:00", End: "02:00"}, at(2, 1, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.window.Contains(tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcementGateActive(t *testing.T) {
+	noon := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	marker := filepath.Join(t.TempDir(), "maintenance.flag")
+
+	gate := EnforcementGate{
+		SuspendDuring: []TimeWindow{{Start: "11:00", End: "13:00"}},
+	}
+	if active, _ := gate.Active(noon); active {
+		t.Error("enforcement active inside suspend window")
+	}
+
+	gate = EnforcementGate{ActiveDuring: []TimeWindow{{Start: "18:00", End: "06:00"}}, SuspendMarker: marker}
+	if active, _ := gate.Active(noon); active {
+		t.Error("enforcement active outside active window")
+	}
+	if active, _ := gate.Active(noon.Add(8 * time.Hour)); !active {
+		t.Error("enforcement not active inside active window")
+	}
+
+	os.WriteFile(marker, nil, 0644)
+	if active, reason := gate.Active(noon.Add(8 * time.Hour)); active {
+		t.Error("enforcement active while marker file exists")
+	} else if reason == "" {
+		t.Error("missing suspend reason")
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// FailureSnapshotConfig 触发重启的故障发生时采集主机环境快照，附加到重启事件
+type FailureSnapshotConfig struct {
+	Enable       bool `yaml:"enable"`        // 是否启用
+	TopProcesses int  `yaml:"top_processes"` // 记录CPU占用最高的进程数（默认5）
+	LogLines     int  `yaml:"log_lines"`     // 系统事件日志和子进程日志各记录的行数（默认20）
+}
+
+// failureSnapshots 启动时由配置设置
+var failureSnapshots FailureSnapshotConfig
+
+const (
+	snapshotCPUSample = 500 * time.Millisecond
+	snapshotTimeout   = 10 * time.Second
+	snapshotMaxPorts  = 100
+)
+
+// EnvSnapshot is the state of the host at the time a process failed
+type EnvSnapshot struct {
+	Time           time.Time         `json:"time"`
+	Memory         snapshotMemory    `json:"memory"`
+	Disks          []snapshotDisk    `json:"disks,omitempty"`
+	TopProcesses   []snapshotProcess `json:"top_processes,omitempty"`
+	ListeningPorts []snapshotPort    `json:"listening_ports,omitempty"`
+	EventLog       []string          `json:"event_log,omitempty"`   // 最近的系统错误/警告事件
+	ProcessLog     []string          `json:"process_log,omitempty"` // 子进程日志文件的最后几行
+	Errors         []string          `json:"errors,omitempty"`      // 未能采集的部分
+}
+
+type snapshotMemory struct {
+	TotalMB     float64 `json:"total_mb"`
+	AvailableMB float64 `json:"available_mb"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+type snapshotDisk struct {
+	Path        string  `json:"path"`
+	TotalGB     float64 `json:"total_gb"`
+	FreeGB      float64 `json:"free_gb"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+type snapshotProcess struct {
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	CPUPercent float64 `json:"cpu_percent"`
+	MemoryMB   float64 `json:"memory_mb"`
+}
+
+type snapshotPort struct {
+	Address string `json:"address"`
+	PID     int32  `json:"pid"`
+	Process string `json:"process,omitempty"`
+}
+
+// captureEnvSnapshot collects the snapshot for a failure of config. Parts
+// that cannot be collected are listed in Errors instead of failing.
+func captureEnvSnapshot(config ProcessConfig, settings FailureSnapshotConfig) *EnvSnapshot {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	lines := defaultInt(settings.LogLines, 20)
+	snapshot := &EnvSnapshot{Time: time.Now()}
+	fail := func(part string, err error) {
+		snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("%s: %v", part, err))
+	}
+
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		snapshot.Memory = snapshotMemory{
+			TotalMB:     float64(vm.Total) / 1024 / 1024,
+			AvailableMB: float64(vm.Available) / 1024 / 1024,
+			UsedPercent: vm.UsedPercent,
+		}
+	} else {
+		fail("memory", err)
+	}
+
+	if partitions, err := disk.PartitionsWithContext(ctx, false); err == nil {
+		for _, p := range partitions {
+			usage, err := disk.UsageWithContext(ctx, p.Mountpoint)
+			if err != nil || usage.Total == 0 {
+				continue
+			}
+			snapshot.Disks = append(snapshot.Disks, snapshotDisk{
+				Path:        p.Mountpoint,
+				TotalGB:     float64(usage.Total) / 1024 / 1024 / 1024,
+				FreeGB:      float64(usage.Free) / 1024 / 1024 / 1024,
+				UsedPercent: usage.UsedPercent,
+			})
+		}
+	} else {
+		fail("disks", err)
+	}
+
+	names := make(map[int32]string)
+	if top, err := topCPUProcesses(ctx, defaultInt(settings.TopProcesses, 5), names); err == nil {
+		snapshot.TopProcesses = top
+	} else {
+		fail("processes", err)
+	}
+
+	if conns, err := psnet.ConnectionsWithContext(ctx, "tcp"); err == nil {
+		for _, c := range conns {
+			if c.Status != "LISTEN" {
+				continue
+			}
+			if len(snapshot.ListeningPorts) == snapshotMaxPorts {
+				snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("ports: only the first %d listening ports are listed", snapshotMaxPorts))
+				break
+			}
+			snapshot.ListeningPorts = append(snapshot.ListeningPorts, snapshotPort{
+				Address: net.JoinHostPort(c.Laddr.IP, strconv.Itoa(int(c.Laddr.Port))),
+				PID:     c.Pid,
+				Process: names[c.Pid],
+			})
+		}
+		sort.Slice(snapshot.ListeningPorts, func(i, j int) bool {
+			return snapshot.ListeningPorts[i].Address < snapshot.ListeningPorts[j].Address
+		})
+	} else {
+		fail("ports", err)
+	}
+
+	if events, err := systemLogTail(ctx, lines); err == nil {
+		snapshot.EventLog = events
+	} else {
+		fail("event log", err)
+	}
+
+	if config.LogFile != "" {
+		if data, err := readFileTail(config.LogFile, 64*1024); err == nil {
+			snapshot.ProcessLog = lastLines(data, lines)
+		} else {
+			fail("process log", err)
+		}
+	}
+	return snapshot
+}
+
+// topCPUProcesses samples the CPU time of every process twice and returns
+// the n busiest; names collects the name of every process seen
+func topCPUProcesses(ctx context.Context, n int, names map[int32]string) ([]snapshotProcess, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	before := make(map[int32]float64, len(procs))
+	for _, p := range procs {
+		if times, err := p.TimesWithContext(ctx); err == nil {
+			before[p.Pid] = times.User + times.System
+		}
+	}
+	start := time.Now()
+	select {
+	case <-time.After(snapshotCPUSample):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	elapsed := time.Since(start).Seconds()
+
+	var top []snapshotProcess
+	for _, p := range procs {
+		name, _ := p.NameWithContext(ctx)
+		names[p.Pid] = name
+		times, err := p.TimesWithContext(ctx)
+		previous, ok := before[p.Pid]
+		if err != nil || !ok {
+			continue
+		}
+		entry := snapshotProcess{PID: p.Pid, Name: name, CPUPercent: (times.User + times.System - previous) / elapsed * 100}
+		if info, err := p.MemoryInfoWithContext(ctx); err == nil {
+			entry.MemoryMB = float64(info.RSS) / 1024 / 1024
+		}
+		top = append(top, entry)
+	}
+	sort.Slice(top, func(i, j int) bool { return top[i].CPUPercent > top[j].CPUPercent })
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top, nil
+}
+
+// lastLines returns the last n non-empty lines of data
+func lastLines(data []byte, n int) []string {
+	var lines []string
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if line := strings.TrimRight(string(line), "\r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// summary renders the snapshot as text for mail and log output
+func (s *EnvSnapshot) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Memory: %.0f%% used, %.0fMB of %.0fMB available\n", s.Memory.UsedPercent, s.Memory.AvailableMB, s.Memory.TotalMB)
+	for _, d := range s.Disks {
+		fmt.Fprintf(&b, "Disk %s: %.0f%% used, %.1fGB free\n", d.Path, d.UsedPercent, d.FreeGB)
+	}
+	if len(s.TopProcesses) > 0 {
+		b.WriteString("Top CPU:\n")
+		for _, p := range s.TopProcesses {
+			fmt.Fprintf(&b, "  %6.1f%%  %8.1fMB  %s (%d)\n", p.CPUPercent, p.MemoryMB, p.Name, p.PID)
+		}
+	}
+	if len(s.ListeningPorts) > 0 {
+		ports := make([]string, 0, len(s.ListeningPorts))
+		for _, p := range s.ListeningPorts {
+			if p.Process != "" {
+				ports = append(ports, fmt.Sprintf("%s (%s)", p.Address, p.Process))
+			} else {
+				ports = append(ports, p.Address)
+			}
+		}
+		fmt.Fprintf(&b, "Listening: %s\n", strings.Join(ports, ", "))
+	}
+	if len(s.EventLog) > 0 {
+		b.WriteString("Event log:\n")
+		for _, line := range s.EventLog {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	if len(s.ProcessLog) > 0 {
+		b.WriteString("Process log:\n")
+		for _, line := range s.ProcessLog {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	for _, err := range s.Errors {
+		fmt.Fprintf(&b, "Not collected: %s\n", err)
+	}
+	return b.String()
+}
+
+// captureFailureSnapshot records the environment before a failure-triggered
+// restart kills the process, so its ports and log are still as they were
+func (s *ProcessSupervisor) captureFailureSnapshot(reason string) {
+	if !failureSnapshots.Enable {
+		return
+	}
+	start := time.Now()
+	s.failureSnapshot = captureEnvSnapshot(s.config, failureSnapshots)
+	logrus.Debugf("Captured environment snapshot for %s (%s) in %v", s.config.Name, reason, time.Since(start).Round(time.Millisecond))
+}
+
+// takeFailureSnapshot returns the snapshot captured for the current restart, if any
+func (s *ProcessSupervisor) takeFailureSnapshot() *EnvSnapshot {
+	snapshot := s.failureSnapshot
+	s.failureSnapshot = nil
+	return snapshot
+}
+
+// parseWevtutilText condenses the text output of "wevtutil qe /f:text" to
+// one line per event: date, level, source and the first description line
+func parseWevtutilText(out string) []string {
+	var events []string
+	var date, level, source, description string
+	inDescription := false
+	flush := func() {
+		if date != "" || source != "" {
+			events = append(events, strings.TrimSpace(fmt.Sprintf("%s %s %s: %s", date, level, source, description)))
+		}
+		date, level, source, description = "", "", "", ""
+		inDescription = false
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Event["):
+			flush()
+		case inDescription:
+			if description == "" && trimmed != "" {
+				description = trimmed
+			}
+		case strings.HasPrefix(trimmed, "Date:"):
+			date = strings.TrimSpace(strings.TrimPrefix(trimmed, "Date:"))
+		case strings.HasPrefix(trimmed, "Level:"):
+			level = strings.TrimSpace(strings.TrimPrefix(trimmed, "Level:"))
+		case strings.HasPrefix(trimmed, "Source:"):
+			source = strings.TrimSpace(strings.TrimPrefix(trimmed, "Source:"))
+		case strings.HasPrefix(trimmed, "Description:"):
+			inDescription = true
+			description = strings.TrimSpace(strings.TrimPrefix(trimmed, "Description:"))
+		}
+	}
+	flush()
+	return events
+}
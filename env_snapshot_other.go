@@ -0,0 +1,30 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// systemLogTail returns the latest warnings and errors of the systemd journal
+func systemLogTail(ctx context.Context, n int) ([]string, error) {
+	path, err := exec.LookPath("journalctl")
+	if err != nil {
+		return nil, fmt.Errorf("no system log available (journalctl not found)")
+	}
+	out, err := exec.CommandContext(ctx, path, "-n", fmt.Sprint(n), "-p", "warning", "--no-pager", "-o", "short-iso").Output()
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range lastLines(out, n) {
+		// 跳过 "-- No entries --"、"-- Boot ... --" 等说明行
+		if !strings.HasPrefix(line, "-- ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
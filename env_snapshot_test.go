@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWevtutilText(t *testing.T) {
+	out := "Event[0]:\r\n" +
+		"  Log Name: Application\r\n" +
+		"  Source: Application Error\r\n" +
+		"  Date: 2025-06-05T16:12:35.1230000Z\r\n" +
+		"  Event ID: 1000\r\n" +
+		"  Level: Error\r\n" +
+		"  Description: \r\n" +
+		"Faulting application name: api_server.exe, version: 2.3.0.0\r\n" +
+		"Faulting module name: ntdll.dll\r\n" +
+		"\r\n" +
+		"Event[1]:\r\n" +
+		"  Log Name: Application\r\n" +
+		"  Source: MSSQLSERVER\r\n" +
+		"  Date: 2025-06-05T16:10:00.0000000Z\r\n" +
+		"  Level: Warning\r\n" +
+		"  Description: Login failed for user 'app'.\r\n"
+	want := []string{
+		"2025-06-05T16:12:35.1230000Z Error Application Error: Faulting application name: api_server.exe, version: 2.3.0.0",
+		"2025-06-05T16:10:00.0000000Z Warning MSSQLSERVER: Login failed for user 'app'.",
+	}
+	if got := parseWevtutilText(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWevtutilText =\n%q\nwant\n%q", got, want)
+	}
+	if got := parseWevtutilText(""); len(got) != 0 {
+		t.Errorf("empty output parsed as %q", got)
+	}
+}
+
+func TestCaptureEnvSnapshot(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "api.log")
+	if err := os.WriteFile(logFile, []byte("starting\r\nlistening on :8080\n\npanic: nil map\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := captureEnvSnapshot(ProcessConfig{Name: "api", LogFile: logFile}, FailureSnapshotConfig{TopProcesses: 3, LogLines: 2})
+
+	if snapshot.Memory.TotalMB <= 0 {
+		t.Errorf("memory not collected: %+v (errors: %q)", snapshot.Memory, snapshot.Errors)
+	}
+	if len(snapshot.TopProcesses) == 0 || len(snapshot.TopProcesses) > 3 {
+		t.Errorf("top processes = %+v, want 1-3 entries", snapshot.TopProcesses)
+	}
+	if want := []string{"listening on :8080", "panic: nil map"}; !reflect.DeepEqual(snapshot.ProcessLog, want) {
+		t.Errorf("process log = %q, want %q", snapshot.ProcessLog, want)
+	}
+	summary := snapshot.summary()
+	for _, want := range []string{"Memory:", "Top CPU:", "Process log:\n  listening on :8080"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary does not contain %q:\n%s", want, summary)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// systemLogTail returns the latest critical, error and warning events of the
+// Application and System logs
+func systemLogTail(ctx context.Context, n int) ([]string, error) {
+	var events []string
+	for _, log := range []string{"Application", "System"} {
+		out, err := exec.CommandContext(ctx, "wevtutil", "qe", log,
+			"/q:*[System[(Level=1 or Level=2 or Level=3)]]", fmt.Sprintf("/c:%d", n), "/rd:true", "/f:text").Output()
+		if err != nil {
+			return events, fmt.Errorf("%s log: %v", log, err)
+		}
+		for _, e := range parseWevtutilText(string(out)) {
+			events = append(events, log+": "+e)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// EventHistoryConfig 将事件历史保存到嵌入式数据库（bbolt），监控程序重启后仍可查询
+type EventHistoryConfig struct {
+	Path          string `yaml:"path"`           // 数据库文件，如 "data/events.db"（为空则只保存在内存中）
+	RetentionDays int    `yaml:"retention_days"` // 保留天数（默认30）
+	MaxEvents     int    `yaml:"max_events"`     // 最多保留的事件数量（0表示不限）
+}
+
+const (
+	eventPruneInterval = time.Hour
+	// 并发产生的事件ID顺序与时间可能略有出入，按时间过滤时多扫描这段时间
+	eventClockSlack = time.Minute
+)
+
+var (
+	eventsBucket  = []byte("events")
+	stateBucket   = []byte("state")
+	metricsBucket = []byte("metrics")
+)
+
+// eventRecorder is an event store that records the events it is sent
+type eventRecorder interface {
+	EventStore
+	EventSink
+}
+
+// boltEventStore keeps every event in a bbolt database, keyed by its ID in
+// big-endian order so cursors walk the events in the order they occurred.
+// It is the bolt storage backend: the state and the metric snapshots are
+// kept in buckets of their own.
+type boltEventStore struct {
+	db        *bolt.DB
+	retention time.Duration
+	maxEvents int
+}
+
+// openBoltEventStore opens or creates the event database of config
+func openBoltEventStore(config EventHistoryConfig) (*boltEventStore, error) {
+	if dir := filepath.Dir(config.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	// 另一个实例持有文件锁时不无限等待
+	db, err := bolt.Open(config.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event database %s: %v", config.Path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{eventsBucket, stateBucket, metricsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize event database %s: %v", config.Path, err)
+	}
+	return &boltEventStore{
+		db:        db,
+		retention: time.Duration(defaultInt(config.RetentionDays, 30)) * 24 * time.Hour,
+		maxEvents: config.MaxEvents,
+	}, nil
+}
+
+func eventKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// HandleEvent implements EventSink
+func (b *boltEventStore) HandleEvent(e Event) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = id
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(eventKey(id), data)
+	})
+	// 停止时数据库已关闭，之后的事件只记录在日志中
+	if err != nil && err != bolt.ErrDatabaseNotOpen {
+		logrus.Errorf("Failed to record event %s in history: %v", e.Type, err)
+	}
+}
+
+// Query implements EventStore
+func (b *boltEventStore) Query(filter EventFilter) ([]Event, bool) {
+	var result []Event
+	more := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		var k, v []byte
+		if filter.Before > 0 {
+			// 游标指向第一个不小于 Before 的事件，从它的前一个开始
+			if k, _ = c.Seek(eventKey(filter.Before)); k != nil {
+				k, v = c.Prev()
+			} else {
+				k, v = c.Last()
+			}
+		} else {
+			k, v = c.Last()
+		}
+		for ; k != nil; k, v = c.Prev() {
+			var e Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				continue
+			}
+			if !filter.Since.IsZero() && e.Time.Before(filter.Since.Add(-eventClockSlack)) {
+				break
+			}
+			if !filter.matches(e) {
+				continue
+			}
+			if filter.Limit > 0 && len(result) == filter.Limit {
+				more = true
+				break
+			}
+			result = append(result, e)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("Failed to query event history: %v", err)
+	}
+	return result, more
+}
+
+// LoadState implements Storage
+func (b *boltEventStore) LoadState(key string) ([]byte, error) {
+	var data []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(stateBucket).Get([]byte(key)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data, err
+}
+
+// SaveState implements Storage
+func (b *boltEventStore) SaveState(key string, data []byte) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Put([]byte(key), data)
+	})
+	if err == bolt.ErrDatabaseNotOpen {
+		return nil
+	}
+	return err
+}
+
+// RecordMetrics implements Storage; each snapshot is one record
+func (b *boltEventStore) RecordMetrics(samples []MetricSample) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(metricsBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(eventKey(id), data)
+	})
+	if err == bolt.ErrDatabaseNotOpen {
+		return nil
+	}
+	return err
+}
+
+// pruneMetrics deletes the metric snapshots older than the retention
+func (b *boltEventStore) pruneMetrics(now time.Time) (int, error) {
+	removed := 0
+	cutoff := now.Add(-b.retention)
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(metricsBucket)
+		var expired [][]byte
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var samples []MetricSample
+			if json.Unmarshal(v, &samples) == nil && len(samples) > 0 && !samples[0].Time.Before(cutoff) {
+				break
+			}
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	return removed, err
+}
+
+// prune deletes the events older than the retention and the oldest events
+// beyond max_events
+func (b *boltEventStore) prune(now time.Time) (int, error) {
+	removed := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		excess := 0
+		if b.maxEvents > 0 {
+			excess = bucket.Stats().KeyN - b.maxEvents
+		}
+		cutoff := now.Add(-b.retention)
+		// 遍历时删除会使游标跳过元素，先收集再删除
+		var expired [][]byte
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var e Event
+			if len(expired) >= excess && json.Unmarshal(v, &e) == nil && !e.Time.Before(cutoff) {
+				break
+			}
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	return removed, err
+}
+
+// Run prunes the history at startup and every hour until ctx is cancelled,
+// then closes the database
+func (b *boltEventStore) Run(ctx context.Context) {
+	defer b.db.Close()
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+	for {
+		if removed, err := b.prune(time.Now()); err != nil {
+			logrus.Errorf("Failed to prune event history: %v", err)
+		} else if removed > 0 {
+			logrus.Infof("Removed %d event(s) past retention from the event history", removed)
+		}
+		if _, err := b.pruneMetrics(time.Now()); err != nil {
+			logrus.Errorf("Failed to prune metrics history: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltEventStore(t *testing.T) {
+	config := EventHistoryConfig{Path: filepath.Join(t.TempDir(), "data", "events.db"), RetentionDays: 7, MaxEvents: 4}
+	store, err := openBoltEventStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, e := range []Event{
+		{Time: now.Add(-10 * 24 * time.Hour), Type: "process_restarted", Process: "old.exe"},
+		{Time: now.Add(-3 * time.Hour), Type: "process_started", Process: "web.exe"},
+		{Time: now.Add(-2 * time.Hour), Type: "health_check_failed", Process: "web.exe", Severity: SeverityWarning},
+		{Time: now.Add(-1 * time.Hour), Type: "process_restarted", Process: "web.exe", Details: map[string]string{"reason": "health check failed"}},
+		{Time: now.Add(-30 * time.Minute), Type: "registry_value_restored"},
+		{Time: now, Type: "process_stopped", Process: "worker.exe"},
+	} {
+		e.Message = "event"
+		store.HandleEvent(e)
+	}
+
+	events, more := store.Query(EventFilter{Processes: []string{"web.exe"}, Limit: 2})
+	if len(events) != 2 || !more || events[0].Type != "process_restarted" || events[0].Details["reason"] != "health check failed" {
+		t.Fatalf("first page = %+v, more=%v", events, more)
+	}
+	events, more = store.Query(EventFilter{Processes: []string{"web.exe"}, Limit: 2, Before: events[1].ID})
+	if len(events) != 1 || more || events[0].Type != "process_started" {
+		t.Errorf("second page = %+v, more=%v", events, more)
+	}
+	if events, _ := store.Query(EventFilter{Since: now.Add(-90 * time.Minute)}); len(events) != 3 {
+		t.Errorf("since filter returned %d events", len(events))
+	}
+
+	// 超过7天的事件和超过 max_events 的最旧事件被删除
+	removed, err := store.prune(now)
+	if err != nil || removed != 2 {
+		t.Errorf("prune removed %d (%v), want 2", removed, err)
+	}
+	store.db.Close()
+
+	// 重新打开后事件仍在，新事件的ID继续递增
+	store, err = openBoltEventStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.db.Close()
+	store.HandleEvent(Event{Time: now, Type: "process_started", Process: "worker.exe"})
+	events, _ = store.Query(EventFilter{})
+	if len(events) != 5 || events[0].ID != 7 || events[4].Type != "health_check_failed" {
+		t.Errorf("after reopen: %+v", events)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultEventBuffer 内存中保留的最近事件数量
+const defaultEventBuffer = 10000
+
+// EventFilter selects events from an EventStore. Zero values match everything.
+type EventFilter struct {
+	Processes  []string
+	Severities []string
+	Types      []string
+	Since      time.Time
+	Until      time.Time
+	Before     uint64 // 分页游标：只返回ID小于该值的事件
+	Limit      int
+}
+
+// EventStore answers event queries for the API, newest first
+type EventStore interface {
+	Query(filter EventFilter) (events []Event, more bool)
+}
+
+// memoryEventStore keeps the most recent events in a ring buffer. It is an
+// EventSink, so it sees every emitted event once registered.
+type memoryEventStore struct {
+	mu     sync.RWMutex
+	events []Event
+	start  int // 环形缓冲区中最旧事件的位置
+	nextID uint64
+}
+
+func newMemoryEventStore(capacity int) *memoryEventStore {
+	if capacity <= 0 {
+		capacity = defaultEventBuffer
+	}
+	return &memoryEventStore{events: make([]Event, 0, capacity), nextID: 1}
+}
+
+// eventHistory is the store behind GET /events: the storage backend, in
+// memory unless storage or event_history.path is set
+var eventHistory eventRecorder = newMemoryEventStore(defaultEventBuffer)
+
+// HandleEvent implements EventSink
+func (m *memoryEventStore) HandleEvent(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = m.nextID
+	m.nextID++
+	if len(m.events) < cap(m.events) {
+		m.events = append(m.events, e)
+		return
+	}
+	m.events[m.start] = e
+	m.start = (m.start + 1) % len(m.events)
+}
+
+// Query implements EventStore
+func (m *memoryEventStore) Query(filter EventFilter) ([]Event, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []Event
+	for i := len(m.events) - 1; i >= 0; i-- {
+		e := m.events[(m.start+i)%len(m.events)]
+		if filter.Before > 0 && e.ID >= filter.Before {
+			continue
+		}
+		if !filter.matches(e) {
+			continue
+		}
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			return result, true
+		}
+		result = append(result, e)
+	}
+	return result, false
+}
+
+func (f EventFilter) matches(e Event) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return matchesAny(f.Processes, e.Process) && matchesAny(f.Severities, e.Severity) && matchesAny(f.Types, e.Type)
+}
+
+// matchesAny reports whether value is one of values; an empty list matches all
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
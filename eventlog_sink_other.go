@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+func newEventLogSink(config EventLogConfig) (EventSink, error) {
+	return nil, fmt.Errorf("the Windows event log is only available on Windows")
+}
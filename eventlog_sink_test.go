@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEventLogFilter(t *testing.T) {
+	tests := []struct {
+		config EventLogConfig
+		event  Event
+		want   bool
+	}{
+		{EventLogConfig{}, Event{Severity: SeverityWarning, Type: "registry_value_restored"}, true},
+		{EventLogConfig{}, Event{Severity: SeverityCritical, Type: "monitor_panic"}, true},
+		{EventLogConfig{}, Event{Severity: SeverityInfo, Type: "process_restarted"}, true}, // 默认额外写入
+		{EventLogConfig{}, Event{Severity: SeverityInfo, Type: "circuit_closed"}, false},
+		{EventLogConfig{Events: []string{}}, Event{Severity: SeverityInfo, Type: "process_restarted"}, false},
+		{EventLogConfig{MinSeverity: SeverityInfo}, Event{Severity: SeverityInfo, Type: "circuit_closed"}, true},
+		{EventLogConfig{MinSeverity: SeverityCritical}, Event{Severity: SeverityWarning, Type: "health_check_failed"}, false},
+	}
+	for i, tt := range tests {
+		if got := newEventLogFilter(tt.config).match(tt.event); got != tt.want {
+			t.Errorf("%d: match(%s %s) = %v, want %v", i, tt.event.Severity, tt.event.Type, got, tt.want)
+		}
+	}
+}
+
+func TestEventLogMessage(t *testing.T) {
+	e := Event{
+		Severity: SeverityWarning,
+		Type:     "restart_failed",
+		Process:  "api_server.exe",
+		Message:  "Failed to restart process api_server.exe: access denied",
+		Details:  map[string]string{"reason": "port 8080 not in use", "error": "access denied"},
+	}
+	want := "Failed to restart process api_server.exe: access denied\r\n\r\n" +
+		"type: restart_failed\r\n" +
+		"process: api_server.exe\r\n" +
+		"error: access denied\r\n" +
+		"reason: port 8080 not in use\r\n"
+	if got := eventLogMessage(e); got != want {
+		t.Errorf("message =\n%q\nwant\n%q", got, want)
+	}
+
+	e.Snapshot = &EnvSnapshot{Memory: snapshotMemory{TotalMB: 8192, AvailableMB: 1024, UsedPercent: 87.5}}
+	if got := eventLogMessage(e); !strings.Contains(got, "\r\nMemory: 88% used") {
+		t.Errorf("snapshot missing from message:\n%s", got)
+	}
+
+	if eventLogID("restart_failed") != 102 || eventLogID("something_new") != defaultEventLogID {
+		t.Errorf("unexpected event IDs")
+	}
+	for eventType, id := range eventLogIDs {
+		if id < 1 || id > 1000 {
+			t.Errorf("event ID %d of %s is outside the EventCreate range 1-1000", id, eventType)
+		}
+	}
+}
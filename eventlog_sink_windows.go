@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventLogSink writes monitor events to the Application event log
+type eventLogSink struct {
+	filter eventLogFilter
+
+	mu  sync.Mutex
+	log *eventlog.Log
+}
+
+func newEventLogSink(config EventLogConfig) (EventSink, error) {
+	source := config.Source
+	if source == "" {
+		source = defaultEventLogSource
+	}
+	// 注册事件源需要管理员权限；未注册时事件仍会写入，但事件查看器会提示找不到描述
+	if err := registerEventSource(source); err != nil {
+		logrus.Warnf("Failed to register event source %s (run install-service as administrator to register it): %v", source, err)
+	}
+	log, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Writing monitor events to the Application event log as %s", source)
+	return &eventLogSink{filter: newEventLogFilter(config), log: log}, nil
+}
+
+// registerEventSource registers source in the Application log using the
+// generic EventCreate.exe message file; an existing registration is kept
+func registerEventSource(source string) error {
+	err := eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil && strings.Contains(err.Error(), "registry key already exists") {
+		return nil
+	}
+	return err
+}
+
+func (s *eventLogSink) HandleEvent(e Event) {
+	if !s.filter.match(e) {
+		return
+	}
+	id, msg := eventLogID(e.Type), eventLogMessage(e)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	switch e.Severity {
+	case SeverityCritical:
+		err = s.log.Error(id, msg)
+	case SeverityWarning:
+		err = s.log.Warning(id, msg)
+	default:
+		err = s.log.Info(id, msg)
+	}
+	if err != nil {
+		// 不通过 emitEvent 报告，避免写入失败产生新的事件
+		logrus.Debugf("Failed to write event %s to the event log: %v", e.Type, err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 事件级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Event is a notable occurrence in the monitor, delivered to every event sink
+type Event struct {
+	ID            uint64            `json:"id,omitempty"` // 由事件存储分配，用于分页
+	Time          time.Time         `json:"time"`
+	Severity      string            `json:"severity"`
+	Type          string            `json:"type"` // 如 monitor_panic
+	Process       string            `json:"process,omitempty"`
+	Message       string            `json:"message"`
+	CorrelationID string            `json:"correlation_id,omitempty"` // 进程当前启动/重启周期的关联ID（子进程环境变量 PM_CORRELATION_ID）
+	Details       map[string]string `json:"details,omitempty"`
+	Snapshot      *EnvSnapshot      `json:"snapshot,omitempty"`  // 故障时的主机环境快照（failure_snapshot）
+	HostInfo      *HostInfo         `json:"host_info,omitempty"` // 产生事件的主机（host 配置）
+}
+
+// severityRank orders event severities for min_severity filters
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// EventSink receives monitor events (notifications, webhooks, event log ...).
+// HandleEvent must not block for long; slow sinks should queue internally.
+type EventSink interface {
+	HandleEvent(e Event)
+}
+
+var (
+	eventSinksMu sync.RWMutex
+	eventSinks   []EventSink
+)
+
+// registerEventSink adds a sink that receives every emitted event
+func registerEventSink(sink EventSink) {
+	eventSinksMu.Lock()
+	defer eventSinksMu.Unlock()
+	eventSinks = append(eventSinks, sink)
+}
+
+// emitEvent logs e and delivers it to all registered sinks
+func emitEvent(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.HostInfo == nil {
+		host := currentHost()
+		e.HostInfo = &host
+	}
+	if e.CorrelationID == "" && e.Process != "" {
+		e.CorrelationID = correlationID(e.Process)
+	}
+	entry := logrus.WithField("event", e.Type)
+	if e.Process != "" {
+		entry = entry.WithField("process", e.Process)
+	}
+	if e.CorrelationID != "" {
+		entry = entry.WithField("correlation_id", e.CorrelationID)
+	}
+	switch e.Severity {
+	case SeverityCritical:
+		entry.Error(e.Message)
+	case SeverityWarning:
+		entry.Warn(e.Message)
+	default:
+		entry.Info(e.Message)
+	}
+
+	eventSinksMu.RLock()
+	defer eventSinksMu.RUnlock()
+	for _, sink := range eventSinks {
+		sink.HandleEvent(e)
+	}
+}
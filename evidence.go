@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SecurityConfig 安全模式：检测到篡改时不做恢复，只生成签名的证据记录并转发到 SIEM
+type SecurityConfig struct {
+	ReportOnly   bool   `yaml:"report_only"`   // 是否启用只报告模式（禁止自动修复）
+	SIEMEndpoint string `yaml:"siem_endpoint"` // 证据记录以 JSON POST 到该地址（为空则只保存在本地）
+	KeyFile      string `yaml:"key_file"`      // Ed25519 签名私钥文件（默认 evidence.key，不存在时自动生成）
+	SpoolDir     string `yaml:"spool_dir"`     // 本地证据目录（默认 evidence）
+}
+
+// EvidenceRecord is a signed description of one tamper detection
+type EvidenceRecord struct {
+	ID         string      `json:"id"`
+	Host       string      `json:"host"`
+	Source     string      `json:"source"` // registry, file, autostart
+	Target     string      `json:"target"` // 如 HKLM\SOFTWARE\...\ValueName
+	Before     interface{} `json:"before"` // 期望值或上一次的值
+	After      interface{} `json:"after"`  // 检测到的值，已删除时为 null
+	BeforeHash string      `json:"before_hash"`
+	AfterHash  string      `json:"after_hash"`
+	DetectedAt time.Time   `json:"detected_at"`
+	Action     string      `json:"action"` // 只报告模式下为 reported
+	KeyID      string      `json:"key_id"` // 公钥的 SHA-256 前16位十六进制
+	PublicKey  string      `json:"public_key"`
+	Signature  string      `json:"signature,omitempty"` // 对去掉 signature 字段的记录 JSON 的 Ed25519 签名（base64）
+}
+
+// evidenceReporter signs, stores and forwards evidence records
+type evidenceReporter struct {
+	config SecurityConfig
+	key    ed25519.PrivateKey
+	host   string
+	mu     sync.Mutex
+}
+
+// evidence is nil unless security.report_only is enabled
+var evidence *evidenceReporter
+
+// reportOnlyMode reports whether automatic remediation is forbidden
+func reportOnlyMode() bool {
+	return evidence != nil
+}
+
+func newEvidenceReporter(config SecurityConfig) (*evidenceReporter, error) {
+	if config.KeyFile == "" {
+		config.KeyFile = "evidence.key"
+	}
+	if config.SpoolDir == "" {
+		config.SpoolDir = "evidence"
+	}
+	key, err := loadEvidenceKey(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(config.SpoolDir, "sent"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create evidence directory: %v", err)
+	}
+	host := currentHost().Hostname
+	return &evidenceReporter{config: config, key: key, host: host}, nil
+}
+
+// loadEvidenceKey reads the hex-encoded Ed25519 seed, generating one if missing
+func loadEvidenceKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid evidence key file %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read evidence key: %v", err)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, fmt.Errorf("failed to generate evidence key: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(seed)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write evidence key: %v", err)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	logrus.Infof("Generated evidence signing key %s (public key: %s)", path,
+		base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	return key, nil
+}
+
+// hashValue returns the SHA-256 of the JSON encoding of v
+func hashValue(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign fills in the key fields and the signature of record
+func (e *evidenceReporter) sign(record *EvidenceRecord) {
+	pub := e.key.Public().(ed25519.PublicKey)
+	keySum := sha256.Sum256(pub)
+	record.KeyID = hex.EncodeToString(keySum[:8])
+	record.PublicKey = base64.StdEncoding.EncodeToString(pub)
+	record.Signature = ""
+	payload, _ := json.Marshal(record)
+	record.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(e.key, payload))
+}
+
+// verifyEvidence checks the signature of a record against its embedded public key
+func verifyEvidence(record EvidenceRecord) bool {
+	pub, err := base64.StdEncoding.DecodeString(record.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(record.Signature)
+	if err != nil {
+		return false
+	}
+	record.Signature = ""
+	payload, _ := json.Marshal(record)
+	return ed25519.Verify(ed25519.PublicKey(pub), payload, sig)
+}
+
+// Report records a tamper detection: the signed record is written to the
+// spool directory first and forwarded to the SIEM by runEvidenceForwarder.
+func (e *evidenceReporter) Report(source, target string, before, after interface{}) {
+	now := time.Now()
+	record := EvidenceRecord{
+		ID:         fmt.Sprintf("%s-%d", source, now.UnixNano()),
+		Host:       e.host,
+		Source:     source,
+		Target:     target,
+		Before:     before,
+		After:      after,
+		BeforeHash: hashValue(before),
+		AfterHash:  hashValue(after),
+		DetectedAt: now,
+		Action:     "reported",
+	}
+	e.sign(&record)
+
+	data, _ := json.MarshalIndent(record, "", "  ")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	path := filepath.Join(e.config.SpoolDir, record.ID+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		logrus.Errorf("Failed to write evidence record %s: %v", path, err)
+		return
+	}
+	logrus.Warnf("Tamper detected on %s %s, evidence recorded in %s (report-only mode, not corrected)", source, target, path)
+}
+
+// runEvidenceForwarder sends spooled evidence records to the SIEM endpoint,
+// moving delivered records to spool/sent. Undelivered records are retried.
+func (e *evidenceReporter) runEvidenceForwarder(ctx context.Context) {
+	if e.config.SIEMEndpoint == "" {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	for {
+		select {
+		case <-ticker.C:
+			entries, err := os.ReadDir(e.config.SpoolDir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+					continue
+				}
+				path := filepath.Join(e.config.SpoolDir, entry.Name())
+				data, err := os.ReadFile(path)
+				if err != nil {
+					continue
+				}
+				resp, err := client.Post(e.config.SIEMEndpoint, "application/json", bytes.NewReader(data))
+				if err != nil {
+					logrus.Debugf("Failed to forward evidence %s: %v", entry.Name(), err)
+					break // SIEM 不可达，下一轮再试
+				}
+				resp.Body.Close()
+				if resp.StatusCode < 200 || resp.StatusCode > 299 {
+					logrus.Warnf("SIEM rejected evidence %s: HTTP %d", entry.Name(), resp.StatusCode)
+					continue
+				}
+				os.Rename(path, filepath.Join(e.config.SpoolDir, "sent", entry.Name()))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvidenceSignature(t *testing.T) {
+	dir := t.TempDir()
+	reporter, err := newEvidenceReporter(SecurityConfig{
+		KeyFile:  filepath.Join(dir, "evidence.key"),
+		SpoolDir: filepath.Join(dir, "spool"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter.Report("registry", `HKLM\SOFTWARE\App\Mode`, uint32(1), uint32(0))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "spool", "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 evidence record, found %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	var record EvidenceRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if !verifyEvidence(record) {
+		t.Fatal("signature of a freshly written record does not verify")
+	}
+
+	record.After = float64(1)
+	if verifyEvidence(record) {
+		t.Error("signature still verifies after the record was modified")
+	}
+
+	// 重新加载应使用同一把密钥
+	again, err := newEvidenceReporter(SecurityConfig{
+		KeyFile:  filepath.Join(dir, "evidence.key"),
+		SpoolDir: filepath.Join(dir, "spool"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.key.Equal(reporter.key) {
+		t.Error("reloaded key differs from generated key")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// exitCode returns the exit code of a reaped child, or -1 if it was ended
+// by a signal or its state is unknown
+func exitCode(cmd *exec.Cmd) int {
+	if cmd == nil || cmd.ProcessState == nil {
+		return -1
+	}
+	return cmd.ProcessState.ExitCode()
+}
+
+// restartOnExitCode reports whether a child that exited with code should be
+// restarted. Codes in restart_on_exit_codes always restart, so a crash code
+// wins over ignore_exit_codes; ending by a signal (-1) counts as a crash
+// unless -1 is ignored explicitly.
+func restartOnExitCode(config ProcessConfig, code int) bool {
+	if containsInt(config.RestartOnExitCodes, code) {
+		return true
+	}
+	if containsInt(config.IgnoreExitCodes, code) {
+		return false
+	}
+	return len(config.RestartOnExitCodes) == 0 || code == -1
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// exitedIntentionally handles a child that exited with a code that does
+// not call for a restart: it stays down until started manually
+func (s *ProcessSupervisor) exitedIntentionally(code int) {
+	config := s.config
+	pid := s.currentCmd.Process.Pid
+	logrus.Infof("Process %s exited with code %d, which does not trigger a restart", config.Name, code)
+	s.stopped = true
+	s.currentCmd = nil
+	s.closeStdin()
+	s.untrack()
+	s.updateStatus(func(st *ProcessStatus) {
+		st.State = StateExited
+		st.PID = 0
+	})
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "process_stopped",
+		Process:  config.Name,
+		Message:  fmt.Sprintf("Process %s (PID: %d) exited with code %d and will not be restarted", config.Name, pid, code),
+		Details: map[string]string{
+			"reason":    "exit code " + strconv.Itoa(code),
+			"pid":       strconv.Itoa(pid),
+			"exit_code": strconv.Itoa(code),
+		},
+	})
+}
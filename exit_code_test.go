@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestRestartOnExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		restart []int
+		ignore  []int
+		code    int
+		want    bool
+	}{
+		{"no policy", nil, nil, 0, true},
+		{"ignored code", nil, []int{0, 75}, 75, false},
+		{"other code", nil, []int{0, 75}, 1, true},
+		{"signal is a crash", nil, []int{0}, -1, true},
+		{"ignored signal", nil, []int{-1}, -1, false},
+		{"only crash codes", []int{1, 2}, nil, 0, false},
+		{"crash code", []int{1, 2}, nil, 2, true},
+		{"crash code wins over ignore", []int{1}, []int{1}, 1, true},
+		{"signal with crash codes", []int{1}, nil, -1, true},
+	}
+	for _, tt := range tests {
+		config := ProcessConfig{RestartOnExitCodes: tt.restart, IgnoreExitCodes: tt.ignore}
+		if got := restartOnExitCode(config, tt.code); got != tt.want {
+			t.Errorf("%s: restartOnExitCode(%d) = %v, want %v", tt.name, tt.code, got, tt.want)
+		}
+	}
+	if code := exitCode(nil); code != -1 {
+		t.Errorf("exitCode(nil) = %d, want -1", code)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFileBaselineDir   = "file_baselines"
+	defaultFileCheckInterval = 30
+)
+
+// 文件相对基线的变化
+const (
+	fileChangeContent     = "content"
+	fileChangePermissions = "permissions"
+	fileChangeDeleted     = "deleted"
+)
+
+// FileMonitor 监控配置文件等文件的内容（SHA-256）、权限和是否被删除，与注册表监控对应
+type FileMonitor struct {
+	Name            string          `yaml:"name"`              // 监控名称
+	Path            string          `yaml:"path"`              // 要监控的文件
+	CheckInterval   int             `yaml:"check_interval"`    // 检查间隔（秒，默认30）
+	Baseline        string          `yaml:"baseline"`          // 基线副本文件（默认 file_baselines/<name>，首次启动时从当前文件创建）
+	Restore         bool            `yaml:"restore"`           // 内容、权限变化或文件被删除时从基线副本还原
+	ExecuteOnChange bool            `yaml:"execute_on_change"` // 变化时是否执行命令
+	Command         string          `yaml:"command"`           // 变化时执行的命令
+	Args            []string        `yaml:"args"`              // 命令参数
+	WorkDir         string          `yaml:"work_dir"`          // 工作目录
+	Enforce         EnforcementGate `yaml:"enforce"`           // 何时还原（时间段/标记文件），与注册表监控相同
+}
+
+// fileState is what the monitor observes of a file
+type fileState struct {
+	Exists bool        `json:"exists"`
+	Hash   string      `json:"sha256,omitempty"`
+	Mode   os.FileMode `json:"mode,omitempty"`
+}
+
+// changes lists how s differs from the baseline
+func (s fileState) changes(baseline fileState) []string {
+	if !s.Exists {
+		return []string{fileChangeDeleted}
+	}
+	var changes []string
+	if s.Hash != baseline.Hash {
+		changes = append(changes, fileChangeContent)
+	}
+	if s.Mode != baseline.Mode {
+		changes = append(changes, fileChangePermissions)
+	}
+	return changes
+}
+
+// fileBaseline 基线的元数据，保存在基线副本旁的 .json 文件中
+type fileBaseline struct {
+	Path  string    `json:"path"`
+	Taken time.Time `json:"taken"`
+	State fileState `json:"state"`
+}
+
+// baselineFile returns where the baseline copy of the monitored file is kept
+func (f FileMonitor) baselineFile() string {
+	if f.Baseline != "" {
+		return f.Baseline
+	}
+	return filepath.Join(defaultFileBaselineDir, safeFileName(f.Name))
+}
+
+// observeFile hashes path and reads its permissions
+func observeFile(path string) (fileState, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fileState{}, err
+	}
+	if info.IsDir() {
+		return fileState{}, fmt.Errorf("%s is a directory", path)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return fileState{}, err
+	}
+	return fileState{Exists: true, Hash: hex.EncodeToString(h.Sum(nil)), Mode: info.Mode().Perm()}, nil
+}
+
+// loadFileBaseline reads the baseline of config. A baseline taken from
+// another path is ignored, so changing path in the config starts over.
+func loadFileBaseline(config FileMonitor) (*fileBaseline, error) {
+	data, err := os.ReadFile(config.baselineFile() + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var baseline fileBaseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid file baseline %s.json: %v", config.baselineFile(), err)
+	}
+	if baseline.Path != config.Path {
+		return nil, os.ErrNotExist
+	}
+	// 副本丢失或被修改时无法还原，重新创建基线
+	copied, err := observeFile(config.baselineFile())
+	if err != nil {
+		return nil, err
+	}
+	if !copied.Exists || copied.Hash != baseline.State.Hash {
+		return nil, fmt.Errorf("baseline copy %s does not match its recorded hash", config.baselineFile())
+	}
+	return &baseline, nil
+}
+
+// takeFileBaseline copies the monitored file and records its hash and permissions
+func takeFileBaseline(config FileMonitor) (*fileBaseline, error) {
+	data, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	baseline := &fileBaseline{
+		Path:  config.Path,
+		Taken: time.Now(),
+		State: fileState{Exists: true, Hash: hex.EncodeToString(sum[:]), Mode: info.Mode().Perm()},
+	}
+	meta, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	copyPath := config.baselineFile()
+	if err := os.MkdirAll(filepath.Dir(copyPath), 0755); err != nil {
+		return nil, err
+	}
+	// 先写副本再写元数据，中途失败时副本与记录的哈希不符，下次启动会重新创建
+	if err := writeFileAtomic(copyPath, data); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(copyPath+".json", meta); err != nil {
+		return nil, err
+	}
+	return baseline, nil
+}
+
+// writeFileAtomic replaces path through a temporary file, so a crash never
+// leaves it truncated
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// restoreMonitoredFile writes the baseline copy back over the monitored file. The
+// file is rewritten in place rather than replaced, so its owner and ACL are kept.
+func restoreMonitoredFile(config FileMonitor, baseline *fileBaseline) error {
+	data, err := os.ReadFile(config.baselineFile())
+	if err != nil {
+		return fmt.Errorf("failed to read baseline copy: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(config.Path, data, baseline.State.Mode); err != nil {
+		return err
+	}
+	return os.Chmod(config.Path, baseline.State.Mode)
+}
+
+// runFileChangeCommand 在后台执行配置的变更命令，通过环境变量传递变化的内容
+func runFileChangeCommand(config FileMonitor, changes []string, restored bool) {
+	logrus.Infof("Executing command due to file change: %s %v", config.Command, config.Args)
+	cmd := exec.Command(config.Command, config.Args...)
+	if config.WorkDir != "" {
+		cmd.Dir = config.WorkDir
+	}
+	cmd.Env = append(os.Environ(),
+		"FILE_PATH="+config.Path,
+		"FILE_CHANGES="+strings.Join(changes, ","),
+		fmt.Sprintf("FILE_RESTORED=%t", restored),
+	)
+	if err := cmd.Start(); err != nil {
+		logrus.Errorf("Failed to execute command: %v", err)
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			logrus.Errorf("Command execution failed: %v", err)
+		}
+	}()
+}
+
+// fileMonitor holds the state of one running file monitor
+type fileMonitor struct {
+	config   FileMonitor
+	baseline *fileBaseline
+	last     fileState // 上一次检查看到的状态，同一偏差只报告一次
+	now      func() time.Time
+}
+
+// check compares the file with its baseline, reporting a new deviation and
+// restoring the baseline when enforcement allows it
+func (m *fileMonitor) check() {
+	config := m.config
+	current, err := observeFile(config.Path)
+	if err != nil {
+		logrus.Errorf("File monitor %s: failed to read %s: %v", config.Name, config.Path, err)
+		return
+	}
+	if m.baseline == nil {
+		// 启动时文件不存在，出现后以它为基线
+		if !current.Exists {
+			return
+		}
+		baseline, err := takeFileBaseline(config)
+		if err != nil {
+			logrus.Errorf("File monitor %s: failed to create baseline of %s: %v", config.Name, config.Path, err)
+			return
+		}
+		logrus.Infof("File %s was created, baseline taken (sha256 %s)", config.Path, baseline.State.Hash)
+		m.baseline, m.last = baseline, baseline.State
+		return
+	}
+
+	changes := current.changes(m.baseline.State)
+	if len(changes) == 0 {
+		m.last = current
+		return
+	}
+	isNew := current != m.last
+	m.last = current
+
+	enforce, suspendReason := config.Enforce.Active(m.now())
+	restored := false
+	if config.Restore && enforce {
+		if err := restoreMonitoredFile(config, m.baseline); err != nil {
+			logrus.Errorf("Failed to restore %s from its baseline: %v", config.Path, err)
+		} else {
+			restored = true
+			m.last = m.baseline.State
+		}
+	}
+	if !isNew {
+		// 已报告过的偏差，只有在恢复执行后被还原时才记录
+		if restored {
+			m.reportRestored(changes)
+		}
+		return
+	}
+
+	details := map[string]string{
+		"monitor":  config.Name,
+		"path":     config.Path,
+		"changes":  strings.Join(changes, ","),
+		"baseline": m.baseline.State.Hash,
+		"restored": fmt.Sprintf("%t", restored),
+	}
+	if current.Exists {
+		details["sha256"] = current.Hash
+		details["mode"] = current.Mode.String()
+	}
+	logrus.Warnf("File %s changed: %s", config.Path, strings.Join(changes, ", "))
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "file_changed",
+		Message:  fmt.Sprintf("File %s changed: %s", config.Path, strings.Join(changes, ", ")),
+		Details:  details,
+	})
+	if evidence != nil {
+		var after interface{}
+		if current.Exists {
+			after = current
+		}
+		evidence.Report("file", config.Path, m.baseline.State, after)
+	}
+	if config.Restore && !enforce {
+		logrus.Warnf("File restore for %s suspended: %s", config.Name, suspendReason)
+	}
+	if restored {
+		m.reportRestored(changes)
+	}
+	if config.ExecuteOnChange && config.Command != "" {
+		runFileChangeCommand(config, changes, restored)
+	}
+}
+
+func (m *fileMonitor) reportRestored(changes []string) {
+	logrus.Infof("Restored %s from its baseline", m.config.Path)
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "file_restored",
+		Message:  fmt.Sprintf("File %s was restored from its baseline (%s)", m.config.Path, strings.Join(changes, ", ")),
+		Details: map[string]string{
+			"monitor": m.config.Name,
+			"path":    m.config.Path,
+			"changes": strings.Join(changes, ","),
+		},
+	})
+}
+
+// newFileMonitor loads the saved baseline of config, or takes one from the
+// current file. A missing file is not an error: its baseline is taken once
+// it is created.
+func newFileMonitor(config FileMonitor) (*fileMonitor, error) {
+	m := &fileMonitor{config: config, now: time.Now}
+	baseline, err := loadFileBaseline(config)
+	if err == nil {
+		logrus.Infof("File monitor %s: using baseline taken %s", config.Name, baseline.Taken.Format(time.RFC3339))
+	} else {
+		if !os.IsNotExist(err) {
+			logrus.Warnf("File monitor %s: %v, taking a new baseline", config.Name, err)
+		}
+		baseline, err = takeFileBaseline(config)
+		if os.IsNotExist(err) {
+			logrus.Warnf("File %s does not exist, waiting for it to be created", config.Path)
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create baseline of %s: %v", config.Path, err)
+		}
+		logrus.Infof("File monitor %s: baseline of %s taken (sha256 %s)", config.Name, config.Path, baseline.State.Hash)
+	}
+	m.baseline, m.last = baseline, baseline.State
+	return m, nil
+}
+
+// MonitorFile 监控文件的内容、权限和是否被删除，直到 ctx 取消
+func MonitorFile(config FileMonitor, ctx context.Context) {
+	logrus.Infof("Starting file monitor %s for %s", config.Name, config.Path)
+	m, err := newFileMonitor(config)
+	if err != nil {
+		logrus.Errorf("File monitor %s: %v", config.Name, err)
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(defaultInt(config.CheckInterval, defaultFileCheckInterval)) * time.Second)
+	defer ticker.Stop()
+	for {
+		// 基线早于本次启动时，停止期间的修改在第一次检查时发现
+		m.check()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logrus.Infof("Stopping file monitor %s", config.Name)
+			return
+		}
+	}
+}
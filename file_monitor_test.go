@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fileEvents returns the types of the events of one file monitor
+func fileEvents(sink *recordingSink, monitor string) []string {
+	var types []string
+	for _, e := range sink.events {
+		if e.Details["monitor"] == monitor {
+			types = append(types, e.Type+":"+e.Details["changes"])
+		}
+	}
+	return types
+}
+
+func TestFileMonitorRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.conf")
+	os.WriteFile(path, []byte("listen=8080\n"), 0640)
+	config := FileMonitor{Name: "app-conf-restore", Path: path, Baseline: filepath.Join(dir, "baseline", "app.conf"), Restore: true}
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	m, err := newFileMonitor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.check()
+	if events := fileEvents(sink, config.Name); len(events) != 0 {
+		t.Fatalf("unchanged file reported: %v", events)
+	}
+
+	os.WriteFile(path, []byte("listen=9090\n"), 0640)
+	m.check()
+	if data, _ := os.ReadFile(path); string(data) != "listen=8080\n" {
+		t.Errorf("content not restored: %q", data)
+	}
+
+	os.Remove(path)
+	m.check()
+	if data, _ := os.ReadFile(path); string(data) != "listen=8080\n" {
+		t.Errorf("deleted file not restored: %q", data)
+	}
+
+	want := []string{"file_changed:content", "file_restored:content", "file_changed:deleted", "file_restored:deleted"}
+	if runtime.GOOS != "windows" {
+		// Windows 上权限只反映只读属性
+		os.Chmod(path, 0666)
+		m.check()
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+			t.Errorf("permissions not restored: %v", info.Mode().Perm())
+		}
+		want = append(want, "file_changed:permissions", "file_restored:permissions")
+	}
+	events := fileEvents(sink, config.Name)
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events = %v, want %v", events, want)
+			break
+		}
+	}
+}
+
+func TestFileMonitorReportsDriftOnce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.conf")
+	os.WriteFile(path, []byte("a"), 0644)
+	config := FileMonitor{Name: "app-conf-report", Path: path, Baseline: filepath.Join(dir, "app.conf.baseline")}
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	m, err := newFileMonitor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("b"), 0644)
+	m.check()
+	m.check()
+	os.WriteFile(path, []byte("c"), 0644)
+	m.check()
+	if events := fileEvents(sink, config.Name); len(events) != 2 {
+		t.Errorf("expected one event per distinct change, got %v", events)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "c" {
+		t.Errorf("file restored although restore is off: %q", data)
+	}
+
+	// 基线在重新启动后保留：停止期间的修改在第一次检查时发现
+	m, err = newFileMonitor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.check()
+	if events := fileEvents(sink, config.Name); len(events) != 3 {
+		t.Errorf("change made while stopped not reported: %v", events)
+	}
+
+	// 监控的路径改变后重新创建基线
+	config.Path = filepath.Join(dir, "other.conf")
+	os.WriteFile(config.Path, []byte("x"), 0644)
+	m, err = newFileMonitor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.baseline == nil || m.baseline.Path != config.Path {
+		t.Errorf("baseline of the old path reused: %+v", m.baseline)
+	}
+}
+
+func TestFileMonitorWaitsForMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	config := FileMonitor{Name: "late", Path: filepath.Join(dir, "late.conf"), Baseline: filepath.Join(dir, "late.baseline")}
+	m, err := newFileMonitor(config)
+	if err != nil || m.baseline != nil {
+		t.Fatalf("newFileMonitor = %+v, %v", m, err)
+	}
+	m.check()
+	os.WriteFile(config.Path, []byte("x"), 0644)
+	m.check()
+	if m.baseline == nil {
+		t.Fatal("baseline not taken once the file was created")
+	}
+	if _, err := loadFileBaseline(config); err != nil {
+		t.Errorf("baseline not saved: %v", err)
+	}
+}
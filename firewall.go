@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// firewallCommandTimeout netsh / nft 命令的超时
+const firewallCommandTimeout = 30 * time.Second
+
+// FirewallConfig 进程 open_firewall 时创建放行规则的方式
+type FirewallConfig struct {
+	NftTable string `yaml:"nft_table"` // Linux：添加规则的 nftables 表（默认 "inet filter"）
+	NftChain string `yaml:"nft_chain"` // Linux：添加规则的链（默认 input）
+}
+
+// firewallSettings is set from the config at startup
+var firewallSettings FirewallConfig
+
+// firewallRuleName returns the name (Windows) or comment (nftables) that
+// identifies the rules of a process
+func firewallRuleName(process string) string {
+	return "ProcessMonitor " + process
+}
+
+// firewallBackend creates and removes the allow rules of one process
+type firewallBackend interface {
+	// open creates the rule unless it already exists. program restricts the
+	// rule to one executable where the firewall supports it.
+	open(name string, ports []int, program string) error
+	close(name string) error
+}
+
+// firewallRunner runs a firewall command and returns its combined output
+type firewallRunner func(name string, args ...string) (string, error)
+
+func runFirewallCommand(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), firewallCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// firewall is replaced in tests
+var firewall firewallBackend = newFirewallBackend(runFirewallCommand)
+
+// openFirewall creates the allow rule for the ports of the process once
+// after it has been started
+func (s *ProcessSupervisor) openFirewall() {
+	config := s.config
+	if !config.OpenFirewall || len(config.Ports) == 0 || s.firewallOpen {
+		return
+	}
+	program := ""
+	if path, err := executablePath(config); err == nil {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			program = path
+		}
+	}
+	if err := firewall.open(firewallRuleName(config.Name), config.Ports, program); err != nil {
+		logrus.Errorf("Failed to open firewall ports %v for %s: %v", config.Ports, config.Name, err)
+		return
+	}
+	s.firewallOpen = true
+}
+
+// closeFirewall removes the allow rule when the monitor stops the process
+func (s *ProcessSupervisor) closeFirewall() {
+	config := s.config
+	if !config.OpenFirewall || len(config.Ports) == 0 {
+		return
+	}
+	// 监控程序上次异常退出时留下的规则也在这里删除
+	if err := firewall.close(firewallRuleName(config.Name)); err != nil {
+		logrus.Errorf("Failed to remove firewall rule for %s: %v", config.Name, err)
+		return
+	}
+	s.firewallOpen = false
+}
@@ -0,0 +1,101 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nftFirewall adds accept rules to an existing nftables chain. The rules
+// are identified by their comment and removed by handle.
+type nftFirewall struct {
+	run firewallRunner
+}
+
+func newFirewallBackend(run firewallRunner) firewallBackend {
+	return nftFirewall{run: run}
+}
+
+// chain returns the family, table and chain arguments of the nft commands
+func (f nftFirewall) chain() []string {
+	table := firewallSettings.NftTable
+	if table == "" {
+		table = "inet filter"
+	}
+	chain := firewallSettings.NftChain
+	if chain == "" {
+		chain = "input"
+	}
+	return append(strings.Fields(table), chain)
+}
+
+var nftHandlePattern = regexp.MustCompile(`# handle (\d+)\s*$`)
+
+// nftRuleHandles returns the handles of the rules in an "nft -a list chain"
+// listing that carry comment
+func nftRuleHandles(listing, comment string) []string {
+	var handles []string
+	quoted := fmt.Sprintf("comment %q", comment)
+	for _, line := range strings.Split(listing, "\n") {
+		if !strings.Contains(line, quoted) {
+			continue
+		}
+		if m := nftHandlePattern.FindStringSubmatch(line); m != nil {
+			handles = append(handles, m[1])
+		}
+	}
+	return handles
+}
+
+func (f nftFirewall) handles(name string) ([]string, error) {
+	out, err := f.run("nft", append([]string{"-a", "list", "chain"}, f.chain()...)...)
+	if err != nil {
+		return nil, err
+	}
+	return nftRuleHandles(out, name), nil
+}
+
+// open adds the rule; program is ignored because nftables matches packets,
+// not executables
+func (f nftFirewall) open(name string, ports []int, program string) error {
+	existing, err := f.handles(name)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+	list := make([]string, len(ports))
+	for i, port := range ports {
+		list[i] = strconv.Itoa(port)
+	}
+	args := append([]string{"add", "rule"}, f.chain()...)
+	args = append(args, "tcp", "dport", "{", strings.Join(list, ", "), "}", "accept", "comment", strconv.Quote(name))
+	if _, err := f.run("nft", args...); err != nil {
+		return err
+	}
+	logrus.Infof("Added nftables rule %q for TCP ports %s", name, strings.Join(list, ","))
+	return nil
+}
+
+func (f nftFirewall) close(name string) error {
+	existing, err := f.handles(name)
+	if err != nil {
+		return err
+	}
+	for _, handle := range existing {
+		args := append([]string{"delete", "rule"}, f.chain()...)
+		if _, err := f.run("nft", append(args, "handle", handle)...); err != nil {
+			return fmt.Errorf("failed to delete rule %q: %v", name, err)
+		}
+	}
+	if len(existing) > 0 {
+		logrus.Infof("Removed nftables rule %q", name)
+	}
+	return nil
+}
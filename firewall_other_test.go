@@ -0,0 +1,65 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nftListing = `table inet filter {
+	chain input { # handle 1
+		type filter hook input priority filter; policy drop;
+		ct state established,related accept # handle 4
+		tcp dport { 80, 443 } accept comment "ProcessMonitor web.exe" # handle 7
+		tcp dport 8080 accept comment "ProcessMonitor web.exe.old" # handle 8
+		tcp dport 80 accept comment "ProcessMonitor web.exe" # handle 9
+	}
+}
+`
+
+func TestNftRuleHandles(t *testing.T) {
+	if got := nftRuleHandles(nftListing, "ProcessMonitor web.exe"); !reflect.DeepEqual(got, []string{"7", "9"}) {
+		t.Errorf("handles = %v, want [7 9]", got)
+	}
+	if got := nftRuleHandles(nftListing, "ProcessMonitor api.exe"); len(got) != 0 {
+		t.Errorf("handles of a missing rule = %v", got)
+	}
+}
+
+func TestNftFirewall(t *testing.T) {
+	listing := ""
+	var calls []string
+	f := nftFirewall{run: func(name string, args ...string) (string, error) {
+		call := name + " " + strings.Join(args, " ")
+		calls = append(calls, call)
+		if strings.HasPrefix(call, "nft -a list chain") {
+			return listing, nil
+		}
+		return "", nil
+	}}
+
+	if err := f.open("ProcessMonitor web.exe", []int{80, 443}, "/opt/web/web"); err != nil {
+		t.Fatal(err)
+	}
+	want := `nft add rule inet filter input tcp dport { 80, 443 } accept comment "ProcessMonitor web.exe"`
+	if len(calls) != 2 || calls[1] != want {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+
+	// 规则已存在时不重复添加，删除时按 handle 删除
+	listing = nftListing
+	calls = nil
+	if err := f.open("ProcessMonitor web.exe", []int{80, 443}, ""); err != nil || len(calls) != 1 {
+		t.Fatalf("open with an existing rule: err = %v, calls = %q", err, calls)
+	}
+	calls = nil
+	if err := f.close("ProcessMonitor web.exe"); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(calls[1:]) != "[nft delete rule inet filter input handle 7 nft delete rule inet filter input handle 9]" {
+		t.Errorf("delete calls = %q", calls)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+type fakeFirewall struct {
+	opened, closed []string
+	fail           bool
+}
+
+func (f *fakeFirewall) open(name string, ports []int, program string) error {
+	if f.fail {
+		return fmt.Errorf("access denied")
+	}
+	f.opened = append(f.opened, fmt.Sprintf("%s %v", name, ports))
+	return nil
+}
+
+func (f *fakeFirewall) close(name string) error {
+	f.closed = append(f.closed, name)
+	return nil
+}
+
+func TestSupervisorFirewall(t *testing.T) {
+	fake := &fakeFirewall{}
+	saved := firewall
+	firewall = fake
+	defer func() { firewall = saved }()
+
+	s := NewProcessSupervisor(ProcessConfig{Name: "web.exe", Ports: []int{80, 443}, OpenFirewall: true})
+	s.openFirewall()
+	s.openFirewall()
+	if len(fake.opened) != 1 || fake.opened[0] != "ProcessMonitor web.exe [80 443]" {
+		t.Fatalf("opened = %q, want one rule", fake.opened)
+	}
+	s.closeFirewall()
+	if len(fake.closed) != 1 || s.firewallOpen {
+		t.Fatalf("closed = %q, firewallOpen = %v", fake.closed, s.firewallOpen)
+	}
+
+	// 创建失败时下次启动再试
+	fake.fail = true
+	s.openFirewall()
+	fake.fail = false
+	s.openFirewall()
+	if len(fake.opened) != 2 {
+		t.Errorf("rule not retried after a failure: %q", fake.opened)
+	}
+
+	off := NewProcessSupervisor(ProcessConfig{Name: "worker.exe", Ports: []int{8080}})
+	off.openFirewall()
+	off.closeFirewall()
+	if len(fake.opened) != 2 || len(fake.closed) != 1 {
+		t.Errorf("rules changed for a process without open_firewall")
+	}
+}
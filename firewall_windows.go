@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// netshFirewall manages Windows Firewall rules with netsh advfirewall
+type netshFirewall struct {
+	run firewallRunner
+}
+
+func newFirewallBackend(run firewallRunner) firewallBackend {
+	return netshFirewall{run: run}
+}
+
+func (f netshFirewall) exists(name string) bool {
+	// 没有该规则时 netsh 返回非0退出码
+	_, err := f.run("netsh", "advfirewall", "firewall", "show", "rule", "name="+name)
+	return err == nil
+}
+
+func (f netshFirewall) open(name string, ports []int, program string) error {
+	if f.exists(name) {
+		return nil
+	}
+	list := make([]string, len(ports))
+	for i, port := range ports {
+		list[i] = strconv.Itoa(port)
+	}
+	args := []string{"advfirewall", "firewall", "add", "rule", "name=" + name,
+		"dir=in", "action=allow", "protocol=TCP", "localport=" + strings.Join(list, ",")}
+	if program != "" {
+		args = append(args, "program="+program)
+	}
+	if _, err := f.run("netsh", args...); err != nil {
+		return err
+	}
+	logrus.Infof("Added Windows Firewall rule %q for TCP ports %s", name, strings.Join(list, ","))
+	return nil
+}
+
+func (f netshFirewall) close(name string) error {
+	if !f.exists(name) {
+		return nil
+	}
+	if _, err := f.run("netsh", "advfirewall", "firewall", "delete", "rule", "name="+name); err != nil {
+		return fmt.Errorf("failed to delete rule %q: %v", name, err)
+	}
+	logrus.Infof("Removed Windows Firewall rule %q", name)
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// isConnRefused reports whether err is a refused TCP connection
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// defaultGateway returns the IPv4 default gateway from /proc/net/route
+func defaultGateway() (string, error) {
+	data, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return "", fmt.Errorf("failed to read routing table: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		fields := strings.Fields(line)
+		// Iface Destination Gateway Flags ...
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		ip, err := parseHexIPv4(fields[2])
+		if err != nil || ip.IsUnspecified() {
+			continue
+		}
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("no default gateway found")
+}
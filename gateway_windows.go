@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// x/sys/windows 未定义该标志
+const gaaFlagIncludeGateways = 0x80
+
+// isConnRefused reports whether err is a refused TCP connection
+func isConnRefused(err error) bool {
+	return errors.Is(err, windows.WSAECONNREFUSED)
+}
+
+// defaultGateway returns the first IPv4 gateway of an adapter that is up
+func defaultGateway() (string, error) {
+	size := uint32(15 * 1024)
+	for i := 0; i < 3; i++ {
+		buf := make([]byte, size)
+		addrs := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_INET, gaaFlagIncludeGateways, 0, addrs, &size)
+		if err == windows.ERROR_BUFFER_OVERFLOW {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("GetAdaptersAddresses failed: %v", err)
+		}
+		for a := addrs; a != nil; a = a.Next {
+			if a.OperStatus != windows.IfOperStatusUp {
+				continue
+			}
+			for g := a.FirstGatewayAddress; g != nil; g = g.Next {
+				if ip := g.Address.IP(); ip != nil && !ip.IsUnspecified() {
+					return ip.String(), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("no default gateway found")
+	}
+	return "", fmt.Errorf("GetAdaptersAddresses: buffer too small")
+}
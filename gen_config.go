@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// inventoryColumns 清单表格支持的列；name 和 path 至少需要一个
+var inventoryColumns = map[string]bool{
+	"name":           true, // 服务名称，写入标签 app 和注释
+	"path":           true, // 程序路径（为空时使用 name）
+	"args":           true, // 启动参数，空格分隔
+	"work_dir":       true,
+	"ports":          true, // 端口，用 ; | 或空格分隔
+	"health_url":     true, // 健康检查地址，多个用 ; 分隔
+	"check_interval": true, // 检查间隔（秒，默认30）
+	"restart_delay":  true, // 重启延迟（秒，默认5）
+}
+
+// generatedProcess is the subset of ProcessConfig written by gen-config
+type generatedProcess struct {
+	Name          string            `yaml:"name"`
+	Args          []string          `yaml:"args,omitempty"`
+	WorkDir       string            `yaml:"work_dir,omitempty"`
+	Ports         []int             `yaml:"ports,omitempty"`
+	HealthChecks  []string          `yaml:"health_checks,omitempty"`
+	CheckInterval int               `yaml:"check_interval"`
+	RestartDelay  int               `yaml:"restart_delay"`
+	Labels        map[string]string `yaml:"labels,omitempty"`
+}
+
+// runGenConfigCommand implements
+// "processmonitor gen-config -from inventory.csv [-o config.yaml] [-force]"
+func runGenConfigCommand(args []string) error {
+	fs := flag.NewFlagSet("gen-config", flag.ContinueOnError)
+	from := fs.String("from", "", "inventory CSV file")
+	output := fs.String("o", "", "output config file (default: standard output)")
+	force := fs.Bool("force", false, "overwrite the output file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("usage: processmonitor gen-config -from inventory.csv [-o config.yaml] [-force]")
+	}
+
+	f, err := os.Open(*from)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := generateConfig(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *from, err)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if !*force && fileExists(*output) {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", *output)
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "config written to %s\n", *output)
+	return nil
+}
+
+// generateConfig converts an inventory sheet into a config file and checks
+// it with the same validation as a normal config
+func generateConfig(r io.Reader) ([]byte, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// Excel 导出的 UTF-8 文件带 BOM；部分区域设置下使用分号分隔
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(raw))
+	if header, _, _ := bytes.Cut(raw, []byte("\n")); bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("no processes: expected a header row and at least one process")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !inventoryColumns[name] {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["path"]; !ok {
+		if _, ok := columns["name"]; !ok {
+			return nil, fmt.Errorf("the inventory needs a name or path column")
+		}
+	}
+
+	var doc yaml.Node
+	processes := &yaml.Node{Kind: yaml.SequenceNode}
+	for i, record := range records[1:] {
+		line := i + 2
+		cell := func(column string) string {
+			if idx, ok := columns[column]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+		p, err := inventoryProcess(cell)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		encoded, err := yaml.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(encoded, &node); err != nil {
+			return nil, err
+		}
+		entry := node.Content[0]
+		if name := cell("name"); name != "" {
+			entry.HeadComment = name
+		}
+		processes.Content = append(processes.Content, entry)
+	}
+	doc.Kind = yaml.DocumentNode
+	doc.HeadComment = "Generated by processmonitor gen-config"
+	doc.Content = []*yaml.Node{{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "processes"},
+			processes,
+		},
+	}}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	enc.Close()
+
+	// 与加载配置文件时相同的校验
+	var config Config
+	if err := yaml.Unmarshal(buf.Bytes(), &config); err != nil {
+		return nil, fmt.Errorf("generated config does not parse: %v", err)
+	}
+	applyConfigDefaults(&config)
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inventoryProcess builds the process of one inventory row
+func inventoryProcess(cell func(string) string) (generatedProcess, error) {
+	p := generatedProcess{
+		Name:          cell("path"),
+		Args:          strings.Fields(cell("args")),
+		WorkDir:       cell("work_dir"),
+		HealthChecks:  splitInventoryList(cell("health_url"), ";"),
+		CheckInterval: 30,
+		RestartDelay:  5,
+	}
+	if p.Name == "" {
+		p.Name = cell("name")
+	}
+	if p.Name == "" {
+		return p, fmt.Errorf("name and path are both empty")
+	}
+	if name := cell("name"); name != "" {
+		p.Labels = map[string]string{"app": name}
+	}
+	for _, field := range splitInventoryList(cell("ports"), ";|, ") {
+		port, err := strconv.Atoi(field)
+		if err != nil {
+			return p, fmt.Errorf("invalid port %q", field)
+		}
+		p.Ports = append(p.Ports, port)
+	}
+	for column, target := range map[string]*int{"check_interval": &p.CheckInterval, "restart_delay": &p.RestartDelay} {
+		if v := cell(column); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return p, fmt.Errorf("invalid %s %q", column, v)
+			}
+			*target = n
+		}
+	}
+	return p, nil
+}
+
+// splitInventoryList splits a cell on any of the separator characters
+func splitInventoryList(s, separators string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(separators, r) || unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateConfig(t *testing.T) {
+	inventory := "\xef\xbb\xbfname,path,ports,health_url,check_interval\n" +
+		"Order API,C:\\apps\\order\\order_api.exe,8080;8443,http://localhost:8080/health,15\n" +
+		"Legacy Batch,C:\\apps\\batch\\batch.exe,,,\n" +
+		",,,,\n"
+	data, err := generateConfig(strings.NewReader(inventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Processes) != 2 {
+		t.Fatalf("got %d processes, want 2:\n%s", len(config.Processes), data)
+	}
+	api := config.Processes[0]
+	if api.Name != `C:\apps\order\order_api.exe` || len(api.Ports) != 2 || api.Ports[1] != 8443 ||
+		len(api.HealthChecks) != 1 || api.CheckInterval != 15 || api.Labels["app"] != "Order API" {
+		t.Errorf("unexpected process: %+v", api)
+	}
+	if batch := config.Processes[1]; batch.CheckInterval != 30 || len(batch.Ports) != 0 {
+		t.Errorf("unexpected defaults: %+v", batch)
+	}
+	if !strings.Contains(string(data), "# Legacy Batch") {
+		t.Errorf("generated config should name each process in a comment:\n%s", data)
+	}
+}
+
+func TestGenerateConfigErrors(t *testing.T) {
+	tests := []struct {
+		inventory string
+		want      string
+	}{
+		{"name;path;ports\nweb;web.exe;80\n", ""}, // 分号分隔
+		{"name,path,ports\nweb,web.exe,http\n", "line 2: invalid port"},
+		{"name,path,owner\nweb,web.exe,ops\n", `unknown column "owner"`},
+		{"name,path,health_url\nweb,web.exe,localhost/health\n", "http://"},
+		{"name,path\nweb,web.exe\nweb2,web.exe\n", "defined more than once"},
+	}
+	for _, tt := range tests {
+		_, err := generateConfig(strings.NewReader(tt.inventory))
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%q: err = %v, want %q", tt.inventory, err, tt.want)
+		}
+	}
+}
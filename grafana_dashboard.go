@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// grafanaPanel is the subset of the Grafana panel model written by
+// grafana-dashboard; rows are panels of type "row"
+type grafanaPanel struct {
+	ID          int             `json:"id"`
+	Type        string          `json:"type"`
+	Title       string          `json:"title"`
+	GridPos     grafanaGridPos  `json:"gridPos"`
+	Datasource  *grafanaRef     `json:"datasource,omitempty"`
+	Targets     []grafanaTarget `json:"targets,omitempty"`
+	FieldConfig map[string]any  `json:"fieldConfig,omitempty"`
+	Options     map[string]any  `json:"options,omitempty"`
+	Repeat      string          `json:"repeat,omitempty"`
+	Collapsed   bool            `json:"collapsed"`
+	Panels      []grafanaPanel  `json:"panels,omitempty"`
+}
+
+type grafanaGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type grafanaRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+}
+
+// 所有面板使用的 Prometheus 数据源（模板变量，导入时选择）
+var grafanaDatasource = &grafanaRef{Type: "prometheus", UID: "${datasource}"}
+
+// dashboardBuilder lays panels out on Grafana's 24-column grid
+type dashboardBuilder struct {
+	panels []grafanaPanel
+	y      int
+	x      int
+	rowH   int
+}
+
+func (b *dashboardBuilder) row(title, repeat string) {
+	b.newLine()
+	b.add(grafanaPanel{Type: "row", Title: title, Repeat: repeat}, 24, 1)
+	b.newLine()
+}
+
+func (b *dashboardBuilder) newLine() {
+	if b.x > 0 {
+		b.y += b.rowH
+		b.x, b.rowH = 0, 0
+	}
+}
+
+func (b *dashboardBuilder) add(p grafanaPanel, w, h int) {
+	if b.x+w > 24 {
+		b.newLine()
+	}
+	p.ID = len(b.panels) + 1
+	p.GridPos = grafanaGridPos{X: b.x, Y: b.y, W: w, H: h}
+	if p.Type != "row" {
+		p.Datasource = grafanaDatasource
+	}
+	b.panels = append(b.panels, p)
+	b.x += w
+	if h > b.rowH {
+		b.rowH = h
+	}
+}
+
+func (b *dashboardBuilder) stat(title, expr, unit string, w int, mappings ...map[string]any) {
+	defaults := map[string]any{"unit": unit}
+	if len(mappings) > 0 {
+		defaults["mappings"] = mappings
+	}
+	b.add(grafanaPanel{
+		Type:        "stat",
+		Title:       title,
+		Targets:     []grafanaTarget{{RefID: "A", Expr: expr}},
+		FieldConfig: map[string]any{"defaults": defaults},
+		Options:     map[string]any{"reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}}, "colorMode": "background"},
+	}, w, 4)
+}
+
+func (b *dashboardBuilder) timeseries(title, unit string, w int, targets ...grafanaTarget) {
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	b.add(grafanaPanel{
+		Type:        "timeseries",
+		Title:       title,
+		Targets:     targets,
+		FieldConfig: map[string]any{"defaults": map[string]any{"unit": unit}},
+	}, w, 8)
+}
+
+// promSelector returns the exported name of an internal metric with the
+// dashboard's label filter
+func promSelector(name, suffix, filter string) string {
+	return promName(name, suffix) + "{" + filter + "}"
+}
+
+// upMapping shows process_up as UP/DOWN in green/red
+var upMapping = map[string]any{
+	"type": "value",
+	"options": map[string]any{
+		"0": map[string]any{"text": "DOWN", "color": "red"},
+		"1": map[string]any{"text": "UP", "color": "green"},
+	},
+}
+
+// grafanaDashboard builds a dashboard for the metrics of /metrics: an
+// overview row, one repeated row per process and restart annotations
+func grafanaDashboard(title, uid string) map[string]any {
+	all := `instance=~"$instance"`
+	one := `instance=~"$instance",process="$process"`
+	latency := promName("check.latency", "_seconds") // summary：_sum 和 _count
+	b := &dashboardBuilder{}
+
+	b.row("Overview", "")
+	b.stat("Processes up", "sum("+promSelector("process.up", "", all)+")", "none", 6)
+	b.stat("Processes down", "count("+promSelector("process.up", "", all)+" == 0) or vector(0)", "none", 6)
+	b.stat("Restarts (24h)", "sum(increase("+promSelector("restarts", "_total", all)+"[24h]))", "none", 6)
+	b.stat("Monitor uptime", "min("+promSelector("monitor.uptime", "", all)+")", "s", 6)
+	b.timeseries("Restarts by process", "none", 24, grafanaTarget{
+		Expr:         "sum by (process) (increase(" + promSelector("restarts", "_total", all) + "[$__rate_interval]))",
+		LegendFormat: "{{process}}",
+	})
+
+	b.row("$process", "process")
+	b.stat("Status", "max("+promSelector("process.up", "", one)+")", "none", 4, upMapping)
+	b.stat("Restarts (24h)", "sum(increase("+promSelector("restarts", "_total", one)+"[24h]))", "none", 4)
+	b.timeseries("Health check latency", "s", 8, grafanaTarget{
+		Expr: "rate(" + latency + "_sum{" + one + "}[$__rate_interval]) / rate(" +
+			latency + "_count{" + one + "}[$__rate_interval])",
+		LegendFormat: "{{instance}}",
+	})
+	b.timeseries("Check failures", "none", 8,
+		grafanaTarget{Expr: "increase(" + promSelector("check.failures", "_total", one) + "[$__rate_interval])", LegendFormat: "health check"},
+		grafanaTarget{Expr: "increase(" + promSelector("port_check.failures", "_total", one) + "[$__rate_interval])", LegendFormat: "port check"},
+	)
+	// CPU 和内存只在启用 statsd 时采集
+	b.timeseries("CPU", "percent", 12, grafanaTarget{Expr: promSelector("process.cpu_percent", "", one), LegendFormat: "{{instance}}"})
+	b.timeseries("Memory", "decmbytes", 12, grafanaTarget{Expr: promSelector("process.memory_mb", "", one), LegendFormat: "{{instance}}"})
+
+	uptime := promName("monitor.uptime", "")
+	up := promName("process.up", "")
+	return map[string]any{
+		"uid":           uid,
+		"title":         title,
+		"tags":          []string{"processmonitor"},
+		"timezone":      "browser",
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]any{"from": "now-24h", "to": "now"},
+		"panels":        b.panels,
+		"templating": map[string]any{"list": []map[string]any{
+			{"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus"},
+			{
+				"name": "instance", "label": "Host", "type": "query", "datasource": grafanaDatasource,
+				"query": "label_values(" + uptime + ", instance)", "refresh": 2,
+				"multi": true, "includeAll": true, "current": map[string]any{"text": "All", "value": "$__all"},
+			},
+			{
+				"name": "process", "label": "Process", "type": "query", "datasource": grafanaDatasource,
+				"query": `label_values(` + up + `{instance=~"$instance"}, process)`, "refresh": 2,
+				"multi": true, "includeAll": true, "current": map[string]any{"text": "All", "value": "$__all"},
+			},
+		}},
+		"annotations": map[string]any{"list": []map[string]any{
+			{
+				"name":        "Restarts",
+				"datasource":  grafanaDatasource,
+				"enable":      true,
+				"iconColor":   "orange",
+				"expr":        "increase(" + promSelector("restarts", "_total", `instance=~"$instance",process=~"$process"`) + "[2m]) > 0",
+				"step":        "60s",
+				"titleFormat": "Restart",
+				"textFormat":  "{{process}} on {{instance}}",
+				"tagKeys":     "process,instance",
+			},
+		}},
+	}
+}
+
+// runGrafanaDashboardCommand implements
+// "processmonitor grafana-dashboard [-o dashboard.json] [-title name] [-uid uid] [-force]"
+func runGrafanaDashboardCommand(args []string) error {
+	fs := flag.NewFlagSet("grafana-dashboard", flag.ContinueOnError)
+	output := fs.String("o", "", "output file (default: standard output)")
+	title := fs.String("title", "ProcessMonitor", "dashboard title")
+	uid := fs.String("uid", "processmonitor", "dashboard UID")
+	force := fs.Bool("force", false, "overwrite the output file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// PromQL 中的 > 和 =~ 保持原样，不转义为 \u003e
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(grafanaDashboard(*title, *uid)); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if *output == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if !*force && fileExists(*output) {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", *output)
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "dashboard written to %s, import it in Grafana under Dashboards > New > Import\n", *output)
+	return nil
+}
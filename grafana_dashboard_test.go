@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrafanaDashboardMatchesMetrics(t *testing.T) {
+	// 导出与面板使用的相同指标
+	r := newPromRegistry()
+	tags := processTags("api.exe")
+	r.Count("restarts", 1, tags)
+	r.Count("check.failures", 1, tags)
+	r.Count("port_check.failures", 1, tags)
+	r.Timing("check.latency", time.Second, tags)
+	r.Gauge("process.up", 1, tags)
+	r.Gauge("process.cpu_percent", 12, tags)
+	r.Gauge("process.memory_mb", 300, tags)
+	r.Gauge("monitor.uptime", 60, nil)
+	var exported bytes.Buffer
+	r.writeText(&exported)
+
+	data, err := json.Marshal(grafanaDashboard("ProcessMonitor", "pm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dashboard struct {
+		Panels      []grafanaPanel `json:"panels"`
+		Annotations struct {
+			List []struct {
+				Expr string `json:"expr"`
+			} `json:"list"`
+		} `json:"annotations"`
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+
+	var exprs []string
+	repeated := false
+	for _, p := range dashboard.Panels {
+		if p.Type == "row" && p.Repeat == "process" {
+			repeated = true
+		}
+		for _, target := range p.Targets {
+			exprs = append(exprs, target.Expr)
+		}
+	}
+	if !repeated {
+		t.Error("no row repeated per process")
+	}
+	if len(dashboard.Annotations.List) != 1 || !strings.Contains(dashboard.Annotations.List[0].Expr, "restart_total") {
+		t.Errorf("restart annotation = %+v", dashboard.Annotations.List)
+	}
+	exprs = append(exprs, dashboard.Annotations.List[0].Expr)
+
+	metric := regexp.MustCompile(`([a-z_]+)\{`)
+	for _, expr := range exprs {
+		for _, m := range metric.FindAllStringSubmatch(expr, -1) {
+			if text := exported.String(); !strings.Contains(text, "\n"+m[1]+"{") && !strings.Contains(text, "\n"+m[1]+" ") {
+				t.Errorf("%s is not exported (in %s)", m[1], expr)
+			}
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GroupConfig 定义一个命名进程组
+type GroupConfig struct {
+	Name     string            `yaml:"name"`     // 组名，如 web-tier
+	Members  []string          `yaml:"members"`  // 显式列出的进程名
+	Selector map[string]string `yaml:"selector"` // 按标签选择进程（所有标签都匹配才算成员）
+}
+
+// groupMembers resolves the process names belonging to a group.
+// A group matches explicit members plus every process whose labels match the selector.
+func groupMembers(group GroupConfig, processes []ProcessConfig) []string {
+	var members []string
+	seen := make(map[string]bool)
+
+	explicit := make(map[string]bool)
+	for _, name := range group.Members {
+		explicit[name] = true
+	}
+
+	for _, p := range processes {
+		if seen[p.Name] {
+			continue
+		}
+		if explicit[p.Name] || labelsMatch(p.Labels, group.Selector) {
+			members = append(members, p.Name)
+			seen[p.Name] = true
+		}
+	}
+	return members
+}
+
+// labelsMatch reports whether labels contain every key/value in selector
+func labelsMatch(labels, selector map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyOrder sorts members so that every process comes after the
+// members it depends on. Dependencies outside the set are ignored.
+func dependencyOrder(members []string, processes []ProcessConfig) ([]string, error) {
+	inSet := make(map[string]bool)
+	for _, name := range members {
+		inSet[name] = true
+	}
+
+	deps := make(map[string][]string)
+	for _, p := range processes {
+		if !inSet[p.Name] {
+			continue
+		}
+		for _, d := range p.DependsOn {
+			if inSet[d] {
+				deps[p.Name] = append(deps[p.Name], d)
+			}
+		}
+	}
+
+	var order []string
+	state := make(map[string]int) // 0=未访问 1=访问中 2=已完成
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, d := range deps[name] {
+			if err := visit(d, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// findGroup looks up a group by name; "all" is an implicit group of every process
+func (m *ProcessManager) findGroup(name string) (GroupConfig, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, g := range m.groups {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	if name == "all" {
+		return GroupConfig{Name: "all", Members: append([]string(nil), m.order...)}, true
+	}
+	return GroupConfig{}, false
+}
+
+func (m *ProcessManager) processConfigs() []ProcessConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	configs := make([]ProcessConfig, 0, len(m.order))
+	for _, name := range m.order {
+		configs = append(configs, m.configs[name])
+	}
+	return configs
+}
+
+// groupStep is one start, stop or restart of a group member
+type groupStep struct {
+	Action  string
+	Process string
+}
+
+func (s groupStep) String() string {
+	return s.Action + " " + s.Process
+}
+
+// groupOrder returns the enabled members of a group in dependency order
+func (m *ProcessManager) groupOrder(groupName string) ([]string, error) {
+	group, ok := m.findGroup(groupName)
+	if !ok {
+		return nil, fmt.Errorf("unknown group: %s", groupName)
+	}
+	configs := m.processConfigs()
+	members := groupMembers(group, configs)
+	if len(members) == 0 {
+		return nil, fmt.Errorf("group %s has no enabled members", groupName)
+	}
+	return dependencyOrder(members, configs)
+}
+
+// groupPlan returns the steps of start/stop/restart over a group: stop runs
+// in reverse dependency order; restart stops everything first, then starts
+// in order
+func (m *ProcessManager) groupPlan(groupName, action string) ([]groupStep, error) {
+	switch action {
+	case "start", "stop", "restart":
+	default:
+		return nil, fmt.Errorf("unknown group action: %s", action)
+	}
+	order, err := m.groupOrder(groupName)
+	if err != nil {
+		return nil, err
+	}
+	var steps []groupStep
+	if action != "start" {
+		for i := len(order) - 1; i >= 0; i-- {
+			steps = append(steps, groupStep{"stop", order[i]})
+		}
+	}
+	if action != "stop" {
+		for _, name := range order {
+			steps = append(steps, groupStep{"start", name})
+		}
+	}
+	return steps, nil
+}
+
+// runGroupStep sends one step of a group action to its supervisor
+func (m *ProcessManager) runGroupStep(ctx context.Context, step groupStep, reason string) error {
+	s, ok := m.Get(step.Process)
+	if !ok {
+		return fmt.Errorf("unknown process: %s", step.Process)
+	}
+	if err := s.Send(ctx, step.Action, reason); err != nil {
+		return fmt.Errorf("failed to %s %s: %v", step.Action, step.Process, err)
+	}
+	return nil
+}
+
+// GroupAction runs start/stop/restart over a group in dependency order.
+// Stop runs in reverse order; restart stops everything first, then starts in order.
+func (m *ProcessManager) GroupAction(ctx context.Context, groupName, action string) error {
+	steps, err := m.groupPlan(groupName, action)
+	if err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("group %s %s", groupName, action)
+	logrus.Infof("Running %s on group %s: %v", action, groupName, steps)
+	for _, step := range steps {
+		if err := m.runGroupStep(ctx, step, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleGroups serves POST /groups/{name}/{start|stop|restart}; with
+// ?async=1 the action runs as a job and the request returns at once
+func (s *APIServer) handleGroups(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/groups/"), "/"), "/")
+	if len(parts) != 2 || r.Method != http.MethodPost {
+		http.Error(w, "usage: POST /groups/{name}/{start|stop|restart}", http.StatusBadRequest)
+		return
+	}
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		req := JobRequest{Operation: "group_" + parts[1], Group: parts[0]}
+		target, steps, err := s.jobSteps(req, r.RemoteAddr)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		s.writeJobAccepted(w, s.jobs.start(req.Operation, target, steps))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
+	defer cancel()
+	if err := s.manager.GroupAction(ctx, parts[0], parts[1]); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"result": "ok"})
+}
+
+// runGroupCommand implements "processmonitor group <start|stop|restart> <name>"
+// by asking the running monitor over its HTTP API.
+func runGroupCommand(config Config, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: processmonitor group <start|stop|restart> <group>")
+	}
+	if config.API.Listen == "" {
+		return fmt.Errorf("group commands require api.listen to be configured")
+	}
+
+	resp, err := postAPI(config.API, "/groups/"+args[1]+"/"+args[0], "application/json", nil, 10*time.Minute)
+	if err != nil {
+		return fmt.Errorf("failed to contact monitor: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("group %s %s failed: %s", args[0], args[1], strings.TrimSpace(string(body)))
+	}
+	fmt.Printf("group %s %s: done\n", args[0], args[1])
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupMembers(t *testing.T) {
+	processes := []ProcessConfig{
+		{Name: "db", Labels: map[string]string{"tier": "data"}},
+		{Name: "api", Labels: map[string]string{"tier": "web"}},
+		{Name: "frontend", Labels: map[string]string{"tier": "web"}},
+		{Name: "worker"},
+	}
+
+	tests := []struct {
+		name  string
+		group GroupConfig
+		want  []string
+	}{
+		{"selector", GroupConfig{Selector: map[string]string{"tier": "web"}}, []string{"api", "frontend"}},
+		{"explicit", GroupConfig{Members: []string{"worker", "db"}}, []string{"db", "worker"}},
+		{"explicit and selector", GroupConfig{Members: []string{"db"}, Selector: map[string]string{"tier": "web"}}, []string{"db", "api", "frontend"}},
+		{"no match", GroupConfig{Selector: map[string]string{"tier": "cache"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupMembers(tt.group, processes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groupMembers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	processes := []ProcessConfig{
+		{Name: "frontend", DependsOn: []string{"api"}},
+		{Name: "api", DependsOn: []string{"db", "cache"}},
+		{Name: "db"},
+		{Name: "cache"},
+	}
+
+	got, err := dependencyOrder([]string{"frontend", "api", "db"}, processes)
+	if err != nil {
+		t.Fatalf("dependencyOrder() error = %v", err)
+	}
+	want := []string{"db", "api", "frontend"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyOrder() = %v, want %v", got, want)
+	}
+
+	cyclic := []ProcessConfig{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+	}
+	if _, err := dependencyOrder([]string{"a", "b"}, cyclic); err == nil {
+		t.Errorf("dependencyOrder() expected cycle error")
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// grpc.health.v1 HealthCheckResponse.ServingStatus
+var grpcServingStatus = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+const grpcServing = 1
+
+// 常见的 gRPC 状态码，用于错误信息
+var grpcStatusNames = map[string]string{
+	"1":  "CANCELLED",
+	"2":  "UNKNOWN",
+	"4":  "DEADLINE_EXCEEDED",
+	"5":  "NOT_FOUND",
+	"7":  "PERMISSION_DENIED",
+	"12": "UNIMPLEMENTED",
+	"13": "INTERNAL",
+	"14": "UNAVAILABLE",
+	"16": "UNAUTHENTICATED",
+}
+
+const maxGRPCResponse = 64 * 1024
+
+// grpcTLSConfig returns the TLS settings of a grpc check, or nil for plaintext (h2c)
+func (c HealthCheck) grpcTLSConfig() (*tls.Config, error) {
+	if !c.TLS {
+		return nil, nil
+	}
+	config, err := c.tlsClientConfig()
+	if config == nil && err == nil {
+		config = &tls.Config{}
+	}
+	return config, err
+}
+
+// runGRPC calls grpc.health.v1.Health/Check on every address of the check
+// until one reports SERVING
+func (c HealthCheck) runGRPC(config ProcessConfig, timeout time.Duration) error {
+	host, port, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := c.grpcTLSConfig()
+	if err != nil {
+		return err
+	}
+	// authority 同时用作 :authority 头和 TLS 证书校验的主机名
+	authority := c.Authority
+	if authority == "" {
+		authority = c.Address
+	}
+	return tryAddresses(config.Name, c, host, timeout, func(ctx context.Context, addr string) error {
+		return grpcHealthCheck(ctx, net.JoinHostPort(addr, port), authority, c.Service, tlsConfig)
+	})
+}
+
+// grpcHealthCheck performs one Health/Check call over HTTP/2 to dialAddr
+func grpcHealthCheck(ctx context.Context, dialAddr, authority, service string, tlsConfig *tls.Config) error {
+	scheme := "http"
+	transport := &http2.Transport{AllowHTTP: true}
+	if tlsConfig != nil {
+		scheme = "https"
+		transport.TLSClientConfig = tlsConfig
+	}
+	transport.DialTLS = func(network, _ string, cfg *tls.Config) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, dialAddr)
+		if err != nil || tlsConfig == nil {
+			return conn, err
+		}
+		// cfg 由 transport 根据 authority 设置 ServerName 和 ALPN（h2）
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, scheme+"://"+authority+"/grpc.health.v1.Health/Check",
+		bytes.NewReader(grpcFrame(encodeHealthCheckRequest(service))))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d (not a gRPC server?)", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGRPCResponse))
+	if err != nil {
+		return err
+	}
+
+	// 出错时服务器可能只返回头部（Trailers-Only），状态在头部中
+	code := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if code != "0" {
+		return grpcStatusError(code, message, service)
+	}
+
+	payload, err := grpcUnframe(body)
+	if err != nil {
+		return err
+	}
+	status, err := decodeHealthCheckResponse(payload)
+	if err != nil {
+		return err
+	}
+	if status != grpcServing {
+		name := grpcServingStatus[status]
+		if name == "" {
+			name = fmt.Sprintf("status %d", status)
+		}
+		if service == "" {
+			return fmt.Errorf("server is %s", name)
+		}
+		return fmt.Errorf("service %s is %s", service, name)
+	}
+	return nil
+}
+
+// grpcStatusError describes a failed gRPC call
+func grpcStatusError(code, message, service string) error {
+	if code == "" {
+		return fmt.Errorf("response has no grpc-status")
+	}
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	switch code {
+	case "12":
+		return fmt.Errorf("server does not implement grpc.health.v1.Health")
+	case "5":
+		return fmt.Errorf("health status of service %q is unknown to the server", service)
+	}
+	name := grpcStatusNames[code]
+	if name == "" {
+		name = "code " + code
+	}
+	if message != "" {
+		return fmt.Errorf("gRPC %s: %s", name, message)
+	}
+	return fmt.Errorf("gRPC %s", name)
+}
+
+// grpcFrame prefixes an uncompressed message with the gRPC length header
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// grpcUnframe returns the first message of a gRPC response body
+func grpcUnframe(body []byte) ([]byte, error) {
+	if len(body) < 5 {
+		return nil, fmt.Errorf("empty gRPC response")
+	}
+	if body[0] != 0 {
+		return nil, fmt.Errorf("compressed gRPC responses are not supported")
+	}
+	n := binary.BigEndian.Uint32(body[1:5])
+	if uint32(len(body)-5) < n {
+		return nil, fmt.Errorf("truncated gRPC response")
+	}
+	return body[5 : 5+n], nil
+}
+
+// encodeHealthCheckRequest encodes HealthCheckRequest{service}; field 1 is
+// left out for the empty string (the whole server), as protobuf does
+func encodeHealthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	msg := []byte{0x0a}
+	msg = binary.AppendUvarint(msg, uint64(len(service)))
+	return append(msg, service...)
+}
+
+// decodeHealthCheckResponse reads the status (field 1) of a
+// HealthCheckResponse, skipping fields added by newer servers
+func decodeHealthCheckResponse(msg []byte) (uint64, error) {
+	var status uint64
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid HealthCheckResponse")
+		}
+		msg = msg[n:]
+		switch key & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, fmt.Errorf("invalid HealthCheckResponse")
+			}
+			if key>>3 == 1 {
+				status = v
+			}
+			msg = msg[n:]
+		case 1: // 64-bit
+			if len(msg) < 8 {
+				return 0, fmt.Errorf("invalid HealthCheckResponse")
+			}
+			msg = msg[8:]
+		case 2: // length-delimited
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return 0, fmt.Errorf("invalid HealthCheckResponse")
+			}
+			msg = msg[n+int(l):]
+		case 5: // 32-bit
+			if len(msg) < 4 {
+				return 0, fmt.Errorf("invalid HealthCheckResponse")
+			}
+			msg = msg[4:]
+		default:
+			return 0, fmt.Errorf("invalid HealthCheckResponse")
+		}
+	}
+	return status, nil
+}
+
+// parseGRPCCheck parses "grpc:host:port[/service]" and "grpcs:..." (TLS)
+func parseGRPCCheck(s string, secure bool) HealthCheck {
+	check := HealthCheck{Type: CheckGRPC, Address: s, TLS: secure}
+	if i := strings.Index(s, "/"); i >= 0 {
+		check.Address, check.Service = s[:i], s[i+1:]
+	}
+	return check
+}
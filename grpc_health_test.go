@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// grpcHealthServer answers Health/Check with the status of the requested
+// service from statuses; unknown services get NOT_FOUND
+func grpcHealthServer(t *testing.T, statuses map[string]uint64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/grpc.health.v1.Health/Check" || r.Header.Get("Content-Type") != "application/grpc" {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "12")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		msg, err := grpcUnframe(body)
+		if err != nil {
+			t.Errorf("server: %v", err)
+			return
+		}
+		service := ""
+		if len(msg) > 2 {
+			service = string(msg[2:])
+		}
+		status, ok := statuses[service]
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		if !ok {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown%20service")
+			return
+		}
+		w.Write(grpcFrame([]byte{0x08, byte(status)}))
+		w.Header().Set("Grpc-Status", "0")
+	})
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	statuses := map[string]uint64{"": 1, "orders.v1": 1, "billing.v1": 2}
+	server := httptest.NewServer(h2c.NewHandler(grpcHealthServer(t, statuses), &http2.Server{}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		check string
+		err   string
+	}{
+		{"grpc:" + addr, ""},
+		{"grpc:" + addr + "/orders.v1", ""},
+		{"grpc:" + addr + "/billing.v1", "service billing.v1 is NOT_SERVING"},
+		{"grpc:" + addr + "/missing.v1", "unknown to the server"},
+	}
+	for _, tt := range tests {
+		check := parseHealthCheck(tt.check)
+		if err := check.validate(); err != nil {
+			t.Fatalf("%s: %v", tt.check, err)
+		}
+		err := check.run(ProcessConfig{Name: "orders"}, 0)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.check, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: err = %v, want %q", tt.check, err, tt.err)
+		}
+	}
+}
+
+func TestGRPCHealthCheckTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(grpcHealthServer(t, map[string]uint64{"": 1}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	_, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "https://"), ":")
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(ca, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// httptest 的证书对 example.com 有效：通过 authority 指定证书校验使用的主机名
+	check := HealthCheck{Type: CheckGRPC, Address: "127.0.0.1:" + port, TLS: true, CAFile: ca, Authority: "example.com"}
+	if err := check.run(ProcessConfig{Name: "orders"}, 0); err != nil {
+		t.Errorf("with ca_file: %v", err)
+	}
+	check.CAFile = ""
+	if err := check.run(ProcessConfig{Name: "orders"}, 0); err == nil {
+		t.Error("untrusted certificate was accepted")
+	}
+	check.InsecureSkipVerify = true
+	if err := check.run(ProcessConfig{Name: "orders"}, 0); err != nil {
+		t.Errorf("with insecure_skip_verify: %v", err)
+	}
+}
+
+func TestParseGRPCCheck(t *testing.T) {
+	tests := []struct {
+		in      string
+		address string
+		service string
+		tls     bool
+	}{
+		{"grpc:localhost:50051", "localhost:50051", "", false},
+		{"grpcs:api.local:443/orders.v1.Orders", "api.local:443", "orders.v1.Orders", true},
+	}
+	for _, tt := range tests {
+		c := parseHealthCheck(tt.in)
+		if c.Type != CheckGRPC || c.Address != tt.address || c.Service != tt.service || c.TLS != tt.tls {
+			t.Errorf("parseHealthCheck(%q) = %+v", tt.in, c)
+		}
+		if c.String() != tt.in {
+			t.Errorf("String() = %q, want %q", c.String(), tt.in)
+		}
+	}
+	if err := (HealthCheck{Type: CheckGRPC, Address: "localhost:1", CAFile: "ca.pem"}).validate(); err == nil {
+		t.Error("ca_file without tls was accepted")
+	}
+}
+
+func TestDecodeHealthCheckResponse(t *testing.T) {
+	// 未知字段（字段2字符串、字段3 fixed32）被跳过
+	msg := []byte{0x12, 0x02, 'o', 'k', 0x08, 0x02, 0x1d, 1, 2, 3, 4}
+	if status, err := decodeHealthCheckResponse(msg); err != nil || status != 2 {
+		t.Errorf("status = %d, %v, want 2", status, err)
+	}
+	if _, err := decodeHealthCheckResponse([]byte{0x12, 0x05, 'o'}); err == nil {
+		t.Error("truncated message was accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// guardRestartDelay 监控 goroutine 崩溃后重新启动前的等待时间，上限 guardMaxRestartDelay
+const (
+	guardRestartDelay    = 5 * time.Second
+	guardMaxRestartDelay = 5 * time.Minute
+)
+
+// runGuarded runs fn and restarts it when it panics, so one unexpected panic
+// (e.g. inside gopsutil) cannot silently end the monitoring of a process
+// while the daemon keeps running. It returns when fn returns normally or ctx
+// is cancelled. process names the managed process the goroutine belongs to.
+func runGuarded(ctx context.Context, name, process string, fn func(ctx context.Context)) {
+	delay := guardRestartDelay
+	for {
+		if !runRecovered(name, process, fn, ctx) {
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		logrus.Warnf("Restarting %s after panic", name)
+		// 连续崩溃时逐步延长等待，避免刷屏
+		delay *= 2
+		if delay > guardMaxRestartDelay {
+			delay = guardMaxRestartDelay
+		}
+	}
+}
+
+// runRecovered calls fn and reports whether it panicked
+func runRecovered(name, process string, fn func(ctx context.Context), ctx context.Context) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			stack := string(debug.Stack())
+			logrus.Errorf("Panic in %s: %v\n%s", name, r, stack)
+			internalErrors.record("panic", fmt.Sprintf("panic in %s: %v", name, r))
+			emitCount("monitor.panics", 1, map[string]string{"goroutine": name})
+			emitEvent(Event{
+				Severity: SeverityCritical,
+				Type:     "monitor_panic",
+				Process:  process,
+				Message:  fmt.Sprintf("%s panicked and will be restarted: %v", name, r),
+				Details:  map[string]string{"goroutine": name, "stack": stack},
+			})
+		}
+	}()
+	fn(ctx)
+	return false
+}
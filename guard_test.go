@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink records events; it stays registered for the rest of the
+// test run, so background goroutines of later tests may deliver to it
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recordingSink) HandleEvent(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func TestRunRecovered(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	panicked := runRecovered("test goroutine", "web.exe", func(ctx context.Context) {
+		var m map[string]int
+		m["boom"] = 1
+	}, context.Background())
+	if !panicked {
+		t.Fatal("panic was not reported")
+	}
+	if len(sink.events) != 1 || sink.events[0].Severity != SeverityCritical || sink.events[0].Process != "web.exe" {
+		t.Fatalf("unexpected events: %+v", sink.events)
+	}
+	if sink.events[0].Details["stack"] == "" {
+		t.Error("event is missing the stack trace")
+	}
+
+	if runRecovered("test goroutine", "", func(ctx context.Context) {}, context.Background()) {
+		t.Error("normal return reported as panic")
+	}
+}
+
+func TestRunGuardedStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	done := make(chan struct{})
+	go func() {
+		runGuarded(ctx, "test goroutine", "", func(ctx context.Context) {
+			runs++
+			panic("always")
+		})
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("runGuarded did not return after cancel")
+	}
+	if runs != 1 {
+		t.Errorf("expected 1 run before cancel, got %d", runs)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHealthCheckYAML(t *testing.T) {
+	data := `
+- "http://localhost:8080/health"
+- "tcp:localhost:3306"
+- "cmd:/usr/bin/pg_isready -q -h localhost"
+- "script:check_queue.sh | grep ok"
+- type: cmd
+  command: redis-cli
+  args: ["ping"]
+  timeout: 2
+- url: "http://localhost/status"
+- "ping:db.corp"
+- type: dns
+  address: db.corp
+  expect_ips: ["10.0.0.5", "10.0.1.0/24"]
+`
+	var checks []HealthCheck
+	if err := yaml.Unmarshal([]byte(data), &checks); err != nil {
+		t.Fatal(err)
+	}
+	want := []HealthCheck{
+		{Type: CheckHTTP, URL: "http://localhost:8080/health"},
+		{Type: CheckTCP, Address: "localhost:3306"},
+		{Type: CheckCmd, Command: "/usr/bin/pg_isready", Args: []string{"-q", "-h", "localhost"}},
+		{Type: CheckScript, Command: "check_queue.sh | grep ok"},
+		{Type: CheckCmd, Command: "redis-cli", Args: []string{"ping"}, Timeout: 2},
+		{Type: CheckHTTP, URL: "http://localhost/status"},
+		{Type: CheckPing, Address: "db.corp"},
+		{Type: CheckDNS, Address: "db.corp"},
+	}
+	if len(checks) != len(want) {
+		t.Fatalf("parsed %d checks, want %d", len(checks), len(want))
+	}
+	for i := range want {
+		if checks[i].String() != want[i].String() || checks[i].Type != want[i].Type || checks[i].Timeout != want[i].Timeout {
+			t.Errorf("check %d = %+v, want %+v", i, checks[i], want[i])
+		}
+		if err := checks[i].validate(); err != nil {
+			t.Errorf("check %d: %v", i, err)
+		}
+	}
+}
+
+func TestHealthCheckRun(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	open := ln.Addr().String()
+	gone, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := gone.Addr().String() // 监听已关闭，连接应被拒绝
+	gone.Close()
+
+	tests := []struct {
+		check   string
+		wantErr string // 为空表示检查应当通过
+	}{
+		{ok.URL, ""},
+		{failing.URL, "HTTP 503"},
+		{"tcp:" + open, ""},
+		{"tcp:" + closed, "refused"},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, []struct {
+			check   string
+			wantErr string
+		}{
+			{"cmd:true", ""},
+			{"cmd:false", "exit status 1"},
+			{"script:test \"$PM_PROCESS\" = db", ""},
+			{"script:echo not ready; exit 3", "not ready"},
+		}...)
+	}
+	config := ProcessConfig{Name: "db"}
+	for _, tt := range tests {
+		err := parseHealthCheck(tt.check).run(config, 0)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: err = %v, want %q", tt.check, err, tt.wantErr)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		slow := HealthCheck{Type: CheckScript, Command: "sleep 5", Timeout: 1}
+		if err := slow.run(config, 0); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("slow check: err = %v, want timeout", err)
+		}
+	}
+}
+
+func TestCheckHealthFailureThreshold(t *testing.T) {
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	defer srv.Close()
+	s := NewProcessSupervisor(ProcessConfig{Name: "api", FailureThreshold: 3, HealthChecks: []HealthCheck{parseHealthCheck(srv.URL)}})
+
+	// 每一步的检查结果和是否应当重启
+	steps := []struct {
+		healthy bool
+		restart bool
+	}{
+		{false, false},
+		{false, false},
+		{true, false}, // 成功后重新计数
+		{false, false},
+		{false, false},
+		{false, true},
+	}
+	for i, step := range steps {
+		healthy = step.healthy
+		restart, reason := s.checkEndpoints()
+		if restart != step.restart {
+			t.Errorf("step %d: restart = %v (%s), want %v", i, restart, reason, step.restart)
+		}
+	}
+}
+
+func TestUnhealthyHookBeforeFailureThreshold(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer srv.Close()
+	out := filepath.Join(t.TempDir(), "hook.txt")
+	s := NewProcessSupervisor(ProcessConfig{
+		Name:             "api",
+		FailureThreshold: 3,
+		HealthChecks:     []HealthCheck{parseHealthCheck(srv.URL)},
+		OnUnhealthy:      "echo unhealthy> " + out,
+	})
+
+	// 第一轮失败就执行 on_unhealthy，但未达到 failure_threshold 不重启
+	if restart, reason := s.checkEndpoints(); restart {
+		t.Fatalf("restart after 1/3 failures (%s)", reason)
+	}
+	if health := s.Status().Health; health != HealthUnhealthy {
+		t.Errorf("health = %q, want %q", health, HealthUnhealthy)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("on_unhealthy did not run: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "unhealthy" {
+		t.Errorf("hook output = %q", got)
+	}
+}
+
+func TestHealthCheckTriesEveryAddress(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "vip.example.test:"+r.URL.Query().Get("port") {
+			t.Errorf("Host header = %q", r.Host)
+		}
+	}))
+	defer healthy.Close()
+	_, port, _ := net.SplitHostPort(healthy.Listener.Addr().String())
+
+	defer func(orig func(context.Context, string) ([]string, error)) { lookupHost = orig }(lookupHost)
+	// 127.0.0.2 上没有服务，应接着尝试 127.0.0.1
+	addrs := []string{"127.0.0.2", "127.0.0.1"}
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host != "vip.example.test" {
+			return nil, fmt.Errorf("unexpected lookup of %s", host)
+		}
+		return addrs, nil
+	}
+
+	config := ProcessConfig{Name: "web.exe"}
+	checks := []HealthCheck{
+		{Type: CheckHTTP, URL: "http://vip.example.test:" + port + "/health?port=" + port, Timeout: 2},
+		{Type: CheckTCP, Address: "vip.example.test:" + port, Timeout: 2},
+	}
+	for _, check := range checks {
+		if err := check.run(config, 0); err != nil {
+			t.Errorf("%s failed although one address is healthy: %v", check, err)
+		}
+	}
+
+	addrs = []string{"127.0.0.2", "127.0.0.3"}
+	for _, check := range checks {
+		err := check.run(config, 0)
+		if err == nil || !strings.Contains(err.Error(), "all 2 addresses of vip.example.test failed") || !strings.Contains(err.Error(), "127.0.0.3: ") {
+			t.Errorf("%s: unexpected error %v", check, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 健康状态
+const (
+	HealthUnknown   = ""
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+const defaultHookTimeout = 30
+
+// runHook executes a hook command through the system shell and waits for it
+// (up to hook_timeout) so e.g. a load balancer is drained before a restart.
+// Details are passed in PM_* environment variables.
+func runHook(config ProcessConfig, event, command, reason string, pid int) error {
+	timeout := config.HookTimeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	cmd.Dir = config.WorkDir
+	cmd.Env = append(os.Environ(),
+		"PM_PROCESS="+config.Name,
+		"PM_EVENT="+event,
+		"PM_REASON="+reason,
+		"PM_PID="+strconv.Itoa(pid),
+		correlationEnv+"="+correlationID(config.Name),
+	)
+	// 超时结束 shell 后，不等待仍持有输出管道的孙进程
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s hook timed out after %ds", event, timeout)
+	}
+	if isExecError(err) {
+		internalErrors.record("exec", fmt.Sprintf("%s hook of %s cannot be executed: %v", event, config.Name, err))
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %v: %s", event, err, out)
+	}
+	logrus.Debugf("%s hook for %s output: %s", event, config.Name, out)
+	return nil
+}
+
+// setHealth records a health check result and fires on_healthy/on_unhealthy
+// when the health state changes. The first result after monitor start counts
+// as a change, so a load balancer weight is always brought in line.
+func (s *ProcessSupervisor) setHealth(healthy bool, reason string) {
+	health := HealthUnhealthy
+	command := s.config.OnUnhealthy
+	if healthy {
+		health = HealthHealthy
+		command = s.config.OnHealthy
+	}
+
+	previous := s.Status()
+	if previous.Health == health {
+		return
+	}
+	s.updateStatus(func(st *ProcessStatus) { st.Health = health })
+	logrus.Infof("Process %s health changed: %s -> %s", s.config.Name, healthOrUnknown(previous.Health), health)
+
+	if command == "" {
+		return
+	}
+	if err := runHook(s.config, "on_"+health, command, reason, previous.PID); err != nil {
+		logrus.Errorf("Process %s: %v", s.config.Name, err)
+	}
+}
+
+func healthOrUnknown(health string) string {
+	if health == HealthUnknown {
+		return "unknown"
+	}
+	return health
+}
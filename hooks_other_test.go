@@ -0,0 +1,40 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "env")
+	config := ProcessConfig{Name: "api", WorkDir: dir}
+	if err := runHook(config, "on_unhealthy", "echo $PM_PROCESS $PM_EVENT $PM_PID $PM_REASON > env", "port 80 not in use", 4242); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run in work_dir: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "api on_unhealthy 4242 port 80 not in use" {
+		t.Errorf("hook environment = %q", got)
+	}
+
+	if err := runHook(config, "on_healthy", "echo broken; exit 3", "", 0); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failing hook: err = %v, want output in error", err)
+	}
+
+	config.HookTimeout = 1
+	started := time.Now()
+	if err := runHook(config, "on_unhealthy", "sleep 5", "", 0); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("slow hook: err = %v, want timeout", err)
+	}
+	if time.Since(started) > 4*time.Second {
+		t.Error("hook_timeout did not end the hook")
+	}
+}
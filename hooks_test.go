@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHealthHookTransitions(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hooks.txt")
+	s := NewProcessSupervisor(ProcessConfig{
+		Name:        "api",
+		OnHealthy:   "echo healthy>> " + out,
+		OnUnhealthy: "echo unhealthy>> " + out,
+	})
+
+	// 第一次结果也是状态变化；状态不变时不重复执行钩子
+	steps := []struct {
+		healthy bool
+		want    string
+	}{
+		{true, HealthHealthy},
+		{true, HealthHealthy},
+		{false, HealthUnhealthy},
+		{false, HealthUnhealthy},
+		{true, HealthHealthy},
+	}
+	for i, step := range steps {
+		s.setHealth(step.healthy, "test")
+		if got := s.Status().Health; got != step.want {
+			t.Errorf("step %d: health = %q, want %q", i, got, step.want)
+		}
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fired := strings.Fields(string(data))
+	if want := []string{"healthy", "unhealthy", "healthy"}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("hooks fired %q, want %q", fired, want)
+	}
+}
+
+func TestHealthWithoutHooks(t *testing.T) {
+	s := NewProcessSupervisor(ProcessConfig{Name: "api"})
+	if s.Status().Health != HealthUnknown {
+		t.Fatalf("initial health = %q", s.Status().Health)
+	}
+	s.setHealth(false, "port 80 not in use")
+	if s.Status().Health != HealthUnhealthy {
+		t.Errorf("health = %q, want %q", s.Status().Health, HealthUnhealthy)
+	}
+	if healthOrUnknown(HealthUnknown) != "unknown" || healthOrUnknown(HealthHealthy) != HealthHealthy {
+		t.Error("healthOrUnknown")
+	}
+}
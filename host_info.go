@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// HostConfig 附加到所有事件、指标和通知中的主机信息，多台机器发送到同一渠道时用于区分来源
+type HostConfig struct {
+	Name string            `yaml:"name"` // 主机名（默认系统主机名）
+	IP   string            `yaml:"ip"`   // 上报的IP地址（默认第一个非回环的 IPv4 地址）
+	Tags map[string]string `yaml:"tags"` // 静态标签，如 site: sh01、rack: a3、customer: acme
+}
+
+// HostInfo describes the machine the monitor runs on
+type HostInfo struct {
+	Hostname  string            `json:"hostname"`
+	IP        string            `json:"ip,omitempty"`
+	OS        string            `json:"os"`
+	OSVersion string            `json:"os_version,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// hostTagName 标签名同时用作 Prometheus 标签，限制为合法的标签名
+var hostTagName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+var (
+	hostInfoMu sync.RWMutex
+	hostInfo   *HostInfo
+)
+
+// detectHostInfo collects the host information, applying the overrides of config
+func detectHostInfo(config HostConfig) HostInfo {
+	info := HostInfo{Hostname: config.Name, IP: config.IP, OS: runtime.GOOS, Tags: config.Tags}
+	if info.Hostname == "" {
+		info.Hostname, _ = os.Hostname()
+	}
+	if info.IP == "" {
+		info.IP = primaryIP()
+	}
+	if platform, _, version, err := host.PlatformInformation(); err == nil {
+		info.OSVersion = strings.TrimSpace(platform + " " + version)
+	}
+	return info
+}
+
+// primaryIP returns the first IPv4 address of an interface that is up and
+// not a loopback, or the first such IPv6 address if there is no IPv4 one
+func primaryIP() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	var v6 string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			if ip4 := ipnet.IP.To4(); ip4 != nil {
+				return ip4.String()
+			}
+			if v6 == "" {
+				v6 = ipnet.IP.String()
+			}
+		}
+	}
+	return v6
+}
+
+// setHostInfo replaces the host information, e.g. after the config is loaded
+func setHostInfo(config HostConfig) {
+	info := detectHostInfo(config)
+	hostInfoMu.Lock()
+	hostInfo = &info
+	hostInfoMu.Unlock()
+}
+
+// currentHost returns the host information, detecting it without overrides
+// if the config has not been applied yet
+func currentHost() HostInfo {
+	hostInfoMu.RLock()
+	info := hostInfo
+	hostInfoMu.RUnlock()
+	if info == nil {
+		setHostInfo(HostConfig{})
+		return currentHost()
+	}
+	return *info
+}
+
+// metricTags returns the host tags added to every metric: the host name and
+// the static tags. IP and OS version are only exported once, in host_info.
+func (h HostInfo) metricTags() map[string]string {
+	tags := map[string]string{"host": h.Hostname}
+	for k, v := range h.Tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// details returns the host information as flat key/value pairs, for
+// Prometheus labels and the details of incidents
+func (h HostInfo) details() map[string]string {
+	details := h.metricTags()
+	details["os"] = h.OS
+	if h.IP != "" {
+		details["ip"] = h.IP
+	}
+	if h.OSVersion != "" {
+		details["os_version"] = h.OSVersion
+	}
+	return details
+}
+
+// eventHost returns the host an event came from
+func eventHost(e Event) HostInfo {
+	if e.HostInfo != nil {
+		return *e.HostInfo
+	}
+	return currentHost()
+}
+
+// facts lists the host information as name/value pairs for notifications
+func (h HostInfo) facts() [][2]string {
+	facts := [][2]string{{"Host", h.Hostname}}
+	if h.IP != "" {
+		facts = append(facts, [2]string{"IP", h.IP})
+	}
+	system := h.OS
+	if h.OSVersion != "" {
+		system += " (" + h.OSVersion + ")"
+	}
+	facts = append(facts, [2]string{"OS", system})
+	for _, k := range sortedKeys(h.Tags) {
+		facts = append(facts, [2]string{k, h.Tags[k]})
+	}
+	return facts
+}
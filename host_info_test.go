@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectHostInfo(t *testing.T) {
+	info := detectHostInfo(HostConfig{Name: "web01", IP: "10.0.0.5", Tags: map[string]string{"site": "sh01", "rack": "a3"}})
+	if info.Hostname != "web01" || info.IP != "10.0.0.5" || info.OS != runtime.GOOS {
+		t.Errorf("overrides not applied: %+v", info)
+	}
+	if detected := detectHostInfo(HostConfig{}); detected.Hostname == "" {
+		t.Error("hostname not detected")
+	}
+
+	details := info.details()
+	for k, want := range map[string]string{"host": "web01", "ip": "10.0.0.5", "os": runtime.GOOS, "site": "sh01", "rack": "a3"} {
+		if details[k] != want {
+			t.Errorf("details[%s] = %q, want %q", k, details[k], want)
+		}
+	}
+	if tags := info.metricTags(); len(tags) != 3 || tags["ip"] != "" {
+		t.Errorf("metric tags should only hold host and static tags: %v", tags)
+	}
+
+	var names []string
+	for _, f := range info.facts() {
+		names = append(names, f[0])
+	}
+	if got := strings.Join(names, ","); got != "Host,IP,OS,rack,site" {
+		t.Errorf("facts = %s", got)
+	}
+}
+
+func TestEventsCarryHostInfo(t *testing.T) {
+	defer func(orig *HostInfo) { hostInfo = orig }(hostInfo)
+	hostInfo = &HostInfo{Hostname: "web01", OS: "linux", Tags: map[string]string{"customer": "acme"}}
+
+	sink := &recordingSink{}
+	registerEventSink(sink)
+	emitEvent(Event{Severity: SeverityInfo, Type: "host_info_test", Message: "test"})
+	e := sink.events[len(sink.events)-1]
+	if e.HostInfo == nil || e.HostInfo.Hostname != "web01" || e.HostInfo.Tags["customer"] != "acme" {
+		t.Errorf("event host = %+v", e.HostInfo)
+	}
+
+	data, _ := json.Marshal(newTeamsNotifier(TeamsConfig{}).card(e))
+	if card := string(data); !strings.Contains(card, `"title":"customer","value":"acme"`) {
+		t.Errorf("Teams card is missing the host tags: %s", card)
+	}
+}
+
+func TestValidateHostConfig(t *testing.T) {
+	for _, tt := range []struct {
+		host HostConfig
+		ok   bool
+	}{
+		{HostConfig{Name: "web01", IP: "10.0.0.5", Tags: map[string]string{"site": "sh01"}}, true},
+		{HostConfig{Tags: map[string]string{"data-center": "x"}}, false},
+		{HostConfig{Tags: map[string]string{"host": "x"}}, false},
+		{HostConfig{IP: "10.0.0"}, false},
+	} {
+		if err := validateConfig(Config{Host: tt.host}); (err == nil) != tt.ok {
+			t.Errorf("validateConfig(%+v) = %v, want ok=%v", tt.host, err, tt.ok)
+		}
+	}
+}
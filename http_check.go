@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHealthBody expect_body 最多读取的响应体字节数
+const maxHealthBody = 64 * 1024
+
+// ProxyDirect 健康检查不使用代理（忽略 HTTP_PROXY 等环境变量）
+const ProxyDirect = "direct"
+
+// statusRange is an inclusive range of HTTP status codes
+type statusRange struct{ min, max int }
+
+// parseStatusRanges parses expect_status: codes ("204"), ranges
+// ("200-299") and classes ("2xx")
+func parseStatusRanges(specs []string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		var r statusRange
+		var err error
+		switch {
+		case len(spec) == 3 && strings.HasSuffix(strings.ToLower(spec), "xx"):
+			var class int
+			class, err = strconv.Atoi(spec[:1])
+			r = statusRange{class * 100, class*100 + 99}
+		case strings.Contains(spec, "-"):
+			lo, hi, _ := strings.Cut(spec, "-")
+			r.min, err = strconv.Atoi(strings.TrimSpace(lo))
+			if err == nil {
+				r.max, err = strconv.Atoi(strings.TrimSpace(hi))
+			}
+		default:
+			r.min, err = strconv.Atoi(spec)
+			r.max = r.min
+		}
+		if err != nil || r.min < 100 || r.max > 599 || r.min > r.max {
+			return nil, fmt.Errorf("invalid expect_status %q: use a code (204), a range (200-299) or a class (2xx)", spec)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func statusExpected(ranges []statusRange, code int) bool {
+	if len(ranges) == 0 {
+		return code == http.StatusOK
+	}
+	for _, r := range ranges {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsClientConfig returns the TLS settings of a check: the CA used to verify
+// the server, a client certificate for mutual TLS and insecure_skip_verify.
+// It returns nil when none of them is set.
+func (c HealthCheck) tlsClientConfig() (*tls.Config, error) {
+	if c.CAFile == "" && c.CertFile == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s contains no certificates", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// validateHTTP reports configuration errors of the HTTP options
+func (c HealthCheck) validateHTTP() error {
+	if c.Method != "" && c.Method != strings.ToUpper(c.Method) {
+		return fmt.Errorf("health check %s: method must be upper case, got %q", c.URL, c.Method)
+	}
+	if _, err := parseStatusRanges(c.ExpectStatus); err != nil {
+		return fmt.Errorf("health check %s: %v", c.URL, err)
+	}
+	if c.ExpectBody != "" {
+		if c.Method == http.MethodHead {
+			return fmt.Errorf("health check %s: expect_body cannot be used with method HEAD", c.URL)
+		}
+		if _, err := regexp.Compile(c.ExpectBody); err != nil {
+			return fmt.Errorf("health check %s: invalid expect_body: %v", c.URL, err)
+		}
+	}
+	if c.Proxy != "" && c.Proxy != ProxyDirect {
+		if _, err := parseProxyURL(c.Proxy); err != nil {
+			return fmt.Errorf("health check %s: %v", c.URL, err)
+		}
+	}
+	if (c.CAFile != "" || c.CertFile != "" || c.InsecureSkipVerify) && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("health check %s: ca_file, cert_file and insecure_skip_verify require an https:// URL", c.URL)
+	}
+	return nil
+}
+
+// runHTTP requests the URL of the check on every address of its host until
+// one answers with an expected status (and body)
+func (c HealthCheck) runHTTP(config ProcessConfig, timeout time.Duration) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ranges, err := parseStatusRanges(c.ExpectStatus)
+	if err != nil {
+		return err
+	}
+	var bodyPattern *regexp.Regexp
+	if c.ExpectBody != "" {
+		if bodyPattern, err = regexp.Compile(c.ExpectBody); err != nil {
+			return err
+		}
+	}
+	tlsConfig, err := c.tlsClientConfig()
+	if err != nil {
+		return err
+	}
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	proxy, err := c.proxyFunc()
+	if err != nil {
+		return err
+	}
+	var proxied bool
+	if proxy != nil {
+		probe, err := http.NewRequest(method, c.URL, nil)
+		if err != nil {
+			return err
+		}
+		proxyURL, err := proxy(probe)
+		if err != nil {
+			return err
+		}
+		proxied = proxyURL != nil
+	}
+
+	attempt := func(ctx context.Context, addr string) error {
+		var body io.Reader
+		if c.Body != "" {
+			body = strings.NewReader(c.Body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.URL, body)
+		if err != nil {
+			return err
+		}
+		for k, v := range c.Headers {
+			if strings.EqualFold(k, "Host") {
+				req.Host = v
+				continue
+			}
+			req.Header.Set(k, v)
+		}
+
+		client := http.DefaultClient
+		if addr != u.Hostname() || tlsConfig != nil || expectsRedirect(ranges) || c.Proxy != "" {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.DisableKeepAlives = true
+			transport.TLSClientConfig = tlsConfig
+			transport.Proxy = proxy
+			if addr != u.Hostname() {
+				// 连接指定的地址，Host 头和 TLS 证书校验仍使用URL中的主机名
+				transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, net.JoinHostPort(addr, port))
+				}
+			}
+			client = &http.Client{Transport: transport}
+			if expectsRedirect(ranges) {
+				// 期望 3xx 时检查重定向响应本身，不跟随
+				client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+			}
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if !statusExpected(ranges, resp.StatusCode) {
+			if len(c.ExpectStatus) == 0 {
+				return fmt.Errorf("HTTP %d", resp.StatusCode)
+			}
+			return fmt.Errorf("HTTP %d (expected %s)", resp.StatusCode, strings.Join(c.ExpectStatus, ", "))
+		}
+		if bodyPattern != nil {
+			data, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBody))
+			if err != nil {
+				return fmt.Errorf("failed to read response: %v", err)
+			}
+			if !bodyPattern.Match(data) {
+				return fmt.Errorf("response body does not match %q: %s", c.ExpectBody, truncate(strings.TrimSpace(string(data)), 200))
+			}
+		}
+		return nil
+	}
+	if proxied {
+		// 通过代理访问时由代理解析主机名（本机可能无法解析），不逐个尝试各个地址
+		return withCheckTimeout(timeout, func(ctx context.Context) error { return attempt(ctx, u.Hostname()) })
+	}
+	return tryAddresses(config.Name, c, u.Hostname(), timeout, attempt)
+}
+
+// proxyFunc returns the proxy of an HTTP check: the proxy option, nil for
+// proxy: direct, or HTTP_PROXY/HTTPS_PROXY/NO_PROXY of the monitor
+func (c HealthCheck) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	switch c.Proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case ProxyDirect:
+		return nil, nil
+	}
+	u, err := parseProxyURL(c.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(u), nil
+}
+
+func parseProxyURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+		// 不在错误中显示代理地址，其中可能包含密码
+		return nil, fmt.Errorf("invalid proxy: use http://, https:// or socks5://host:port, or %s", ProxyDirect)
+	}
+	return u, nil
+}
+
+// expectsRedirect reports whether a 3xx status counts as healthy
+func expectsRedirect(ranges []statusRange) bool {
+	for _, r := range ranges {
+		if r.min < 400 && r.max >= 300 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 应用程序池状态，与 appcmd 和 IIS Administration API 的输出一致（小写）
+const (
+	AppPoolStarted  = "started"
+	AppPoolStopped  = "stopped"
+	AppPoolStarting = "starting"
+	AppPoolStopping = "stopping"
+)
+
+// 队列过长时的处理方式
+const (
+	QueueActionRecycle = "recycle"
+	QueueActionAlert   = "alert"
+)
+
+const (
+	defaultAppPoolCheckInterval = 30
+	defaultQueueSustainedChecks = 3
+	iisCommandTimeout           = 30 * time.Second
+)
+
+// IISConfig 监控 IIS 应用程序池（仅Windows）
+type IISConfig struct {
+	AdminAPI IISAdminAPIConfig   `yaml:"admin_api"` // 通过 IIS Administration API 管理（为空时使用 appcmd.exe）
+	AppPools []IISAppPoolMonitor `yaml:"app_pools"`
+}
+
+// IISAdminAPIConfig 是 Microsoft IIS Administration API 的地址和访问令牌
+type IISAdminAPIConfig struct {
+	URL                string `yaml:"url"`                  // 如 "https://localhost:55539"
+	AccessToken        string `yaml:"access_token"`         // 在 API 的 /security/tokens 页面生成
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // 不校验证书（API 默认使用自签名证书）
+	Timeout            int    `yaml:"timeout"`              // 请求超时（秒，默认10）
+}
+
+// IISAppPoolMonitor 监控一个应用程序池：停止时启动，请求队列过长时回收
+type IISAppPoolMonitor struct {
+	Name            string          `yaml:"name"`             // 应用程序池名称，如 "DefaultAppPool"
+	CheckInterval   int             `yaml:"check_interval"`   // 检查间隔（秒，默认30）
+	Restart         bool            `yaml:"restart"`          // 池停止时启动它
+	MaxQueueLength  int             `yaml:"max_queue_length"` // 请求队列长度上限（HTTP.sys 队列中等待的请求数，0表示不检查）
+	SustainedChecks int             `yaml:"sustained_checks"` // 连续多少次检查超过上限后处理（默认3）
+	QueueAction     string          `yaml:"queue_action"`     // 超过上限时：recycle（默认，回收池）或 alert（只告警）
+	Enforce         EnforcementGate `yaml:"enforce"`          // 何时启动和回收池，与服务监控相同
+}
+
+// validate checks one application pool monitor
+func (m IISAppPoolMonitor) validate() error {
+	if strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if m.CheckInterval < 0 || m.MaxQueueLength < 0 || m.SustainedChecks < 0 {
+		return fmt.Errorf("check_interval, max_queue_length and sustained_checks must not be negative")
+	}
+	switch m.QueueAction {
+	case "", QueueActionRecycle, QueueActionAlert:
+	default:
+		return fmt.Errorf("invalid queue_action %q (use recycle or alert)", m.QueueAction)
+	}
+	return nil
+}
+
+// appPoolTags returns the metric tags of a monitored application pool
+func appPoolTags(name string) map[string]string {
+	return map[string]string{"app_pool": name}
+}
+
+// iisBackend reads and controls application pools
+type iisBackend interface {
+	poolState(ctx context.Context, name string) (string, error)
+	startPool(ctx context.Context, name string) error
+	recyclePool(ctx context.Context, name string) error
+}
+
+// newIISBackend uses the IIS Administration API when it is configured and
+// appcmd.exe otherwise
+func newIISBackend(config IISAdminAPIConfig) iisBackend {
+	if config.URL == "" {
+		return appcmdBackend{path: filepath.Join(os.Getenv("windir"), "system32", "inetsrv", "appcmd.exe")}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &iisAdminAPI{
+		config: config,
+		client: &http.Client{Timeout: time.Duration(defaultInt(config.Timeout, 10)) * time.Second, Transport: transport},
+		ids:    make(map[string]string),
+	}
+}
+
+// appcmdBackend controls pools with %windir%\system32\inetsrv\appcmd.exe
+type appcmdBackend struct {
+	path string
+}
+
+func (b appcmdBackend) run(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, iisCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, b.path, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("appcmd %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+func (b appcmdBackend) poolState(ctx context.Context, name string) (string, error) {
+	out, err := b.run(ctx, "list", "apppool", "/apppool.name:"+name, "/text:state")
+	if err != nil {
+		return "", err
+	}
+	state := strings.ToLower(strings.TrimSpace(out))
+	if state == "" {
+		return "", fmt.Errorf("application pool %s does not exist", name)
+	}
+	return state, nil
+}
+
+func (b appcmdBackend) startPool(ctx context.Context, name string) error {
+	_, err := b.run(ctx, "start", "apppool", "/apppool.name:"+name)
+	return err
+}
+
+func (b appcmdBackend) recyclePool(ctx context.Context, name string) error {
+	_, err := b.run(ctx, "recycle", "apppool", "/apppool.name:"+name)
+	return err
+}
+
+// iisAdminAPI controls pools through the REST API of Microsoft IIS
+// Administration (/api/webserver/application-pools)
+type iisAdminAPI struct {
+	config IISAdminAPIConfig
+	client *http.Client
+	ids    map[string]string // 池名称 -> API 中的 id
+}
+
+// iisAppPool is the part of an application pool resource the monitor uses
+type iisAppPool struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func (a *iisAdminAPI) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(a.config.URL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Access-Token", "Bearer "+a.config.AccessToken)
+	req.Header.Set("Accept", "application/hal+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("IIS Administration API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("IIS Administration API %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// poolID looks up the API id of the pool named name
+func (a *iisAdminAPI) poolID(ctx context.Context, name string) (string, error) {
+	if id, ok := a.ids[name]; ok {
+		return id, nil
+	}
+	var list struct {
+		AppPools []iisAppPool `json:"app_pools"`
+	}
+	if err := a.do(ctx, http.MethodGet, "/api/webserver/application-pools", nil, &list); err != nil {
+		return "", err
+	}
+	for _, pool := range list.AppPools {
+		if strings.EqualFold(pool.Name, name) {
+			a.ids[name] = pool.ID
+			return pool.ID, nil
+		}
+	}
+	return "", fmt.Errorf("application pool %s does not exist", name)
+}
+
+// pool fetches the pool named name; a stale id (pool recreated) is looked up again
+func (a *iisAdminAPI) pool(ctx context.Context, name string) (iisAppPool, error) {
+	id, err := a.poolID(ctx, name)
+	if err != nil {
+		return iisAppPool{}, err
+	}
+	var pool iisAppPool
+	if err := a.do(ctx, http.MethodGet, "/api/webserver/application-pools/"+url.PathEscape(id), nil, &pool); err != nil {
+		delete(a.ids, name)
+		return iisAppPool{}, err
+	}
+	return pool, nil
+}
+
+func (a *iisAdminAPI) setStatus(ctx context.Context, name, status string) error {
+	id, err := a.poolID(ctx, name)
+	if err != nil {
+		return err
+	}
+	return a.do(ctx, http.MethodPatch, "/api/webserver/application-pools/"+url.PathEscape(id), map[string]string{"status": status}, nil)
+}
+
+func (a *iisAdminAPI) poolState(ctx context.Context, name string) (string, error) {
+	pool, err := a.pool(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(pool.Status), nil
+}
+
+func (a *iisAdminAPI) startPool(ctx context.Context, name string) error {
+	return a.setStatus(ctx, name, AppPoolStarted)
+}
+
+// recyclePool stops and starts the pool; the API has no overlapped recycle
+func (a *iisAdminAPI) recyclePool(ctx context.Context, name string) error {
+	if err := a.setStatus(ctx, name, AppPoolStopped); err != nil {
+		return err
+	}
+	return a.setStatus(ctx, name, AppPoolStarted)
+}
+
+// appPoolWatch holds what one application pool monitor has already
+// reported, so every deviation is logged and notified once
+type appPoolWatch struct {
+	config          IISAppPoolMonitor
+	backend         iisBackend
+	queueLength     func(pool string) (int, error) // 请求队列长度（Windows 上读取性能计数器）
+	missing         string                         // 已报告的读取池状态的错误
+	stoppedReported bool
+	queueHigh       int  // 连续超过 max_queue_length 的检查次数
+	queueReported   bool // 已报告队列过长（alert 或回收被暂停时）
+}
+
+// check compares the pool with its config, starting or recycling it when
+// enforcement allows it
+func (w *appPoolWatch) check(ctx context.Context) {
+	config := w.config
+	state, err := w.backend.poolState(ctx, config.Name)
+	if err != nil {
+		if w.missing != err.Error() {
+			logrus.Errorf("IIS app pool monitor %s: %v", config.Name, err)
+			w.missing = err.Error()
+		}
+		return
+	}
+	if w.missing != "" {
+		logrus.Infof("IIS app pool %s is available again, monitoring it", config.Name)
+		w.missing = ""
+	}
+
+	enforce, suspendReason := config.Enforce.Active(time.Now())
+	switch state {
+	case AppPoolStarted:
+		w.stoppedReported = false
+		w.checkQueue(ctx, enforce, suspendReason)
+		return
+	case AppPoolStarting, AppPoolStopping:
+		// 状态正在变化，下次检查再看结果
+		return
+	}
+
+	w.queueHigh = 0
+	if !config.Restart || !enforce {
+		if !w.stoppedReported {
+			w.stoppedReported = true
+			reason := "restart is off"
+			if config.Restart {
+				reason = "enforcement suspended: " + suspendReason
+			}
+			emitEvent(Event{
+				Severity: SeverityWarning,
+				Type:     "service_stopped",
+				Process:  config.Name,
+				Message:  fmt.Sprintf("IIS app pool %s is %s (%s)", config.Name, state, reason),
+				Details:  map[string]string{"kind": "iis_app_pool", "state": state, "reason": reason},
+			})
+		}
+		return
+	}
+
+	logrus.Warnf("IIS app pool %s is %s, starting it", config.Name, state)
+	w.act(ctx, "start", "app pool "+state, w.backend.startPool)
+	w.stoppedReported = false
+}
+
+// checkQueue recycles the pool (or alerts) when its request queue stays
+// above max_queue_length for sustained_checks checks in a row
+func (w *appPoolWatch) checkQueue(ctx context.Context, enforce bool, suspendReason string) {
+	config := w.config
+	if config.MaxQueueLength <= 0 || w.queueLength == nil {
+		return
+	}
+	length, err := w.queueLength(config.Name)
+	if err != nil {
+		logrus.Debugf("IIS app pool %s: cannot read request queue length: %v", config.Name, err)
+		return
+	}
+	emitGauge("app_pool.queue_length", float64(length), appPoolTags(config.Name))
+	if length <= config.MaxQueueLength {
+		w.queueHigh = 0
+		w.queueReported = false
+		return
+	}
+	w.queueHigh++
+	if w.queueHigh < defaultInt(config.SustainedChecks, defaultQueueSustainedChecks) {
+		return
+	}
+
+	reason := fmt.Sprintf("request queue length %d above %d", length, config.MaxQueueLength)
+	recycle := config.QueueAction != QueueActionAlert && enforce
+	if !recycle && w.queueReported {
+		return
+	}
+	action := "alert only"
+	switch {
+	case recycle:
+		action = "recycling the pool"
+	case config.QueueAction != QueueActionAlert:
+		action = "recycle suspended: " + suspendReason
+	}
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "app_pool_queue_high",
+		Process:  config.Name,
+		Message:  fmt.Sprintf("IIS app pool %s has %d queued requests (limit %d), %s", config.Name, length, config.MaxQueueLength, action),
+		Details: map[string]string{
+			"kind":         "iis_app_pool",
+			"queue_length": fmt.Sprint(length),
+			"limit":        fmt.Sprint(config.MaxQueueLength),
+			"action":       action,
+		},
+	})
+	w.queueReported = true
+	if recycle {
+		logrus.Warnf("IIS app pool %s: %s, recycling it", config.Name, reason)
+		w.act(ctx, "recycle", reason, w.backend.recyclePool)
+		w.queueHigh = 0
+		w.queueReported = false
+	}
+}
+
+// act starts or recycles the pool and reports the result with the same
+// events as a process restart
+func (w *appPoolWatch) act(ctx context.Context, verb, reason string, fn func(context.Context, string) error) {
+	name := w.config.Name
+	emitCount("restarts", 1, appPoolTags(name))
+	if err := fn(ctx, name); err != nil {
+		emitEvent(Event{
+			Severity: SeverityWarning,
+			Type:     "restart_failed",
+			Process:  name,
+			Message:  fmt.Sprintf("Failed to %s IIS app pool %s: %v", verb, name, err),
+			Details:  map[string]string{"kind": "iis_app_pool", "reason": reason, "error": err.Error()},
+		})
+		return
+	}
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "process_restarted",
+		Process:  name,
+		Message:  fmt.Sprintf("Successfully ran %s on IIS app pool %s", verb, name),
+		Details:  map[string]string{"kind": "iis_app_pool", "reason": reason},
+	})
+}
+
+// MonitorIISAppPool 监控 IIS 应用程序池的状态和请求队列，直到 ctx 取消
+func MonitorIISAppPool(config IISAppPoolMonitor, backend iisBackend, ctx context.Context) {
+	logrus.Infof("Starting IIS app pool monitor for %s", config.Name)
+	w := &appPoolWatch{config: config, backend: backend, queueLength: appPoolQueueLength}
+	ticker := time.NewTicker(time.Duration(defaultInt(config.CheckInterval, defaultAppPoolCheckInterval)) * time.Second)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logrus.Infof("Stopping IIS app pool monitor %s", config.Name)
+			return
+		}
+	}
+}
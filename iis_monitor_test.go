@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeIIS is an iisBackend keeping the pool state in memory
+type fakeIIS struct {
+	state    string
+	starts   int
+	recycles int
+}
+
+func (f *fakeIIS) poolState(ctx context.Context, name string) (string, error) { return f.state, nil }
+func (f *fakeIIS) startPool(ctx context.Context, name string) error {
+	f.starts++
+	f.state = AppPoolStarted
+	return nil
+}
+func (f *fakeIIS) recyclePool(ctx context.Context, name string) error {
+	f.recycles++
+	return nil
+}
+
+func poolEvents(sink *recordingSink, pool string) []string {
+	var types []string
+	for _, e := range sink.events {
+		if e.Process == pool {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+func TestAppPoolWatchRestartsStoppedPool(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	iis := &fakeIIS{state: AppPoolStopped}
+	w := &appPoolWatch{config: IISAppPoolMonitor{Name: "OrdersPool", Restart: true}, backend: iis}
+	w.check(context.Background())
+	if iis.starts != 1 {
+		t.Fatalf("pool started %d times, want 1", iis.starts)
+	}
+	if got := poolEvents(sink, "OrdersPool"); len(got) != 1 || got[0] != "process_restarted" {
+		t.Errorf("events = %v, want [process_restarted]", got)
+	}
+
+	// 不自动启动时只报告一次
+	iis = &fakeIIS{state: AppPoolStopped}
+	w = &appPoolWatch{config: IISAppPoolMonitor{Name: "ReportsPool"}, backend: iis}
+	w.check(context.Background())
+	w.check(context.Background())
+	if iis.starts != 0 {
+		t.Error("pool started although restart is off")
+	}
+	if got := poolEvents(sink, "ReportsPool"); len(got) != 1 || got[0] != "service_stopped" {
+		t.Errorf("events = %v, want one service_stopped", got)
+	}
+}
+
+func TestAppPoolWatchQueueLength(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	queue := 0
+	iis := &fakeIIS{state: AppPoolStarted}
+	w := &appPoolWatch{
+		config:      IISAppPoolMonitor{Name: "ApiPool", MaxQueueLength: 100, SustainedChecks: 2},
+		backend:     iis,
+		queueLength: func(string) (int, error) { return queue, nil },
+	}
+	queue = 500
+	w.check(context.Background())
+	if iis.recycles != 0 {
+		t.Fatal("recycled before sustained_checks")
+	}
+	w.check(context.Background())
+	if iis.recycles != 1 {
+		t.Fatalf("recycles = %d, want 1", iis.recycles)
+	}
+	queue = 10
+	w.check(context.Background())
+	if iis.recycles != 1 || w.queueHigh != 0 {
+		t.Errorf("recycles = %d, queueHigh = %d after the queue drained", iis.recycles, w.queueHigh)
+	}
+	if got := poolEvents(sink, "ApiPool"); len(got) != 2 || got[0] != "app_pool_queue_high" || got[1] != "process_restarted" {
+		t.Errorf("events = %v", got)
+	}
+
+	// alert 只告警一次，不回收
+	w.config.QueueAction = QueueActionAlert
+	w.config.Name = "AlertPool"
+	queue = 500
+	for i := 0; i < 4; i++ {
+		w.check(context.Background())
+	}
+	if iis.recycles != 1 {
+		t.Error("alert action recycled the pool")
+	}
+	if got := poolEvents(sink, "AlertPool"); len(got) != 1 {
+		t.Errorf("events = %v, want one alert", got)
+	}
+}
+
+func TestIISAdminAPIBackend(t *testing.T) {
+	status := "stopped"
+	var patches []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Access-Token") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/webserver/application-pools":
+			json.NewEncoder(w).Encode(map[string]interface{}{"app_pools": []iisAppPool{{Name: "DefaultAppPool", ID: "abc"}, {Name: "OrdersPool", ID: "x1"}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/webserver/application-pools/x1":
+			json.NewEncoder(w).Encode(iisAppPool{Name: "OrdersPool", ID: "x1", Status: status})
+		case r.Method == http.MethodPatch && r.URL.Path == "/api/webserver/application-pools/x1":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			status = body["status"]
+			patches = append(patches, status)
+			json.NewEncoder(w).Encode(iisAppPool{Name: "OrdersPool", ID: "x1", Status: status})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	api := newIISBackend(IISAdminAPIConfig{URL: srv.URL, AccessToken: "secret"})
+	if state, err := api.poolState(ctx, "orderspool"); err != nil || state != AppPoolStopped {
+		t.Fatalf("poolState = %q, %v", state, err)
+	}
+	if err := api.startPool(ctx, "OrdersPool"); err != nil || status != AppPoolStarted {
+		t.Fatalf("startPool: %v, status %s", err, status)
+	}
+	if err := api.recyclePool(ctx, "OrdersPool"); err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 3 || patches[1] != AppPoolStopped || patches[2] != AppPoolStarted {
+		t.Errorf("patches = %v, want started, stopped, started", patches)
+	}
+	if _, err := api.poolState(ctx, "MissingPool"); err == nil {
+		t.Error("unknown pool should fail")
+	}
+
+	bad := newIISBackend(IISAdminAPIConfig{URL: srv.URL, AccessToken: "wrong"})
+	if _, err := bad.poolState(ctx, "OrdersPool"); err == nil {
+		t.Error("wrong access token should fail")
+	}
+}
+
+func TestIISAppPoolMonitorValidate(t *testing.T) {
+	tests := []struct {
+		monitor IISAppPoolMonitor
+		ok      bool
+	}{
+		{IISAppPoolMonitor{Name: "DefaultAppPool", Restart: true}, true},
+		{IISAppPoolMonitor{Name: "Api", MaxQueueLength: 100, QueueAction: QueueActionAlert}, true},
+		{IISAppPoolMonitor{Name: "Api", QueueAction: "restart"}, false},
+		{IISAppPoolMonitor{Name: "Api", MaxQueueLength: -1}, false},
+		{IISAppPoolMonitor{Name: ""}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.monitor.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok=%v", tt.monitor, err, tt.ok)
+		}
+	}
+
+	err := validateConfig(Config{IIS: IISConfig{
+		AdminAPI: IISAdminAPIConfig{URL: "localhost:55539"},
+		AppPools: []IISAppPoolMonitor{{Name: "Api"}, {Name: "api"}},
+	}})
+	if err == nil {
+		t.Error("invalid iis config accepted")
+	}
+}
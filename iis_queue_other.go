@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// appPoolQueueLength 请求队列长度来自 Windows 性能计数器，其他平台不可用
+func appPoolQueueLength(pool string) (int, error) {
+	return 0, fmt.Errorf("request queue length is only available on Windows")
+}
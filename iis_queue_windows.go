@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	pdh                             = windows.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQueryW               = pdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW       = pdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = pdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterValue = pdh.NewProc("PdhGetFormattedCounterValue")
+	procPdhCloseQuery               = pdh.NewProc("PdhCloseQuery")
+)
+
+const pdhFmtLarge = 0x00000400
+
+// pdhCounterValue is PDH_FMT_COUNTERVALUE with the 64-bit integer member
+type pdhCounterValue struct {
+	CStatus    uint32
+	_          uint32
+	LargeValue int64
+}
+
+// appPoolQueueLength reads the HTTP.sys request queue of an application
+// pool (performance counter HTTP Service Request Queues\CurrentQueueSize)
+func appPoolQueueLength(pool string) (int, error) {
+	// 计数器实例名中的括号和斜杠需要替换，与性能监视器中显示的实例名一致
+	instance := strings.NewReplacer("(", "[", ")", "]", "/", "_", "#", "_").Replace(pool)
+	path, err := windows.UTF16PtrFromString(`\HTTP Service Request Queues(` + instance + `)\CurrentQueueSize`)
+	if err != nil {
+		return 0, err
+	}
+
+	var query, counter windows.Handle
+	if r, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&query))); r != 0 {
+		return 0, fmt.Errorf("PdhOpenQuery failed: 0x%x", r)
+	}
+	defer procPdhCloseQuery.Call(uintptr(query))
+	if r, _, _ := procPdhAddEnglishCounterW.Call(uintptr(query), uintptr(unsafe.Pointer(path)), 0, uintptr(unsafe.Pointer(&counter))); r != 0 {
+		return 0, fmt.Errorf("no request queue counter for app pool %s: 0x%x", pool, r)
+	}
+	if r, _, _ := procPdhCollectQueryData.Call(uintptr(query)); r != 0 {
+		return 0, fmt.Errorf("PdhCollectQueryData failed: 0x%x", r)
+	}
+	var value pdhCounterValue
+	if r, _, _ := procPdhGetFormattedCounterValue.Call(uintptr(counter), pdhFmtLarge, 0, uintptr(unsafe.Pointer(&value))); r != 0 {
+		return 0, fmt.Errorf("PdhGetFormattedCounterValue failed: 0x%x", r)
+	}
+	return int(value.LargeValue), nil
+}
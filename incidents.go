@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IncidentsConfig 将严重事件同步为 PagerDuty / OpsGenie 事故
+type IncidentsConfig struct {
+	PagerDuty PagerDutyConfig `yaml:"pagerduty"`
+	OpsGenie  OpsGenieConfig  `yaml:"opsgenie"`
+	Events    []string        `yaml:"events"`  // 除 critical 事件外，额外触发事故的事件类型
+	Timeout   int             `yaml:"timeout"` // 单次请求超时（秒，默认10）
+}
+
+// PagerDutyConfig PagerDuty Events API v2
+type PagerDutyConfig struct {
+	RoutingKey string `yaml:"routing_key"` // 服务集成的 Integration Key，为空则不启用
+	URL        string `yaml:"url"`         // 默认 https://events.pagerduty.com/v2/enqueue
+}
+
+// OpsGenieConfig OpsGenie Alert API
+type OpsGenieConfig struct {
+	APIKey   string   `yaml:"api_key"`  // API 集成的密钥，为空则不启用
+	URL      string   `yaml:"url"`      // 默认 https://api.opsgenie.com（欧洲区为 https://api.eu.opsgenie.com）
+	Priority string   `yaml:"priority"` // 告警优先级 P1-P5（默认 P2）
+	Tags     []string `yaml:"tags"`     // 附加到告警的标签
+}
+
+// 事故生命周期操作
+const (
+	incidentTrigger     = "trigger"
+	incidentAcknowledge = "acknowledge"
+	incidentResolve     = "resolve"
+)
+
+// incidentTransition ties an event type to a lifecycle action of an alert
+type incidentTransition struct {
+	action string
+	alert  string
+}
+
+// incidentTransitions lists the events that open, acknowledge and resolve
+// the same alert. Other critical events (and the types in events) open an
+// alert named after the event that has to be resolved by hand.
+var incidentTransitions = map[string]incidentTransition{
+	"circuit_open":      {incidentTrigger, "circuit"},
+	"circuit_half_open": {incidentAcknowledge, "circuit"}, // 正在尝试恢复
+	"circuit_closed":    {incidentResolve, "circuit"},
+	"safe_mode_entered": {incidentTrigger, "safe_mode"},
+	"safe_mode_exited":  {incidentResolve, "safe_mode"},
+	"update_failed":     {incidentTrigger, "update"},
+	"binary_updated":    {incidentResolve, "update"},
+}
+
+// incident is one lifecycle change to deliver to the providers
+type incident struct {
+	action string
+	key    string // 去重键，同一告警的触发、确认和恢复使用相同的键
+	event  Event
+}
+
+// incidentProvider delivers incidents to one paging service
+type incidentProvider interface {
+	name() string
+	send(ctx context.Context, inc incident) error
+}
+
+// incidentManager is an EventSink that keeps the state of open alerts and
+// forwards their lifecycle to PagerDuty and OpsGenie in event order
+type incidentManager struct {
+	config    IncidentsConfig
+	host      string
+	providers []incidentProvider
+	queue     chan incident
+
+	mu   sync.Mutex
+	open map[string]string // 去重键 -> 当前状态（trigger/acknowledge）
+}
+
+func newIncidentManager(config IncidentsConfig) *incidentManager {
+	if config.Timeout <= 0 {
+		config.Timeout = 10
+	}
+	client := &http.Client{Timeout: time.Duration(config.Timeout) * time.Second}
+	host := currentHost().Hostname
+	m := &incidentManager{
+		config: config,
+		host:   host,
+		queue:  make(chan incident, 100),
+		open:   make(map[string]string),
+	}
+	if config.PagerDuty.RoutingKey != "" {
+		m.providers = append(m.providers, &pagerDutyProvider{config: config.PagerDuty, client: client, host: host})
+	}
+	if config.OpsGenie.APIKey != "" {
+		m.providers = append(m.providers, &opsGenieProvider{config: config.OpsGenie, client: client, host: host})
+	}
+	return m
+}
+
+// enabled reports whether any provider is configured
+func (m *incidentManager) enabled() bool {
+	return len(m.providers) > 0
+}
+
+// HandleEvent turns e into an incident action when it affects an alert
+func (m *incidentManager) HandleEvent(e Event) {
+	inc, ok := m.transition(e)
+	if !ok {
+		return
+	}
+	select {
+	case m.queue <- inc:
+	default:
+		logrus.Warnf("Incident queue is full, dropping %s of %s", inc.action, inc.key)
+	}
+}
+
+// transition applies e to the alert state and returns the action to send.
+// Acknowledge and resolve are only sent for alerts this monitor opened.
+func (m *incidentManager) transition(e Event) (incident, bool) {
+	t, known := incidentTransitions[e.Type]
+	if !known {
+		extra := len(m.config.Events) > 0 && matchesAny(m.config.Events, e.Type)
+		if e.Severity != SeverityCritical && !extra {
+			return incident{}, false
+		}
+		t = incidentTransition{incidentTrigger, e.Type}
+	}
+	key := fmt.Sprintf("processmonitor/%s/%s", m.host, t.alert)
+	if e.Process != "" {
+		key += "/" + e.Process
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, isOpen := m.open[key]
+	switch t.action {
+	case incidentTrigger:
+		m.open[key] = incidentTrigger
+	case incidentAcknowledge:
+		if !isOpen || state == incidentAcknowledge {
+			return incident{}, false
+		}
+		m.open[key] = incidentAcknowledge
+	case incidentResolve:
+		if !isOpen {
+			return incident{}, false
+		}
+		delete(m.open, key)
+	}
+	return incident{action: t.action, key: key, event: e}, true
+}
+
+// Run delivers queued incidents until ctx is done
+func (m *incidentManager) Run(ctx context.Context) {
+	for {
+		select {
+		case inc := <-m.queue:
+			for _, p := range m.providers {
+				if err := p.send(ctx, inc); err != nil {
+					logrus.Errorf("Failed to %s %s incident %s: %v", inc.action, p.name(), inc.key, err)
+				} else {
+					logrus.Infof("Sent %s of incident %s to %s", inc.action, inc.key, p.name())
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// incidentSummary is the one-line title of an incident
+func incidentSummary(host string, e Event) string {
+	return fmt.Sprintf("[%s] %s", host, e.Message)
+}
+
+// pagerDutyProvider sends Events API v2 requests
+type pagerDutyProvider struct {
+	config PagerDutyConfig
+	client *http.Client
+	host   string
+}
+
+func (p *pagerDutyProvider) name() string { return "PagerDuty" }
+
+func (p *pagerDutyProvider) send(ctx context.Context, inc incident) error {
+	endpoint := p.config.URL
+	if endpoint == "" {
+		endpoint = "https://events.pagerduty.com/v2/enqueue"
+	}
+	body := map[string]interface{}{
+		"routing_key":  p.config.RoutingKey,
+		"event_action": inc.action,
+		"dedup_key":    inc.key,
+	}
+	if inc.action == incidentTrigger {
+		severity := "critical"
+		switch inc.event.Severity {
+		case SeverityWarning:
+			severity = "warning"
+		case SeverityInfo:
+			severity = "info"
+		}
+		details := map[string]string{"message": inc.event.Message}
+		for k, v := range eventHost(inc.event).details() {
+			details[k] = v
+		}
+		for k, v := range inc.event.Details {
+			details[k] = v
+		}
+		if inc.event.CorrelationID != "" {
+			details["correlation_id"] = inc.event.CorrelationID
+		}
+		body["payload"] = map[string]interface{}{
+			"summary":        truncate(incidentSummary(p.host, inc.event), 1024),
+			"source":         p.host,
+			"severity":       severity,
+			"component":      inc.event.Process,
+			"class":          inc.event.Type,
+			"timestamp":      inc.event.Time.Format(time.RFC3339),
+			"custom_details": details,
+		}
+	}
+	return postJSON(ctx, p.client, endpoint, nil, body)
+}
+
+// opsGenieProvider sends Alert API requests
+type opsGenieProvider struct {
+	config OpsGenieConfig
+	client *http.Client
+	host   string
+}
+
+func (p *opsGenieProvider) name() string { return "OpsGenie" }
+
+func (p *opsGenieProvider) send(ctx context.Context, inc incident) error {
+	base := strings.TrimRight(p.config.URL, "/")
+	if base == "" {
+		base = "https://api.opsgenie.com"
+	}
+	headers := map[string]string{"Authorization": "GenieKey " + p.config.APIKey}
+	alias := url.PathEscape(inc.key)
+
+	switch inc.action {
+	case incidentAcknowledge:
+		return postJSON(ctx, p.client, base+"/v2/alerts/"+alias+"/acknowledge?identifierType=alias", headers,
+			map[string]string{"source": p.host, "note": inc.event.Message})
+	case incidentResolve:
+		return postJSON(ctx, p.client, base+"/v2/alerts/"+alias+"/close?identifierType=alias", headers,
+			map[string]string{"source": p.host, "note": inc.event.Message})
+	}
+
+	priority := p.config.Priority
+	if priority == "" {
+		priority = "P2"
+	}
+	details := map[string]string{"event": inc.event.Type}
+	for k, v := range eventHost(inc.event).details() {
+		details[k] = v
+	}
+	for k, v := range inc.event.Details {
+		details[k] = v
+	}
+	if inc.event.CorrelationID != "" {
+		details["correlation_id"] = inc.event.CorrelationID
+	}
+	return postJSON(ctx, p.client, base+"/v2/alerts", headers, map[string]interface{}{
+		"message":     truncate(incidentSummary(p.host, inc.event), 130),
+		"alias":       truncate(inc.key, 512),
+		"description": inc.event.Message,
+		"source":      p.host,
+		"entity":      inc.event.Process,
+		"priority":    priority,
+		"tags":        p.config.Tags,
+		"details":     details,
+	})
+}
+
+// truncate shortens s to at most n bytes for APIs with field limits
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
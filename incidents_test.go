@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncidentLifecycle(t *testing.T) {
+	m := newIncidentManager(IncidentsConfig{Events: []string{"operation_timeout"}})
+	m.host = "host1"
+	steps := []struct {
+		event  Event
+		action string // 为空表示不发送
+	}{
+		{Event{Type: "circuit_closed", Process: "a"}, ""}, // 没有打开的告警
+		{Event{Type: "circuit_open", Severity: SeverityCritical, Process: "a"}, incidentTrigger},
+		{Event{Type: "circuit_half_open", Severity: SeverityWarning, Process: "a"}, incidentAcknowledge},
+		{Event{Type: "circuit_half_open", Severity: SeverityWarning, Process: "a"}, ""},
+		{Event{Type: "circuit_closed", Process: "b"}, ""},
+		{Event{Type: "circuit_closed", Process: "a"}, incidentResolve},
+		{Event{Type: "circuit_closed", Process: "a"}, ""},
+		{Event{Type: "monitor_panic", Severity: SeverityCritical}, incidentTrigger},
+		{Event{Type: "operation_timeout", Severity: SeverityWarning}, incidentTrigger},
+		{Event{Type: "config_reloaded", Severity: SeverityInfo}, ""},
+	}
+	for i, step := range steps {
+		inc, ok := m.transition(step.event)
+		got := ""
+		if ok {
+			got = inc.action
+		}
+		if got != step.action {
+			t.Errorf("step %d (%s %s): action = %q, want %q", i, step.event.Type, step.event.Process, got, step.action)
+		}
+	}
+	if inc, _ := m.transition(Event{Type: "safe_mode_entered", Severity: SeverityCritical}); inc.key != "processmonitor/host1/safe_mode" {
+		t.Errorf("dedup key = %q", inc.key)
+	}
+}
+
+func TestIncidentProviders(t *testing.T) {
+	type request struct {
+		path string
+		auth string
+		body map[string]interface{}
+	}
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		requests <- request{r.URL.RequestURI(), r.Header.Get("Authorization"), body}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	m := newIncidentManager(IncidentsConfig{
+		PagerDuty: PagerDutyConfig{RoutingKey: "rk", URL: srv.URL + "/v2/enqueue"},
+		OpsGenie:  OpsGenieConfig{APIKey: "gk", URL: srv.URL},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	m.HandleEvent(Event{Type: "circuit_open", Severity: SeverityCritical, Process: "api.exe", Message: "stopped restarting", Time: time.Now()})
+	m.HandleEvent(Event{Type: "circuit_closed", Severity: SeverityInfo, Process: "api.exe", Message: "stable again"})
+
+	var got []request
+	for len(got) < 4 {
+		select {
+		case r := <-requests:
+			got = append(got, r)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d requests, want 4", len(got))
+		}
+	}
+
+	if got[0].path != "/v2/enqueue" || got[0].body["event_action"] != "trigger" || got[0].body["routing_key"] != "rk" {
+		t.Errorf("unexpected PagerDuty trigger: %+v", got[0])
+	}
+	if got[1].path != "/v2/alerts" || got[1].auth != "GenieKey gk" || got[1].body["entity"] != "api.exe" {
+		t.Errorf("unexpected OpsGenie create: %+v", got[1])
+	}
+	if got[2].body["event_action"] != "resolve" || got[2].body["dedup_key"] != got[0].body["dedup_key"] {
+		t.Errorf("unexpected PagerDuty resolve: %+v", got[2])
+	}
+	if !strings.HasSuffix(got[3].path, "/close?identifierType=alias") || !strings.Contains(got[3].path, "api.exe") {
+		t.Errorf("unexpected OpsGenie close: %+v", got[3])
+	}
+}
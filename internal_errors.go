@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultErrorDigestInterval = 3600
+	errorDigestBatch           = time.Minute // 第一条错误之后等待的时间，同时发生的错误汇总到一个事件中
+	errorDigestMaxKinds        = 20          // 汇总事件中列出的错误种类数
+)
+
+// internalErrorKind counts the occurrences of one kind of internal error;
+// messages differing only in numbers (PIDs, ports, error codes) are one kind
+type internalErrorKind struct {
+	Component string
+	Message   string // 最近一次的完整消息
+	Count     int
+}
+
+// internalErrorLog aggregates the errors of the monitor itself (recovered
+// panics, registry API failures, commands that cannot be executed) between
+// two digest events, so operators learn the tool needs attention without
+// reading its debug log
+type internalErrorLog struct {
+	mu      sync.Mutex
+	kinds   map[string]*internalErrorKind
+	total   int
+	since   time.Time
+	pending chan struct{}
+}
+
+func newInternalErrorLog() *internalErrorLog {
+	return &internalErrorLog{kinds: make(map[string]*internalErrorKind), pending: make(chan struct{}, 1)}
+}
+
+var internalErrors = newInternalErrorLog()
+
+var digitsPattern = regexp.MustCompile(`\d+`)
+
+func (l *internalErrorLog) record(component, msg string) {
+	emitCount("monitor.internal_errors", 1, map[string]string{"component": component})
+	key := component + "\x00" + digitsPattern.ReplaceAllString(msg, "#")
+	l.mu.Lock()
+	if l.total == 0 {
+		l.since = time.Now()
+	}
+	l.total++
+	kind := l.kinds[key]
+	if kind == nil {
+		kind = &internalErrorKind{Component: component}
+		l.kinds[key] = kind
+	}
+	kind.Message = msg
+	kind.Count++
+	l.mu.Unlock()
+
+	select {
+	case l.pending <- struct{}{}:
+	default:
+	}
+}
+
+// digest returns the event summarizing the errors recorded since the last
+// digest and starts counting again; ok is false when there were none
+func (l *internalErrorLog) digest() (e Event, ok bool) {
+	l.mu.Lock()
+	kinds, total, since := l.kinds, l.total, l.since
+	l.kinds, l.total = make(map[string]*internalErrorKind), 0
+	l.mu.Unlock()
+	if total == 0 {
+		return Event{}, false
+	}
+
+	sorted := make([]*internalErrorKind, 0, len(kinds))
+	components := make(map[string]int)
+	for _, k := range kinds {
+		sorted = append(sorted, k)
+		components[k.Component] += k.Count
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Message < sorted[j].Message
+	})
+	var lines []string
+	for i, k := range sorted {
+		if i == errorDigestMaxKinds {
+			lines = append(lines, fmt.Sprintf("... and %d more kinds", len(sorted)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%dx %s: %s", k.Count, k.Component, k.Message))
+	}
+	names := make([]string, 0, len(components))
+	for c := range components {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	counts := make([]string, 0, len(names))
+	for _, c := range names {
+		counts = append(counts, c+"="+strconv.Itoa(components[c]))
+	}
+
+	return Event{
+		Severity: SeverityWarning,
+		Type:     "internal_errors",
+		Message:  fmt.Sprintf("The monitor had %d internal errors since %s (%s), most frequent: %s", total, since.Format(time.RFC3339), strings.Join(counts, ", "), sorted[0].Message),
+		Details: map[string]string{
+			"count":      strconv.Itoa(total),
+			"since":      since.Format(time.RFC3339),
+			"components": strings.Join(counts, ", "),
+			"errors":     strings.Join(lines, "\n"),
+		},
+	}, true
+}
+
+// internalErrorf logs an error of the monitor itself and records it for the
+// internal errors digest
+func internalErrorf(component, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logrus.Error(msg)
+	internalErrors.record(component, msg)
+}
+
+// isExecError reports whether err means a program could not be executed at
+// all (not found, permission denied), as opposed to exiting with an error
+func isExecError(err error) bool {
+	var execErr *exec.Error
+	var pathErr *fs.PathError
+	return errors.As(err, &execErr) || errors.As(err, &pathErr)
+}
+
+// runErrorDigest emits an internal_errors event after internal errors
+// occurred, at most once per self_monitor.error_digest_interval
+func runErrorDigest(config SelfMonitorConfig, ctx context.Context) {
+	if config.ErrorDigestInterval < 0 {
+		return
+	}
+	interval := seconds(defaultInt(config.ErrorDigestInterval, defaultErrorDigestInterval))
+	var last time.Time
+	for {
+		select {
+		case <-internalErrors.pending:
+		case <-ctx.Done():
+			return
+		}
+		wait := errorDigestBatch
+		if next := time.Until(last.Add(interval)); next > wait {
+			wait = next
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		if e, ok := internalErrors.digest(); ok {
+			emitEvent(e)
+			last = time.Now()
+		}
+	}
+}
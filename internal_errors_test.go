@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestInternalErrorDigest(t *testing.T) {
+	l := newInternalErrorLog()
+	if _, ok := l.digest(); ok {
+		t.Fatal("digest without errors")
+	}
+	l.record("registry", "Failed to open registry key HKLM\\Software\\App: Access is denied (5)")
+	l.record("panic", "panic in file monitor app.conf: runtime error")
+	for pid := 100; pid < 103; pid++ {
+		l.record("exec", "Failed to start process worker (PID "+strings.Repeat("9", pid-99)+"): permission denied")
+	}
+
+	e, ok := l.digest()
+	if !ok {
+		t.Fatal("no digest after errors")
+	}
+	if e.Type != "internal_errors" || e.Details["count"] != "5" {
+		t.Errorf("event = %s, count %s", e.Type, e.Details["count"])
+	}
+	if got := e.Details["components"]; got != "exec=3, panic=1, registry=1" {
+		t.Errorf("components = %q", got)
+	}
+	lines := strings.Split(e.Details["errors"], "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "3x exec: ") {
+		t.Errorf("errors = %q, want the three exec errors grouped first", lines)
+	}
+	if _, ok := l.digest(); ok {
+		t.Error("errors reported twice")
+	}
+	select {
+	case <-l.pending:
+	default:
+		t.Error("recording did not wake up the digest loop")
+	}
+}
+
+func TestIsExecError(t *testing.T) {
+	if err := exec.Command("/nonexistent/program").Run(); !isExecError(err) {
+		t.Errorf("missing program: %v not an exec error", err)
+	}
+	if err := exec.Command("does-not-exist-anywhere").Run(); !isExecError(err) {
+		t.Errorf("program not in PATH: %v not an exec error", err)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := exec.Command("/bin/sh", "-c", "exit 3").Run(); isExecError(err) {
+		t.Errorf("exit status %v counted as exec error", err)
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 任务和步骤的状态
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+	JobSkipped   = "skipped" // 只用于步骤：之前的步骤失败或任务被取消
+)
+
+const (
+	maxFinishedJobs          = 100 // 保留的已结束任务数量
+	defaultRollingWait       = 60  // rolling_restart 等待每个进程恢复运行的时间（秒）
+	rollingRestartPollPeriod = 500 * time.Millisecond
+)
+
+// JobRequest is the body of POST /jobs
+type JobRequest struct {
+	Operation   string `json:"operation"`              // group_start, group_stop, group_restart, rolling_restart, reload
+	Group       string `json:"group,omitempty"`        // group_* 和 rolling_restart 的进程组（all 为所有进程）
+	Delay       int    `json:"delay,omitempty"`        // rolling_restart：每个进程恢复后等待多少秒再重启下一个
+	WaitTimeout int    `json:"wait_timeout,omitempty"` // rolling_restart：等待每个进程恢复运行且健康的时间（秒，默认60）
+}
+
+// JobStep is one step of a job, such as the restart of one process
+type JobStep struct {
+	Name     string     `json:"name"`
+	State    string     `json:"state"`
+	Error    string     `json:"error,omitempty"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+
+	run func(ctx context.Context) error
+}
+
+// Job is a long-running operation started through the API. Its steps run
+// in order; the job stops at the first failed step.
+type Job struct {
+	ID        string     `json:"id"`
+	Operation string     `json:"operation"`
+	Target    string     `json:"target,omitempty"`
+	State     string     `json:"state"`
+	Done      int        `json:"done"`  // 已完成的步骤数
+	Total     int        `json:"total"` // 步骤总数
+	Error     string     `json:"error,omitempty"`
+	Created   time.Time  `json:"created"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Steps     []JobStep  `json:"steps"`
+
+	cancel context.CancelFunc
+}
+
+// jobTracker runs the jobs of the API server and keeps them, so clients can
+// follow their progress and find out how far a failed operation got
+type jobTracker struct {
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string // 按创建顺序
+}
+
+func newJobTracker() *jobTracker {
+	return &jobTracker{jobs: make(map[string]*Job)}
+}
+
+// start runs steps as a new job in the background and returns its ID
+func (t *jobTracker) start(operation, target string, steps []JobStep) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        newCorrelationID(),
+		Operation: operation,
+		Target:    target,
+		State:     JobRunning,
+		Total:     len(steps),
+		Created:   time.Now(),
+		Steps:     steps,
+		cancel:    cancel,
+	}
+	for i := range job.Steps {
+		job.Steps[i].State = JobPending
+	}
+	t.mu.Lock()
+	t.jobs[job.ID] = job
+	t.order = append(t.order, job.ID)
+	t.pruneLocked()
+	t.mu.Unlock()
+
+	logrus.Infof("Job %s started: %s %s (%d steps)", job.ID, operation, target, len(steps))
+	go t.run(ctx, job)
+	return job.ID
+}
+
+func (t *jobTracker) run(ctx context.Context, job *Job) {
+	defer job.cancel()
+	for i := range job.Steps {
+		if ctx.Err() != nil {
+			t.finish(job, i, JobCancelled, "cancelled")
+			return
+		}
+		now := time.Now()
+		t.mu.Lock()
+		job.Steps[i].State, job.Steps[i].Started = JobRunning, &now
+		t.mu.Unlock()
+
+		err := runRecoveredErr("job "+job.Operation, func() error { return job.Steps[i].run(ctx) })
+
+		now = time.Now()
+		t.mu.Lock()
+		job.Steps[i].Finished = &now
+		if err != nil {
+			job.Steps[i].State, job.Steps[i].Error = JobFailed, err.Error()
+		} else {
+			job.Steps[i].State = JobSucceeded
+			job.Done++
+		}
+		t.mu.Unlock()
+		if err != nil {
+			if ctx.Err() != nil {
+				t.finish(job, i+1, JobCancelled, "cancelled")
+			} else {
+				t.finish(job, i+1, JobFailed, fmt.Sprintf("%s: %v", job.Steps[i].Name, err))
+			}
+			return
+		}
+	}
+	t.finish(job, len(job.Steps), JobSucceeded, "")
+}
+
+// finish ends job; the steps from skipFrom on did not run
+func (t *jobTracker) finish(job *Job, skipFrom int, state, msg string) {
+	now := time.Now()
+	t.mu.Lock()
+	for i := skipFrom; i < len(job.Steps); i++ {
+		job.Steps[i].State = JobSkipped
+	}
+	job.State, job.Error, job.Finished = state, msg, &now
+	t.mu.Unlock()
+	switch state {
+	case JobSucceeded:
+		logrus.Infof("Job %s (%s %s) succeeded", job.ID, job.Operation, job.Target)
+	default:
+		logrus.Warnf("Job %s (%s %s) %s after %d of %d steps: %s", job.ID, job.Operation, job.Target, state, job.Done, job.Total, msg)
+	}
+}
+
+// pruneLocked forgets the oldest finished jobs beyond maxFinishedJobs
+func (t *jobTracker) pruneLocked() {
+	finished := 0
+	for _, id := range t.order {
+		if t.jobs[id].State != JobRunning {
+			finished++
+		}
+	}
+	kept := t.order[:0]
+	for _, id := range t.order {
+		if finished > maxFinishedJobs && t.jobs[id].State != JobRunning {
+			delete(t.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	t.order = kept
+}
+
+// get returns a copy of a job
+func (t *jobTracker) get(id string) (Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	c := *job
+	c.Steps = append([]JobStep(nil), job.Steps...)
+	return c, true
+}
+
+// list returns copies of the jobs, newest first
+func (t *jobTracker) list() []Job {
+	t.mu.Lock()
+	ids := append([]string(nil), t.order...)
+	t.mu.Unlock()
+	jobs := make([]Job, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		if job, ok := t.get(ids[i]); ok {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// cancel stops a running job after its current step
+func (t *jobTracker) cancel(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	if ok {
+		job.cancel()
+	}
+	return ok
+}
+
+// cancelAll cancels every running job when the monitor stops
+func (t *jobTracker) cancelAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, job := range t.jobs {
+		job.cancel()
+	}
+}
+
+// runRecoveredErr runs fn, turning a panic into an error: a job step that
+// panics fails the job instead of the API server
+func runRecoveredErr(name string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Errorf("Panic in %s: %v\n%s", name, r, debug.Stack())
+			internalErrors.record("panic", fmt.Sprintf("panic in %s: %v", name, r))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// jobSteps returns the steps of the operation requested by req
+func (s *APIServer) jobSteps(req JobRequest, remote string) (target string, steps []JobStep, err error) {
+	reason := fmt.Sprintf("API job from %s", remote)
+	switch {
+	case strings.HasPrefix(req.Operation, "group_"):
+		plan, err := s.manager.groupPlan(req.Group, strings.TrimPrefix(req.Operation, "group_"))
+		if err != nil {
+			return "", nil, err
+		}
+		reason = fmt.Sprintf("group %s %s (%s)", req.Group, strings.TrimPrefix(req.Operation, "group_"), reason)
+		for _, step := range plan {
+			step := step
+			steps = append(steps, JobStep{Name: step.String(), run: func(ctx context.Context) error {
+				return s.manager.runGroupStep(ctx, step, reason)
+			}})
+		}
+		return req.Group, steps, nil
+	case req.Operation == "rolling_restart":
+		if req.Delay < 0 || req.WaitTimeout < 0 {
+			return "", nil, fmt.Errorf("delay and wait_timeout must not be negative")
+		}
+		order, err := s.manager.groupOrder(req.Group)
+		if err != nil {
+			return "", nil, err
+		}
+		reason = fmt.Sprintf("rolling restart of %s (%s)", req.Group, reason)
+		wait := seconds(defaultInt(req.WaitTimeout, defaultRollingWait))
+		for i, name := range order {
+			name, last := name, i == len(order)-1
+			steps = append(steps, JobStep{Name: "restart " + name, run: func(ctx context.Context) error {
+				if err := s.manager.runGroupStep(ctx, groupStep{"restart", name}, reason); err != nil {
+					return err
+				}
+				if err := s.manager.waitHealthy(ctx, name, wait); err != nil {
+					return err
+				}
+				if last || req.Delay == 0 {
+					return nil
+				}
+				select {
+				case <-time.After(seconds(req.Delay)):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}})
+		}
+		return req.Group, steps, nil
+	case req.Operation == "reload":
+		if s.reload == nil {
+			return "", nil, fmt.Errorf("reload is not available")
+		}
+		return "", []JobStep{{Name: "reload", run: s.reload}}, nil
+	default:
+		return "", nil, fmt.Errorf("unknown operation %q: use group_start, group_stop, group_restart, rolling_restart or reload", req.Operation)
+	}
+}
+
+// waitHealthy waits until a process is running and not unhealthy, so a
+// rolling restart only moves on once the restarted instance serves again
+func (m *ProcessManager) waitHealthy(ctx context.Context, name string, timeout time.Duration) error {
+	sup, ok := m.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown process: %s", name)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		st := sup.Status()
+		if st.State == StateRunning && st.Health != HealthUnhealthy {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%s not running and healthy within %v (state %s, health %q)", name, timeout, st.State, st.Health)
+		}
+		select {
+		case <-time.After(rollingRestartPollPeriod):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// handleJobs serves POST /jobs (start a job), GET /jobs (all jobs, newest
+// first), GET /jobs/{id} (progress) and DELETE /jobs/{id} (cancel)
+func (s *APIServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		var req JobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid job request: " + err.Error()})
+			return
+		}
+		target, steps, err := s.jobSteps(req, r.RemoteAddr)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		s.writeJobAccepted(w, s.jobs.start(req.Operation, target, steps))
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.jobs.list())
+	case id == "":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	case r.Method == http.MethodGet || r.Method == http.MethodDelete:
+		if r.Method == http.MethodDelete && !s.jobs.cancel(id) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown job: " + id})
+			return
+		}
+		job, ok := s.jobs.get(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown job: " + id})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJobAccepted answers a request that started a job with 202 and the
+// job, whose Location is polled for progress
+func (s *APIServer) writeJobAccepted(w http.ResponseWriter, id string) {
+	job, _ := s.jobs.get(id)
+	w.Header().Set("Location", "/jobs/"+id)
+	w.Header().Set("Retry-After", strconv.Itoa(1))
+	writeJSON(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitJob polls a job until it is no longer running
+func waitJob(t *testing.T, jobs *jobTracker, id string) Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := jobs.get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.State != JobRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still running: %+v", id, job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobTracker(t *testing.T) {
+	jobs := newJobTracker()
+	var ran []string
+	step := func(name string, err error) JobStep {
+		return JobStep{Name: name, run: func(context.Context) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+
+	job := waitJob(t, jobs, jobs.start("group_restart", "web", []JobStep{step("stop api", nil), step("start api", nil)}))
+	if job.State != JobSucceeded || job.Done != 2 || job.Total != 2 || job.Finished == nil || job.Steps[1].State != JobSucceeded {
+		t.Errorf("succeeded job = %+v", job)
+	}
+
+	// 失败的步骤结束任务，之后的步骤不执行
+	ran = nil
+	job = waitJob(t, jobs, jobs.start("rolling_restart", "web", []JobStep{
+		step("restart db", nil), step("restart api", fmt.Errorf("not ready within 30s")), step("restart frontend", nil),
+	}))
+	states := []string{job.Steps[0].State, job.Steps[1].State, job.Steps[2].State}
+	if job.State != JobFailed || job.Done != 1 || !reflect.DeepEqual(states, []string{JobSucceeded, JobFailed, JobSkipped}) ||
+		job.Error != "restart api: not ready within 30s" || len(ran) != 2 {
+		t.Errorf("failed job = %+v, ran %v", job, ran)
+	}
+
+	// 取消在当前步骤结束后生效
+	release := make(chan struct{})
+	blocking := JobStep{Name: "stop db", run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}}
+	id := jobs.start("group_stop", "data", []JobStep{blocking, step("stop cache", nil)})
+	if !jobs.cancel(id) || jobs.cancel("unknown") {
+		t.Error("cancel() result wrong")
+	}
+	close(release)
+	job = waitJob(t, jobs, id)
+	if job.State != JobCancelled || job.Steps[1].State != JobSkipped {
+		t.Errorf("cancelled job = %+v", job)
+	}
+
+	// 步骤中的 panic 使任务失败
+	job = waitJob(t, jobs, jobs.start("reload", "", []JobStep{{Name: "reload", run: func(context.Context) error { panic("boom") }}}))
+	if job.State != JobFailed || !strings.Contains(job.Error, "boom") {
+		t.Errorf("panicking job = %+v", job)
+	}
+
+	if list := jobs.list(); len(list) != 4 || list[0].Operation != "reload" || list[3].Operation != "group_restart" {
+		t.Errorf("list() = %d jobs, first %s", len(list), list[0].Operation)
+	}
+}
+
+func TestJobTrackerPrune(t *testing.T) {
+	jobs := newJobTracker()
+	var last string
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		last = jobs.start("reload", "", []JobStep{{Name: "reload", run: func(context.Context) error { return nil }}})
+		waitJob(t, jobs, last)
+	}
+	jobs.start("reload", "", nil)
+	if n := len(jobs.list()); n > maxFinishedJobs+1 {
+		t.Errorf("%d jobs kept", n)
+	}
+	if _, ok := jobs.get(last); !ok {
+		t.Error("newest finished job was pruned")
+	}
+}
+
+func TestGroupPlan(t *testing.T) {
+	manager := NewProcessManager(Config{
+		Processes: []ProcessConfig{
+			{Name: "frontend", Enable: true, DependsOn: []string{"api"}, Labels: map[string]string{"tier": "web"}},
+			{Name: "api", Enable: true, DependsOn: []string{"db"}, Labels: map[string]string{"tier": "web"}},
+			{Name: "db", Enable: true},
+		},
+	})
+	steps, err := manager.groupPlan("all", "restart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(steps); got != "[stop frontend stop api stop db start db start api start frontend]" {
+		t.Errorf("restart plan = %s", got)
+	}
+	if _, err := manager.groupPlan("all", "reboot"); err == nil {
+		t.Error("unknown action accepted")
+	}
+	if _, err := manager.groupPlan("cache", "start"); err == nil {
+		t.Error("unknown group accepted")
+	}
+}
+
+func TestJobsAPI(t *testing.T) {
+	server := NewAPIServer(APIConfig{AdminToken: testAdminToken}, NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}}))
+	reloaded := make(chan struct{}, 1)
+	server.reload = func(context.Context) error {
+		reloaded <- struct{}{}
+		return nil
+	}
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, apiRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("/jobs", `{"operation": "reload"}`)
+	var job Job
+	json.Unmarshal(rec.Body.Bytes(), &job)
+	if rec.Code != http.StatusAccepted || job.ID == "" || rec.Header().Get("Location") != "/jobs/"+job.ID {
+		t.Fatalf("POST /jobs = %d %s", rec.Code, rec.Body)
+	}
+	<-reloaded
+	job = waitJob(t, server.jobs, job.ID)
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil || rec.Code != http.StatusOK || job.State != JobSucceeded || job.Steps[0].Name != "reload" {
+		t.Errorf("GET /jobs/{id} = %d %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct{ path, body, want string }{
+		{"/jobs", `{"operation": "format_disk"}`, "unknown operation"},
+		{"/jobs", `{"operation": "rolling_restart", "group": "cache"}`, "unknown group"},
+		{"/jobs", `{"operation": "rolling_restart", "group": "all", "delay": -1}`, "must not be negative"},
+		{"/jobs", `not json`, "invalid job request"},
+		{"/groups/all/reboot?async=1", ``, "unknown group action"},
+	} {
+		if rec := post(tt.path, tt.body); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("POST %s %s = %d %s, want %q", tt.path, tt.body, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, apiRequest(http.MethodDelete, "/jobs/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE unknown job = %d", rec.Code)
+	}
+}
+
+func TestWaitHealthy(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{{Name: "web.exe", Enable: true}}})
+	sup, _ := manager.Get("web.exe")
+	sup.updateStatus(func(st *ProcessStatus) { st.State, st.Health = StateRunning, HealthUnhealthy })
+	if err := manager.waitHealthy(context.Background(), "web.exe", 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "not running and healthy") {
+		t.Errorf("unhealthy: err = %v", err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		sup.updateStatus(func(st *ProcessStatus) { st.Health = "healthy" })
+	}()
+	if err := manager.waitHealthy(context.Background(), "web.exe", 5*time.Second); err != nil {
+		t.Errorf("recovered: err = %v", err)
+	}
+}
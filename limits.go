@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+// LimitsConfig 由操作系统强制执行的资源上限：超过时分配失败或被系统结束，
+// 不像 max_cpu_percent / max_memory_mb 那样需要持续超过阈值后才由监控程序重启
+type LimitsConfig struct {
+	MemoryMB   float64 `yaml:"memory_mb"`   // 内存上限（MB）：Windows 作业对象的提交内存上限，Linux cgroup v2 的 memory.max（无 cgroup v2 时为 RLIMIT_AS）
+	CPUPercent float64 `yaml:"cpu_percent"` // CPU速率上限（百分比，按单核计算，200 表示两个核）：Windows 作业对象的 CPU 速率控制，Linux cgroup v2 的 cpu.max
+	Cgroup     string  `yaml:"cgroup"`      // Linux：创建进程 cgroup 的上级目录（默认 /sys/fs/cgroup/processmonitor）
+}
+
+func (c LimitsConfig) enabled() bool {
+	return c.MemoryMB > 0 || c.CPUPercent > 0
+}
+
+func (c LimitsConfig) validate(config ProcessConfig) error {
+	switch {
+	case c.MemoryMB < 0:
+		return fmt.Errorf("limits.memory_mb must not be negative")
+	case c.CPUPercent < 0:
+		return fmt.Errorf("limits.cpu_percent must not be negative")
+	case c.MemoryMB > 0 && config.MaxMemoryMB >= c.MemoryMB:
+		// 硬上限先生效，max_memory_mb 永远不会触发
+		return fmt.Errorf("limits.memory_mb (%g) must be above max_memory_mb (%g)", c.MemoryMB, config.MaxMemoryMB)
+	case c.CPUPercent > 0 && config.MaxCPUPercent >= c.CPUPercent:
+		return fmt.Errorf("limits.cpu_percent (%g) must be above max_cpu_percent (%g)", c.CPUPercent, config.MaxCPUPercent)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultCgroupParent = "/sys/fs/cgroup/processmonitor"
+	cgroupCPUPeriod     = 100000 // cpu.max 的周期（微秒）
+)
+
+// applyLimits moves the child into a cgroup v2 group of its own with
+// memory.max and cpu.max set. Processes it creates from then on stay in the
+// group; children forked before the move (right after start) are not
+// limited. Without a usable cgroup v2 hierarchy the memory limit falls back
+// to RLIMIT_AS of the child, and the CPU limit cannot be applied.
+func applyLimits(cmd *exec.Cmd, config ProcessConfig) error {
+	limits := config.Limits
+	if !limits.enabled() {
+		return nil
+	}
+	pid := cmd.Process.Pid
+	dir, err := limitsCgroup(config, pid)
+	if err == nil {
+		if err = writeCgroupLimits(dir, limits); err == nil {
+			err = os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+		}
+		if err == nil {
+			logrus.Infof("Limited %s (PID: %d) with cgroup %s", config.Name, pid, dir)
+			return nil
+		}
+		os.Remove(dir)
+	}
+
+	if limits.MemoryMB <= 0 {
+		return fmt.Errorf("cgroup v2 is not usable, cpu_percent not applied: %v", err)
+	}
+	bytes := uint64(limits.MemoryMB * 1024 * 1024)
+	if rerr := unix.Prlimit(pid, unix.RLIMIT_AS, &unix.Rlimit{Cur: bytes, Max: bytes}, nil); rerr != nil {
+		return fmt.Errorf("cgroup v2 is not usable (%v) and RLIMIT_AS failed: %v", err, rerr)
+	}
+	if limits.CPUPercent > 0 {
+		return fmt.Errorf("cgroup v2 is not usable, cpu_percent not applied (memory limited with RLIMIT_AS): %v", err)
+	}
+	logrus.Infof("Limited address space of %s (PID: %d) to %g MB (cgroup v2 is not usable: %v)", config.Name, pid, limits.MemoryMB, err)
+	return nil
+}
+
+// limitsCgroup creates the cgroup of one instance under the cgroup parent,
+// enabling the memory and cpu controllers for it. Empty groups of earlier
+// instances of the process are removed.
+func limitsCgroup(config ProcessConfig, pid int) (string, error) {
+	parent := config.Limits.Cgroup
+	if parent == "" {
+		parent = defaultCgroupParent
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(parent), "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%s is not in a cgroup v2 hierarchy", parent)
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	controllers := cgroupControllers(config.Limits)
+	// 上级可能已经启用了这些控制器，失败时以 cgroup.controllers 为准
+	os.WriteFile(filepath.Join(filepath.Dir(parent), "cgroup.subtree_control"), []byte(controllers), 0644)
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(controllers), 0644); err != nil {
+		return "", fmt.Errorf("failed to enable %s in %s: %v", controllers, parent, err)
+	}
+
+	name := cgroupName(config.Name)
+	stale, _ := filepath.Glob(filepath.Join(parent, name+"-*"))
+	for _, dir := range stale {
+		if _, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), name+"-")); err != nil {
+			continue
+		}
+		// 仍有进程的 cgroup 不能删除
+		os.Remove(dir)
+	}
+	dir := filepath.Join(parent, name+"-"+strconv.Itoa(pid))
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return dir, nil
+}
+
+// cgroupControllers returns the subtree_control line enabling the
+// controllers the limits need
+func cgroupControllers(limits LimitsConfig) string {
+	var controllers []string
+	if limits.MemoryMB > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	if limits.CPUPercent > 0 {
+		controllers = append(controllers, "+cpu")
+	}
+	return strings.Join(controllers, " ")
+}
+
+// writeCgroupLimits writes memory.max and cpu.max of the cgroup dir
+func writeCgroupLimits(dir string, limits LimitsConfig) error {
+	if limits.MemoryMB > 0 {
+		bytes := int64(limits.MemoryMB * 1024 * 1024)
+		if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatInt(bytes, 10)), 0644); err != nil {
+			return fmt.Errorf("failed to set memory.max: %v", err)
+		}
+	}
+	if limits.CPUPercent > 0 {
+		quota := int64(limits.CPUPercent / 100 * cgroupCPUPeriod)
+		if quota < 1000 {
+			quota = 1000 // 内核要求的最小配额
+		}
+		value := fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)
+		if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(value), 0644); err != nil {
+			return fmt.Errorf("failed to set cpu.max: %v", err)
+		}
+	}
+	return nil
+}
+
+// cgroupName turns a process name (usually a path) into a cgroup directory name
+func cgroupName(name string) string {
+	name = strings.Trim(name, "/.")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCgroupLimits(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeCgroupLimits(dir, LimitsConfig{MemoryMB: 512, CPUPercent: 150}); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		"memory.max": "536870912",
+		"cpu.max":    "150000 100000",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q (%v), want %q", file, data, err, want)
+		}
+	}
+	if got := cgroupControllers(LimitsConfig{CPUPercent: 50}); got != "+cpu" {
+		t.Errorf("controllers = %q", got)
+	}
+}
+
+func TestCgroupName(t *testing.T) {
+	tests := map[string]string{
+		"/opt/app/server":  "opt_app_server",
+		"worker.sh":        "worker.sh",
+		"./bin/my app (2)": "bin_my_app__2_",
+	}
+	for name, want := range tests {
+		if got := cgroupName(name); got != want {
+			t.Errorf("cgroupName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// applyLimits is not supported on this platform
+func applyLimits(cmd *exec.Cmd, config ProcessConfig) error {
+	if !config.Limits.enabled() {
+		return nil
+	}
+	return fmt.Errorf("limits are only supported on Linux and Windows")
+}
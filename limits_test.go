@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestLimitsValidate(t *testing.T) {
+	tests := []struct {
+		config ProcessConfig
+		ok     bool
+	}{
+		{ProcessConfig{Limits: LimitsConfig{MemoryMB: 2048, CPUPercent: 150}}, true},
+		{ProcessConfig{MaxMemoryMB: 1500, Limits: LimitsConfig{MemoryMB: 2048}}, true},
+		{ProcessConfig{MaxMemoryMB: 2048, Limits: LimitsConfig{MemoryMB: 2048}}, false},
+		{ProcessConfig{MaxCPUPercent: 90, Limits: LimitsConfig{CPUPercent: 50}}, false},
+		{ProcessConfig{Limits: LimitsConfig{MemoryMB: -1}}, false},
+		{ProcessConfig{MaxMemoryMB: 512}, true},
+	}
+	for i, tt := range tests {
+		if err := tt.config.Limits.validate(tt.config); (err == nil) != tt.ok {
+			t.Errorf("case %d: err = %v", i, err)
+		}
+	}
+}
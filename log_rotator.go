@@ -0,0 +1,292 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LoggingConfig 监控程序自身日志文件的轮转和清理
+type LoggingConfig struct {
+	File       string `yaml:"file"`         // 日志文件（默认 processmonitor.log）
+	MaxSizeMB  int    `yaml:"max_size_mb"`  // 超过此大小时轮转（MB，默认100）
+	Daily      bool   `yaml:"daily"`        // 每天第一次写入时轮转前一天的日志（与按大小轮转同时生效）
+	MaxBackups int    `yaml:"max_backups"`  // 保留的轮转文件数量（0表示不限）
+	MaxAgeDays int    `yaml:"max_age_days"` // 删除超过多少天的轮转文件（默认31，-1表示不按时间删除）
+	Compress   bool   `yaml:"compress"`     // 用 gzip 压缩轮转后的文件
+}
+
+const (
+	defaultLogFile      = "processmonitor.log"
+	logBackupTimeFormat = "2006-01-02_15-04-05"
+)
+
+// logFile returns the log file of config, defaulted
+func (c LoggingConfig) logFile() string {
+	if c.File != "" {
+		return c.File
+	}
+	return defaultLogFile
+}
+
+// LogRotator writes the monitor's own log to a file, rotating it by size
+// and optionally by day. Rotated files are named <file>.<timestamp>, then
+// compressed and cleaned up in the background.
+type LogRotator struct {
+	filename   string
+	maxSize    int64
+	daily      bool
+	maxBackups int
+	maxAge     time.Duration // 0 表示不按时间删除
+	compress   bool
+	console    io.Writer
+	now        func() time.Time
+
+	mu          sync.Mutex
+	currentFile *os.File
+	size        int64
+	opened      time.Time // 当前文件内容开始的时间，用于按天轮转
+
+	// 压缩和清理在后台依次执行，不阻塞写日志
+	maintenance sync.Mutex
+	pending     sync.WaitGroup
+}
+
+// NewLogRotator creates the rotator for config
+func NewLogRotator(config LoggingConfig) *LogRotator {
+	lr := &LogRotator{
+		filename:   config.logFile(),
+		maxSize:    int64(defaultInt(config.MaxSizeMB, 100)) * 1024 * 1024,
+		daily:      config.Daily,
+		maxBackups: config.MaxBackups,
+		compress:   config.Compress,
+		console:    os.Stdout,
+		now:        time.Now,
+	}
+	if days := defaultInt(config.MaxAgeDays, 31); days > 0 {
+		lr.maxAge = time.Duration(days) * 24 * time.Hour
+	}
+	return lr
+}
+
+func (lr *LogRotator) Write(p []byte) (n int, err error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if lr.currentFile == nil {
+		if err := lr.open(); err != nil {
+			return 0, err
+		}
+	}
+	now := lr.now()
+	if lr.size > 0 && (lr.size+int64(len(p)) > lr.maxSize || lr.daily && !sameDay(lr.opened, now)) {
+		lr.rotate(now)
+		if err := lr.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	// Write to both file and console
+	n, err = lr.currentFile.Write(p)
+	lr.size += int64(n)
+	if err == nil && lr.console != nil {
+		lr.console.Write(p)
+	}
+	return n, err
+}
+
+// open opens the log file for appending; an existing file counts as started
+// when it was last written, so a daily rotation also covers restarts
+func (lr *LogRotator) open() error {
+	if dir := filepath.Dir(lr.filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(lr.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	lr.currentFile = file
+	lr.size = 0
+	lr.opened = lr.now()
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		lr.size = info.Size()
+		lr.opened = info.ModTime()
+	}
+	return nil
+}
+
+// rotate renames the current file to a timestamped backup. It runs inside
+// Write, so it must not log; the result is logged by the background work.
+func (lr *LogRotator) rotate(now time.Time) {
+	lr.currentFile.Close()
+	lr.currentFile = nil
+	lr.size = 0
+
+	// Create backup filename with timestamp
+	backupName := fmt.Sprintf("%s.%s", lr.filename, now.Format(logBackupTimeFormat))
+	for i := 1; fileExists(backupName) || fileExists(backupName+".gz"); i++ {
+		backupName = fmt.Sprintf("%s.%s-%d", lr.filename, now.Format(logBackupTimeFormat), i)
+	}
+
+	// Rename current log file to backup
+	renameErr := os.Rename(lr.filename, backupName)
+	lr.pending.Add(1)
+	go func() {
+		defer lr.pending.Done()
+		if renameErr != nil {
+			logrus.Errorf("Failed to rotate log file: %v", renameErr)
+			return
+		}
+		logrus.Infof("Log file rotated to: %s", backupName)
+		lr.maintain(backupName)
+	}()
+}
+
+// maintain compresses a new backup and removes the backups that exceed the
+// configured count or age
+func (lr *LogRotator) maintain(backup string) {
+	lr.maintenance.Lock()
+	defer lr.maintenance.Unlock()
+	if lr.compress && backup != "" {
+		if err := gzipFile(backup); err != nil {
+			logrus.Errorf("Failed to compress log file %s: %v", backup, err)
+		}
+	}
+	lr.cleanup()
+}
+
+// Cleanup removes old backups; it runs at startup and once a day
+func (lr *LogRotator) Cleanup() {
+	lr.maintain("")
+}
+
+func (lr *LogRotator) cleanup() {
+	backups, err := lr.backups()
+	if err != nil {
+		logrus.Errorf("Failed to read log directory: %v", err)
+		return
+	}
+	cutoff := lr.now().Add(-lr.maxAge)
+	for i, backup := range backups {
+		if (lr.maxBackups > 0 && i >= lr.maxBackups) || (lr.maxAge > 0 && backup.modTime.Before(cutoff)) {
+			if err := os.Remove(backup.path); err != nil {
+				logrus.Errorf("Failed to remove old log file %s: %v", backup.path, err)
+			} else {
+				logrus.Infof("Removed old log file: %s", backup.path)
+			}
+		}
+	}
+}
+
+type logBackup struct {
+	path    string
+	taken   time.Time
+	modTime time.Time
+}
+
+// backups lists the rotated files of the log, newest first. Only names
+// written by rotate are listed, so unrelated files next to the log are kept.
+func (lr *LogRotator) backups() ([]logBackup, error) {
+	dir, base := filepath.Dir(lr.filename), filepath.Base(lr.filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []logBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".gz")
+		if len(stamp) < len(logBackupTimeFormat) {
+			continue
+		}
+		taken, err := time.ParseInLocation(logBackupTimeFormat, stamp[:len(logBackupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, logBackup{path: filepath.Join(dir, name), taken: taken, modTime: info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].taken.Equal(backups[j].taken) {
+			return backups[i].taken.After(backups[j].taken)
+		}
+		return backups[i].path > backups[j].path
+	})
+	return backups, nil
+}
+
+// Close closes the log file and waits for pending compression and cleanup
+func (lr *LogRotator) Close() error {
+	lr.mu.Lock()
+	var err error
+	if lr.currentFile != nil {
+		err = lr.currentFile.Close()
+		lr.currentFile = nil
+	}
+	lr.mu.Unlock()
+	lr.pending.Wait()
+	return err
+}
+
+// gzipFile compresses path to path.gz and removes path, keeping its
+// modification time so age-based cleanup still works
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = info.ModTime()
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	in.Close()
+	return os.Remove(path)
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRotator(t *testing.T, config LoggingConfig, clock *time.Time) *LogRotator {
+	config.File = filepath.Join(t.TempDir(), "monitor.log")
+	lr := NewLogRotator(config)
+	lr.console = nil
+	lr.now = func() time.Time { return *clock }
+	return lr
+}
+
+func logFiles(t *testing.T, lr *LogRotator) []string {
+	entries, err := os.ReadDir(filepath.Dir(lr.filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestLogRotatorSizeAndBackups(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	lr := newTestRotator(t, LoggingConfig{MaxBackups: 2}, &clock)
+	lr.maxSize = 10
+
+	for i := 0; i < 4; i++ {
+		if _, err := lr.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+		lr.pending.Wait()
+		clock = clock.Add(time.Second)
+	}
+	lr.Close()
+
+	want := []string{"monitor.log", "monitor.log.2024-05-01_10-00-02", "monitor.log.2024-05-01_10-00-03"}
+	if got := logFiles(t, lr); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestLogRotatorDailyCompressed(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 23, 59, 0, 0, time.Local)
+	lr := newTestRotator(t, LoggingConfig{Daily: true, Compress: true}, &clock)
+
+	lr.Write([]byte("day one\n"))
+	lr.Write([]byte("still day one\n"))
+	clock = clock.Add(2 * time.Minute)
+	lr.Write([]byte("day two\n"))
+	lr.Close()
+
+	want := []string{"monitor.log", "monitor.log.2024-05-02_00-01-00.gz"}
+	got := logFiles(t, lr)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	f, err := os.Open(filepath.Join(filepath.Dir(lr.filename), want[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "day one\nstill day one\n" {
+		t.Errorf("compressed backup = %q", data)
+	}
+}
+
+func TestLogRotatorCleanupByAge(t *testing.T) {
+	clock := time.Now()
+	lr := newTestRotator(t, LoggingConfig{MaxAgeDays: 7}, &clock)
+	dir := filepath.Dir(lr.filename)
+	for name, age := range map[string]time.Duration{
+		"monitor.log.2024-01-01_00-00-00":    10 * 24 * time.Hour,
+		"monitor.log.2024-01-02_00-00-00.gz": 3 * 24 * time.Hour,
+		"monitor.log.lock":                   30 * 24 * time.Hour, // 不是轮转文件，不删除
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("x"), 0644)
+		modTime := clock.Add(-age)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	lr.Cleanup()
+	want := []string{"monitor.log.2024-01-02_00-00-00.gz", "monitor.log.lock"}
+	if got := logFiles(t, lr); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
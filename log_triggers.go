@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 日志触发器的动作
+const (
+	TriggerRestart = "restart" // 重启进程
+	TriggerCommand = "command" // 执行命令
+	TriggerAlert   = "alert"   // 只发出告警事件
+)
+
+const defaultTriggerCooldown = 300
+
+// LogTrigger 子进程输出的一行匹配正则表达式时执行的动作
+type LogTrigger struct {
+	Pattern  string `yaml:"pattern"`  // 正则表达式，如 "OutOfMemoryError"
+	Action   string `yaml:"action"`   // restart、command 或 alert
+	Command  string `yaml:"command"`  // action 为 command 时执行的命令（PM_REASON 为匹配的行）
+	Severity string `yaml:"severity"` // 告警事件的级别：info、warning（默认）或 critical
+	Cooldown int    `yaml:"cooldown"` // 同一触发器两次动作之间的最短间隔（秒，默认300）
+}
+
+// validate checks the action and pattern of a trigger
+func (t LogTrigger) validate() error {
+	if _, err := regexp.Compile(t.Pattern); err != nil || t.Pattern == "" {
+		return fmt.Errorf("invalid log trigger pattern %q", t.Pattern)
+	}
+	switch t.Action {
+	case TriggerRestart, TriggerAlert:
+	case TriggerCommand:
+		if t.Command == "" {
+			return fmt.Errorf("log trigger %q: command is empty", t.Pattern)
+		}
+	default:
+		return fmt.Errorf("log trigger %q: invalid action %q (use restart, command or alert)", t.Pattern, t.Action)
+	}
+	switch t.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("log trigger %q: invalid severity %q", t.Pattern, t.Severity)
+	}
+	if t.Cooldown < 0 {
+		return fmt.Errorf("log trigger %q: cooldown must not be negative", t.Pattern)
+	}
+	return nil
+}
+
+type compiledTrigger struct {
+	LogTrigger
+	re       *regexp.Regexp
+	cooldown time.Duration
+	lastFire time.Time
+}
+
+// logTriggerMonitor matches the output of one process against its triggers.
+// Restarts are handed to the supervisor's Run goroutine through wake, like
+// protocol state changes.
+type logTriggerMonitor struct {
+	config ProcessConfig
+	now    func() time.Time
+
+	mu            sync.Mutex
+	triggers      []*compiledTrigger
+	restartReason string // 等待 Run 处理的重启
+	wake          chan struct{}
+}
+
+// newLogTriggerMonitor returns nil when the process has no triggers
+func newLogTriggerMonitor(config ProcessConfig) *logTriggerMonitor {
+	if len(config.LogTriggers) == 0 {
+		return nil
+	}
+	m := &logTriggerMonitor{config: config, now: time.Now, wake: make(chan struct{}, 1)}
+	for _, t := range config.LogTriggers {
+		re, err := regexp.Compile(t.Pattern)
+		if err != nil {
+			// 配置校验已拒绝无效的表达式
+			continue
+		}
+		cooldown := defaultTriggerCooldown
+		if t.Cooldown > 0 {
+			cooldown = t.Cooldown
+		}
+		m.triggers = append(m.triggers, &compiledTrigger{LogTrigger: t, re: re, cooldown: time.Duration(cooldown) * time.Second})
+	}
+	return m
+}
+
+// wakeup returns the channel signalled when a trigger requests a restart;
+// nil (never ready) when the process has no triggers
+func (m *logTriggerMonitor) wakeup() <-chan struct{} {
+	if m == nil {
+		return nil
+	}
+	return m.wake
+}
+
+// takeRestart returns and clears the pending restart request
+func (m *logTriggerMonitor) takeRestart() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reason := m.restartReason
+	m.restartReason = ""
+	return reason
+}
+
+// reset drops a restart requested by the previous instance
+func (m *logTriggerMonitor) reset() {
+	m.takeRestart()
+}
+
+// handleLine runs the actions of every trigger matching line whose
+// cooldown has passed
+func (m *logTriggerMonitor) handleLine(line string, pid int) {
+	m.mu.Lock()
+	var fired []*compiledTrigger
+	now := m.now()
+	for _, t := range m.triggers {
+		if !t.re.MatchString(line) {
+			continue
+		}
+		if !t.lastFire.IsZero() && now.Sub(t.lastFire) < t.cooldown {
+			continue
+		}
+		t.lastFire = now
+		fired = append(fired, t)
+		if t.Action == TriggerRestart && m.restartReason == "" {
+			m.restartReason = fmt.Sprintf("log matched %q", t.Pattern)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, t := range fired {
+		m.fire(t.LogTrigger, line, pid)
+	}
+}
+
+func (m *logTriggerMonitor) fire(t LogTrigger, line string, pid int) {
+	name := m.config.Name
+	line = truncate(line, 1000)
+	severity := t.Severity
+	if severity == "" {
+		severity = SeverityWarning
+	}
+	logrus.Warnf("Process %s output matched log trigger %q (%s): %s", name, t.Pattern, t.Action, line)
+	emitCount("log_trigger.fired", 1, processTags(name))
+	emitEvent(Event{
+		Severity: severity,
+		Type:     "log_trigger",
+		Process:  name,
+		Message:  fmt.Sprintf("Process %s output matched %q: %s", name, t.Pattern, line),
+		Details: map[string]string{
+			"pattern": t.Pattern,
+			"action":  t.Action,
+			"line":    line,
+		},
+	})
+
+	switch t.Action {
+	case TriggerRestart:
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	case TriggerCommand:
+		// 在单独的 goroutine 中执行，不阻塞子进程的输出
+		go func() {
+			if err := runHook(m.config, "log_trigger", t.Command, line, pid); err != nil {
+				logrus.Errorf("Process %s: %v", name, err)
+			}
+		}()
+	}
+}
+
+// wrap routes stdout and stderr of a new instance through the triggers
+func (m *logTriggerMonitor) wrap(stdio processIO, pid func() int) processIO {
+	if m == nil {
+		return stdio
+	}
+	if stdio.Stdout == nil {
+		stdio.Stdout, stdio.Stderr = os.Stdout, os.Stderr
+	}
+	stdio.Stdout = &logTriggerWriter{m: m, next: stdio.Stdout, pid: pid}
+	stdio.Stderr = &logTriggerWriter{m: m, next: stdio.Stderr, pid: pid}
+	return stdio
+}
+
+// logTriggerWriter passes output on to next unchanged and matches each
+// complete line against the triggers
+type logTriggerWriter struct {
+	m    *logTriggerMonitor
+	next io.Writer
+	pid  func() int
+	buf  []byte
+}
+
+func (w *logTriggerWriter) Write(p []byte) (int, error) {
+	w.next.Write(p)
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.m.handleLine(string(bytes.TrimRight(w.buf[:i], "\r")), w.pid())
+		w.buf = w.buf[i+1:]
+	}
+	// 没有换行的超长输出按一行匹配，不无限累积
+	if len(w.buf) > maxChildLogLine {
+		w.m.handleLine(string(w.buf), w.pid())
+		w.buf = nil
+	}
+	return len(p), nil
+}
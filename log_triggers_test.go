@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLogTriggerValidate(t *testing.T) {
+	tests := []struct {
+		trigger LogTrigger
+		ok      bool
+	}{
+		{LogTrigger{Pattern: "OutOfMemoryError", Action: TriggerRestart}, true},
+		{LogTrigger{Pattern: "license expired", Action: TriggerAlert, Severity: SeverityCritical}, true},
+		{LogTrigger{Pattern: "disk full", Action: TriggerCommand, Command: "cleanup.cmd"}, true},
+		{LogTrigger{Pattern: "disk full", Action: TriggerCommand}, false},
+		{LogTrigger{Pattern: "(unclosed", Action: TriggerAlert}, false},
+		{LogTrigger{Pattern: "", Action: TriggerAlert}, false},
+		{LogTrigger{Pattern: "x", Action: "reboot"}, false},
+		{LogTrigger{Pattern: "x", Action: TriggerAlert, Severity: "fatal"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.trigger.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok=%v", tt.trigger, err, tt.ok)
+		}
+	}
+}
+
+func TestLogTriggerRestartCooldown(t *testing.T) {
+	m := newLogTriggerMonitor(ProcessConfig{Name: "app.exe", LogTriggers: []LogTrigger{
+		{Pattern: `java\.lang\.OutOfMemoryError`, Action: TriggerRestart, Cooldown: 60},
+		{Pattern: "license expired", Action: TriggerAlert},
+	}})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	var out bytes.Buffer
+	stdio := m.wrap(processIO{Stdout: &out, Stderr: &out}, func() int { return 42 })
+	stdio.Stderr.Write([]byte("Exception in thread \"main\" java.lang.OutOf"))
+	if m.takeRestart() != "" {
+		t.Fatal("partial line must not trigger")
+	}
+	stdio.Stderr.Write([]byte("MemoryError: Java heap space\r\nnext line\n"))
+	if out.String() != "Exception in thread \"main\" java.lang.OutOfMemoryError: Java heap space\r\nnext line\n" {
+		t.Errorf("output not passed through unchanged: %q", out.String())
+	}
+	select {
+	case <-m.wakeup():
+	default:
+		t.Error("restart trigger did not wake the supervisor")
+	}
+	if reason := m.takeRestart(); reason != `log matched "java\\.lang\\.OutOfMemoryError"` {
+		t.Errorf("restart reason = %q", reason)
+	}
+
+	// 冷却时间内再次匹配不重复重启
+	now = now.Add(30 * time.Second)
+	stdio.Stdout.Write([]byte("java.lang.OutOfMemoryError\n"))
+	if reason := m.takeRestart(); reason != "" {
+		t.Errorf("restart within cooldown: %q", reason)
+	}
+	now = now.Add(31 * time.Second)
+	stdio.Stdout.Write([]byte("java.lang.OutOfMemoryError\n"))
+	if reason := m.takeRestart(); reason == "" {
+		t.Error("no restart after cooldown")
+	}
+
+	// 只告警的触发器不请求重启
+	stdio.Stdout.Write([]byte("WARN license expired on 2024-01-01\n"))
+	if reason := m.takeRestart(); reason != "" {
+		t.Errorf("alert trigger requested a restart: %q", reason)
+	}
+}
@@ -4,10 +4,9 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -15,108 +14,14 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/shirou/gopsutil/v3/process"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/sys/windows"
 	"gopkg.in/yaml.v3"
 )
 
-// LogRotator handles log file rotation
-type LogRotator struct {
-	filename    string
-	maxSize     int64 // Maximum size in bytes
-	currentFile *os.File
-}
-
-func NewLogRotator(filename string, maxSize int64) *LogRotator {
-	return &LogRotator{
-		filename: filename,
-		maxSize:  maxSize,
-	}
-}
-
-func (lr *LogRotator) Write(p []byte) (n int, err error) {
-	// Check if we need to rotate
-	if lr.currentFile != nil {
-		if stat, err := lr.currentFile.Stat(); err == nil {
-			if stat.Size()+int64(len(p)) > lr.maxSize {
-				lr.rotate()
-			}
-		}
-	}
-
-	// Open file if not already open
-	if lr.currentFile == nil {
-		lr.currentFile, err = os.OpenFile(lr.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			return 0, err
-		}
-	}
-
-	// Write to both file and console
-	n, err = lr.currentFile.Write(p)
-	if err == nil {
-		fmt.Print(string(p)) // Also print to console
-	}
-	return n, err
-}
-
-func (lr *LogRotator) rotate() {
-	if lr.currentFile != nil {
-		lr.currentFile.Close()
-		lr.currentFile = nil
-	}
-
-	// Create backup filename with timestamp
-	now := time.Now()
-	backupName := fmt.Sprintf("%s.%s", lr.filename, now.Format("2006-01-02_15-04-05"))
-
-	// Rename current log file to backup
-	if err := os.Rename(lr.filename, backupName); err != nil {
-		logrus.Errorf("Failed to rotate log file: %v", err)
-		return
-	}
-
-	logrus.Infof("Log file rotated to: %s", backupName)
-}
-
-func (lr *LogRotator) Close() error {
-	if lr.currentFile != nil {
-		return lr.currentFile.Close()
-	}
-	return nil
-}
-
-// MonthlyCleanup removes log files older than 1 month
-func (lr *LogRotator) MonthlyCleanup() {
-	dir := filepath.Dir(lr.filename)
-	baseName := filepath.Base(lr.filename)
-
-	files, err := ioutil.ReadDir(dir)
-	if err != nil {
-		logrus.Errorf("Failed to read log directory: %v", err)
-		return
-	}
-
-	cutoff := time.Now().AddDate(0, -1, 0) // 1 month ago
-
-	for _, file := range files {
-		if strings.HasPrefix(file.Name(), baseName+".") && !file.IsDir() {
-			if file.ModTime().Before(cutoff) {
-				fullPath := filepath.Join(dir, file.Name())
-				if err := os.Remove(fullPath); err != nil {
-					logrus.Errorf("Failed to remove old log file %s: %v", fullPath, err)
-				} else {
-					logrus.Infof("Removed old log file: %s", fullPath)
-				}
-			}
-		}
-	}
-}
-
 // ConsoleHook sends logs to console as well as file
 type ConsoleHook struct{}
 
@@ -131,42 +36,150 @@ func (hook *ConsoleHook) Levels() []logrus.Level {
 
 // Config represents the configuration structure
 type Config struct {
-	Processes        []ProcessConfig   `yaml:"processes"`
-	RegistryMonitors []RegistryMonitor `yaml:"registry_monitors"`
+	Processes        []ProcessConfig        `yaml:"processes"`
+	RegistryMonitors []RegistryMonitor      `yaml:"registry_monitors"`
+	FileMonitors     []FileMonitor          `yaml:"file_monitors"`     // 文件完整性监控
+	ServiceMonitors  []ServiceMonitor       `yaml:"service_monitors"`  // Windows 服务监控
+	IIS              IISConfig              `yaml:"iis"`               // IIS 应用程序池监控
+	COMPlusApps      []COMPlusMonitor       `yaml:"complus_apps"`      // COM+ 服务器应用程序监控
+	Tasks            []TaskConfig           `yaml:"tasks"`             // 定时任务和启动时执行一次的命令
+	Groups           []GroupConfig          `yaml:"groups"`            // 命名进程组
+	API              APIConfig              `yaml:"api"`               // 内置HTTP服务（/healthz 等）
+	StatusPage       StatusPageConfig       `yaml:"status_page"`       // 只读公开状态页（单独端口）
+	SelfMonitor      SelfMonitorConfig      `yaml:"self_monitor"`      // 监控程序自身资源占用
+	PrivilegedHelper PrivilegedHelperConfig `yaml:"privileged_helper"` // 最小权限模式的特权助手
+	RegistryStatus   RegistryStatusConfig   `yaml:"registry_status"`   // 将进程状态发布到注册表
+	CommandQueue     CommandQueueConfig     `yaml:"command_queue"`     // 基于文件的命令队列
+	Control          ControlConfig          `yaml:"control"`           // 本机控制通道（Unix 域套接字或 Windows 命名管道）
+	Statsd           StatsdConfig           `yaml:"statsd"`            // statsd/DogStatsD 指标推送
+	NetworkHealth    NetworkHealthConfig    `yaml:"network_health"`    // 出站网络连通性探测
+	DiskHealth       DiskHealthConfig       `yaml:"disk_health"`       // 磁盘 SMART / 存储可靠性计数器检查
+	Firewall         FirewallConfig         `yaml:"firewall"`          // open_firewall 规则添加到的 nftables 表和链
+	Security         SecurityConfig         `yaml:"security"`          // 只报告安全模式与签名证据
+	ProcessTable     ProcessTableConfig     `yaml:"process_table"`     // 所有监控共用的进程表刷新间隔
+	Timeouts         TimeoutConfig          `yaml:"timeouts"`          // 进程枚举、结束进程和外部命令的超时
+	ConfigDrift      ConfigDriftConfig      `yaml:"config_drift"`      // 与标准配置（golden）比较，发现并还原本地修改
+	SafeMode         SafeModeConfig         `yaml:"safe_mode"`         // 主配置无效或资源不足时切换到的最小配置
+	ReloadInterval   int                    `yaml:"reload_interval"`   // 检查配置文件是否修改的间隔（秒，0表示不自动重新加载）
+	Teams            TeamsConfig            `yaml:"teams"`             // Microsoft Teams 通知
+	Incidents        IncidentsConfig        `yaml:"incidents"`         // PagerDuty / OpsGenie 事故
+	Notifications    NotificationsConfig    `yaml:"notifications"`     // 通用 HTTP Webhook 通知
+	FailureSnapshot  FailureSnapshotConfig  `yaml:"failure_snapshot"`  // 故障重启时采集主机环境快照
+	Logging          LoggingConfig          `yaml:"logging"`           // 监控程序日志文件的轮转和清理
+	EventHistory     EventHistoryConfig     `yaml:"event_history"`     // 事件历史持久化
+	Storage          StorageConfig          `yaml:"storage"`           // 事件历史、进程状态和指标历史的持久化后端
+	Host             HostConfig             `yaml:"host"`              // 附加到事件、指标和通知中的主机信息
 }
 
 // ProcessConfig represents the configuration for a single process
 type ProcessConfig struct {
-	Name             string   `yaml:"name"`
-	Enable           bool     `yaml:"enable"` // 新增：是否启用此监控配置
-	Args             []string `yaml:"args"`
-	RestartCommand   string   `yaml:"restart_command"` // 重启时使用的程序路径
-	WorkDir          string   `yaml:"work_dir"`        // 程序的工作目录
-	Ports            []int    `yaml:"ports"`
-	HealthChecks     []string `yaml:"health_checks"`
-	CheckInterval    int      `yaml:"check_interval"`
-	RestartDelay     int      `yaml:"restart_delay"`
-	KillOnExit       bool     `yaml:"kill_on_exit"`
-	ExcludeProcesses []string `yaml:"exclude_processes"` // 进程排斥列表
+	Name             string            `yaml:"name"`
+	Enable           bool              `yaml:"enable"` // 新增：是否启用此监控配置
+	Args             []string          `yaml:"args"`
+	RestartCommand   string            `yaml:"restart_command"` // 重启时使用的程序路径
+	WorkDir          string            `yaml:"work_dir"`        // 程序的工作目录
+	Ports            []int             `yaml:"ports"`
+	PortChecks       []PortCheck       `yaml:"port_checks"`       // 非 TCP 的端口检查：udp:<端口>、unix:<套接字路径> 或 pipe:<管道名>
+	HealthChecks     []HealthCheck     `yaml:"health_checks"`     // 健康检查：HTTP、tcp:、cmd: 或 script:
+	FailureThreshold int               `yaml:"failure_threshold"` // 健康检查连续失败多少次后重启（默认1）
+	SuccessThreshold int               `yaml:"success_threshold"` // 不健康后连续成功多少次才视为恢复（默认1）
+	CheckInterval    int               `yaml:"check_interval"`
+	CheckConcurrency int               `yaml:"check_concurrency"` // 同时执行的端口和健康检查数量（默认4，1表示依次执行）
+	CheckDeadline    int               `yaml:"check_deadline"`    // 一轮端口和健康检查的总截止时间（秒，默认为 check_interval），未完成的检查记为失败
+	StartupTimeout   int               `yaml:"startup_timeout"`   // 启动后必须在多少秒内通过就绪检查，否则视为启动失败（0表示启动后固定等待2秒）
+	ReadinessChecks  []HealthCheck     `yaml:"readiness_checks"`  // 启动时的就绪检查（为空则使用 ports、port_checks 和 health_checks）
+	RestartDelay     int               `yaml:"restart_delay"`
+	KillOnExit       bool              `yaml:"kill_on_exit"`
+	KillProcessTree  bool              `yaml:"kill_process_tree"` // 停止或重启时同时结束进程启动的子进程（Windows 作业对象，Linux 进程组）
+	ProcessGroup     string            `yaml:"process_group"`     // 子进程的进程组（仅Linux）：group（默认，setpgid）、session（setsid）或 inherit
+	OnMonitorDeath   string            `yaml:"on_monitor_death"`  // 监控程序意外退出时子进程的处理：detach（继续运行，重启后接管）、kill 或 term（默认与以前相同）
+	ExcludeProcesses []string          `yaml:"exclude_processes"` // 进程排斥列表
+	CPUProfile       CPUProfileConfig  `yaml:"cpu_profile"`       // CPU持续过高时自动采样
+	Labels           map[string]string `yaml:"labels"`            // 标签，用于进程组选择
+	DependsOn        []string          `yaml:"depends_on"`        // 依赖的进程（组操作时先启动依赖）
+	KeepStdin        bool              `yaml:"keep_stdin"`        // 保持子进程标准输入连接，可通过API发送命令行
+
+	User                string   `yaml:"user"`                 // 以此用户（用户名或UID）运行子进程（仅Linux，需要root）
+	Group               string   `yaml:"group"`                // 子进程的主组（组名或GID，默认为 user 的主组，仅Linux）
+	RunAsUser           string   `yaml:"run_as_user"`          // 以此账户运行子进程：DOMAIN\user、user@domain 或本机用户名（仅Windows）
+	RunAsPassword       string   `yaml:"run_as_password"`      // run_as_user 的密码（建议加密配置文件）
+	RunAsLogon          string   `yaml:"run_as_logon"`         // run_as_user 的登录类型：interactive（默认）、batch 或 service
+	Umask               string   `yaml:"umask"`                // 子进程的 umask（八进制，如 "0002"，仅Linux）
+	SupplementaryGroups []string `yaml:"supplementary_groups"` // 子进程的附加组（组名或GID，仅Linux，需要root）
+	SELinuxContext      string   `yaml:"selinux_context"`      // 子进程的 SELinux 上下文（仅Linux）
+	AppArmorProfile     string   `yaml:"apparmor_profile"`     // 子进程的 AppArmor 配置文件（仅Linux）
+	IntegrityLevel      string   `yaml:"integrity_level"`      // 子进程的完整性级别：low 或 medium（仅Windows）
+	RestrictedToken     bool     `yaml:"restricted_token"`     // 使用去除管理员组和特权的受限令牌启动（仅Windows）
+
+	OnUnhealthy string `yaml:"on_unhealthy"` // 健康状态变为异常时执行的命令（在重启之前）
+	OnHealthy   string `yaml:"on_healthy"`   // 健康状态恢复正常时执行的命令
+	HookTimeout int    `yaml:"hook_timeout"` // 钩子命令超时（秒，默认30）
+
+	NetworkDependent bool              `yaml:"network_dependent"` // 依赖网络：网络探测失败时暂停重启
+	WindowCheck      WindowCheckConfig `yaml:"window_check"`      // 主窗口无响应检查（仅Windows）
+	CrashLoop        CrashLoopConfig   `yaml:"crash_loop"`        // 崩溃循环检测与输入隔离
+	RestartProfiles  []RestartProfile  `yaml:"restart_profiles"`  // 按失败类型或手动指定选用的替代启动命令、参数和钩子
+
+	LogFile    string `yaml:"log_file"`     // 子进程标准输出/错误写入的日志文件（为空则输出到控制台）
+	LogMaxSize int    `yaml:"log_max_size"` // 单个日志文件最大大小（MB，默认10）
+	LogBackups int    `yaml:"log_backups"`  // 保留的备份数量（默认5）
+
+	LogTriggers []LogTrigger `yaml:"log_triggers"` // 子进程输出匹配正则表达式时重启、执行命令或告警
+
+	StopSignal  string `yaml:"stop_signal"`  // 优雅停止信号：SIGTERM/SIGINT 等；Windows 上为 WM_CLOSE 或 CTRL_BREAK
+	StopCommand string `yaml:"stop_command"` // 优雅停止命令（优先于 stop_signal）
+	StopTimeout int    `yaml:"stop_timeout"` // 等待优雅停止的时间，超时后强制结束（秒，默认10）
+
+	RestartPolicy      RestartPolicyConfig `yaml:"restart_policy"`        // 自动重启的指数退避与熔断
+	RestartOnExitCodes []int               `yaml:"restart_on_exit_codes"` // 只有以这些退出码退出时才重启（为空表示除 ignore_exit_codes 外都重启）
+	IgnoreExitCodes    []int               `yaml:"ignore_exit_codes"`     // 以这些退出码退出视为有意退出，不重启（如 0 或计划维护的退出码）
+
+	SessionMode     string `yaml:"session_mode"`     // Windows会话：per_session（每个活动会话一个实例）或 session0（仅服务会话）
+	SessionInterval int    `yaml:"session_interval"` // per_session 模式下检查会话登录/注销的间隔（秒，默认10）
+
+	MatchMode    string `yaml:"match_mode"`    // 识别已运行实例的方式：substring（默认）、exact 或 regex
+	MatchPattern string `yaml:"match_pattern"` // match_mode 为 regex 时匹配程序路径或命令行的正则表达式
+	PIDFile      string `yaml:"pid_file"`      // 记录当前实例PID的文件，监控程序重启后据此接管进程
+	Adopt        bool   `yaml:"adopt"`         // 接管启动前已在运行的实例：按PID跟踪，停止/重启/kill_on_exit 与自己启动的进程相同
+
+	Schedule ScheduleConfig `yaml:"schedule"` // 运行时间段与节假日日历，之外停止进程
+
+	DiskQuota DiskQuotaConfig `yaml:"disk_quota"` // 数据/日志目录大小的告警阈值和清理命令
+
+	OpenFirewall bool `yaml:"open_firewall"` // 启动后为 ports 创建防火墙放行规则（Windows 防火墙或 nftables），监控程序停止进程时删除
+
+	PortReserve  PortReserveConfig  `yaml:"port_reserve"`  // 进程停止期间由监控程序占用端口（HTTP 返回503）
+	PortConflict PortConflictConfig `yaml:"port_conflict"` // 启动前端口已被占用时等待释放、结束旧实例或不启动
+
+	Standby StandbyConfig `yaml:"standby"` // 保持一个空闲的备用实例，主实例故障时提升它
+
+	AdminAPI AdminAPIConfig `yaml:"admin_api"` // 通过监控程序的 HTTP 接口转发子进程管理接口（路径白名单，需要 api.admin_token）
+
+	Limits LimitsConfig `yaml:"limits"` // 由操作系统强制执行的内存和CPU上限（Windows 作业对象，Linux cgroup v2）
+
+	MaxCPUPercent    float64 `yaml:"max_cpu_percent"`   // CPU使用率上限（百分比，按单核计算，0表示不检查）
+	MaxMemoryMB      float64 `yaml:"max_memory_mb"`     // 内存（RSS）上限（MB，0表示不检查）
+	SustainedSeconds int     `yaml:"sustained_seconds"` // 持续超过上限多长时间后处理（秒，默认60）
+	ResourceAction   string  `yaml:"resource_action"`   // 超过上限时：restart（默认）或 alert（只告警）
+
+	NetNS NetNSConfig `yaml:"netns"` // 在独立的网络命名空间中运行并转发端口（仅Linux）
+
+	Protocol ProtocolConfig `yaml:"protocol"` // 子进程以 JSON 行报告就绪、健康和停止确认
+
+	WaitForFile       []string `yaml:"wait_for_file"`       // 首次启动前等待这些文件存在
+	WaitForPort       []string `yaml:"wait_for_port"`       // 首次启动前等待这些端口可以连接（host:port，只写端口时为本机）
+	WaitForHTTP       []string `yaml:"wait_for_http"`       // 首次启动前等待这些地址返回200
+	WaitTimeout       int      `yaml:"wait_timeout"`        // 最长等待时间（秒，默认300）
+	WaitTimeoutAction string   `yaml:"wait_timeout_action"` // 超时后：start（仍然启动，默认）或 stop（保持停止）
+
+	sessionScoped bool          // 只匹配和启动指定会话中的实例
+	sessionID     uint32        // sessionScoped 时的会话ID
+	ignorePID     *atomic.Int32 // 按名称匹配时忽略的PID（standby 的另一个实例）
 }
 
 // isProcessRunning checks if a process is running by name
 func isProcessRunning(name string) (bool, error) {
-	processes, err := process.Processes()
-	if err != nil {
-		return false, err
-	}
-
-	processName := filepath.Base(name)
-	for _, p := range processes {
-		exe, _ := p.Exe()
-		cmdline, _ := p.Cmdline()
-		// Check both executable path and command line
-		if strings.Contains(exe, processName) || strings.Contains(cmdline, processName) {
-			return true, nil
-		}
-	}
-	return false, nil
+	return isConfigRunning(ProcessConfig{Name: name})
 }
 
 // checkExcludeProcesses 检查排斥进程列表中的进程是否存在
@@ -175,25 +188,29 @@ func checkExcludeProcesses(excludeProcesses []string) (bool, []string) {
 		return false, nil
 	}
 
-	processes, err := process.Processes()
-	if err != nil {
-		logrus.Errorf("Failed to get process list: %v", err)
-		return false, nil
-	}
-
 	var foundProcesses []string
 
-	for _, excludeName := range excludeProcesses {
-		processName := filepath.Base(excludeName)
-		for _, p := range processes {
-			exe, _ := p.Exe()
-			cmdline, _ := p.Cmdline()
-			// Check both executable path and command line
-			if strings.Contains(exe, processName) || strings.Contains(cmdline, processName) {
-				foundProcesses = append(foundProcesses, excludeName)
-				break
+	err := runWithTimeout("exclude process scan", seconds(opTimeouts.ProcessScan), func(ctx context.Context) error {
+		entries, err := procTable.snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		var found []string
+		for _, excludeName := range excludeProcesses {
+			processName := filepath.Base(excludeName)
+			for _, e := range entries {
+				if processMatches(e, processName) && processAlive(ctx, e) {
+					found = append(found, excludeName)
+					break
+				}
 			}
 		}
+		foundProcesses = found
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("Failed to get process list: %v", err)
+		return false, nil
 	}
 
 	return len(foundProcesses) > 0, foundProcesses
@@ -211,23 +228,20 @@ func isPortInUse(port int) bool {
 	return false
 }
 
-// isHealthCheckOK performs HTTP health check
-func isHealthCheckOK(url string) bool {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-	resp, err := client.Get(url)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
+// processIO holds the standard streams of a child; nil fields use the defaults
+type processIO struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Protocol *os.File // 监督协议管道的写端（channel: pipe）
+	Env      []string // 监控程序附加的环境变量（如 PM_CORRELATION_ID）
 }
 
 // startProcess starts a new process
-func startProcess(config ProcessConfig, isRestart bool) (*exec.Cmd, error) {
+func startProcess(config ProcessConfig, isRestart bool, stdio processIO) (*exec.Cmd, error) {
 	// 检查进程是否已经在运行
-	running, err := isProcessRunning(config.Name)
+	running, err := isConfigRunning(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if process is running: %v", err)
 	}
@@ -273,192 +287,47 @@ func startProcess(config ProcessConfig, isRestart bool) (*exec.Cmd, error) {
 	}
 
 	// Set process attributes to prevent automatic termination when parent exits
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	if err := setProcessAttributes(cmd, config); err != nil {
+		return nil, fmt.Errorf("failed to set process attributes: %v", err)
+	}
+	if err := applyNetNS(cmd, config); err != nil {
+		return nil, err
+	}
+	if stdio.Protocol != nil {
+		if err := attachProtocolPipe(cmd, stdio.Protocol); err != nil {
+			return nil, err
 		}
 	}
+	if len(stdio.Env) > 0 {
+		cmd.Env = append(cmd.Environ(), stdio.Env...)
+	}
 
+	cmd.Stdin = stdio.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	err = cmd.Start()
+	if stdio.Stdout != nil {
+		cmd.Stdout = stdio.Stdout
+		cmd.Stderr = stdio.Stderr
+		// 孙进程继承输出管道时，不让 Wait 因等待管道关闭而卡住
+		cmd.WaitDelay = 5 * time.Second
+	}
+	err = startCommand(cmd, config)
+	if err == nil {
+		// 下一次检查必须看到新进程，不能使用启动前的进程表
+		procTable.invalidate()
+		if err := attachProcessTree(cmd, config); err != nil {
+			logrus.Warnf("Process %s started, but its child processes will not be stopped with it: %v", config.Name, err)
+		}
+		if err := applyLimits(cmd, config); err != nil {
+			logrus.Warnf("Process %s started without its resource limits: %v", config.Name, err)
+		}
+	}
 	return cmd, err
 }
 
 // killExistingProcesses kills any existing processes with the same name
 func killExistingProcesses(name string) {
-	procs, _ := process.Processes()
-	processName := filepath.Base(name)
-
-	for _, p := range procs {
-		exe, _ := p.Exe()
-		cmdline, _ := p.Cmdline()
-		if strings.Contains(exe, processName) || strings.Contains(cmdline, processName) {
-			logrus.Infof("Killing existing process: %s (PID: %d)", name, p.Pid)
-			p.Kill()
-		}
-	}
-}
-
-// monitorProcess monitors a process and restarts it if necessary
-func monitorProcess(config ProcessConfig, ctx context.Context) {
-	ticker := time.NewTicker(time.Duration(config.CheckInterval) * time.Second)
-	defer ticker.Stop()
-
-	var currentCmd *exec.Cmd
-	var isRestarting bool
-
-	// Check if process is already running before initial start
-	running, err := isProcessRunning(config.Name)
-	if err != nil {
-		logrus.Errorf("Failed to check if process %s is running: %v", config.Name, err)
-	} else if running {
-		logrus.Infof("Process %s is already running, skipping initial start", config.Name)
-	} else {
-		// Start the process initially only if it's not already running
-		logrus.Infof("Starting initial process: %s", config.Name)
-		cmd, err := startProcess(config, false) // 初始启动，isRestart = false
-		if err != nil {
-			if strings.Contains(err.Error(), "exclude processes found") {
-				logrus.Infof("Skipping initial start of %s due to exclude processes", config.Name)
-			} else {
-				logrus.Errorf("Failed to start initial process %s: %v", config.Name, err)
-			}
-		} else {
-			currentCmd = cmd
-			// Give the process some time to start up
-			time.Sleep(2 * time.Second)
-		}
-	}
-
-	for {
-		select {
-		case <-ticker.C:
-			// Skip monitoring if currently restarting
-			if isRestarting {
-				logrus.Debugf("Process %s is currently restarting, skipping check", config.Name)
-				continue
-			}
-
-			needRestart := false
-			processRunning := false
-
-			// Check if current command is still running
-			if currentCmd != nil && currentCmd.Process != nil {
-				// Check if process is still alive using process state
-				processState := currentCmd.ProcessState
-				if processState != nil && processState.Exited() {
-					logrus.Warnf("Managed process %s (PID: %d) has exited", config.Name, currentCmd.Process.Pid)
-					needRestart = true
-				} else {
-					// 即使 ProcessState 显示进程在运行，也通过名称再次检查
-					running, _ := isProcessRunning(config.Name)
-					if !running {
-						logrus.Warnf("Process %s (PID: %d) was manually closed", config.Name, currentCmd.Process.Pid)
-						needRestart = true
-					} else {
-						processRunning = true
-						logrus.Debugf("Process %s (PID: %d) is running", config.Name, currentCmd.Process.Pid)
-					}
-				}
-			} else {
-				// No current command, check if process exists by name
-				running, _ := isProcessRunning(config.Name)
-				if !running {
-					logrus.Warnf("Process %s is not running", config.Name)
-					needRestart = true
-				} else {
-					processRunning = true
-				}
-			}
-
-			// Only check ports and health if process is running
-			if processRunning {
-				// Check ports if configured
-				if len(config.Ports) > 0 {
-					allPortsOK := true
-					for _, port := range config.Ports {
-						if !isPortInUse(port) {
-							logrus.Warnf("Port %d is not in use for process %s", port, config.Name)
-							allPortsOK = false
-							break
-						}
-					}
-					if !allPortsOK {
-						needRestart = true
-					}
-				}
-
-				// Check health checks if configured
-				if !needRestart && len(config.HealthChecks) > 0 {
-					allHealthOK := true
-					for _, check := range config.HealthChecks {
-						if !isHealthCheckOK(check) {
-							logrus.Warnf("Health check failed for %s: %s", config.Name, check)
-							allHealthOK = false
-							break
-						}
-					}
-					if !allHealthOK {
-						needRestart = true
-					}
-				}
-			}
-
-			// If process needs restart
-			if needRestart {
-				isRestarting = true
-				logrus.Warnf("Process %s needs to be restarted", config.Name)
-
-				// Kill current process if it exists
-				if currentCmd != nil && currentCmd.Process != nil {
-					logrus.Infof("Terminating current process %s (PID: %d)", config.Name, currentCmd.Process.Pid)
-					currentCmd.Process.Kill()
-					currentCmd.Wait() // Wait for process to exit
-					currentCmd = nil
-				}
-
-				// Kill any other instances of the process
-				killExistingProcesses(config.Name)
-
-				// Wait for restart delay
-				if config.RestartDelay > 0 {
-					logrus.Infof("Waiting %d seconds before restart", config.RestartDelay)
-					time.Sleep(time.Duration(config.RestartDelay) * time.Second)
-				}
-
-				// Start new process
-				cmd, err := startProcess(config, true) // 重启进程，isRestart = true
-				if err != nil {
-					if strings.Contains(err.Error(), "exclude processes found") {
-						logrus.Infof("Skipping restart of %s due to exclude processes", config.Name)
-					} else {
-						logrus.Errorf("Failed to restart process %s: %v", config.Name, err)
-					}
-					currentCmd = nil
-				} else {
-					logrus.Infof("Successfully restarted process %s (PID: %d)", config.Name, cmd.Process.Pid)
-					currentCmd = cmd
-					// Give the new process time to start up
-					time.Sleep(2 * time.Second)
-				}
-
-				isRestarting = false
-			} else if processRunning {
-				logrus.Debugf("Process %s is healthy", config.Name)
-			}
-
-		case <-ctx.Done():
-			if config.KillOnExit && currentCmd != nil && currentCmd.Process != nil {
-				logrus.Infof("Stopping process %s (PID: %d)", config.Name, currentCmd.Process.Pid)
-				currentCmd.Process.Kill()
-				currentCmd.Wait()
-			} else if currentCmd != nil && currentCmd.Process != nil {
-				logrus.Infof("Leaving process %s (PID: %d) running", config.Name, currentCmd.Process.Pid)
-			}
-			return
-		}
-	}
+	killConfigProcesses(ProcessConfig{Name: name})
 }
 
 // createSelfMonitorScript creates a script to monitor the monitor process itself
@@ -496,9 +365,9 @@ done`, os.Args[0])
 func loadConfig(configFile string) (Config, error) {
 	var config Config
 
-	data, err := ioutil.ReadFile(configFile)
+	data, err := readConfigFile(configFile)
 	if err != nil {
-		return config, fmt.Errorf("error reading config file: %v", err)
+		return config, err
 	}
 
 	if err := yaml.Unmarshal(data, &config); err != nil {
@@ -508,51 +377,24 @@ func loadConfig(configFile string) (Config, error) {
 	return config, nil
 }
 
-// isAdmin 检查当前用户是否具有管理员权限
-func isAdmin() bool {
-	// 使用windows包提供的API检查管理员权限
-	var sid *windows.SID
-	err := windows.AllocateAndInitializeSid(
-		&windows.SECURITY_NT_AUTHORITY,
-		2,
-		windows.SECURITY_BUILTIN_DOMAIN_RID,
-		windows.DOMAIN_ALIAS_RID_ADMINS,
-		0, 0, 0, 0, 0, 0,
-		&sid)
+// readConfigFile returns the YAML of the config file, decrypted if needed
+func readConfigFile(configFile string) ([]byte, error) {
+	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		log.Printf("初始化SID失败: %v", err)
-		// 回退到物理驱动器检查
-		if _, err := os.Open("\\\\.\\PHYSICALDRIVE0"); err == nil {
-			return true
-		}
-		return false
+		return nil, fmt.Errorf("error reading config file: %v", err)
 	}
-	defer windows.FreeSid(sid)
-
-	// 检查当前进程令牌
-	token := windows.Token(0)
-	member, err := token.IsMember(sid)
-	if err != nil {
-		log.Printf("检查令牌成员关系失败: %v", err)
-		// 回退到物理驱动器检查
-		if _, err := os.Open("\\\\.\\PHYSICALDRIVE0"); err == nil {
-			return true
+	if isEncryptedConfig(data) {
+		if data, err = decryptConfig(data); err != nil {
+			return nil, fmt.Errorf("error decrypting config file: %v", err)
 		}
-		return false
 	}
-
-	return member
+	return data, nil
 }
 
 // 版本信息，将在编译时通过 -ldflags 注入
 var version = "development"
 
 func main() {
-	// 检查管理员权限
-	if !isAdmin() {
-		logrus.Fatal("此程序需要管理员权限运行。请右键点击程序，选择'以管理员身份运行'。")
-	}
-
 	// Parse command line flags
 	configFile := flag.String("config", "config.yaml", "path to config file")
 	logrus.Infof("Loading config from: %s", *configFile)
@@ -575,32 +417,51 @@ func main() {
 		return
 	}
 
-	// Load configuration
-	config, err := loadConfig(*configFile)
-	if err != nil {
-		logrus.Fatalf("Error loading config: %v", err)
+	// 子命令（如 profile）执行后直接退出，不启动监控
+	if flag.NArg() > 0 {
+		if err := runSubcommand(*configFile, flag.Args()); err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		return
 	}
 
-	// 向后兼容处理：如果没有指定 enable 字段，默认为 true
-	for i := range config.Processes {
-		if !config.Processes[i].Enable {
-			config.Processes[i].Enable = true
+	// 由服务控制管理器启动时以Windows服务方式运行
+	if runningAsService() {
+		if err := runService(*configFile); err != nil {
+			logrus.Fatalf("Service failed: %v", err)
 		}
+		return
 	}
 
-	// 注册表监控也添加向后兼容处理
-	for i := range config.RegistryMonitors {
-		if !config.RegistryMonitors[i].Enable {
-			config.RegistryMonitors[i].Enable = true
-		}
+	// Set up signal handling
+	shutdown := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		<-sigs
+		close(shutdown)
+	}()
+	runMonitor(*configFile, shutdown, nil)
+}
+
+// runMonitor loads the config and supervises everything until shutdown is
+// closed. ready, if set, receives the process manager once monitoring started.
+func runMonitor(configFile string, shutdown <-chan struct{}, ready func(manager *ProcessManager)) {
+	// Load configuration
+	config, err := loadConfig(configFile)
+	if err != nil {
+		logrus.Fatalf("Error loading config: %v", err)
 	}
 
+	applyConfigDefaults(&config)
+	configErr := validateConfig(config)
+
 	// Set up context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Set up logging with rotation (100MB limit)
-	logRotator := NewLogRotator("processmonitor.log", 100*1024*1024) // 100MB
+	// 日志文件按大小（默认100MB）和可选的按天轮转，旧文件在后台压缩和清理
+	logRotator := NewLogRotator(config.Logging)
 	defer logRotator.Close()
 
 	logrus.SetOutput(logRotator)
@@ -609,43 +470,175 @@ func main() {
 		FullTimestamp: true,
 	})
 
-	// Start monthly cleanup routine
+	// 启动时和之后每天清理超过数量或保留天数的轮转文件
 	go func() {
-		ticker := time.NewTicker(24 * time.Hour) // Check daily
+		logRotator.Cleanup()
+		ticker := time.NewTicker(24 * time.Hour)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
-				// Check if it's the first day of the month
-				now := time.Now()
-				if now.Day() == 1 {
-					logRotator.MonthlyCleanup()
-				}
+				logRotator.Cleanup()
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
+	opTimeouts = timeoutDefaults(config.Timeouts)
+	procTable.setRefresh(seconds(defaultInt(config.ProcessTable.Refresh, defaultProcessTableRefresh)))
+	firewallSettings = config.Firewall
+	failureSnapshots = config.FailureSnapshot
+
+	// 主机名、IP、系统版本和静态标签，在创建通知和指标之前确定
+	setHostInfo(config.Host)
+	host := currentHost()
+	logrus.Infof("Host %s (%s, %s %s)", host.Hostname, host.IP, host.OS, host.OSVersion)
+
+	// 事件历史（GET /events）、进程状态和指标历史保存在 storage 选择的后端中；默认只保存在内存中，
+	// 配置了 event_history 时使用数据库。打开失败时退回内存
+	storage, err := openStorage(storageConfig(config), config.API.EventBuffer)
+	if err != nil {
+		logrus.Errorf("Storage falls back to memory: %v", err)
+		storage = newMemoryStorage(config.API.EventBuffer)
+	}
+	eventHistory, stateStorage = storage, storage
+	go runGuarded(ctx, "storage", "", storage.Run)
+	registerEventSink(storage)
+	if interval := storageConfig(config).MetricsInterval; interval >= 0 {
+		if _, memory := storage.(*memoryStorage); !memory {
+			recorder := newMetricsRecorder(storage)
+			registerMetricsSink(recorder)
+			go runGuarded(ctx, "metrics history", "", func(ctx context.Context) {
+				recorder.Run(ctx, seconds(defaultInt(interval, defaultStorageMetricsInterval)))
+			})
+		}
+	}
+	registerEventSink(registryMonitors)
+
+	// Teams 通知
+	if config.Teams.WebhookURL != "" {
+		teams := newTeamsNotifier(config.Teams)
+		registerEventSink(teams)
+		go runGuarded(ctx, "teams notifier", "", teams.Run)
+	}
+
+	// Webhook 和邮件通知
+	startNotifications(ctx, config.Notifications)
+
+	// PagerDuty / OpsGenie 事故的触发、确认和恢复
+	if incidents := newIncidentManager(config.Incidents); incidents.enabled() {
+		registerEventSink(incidents)
+		go runGuarded(ctx, "incident manager", "", incidents.Run)
+	}
+
+	// 最小权限模式：连接特权助手
+	initPrivilegedHelper(config.PrivilegedHelper)
+
+	// 只报告安全模式：策略禁止自动修复，无法记录证据时不能退回到修复模式
+	if config.Security.ReportOnly {
+		reporter, err := newEvidenceReporter(config.Security)
+		if err != nil {
+			logrus.Fatalf("Report-only security mode could not be initialized: %v", err)
+		}
+		evidence = reporter
+		go evidence.runEvidenceForwarder(ctx)
+		logrus.Infof("Report-only security mode enabled: tamper detections are recorded as signed evidence and not corrected")
+	}
+
+	// 检测本机平台能力，停用无法在本机工作的功能
+	reportCapabilities(&config)
+
+	// 检查运行权限，报告哪些已配置的功能因权限不足无法工作
+	reportPrivileges(config)
+
+	// 容器环境检测：cgroup 限制和PID命名空间
+	reportContainer(config)
+
+	// 记录监控程序自身的资源占用
+	go runGuarded(ctx, "self monitor", "", func(ctx context.Context) { runSelfMonitor(config.SelfMonitor, ctx) })
+	go runGuarded(ctx, "internal error digest", "", func(ctx context.Context) { runErrorDigest(config.SelfMonitor, ctx) })
+
+	// 网络健康探测，必须在进程监控和API服务开始之前创建
+	if config.NetworkHealth.Enable {
+		networkHealth = newNetworkHealthMonitor(config.NetworkHealth)
+		go runGuarded(ctx, "network health monitor", networkHealthName, networkHealth.Run)
+	}
+
+	// 磁盘硬件健康检查
+	if config.DiskHealth.Enable {
+		go runGuarded(ctx, "disk health monitor", "", func(ctx context.Context) { runDiskHealth(config.DiskHealth, ctx) })
+	}
+
+	// 主配置无效时，如果配置了安全模式则只监控其中的关键进程
+	controller := newConfigController(configFile, config, configErr, nil)
+	processConfig, err := controller.initialConfig()
+	if err != nil {
+		logrus.Fatalf("Error in config: %v", err)
+	}
+
+	// 为每个启用的进程创建监控器
+	manager := NewProcessManager(processConfig)
+	controller.manager = manager
+
+	// 启动内置HTTP服务
+	if config.API.Listen != "" {
+		api := NewAPIServer(config.API, manager)
+		api.reload = controller.requestReload
+		go api.Run(ctx)
+	}
+	if config.StatusPage.Listen != "" {
+		go NewStatusPageServer(config.StatusPage, manager).Run(ctx)
+	}
+
 	logrus.Infof("Starting Process Monitor v1.0")
 	logrus.Infof("Monitoring %d processes", len(config.Processes))
 
-	// Set up signal handling
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-
 	// WaitGroup for registry monitors
 	var wg sync.WaitGroup
 
+	// statsd 指标推送（在启动进程之前注册，避免漏掉初始重启）
+	if config.Statsd.Address != "" {
+		go runGuarded(ctx, "statsd", "", func(ctx context.Context) { runStatsd(config.Statsd, manager, ctx) })
+	}
+
 	// Start monitoring each process
-	for _, processConfig := range config.Processes {
-		// 检查是否启用此配置
-		if !processConfig.Enable {
-			logrus.Infof("Skipping disabled process monitor: %s", processConfig.Name)
-			continue
-		}
-		go monitorProcess(processConfig, ctx)
+	manager.Start(ctx)
+	if ready != nil {
+		ready(manager)
+	}
+
+	// 配置重新加载与安全模式切换
+	reloadCh := reloadSignals()
+	go runGuarded(ctx, "config controller", "", func(ctx context.Context) {
+		controller.Run(ctx, config.ReloadInterval, reloadCh)
+	})
+
+	// 本机控制通道：status/restart/reload/tail 子命令
+	if config.Control.Enable {
+		go runGuarded(ctx, "control channel", "", func(ctx context.Context) {
+			runControlServer(config.Control, manager, controller.requestReload, ctx)
+		})
+	}
+
+	// 与标准配置比较，防止本地篡改监控程序自身的配置
+	if config.ConfigDrift.Golden != "" {
+		go runGuarded(ctx, "config drift monitor", "", func(ctx context.Context) {
+			runConfigDrift(config.ConfigDrift, configFile, controller.requestReload, ctx)
+		})
+	}
+
+	// 基于文件的命令队列
+	if config.CommandQueue.Enable {
+		go runGuarded(ctx, "command queue", "", func(ctx context.Context) { runCommandQueue(config.CommandQueue, manager, ctx) })
+	}
+
+	// 将进程状态镜像到注册表
+	if config.RegistryStatus.Enable {
+		go runGuarded(ctx, "registry status publisher", "", func(ctx context.Context) {
+			runRegistryStatusPublisher(config.RegistryStatus, manager, ctx)
+		})
 	}
 
 	// Start registry monitoring (Windows only)
@@ -664,12 +657,63 @@ func main() {
 				continue
 			}
 			wg.Add(1)
-			go MonitorRegistry(regConfig, ctx, &wg)
+			go func(regConfig RegistryMonitor) {
+				defer wg.Done()
+				runGuarded(ctx, "registry monitor "+regConfig.Name, "", func(ctx context.Context) {
+					// MonitorRegistry 在退出（包括 panic）时调用 Done，每次运行使用独立的 WaitGroup
+					var done sync.WaitGroup
+					done.Add(1)
+					MonitorRegistry(regConfig, ctx, &done)
+				})
+			}(regConfig)
+		}
+	}
+
+	// Windows 服务监控
+	if runtime.GOOS == "windows" {
+		for _, serviceConfig := range config.ServiceMonitors {
+			serviceConfig := serviceConfig
+			go runGuarded(ctx, "service monitor "+serviceConfig.Name, "", func(ctx context.Context) { MonitorService(serviceConfig, ctx) })
 		}
+	} else if len(config.ServiceMonitors) > 0 {
+		logrus.Warnf("service_monitors are only supported on Windows, ignoring %d service monitor(s)", len(config.ServiceMonitors))
+	}
+
+	// IIS 应用程序池监控
+	if runtime.GOOS == "windows" {
+		backend := newIISBackend(config.IIS.AdminAPI)
+		for _, poolConfig := range config.IIS.AppPools {
+			poolConfig := poolConfig
+			go runGuarded(ctx, "IIS app pool monitor "+poolConfig.Name, "", func(ctx context.Context) { MonitorIISAppPool(poolConfig, backend, ctx) })
+		}
+	} else if len(config.IIS.AppPools) > 0 {
+		logrus.Warnf("iis.app_pools are only supported on Windows, ignoring %d app pool monitor(s)", len(config.IIS.AppPools))
+	}
+
+	// COM+ 应用程序监控
+	if runtime.GOOS == "windows" {
+		for _, appConfig := range config.COMPlusApps {
+			appConfig := appConfig
+			go runGuarded(ctx, "COM+ monitor "+appConfig.Name, "", func(ctx context.Context) { MonitorCOMPlusApp(appConfig, ctx) })
+		}
+	} else if len(config.COMPlusApps) > 0 {
+		logrus.Warnf("complus_apps are only supported on Windows, ignoring %d COM+ monitor(s)", len(config.COMPlusApps))
+	}
+
+	// 文件完整性监控（所有平台）
+	for _, fileConfig := range config.FileMonitors {
+		fileConfig := fileConfig
+		go runGuarded(ctx, "file monitor "+fileConfig.Name, "", func(ctx context.Context) { MonitorFile(fileConfig, ctx) })
+	}
+
+	// 定时任务（所有平台）
+	for _, taskConfig := range config.Tasks {
+		taskConfig := taskConfig
+		go runGuarded(ctx, "task "+taskConfig.Name, "", func(ctx context.Context) { RunTask(taskConfig, ctx) })
 	}
 
 	// Wait for termination signal
-	<-sigs
+	<-shutdown
 	logrus.Info("Received shutdown signal, stopping all processes...")
 	cancel()
 
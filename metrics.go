@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// MetricsSink receives metric events from the supervisors. Tags are
+// key/value pairs such as {"process": "api_server.exe"}.
+type MetricsSink interface {
+	Count(name string, value int64, tags map[string]string)
+	Gauge(name string, value float64, tags map[string]string)
+	Timing(name string, d time.Duration, tags map[string]string)
+}
+
+var (
+	metricSinksMu sync.RWMutex
+	metricSinks   []MetricsSink
+)
+
+// registerMetricsSink adds a sink that receives every emitted metric
+func registerMetricsSink(sink MetricsSink) {
+	metricSinksMu.Lock()
+	defer metricSinksMu.Unlock()
+	metricSinks = append(metricSinks, sink)
+}
+
+func emitCount(name string, value int64, tags map[string]string) {
+	metricSinksMu.RLock()
+	defer metricSinksMu.RUnlock()
+	for _, sink := range metricSinks {
+		sink.Count(name, value, tags)
+	}
+}
+
+func emitGauge(name string, value float64, tags map[string]string) {
+	metricSinksMu.RLock()
+	defer metricSinksMu.RUnlock()
+	for _, sink := range metricSinks {
+		sink.Gauge(name, value, tags)
+	}
+}
+
+func emitTiming(name string, d time.Duration, tags map[string]string) {
+	metricSinksMu.RLock()
+	defer metricSinksMu.RUnlock()
+	for _, sink := range metricSinks {
+		sink.Timing(name, d, tags)
+	}
+}
+
+// processTags returns the standard tags for metrics about a managed process
+func processTags(name string) map[string]string {
+	return map[string]string{"process": name}
+}
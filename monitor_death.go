@@ -0,0 +1,43 @@
+package main
+
+import "fmt"
+
+// 监控程序本身意外退出（崩溃、被 kill -9）时子进程的处理方式（on_monitor_death）
+const (
+	MonitorDeathDetach = "detach" // 继续运行；监控程序重新启动后按 adopt 接管
+	MonitorDeathKill   = "kill"   // 立即结束（Linux PDEATHSIG SIGKILL，Windows kill-on-close 作业对象）
+	MonitorDeathTerm   = "term"   // 发送 SIGTERM（Windows 上与 kill 相同）
+)
+
+// validateMonitorDeath checks on_monitor_death and the options it cannot
+// be combined with
+func validateMonitorDeath(config ProcessConfig) error {
+	switch config.OnMonitorDeath {
+	case "":
+	case MonitorDeathDetach:
+		if config.ProcessGroup == ProcessGroupInherit {
+			return fmt.Errorf("on_monitor_death detach requires the process to have its own process group (process_group: inherit)")
+		}
+	case MonitorDeathKill, MonitorDeathTerm:
+		// 安全标签在一个锁定后退出的线程上启动子进程，线程退出时就会触发 PDEATHSIG
+		if config.SELinuxContext != "" || config.AppArmorProfile != "" {
+			return fmt.Errorf("on_monitor_death %s cannot be used with selinux_context or apparmor_profile", config.OnMonitorDeath)
+		}
+	default:
+		return fmt.Errorf("on_monitor_death must be detach, kill or term, got %q", config.OnMonitorDeath)
+	}
+	return nil
+}
+
+// endsWithMonitor reports whether the child is ended when the monitor
+// process exits
+func (c ProcessConfig) endsWithMonitor() bool {
+	return c.OnMonitorDeath == MonitorDeathKill || c.OnMonitorDeath == MonitorDeathTerm
+}
+
+// adoptsRunning reports whether an instance found running at startup is
+// taken over like a child of the monitor: with adopt, or when it was left
+// running by a previous monitor with on_monitor_death detach
+func (c ProcessConfig) adoptsRunning() bool {
+	return c.Adopt || c.OnMonitorDeath == MonitorDeathDetach
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setParentDeathSignal makes the kernel signal the child when the monitor
+// exits (PR_SET_PDEATHSIG), also when it is killed without a chance to stop
+// its children. The signal is set after the user switch, which would clear it.
+func setParentDeathSignal(cmd *exec.Cmd, config ProcessConfig) {
+	switch config.OnMonitorDeath {
+	case MonitorDeathKill:
+		cmd.SysProcAttr.Pdeathsig = syscall.SIGKILL
+	case MonitorDeathTerm:
+		cmd.SysProcAttr.Pdeathsig = syscall.SIGTERM
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestParentDeathSignal(t *testing.T) {
+	tests := map[string]syscall.Signal{
+		"":                 0,
+		MonitorDeathDetach: 0,
+		MonitorDeathKill:   syscall.SIGKILL,
+		MonitorDeathTerm:   syscall.SIGTERM,
+	}
+	for mode, want := range tests {
+		cmd := exec.Command("/bin/true")
+		if err := setProcessAttributes(cmd, ProcessConfig{Name: "true", OnMonitorDeath: mode}); err != nil {
+			t.Fatal(err)
+		}
+		if got := cmd.SysProcAttr.Pdeathsig; got != want {
+			t.Errorf("on_monitor_death %q: pdeathsig = %v, want %v", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"os/exec"
+
+	"github.com/sirupsen/logrus"
+)
+
+// setParentDeathSignal is not supported: there is no parent-death signal
+// outside Linux
+func setParentDeathSignal(cmd *exec.Cmd, config ProcessConfig) {
+	if config.endsWithMonitor() {
+		logrus.Warnf("on_monitor_death %s is only supported on Linux and Windows, ignored for %s", config.OnMonitorDeath, config.Name)
+	}
+}
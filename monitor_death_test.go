@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestValidateMonitorDeath(t *testing.T) {
+	tests := []struct {
+		config ProcessConfig
+		ok     bool
+	}{
+		{ProcessConfig{}, true},
+		{ProcessConfig{OnMonitorDeath: MonitorDeathDetach}, true},
+		{ProcessConfig{OnMonitorDeath: MonitorDeathKill, KillProcessTree: true}, true},
+		{ProcessConfig{OnMonitorDeath: MonitorDeathTerm, ProcessGroup: ProcessGroupInherit}, true},
+		{ProcessConfig{OnMonitorDeath: "restart"}, false},
+		{ProcessConfig{OnMonitorDeath: MonitorDeathDetach, ProcessGroup: ProcessGroupInherit}, false},
+		{ProcessConfig{OnMonitorDeath: MonitorDeathKill, SELinuxContext: "system_u:system_r:app_t:s0"}, false},
+		{ProcessConfig{OnMonitorDeath: MonitorDeathTerm, AppArmorProfile: "app"}, false},
+	}
+	for _, tt := range tests {
+		if err := validateMonitorDeath(tt.config); (err == nil) != tt.ok {
+			t.Errorf("on_monitor_death %q: err = %v, want ok = %v", tt.config.OnMonitorDeath, err, tt.ok)
+		}
+	}
+
+	if !(ProcessConfig{OnMonitorDeath: MonitorDeathDetach}).adoptsRunning() {
+		t.Error("on_monitor_death detach does not adopt the instance left running")
+	}
+	if (ProcessConfig{OnMonitorDeath: MonitorDeathKill}).adoptsRunning() {
+		t.Error("on_monitor_death kill adopts running instances")
+	}
+}
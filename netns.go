@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NetNSConfig 在独立的网络命名空间中运行进程（仅Linux，需要root）
+type NetNSConfig struct {
+	Enable bool          `yaml:"enable"` // 启用后子进程只能看到自己的回环网卡，外部访问通过 ports 转发
+	Ports  []PortForward `yaml:"ports"`  // 由监控程序在主机上监听并转发到命名空间内的端口
+}
+
+// PortForward 一条端口转发
+type PortForward struct {
+	Listen string `yaml:"listen"` // 主机上的监听地址，如 ":8080" 或 "127.0.0.1:8080"
+	Target int    `yaml:"target"` // 命名空间内程序监听的端口（连接 127.0.0.1）
+}
+
+// netnsDialer connects to addr inside the network namespace of pid
+type netnsDialer func(ctx context.Context, pid int32, addr string) (net.Conn, error)
+
+// portProxy owns the host side listeners of a process running in its own
+// network namespace. The listeners stay open across restarts; new
+// connections go to the current instance while connections accepted before
+// a restart keep talking to the old one until they close, so the old and new
+// instance never compete for the same host port.
+type portProxy struct {
+	name  string
+	ports []PortForward
+	dial  netnsDialer
+
+	target atomic.Int32 // 当前实例的PID，0 表示没有运行中的实例
+
+	mu        sync.Mutex
+	listeners []net.Listener
+}
+
+func newPortProxy(config ProcessConfig) *portProxy {
+	if !config.NetNS.Enable || len(config.NetNS.Ports) == 0 {
+		return nil
+	}
+	return &portProxy{name: config.Name, ports: config.NetNS.Ports, dial: dialInNetNS}
+}
+
+// Listen opens the host listeners and serves them until ctx is done
+func (p *portProxy) Listen(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, forward := range p.ports {
+		ln, err := net.Listen("tcp", forward.Listen)
+		if err != nil {
+			p.closeLocked()
+			return fmt.Errorf("failed to listen on %s: %v", forward.Listen, err)
+		}
+		p.listeners = append(p.listeners, ln)
+		logrus.Infof("Forwarding %s to port %d in the network namespace of %s", ln.Addr(), forward.Target, p.name)
+		go p.serve(ctx, ln, forward.Target)
+	}
+	go func() {
+		<-ctx.Done()
+		p.Close()
+	}()
+	return nil
+}
+
+// Close stops accepting new connections
+func (p *portProxy) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeLocked()
+}
+
+func (p *portProxy) closeLocked() {
+	for _, ln := range p.listeners {
+		ln.Close()
+	}
+	p.listeners = nil
+}
+
+// setTarget directs new connections to the instance with the given PID
+func (p *portProxy) setTarget(pid int32) {
+	p.target.Store(pid)
+}
+
+func (p *portProxy) serve(ctx context.Context, ln net.Listener, port int) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.forward(ctx, conn, port)
+	}
+}
+
+// forward connects conn to port inside the namespace of the current instance
+func (p *portProxy) forward(ctx context.Context, conn net.Conn, port int) {
+	defer conn.Close()
+	pid := p.target.Load()
+	if pid == 0 {
+		logrus.Debugf("Rejecting connection from %s: %s is not running", conn.RemoteAddr(), p.name)
+		return
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	upstream, err := p.dial(dialCtx, pid, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	cancel()
+	if err != nil {
+		logrus.Warnf("Port proxy of %s: failed to connect to port %d of PID %d: %v", p.name, port, pid, err)
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		// 单向关闭，让对端读到 EOF
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+	<-done
+	<-done
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// applyNetNS makes cmd start in a new network namespace. The child is
+// launched through "processmonitor netns-exec", which brings up the loopback
+// interface of the empty namespace before executing the real program, so the
+// program can bind 127.0.0.1 as soon as it starts.
+func applyNetNS(cmd *exec.Cmd, config ProcessConfig) error {
+	if !config.NetNS.Enable {
+		return nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate monitor executable for netns-exec: %v", err)
+	}
+	cmd.Args = append([]string{self, "netns-exec", cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = self
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	return nil
+}
+
+// runNetNSExec is the "netns-exec <program> [args...]" helper run as the
+// first process of a new network namespace
+func runNetNSExec(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: processmonitor netns-exec <program> [args...]")
+	}
+	if err := bringUpLoopback(); err != nil {
+		return err
+	}
+	path := args[0]
+	if !strings.Contains(path, "/") {
+		found, err := exec.LookPath(path)
+		if err != nil {
+			return err
+		}
+		path = found
+	}
+	return syscall.Exec(path, args, os.Environ())
+}
+
+// bringUpLoopback sets the lo interface of the current namespace up
+func bringUpLoopback() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("failed to read flags of lo: %v", err)
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("failed to bring up lo: %v", err)
+	}
+	return nil
+}
+
+// dialInNetNS connects to addr from inside the network namespace of pid.
+// The socket is created on a dedicated OS thread that temporarily joins the
+// namespace; the connection keeps belonging to that namespace afterwards.
+func dialInNetNS(ctx context.Context, pid int32, addr string) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		target, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+		if err != nil {
+			runtime.UnlockOSThread()
+			resc <- result{err: err}
+			return
+		}
+		defer target.Close()
+		orig, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			resc <- result{err: err}
+			return
+		}
+		defer orig.Close()
+
+		if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			resc <- result{err: fmt.Errorf("failed to enter network namespace: %v", err)}
+			return
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		// 无法切换回原命名空间时不解锁线程，goroutine 结束时该线程随之退出
+		if unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+		resc <- result{conn, err}
+	}()
+	res := <-resc
+	return res.conn, res.err
+}
+
+// netnsCapability reports whether the monitor may create network namespaces,
+// which requires CAP_SYS_ADMIN
+func netnsCapability() Capability {
+	if _, err := os.Stat("/proc/self/ns/net"); err != nil {
+		return Capability{Name: CapNetNS, Detail: "the kernel does not support network namespaces"}
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return Capability{Name: CapNetNS, Detail: "cannot read process capabilities: " + err.Error()}
+	}
+	if data[0].Effective&(1<<unix.CAP_SYS_ADMIN) == 0 {
+		return Capability{Name: CapNetNS, Detail: "the monitor lacks CAP_SYS_ADMIN (run as root)"}
+	}
+	return Capability{Name: CapNetNS, Available: true, Detail: "CAP_SYS_ADMIN"}
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+)
+
+// applyNetNS rejects netns outside Linux
+func applyNetNS(cmd *exec.Cmd, config ProcessConfig) error {
+	if config.NetNS.Enable {
+		return fmt.Errorf("netns is only supported on Linux")
+	}
+	return nil
+}
+
+func runNetNSExec(args []string) error {
+	return fmt.Errorf("netns-exec is only supported on Linux")
+}
+
+func dialInNetNS(ctx context.Context, pid int32, addr string) (net.Conn, error) {
+	return nil, fmt.Errorf("netns is only supported on Linux")
+}
+
+func netnsCapability() Capability {
+	return Capability{Name: CapNetNS, Detail: "network namespaces are only available on Linux"}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// echoServer answers every line with its name
+func echoServer(t *testing.T, name string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					if _, err := r.ReadString('\n'); err != nil {
+						return
+					}
+					fmt.Fprintf(conn, "%s\n", name)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestPortProxyRetarget(t *testing.T) {
+	// 用PID区分新旧实例，测试中直接连接各自的本地服务
+	backends := map[int32]string{1: echoServer(t, "old"), 2: echoServer(t, "new")}
+	p := newPortProxy(ProcessConfig{Name: "api", NetNS: NetNSConfig{Enable: true, Ports: []PortForward{{Listen: "127.0.0.1:0", Target: 8080}}}})
+	p.dial = func(ctx context.Context, pid int32, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", backends[pid])
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Listen(ctx); err != nil {
+		t.Fatal(err)
+	}
+	addr := p.listeners[0].Addr().String()
+
+	ask := func(conn net.Conn) string {
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		fmt.Fprintln(conn, "who")
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		return line
+	}
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	// 没有运行中的实例时直接关闭连接
+	if got := ask(dial()); got != "" {
+		t.Errorf("no target: got %q, want connection closed", got)
+	}
+
+	p.setTarget(1)
+	oldConn := dial()
+	if got := ask(oldConn); got != "old\n" {
+		t.Fatalf("got %q, want old", got)
+	}
+
+	// 切换到新实例后，新连接到新实例，已有连接继续由旧实例处理
+	p.setTarget(2)
+	if got := ask(dial()); got != "new\n" {
+		t.Errorf("new connection: got %q, want new", got)
+	}
+	if got := ask(oldConn); got != "old\n" {
+		t.Errorf("existing connection: got %q, want old", got)
+	}
+}
+
+func TestNewPortProxyDisabled(t *testing.T) {
+	if p := newPortProxy(ProcessConfig{Name: "a", NetNS: NetNSConfig{Ports: []PortForward{{Listen: ":80", Target: 80}}}}); p != nil {
+		t.Error("netns not enabled, proxy should be nil")
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// networkHealthName 网络健康伪进程在状态列表中的名称
+const networkHealthName = "network-health"
+
+// NetworkHealthConfig 出站网络连通性探测（不使用ICMP）
+type NetworkHealthConfig struct {
+	Enable           bool           `yaml:"enable"`            // 是否启用
+	Interval         int            `yaml:"interval"`          // 探测间隔（秒，默认30）
+	FailureThreshold int            `yaml:"failure_threshold"` // 连续失败多少轮后判定网络中断（默认2）
+	Mode             string         `yaml:"mode"`              // any：任一探测成功即正常（默认）；all：全部成功才正常
+	Probes           []NetworkProbe `yaml:"probes"`            // 探测列表，为空时使用内置目录
+}
+
+// NetworkProbe is one outbound connectivity probe
+type NetworkProbe struct {
+	Type    string `yaml:"type"`    // http, dns, tcp, gateway
+	URL     string `yaml:"url"`     // http: 回显地址，返回2xx即成功
+	Host    string `yaml:"host"`    // dns: 要解析的域名
+	Address string `yaml:"address"` // tcp: host:port；gateway: 端口（默认53）
+	Timeout int    `yaml:"timeout"` // 超时（秒，默认5）
+}
+
+// defaultNetworkProbes is the built-in probe catalog used when none are configured
+var defaultNetworkProbes = []NetworkProbe{
+	{Type: "http", URL: "http://www.msftconnecttest.com/connecttest.txt"},
+	{Type: "http", URL: "http://connectivitycheck.gstatic.com/generate_204"},
+	{Type: "dns", Host: "www.microsoft.com"},
+	{Type: "gateway"},
+}
+
+// networkHealthMonitor evaluates the probes and tracks the resulting state
+type networkHealthMonitor struct {
+	config NetworkHealthConfig
+
+	mu       sync.RWMutex
+	healthy  bool
+	failures int
+	since    time.Time
+	lastErr  string
+}
+
+// networkHealth is nil unless network_health is enabled
+var networkHealth *networkHealthMonitor
+
+// networkAvailable reports whether network-dependent processes may be restarted
+func networkAvailable() bool {
+	if networkHealth == nil {
+		return true
+	}
+	networkHealth.mu.RLock()
+	defer networkHealth.mu.RUnlock()
+	return networkHealth.healthy
+}
+
+func newNetworkHealthMonitor(config NetworkHealthConfig) *networkHealthMonitor {
+	if config.Interval <= 0 {
+		config.Interval = 30
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 2
+	}
+	if len(config.Probes) == 0 {
+		config.Probes = defaultNetworkProbes
+	}
+	// 启动时假定网络正常，避免第一轮探测前阻止进程启动
+	return &networkHealthMonitor{config: config, healthy: true, since: time.Now()}
+}
+
+// Status reports the network health as a pseudo-process status
+func (n *networkHealthMonitor) Status() ProcessStatus {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	st := ProcessStatus{Name: networkHealthName, State: StateRunning, StartedAt: n.since, Health: HealthHealthy}
+	if !n.healthy {
+		st.State = StateDown
+		st.Health = HealthUnhealthy
+		st.LastRestartReason = n.lastErr
+	}
+	return st
+}
+
+// Run probes the network every interval until ctx is cancelled
+func (n *networkHealthMonitor) Run(ctx context.Context) {
+	logrus.Infof("Network health monitor started with %d probe(s)", len(n.config.Probes))
+	ticker := time.NewTicker(time.Duration(n.config.Interval) * time.Second)
+	defer ticker.Stop()
+
+	n.check()
+	for {
+		select {
+		case <-ticker.C:
+			n.check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check runs all probes once and updates the state
+func (n *networkHealthMonitor) check() {
+	passed := 0
+	var lastErr error
+	for _, probe := range n.config.Probes {
+		if err := runNetworkProbe(probe); err != nil {
+			logrus.Debugf("Network probe %s failed: %v", probe.describe(), err)
+			lastErr = fmt.Errorf("%s: %v", probe.describe(), err)
+		} else {
+			passed++
+		}
+	}
+
+	ok := passed > 0
+	if n.config.Mode == "all" {
+		ok = passed == len(n.config.Probes)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if ok {
+		n.failures = 0
+		if !n.healthy {
+			logrus.Infof("Network connectivity restored (%d/%d probes passed), resuming restarts of network-dependent processes",
+				passed, len(n.config.Probes))
+			n.healthy = true
+			n.since = time.Now()
+			n.lastErr = ""
+		}
+		return
+	}
+
+	n.failures++
+	n.lastErr = lastErr.Error()
+	if n.healthy && n.failures >= n.config.FailureThreshold {
+		logrus.Warnf("Network connectivity lost (%d/%d probes passed, last error: %v), pausing restarts of network-dependent processes",
+			passed, len(n.config.Probes), lastErr)
+		n.healthy = false
+		n.since = time.Now()
+	}
+}
+
+func (p NetworkProbe) describe() string {
+	switch p.Type {
+	case "http":
+		return "http " + p.URL
+	case "dns":
+		return "dns " + p.Host
+	default:
+		return p.Type + " " + p.Address
+	}
+}
+
+// runNetworkProbe executes a single probe
+func runNetworkProbe(probe NetworkProbe) error {
+	timeout := 5 * time.Second
+	if probe.Timeout > 0 {
+		timeout = time.Duration(probe.Timeout) * time.Second
+	}
+
+	switch probe.Type {
+	case "http":
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(probe.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	case "dns":
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, probe.Host)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("no addresses")
+		}
+		return nil
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", probe.Address, timeout)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	case "gateway":
+		gateway, err := defaultGateway()
+		if err != nil {
+			return err
+		}
+		port := probe.Address
+		if port == "" {
+			port = "53"
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(gateway, port), timeout)
+		if err != nil {
+			// 网关拒绝连接说明它是可达的
+			if isConnRefused(err) {
+				return nil
+			}
+			return err
+		}
+		conn.Close()
+		return nil
+	default:
+		return fmt.Errorf("unknown probe type: %s", probe.Type)
+	}
+}
+
+// parseHexIPv4 parses a little-endian hex IPv4 address as found in /proc/net/route
+func parseHexIPv4(s string) (net.IP, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(byte(v), byte(v>>8), byte(v>>16), byte(v>>24)), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestParseHexIPv4(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"0101A8C0", "192.168.1.1"},
+		{"0100000A", "10.0.0.1"},
+		{"00000000", "0.0.0.0"},
+	}
+	for _, tt := range tests {
+		ip, err := parseHexIPv4(tt.input)
+		if err != nil {
+			t.Fatalf("parseHexIPv4(%q): %v", tt.input, err)
+		}
+		if ip.String() != tt.want {
+			t.Errorf("parseHexIPv4(%q) = %s, want %s", tt.input, ip, tt.want)
+		}
+	}
+}
+
+func TestNetworkHealthThreshold(t *testing.T) {
+	n := newNetworkHealthMonitor(NetworkHealthConfig{
+		FailureThreshold: 2,
+		Probes:           []NetworkProbe{{Type: "tcp", Address: "127.0.0.1:1", Timeout: 1}},
+	})
+	n.check()
+	if !n.healthy {
+		t.Fatal("network marked down before reaching failure threshold")
+	}
+	n.check()
+	if n.healthy {
+		t.Fatal("network still healthy after reaching failure threshold")
+	}
+	if st := n.Status(); st.State != StateDown || st.Name != networkHealthName {
+		t.Errorf("unexpected pseudo-process status: %+v", st)
+	}
+}
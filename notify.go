@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// retryPolicy 通知请求的重试次数与退避
+type retryPolicy struct {
+	attempts int           // 最多尝试的次数
+	delay    time.Duration // 第一次重试前的等待时间，之后每次翻倍
+}
+
+// defaultRetry 内置通知（Teams、PagerDuty、OpsGenie）使用的重试策略
+var defaultRetry = retryPolicy{attempts: 3, delay: 2 * time.Second}
+
+// postJSON posts payload as JSON to url with the default retry policy
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return sendWithRetry(ctx, client, defaultRetry, http.MethodPost, url, headers, body)
+}
+
+// notifyStatusError is an error answer of a notification receiver
+type notifyStatusError struct {
+	status string
+	code   int
+	body   string
+}
+
+func (e *notifyStatusError) Error() string {
+	return fmt.Sprintf("server returned %s: %s", e.status, e.body)
+}
+
+// retryable reports whether sending again may succeed: the receiver
+// throttled (429) or failed (5xx) rather than refused the request
+func (e *notifyStatusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// sendWithRetry sends a JSON body, retrying with exponential backoff when
+// the receiver throttles (429) or fails (5xx, network errors). Other 4xx
+// answers mean the request itself is wrong and are not retried.
+func sendWithRetry(ctx context.Context, client *http.Client, policy retryPolicy, method, url string, headers map[string]string, body []byte) error {
+	delay := policy.delay
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return nil
+			}
+			// 地址中可能含有密钥（Teams/Slack Webhook），错误信息中不包含地址
+			statusErr := &notifyStatusError{resp.Status, resp.StatusCode, strings.TrimSpace(string(respBody))}
+			if !statusErr.retryable() {
+				return statusErr
+			}
+			err = statusErr
+		}
+		if attempt >= policy.attempts {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// startNotifications registers the configured webhooks and mail notifier
+func startNotifications(ctx context.Context, config NotificationsConfig) {
+	for _, wc := range config.Webhooks {
+		w, err := newWebhookNotifier(wc)
+		if err != nil {
+			logrus.Errorf("%v", err)
+			continue
+		}
+		if config.Queue.Dir != "" {
+			// 无法打开持久化队列时仍在内存中排队发送
+			if w.durable, err = openWebhookQueue(config.Queue, w.config.Name); err != nil {
+				logrus.Errorf("Webhook %s: %v, events are queued in memory only", w.config.Name, err)
+			} else if n := w.durable.len(); n > 0 {
+				logrus.Infof("Webhook %s: %d undelivered event(s) queued from before", w.config.Name, n)
+			}
+		}
+		registerEventSink(w)
+		go runGuarded(ctx, "webhook "+w.config.Name, "", w.Run)
+	}
+	if config.SMTP.Server != "" {
+		mail := newSMTPNotifier(config.SMTP)
+		registerEventSink(mail)
+		go runGuarded(ctx, "mail notifier", "", mail.Run)
+	}
+	if config.EventLog.Enable {
+		sink, err := newEventLogSink(config.EventLog)
+		if err != nil {
+			logrus.Errorf("Event log output disabled: %v", err)
+			return
+		}
+		registerEventSink(sink)
+	}
+}
@@ -0,0 +1,44 @@
+# 由 make rpm 调用：二进制文件和其他文件已在 _sourcedir 中准备好，不在这里编译
+Name:           processmonitor
+Version:        %{pkg_version}
+Release:        1
+Summary:        Process monitor watchdog
+License:        MIT
+Requires:       systemd
+%{?systemd_requires}
+
+%global debug_package %{nil}
+
+%description
+Monitors configured processes, ports and health checks and restarts
+them automatically. Runs as the processmonitor systemd service.
+
+%install
+install -D -m 0755 %{_sourcedir}/processmonitor %{buildroot}/usr/bin/processmonitor
+install -D -m 0644 %{_sourcedir}/processmonitor.service %{buildroot}%{_unitdir}/processmonitor.service
+install -D -m 0640 %{_sourcedir}/config.yaml %{buildroot}/etc/processmonitor/config.yaml
+install -D -m 0644 %{_sourcedir}/config_example.yaml %{buildroot}/usr/share/doc/processmonitor/config_example.yaml
+install -D -m 0644 %{_sourcedir}/README.md %{buildroot}/usr/share/doc/processmonitor/README.md
+install -d -m 0750 %{buildroot}/var/lib/processmonitor %{buildroot}/var/log/processmonitor
+
+%files
+/usr/bin/processmonitor
+%{_unitdir}/processmonitor.service
+%dir /etc/processmonitor
+%config(noreplace) /etc/processmonitor/config.yaml
+/usr/share/doc/processmonitor
+%dir /var/lib/processmonitor
+%dir /var/log/processmonitor
+
+%post
+# 首次安装时开机启动并立即启动，升级时由 %postun 重启
+%systemd_post processmonitor.service
+if [ $1 -eq 1 ] && [ -d /run/systemd/system ]; then
+    systemctl enable --now processmonitor.service || :
+fi
+
+%preun
+%systemd_preun processmonitor.service
+
+%postun
+%systemd_postun_with_restart processmonitor.service
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+// 安装包中的默认配置必须能直接启动
+func TestPackagedConfigsAreValid(t *testing.T) {
+	for _, path := range []string{"packaging/linux/config.yaml", "packaging/windows/config.yaml"} {
+		config, err := loadConfig(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		applyConfigDefaults(&config)
+		if err := validateConfig(config); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		if config.API.Listen == "" || config.Logging.File == "" {
+			t.Errorf("%s: api.listen and logging.file should be set", path)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	defaultPingCount = 3
+	pingPayload      = "processmonitor"
+)
+
+// ICMP echo message types
+const (
+	icmpEchoReply     = 0
+	icmpEchoRequest   = 8
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// pingSocket is an ICMP socket for one address family
+type pingSocket struct {
+	conn     net.PacketConn
+	v6       bool
+	datagram bool // 非特权的 ICMP 数据报套接字，内核改写 ID，只按序号匹配
+}
+
+// listenPing opens an unprivileged ICMP datagram socket and falls back to a
+// raw socket, which needs root or administrator rights
+func listenPing(ip net.IP) (*pingSocket, error) {
+	s := &pingSocket{v6: ip.To4() == nil}
+	conn, err := listenICMPDatagram(s.v6)
+	if err == nil {
+		s.conn, s.datagram = conn, true
+		return s, nil
+	}
+	network, addr := "ip4:icmp", "0.0.0.0"
+	if s.v6 {
+		network, addr = "ip6:ipv6-icmp", "::"
+	}
+	conn, rawErr := net.ListenPacket(network, addr)
+	if rawErr != nil {
+		return nil, fmt.Errorf("cannot open an ICMP socket (unprivileged: %v; raw: %v)", err, rawErr)
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// icmpChecksum is the Internet checksum (RFC 1071)
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// echoRequest encodes an ICMP echo request. The kernel computes the
+// checksum of ICMPv6 messages itself.
+func (s *pingSocket) echoRequest(id, seq int) []byte {
+	msg := make([]byte, 8, 8+len(pingPayload))
+	msg[0] = icmpEchoRequest
+	if s.v6 {
+		msg[0] = icmpv6EchoRequest
+	}
+	binary.BigEndian.PutUint16(msg[4:], uint16(id))
+	binary.BigEndian.PutUint16(msg[6:], uint16(seq))
+	msg = append(msg, pingPayload...)
+	if !s.v6 {
+		binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+	}
+	return msg
+}
+
+// isReply reports whether msg is the echo reply to the request id/seq
+func (s *pingSocket) isReply(msg []byte, id, seq int) bool {
+	want := byte(icmpEchoReply)
+	if s.v6 {
+		want = icmpv6EchoReply
+	}
+	if len(msg) < 8 || msg[0] != want || string(msg[8:]) != pingPayload {
+		return false
+	}
+	return int(binary.BigEndian.Uint16(msg[6:])) == seq && (s.datagram || int(binary.BigEndian.Uint16(msg[4:])) == id&0xffff)
+}
+
+// ping sends up to count echo requests to addr, one after the other, and
+// succeeds on the first reply. The time left until the deadline of ctx is
+// shared between the requests still to send.
+func ping(ctx context.Context, addr string, count int) error {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", addr)
+		if err != nil {
+			return err
+		}
+		ip = ips[0]
+	}
+	s, err := listenPing(ip)
+	if err != nil {
+		return err
+	}
+	defer s.conn.Close()
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if s.datagram {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Duration(defaultCheckTimeout) * time.Second)
+	}
+	id := os.Getpid() & 0xffff
+	buf := make([]byte, 1500)
+	for seq := 1; seq <= count; seq++ {
+		if _, err := s.conn.WriteTo(s.echoRequest(id, seq), dst); err != nil {
+			return err
+		}
+		s.conn.SetReadDeadline(time.Now().Add(time.Until(deadline) / time.Duration(count-seq+1)))
+		for {
+			n, peer, err := s.conn.ReadFrom(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if s.isReply(buf[:n], id, seq) && peerIP(peer).Equal(ip) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no reply to %d echo request(s)", count)
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// runPing pings every address of the check host until one replies
+func (c HealthCheck) runPing(config ProcessConfig, timeout time.Duration) error {
+	count := defaultInt(c.Count, defaultPingCount)
+	return tryAddresses(config.Name, c, c.Address, timeout, func(ctx context.Context, addr string) error {
+		return ping(ctx, addr, count)
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEchoRequest(t *testing.T) {
+	s := &pingSocket{}
+	msg := s.echoRequest(0x1234, 2)
+	if msg[0] != icmpEchoRequest || icmpChecksum(msg) != 0 || string(msg[8:]) != pingPayload {
+		t.Errorf("echo request = % x", msg)
+	}
+	reply := append([]byte(nil), msg...)
+	reply[0] = icmpEchoReply
+	if !s.isReply(reply, 0x1234, 2) || s.isReply(reply, 0x1234, 3) || s.isReply(reply, 0x4321, 2) || s.isReply(msg, 0x1234, 2) {
+		t.Error("isReply() matched the wrong messages")
+	}
+	// 数据报套接字的 ID 由内核改写
+	s.datagram = true
+	if !s.isReply(reply, 0x4321, 2) {
+		t.Error("isReply() on a datagram socket compared the ID")
+	}
+}
+
+func TestPingCheck(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ping(ctx, "127.0.0.1", 2); err != nil {
+		if strings.Contains(err.Error(), "cannot open an ICMP socket") {
+			t.Skip(err)
+		}
+		t.Fatalf("ping 127.0.0.1: %v", err)
+	}
+	check := HealthCheck{Type: CheckPing, Address: "127.0.0.1", Timeout: 2}
+	if err := check.run(ProcessConfig{Name: "web.exe"}, 0); err != nil {
+		t.Errorf("ping check: %v", err)
+	}
+}
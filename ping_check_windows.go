@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// listenICMPDatagram is not supported: Windows has no unprivileged ICMP
+// sockets, pinging needs a raw socket and administrator rights
+func listenICMPDatagram(v6 bool) (net.PacketConn, error) {
+	return nil, fmt.Errorf("unprivileged ICMP sockets are not supported on Windows")
+}
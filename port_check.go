@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"gopkg.in/yaml.v3"
+)
+
+// 除 ports（TCP）外的端口检查类型
+const (
+	PortCheckUDP  = "udp"  // 本机有套接字绑定了这个 UDP 端口（DNS、syslog 接收等）
+	PortCheckUnix = "unix" // Unix 域套接字可以连接
+	PortCheckPipe = "pipe" // Windows 命名管道存在
+)
+
+const pipePrefix = `\\.\pipe\`
+
+// portCheckTimeout 连接 Unix 域套接字的超时
+const portCheckTimeout = 2 * time.Second
+
+// PortCheck is a port check for services that do not listen on TCP: a UDP
+// port, a Unix domain socket or a Windows named pipe. Like ports, a failed
+// port check restarts the process at once.
+type PortCheck struct {
+	Type string `yaml:"type"` // udp、unix 或 pipe
+	Port int    `yaml:"port"` // udp：端口
+	Path string `yaml:"path"` // unix：套接字路径；pipe：管道名或 \\.\pipe\ 路径
+}
+
+// parsePortCheck parses the string form: udp:<port>, unix:<path> or
+// pipe:<name>
+func parsePortCheck(s string) PortCheck {
+	typ, value, _ := strings.Cut(s, ":")
+	c := PortCheck{Type: typ}
+	switch typ {
+	case PortCheckUDP:
+		c.Port, _ = strconv.Atoi(value)
+	default:
+		c.Path = value
+	}
+	return c
+}
+
+// UnmarshalYAML accepts both the string and the structured form
+func (c *PortCheck) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = parsePortCheck(node.Value)
+		return nil
+	}
+	type plain PortCheck
+	return node.Decode((*plain)(c))
+}
+
+// String returns the check in its string form, used in logs, events and
+// check latencies
+func (c PortCheck) String() string {
+	if c.Type == PortCheckUDP {
+		return "udp:" + strconv.Itoa(c.Port)
+	}
+	return c.Type + ":" + c.Path
+}
+
+func (c PortCheck) validate() error {
+	switch c.Type {
+	case PortCheckUDP:
+		if c.Port <= 0 || c.Port > 65535 {
+			return fmt.Errorf("port check %s: invalid port %d", c, c.Port)
+		}
+	case PortCheckUnix:
+		if c.Path == "" {
+			return fmt.Errorf("port check unix: requires a socket path")
+		}
+	case PortCheckPipe:
+		if c.Path == "" {
+			return fmt.Errorf("port check pipe: requires a pipe name")
+		}
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("port check %s: named pipes are only supported on Windows", c)
+		}
+	default:
+		return fmt.Errorf("port check %q must be udp:<port>, unix:<path> or pipe:<name>", c.String())
+	}
+	return nil
+}
+
+// run reports an error when nothing serves the port, socket or pipe
+func (c PortCheck) run() error {
+	switch c.Type {
+	case PortCheckUDP:
+		bound, err := isUDPPortBound(c.Port)
+		if err != nil {
+			return err
+		}
+		if !bound {
+			return fmt.Errorf("udp port %d is not in use", c.Port)
+		}
+	case PortCheckUnix:
+		conn, err := net.DialTimeout("unix", c.Path, portCheckTimeout)
+		if err != nil {
+			return fmt.Errorf("unix socket %s: %v", c.Path, err)
+		}
+		conn.Close()
+	case PortCheckPipe:
+		name := c.Path
+		if !strings.HasPrefix(name, pipePrefix) {
+			name = pipePrefix + name
+		}
+		exists, err := pipeExists(name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("named pipe %s does not exist", name)
+		}
+	}
+	return nil
+}
+
+// isUDPPortBound reports whether a socket of this host is bound to the UDP
+// port. UDP has no handshake to probe, so the socket table is read instead.
+func isUDPPortBound(port int) (bool, error) {
+	bound := false
+	err := runWithTimeout("udp socket scan", seconds(opTimeouts.ProcessScan), func(ctx context.Context) error {
+		conns, err := psnet.ConnectionsWithContext(ctx, "udp")
+		if err != nil {
+			return err
+		}
+		for _, c := range conns {
+			if int(c.Laddr.Port) == port {
+				bound = true
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list udp sockets: %v", err)
+	}
+	return bound, nil
+}
+
+// portChecks returns the checks of ports and port_checks of config, in
+// that order
+func portChecks(config ProcessConfig) []namedCheck {
+	checks := make([]namedCheck, 0, len(config.Ports)+len(config.PortChecks))
+	for _, port := range config.Ports {
+		port := port
+		checks = append(checks, namedCheck{name: fmt.Sprintf("port:%d", port), run: func() error {
+			if !isPortInUse(port) {
+				return fmt.Errorf("port %d is not in use", port)
+			}
+			return nil
+		}})
+	}
+	for _, check := range config.PortChecks {
+		check := check
+		checks = append(checks, namedCheck{name: check.String(), run: check.run})
+	}
+	return checks
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// pipeExists is not supported: named pipes exist only on Windows
+func pipeExists(name string) (bool, error) {
+	return false, fmt.Errorf("named pipes are only supported on Windows")
+}
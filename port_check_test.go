@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParsePortCheck(t *testing.T) {
+	var config struct {
+		PortChecks []PortCheck `yaml:"port_checks"`
+	}
+	data := `port_checks: ["udp:53", "unix:/run/app.sock", "pipe:app", {type: udp, port: 514}]`
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	want := []PortCheck{
+		{Type: PortCheckUDP, Port: 53},
+		{Type: PortCheckUnix, Path: "/run/app.sock"},
+		{Type: PortCheckPipe, Path: "app"},
+		{Type: PortCheckUDP, Port: 514},
+	}
+	if len(config.PortChecks) != len(want) {
+		t.Fatalf("port_checks = %+v", config.PortChecks)
+	}
+	for i, c := range config.PortChecks {
+		if c != want[i] {
+			t.Errorf("port_checks[%d] = %+v, want %+v", i, c, want[i])
+		}
+	}
+	if got := config.PortChecks[0].String(); got != "udp:53" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, s := range []string{"udp:0", "udp:dns", "unix:", "tcp:8080", "/run/app.sock"} {
+		if err := parsePortCheck(s).validate(); err == nil {
+			t.Errorf("%q was accepted", s)
+		}
+	}
+	if err := parsePortCheck("pipe:app").validate(); (err == nil) != (runtime.GOOS == "windows") {
+		t.Errorf("pipe:app on %s: %v", runtime.GOOS, err)
+	}
+}
+
+func TestUDPPortCheck(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := PortCheck{Type: PortCheckUDP, Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	if err := check.run(); err != nil {
+		t.Errorf("bound udp port: %v", err)
+	}
+	conn.Close()
+	if err := check.run(); err == nil {
+		t.Error("closed udp port passed the check")
+	}
+}
+
+func TestUnixSocketPortCheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are checked on Linux")
+	}
+	path := filepath.Join(t.TempDir(), "app.sock")
+	check := PortCheck{Type: PortCheckUnix, Path: path}
+	if err := check.run(); err == nil {
+		t.Error("missing socket passed the check")
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := check.run(); err != nil {
+		t.Errorf("listening socket: %v", err)
+	}
+}
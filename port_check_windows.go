@@ -0,0 +1,25 @@
+package main
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// pipeExists reports whether the named pipe exists. The pipe file system is
+// listed instead of opening the pipe, which would take one of its instances
+// and show up as a client connection in the server.
+func pipeExists(name string) (bool, error) {
+	path, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return false, err
+	}
+	var data windows.Win32finddata
+	h, err := windows.FindFirstFile(path, &data)
+	if err == windows.ERROR_FILE_NOT_FOUND {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	windows.FindClose(h)
+	return true, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// 启动前发现端口被占用时的处理方式
+const (
+	PortConflictWait = "wait" // 等待端口释放后再启动，超时则启动失败
+	PortConflictKill = "kill" // 结束程序路径相符的占用进程（如未退出的旧实例），其他占用者同 wait
+	PortConflictFail = "fail" // 立即启动失败
+)
+
+const defaultPortConflictTimeout = 30
+
+// PortConflictConfig 启动前检查 ports 是否已被占用（未退出的旧实例、继承了监听套接字的孙进程等），
+// 而不是启动一个立即因端口绑定失败而退出的进程
+type PortConflictConfig struct {
+	Action    string   `yaml:"action"`     // wait、kill 或 fail；为空不检查（以前的行为）
+	Timeout   int      `yaml:"timeout"`    // 等待端口释放的时间（秒，默认30）
+	KillPaths []string `yaml:"kill_paths"` // kill 时还允许结束的程序路径（进程自己的程序总是允许）
+}
+
+func (c PortConflictConfig) validate(config ProcessConfig) error {
+	if len(c.KillPaths) > 0 && c.Action != PortConflictKill {
+		return fmt.Errorf("port_conflict.kill_paths requires action kill")
+	}
+	switch c.Action {
+	case "":
+		return nil
+	case PortConflictWait, PortConflictKill, PortConflictFail:
+	default:
+		return fmt.Errorf("port_conflict.action must be wait, kill or fail, got %q", c.Action)
+	}
+	if len(config.Ports) == 0 {
+		return fmt.Errorf("port_conflict requires ports")
+	}
+	if config.NetNS.Enable {
+		return fmt.Errorf("port_conflict cannot be used with netns, whose port forwards hold the ports")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("port_conflict.timeout must not be negative")
+	}
+	return nil
+}
+
+// portHolder is a process listening on one of the ports of a process
+type portHolder struct {
+	Port int
+	PID  int32 // 0 表示无法确定（权限不足时）
+	Exe  string
+}
+
+func (h portHolder) String() string {
+	if h.PID == 0 {
+		return fmt.Sprintf("port %d is in use by an unknown process", h.Port)
+	}
+	return fmt.Sprintf("port %d is in use by %s (PID: %d)", h.Port, h.Exe, h.PID)
+}
+
+// findPortHolders returns the listeners on ports. When the connection table
+// cannot be read, ports that accept connections are reported without a PID.
+func findPortHolders(ports []int) []portHolder {
+	wanted := make(map[int]bool, len(ports))
+	for _, port := range ports {
+		wanted[port] = true
+	}
+	var holders []portHolder
+	err := runWithTimeout("port scan", seconds(opTimeouts.ProcessScan), func(ctx context.Context) error {
+		conns, err := psnet.ConnectionsWithContext(ctx, "tcp")
+		if err != nil {
+			return err
+		}
+		seen := make(map[int]bool)
+		for _, c := range conns {
+			port := int(c.Laddr.Port)
+			if c.Status != "LISTEN" || !wanted[port] || seen[port] {
+				continue
+			}
+			seen[port] = true
+			h := portHolder{Port: port, PID: c.Pid}
+			if p, err := process.NewProcessWithContext(ctx, c.Pid); c.Pid != 0 && err == nil {
+				h.Exe, _ = p.ExeWithContext(ctx)
+			}
+			holders = append(holders, h)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Warnf("Failed to list listening ports: %v", err)
+		holders = nil
+		for _, port := range ports {
+			if isPortInUse(port) {
+				holders = append(holders, portHolder{Port: port})
+			}
+		}
+	}
+	return holders
+}
+
+// killablePortHolder reports whether h runs the program of the process or
+// one of port_conflict.kill_paths, so port_conflict kill may end it
+func killablePortHolder(config ProcessConfig, h portHolder) bool {
+	if h.PID == 0 || h.PID == int32(os.Getpid()) || h.Exe == "" {
+		return false
+	}
+	paths := config.PortConflict.KillPaths
+	if exe, err := executablePath(config); err == nil {
+		paths = append([]string{exe}, paths...)
+	}
+	for _, path := range paths {
+		if samePath(path, h.Exe) {
+			return true
+		}
+	}
+	return false
+}
+
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// resolvePortConflicts makes sure the ports of the process are free before
+// it is started, as configured by port_conflict: it waits for them to be
+// released and, with kill, ends holders running the process's own program.
+func (s *ProcessSupervisor) resolvePortConflicts() error {
+	c := s.config.PortConflict
+	if c.Action == "" {
+		return nil
+	}
+	// 自己的端口占位不算冲突
+	s.releasePorts()
+	timeout := seconds(defaultInt(c.Timeout, defaultPortConflictTimeout))
+	deadline := time.Now().Add(timeout)
+	killed := make(map[int32]bool)
+	logged := false
+	for {
+		holders := findPortHolders(s.config.Ports)
+		if len(holders) == 0 {
+			return nil
+		}
+		if c.Action == PortConflictFail || !time.Now().Before(deadline) {
+			s.emitPortConflict(holders[0], "start_failed")
+			return fmt.Errorf("%v", holders[0])
+		}
+		if c.Action == PortConflictKill {
+			for _, h := range holders {
+				if killed[h.PID] || !killablePortHolder(s.config, h) {
+					continue
+				}
+				killed[h.PID] = true
+				s.killPortHolder(h)
+			}
+		}
+		if !logged {
+			logrus.Warnf("Delaying start of %s: %v, waiting up to %v", s.config.Name, holders[0], timeout)
+			logged = true
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// killPortHolder ends a stale instance holding a port: it is asked to
+// terminate, and killed if it still holds the port after stop_timeout
+func (s *ProcessSupervisor) killPortHolder(h portHolder) {
+	logrus.Warnf("Terminating %s (PID: %d) holding port %d of %s", h.Exe, h.PID, h.Port, s.config.Name)
+	s.emitPortConflict(h, "killed")
+	p, err := process.NewProcess(h.PID)
+	if err != nil {
+		return
+	}
+	if err := p.Terminate(); err == nil {
+		deadline := time.Now().Add(stopTimeout(s.config))
+		for time.Now().Before(deadline) {
+			if running, err := p.IsRunning(); err != nil || !running {
+				return
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+	if err := killWithTimeout(h.Exe, p); err != nil {
+		logrus.Errorf("Failed to kill %s (PID: %d) holding port %d: %v", h.Exe, h.PID, h.Port, err)
+	}
+}
+
+func (s *ProcessSupervisor) emitPortConflict(h portHolder, action string) {
+	severity := SeverityWarning
+	if action == "start_failed" {
+		severity = SeverityCritical
+	}
+	emitEvent(Event{
+		Severity: severity,
+		Type:     "port_conflict",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Process %s: %v", s.config.Name, h),
+		Details: map[string]string{
+			"port":   strconv.Itoa(h.Port),
+			"pid":    strconv.Itoa(int(h.PID)),
+			"holder": h.Exe,
+			"action": action,
+		},
+	})
+}
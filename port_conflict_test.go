@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPortConflictValidate(t *testing.T) {
+	tests := []struct {
+		config ProcessConfig
+		ok     bool
+	}{
+		{ProcessConfig{}, true},
+		{ProcessConfig{Ports: []int{8080}, PortConflict: PortConflictConfig{Action: PortConflictWait, Timeout: 10}}, true},
+		{ProcessConfig{Ports: []int{8080}, PortConflict: PortConflictConfig{Action: PortConflictKill, KillPaths: []string{"/opt/app/old/server"}}}, true},
+		{ProcessConfig{Ports: []int{8080}, PortConflict: PortConflictConfig{Action: "steal"}}, false},
+		{ProcessConfig{PortConflict: PortConflictConfig{Action: PortConflictFail}}, false},
+		{ProcessConfig{Ports: []int{8080}, PortConflict: PortConflictConfig{Action: PortConflictWait, KillPaths: []string{"/bin/x"}}}, false},
+		{ProcessConfig{Ports: []int{8080}, PortConflict: PortConflictConfig{Action: PortConflictWait, Timeout: -1}}, false},
+		{ProcessConfig{Ports: []int{8080}, NetNS: NetNSConfig{Enable: true}, PortConflict: PortConflictConfig{Action: PortConflictWait}}, false},
+	}
+	for i, tt := range tests {
+		if err := tt.config.PortConflict.validate(tt.config); (err == nil) != tt.ok {
+			t.Errorf("case %d: err = %v, want ok = %v", i, err, tt.ok)
+		}
+	}
+}
+
+func TestKillablePortHolder(t *testing.T) {
+	dir := t.TempDir()
+	config := ProcessConfig{Name: "server", WorkDir: dir, PortConflict: PortConflictConfig{KillPaths: []string{"/opt/old/server"}}}
+	tests := []struct {
+		holder portHolder
+		want   bool
+	}{
+		{portHolder{PID: 4242, Exe: filepath.Join(dir, "server")}, true},
+		{portHolder{PID: 4242, Exe: "/opt/old/server"}, true},
+		{portHolder{PID: 4242, Exe: "/usr/sbin/nginx"}, false},
+		{portHolder{PID: 0, Exe: filepath.Join(dir, "server")}, false},
+		{portHolder{PID: int32(os.Getpid()), Exe: filepath.Join(dir, "server")}, false},
+	}
+	for _, tt := range tests {
+		if got := killablePortHolder(config, tt.holder); got != tt.want {
+			t.Errorf("killablePortHolder(%+v) = %v, want %v", tt.holder, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePortConflicts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	holders := findPortHolders([]int{port})
+	if len(holders) != 1 || holders[0].Port != port {
+		t.Fatalf("holders = %+v, want the test listener on port %d", holders, port)
+	}
+
+	s := NewProcessSupervisor(ProcessConfig{Name: "api", Ports: []int{port}, PortConflict: PortConflictConfig{Action: PortConflictFail}})
+	if err := s.resolvePortConflicts(); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("fail with the port held: err = %v", err)
+	}
+
+	// wait 在端口释放后继续启动
+	s = NewProcessSupervisor(ProcessConfig{Name: "api", Ports: []int{port}, PortConflict: PortConflictConfig{Action: PortConflictWait, Timeout: 10}})
+	time.AfterFunc(500*time.Millisecond, func() { ln.Close() })
+	if err := s.resolvePortConflicts(); err != nil {
+		t.Errorf("wait after the port was released: %v", err)
+	}
+}
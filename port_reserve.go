@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 端口占位模式
+const (
+	ReserveModeHTTP = "http" // 对所有请求返回 503 Service Unavailable（默认）
+	ReserveModeTCP  = "tcp"  // 接受连接后立即关闭
+)
+
+const defaultReserveRetryAfter = 10
+
+// PortReserveConfig 进程停止期间由监控程序占用它的端口，负载均衡器立即得到"不可用"的响应，而不是连接超时
+type PortReserveConfig struct {
+	Enable     bool   `yaml:"enable"`
+	Mode       string `yaml:"mode"`        // http（默认，返回503）或 tcp（接受连接后立即关闭）
+	Ports      []int  `yaml:"ports"`       // 占用的端口（默认为进程的 ports）
+	Bind       string `yaml:"bind"`        // 监听地址（默认所有地址）
+	RetryAfter int    `yaml:"retry_after"` // HTTP 503 响应的 Retry-After（秒，默认10）
+}
+
+func (c PortReserveConfig) validate(config ProcessConfig) error {
+	if !c.Enable {
+		return nil
+	}
+	switch c.Mode {
+	case "", ReserveModeHTTP, ReserveModeTCP:
+	default:
+		return fmt.Errorf("port_reserve.mode must be http or tcp, got %q", c.Mode)
+	}
+	if len(c.Ports) == 0 && len(config.Ports) == 0 {
+		return fmt.Errorf("port_reserve requires ports")
+	}
+	for _, port := range c.Ports {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("port_reserve: invalid port %d", port)
+		}
+	}
+	if config.NetNS.Enable {
+		return fmt.Errorf("port_reserve cannot be used with netns, whose port forwards stay open across restarts")
+	}
+	if c.RetryAfter < 0 {
+		return fmt.Errorf("port_reserve.retry_after must not be negative")
+	}
+	return nil
+}
+
+// portReserver holds the ports of a process while it is down. The monitor
+// listens after stopping the process and closes the listeners right before
+// starting it again, so the new instance can bind them.
+type portReserver struct {
+	name   string
+	config PortReserveConfig
+	ports  []int
+
+	mu      sync.Mutex
+	closers []func() error
+}
+
+func newPortReserver(config ProcessConfig) *portReserver {
+	if !config.PortReserve.Enable {
+		return nil
+	}
+	ports := config.PortReserve.Ports
+	if len(ports) == 0 {
+		ports = config.Ports
+	}
+	return &portReserver{name: config.Name, config: config.PortReserve, ports: ports}
+}
+
+// hold starts listening on the ports that are not held yet. A port still
+// in use (by an instance that did not exit) is skipped with a warning.
+func (r *portReserver) hold() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.closers) > 0 {
+		return
+	}
+	for _, port := range r.ports {
+		addr := net.JoinHostPort(r.config.Bind, strconv.Itoa(port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			logrus.Warnf("Cannot reserve port %d of %s while it is down: %v", port, r.name, err)
+			continue
+		}
+		if r.config.Mode == ReserveModeTCP {
+			go rejectConnections(ln)
+			r.closers = append(r.closers, ln.Close)
+		} else {
+			srv := &http.Server{Handler: r.unavailable(), ReadHeaderTimeout: 5 * time.Second}
+			go srv.Serve(ln)
+			r.closers = append(r.closers, srv.Close)
+		}
+	}
+	if len(r.closers) > 0 {
+		logrus.Infof("Reserving %d port(s) of %s while it is down (%s)", len(r.closers), r.name, r.mode())
+	}
+}
+
+// release closes the listeners so the process can bind its ports
+func (r *portReserver) release() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.closers) == 0 {
+		return
+	}
+	for _, close := range r.closers {
+		close()
+	}
+	r.closers = nil
+	logrus.Infof("Released the reserved ports of %s", r.name)
+}
+
+// holding reports whether any port is currently held
+func (r *portReserver) holding() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.closers) > 0
+}
+
+func (r *portReserver) mode() string {
+	if r.config.Mode == "" {
+		return ReserveModeHTTP
+	}
+	return r.config.Mode
+}
+
+// unavailable answers every request with 503 and closes the connection
+func (r *portReserver) unavailable() http.Handler {
+	retryAfter := strconv.Itoa(defaultInt(r.config.RetryAfter, defaultReserveRetryAfter))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", retryAfter)
+		w.Header().Set("Connection", "close")
+		http.Error(w, r.name+" is not running", http.StatusServiceUnavailable)
+	})
+}
+
+// rejectConnections accepts and immediately closes connections until ln is closed
+func rejectConnections(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+}
+
+// reservePorts holds the ports of the stopped process
+func (s *ProcessSupervisor) reservePorts() {
+	if s.reserver == nil {
+		return
+	}
+	s.reserver.hold()
+	held := s.reserver.holding()
+	s.updateStatus(func(st *ProcessStatus) { st.PortsReserved = held })
+}
+
+// releasePorts frees the reserved ports before the process starts
+func (s *ProcessSupervisor) releasePorts() {
+	if s.reserver == nil {
+		return
+	}
+	s.reserver.release()
+	s.updateStatus(func(st *ProcessStatus) { st.PortsReserved = false })
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// freePort returns a TCP port that was free a moment ago
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestPortReserverHTTP(t *testing.T) {
+	port := freePort(t)
+	s := NewProcessSupervisor(ProcessConfig{
+		Name:        "web",
+		Ports:       []int{port},
+		PortReserve: PortReserveConfig{Enable: true, Bind: "127.0.0.1", RetryAfter: 30},
+	})
+	s.reservePorts()
+	if !s.Status().PortsReserved {
+		t.Fatal("ports_reserved not set")
+	}
+
+	resp, err := http.Get("http://127.0.0.1:" + strconv.Itoa(port) + "/api/orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "30" {
+		t.Errorf("response = %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// 释放后进程可以绑定端口
+	s.releasePorts()
+	ln, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatalf("port still held after release: %v", err)
+	}
+	// 端口被其他程序占用时跳过
+	s.reservePorts()
+	if s.Status().PortsReserved {
+		t.Error("port in use was reported as reserved")
+	}
+	ln.Close()
+}
+
+func TestPortReserverTCP(t *testing.T) {
+	port := freePort(t)
+	r := newPortReserver(ProcessConfig{
+		Name:        "db",
+		PortReserve: PortReserveConfig{Enable: true, Mode: ReserveModeTCP, Bind: "127.0.0.1", Ports: []int{port}},
+	})
+	r.hold()
+	defer r.release()
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(port), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read = %v, want EOF", err)
+	}
+}
+
+func TestPortReserveValidate(t *testing.T) {
+	tests := []struct {
+		config ProcessConfig
+		ok     bool
+	}{
+		{ProcessConfig{Ports: []int{8080}, PortReserve: PortReserveConfig{Enable: true}}, true},
+		{ProcessConfig{PortReserve: PortReserveConfig{Enable: true}}, false},
+		{ProcessConfig{Ports: []int{8080}, PortReserve: PortReserveConfig{Enable: true, Mode: "udp"}}, false},
+		{ProcessConfig{Ports: []int{8080}, PortReserve: PortReserveConfig{Enable: true}, NetNS: NetNSConfig{Enable: true}}, false},
+		{ProcessConfig{PortReserve: PortReserveConfig{Mode: "udp"}}, true},
+	}
+	for i, tt := range tests {
+		if err := tt.config.PortReserve.validate(tt.config); (err == nil) != tt.ok {
+			t.Errorf("case %d: err = %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// PrivilegedHelperConfig 最小权限模式配置：
+// 主监控进程以普通权限运行，注册表写回和结束特权进程交给提升权限的助手进程执行
+type PrivilegedHelperConfig struct {
+	Enable    bool   `yaml:"enable"`     // 是否启用最小权限模式
+	Listen    string `yaml:"listen"`     // 助手监听地址（仅限本机，默认 127.0.0.1:9510）
+	TokenFile string `yaml:"token_file"` // 共享密钥文件（默认 helper.token，应只允许管理员和监控账户读取）
+}
+
+const (
+	defaultHelperListen    = "127.0.0.1:9510"
+	defaultHelperTokenFile = "helper.token"
+	helperMaxClockSkew     = 30 * time.Second
+)
+
+// privilegedHelper is the client used by the monitor when least-privilege mode is on
+var privilegedHelper *helperClient
+
+// helperRequest is the body of every helper call. The helper only accepts
+// references to entries of its own config, never raw values or paths.
+type helperRequest struct {
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`             // 每个请求唯一，助手拒绝重复的 nonce（防重放）
+	Monitor   string `json:"monitor,omitempty"` // 注册表监控名称
+	Value     string `json:"value,omitempty"`   // 注册表值名称
+	Process   string `json:"process,omitempty"` // 进程配置名称
+	PID       int32  `json:"pid,omitempty"`
+}
+
+func helperDefaults(config PrivilegedHelperConfig) PrivilegedHelperConfig {
+	if config.Listen == "" {
+		config.Listen = defaultHelperListen
+	}
+	if config.TokenFile == "" {
+		config.TokenFile = defaultHelperTokenFile
+	}
+	return config
+}
+
+// signHelperRequest returns the hex HMAC-SHA256 of body under secret
+func signHelperRequest(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// loadHelperToken reads the shared secret, creating it when create is true
+func loadHelperToken(path string, create bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return nil, fmt.Errorf("helper token file %s is empty", path)
+		}
+		return []byte(token), nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read helper token: %v", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate helper token: %v", err)
+	}
+	token := hex.EncodeToString(buf)
+	if dir := filepath.Dir(path); dir != "." {
+		os.MkdirAll(dir, 0700)
+	}
+	if err := os.WriteFile(path, []byte(token), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write helper token: %v", err)
+	}
+	logrus.Infof("Generated new helper token in %s", path)
+	return []byte(token), nil
+}
+
+// helperClient talks to the elevated helper
+type helperClient struct {
+	baseURL string
+	secret  []byte
+	client  *http.Client
+}
+
+func newHelperClient(config PrivilegedHelperConfig) (*helperClient, error) {
+	config = helperDefaults(config)
+	secret, err := loadHelperToken(config.TokenFile, false)
+	if err != nil {
+		return nil, err
+	}
+	return &helperClient{
+		baseURL: apiBaseURL(config.Listen),
+		secret:  secret,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (c *helperClient) call(path string, req helperRequest) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	req.Timestamp = time.Now().Unix()
+	req.Nonce = hex.EncodeToString(nonce)
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Signature", signHelperRequest(c.secret, body))
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("privileged helper unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("privileged helper refused %s: %s", path, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// RestoreRegistryValue asks the helper to write the configured expected value
+func (c *helperClient) RestoreRegistryValue(monitor, value string) error {
+	return c.call("/registry/restore", helperRequest{Monitor: monitor, Value: value})
+}
+
+// KillProcess asks the helper to kill pid, which must belong to the named process
+func (c *helperClient) KillProcess(name string, pid int32) error {
+	return c.call("/process/kill", helperRequest{Process: name, PID: pid})
+}
+
+// nonceCache remembers the nonces of accepted requests until their
+// timestamps are too old to pass the clock skew check anyway
+type nonceCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // nonce -> 过期时间
+}
+
+// add records nonce and reports false when it was already used
+func (c *nonceCache) add(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	for n, expires := range c.seen {
+		if now.After(expires) {
+			delete(c.seen, n)
+		}
+	}
+	if _, ok := c.seen[nonce]; ok {
+		return false
+	}
+	// 时间戳允许前后各偏差 helperMaxClockSkew，在此之前同一请求都可能通过时间检查
+	c.seen[nonce] = now.Add(2 * helperMaxClockSkew)
+	return true
+}
+
+// helperServer is the elevated side of least-privilege mode
+type helperServer struct {
+	config Config
+	secret []byte
+	nonces nonceCache
+}
+
+// runHelperCommand implements "processmonitor helper": the elevated helper that
+// performs registry write-backs and privileged kills on behalf of the monitor.
+func runHelperCommand(config Config) error {
+	helperConfig := helperDefaults(config.PrivilegedHelper)
+
+	host, _, err := net.SplitHostPort(helperConfig.Listen)
+	if err != nil {
+		return fmt.Errorf("invalid helper listen address: %v", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("helper must listen on a loopback address, got %s", helperConfig.Listen)
+	}
+
+	secret, err := loadHelperToken(helperConfig.TokenFile, true)
+	if err != nil {
+		return err
+	}
+
+	h := &helperServer{config: config, secret: secret}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/registry/restore", h.authenticated(h.handleRegistryRestore))
+	mux.HandleFunc("/process/kill", h.authenticated(h.handleKill))
+
+	server := &http.Server{
+		Addr:              helperConfig.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logrus.Infof("Privileged helper listening on %s", helperConfig.Listen)
+	return server.ListenAndServe()
+}
+
+// authenticated verifies the HMAC signature, timestamp and nonce before
+// calling next, so a captured request cannot be sent again
+func (h *helperServer) authenticated(next func(http.ResponseWriter, helperRequest)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		expected := signHelperRequest(h.secret, body)
+		if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Signature"))) {
+			logrus.Warnf("Privileged helper: rejected request with invalid signature from %s", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var req helperRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		skew := time.Since(time.Unix(req.Timestamp, 0))
+		if skew > helperMaxClockSkew || skew < -helperMaxClockSkew {
+			http.Error(w, "request expired", http.StatusUnauthorized)
+			return
+		}
+		if req.Nonce == "" || !h.nonces.add(req.Nonce, time.Now()) {
+			logrus.Warnf("Privileged helper: rejected replayed request from %s", r.RemoteAddr)
+			http.Error(w, "request already used", http.StatusUnauthorized)
+			return
+		}
+		next(w, req)
+	}
+}
+
+func (h *helperServer) handleRegistryRestore(w http.ResponseWriter, req helperRequest) {
+	for _, rm := range h.config.RegistryMonitors {
+		if rm.Name != req.Monitor {
+			continue
+		}
+		for _, v := range rm.Values {
+			if v.Name != req.Value || v.ExpectValue == nil {
+				continue
+			}
+			if err := restoreRegistryValueDirect(rm, v); err != nil {
+				logrus.Errorf("Privileged helper: failed to restore %s\\%s\\%s: %v", rm.RootKey, rm.Path, v.Name, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			logrus.Infof("Privileged helper: restored %s\\%s\\%s", rm.RootKey, rm.Path, v.Name)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	http.Error(w, "value is not configured with an expected value", http.StatusForbidden)
+}
+
+func (h *helperServer) handleKill(w http.ResponseWriter, req helperRequest) {
+	var matcher processMatcher
+	configured := false
+	for _, p := range h.config.Processes {
+		if p.Name == req.Process {
+			m, err := newProcessMatcher(p)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			matcher, configured = m, true
+			break
+		}
+	}
+	if !configured {
+		http.Error(w, "process is not configured", http.StatusForbidden)
+		return
+	}
+
+	p, err := process.NewProcess(req.PID)
+	if err != nil {
+		http.Error(w, "no such process", http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(opTimeouts.ProcessScan))
+	defer cancel()
+	if !matcher.matches(ctx, p) {
+		logrus.Warnf("Privileged helper: refused to kill PID %d, it does not match %s", req.PID, req.Process)
+		http.Error(w, "pid does not match process", http.StatusForbidden)
+		return
+	}
+
+	if err := p.Kill(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logrus.Infof("Privileged helper: killed %s (PID: %d)", req.Process, req.PID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// initPrivilegedHelper connects the monitor to the helper when least-privilege mode is on
+func initPrivilegedHelper(config PrivilegedHelperConfig) {
+	if !config.Enable {
+		return
+	}
+	client, err := newHelperClient(config)
+	if err != nil {
+		logrus.Errorf("Least-privilege mode: cannot use privileged helper: %v", err)
+		return
+	}
+	privilegedHelper = client
+	logrus.Infof("Least-privilege mode: registry write-backs and privileged kills go through helper at %s",
+		helperDefaults(config).Listen)
+}
+
+// killProcessWithHelper kills p directly and falls back to the helper on failure
+func killProcessWithHelper(name string, p *process.Process) error {
+	err := p.Kill()
+	if err == nil || privilegedHelper == nil {
+		return err
+	}
+	logrus.Debugf("Direct kill of PID %d failed (%v), delegating to privileged helper", p.Pid, err)
+	return privilegedHelper.KillProcess(name, p.Pid)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedHelperRequest builds a helper request as the client sends it
+func signedHelperRequest(t *testing.T, secret []byte, req helperRequest) *http.Request {
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/process/kill", strings.NewReader(string(body)))
+	r.Header.Set("X-Signature", signHelperRequest(secret, body))
+	return r
+}
+
+func TestHelperAuthentication(t *testing.T) {
+	secret := []byte("secret")
+	h := &helperServer{secret: secret}
+	calls := 0
+	handler := h.authenticated(func(w http.ResponseWriter, req helperRequest) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	})
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    int
+	}{
+		{"valid", func() *http.Request {
+			return signedHelperRequest(t, secret, helperRequest{Timestamp: now, Nonce: "n1"})
+		}, http.StatusOK},
+		{"replay", func() *http.Request {
+			return signedHelperRequest(t, secret, helperRequest{Timestamp: now, Nonce: "n1"})
+		}, http.StatusUnauthorized},
+		{"no nonce", func() *http.Request {
+			return signedHelperRequest(t, secret, helperRequest{Timestamp: now})
+		}, http.StatusUnauthorized},
+		{"bad signature", func() *http.Request {
+			return signedHelperRequest(t, []byte("guessed"), helperRequest{Timestamp: now, Nonce: "n2"})
+		}, http.StatusUnauthorized},
+		{"tampered body", func() *http.Request {
+			r := signedHelperRequest(t, secret, helperRequest{Timestamp: now, Nonce: "n3"})
+			r.Body = http.NoBody
+			return r
+		}, http.StatusUnauthorized},
+		{"expired", func() *http.Request {
+			return signedHelperRequest(t, secret, helperRequest{Timestamp: now - 120, Nonce: "n4"})
+		}, http.StatusUnauthorized},
+		{"from the future", func() *http.Request {
+			return signedHelperRequest(t, secret, helperRequest{Timestamp: now + 120, Nonce: "n5"})
+		}, http.StatusUnauthorized},
+		{"get", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/process/kill", nil)
+		}, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler(w, tt.request())
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestNonceCacheExpiry(t *testing.T) {
+	var c nonceCache
+	now := time.Now()
+	if !c.add("a", now) || c.add("a", now.Add(time.Second)) {
+		t.Fatal("nonce accepted twice within the skew window")
+	}
+	// 过期的 nonce 被清理；同一请求的时间戳此时已无法通过检查
+	c.add("b", now.Add(3*helperMaxClockSkew))
+	if _, ok := c.seen["a"]; ok {
+		t.Error("expired nonce was not pruned")
+	}
+}
+
+func TestHelperKillRefusesOtherProcesses(t *testing.T) {
+	secret := []byte("secret")
+	h := &helperServer{
+		config: Config{Processes: []ProcessConfig{{Name: "notepad.exe", MatchMode: MatchExact}}},
+		secret: secret,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/process/kill", h.authenticated(h.handleKill))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	client := &helperClient{baseURL: srv.URL, secret: secret, client: srv.Client()}
+
+	// 测试进程自身既不是 notepad.exe，也不是已配置的进程
+	pid := int32(os.Getpid())
+	tests := []struct{ name, want string }{
+		{"notepad.exe", "pid does not match process"},
+		{"processmonitor", "process is not configured"},
+	}
+	for _, tt := range tests {
+		err := client.KillProcess(tt.name, pid)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("kill %s (PID %d): err = %v, want %q", tt.name, pid, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// PrivilegeIssue describes a configured feature that will not work with the
+// privileges the monitor is currently running with.
+type PrivilegeIssue struct {
+	Feature     string // 受影响的功能
+	Reason      string // 原因
+	Remediation string // 修复建议
+}
+
+// reportPrivileges checks the privileges required by config at startup and
+// logs precisely which features will not work.
+func reportPrivileges(config Config) []PrivilegeIssue {
+	issues := checkPrivileges(config)
+	if len(issues) == 0 {
+		logrus.Info("Privilege check passed: all configured features have the required rights")
+		return nil
+	}
+
+	logrus.Warnf("Privilege check found %d feature(s) that will not work:", len(issues))
+	for _, issue := range issues {
+		logrus.Warnf("  - %s: %s (%s)", issue.Feature, issue.Reason, issue.Remediation)
+	}
+	return issues
+}
+
+// needsServiceControl reports whether config has monitors that go through the
+// service control manager
+func needsServiceControl(config Config) bool {
+	return len(config.ServiceMonitors) > 0 || len(config.IIS.AppPools) > 0 || len(config.COMPlusApps) > 0
+}
+
+// registryMonitorWrites reports whether a registry monitor needs write access
+func registryMonitorWrites(rm RegistryMonitor) bool {
+	for _, v := range rm.Values {
+		if v.ExpectValue != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+)
+
+// isAdmin 检查当前用户是否为 root
+func isAdmin() bool {
+	return os.Geteuid() == 0
+}
+
+// checkPrivileges reports features that need root on Unix-like systems
+func checkPrivileges(config Config) []PrivilegeIssue {
+	return unixPrivilegeIssues(config, isAdmin())
+}
+
+// unixPrivilegeIssues lists the features of config that do not work unless
+// root is true
+func unixPrivilegeIssues(config Config, root bool) []PrivilegeIssue {
+	var issues []PrivilegeIssue
+	if !root && !config.PrivilegedHelper.Enable && len(config.Processes) > 0 {
+		issues = append(issues, PrivilegeIssue{
+			Feature:     "kill processes of other users",
+			Reason:      "monitor is not running as root; restarts cannot terminate instances owned by other users",
+			Remediation: "run as root or as the user owning the managed processes",
+		})
+	}
+	for _, p := range config.Processes {
+		if p.Enable && len(p.SupplementaryGroups) > 0 && !root {
+			issues = append(issues, PrivilegeIssue{
+				Feature:     "supplementary groups for " + p.Name,
+				Reason:      "setting supplementary groups requires root (CAP_SETGID); the process will fail to start",
+				Remediation: "run as root or remove supplementary_groups and use a setgid directory",
+			})
+		}
+	}
+	return issues
+}
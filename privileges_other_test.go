@@ -0,0 +1,36 @@
+//go:build !windows
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnixPrivilegeIssues(t *testing.T) {
+	processes := []ProcessConfig{
+		{Name: "api", Enable: true, SupplementaryGroups: []string{"www-data"}},
+		{Name: "disabled", SupplementaryGroups: []string{"www-data"}},
+	}
+	tests := []struct {
+		name   string
+		config Config
+		root   bool
+		want   []string
+	}{
+		{"root", Config{Processes: processes}, true, nil},
+		{"user", Config{Processes: processes}, false, []string{"kill processes of other users", "supplementary groups for api"}},
+		// 最小权限模式下由特权助手结束进程，附加组仍需要 root
+		{"user with helper", Config{Processes: processes, PrivilegedHelper: PrivilegedHelperConfig{Enable: true}}, false, []string{"supplementary groups for api"}},
+		{"no processes", Config{}, false, nil},
+	}
+	for _, tt := range tests {
+		var features []string
+		for _, issue := range unixPrivilegeIssues(tt.config, tt.root) {
+			features = append(features, issue.Feature)
+		}
+		if !reflect.DeepEqual(features, tt.want) {
+			t.Errorf("%s: issues = %q, want %q", tt.name, features, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestRegistryMonitorWrites(t *testing.T) {
+	tests := []struct {
+		monitor RegistryMonitor
+		want    bool
+	}{
+		{RegistryMonitor{Name: "watch only", Values: []RegistryValueConfig{{Name: "ProxyEnable"}}}, false},
+		{RegistryMonitor{Name: "enforce", Values: []RegistryValueConfig{{Name: "ProxyEnable"}, {Name: "ProxyServer", ExpectValue: "proxy:8080"}}}, true},
+		{RegistryMonitor{Name: "empty"}, false},
+	}
+	for _, tt := range tests {
+		if got := registryMonitorWrites(tt.monitor); got != tt.want {
+			t.Errorf("registryMonitorWrites(%s) = %v, want %v", tt.monitor.Name, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsServiceControl(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   bool
+	}{
+		{"processes only", Config{Processes: []ProcessConfig{{Name: "api.exe"}}}, false},
+		{"service monitor", Config{ServiceMonitors: []ServiceMonitor{{Name: "Spooler"}}}, true},
+		{"iis", Config{IIS: IISConfig{AppPools: []IISAppPoolMonitor{{Name: "DefaultAppPool"}}}}, true},
+		{"complus", Config{COMPlusApps: []COMPlusMonitor{{Name: "Billing"}}}, true},
+	}
+	for _, tt := range tests {
+		if got := needsServiceControl(tt.config); got != tt.want {
+			t.Errorf("%s: needsServiceControl = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
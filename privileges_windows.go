@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// isAdmin 检查当前用户是否具有管理员权限
+func isAdmin() bool {
+	// 使用windows包提供的API检查管理员权限
+	var sid *windows.SID
+	err := windows.AllocateAndInitializeSid(
+		&windows.SECURITY_NT_AUTHORITY,
+		2,
+		windows.SECURITY_BUILTIN_DOMAIN_RID,
+		windows.DOMAIN_ALIAS_RID_ADMINS,
+		0, 0, 0, 0, 0, 0,
+		&sid)
+	if err != nil {
+		log.Printf("初始化SID失败: %v", err)
+		// 回退到物理驱动器检查
+		if _, err := os.Open("\\\\.\\PHYSICALDRIVE0"); err == nil {
+			return true
+		}
+		return false
+	}
+	defer windows.FreeSid(sid)
+
+	// 检查当前进程令牌
+	token := windows.Token(0)
+	member, err := token.IsMember(sid)
+	if err != nil {
+		log.Printf("检查令牌成员关系失败: %v", err)
+		// 回退到物理驱动器检查
+		if _, err := os.Open("\\\\.\\PHYSICALDRIVE0"); err == nil {
+			return true
+		}
+		return false
+	}
+
+	return member
+}
+
+// tokenPrivilegeState reports whether the current process token holds the named
+// privilege, and whether it is currently enabled.
+func tokenPrivilegeState(name string) (present bool, enabled bool, err error) {
+	var luid windows.LUID
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return false, false, err
+	}
+	if err := windows.LookupPrivilegeValue(nil, namePtr, &luid); err != nil {
+		return false, false, fmt.Errorf("lookup %s: %v", name, err)
+	}
+
+	token := windows.GetCurrentProcessToken()
+	var size uint32
+	windows.GetTokenInformation(token, windows.TokenPrivileges, nil, 0, &size)
+	if size == 0 {
+		return false, false, fmt.Errorf("failed to query token privileges")
+	}
+	buf := make([]byte, size)
+	if err := windows.GetTokenInformation(token, windows.TokenPrivileges, &buf[0], size, &size); err != nil {
+		return false, false, fmt.Errorf("failed to query token privileges: %v", err)
+	}
+
+	privileges := (*windows.Tokenprivileges)(unsafe.Pointer(&buf[0]))
+	for _, p := range privileges.AllPrivileges() {
+		if p.Luid == luid {
+			return true, p.Attributes&windows.SE_PRIVILEGE_ENABLED != 0, nil
+		}
+	}
+	return false, false, nil
+}
+
+// enablePrivilege enables a privilege held (but disabled) in the current process token
+func enablePrivilege(name string) error {
+	var luid windows.LUID
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if err := windows.LookupPrivilegeValue(nil, namePtr, &luid); err != nil {
+		return fmt.Errorf("lookup %s: %v", name, err)
+	}
+
+	var token windows.Token
+	if err := windows.OpenProcessToken(windows.CurrentProcess(), windows.TOKEN_ADJUST_PRIVILEGES|windows.TOKEN_QUERY, &token); err != nil {
+		return fmt.Errorf("open process token: %v", err)
+	}
+	defer token.Close()
+
+	privileges := windows.Tokenprivileges{
+		PrivilegeCount: 1,
+		Privileges:     [1]windows.LUIDAndAttributes{{Luid: luid, Attributes: windows.SE_PRIVILEGE_ENABLED}},
+	}
+	if err := windows.AdjustTokenPrivileges(token, false, &privileges, 0, nil, nil); err != nil {
+		return fmt.Errorf("enable %s: %v", name, err)
+	}
+	return nil
+}
+
+// serviceMonitorAccess returns the rights a service monitor uses on its service
+func serviceMonitorAccess(m ServiceMonitor) uint32 {
+	access := uint32(windows.SERVICE_QUERY_STATUS | windows.SERVICE_QUERY_CONFIG)
+	if m.Restart {
+		access |= windows.SERVICE_START
+	}
+	if m.StartType != "" {
+		access |= windows.SERVICE_CHANGE_CONFIG
+	}
+	return access
+}
+
+// checkServiceControl connects to the service control manager and opens each
+// monitored service with only the rights its monitor uses.
+func checkServiceControl(monitors []ServiceMonitor) []PrivilegeIssue {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT|windows.SC_MANAGER_ENUMERATE_SERVICE)
+	if err != nil {
+		return []PrivilegeIssue{{
+			Feature:     "service control",
+			Reason:      fmt.Sprintf("cannot connect to the service control manager: %v", err),
+			Remediation: "run elevated",
+		}}
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	var issues []PrivilegeIssue
+	for _, m := range monitors {
+		name, err := windows.UTF16PtrFromString(m.Name)
+		if err != nil {
+			continue
+		}
+		s, err := windows.OpenService(scm, name, serviceMonitorAccess(m))
+		if err != nil {
+			// 未安装的服务由服务监控报告
+			if err == windows.ERROR_SERVICE_DOES_NOT_EXIST {
+				continue
+			}
+			issues = append(issues, PrivilegeIssue{
+				Feature:     fmt.Sprintf("service monitor %q", m.Name),
+				Reason:      fmt.Sprintf("cannot open the service to query, start or reconfigure it: %v", err),
+				Remediation: "run elevated or grant the rights in the service's security descriptor",
+			})
+			continue
+		}
+		windows.CloseServiceHandle(s)
+	}
+	return issues
+}
+
+// checkPrivileges verifies HKLM write access, service control and the right to
+// kill processes of other users for the features used by config.
+func checkPrivileges(config Config) []PrivilegeIssue {
+	var issues []PrivilegeIssue
+
+	// 最小权限模式下注册表写回和特权结束进程由助手完成
+	delegated := config.PrivilegedHelper.Enable
+
+	if !isAdmin() && !delegated {
+		issues = append(issues, PrivilegeIssue{
+			Feature:     "elevation",
+			Reason:      "process is not running elevated (UAC)",
+			Remediation: "run as administrator or install as a service",
+		})
+	}
+
+	// 注册表写回：逐个尝试以写权限打开配置的键
+	for _, rm := range config.RegistryMonitors {
+		if delegated || !rm.Enable || !registryMonitorWrites(rm) {
+			continue
+		}
+		rootKey, err := getRootKey(rm.RootKey)
+		if err != nil {
+			continue
+		}
+		k, err := openRegistryKey(rm, rootKey, registry.QUERY_VALUE|registry.SET_VALUE)
+		if err != nil {
+			if err == registry.ErrNotExist {
+				continue
+			}
+			issues = append(issues, PrivilegeIssue{
+				Feature:     fmt.Sprintf("registry monitor %q", rm.Name),
+				Reason:      fmt.Sprintf("cannot write %s\\%s: %v; expected values will not be restored", rm.RootKey, rm.Path, err),
+				Remediation: "run elevated or grant write access on the key",
+			})
+			continue
+		}
+		k.Close()
+	}
+
+	// 服务控制：只在配置了服务、IIS 或 COM+ 监控时检查
+	if needsServiceControl(config) {
+		issues = append(issues, checkServiceControl(config.ServiceMonitors)...)
+	}
+
+	// 结束其他用户的进程需要 SeDebugPrivilege
+	if len(config.Processes) > 0 && !delegated {
+		present, enabled, err := tokenPrivilegeState("SeDebugPrivilege")
+		switch {
+		case err != nil:
+			issues = append(issues, PrivilegeIssue{
+				Feature:     "kill processes of other users",
+				Reason:      fmt.Sprintf("cannot query SeDebugPrivilege: %v", err),
+				Remediation: "run elevated",
+			})
+		case !present:
+			issues = append(issues, PrivilegeIssue{
+				Feature:     "kill processes of other users",
+				Reason:      "SeDebugPrivilege is not held; restarts cannot terminate instances started by other accounts",
+				Remediation: "run elevated or as LocalSystem",
+			})
+		case !enabled:
+			if err := enablePrivilege("SeDebugPrivilege"); err != nil {
+				issues = append(issues, PrivilegeIssue{
+					Feature:     "kill processes of other users",
+					Reason:      fmt.Sprintf("SeDebugPrivilege could not be enabled: %v", err),
+					Remediation: "run elevated",
+				})
+			}
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestServiceMonitorAccess(t *testing.T) {
+	const query = windows.SERVICE_QUERY_STATUS | windows.SERVICE_QUERY_CONFIG
+	tests := []struct {
+		monitor ServiceMonitor
+		want    uint32
+	}{
+		{ServiceMonitor{Name: "watch only"}, query},
+		{ServiceMonitor{Name: "restart", Restart: true}, query | windows.SERVICE_START},
+		{ServiceMonitor{Name: "start type", StartType: ServiceStartAutomatic}, query | windows.SERVICE_CHANGE_CONFIG},
+	}
+	for _, tt := range tests {
+		if got := serviceMonitorAccess(tt.monitor); got != tt.want {
+			t.Errorf("serviceMonitorAccess(%s) = %#x, want %#x", tt.monitor.Name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// umaskMu 序列化修改 umask 的启动过程，umask 是进程级的，子进程在 fork 时继承
+var umaskMu sync.Mutex
+
+// setProcessAttributes applies the Unix launch options of config to cmd:
+// process group, parent-death signal, user, group and supplementary groups
+func setProcessAttributes(cmd *exec.Cmd, config ProcessConfig) error {
+	if config.IntegrityLevel != "" || config.RestrictedToken {
+		logrus.Warnf("integrity_level and restricted_token are only supported on Windows, ignored for %s", config.Name)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// 子进程成为新进程组（或会话）的组长：监控程序所在的进程组收到的信号不会传给它，
+	// 停止时可以向整个进程组发送信号
+	switch config.ProcessGroup {
+	case ProcessGroupInherit:
+	case ProcessGroupSession:
+		cmd.SysProcAttr.Setsid = true
+	default:
+		cmd.SysProcAttr.Setpgid = true
+	}
+	setParentDeathSignal(cmd, config)
+	if config.RunAsUser != "" {
+		logrus.Warnf("run_as_user is only supported on Windows, use user and group for %s", config.Name)
+	}
+	if config.User == "" && config.Group == "" && len(config.SupplementaryGroups) == 0 {
+		return nil
+	}
+
+	cred, env, err := processCredential(config)
+	if err != nil {
+		return err
+	}
+	cmd.SysProcAttr.Credential = cred
+	if len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+	return nil
+}
+
+// processCredential resolves user, group and supplementary_groups into the
+// credential of the child, keeping the monitor's own user and group for what
+// is not configured. Changing them requires root (CAP_SETUID/CAP_SETGID).
+// The returned variables point HOME, USER and LOGNAME at the new user.
+func processCredential(config ProcessConfig) (*syscall.Credential, []string, error) {
+	cred := &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+	var env []string
+	if config.User != "" {
+		u, err := lookupUser(config.User)
+		if err != nil {
+			return nil, nil, err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid uid for user %s: %s", config.User, u.Uid)
+		}
+		gid, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid gid for user %s: %s", config.User, u.Gid)
+		}
+		cred.Uid, cred.Gid = uint32(uid), uint32(gid)
+		if u.Username != "" {
+			env = append(env, "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+		}
+	}
+	if config.Group != "" {
+		ids, err := lookupGroupIDs([]string{config.Group})
+		if err != nil {
+			return nil, nil, err
+		}
+		cred.Gid = ids[0]
+	}
+	// 切换用户时不继承监控程序（root）的附加组
+	groups, err := lookupGroupIDs(config.SupplementaryGroups)
+	if err != nil {
+		return nil, nil, err
+	}
+	cred.Groups = groups
+	return cred, env, nil
+}
+
+// lookupUser resolves a user name or numeric UID. A UID without a passwd
+// entry (common in containers) is used as is, with the same number as GID.
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+		return &user.User{Uid: name, Gid: name}, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown user %s: %v", name, err)
+	}
+	return u, nil
+}
+
+// lookupGroupIDs resolves group names or numeric GIDs
+func lookupGroupIDs(names []string) ([]uint32, error) {
+	var ids []uint32
+	for _, name := range names {
+		if gid, err := strconv.ParseUint(name, 10, 32); err == nil {
+			ids = append(ids, uint32(gid))
+			continue
+		}
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown group %s: %v", name, err)
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid for group %s: %s", name, g.Gid)
+		}
+		ids = append(ids, uint32(gid))
+	}
+	return ids, nil
+}
+
+// parseUmask parses an octal umask such as "0002" or "027"
+func parseUmask(s string) (int, error) {
+	mask, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mask > 0777 {
+		return 0, fmt.Errorf("invalid umask %q: must be octal, e.g. 0002", s)
+	}
+	return int(mask), nil
+}
+
+// startCommand starts cmd, temporarily switching the monitor's umask when the
+// process configures one so the child inherits it, and launching under the
+// configured SELinux context or AppArmor profile.
+func startCommand(cmd *exec.Cmd, config ProcessConfig) error {
+	labelPath, label, err := execSecurityLabel(config)
+	if err != nil {
+		return err
+	}
+
+	start := cmd.Start
+	if config.Umask != "" {
+		mask, err := parseUmask(config.Umask)
+		if err != nil {
+			return err
+		}
+		start = func() error {
+			umaskMu.Lock()
+			defer umaskMu.Unlock()
+			old := syscall.Umask(mask)
+			defer syscall.Umask(old)
+			return cmd.Start()
+		}
+	}
+
+	if labelPath == "" {
+		return start()
+	}
+	logrus.Infof("Starting %s with security label %s", config.Name, label)
+	return startWithExecLabel(labelPath, label, start)
+}
@@ -0,0 +1,122 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUmask(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"0002", 0002, false},
+		{"027", 0027, false},
+		{"0777", 0777, false},
+		{"0800", 0, true},
+		{"1777", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseUmask(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseUmask(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseUmask(%q) = %o, want %o", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSetProcessAttributesProcessGroup(t *testing.T) {
+	tests := []struct {
+		group   string
+		setpgid bool
+		setsid  bool
+	}{
+		{"", true, false},
+		{ProcessGroupNew, true, false},
+		{ProcessGroupSession, false, true},
+		{ProcessGroupInherit, false, false},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("true")
+		if err := setProcessAttributes(cmd, ProcessConfig{Name: "app", ProcessGroup: tt.group}); err != nil {
+			t.Fatal(err)
+		}
+		attr := cmd.SysProcAttr
+		if attr.Setpgid != tt.setpgid || attr.Setsid != tt.setsid {
+			t.Errorf("process_group %q: setpgid = %v, setsid = %v", tt.group, attr.Setpgid, attr.Setsid)
+		}
+	}
+	if err := validateProcessGroup(ProcessConfig{ProcessGroup: ProcessGroupInherit, KillProcessTree: true}); err == nil {
+		t.Error("kill_process_tree with process_group inherit was accepted")
+	}
+}
+
+func TestStopSignalReachesProcessGroup(t *testing.T) {
+	config := ProcessConfig{Name: "group", StopSignal: "SIGTERM"}
+	cmd := exec.Command("/bin/sh", "-c", "sleep 60 & echo $!; wait")
+	if err := setProcessAttributes(cmd, config); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	line, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	grandchild, _ := strconv.Atoi(strings.TrimSpace(line))
+
+	if err := sendStopSignal(config, cmd.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+	deadline := time.Now().Add(2 * time.Second)
+	for !processGone(grandchild) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !processGone(grandchild) {
+		t.Errorf("grandchild %d did not get the stop signal", grandchild)
+	}
+}
+
+func TestProcessCredential(t *testing.T) {
+	cred, env, err := processCredential(ProcessConfig{User: "root", SupplementaryGroups: []string{"12"}})
+	if err != nil {
+		t.Skipf("no root user: %v", err)
+	}
+	if cred.Uid != 0 || cred.Gid != 0 || len(cred.Groups) != 1 || cred.Groups[0] != 12 {
+		t.Errorf("credential = %+v", cred)
+	}
+	if strings.Join(env, " ") != "HOME=/root USER=root LOGNAME=root" {
+		t.Errorf("env = %v", env)
+	}
+
+	// 没有 passwd 条目的 UID（容器中常见）原样使用，GID 相同
+	cred, env, err = processCredential(ProcessConfig{User: "4000123", Group: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Uid != 4000123 || cred.Gid != 0 || len(env) != 0 {
+		t.Errorf("credential = %+v, env = %v", cred, env)
+	}
+
+	if _, _, err := processCredential(ProcessConfig{User: "no-such-user-pm"}); err == nil {
+		t.Error("unknown user was accepted")
+	}
+}
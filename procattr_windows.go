@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// setProcessAttributes puts the child in its own process group so it is not
+// terminated together with the monitor
+func setProcessAttributes(cmd *exec.Cmd, config ProcessConfig) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+	if config.Umask != "" || len(config.SupplementaryGroups) > 0 || config.ProcessGroup != "" {
+		logrus.Warnf("umask, supplementary_groups and process_group are not supported on Windows, ignored for %s", config.Name)
+	}
+	if config.User != "" || config.Group != "" {
+		logrus.Warnf("user and group are only supported on Linux, use run_as_user for %s", config.Name)
+	}
+	if _, _, err := execSecurityLabel(config); err != nil {
+		return err
+	}
+	return nil
+}
+
+// startCommand starts cmd, using a restricted or lower-integrity token when configured
+func startCommand(cmd *exec.Cmd, config ProcessConfig) error {
+	token, err := launchToken(config)
+	if err != nil {
+		return err
+	}
+	if token == 0 {
+		return cmd.Start()
+	}
+	// 进程创建后令牌句柄即可关闭，子进程持有自己的副本
+	defer token.Close()
+
+	if config.sessionScoped || config.RunAsUser != "" {
+		if config.sessionScoped {
+			logrus.Infof("Starting %s in session %d", config.Name, config.sessionID)
+		} else {
+			logrus.Infof("Starting %s as %s", config.Name, config.RunAsUser)
+		}
+		// 使用会话用户或 run_as_user 自己的环境变量（USERPROFILE、TEMP 等），而不是服务的；
+		// 保留监控程序附加的 PM_* 变量（协议管道、关联ID）
+		if env, err := token.Environ(false); err == nil {
+			cmd.Env = append(env, monitorEnv(cmd.Env)...)
+		} else {
+			logrus.Warnf("Failed to load environment of the user of %s: %v", config.Name, err)
+		}
+	}
+	if config.RestrictedToken || config.IntegrityLevel != "" {
+		logrus.Infof("Starting %s with restricted token (integrity level: %s, restricted: %v)",
+			config.Name, config.IntegrityLevel, config.RestrictedToken)
+	}
+	cmd.SysProcAttr.Token = syscall.Token(token)
+	return cmd.Start()
+}
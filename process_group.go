@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+// 子进程的进程组（process_group，仅 Linux/Unix；Windows 上子进程总是在新的进程组中启动）
+const (
+	ProcessGroupNew     = "group"   // 新进程组（setpgid，默认）：不随监控程序所在的进程组（如终端的 Ctrl+C）结束
+	ProcessGroupSession = "session" // 新会话（setsid）：同时脱离控制终端，关闭终端时不会收到 SIGHUP
+	ProcessGroupInherit = "inherit" // 与监控程序在同一进程组（以前的行为）
+)
+
+// validateProcessGroup checks process_group and the options that need the
+// child to lead its own process group
+func validateProcessGroup(config ProcessConfig) error {
+	switch config.ProcessGroup {
+	case "", ProcessGroupNew, ProcessGroupSession:
+	case ProcessGroupInherit:
+		if config.KillProcessTree {
+			return fmt.Errorf("kill_process_tree requires the process to have its own process group (process_group: inherit)")
+		}
+	default:
+		return fmt.Errorf("process_group must be group, session or inherit, got %q", config.ProcessGroup)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// match_mode 取值
+const (
+	MatchSubstring = "substring" // 程序路径或命令行包含进程名（默认，兼容旧版本）
+	MatchExact     = "exact"     // 程序文件名与进程名完全相同
+	MatchRegex     = "regex"     // 程序路径或命令行匹配 match_pattern
+)
+
+// processMatcher decides whether a running process is an instance of a
+// configured process
+type processMatcher struct {
+	mode string
+	name string
+	re   *regexp.Regexp
+}
+
+// newProcessMatcher builds the matcher for config according to its match_mode
+func newProcessMatcher(config ProcessConfig) (processMatcher, error) {
+	m := processMatcher{mode: config.MatchMode, name: filepath.Base(config.Name)}
+	switch config.MatchMode {
+	case "", MatchSubstring:
+		m.mode = MatchSubstring
+	case MatchExact:
+	case MatchRegex:
+		if config.MatchPattern == "" {
+			return m, fmt.Errorf("match_mode regex requires match_pattern")
+		}
+		re, err := regexp.Compile(config.MatchPattern)
+		if err != nil {
+			return m, fmt.Errorf("invalid match_pattern: %v", err)
+		}
+		m.re = re
+	default:
+		return m, fmt.Errorf("invalid match_mode %q", config.MatchMode)
+	}
+	return m, nil
+}
+
+// matches reports whether p is an instance of the configured process
+func (m processMatcher) matches(ctx context.Context, p *process.Process) bool {
+	return m.matchesEntry(readProcessEntry(ctx, p))
+}
+
+// matchesEntry is matches for a process read into the process table
+func (m processMatcher) matchesEntry(e processEntry) bool {
+	switch m.mode {
+	case MatchExact:
+		if e.exe == "" {
+			// 无权读取程序路径时退回到进程名（Linux 上最多15个字符）
+			if e.name == "" {
+				return false
+			}
+			return exactNameMatches(m.name, e.name, runtime.GOOS == "windows") ||
+				(len(e.name) >= 15 && strings.HasPrefix(m.name, e.name))
+		}
+		return exactNameMatches(m.name, filepath.Base(e.exe), runtime.GOOS == "windows")
+	case MatchRegex:
+		return (e.exe != "" && m.re.MatchString(e.exe)) || (e.cmdline != "" && m.re.MatchString(e.cmdline))
+	default:
+		return processMatches(e, m.name)
+	}
+}
+
+// exactNameMatches compares program file names. On Windows the comparison
+// ignores case and the .exe extension, so "App" matches "app.exe".
+func exactNameMatches(want, got string, windows bool) bool {
+	if !windows {
+		return want == got
+	}
+	trim := func(s string) string {
+		s = strings.ToLower(s)
+		return strings.TrimSuffix(s, ".exe")
+	}
+	return trim(want) == trim(got)
+}
+
+// pidFilePath returns where the PID of config is written, or "" when
+// pid_file is not set. Session instances get one file per session.
+func pidFilePath(config ProcessConfig) string {
+	if config.PIDFile == "" || !config.sessionScoped {
+		return config.PIDFile
+	}
+	return fmt.Sprintf("%s.%d", config.PIDFile, config.sessionID)
+}
+
+// writePIDFile records pid in the pid_file of config
+func writePIDFile(config ProcessConfig, pid int) {
+	path := pidFilePath(config)
+	if path == "" {
+		return
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		logrus.Warnf("Failed to write PID file %s for %s: %v", path, config.Name, err)
+	}
+}
+
+// removePIDFile deletes the pid_file of config
+func removePIDFile(config ProcessConfig) {
+	if path := pidFilePath(config); path != "" {
+		os.Remove(path)
+	}
+}
+
+// readPIDFile returns the PID recorded in the pid_file of config, if any
+func readPIDFile(config ProcessConfig) int32 {
+	path := pidFilePath(config)
+	if path == "" {
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return int32(pid)
+}
+
+// trackedAlive reports whether pid is still running and is still an
+// instance of config; a PID reused by an unrelated process does not count
+func trackedAlive(config ProcessConfig, pid int32) bool {
+	matcher, err := newProcessMatcher(config)
+	if err != nil {
+		return false
+	}
+	alive := false
+	runWithTimeout("process scan", seconds(opTimeouts.ProcessScan), func(ctx context.Context) error {
+		p, err := process.NewProcessWithContext(ctx, pid)
+		if err != nil {
+			return nil
+		}
+		if running, err := p.IsRunningWithContext(ctx); err != nil || !running {
+			return nil
+		}
+		if config.sessionScoped {
+			if session, err := processSession(pid); err != nil || session != config.sessionID {
+				return nil
+			}
+		}
+		alive = matcher.matches(ctx, p)
+		return nil
+	})
+	return alive
+}
+
+// adoptRunning looks for an instance of the process that was started
+// before the monitor (recorded in pid_file, or the only match of a scan)
+// and tracks its PID so later checks do not need a name scan. With adopt
+// the oldest of several matches is taken over as well, and the instance is
+// stopped by PID like a child of the monitor.
+func (s *ProcessSupervisor) adoptRunning() (bool, error) {
+	if pid := readPIDFile(s.config); pid != 0 && trackedAlive(s.config, pid) {
+		s.adopt(pid, "pid_file")
+		return true, nil
+	}
+	pids, err := configPIDs(s.config)
+	if err != nil || len(pids) == 0 {
+		return false, err
+	}
+	switch {
+	case len(pids) == 1:
+		s.adopt(pids[0], "process scan")
+	case s.config.adoptsRunning():
+		s.adopt(oldestPID(pids), fmt.Sprintf("oldest of %d instances", len(pids)))
+	}
+	return true, nil
+}
+
+// adopt tracks pid, an instance the monitor did not start
+func (s *ProcessSupervisor) adopt(pid int32, source string) {
+	if pid == s.trackedPID {
+		return
+	}
+	s.track(pid)
+	if !s.config.adoptsRunning() {
+		return
+	}
+	logrus.Infof("Adopted running process %s (PID: %d, from %s)", s.config.Name, pid, source)
+	s.adopted = true
+	s.updateStatus(func(st *ProcessStatus) { st.Adopted = true })
+}
+
+// oldestPID returns the PID of pids that was started first, which for a
+// process with workers is usually the parent; PIDs whose start time cannot
+// be read come last. Start times may only have a resolution of a second, so
+// a parent and the workers it forks at once tie; the lower PID wins then.
+func oldestPID(pids []int32) int32 {
+	oldest, oldestTime := pids[0], int64(0)
+	for _, pid := range pids {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		created, err := p.CreateTime()
+		if err != nil {
+			continue
+		}
+		if oldestTime == 0 || created < oldestTime || (created == oldestTime && pid < oldest) {
+			oldest, oldestTime = pid, created
+		}
+	}
+	return oldest
+}
+
+// track records pid as the instance of the process this supervisor owns
+func (s *ProcessSupervisor) track(pid int32) {
+	s.trackedPID = pid
+	writePIDFile(s.config, int(pid))
+	if s.proxy != nil {
+		s.proxy.setTarget(pid)
+	}
+	s.updateStatus(func(st *ProcessStatus) { st.PID = int(pid) })
+}
+
+// untrack forgets the tracked PID and removes the pid_file
+func (s *ProcessSupervisor) untrack() {
+	s.trackedPID = 0
+	s.adopted = false
+	s.updateStatus(func(st *ProcessStatus) { st.Adopted = false })
+	removePIDFile(s.config)
+	if s.proxy != nil {
+		s.proxy.setTarget(0)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+func TestExactNameMatches(t *testing.T) {
+	tests := []struct {
+		want, got string
+		windows   bool
+		match     bool
+	}{
+		{"node", "node", false, true},
+		{"node", "nodepad_helper", false, false},
+		{"node", "Node", false, false},
+		{"node.exe", "NODE.EXE", true, true},
+		{"node", "node.exe", true, true},
+		{"node", "nodepad_helper.exe", true, false},
+	}
+	for _, tt := range tests {
+		if got := exactNameMatches(tt.want, tt.got, tt.windows); got != tt.match {
+			t.Errorf("exactNameMatches(%q, %q, %v) = %v, want %v", tt.want, tt.got, tt.windows, got, tt.match)
+		}
+	}
+}
+
+func TestNewProcessMatcherErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ProcessConfig
+		ok     bool
+	}{
+		{"default", ProcessConfig{Name: "a"}, true},
+		{"exact", ProcessConfig{Name: "a", MatchMode: MatchExact}, true},
+		{"regex", ProcessConfig{Name: "a", MatchMode: MatchRegex, MatchPattern: `a\.jar$`}, true},
+		{"regex without pattern", ProcessConfig{Name: "a", MatchMode: MatchRegex}, false},
+		{"bad regex", ProcessConfig{Name: "a", MatchMode: MatchRegex, MatchPattern: "("}, false},
+		{"unknown mode", ProcessConfig{Name: "a", MatchMode: "fuzzy"}, false},
+	}
+	for _, tt := range tests {
+		if _, err := newProcessMatcher(tt.config); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestProcessMatcherModes(t *testing.T) {
+	self, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := self.Exe()
+	if err != nil {
+		t.Skipf("cannot read own executable: %v", err)
+	}
+	base := filepath.Base(exe)
+	prefix := base[:len(base)-2]
+
+	tests := []struct {
+		name   string
+		config ProcessConfig
+		match  bool
+	}{
+		{"substring prefix", ProcessConfig{Name: prefix}, true},
+		{"exact prefix", ProcessConfig{Name: prefix, MatchMode: MatchExact}, false},
+		{"exact name", ProcessConfig{Name: base, MatchMode: MatchExact}, true},
+		{"regex", ProcessConfig{Name: "x", MatchMode: MatchRegex, MatchPattern: "^.*" + base + "$"}, true},
+		{"regex miss", ProcessConfig{Name: base, MatchMode: MatchRegex, MatchPattern: "^nothing-like-this$"}, false},
+	}
+	for _, tt := range tests {
+		m, err := newProcessMatcher(tt.config)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := m.matches(context.Background(), self); got != tt.match {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.match)
+		}
+	}
+}
+
+func TestPIDFileTracking(t *testing.T) {
+	self, _ := process.NewProcess(int32(os.Getpid()))
+	exe, err := self.Exe()
+	if err != nil {
+		t.Skipf("cannot read own executable: %v", err)
+	}
+	config := ProcessConfig{
+		Name:      filepath.Base(exe),
+		MatchMode: MatchExact,
+		PIDFile:   filepath.Join(t.TempDir(), "app.pid"),
+	}
+
+	writePIDFile(config, os.Getpid())
+	pid := readPIDFile(config)
+	if pid != int32(os.Getpid()) {
+		t.Fatalf("readPIDFile = %d, want %d", pid, os.Getpid())
+	}
+	if !trackedAlive(config, pid) {
+		t.Error("own PID should be alive and match")
+	}
+
+	// PID 被其他程序重用时不能当作被监控进程
+	other := config
+	other.Name = "some-other-program"
+	if trackedAlive(other, pid) {
+		t.Error("PID of a different program must not match")
+	}
+
+	removePIDFile(config)
+	if readPIDFile(config) != 0 {
+		t.Error("PID file should be gone")
+	}
+}
+
+func TestAdoptFromPIDFile(t *testing.T) {
+	self, _ := process.NewProcess(int32(os.Getpid()))
+	exe, err := self.Exe()
+	if err != nil {
+		t.Skipf("cannot read own executable: %v", err)
+	}
+	config := ProcessConfig{
+		Name:      filepath.Base(exe),
+		MatchMode: MatchExact,
+		PIDFile:   filepath.Join(t.TempDir(), "app.pid"),
+		Adopt:     true,
+	}
+	writePIDFile(config, os.Getpid())
+
+	s := NewProcessSupervisor(config)
+	running, err := s.adoptRunning()
+	if err != nil || !running {
+		t.Fatalf("adoptRunning = %v, %v; want true", running, err)
+	}
+	if s.trackedPID != int32(os.Getpid()) || !s.adopted {
+		t.Errorf("trackedPID = %d, adopted = %v; want own PID adopted", s.trackedPID, s.adopted)
+	}
+	if st := s.Status(); !st.Adopted || st.PID != os.Getpid() {
+		t.Errorf("status = %+v, want adopted with own PID", st)
+	}
+
+	s.untrack()
+	if s.adopted || s.Status().Adopted {
+		t.Error("untrack should clear the adopted flag")
+	}
+
+	// 未开启 adopt 时同样按PID跟踪，但不视为接管（停止时不按PID优雅停止）
+	config.Adopt = false
+	writePIDFile(config, os.Getpid())
+	s = NewProcessSupervisor(config)
+	if running, _ := s.adoptRunning(); !running || s.adopted {
+		t.Errorf("without adopt: running = %v, adopted = %v", running, s.adopted)
+	}
+}
+
+func TestOldestPID(t *testing.T) {
+	// 启动时间可能只精确到秒，同时启动的子进程按PID排在后面
+	task := helperTask("child", "sleep")
+	child := exec.Command(task.Command, task.Args...)
+	if err := child.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		child.Process.Kill()
+		child.Wait()
+	}()
+	self := int32(os.Getpid())
+	if got := oldestPID([]int32{int32(child.Process.Pid), self}); got != self {
+		t.Errorf("oldestPID = %d, want %d (child %d started later)", got, self, child.Process.Pid)
+	}
+	// 无法读取启动时间的PID排在最后
+	if got := oldestPID([]int32{1 << 30, self}); got != self {
+		t.Errorf("oldestPID = %d, want %d", got, self)
+	}
+}
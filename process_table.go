@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultProcessTableRefresh = 2
+
+// ProcessTableConfig 所有监控共用的进程表：每个刷新间隔只枚举一次进程并读取路径/命令行，
+// 而不是每个进程监控各自扫描
+type ProcessTableConfig struct {
+	Refresh int `yaml:"refresh"` // 进程表最长使用时间（秒，默认2），超过后下一次查询时重新枚举
+}
+
+// processEntry is what the process table records of one process
+type processEntry struct {
+	proc    *process.Process
+	exe     string
+	cmdline string
+	name    string // 只在读不到程序路径时读取（如 /proc 以 hidepid 挂载）
+}
+
+// readProcessEntry reads the fields the matchers need from p
+func readProcessEntry(ctx context.Context, p *process.Process) processEntry {
+	e := processEntry{proc: p}
+	e.exe, _ = p.ExeWithContext(ctx)
+	e.cmdline, _ = p.CmdlineWithContext(ctx)
+	if e.exe == "" {
+		e.name, _ = p.NameWithContext(ctx)
+	}
+	return e
+}
+
+// scanProcesses enumerates all processes and reads their entries
+func scanProcesses(ctx context.Context) ([]processEntry, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]processEntry, 0, len(procs))
+	for _, p := range procs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		entries = append(entries, readProcessEntry(ctx, p))
+	}
+	return entries, nil
+}
+
+// processTableScan is a scan in progress; callers arriving meanwhile wait
+// for it instead of starting their own
+type processTableScan struct {
+	done    chan struct{}
+	entries []processEntry
+	err     error
+}
+
+// processTable caches the process list for all monitors. Entries may be up
+// to refresh old, so callers check that matched processes are still alive.
+type processTable struct {
+	mu      sync.Mutex
+	refresh time.Duration
+	entries []processEntry
+	taken   time.Time
+	valid   bool
+	gen     int // 每次 invalidate 加一，之前开始的扫描结果不再保存
+	scan    *processTableScan
+
+	scanFn func(ctx context.Context) ([]processEntry, error)
+	now    func() time.Time
+}
+
+func newProcessTable(refresh time.Duration) *processTable {
+	return &processTable{refresh: refresh, scanFn: scanProcesses, now: time.Now}
+}
+
+// procTable is shared by all monitors; the refresh interval is set from the config at startup
+var procTable = newProcessTable(defaultProcessTableRefresh * time.Second)
+
+// setRefresh changes how long a scan is reused
+func (t *processTable) setRefresh(refresh time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refresh = refresh
+}
+
+// invalidate discards the cached list, e.g. after the monitor started a
+// process that must be visible to the next check
+func (t *processTable) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.valid = false
+	t.gen++
+	t.scan = nil
+}
+
+// snapshot returns the cached process list, scanning again when it is
+// older than the refresh interval. The scan is bounded by the process_scan
+// timeout on its own, so a caller giving up does not cancel it for others.
+func (t *processTable) snapshot(ctx context.Context) ([]processEntry, error) {
+	t.mu.Lock()
+	if t.valid && t.now().Sub(t.taken) < t.refresh {
+		entries := t.entries
+		t.mu.Unlock()
+		return entries, nil
+	}
+	scan := t.scan
+	if scan == nil {
+		scan = &processTableScan{done: make(chan struct{})}
+		t.scan = scan
+		go t.run(scan, t.gen)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-scan.done:
+		return scan.entries, scan.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run performs one scan and stores the result unless the table was
+// invalidated meanwhile
+func (t *processTable) run(scan *processTableScan, gen int) {
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(opTimeouts.ProcessScan))
+	defer cancel()
+	started := t.now()
+	scan.entries, scan.err = t.scanFn(ctx)
+
+	t.mu.Lock()
+	if t.scan == scan {
+		t.scan = nil
+	}
+	if scan.err == nil && gen == t.gen {
+		t.entries, t.taken, t.valid = scan.entries, started, true
+	}
+	t.mu.Unlock()
+	close(scan.done)
+	if scan.err == nil {
+		logrus.Debugf("Process table refreshed: %d processes in %v", len(scan.entries), t.now().Sub(started))
+	}
+}
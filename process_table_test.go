@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// testClock is a settable clock that is safe to read from the scan goroutine
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// countingTable returns a table whose scans are counted and report the
+// current process only
+func countingTable(refresh time.Duration) (*processTable, *int32, *testClock) {
+	var scans int32
+	clock := &testClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	table := newProcessTable(refresh)
+	table.now = clock.Now
+	table.scanFn = func(ctx context.Context) ([]processEntry, error) {
+		atomic.AddInt32(&scans, 1)
+		return []processEntry{{proc: &process.Process{Pid: int32(os.Getpid())}, exe: "/usr/bin/monitor"}}, nil
+	}
+	return table, &scans, clock
+}
+
+func TestProcessTableReusesScan(t *testing.T) {
+	table, scans, clock := countingTable(2 * time.Second)
+	for i := 0; i < 3; i++ {
+		if _, err := table.snapshot(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(scans); n != 1 {
+		t.Fatalf("scans = %d within the refresh interval, want 1", n)
+	}
+
+	clock.advance(2 * time.Second)
+	table.snapshot(context.Background())
+	if n := atomic.LoadInt32(scans); n != 2 {
+		t.Errorf("scans = %d after the refresh interval, want 2", n)
+	}
+
+	table.invalidate()
+	table.snapshot(context.Background())
+	if n := atomic.LoadInt32(scans); n != 3 {
+		t.Errorf("scans = %d after invalidate, want 3", n)
+	}
+}
+
+func TestProcessTableSharesRunningScan(t *testing.T) {
+	table, scans, _ := countingTable(time.Minute)
+	release := make(chan struct{})
+	scan := table.scanFn
+	table.scanFn = func(ctx context.Context) ([]processEntry, error) {
+		<-release
+		return scan(ctx)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if entries, err := table.snapshot(context.Background()); err != nil || len(entries) != 1 {
+				t.Errorf("snapshot = %d entries, %v", len(entries), err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(scans); n != 1 {
+		t.Errorf("scans = %d for concurrent callers, want 1", n)
+	}
+}
+
+func TestProcessTableCallerTimeout(t *testing.T) {
+	table, _, _ := countingTable(time.Minute)
+	release := make(chan struct{})
+	defer close(release)
+	table.scanFn = func(ctx context.Context) ([]processEntry, error) {
+		<-release
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := table.snapshot(ctx); err != context.DeadlineExceeded {
+		t.Errorf("snapshot err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestProcessTableMatching(t *testing.T) {
+	entries := []processEntry{
+		{exe: "/opt/app/bin/worker", cmdline: "/opt/app/bin/worker --queue jobs"},
+		{exe: "/usr/bin/java", cmdline: "java -jar /opt/app/report.jar"},
+		{name: "long-process-na"},
+	}
+	tests := []struct {
+		config ProcessConfig
+		want   []int
+	}{
+		{ProcessConfig{Name: "worker"}, []int{0}},
+		{ProcessConfig{Name: "report.jar"}, []int{1}},
+		{ProcessConfig{Name: "java", MatchMode: MatchExact}, []int{1}},
+		{ProcessConfig{Name: "x", MatchMode: MatchRegex, MatchPattern: `\.jar$`}, []int{1}},
+		{ProcessConfig{Name: "long-process-name"}, []int{2}},
+	}
+	for _, tt := range tests {
+		matcher, err := newProcessMatcher(tt.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for i, e := range entries {
+			if matcher.matchesEntry(e) {
+				got = append(got, i)
+			}
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("%s (%s): matched %v, want %v", tt.config.Name, tt.config.MatchMode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// attachProcessTree is a no-op on Unix: children lead their own process
+// group unless process_group is inherit (see setProcessAttributes)
+func attachProcessTree(cmd *exec.Cmd, config ProcessConfig) error {
+	return nil
+}
+
+// killProcessTree sends SIGKILL to the process group of the child pid,
+// ending the grandchildren that are still left in it
+func killProcessTree(config ProcessConfig, pid int) {
+	if !config.KillProcessTree {
+		return
+	}
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		logrus.Warnf("Failed to kill process group of %s (PID: %d): %v", config.Name, pid, err)
+	}
+}
+
+// detachProcessTree is a no-op on Unix: the process group outlives the monitor
+func detachProcessTree(pid int) {}
@@ -0,0 +1,69 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processGone reports whether pid has exited (zombies not yet reaped by
+// init count as exited)
+func processGone(pid int) bool {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	return len(fields) > 0 && fields[0] == "Z"
+}
+
+func TestStopCommandKillsProcessTree(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("needs /proc")
+	}
+	for _, tree := range []bool{true, false} {
+		config := ProcessConfig{Name: "tree", KillProcessTree: tree}
+		cmd := exec.Command("/bin/sh", "-c", "sleep 60 & echo $!; wait")
+		if err := setProcessAttributes(cmd, config); err != nil {
+			t.Fatal(err)
+		}
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		line, err := bufio.NewReader(out).ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		grandchild, _ := strconv.Atoi(strings.TrimSpace(line))
+		exited := make(chan struct{})
+		go func() {
+			cmd.Wait()
+			close(exited)
+		}()
+
+		stopCommand(config, cmd, exited, nil)
+		deadline := time.Now().Add(2 * time.Second)
+		for !processGone(grandchild) && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if gone := processGone(grandchild); gone != tree {
+			t.Errorf("kill_process_tree=%v: grandchild gone = %v", tree, gone)
+		}
+		if !processGone(grandchild) {
+			// 清理未被结束的孙进程
+			if p, err := os.FindProcess(grandchild); err == nil {
+				p.Kill()
+			}
+		}
+	}
+}
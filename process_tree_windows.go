@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	processJobsMu sync.Mutex
+	processJobs   = make(map[int]windows.Handle) // 子进程PID -> 包含其进程树的作业对象
+)
+
+// JOBOBJECT_CPU_RATE_CONTROL_INFORMATION
+type jobCPURateControl struct {
+	ControlFlags uint32
+	CPURate      uint32 // 占全部处理器的比例，单位为万分之一
+}
+
+const (
+	jobCPURateControlEnable  = 0x1
+	jobCPURateControlHardCap = 0x4
+)
+
+// processJob returns the job object of the child pid, creating one and
+// assigning the child to it on first use. Processes the child creates from
+// then on join the job too; grandchildren created before the assignment
+// (right after start) are not included.
+func processJob(pid int) (windows.Handle, error) {
+	processJobsMu.Lock()
+	defer processJobsMu.Unlock()
+	if job, ok := processJobs[pid]; ok {
+		return job, nil
+	}
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create job object: %v", err)
+	}
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return 0, fmt.Errorf("failed to open process: %v", err)
+	}
+	defer windows.CloseHandle(proc)
+	if err := windows.AssignProcessToJobObject(job, proc); err != nil {
+		windows.CloseHandle(job)
+		return 0, fmt.Errorf("failed to assign process to job object: %v", err)
+	}
+	processJobs[pid] = job
+	return job, nil
+}
+
+// updateJobLimits changes the extended limits of job in place
+func updateJobLimits(job windows.Handle, update func(info *windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION)) error {
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	if err := windows.QueryInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil); err != nil {
+		return fmt.Errorf("failed to query job object limits: %v", err)
+	}
+	update(&info)
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		return fmt.Errorf("failed to set job object limits: %v", err)
+	}
+	return nil
+}
+
+// attachProcessTree puts a child started with kill_process_tree into a job
+// object, so the whole tree can be terminated together with it. The job is
+// kill-on-close unless on_monitor_death is detach: the tree then also ends
+// when the monitor dies and its handle is closed. With on_monitor_death
+// kill or term the job is kill-on-close without kill_process_tree as well.
+func attachProcessTree(cmd *exec.Cmd, config ProcessConfig) error {
+	killOnClose := config.endsWithMonitor() || (config.KillProcessTree && config.OnMonitorDeath != MonitorDeathDetach)
+	if !config.KillProcessTree && !killOnClose {
+		return nil
+	}
+	job, err := processJob(cmd.Process.Pid)
+	if err != nil {
+		return err
+	}
+	if !killOnClose {
+		return nil
+	}
+	return updateJobLimits(job, func(info *windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION) {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	})
+}
+
+// applyLimits sets the memory and CPU rate limits of the job object of the
+// child. The memory limit caps the committed memory of the whole tree:
+// allocations beyond it fail. The CPU rate is a hard cap relative to all
+// processors of the machine.
+func applyLimits(cmd *exec.Cmd, config ProcessConfig) error {
+	limits := config.Limits
+	if !limits.enabled() {
+		return nil
+	}
+	job, err := processJob(cmd.Process.Pid)
+	if err != nil {
+		return err
+	}
+	if limits.MemoryMB > 0 {
+		err := updateJobLimits(job, func(info *windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION) {
+			info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_MEMORY
+			info.JobMemoryLimit = uintptr(limits.MemoryMB * 1024 * 1024)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if limits.CPUPercent > 0 {
+		// cpu_percent 按单核计算，作业对象按全部处理器的万分比计算
+		rate := uint32(limits.CPUPercent * 100 / float64(runtime.NumCPU()))
+		if rate < 1 {
+			rate = 1
+		} else if rate > 10000 {
+			rate = 10000
+		}
+		info := jobCPURateControl{ControlFlags: jobCPURateControlEnable | jobCPURateControlHardCap, CPURate: rate}
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation,
+			uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+			return fmt.Errorf("failed to set job object CPU rate: %v", err)
+		}
+	}
+	logrus.Infof("Limited %s (PID: %d) with a job object", config.Name, cmd.Process.Pid)
+	return nil
+}
+
+// takeProcessJob removes and returns the job object of pid
+func takeProcessJob(pid int) (windows.Handle, bool) {
+	processJobsMu.Lock()
+	defer processJobsMu.Unlock()
+	job, ok := processJobs[pid]
+	delete(processJobs, pid)
+	return job, ok
+}
+
+// killProcessTree terminates every process left in the job object of the
+// child pid (its descendants once the child itself has been stopped). A
+// job created only for limits is closed without terminating anything.
+func killProcessTree(config ProcessConfig, pid int) {
+	job, ok := takeProcessJob(pid)
+	if !ok {
+		return
+	}
+	defer windows.CloseHandle(job)
+	if !config.KillProcessTree {
+		// on_monitor_death 的 kill-on-close 作业：关闭句柄前取消，不结束子孙进程
+		clearKillOnClose(job)
+		return
+	}
+	if err := windows.TerminateJobObject(job, 1); err != nil {
+		logrus.Warnf("Failed to terminate process tree of %s (PID: %d): %v", config.Name, pid, err)
+	}
+}
+
+// detachProcessTree lets the tree of pid keep running after the monitor
+// exits: without it the kill-on-close job would end the tree when the
+// monitor's handle is closed. The limits stay in effect.
+func detachProcessTree(pid int) {
+	job, ok := takeProcessJob(pid)
+	if !ok {
+		return
+	}
+	defer windows.CloseHandle(job)
+	clearKillOnClose(job)
+}
+
+func clearKillOnClose(job windows.Handle) {
+	updateJobLimits(job, func(info *windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION) {
+		info.BasicLimitInformation.LimitFlags &^= windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	})
+}
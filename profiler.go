@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// CPUProfileConfig 配置CPU持续过高时的自动采样
+type CPUProfileConfig struct {
+	Enable           bool    `yaml:"enable"`            // 是否启用自动采样
+	CPUThreshold     float64 `yaml:"cpu_threshold"`     // CPU使用率阈值（百分比，默认90）
+	SustainedSeconds int     `yaml:"sustained_seconds"` // 超过阈值持续多少秒后触发（默认60）
+	Duration         int     `yaml:"duration"`          // 采样时长（秒，默认30）
+	Cooldown         int     `yaml:"cooldown"`          // 两次自动采样的最小间隔（秒，默认3600）
+	OutputDir        string  `yaml:"output_dir"`        // 采样结果保存目录（默认profiles）
+}
+
+const defaultProfileDir = "profiles"
+
+// profileMutex 保证同一时间只有一个采样会话（wpr 是系统级的）
+var profileMutex sync.Mutex
+
+// captureCPUProfile captures a CPU sample of the given PID and returns the result file path.
+// Windows uses ETW via wpr (system-wide trace), Linux uses perf record on the PID.
+func captureCPUProfile(name string, pid int32, duration time.Duration, outputDir string) (string, error) {
+	if !profileMutex.TryLock() {
+		return "", fmt.Errorf("another CPU profile capture is already in progress")
+	}
+	defer profileMutex.Unlock()
+
+	if outputDir == "" {
+		outputDir = defaultProfileDir
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create profile directory: %v", err)
+	}
+
+	// 采样工具挂起时不能无限期占用采样锁
+	ctx, cancel := context.WithTimeout(context.Background(), duration+seconds(opTimeouts.Command))
+	defer cancel()
+
+	baseName := fmt.Sprintf("%s_%d_%s", filepath.Base(name), pid, time.Now().Format("20060102-150405"))
+
+	if runtime.GOOS == "windows" {
+		outputFile := filepath.Join(outputDir, baseName+".etl")
+		if out, err := exec.CommandContext(ctx, "wpr", "-start", "CPU", "-filemode").CombinedOutput(); err != nil {
+			return "", fmt.Errorf("wpr start failed: %v: %s", err, out)
+		}
+		time.Sleep(duration)
+		if out, err := exec.CommandContext(ctx, "wpr", "-stop", outputFile).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("wpr stop failed: %v: %s", err, out)
+		}
+		return outputFile, nil
+	}
+
+	outputFile := filepath.Join(outputDir, baseName+".perf.data")
+	seconds := strconv.Itoa(int(duration.Seconds()))
+	cmd := exec.CommandContext(ctx, "perf", "record", "-F", "99", "-g", "-p", strconv.Itoa(int(pid)), "-o", outputFile, "--", "sleep", seconds)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("perf record failed: %v: %s", err, out)
+	}
+	return outputFile, nil
+}
+
+// runProfileCommand implements "processmonitor profile [-pid N] <process> [seconds]"
+func runProfileCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	pid := fs.Int("pid", 0, "instance to profile when the process has several")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: processmonitor profile [-pid N] <process> [seconds]")
+	}
+	name := args[0]
+
+	duration := 30 * time.Second
+	if len(args) > 1 {
+		seconds, err := strconv.Atoi(args[1])
+		if err != nil || seconds <= 0 {
+			return fmt.Errorf("invalid duration: %s", args[1])
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	// 按配置的 match_mode 和会话查找实例，而不是按名称子串（命令行本身也含有进程名）
+	var processConfig *ProcessConfig
+	for i := range config.Processes {
+		if config.Processes[i].Name == name {
+			processConfig = &config.Processes[i]
+		}
+	}
+	if processConfig == nil {
+		return fmt.Errorf("process %s is not configured", name)
+	}
+	outputDir := defaultProfileDir
+	if processConfig.CPUProfile.OutputDir != "" {
+		outputDir = processConfig.CPUProfile.OutputDir
+	}
+
+	pids, err := configPIDs(*processConfig)
+	if err != nil {
+		return fmt.Errorf("failed to list processes: %v", err)
+	}
+	// 不采样这个命令自身
+	instances := pids[:0]
+	for _, p := range pids {
+		if p != int32(os.Getpid()) {
+			instances = append(instances, p)
+		}
+	}
+	target, err := profileTarget(name, instances, int32(*pid))
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Capturing %v CPU profile of %s (PID: %d)", duration, name, target)
+	file, err := captureCPUProfile(name, target, duration, outputDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("CPU profile written to %s\n", file)
+	return nil
+}
+
+// profileTarget picks the instance to profile among pids: the one given
+// with -pid, or the only one running
+func profileTarget(name string, pids []int32, pid int32) (int32, error) {
+	if pid > 0 {
+		for _, p := range pids {
+			if p == pid {
+				return pid, nil
+			}
+		}
+		return 0, fmt.Errorf("PID %d is not an instance of %s", pid, name)
+	}
+	switch len(pids) {
+	case 0:
+		return 0, fmt.Errorf("process %s is not running", name)
+	case 1:
+		return pids[0], nil
+	}
+	list := make([]string, len(pids))
+	for i, p := range pids {
+		list[i] = strconv.Itoa(int(p))
+	}
+	return 0, fmt.Errorf("process %s has %d instances (PIDs %s), choose one with -pid", name, len(pids), strings.Join(list, ", "))
+}
+
+// cpuProfileTrigger tracks sustained high CPU for one managed process
+type cpuProfileTrigger struct {
+	config      CPUProfileConfig
+	proc        *process.Process
+	aboveSince  time.Time
+	lastCapture time.Time
+	capturing   bool
+	mu          sync.Mutex
+}
+
+func newCPUProfileTrigger(config CPUProfileConfig) *cpuProfileTrigger {
+	if config.CPUThreshold <= 0 {
+		config.CPUThreshold = 90
+	}
+	// 容器CPU配额不足一个核时，进程无法达到按单核计算的阈值
+	config.CPUThreshold = detectContainer().adjustCPUThreshold(config.CPUThreshold)
+	if config.SustainedSeconds <= 0 {
+		config.SustainedSeconds = 60
+	}
+	if config.Duration <= 0 {
+		config.Duration = 30
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 3600
+	}
+	return &cpuProfileTrigger{config: config}
+}
+
+// observe records one CPU sample and reports whether a capture is due: usage
+// has stayed above the threshold for the sustained period and the cooldown
+// since the last capture has passed
+func (t *cpuProfileTrigger) observe(percent float64, now time.Time) bool {
+	if percent < t.config.CPUThreshold {
+		t.aboveSince = time.Time{}
+		return false
+	}
+	if t.aboveSince.IsZero() {
+		t.aboveSince = now
+		return false
+	}
+	if now.Sub(t.aboveSince) < time.Duration(t.config.SustainedSeconds)*time.Second {
+		return false
+	}
+	if !t.lastCapture.IsZero() && now.Sub(t.lastCapture) < time.Duration(t.config.Cooldown)*time.Second {
+		return false
+	}
+	t.lastCapture = now
+	t.aboveSince = time.Time{}
+	return true
+}
+
+// check samples the CPU usage of pid and starts a capture in the background
+// once usage has stayed above the threshold for the sustained period.
+func (t *cpuProfileTrigger) check(name string, pid int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.capturing {
+		return
+	}
+
+	if t.proc == nil || t.proc.Pid != pid {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			logrus.Debugf("CPU profile trigger: cannot open PID %d: %v", pid, err)
+			return
+		}
+		t.proc = p
+		t.aboveSince = time.Time{}
+		// 第一次调用只建立基准
+		t.proc.Percent(0)
+		return
+	}
+
+	percent, err := t.proc.Percent(0)
+	if err != nil {
+		logrus.Debugf("CPU profile trigger: failed to read CPU of PID %d: %v", pid, err)
+		return
+	}
+	if !t.observe(percent, time.Now()) {
+		return
+	}
+
+	logrus.Warnf("Process %s (PID: %d) CPU at %.1f%% for over %ds, capturing CPU profile",
+		name, pid, percent, t.config.SustainedSeconds)
+	t.capturing = true
+
+	go func() {
+		file, err := captureCPUProfile(name, pid, time.Duration(t.config.Duration)*time.Second, t.config.OutputDir)
+		if err != nil {
+			logrus.Errorf("Failed to capture CPU profile for %s: %v", name, err)
+		} else {
+			logrus.Infof("CPU profile for %s written to %s", name, file)
+		}
+		t.mu.Lock()
+		t.capturing = false
+		t.mu.Unlock()
+	}()
+}
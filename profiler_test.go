@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCPUProfileTrigger(t *testing.T) {
+	trigger := &cpuProfileTrigger{config: CPUProfileConfig{CPUThreshold: 80, SustainedSeconds: 60, Cooldown: 3600}}
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// 每一步的采样时间（秒）、CPU使用率和是否应当开始采样
+	steps := []struct {
+		at      int
+		percent float64
+		capture bool
+	}{
+		{0, 95, false},  // 开始超过阈值
+		{30, 95, false}, // 未持续60秒
+		{45, 50, false}, // 回落，重新计时
+		{60, 95, false},
+		{110, 95, false},
+		{120, 95, true}, // 持续60秒
+		{150, 95, false},
+		{200, 95, false}, // 冷却期内不再采样
+		{3700, 95, false},
+		{3760, 95, true}, // 冷却期过后再次持续60秒
+	}
+	for _, step := range steps {
+		if got := trigger.observe(step.percent, start.Add(time.Duration(step.at)*time.Second)); got != step.capture {
+			t.Errorf("at %ds with %.0f%%: capture = %v, want %v", step.at, step.percent, got, step.capture)
+		}
+	}
+}
+
+func TestCPUProfileTriggerDefaults(t *testing.T) {
+	trigger := newCPUProfileTrigger(CPUProfileConfig{Enable: true})
+	config := trigger.config
+	if config.CPUThreshold != detectContainer().adjustCPUThreshold(90) || config.SustainedSeconds != 60 || config.Duration != 30 || config.Cooldown != 3600 {
+		t.Errorf("defaults = %+v", config)
+	}
+}
+
+func TestCaptureCPUProfileSingleSession(t *testing.T) {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	if _, err := captureCPUProfile("api.exe", 1, time.Second, t.TempDir()); err == nil || !strings.Contains(err.Error(), "in progress") {
+		t.Errorf("concurrent capture: err = %v, want in progress", err)
+	}
+}
+
+func TestRunProfileCommandArgs(t *testing.T) {
+	config := Config{Processes: []ProcessConfig{{Name: "pm-profile-test-not-running", MatchMode: MatchExact}}}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "usage"},
+		{[]string{"api.exe", "5", "extra"}, "usage"},
+		{[]string{"api.exe", "soon"}, "invalid duration"},
+		{[]string{"api.exe", "0"}, "invalid duration"},
+		{[]string{"api.exe", "5"}, "not configured"},
+		// 命令行中含有进程名的这个测试进程不算作实例
+		{[]string{"pm-profile-test-not-running", "5"}, "not running"},
+		{[]string{"-pid", "1", "pm-profile-test-not-running"}, "not an instance"},
+	}
+	for _, tt := range tests {
+		if err := runProfileCommand(config, tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("profile %v: err = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestProfileTarget(t *testing.T) {
+	tests := []struct {
+		pids []int32
+		pid  int32
+		want int32
+		err  string
+	}{
+		{nil, 0, 0, "not running"},
+		{[]int32{42}, 0, 42, ""},
+		{[]int32{42, 43}, 0, 0, "PIDs 42, 43), choose one with -pid"},
+		{[]int32{42, 43}, 43, 43, ""},
+		{[]int32{42, 43}, 44, 0, "PID 44 is not an instance"},
+	}
+	for _, tt := range tests {
+		got, err := profileTarget("api.exe", tt.pids, tt.pid)
+		if got != tt.want || (err == nil) != (tt.err == "") || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("profileTarget(%v, %d) = %d, %v, want %d, %q", tt.pids, tt.pid, got, err, tt.want, tt.err)
+		}
+	}
+}
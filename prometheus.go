@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promMetricNames maps internal metric names to the exported Prometheus names
+var promMetricNames = map[string]string{
+	"restarts":            "restart_total",
+	"check.failures":      "health_check_failures_total",
+	"check.latency":       "health_check_duration_seconds",
+	"port_check.failures": "port_check_failures_total",
+	"process.up":          "process_up",
+	"monitor.uptime":      "monitor_uptime_seconds",
+}
+
+// promHelp holds the HELP text of the well-known metrics
+var promHelp = map[string]string{
+	"restart_total":                 "Number of restarts performed by the monitor.",
+	"health_check_failures_total":   "Number of failed HTTP health checks.",
+	"health_check_duration_seconds": "Duration of HTTP health checks.",
+	"port_check_failures_total":     "Number of failed port checks.",
+	"process_up":                    "Whether the managed process is running (1) or not (0).",
+	"monitor_uptime_seconds":        "Seconds since the monitor started.",
+	"processmonitor_host_info":      "Host the monitor runs on, with its IP, OS version and static tags as labels.",
+}
+
+type promSummary struct {
+	sum   float64
+	count int64
+}
+
+// promRegistry is the central MetricsSink that keeps the current value of
+// every metric for the /metrics endpoint.
+type promRegistry struct {
+	mu        sync.Mutex
+	counters  map[string]map[string]float64 // 名称 -> 标签 -> 值
+	gauges    map[string]map[string]float64
+	summaries map[string]map[string]*promSummary
+}
+
+var (
+	promMetrics     = newPromRegistry()
+	promMetricsOnce sync.Once
+)
+
+func newPromRegistry() *promRegistry {
+	return &promRegistry{
+		counters:  make(map[string]map[string]float64),
+		gauges:    make(map[string]map[string]float64),
+		summaries: make(map[string]map[string]*promSummary),
+	}
+}
+
+// promName converts an internal metric name to a Prometheus metric name
+func promName(name, suffix string) string {
+	if mapped, ok := promMetricNames[name]; ok {
+		return mapped
+	}
+	name = "processmonitor_" + strings.NewReplacer(".", "_", "-", "_").Replace(name)
+	if !strings.HasSuffix(name, suffix) {
+		name += suffix
+	}
+	return name
+}
+
+// promLabels renders tags as a sorted Prometheus label set
+func promLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(tags[k])
+		pairs = append(pairs, k+`="`+v+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (r *promRegistry) Count(name string, value int64, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = promName(name, "_total")
+	if r.counters[name] == nil {
+		r.counters[name] = make(map[string]float64)
+	}
+	r.counters[name][promLabels(tags)] += float64(value)
+}
+
+func (r *promRegistry) Gauge(name string, value float64, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = promName(name, "")
+	if r.gauges[name] == nil {
+		r.gauges[name] = make(map[string]float64)
+	}
+	r.gauges[name][promLabels(tags)] = value
+}
+
+func (r *promRegistry) Timing(name string, d time.Duration, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = promName(name, "_seconds")
+	if r.summaries[name] == nil {
+		r.summaries[name] = make(map[string]*promSummary)
+	}
+	labels := promLabels(tags)
+	s := r.summaries[name][labels]
+	if s == nil {
+		s = &promSummary{}
+		r.summaries[name][labels] = s
+	}
+	s.sum += d.Seconds()
+	s.count++
+}
+
+// writeText renders all metrics in the Prometheus text exposition format
+func (r *promRegistry) writeText(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	family := func(name, kind string) {
+		if help, ok := promHelp[name]; ok {
+			fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	}
+	for _, name := range sortedKeys(r.counters) {
+		family(name, "counter")
+		for _, labels := range sortedKeys(r.counters[name]) {
+			fmt.Fprintf(w, "%s%s %g\n", name, labels, r.counters[name][labels])
+		}
+	}
+	for _, name := range sortedKeys(r.gauges) {
+		family(name, "gauge")
+		for _, labels := range sortedKeys(r.gauges[name]) {
+			fmt.Fprintf(w, "%s%s %g\n", name, labels, r.gauges[name][labels])
+		}
+	}
+	for _, name := range sortedKeys(r.summaries) {
+		family(name, "summary")
+		for _, labels := range sortedKeys(r.summaries[name]) {
+			s := r.summaries[name][labels]
+			fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, s.sum)
+			fmt.Fprintf(w, "%s_count%s %d\n", name, labels, s.count)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleMetrics serves GET /metrics in the Prometheus text format
+func (s *APIServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// 进程状态和运行时长在抓取时计算，保证是最新值
+	for _, st := range s.manager.Statuses() {
+		up := 0.0
+		if st.State == StateRunning {
+			up = 1
+		}
+		promMetrics.Gauge("process.up", up, processTags(st.Name))
+	}
+	promMetrics.Gauge("monitor.uptime", time.Since(monitorStartTime).Seconds(), nil)
+	promMetrics.Gauge("host.info", 1, currentHost().details())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	promMetrics.writeText(w)
+}
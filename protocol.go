@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProtocolConfig 监督协议：配合的子进程以 JSON 行报告就绪、健康和停止确认
+type ProtocolConfig struct {
+	Enable           bool   `yaml:"enable"`
+	Channel          string `yaml:"channel"`           // stdout（默认，协议行不写入日志）或 pipe（独立管道，见 PM_PROTOCOL_FD / PM_PROTOCOL_HANDLE）
+	ReadyTimeout     int    `yaml:"ready_timeout"`     // 启动后等待 ready 的时间（秒，0表示不限），超时后重启
+	HeartbeatTimeout int    `yaml:"heartbeat_timeout"` // 就绪后超过该时间没有任何消息视为无响应（秒，0表示不检查）
+	StopAckTimeout   int    `yaml:"stop_ack_timeout"`  // 请求停止后等待 stopping 确认的时间（秒，默认5），未确认则直接结束
+}
+
+// channel 取值
+const (
+	ProtocolStdout = "stdout"
+	ProtocolPipe   = "pipe"
+)
+
+// 协议消息类型，每行一个 JSON 对象：{"pm": "ready"}、{"pm": "unhealthy", "message": "db down"}
+const (
+	MsgReady     = "ready"     // 启动完成，可以接收请求
+	MsgHealthy   = "healthy"   // 恢复健康
+	MsgUnhealthy = "unhealthy" // 不健康，立即按重启策略重启
+	MsgHeartbeat = "heartbeat" // 心跳，任何消息都会刷新心跳时间
+	MsgStopping  = "stopping"  // 已收到停止请求，正在退出
+)
+
+// 进程状态中的协议状态
+const (
+	ProtocolStarting  = "starting"
+	ProtocolReady     = "ready"
+	ProtocolUnhealthy = "unhealthy"
+	ProtocolStopping  = "stopping"
+)
+
+const defaultStopAckTimeout = 5
+
+type protocolMessage struct {
+	PM      string `json:"pm"`
+	Message string `json:"message"`
+}
+
+// protocolMonitor tracks what the current instance reported over the
+// supervision protocol. Messages arrive on the goroutine copying the
+// child's output; state changes wake the supervisor so it reacts without
+// waiting for the next check_interval.
+type protocolMonitor struct {
+	config ProtocolConfig
+	name   string
+	wake   chan struct{}
+
+	mu        sync.Mutex
+	started   time.Time
+	lastSeen  time.Time
+	state     string
+	message   string
+	stopAcked chan struct{}
+}
+
+func newProtocolMonitor(config ProcessConfig) *protocolMonitor {
+	if !config.Protocol.Enable {
+		return nil
+	}
+	return &protocolMonitor{
+		config: config.Protocol,
+		name:   config.Name,
+		wake:   make(chan struct{}, 1),
+		state:  ProtocolStarting,
+	}
+}
+
+// reset starts tracking a new instance
+func (m *protocolMonitor) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = time.Now()
+	m.lastSeen = m.started
+	m.state = ProtocolStarting
+	m.message = ""
+	m.stopAcked = make(chan struct{})
+}
+
+// handleLine processes one line of output and reports whether it was a
+// protocol message
+func (m *protocolMonitor) handleLine(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return false
+	}
+	var msg protocolMessage
+	if err := json.Unmarshal(line, &msg); err != nil || msg.PM == "" {
+		return false
+	}
+	m.handle(msg)
+	return true
+}
+
+func (m *protocolMonitor) handle(msg protocolMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSeen = time.Now()
+	previous := m.state
+	switch msg.PM {
+	case MsgReady, MsgHealthy:
+		if m.state != ProtocolStopping {
+			m.state = ProtocolReady
+			m.message = ""
+		}
+	case MsgUnhealthy:
+		m.state = ProtocolUnhealthy
+		m.message = msg.Message
+	case MsgHeartbeat:
+	case MsgStopping:
+		if m.state != ProtocolStopping {
+			m.state = ProtocolStopping
+			close(m.stopAcked)
+		}
+	default:
+		logrus.Debugf("Process %s sent unknown protocol message %q", m.name, msg.PM)
+		return
+	}
+	if m.state != previous {
+		if msg.Message != "" {
+			logrus.Infof("Process %s reported %s: %s", m.name, msg.PM, msg.Message)
+		} else {
+			logrus.Infof("Process %s reported %s", m.name, msg.PM)
+		}
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// check returns why the instance has to be restarted, if it does
+func (m *protocolMonitor) check(now time.Time) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case m.state == ProtocolUnhealthy:
+		if m.message == "" {
+			return "process reported unhealthy"
+		}
+		return "process reported unhealthy: " + m.message
+	case m.state == ProtocolStarting && m.config.ReadyTimeout > 0 && now.Sub(m.started) > seconds(m.config.ReadyTimeout):
+		return fmt.Sprintf("process did not report ready within %ds", m.config.ReadyTimeout)
+	case m.state == ProtocolReady && m.config.HeartbeatTimeout > 0 && now.Sub(m.lastSeen) > seconds(m.config.HeartbeatTimeout):
+		return fmt.Sprintf("no protocol message for %v", now.Sub(m.lastSeen).Round(time.Second))
+	}
+	return ""
+}
+
+// State returns the protocol state of the current instance
+func (m *protocolMonitor) State() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// acked is closed when the current instance acknowledges a stop request
+func (m *protocolMonitor) acked() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopAcked
+}
+
+// wakeup returns the channel signalled on state changes; nil (never ready)
+// when the protocol is not enabled
+func (m *protocolMonitor) wakeup() <-chan struct{} {
+	if m == nil {
+		return nil
+	}
+	return m.wake
+}
+
+// writer returns an io.Writer that consumes protocol lines written by the
+// child and passes all other output on to next
+func (m *protocolMonitor) writer(next io.Writer) io.Writer {
+	return &protocolWriter{m: m, next: next}
+}
+
+// readPipe consumes protocol messages from the dedicated pipe until the
+// child closes it
+func (m *protocolMonitor) readPipe(r *os.File) {
+	defer r.Close()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if !m.handleLine(scanner.Bytes()) {
+			logrus.Debugf("Process %s: ignoring invalid protocol line %q", m.name, scanner.Text())
+		}
+	}
+}
+
+// protocolWriter splits output into lines and filters protocol messages
+type protocolWriter struct {
+	m    *protocolMonitor
+	next io.Writer
+	buf  []byte
+}
+
+func (w *protocolWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := w.buf[:i+1]
+		if !w.m.handleLine(line) {
+			w.next.Write(line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	// 没有换行的超长输出直接转发，不无限累积
+	if len(w.buf) > 64*1024 {
+		w.next.Write(w.buf)
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// openProtocol prepares the protocol for a new instance and returns the
+// output streams and the write end of the protocol pipe to hand to the child
+func (s *ProcessSupervisor) openProtocol(stdio processIO) (processIO, *os.File, error) {
+	if s.protocol == nil {
+		return stdio, nil, nil
+	}
+	s.protocol.reset()
+	if s.config.Protocol.Channel == ProtocolPipe {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return stdio, nil, err
+		}
+		go s.protocol.readPipe(r)
+		stdio.Protocol = w
+		return stdio, w, nil
+	}
+	if stdio.Stdout == nil {
+		stdio.Stdout, stdio.Stderr = os.Stdout, os.Stderr
+	}
+	stdio.Stdout = s.protocol.writer(stdio.Stdout)
+	return stdio, nil, nil
+}
+
+// checkProtocol applies what the instance reported over the protocol
+func (s *ProcessSupervisor) checkProtocol() (bool, string) {
+	reason := s.protocol.check(time.Now())
+	s.updateStatus(func(st *ProcessStatus) { st.Protocol = s.protocol.State() })
+	if reason == "" {
+		return false, ""
+	}
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "health_check_failed",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Process %s failed its supervision protocol: %s", s.config.Name, reason),
+		Details:  map[string]string{"check": "protocol", "error": reason},
+	})
+	return true, reason
+}
+
+// protocolReady reports whether the current instance may be considered
+// healthy as far as the protocol is concerned (always true without it)
+func (s *ProcessSupervisor) protocolReady() bool {
+	return s.protocol == nil || s.currentCmd == nil || s.protocol.State() == ProtocolReady
+}
+
+// stopAck returns the channel closed when the child acknowledges a stop
+// request, or nil when the protocol is not enabled
+func (s *ProcessSupervisor) stopAck() <-chan struct{} {
+	if s.protocol == nil {
+		return nil
+	}
+	return s.protocol.acked()
+}
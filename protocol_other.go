@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// attachProtocolPipe passes the write end of the protocol pipe to the child
+// as an extra file descriptor (3); its number is passed in PM_PROTOCOL_FD
+func attachProtocolPipe(cmd *exec.Cmd, w *os.File) error {
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Environ(), fmt.Sprintf("PM_PROTOCOL_FD=%d", 2+len(cmd.ExtraFiles)))
+	return nil
+}
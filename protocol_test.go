@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProtocolWriterFiltersMessages(t *testing.T) {
+	m := newProtocolMonitor(ProcessConfig{Name: "api", Protocol: ProtocolConfig{Enable: true}})
+	m.reset()
+	var out bytes.Buffer
+	w := m.writer(&out)
+
+	// 协议行可能被拆成多次写入
+	w.Write([]byte("listening on :8080\n{\"pm\":"))
+	w.Write([]byte("\"ready\"}\n{\"level\":\"info\",\"msg\":\"json log line\"}\n"))
+	if got := m.State(); got != ProtocolReady {
+		t.Errorf("state = %q, want ready", got)
+	}
+	if want := "listening on :8080\n{\"level\":\"info\",\"msg\":\"json log line\"}\n"; out.String() != want {
+		t.Errorf("forwarded output = %q, want %q", out.String(), want)
+	}
+	select {
+	case <-m.wakeup():
+	default:
+		t.Error("ready should wake the supervisor")
+	}
+
+	w.Write([]byte(`{"pm":"unhealthy","message":"database unreachable"}` + "\n"))
+	if reason := m.check(time.Now()); !strings.Contains(reason, "database unreachable") {
+		t.Errorf("check = %q, want unhealthy reason", reason)
+	}
+	w.Write([]byte(`{"pm":"healthy"}` + "\n"))
+	if reason := m.check(time.Now()); reason != "" {
+		t.Errorf("check after recovery = %q", reason)
+	}
+
+	w.Write([]byte(`{"pm":"stopping"}` + "\n"))
+	select {
+	case <-m.acked():
+	default:
+		t.Error("stopping should acknowledge the stop request")
+	}
+}
+
+func TestProtocolTimeouts(t *testing.T) {
+	m := newProtocolMonitor(ProcessConfig{Name: "api", Protocol: ProtocolConfig{Enable: true, ReadyTimeout: 30, HeartbeatTimeout: 10}})
+	m.reset()
+	now := time.Now()
+	if reason := m.check(now.Add(20 * time.Second)); reason != "" {
+		t.Errorf("within ready_timeout: %q", reason)
+	}
+	if reason := m.check(now.Add(31 * time.Second)); !strings.Contains(reason, "ready") {
+		t.Errorf("after ready_timeout: %q", reason)
+	}
+
+	m.handle(protocolMessage{PM: MsgReady})
+	if reason := m.check(time.Now().Add(5 * time.Second)); reason != "" {
+		t.Errorf("within heartbeat_timeout: %q", reason)
+	}
+	if reason := m.check(time.Now().Add(11 * time.Second)); !strings.Contains(reason, "no protocol message") {
+		t.Errorf("after heartbeat_timeout: %q", reason)
+	}
+}
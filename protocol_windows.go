@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// attachProtocolPipe lets the child inherit the write end of the protocol
+// pipe; its handle value is passed in PM_PROTOCOL_HANDLE
+func attachProtocolPipe(cmd *exec.Cmd, w *os.File) error {
+	handle := syscall.Handle(w.Fd())
+	if err := syscall.SetHandleInformation(handle, syscall.HANDLE_FLAG_INHERIT, syscall.HANDLE_FLAG_INHERIT); err != nil {
+		return fmt.Errorf("failed to make protocol pipe inheritable: %v", err)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.AdditionalInheritedHandles = append(cmd.SysProcAttr.AdditionalInheritedHandles, handle)
+	cmd.Env = append(cmd.Environ(), fmt.Sprintf("PM_PROTOCOL_HANDLE=%d", handle))
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// registryAccess describes the situation in which opening a registry key
+// was denied, used to pick the remediation that would actually help
+type registryAccess struct {
+	write           bool // 需要写权限（SeRestorePrivilege），否则为读（SeBackupPrivilege）
+	elevated        bool // 监控程序以提升的权限运行
+	privilegeHeld   bool // 令牌中有所需的备份/还原特权
+	backupSemantics bool // 配置允许以备份语义打开
+}
+
+// privilege returns the privilege that bypasses the key's ACL for the access
+func (a registryAccess) privilege() string {
+	if a.write {
+		return "SeRestorePrivilege"
+	}
+	return "SeBackupPrivilege"
+}
+
+// hint returns a precise remediation for an access denied error
+func (a registryAccess) hint() string {
+	switch {
+	case !a.elevated:
+		return "the monitor is not running elevated: run it as administrator or as a service (LocalSystem)"
+	case a.privilegeHeld && !a.backupSemantics:
+		return fmt.Sprintf("the key's ACL denies access even to administrators (e.g. owned by TrustedInstaller): "+
+			"set backup_semantics: true to open it with %s", a.privilege())
+	case a.privilegeHeld:
+		return "opening with backup semantics also failed: grant the monitor's account access in the key's ACL"
+	default:
+		return fmt.Sprintf("the key's ACL denies access and the account does not hold %s: "+
+			"grant access in the key's ACL, or grant %s (e.g. Backup Operators) and set backup_semantics: true",
+			a.privilege(), a.privilege())
+	}
+}
+
+// registryAncestors returns path and its parent keys, outermost first:
+// "A\B\C" yields "A", "A\B", "A\B\C"
+func registryAncestors(path string) []string {
+	var ancestors []string
+	current := ""
+	for _, part := range strings.Split(path, `\`) {
+		if part == "" {
+			continue
+		}
+		if current != "" {
+			current += `\`
+		}
+		current += part
+		ancestors = append(ancestors, current)
+	}
+	return ancestors
+}
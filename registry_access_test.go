@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryAccessHint(t *testing.T) {
+	tests := []struct {
+		name   string
+		access registryAccess
+		want   string
+	}{
+		{"not elevated", registryAccess{privilegeHeld: true}, "not running elevated"},
+		{"backup available", registryAccess{elevated: true, privilegeHeld: true}, "set backup_semantics: true to open it with SeBackupPrivilege"},
+		{"restore available", registryAccess{write: true, elevated: true, privilegeHeld: true}, "SeRestorePrivilege"},
+		{"backup failed", registryAccess{elevated: true, privilegeHeld: true, backupSemantics: true}, "also failed"},
+		{"privilege missing", registryAccess{write: true, elevated: true}, "does not hold SeRestorePrivilege"},
+	}
+	for _, tt := range tests {
+		if got := tt.access.hint(); !strings.Contains(got, tt.want) {
+			t.Errorf("%s: hint = %q, want it to contain %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryAncestors(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{`SOFTWARE\Vendor\App`, []string{`SOFTWARE`, `SOFTWARE\Vendor`, `SOFTWARE\Vendor\App`}},
+		{`\SOFTWARE\\Vendor\`, []string{`SOFTWARE`, `SOFTWARE\Vendor`}},
+		{`SOFTWARE`, []string{`SOFTWARE`}},
+		{``, nil},
+	}
+	for _, tt := range tests {
+		if got := registryAncestors(tt.path); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("registryAncestors(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	modAdvapi32         = windows.NewLazySystemDLL("advapi32.dll")
+	procRegCreateKeyExW = modAdvapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW  = modAdvapi32.NewProc("RegSetValueExW")
+)
+
+const (
+	regOptionBackupRestore = 0x4 // REG_OPTION_BACKUP_RESTORE
+	regCreatedNewKey       = 0x1 // REG_CREATED_NEW_KEY
+)
+
+// openRegistryKey opens the monitored key. When access is denied and the
+// monitor allows backup_semantics, it retries with SeBackupPrivilege /
+// SeRestorePrivilege, which bypass the key's ACL; otherwise the error
+// carries a hint on what would fix the access.
+func openRegistryKey(config RegistryMonitor, root registry.Key, access uint32) (registry.Key, error) {
+	k, err := registry.OpenKey(root, config.Path, access)
+	if err != windows.ERROR_ACCESS_DENIED {
+		return k, err
+	}
+
+	write := access&(registry.SET_VALUE|registry.CREATE_SUB_KEY) != 0
+	situation := registryAccess{
+		write:           write,
+		elevated:        windows.GetCurrentProcessToken().IsElevated(),
+		backupSemantics: config.BackupSemantics,
+	}
+	situation.privilegeHeld, _, _ = tokenPrivilegeState(situation.privilege())
+
+	if config.BackupSemantics && situation.privilegeHeld {
+		k, backupErr := openKeyBackupSemantics(root, config.Path, write, false)
+		if backupErr == nil {
+			logrus.Warnf("Access to %s\\%s denied, opened it with %s instead", config.RootKey, config.Path, situation.privilege())
+			return k, nil
+		}
+		logrus.Debugf("Opening %s\\%s with backup semantics failed: %v", config.RootKey, config.Path, backupErr)
+	}
+	return 0, fmt.Errorf("access denied to %s\\%s: %s", config.RootKey, config.Path, situation.hint())
+}
+
+// createRegistryKey creates the monitored key together with any missing
+// parent keys, falling back to backup semantics like openRegistryKey
+func createRegistryKey(config RegistryMonitor, root registry.Key) (registry.Key, error) {
+	k, _, err := registry.CreateKey(root, config.Path, registry.QUERY_VALUE|registry.SET_VALUE)
+	if err != windows.ERROR_ACCESS_DENIED || !config.BackupSemantics {
+		return k, err
+	}
+	k, backupErr := openKeyBackupSemantics(root, config.Path, true, true)
+	if backupErr != nil {
+		return 0, fmt.Errorf("%v (with backup semantics: %v)", err, backupErr)
+	}
+	return k, nil
+}
+
+// missingRegistryKey returns the outermost key of path that does not exist,
+// i.e. where the deletion happened, or "" when the whole path exists
+func missingRegistryKey(root registry.Key, path string) string {
+	for _, ancestor := range registryAncestors(path) {
+		k, err := registry.OpenKey(root, ancestor, registry.QUERY_VALUE)
+		if err == registry.ErrNotExist {
+			return ancestor
+		}
+		if err == nil {
+			k.Close()
+		}
+	}
+	return ""
+}
+
+// openKeyBackupSemantics opens a key with REG_OPTION_BACKUP_RESTORE; the key
+// (and missing parents) is only created when create is set
+func openKeyBackupSemantics(root registry.Key, path string, write, create bool) (registry.Key, error) {
+	privileges := []string{"SeBackupPrivilege"}
+	if write {
+		privileges = append(privileges, "SeRestorePrivilege")
+	}
+	for _, name := range privileges {
+		if err := enablePrivilege(name); err != nil {
+			return 0, err
+		}
+	}
+
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var k registry.Key
+	var disposition uint32
+	// 备份语义下 samDesired 被忽略，访问权限由持有的特权决定
+	r, _, _ := procRegCreateKeyExW.Call(uintptr(root), uintptr(unsafe.Pointer(pathPtr)), 0, 0,
+		regOptionBackupRestore, 0, 0, uintptr(unsafe.Pointer(&k)), uintptr(unsafe.Pointer(&disposition)))
+	if r != 0 {
+		return 0, windows.Errno(r)
+	}
+	if disposition == regCreatedNewKey && !create {
+		// 只打开已存在的键，不创建
+		k.Close()
+		registry.DeleteKey(root, path)
+		return 0, registry.ErrNotExist
+	}
+	return k, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+// RegistryValueConfig 表示单个注册表值的监控配置
+type RegistryValueConfig struct {
+	Name        string      `yaml:"name"`         // 值名称
+	Type        string      `yaml:"type"`         // 值类型 (string, dword, qword, binary, expand_string, multi_string)
+	ExpectValue interface{} `yaml:"expect_value"` // 期望值
+}
+
+// RegistryMonitor represents the configuration for a registry key monitor
+type RegistryMonitor struct {
+	Name            string                `yaml:"name"`              // 监控名称
+	Enable          bool                  `yaml:"enable"`            // 是否启用此监控配置（可选，默认为true）
+	RootKey         string                `yaml:"root_key"`          // 根键名称 (HKEY_LOCAL_MACHINE, HKEY_CURRENT_USER, etc.)
+	Path            string                `yaml:"path"`              // 注册表路径
+	Values          []RegistryValueConfig `yaml:"values"`            // 要监控的值配置
+	CheckInterval   int                   `yaml:"check_interval"`    // 检查间隔（秒）
+	ExecuteOnChange bool                  `yaml:"execute_on_change"` // 值变化时是否执行命令
+	Command         string                `yaml:"command"`           // 值变化时执行的命令
+	Args            []string              `yaml:"args"`              // 命令参数
+	WorkDir         string                `yaml:"work_dir"`          // 工作目录
+	Enforce         EnforcementGate       `yaml:"enforce"`           // 何时将期望值写回（时间段/标记文件）
+	BackupSemantics bool                  `yaml:"backup_semantics"`  // 访问被拒绝时以备份/还原语义打开（需要 SeBackupPrivilege / SeRestorePrivilege）
+	RecreateKey     bool                  `yaml:"recreate_key"`      // 键或其上级路径被删除（或不存在）时重建并写入所有期望值
+	Recursive       bool                  `yaml:"recursive"`         // 监控整个子树（所有子键和值），以首次启动时的快照为基线
+	SnapshotFile    string                `yaml:"snapshot_file"`     // 子树基线快照文件（默认 registry_snapshots/<name>.json）
+	RevertDrift     bool                  `yaml:"revert_drift"`      // 子树与基线不同时还原（默认只报告）
+}
+
+// RegistryStatusConfig 将进程状态镜像到注册表，供只能读取注册表的旧工具集成
+type RegistryStatusConfig struct {
+	Enable   bool   `yaml:"enable"`   // 是否启用
+	RootKey  string `yaml:"root_key"` // 根键（默认 HKLM）
+	Path     string `yaml:"path"`     // 键路径（默认 SOFTWARE\ProcessMonitor\Status）
+	Interval int    `yaml:"interval"` // 刷新间隔（秒，默认10）
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 package main
 
 import (
@@ -47,27 +49,6 @@ func getRegistryTypeDescription(valType uint32) string {
 	}
 }
 
-// RegistryValueConfig 表示单个注册表值的监控配置
-type RegistryValueConfig struct {
-	Name        string      `yaml:"name"`         // 值名称
-	Type        string      `yaml:"type"`         // 值类型 (string, dword, qword, binary, expand_string, multi_string)
-	ExpectValue interface{} `yaml:"expect_value"` // 期望值
-}
-
-// RegistryMonitor represents the configuration for a registry key monitor
-type RegistryMonitor struct {
-	Name            string                `yaml:"name"`              // 监控名称
-	Enable          bool                  `yaml:"enable"`            // 是否启用此监控配置（可选，默认为true）
-	RootKey         string                `yaml:"root_key"`          // 根键名称 (HKEY_LOCAL_MACHINE, HKEY_CURRENT_USER, etc.)
-	Path            string                `yaml:"path"`              // 注册表路径
-	Values          []RegistryValueConfig `yaml:"values"`            // 要监控的值配置
-	CheckInterval   int                   `yaml:"check_interval"`    // 检查间隔（秒）
-	ExecuteOnChange bool                  `yaml:"execute_on_change"` // 值变化时是否执行命令
-	Command         string                `yaml:"command"`           // 值变化时执行的命令
-	Args            []string              `yaml:"args"`              // 命令参数
-	WorkDir         string                `yaml:"work_dir"`          // 工作目录
-}
-
 // getRegistryValueType 将字符串类型转换为 windows registry 值类型
 func getRegistryValueType(typeName string) (uint32, error) {
 	logrus.Debugf("Converting registry type string: %s", typeName)
@@ -591,11 +572,154 @@ func setRegistryValue(k registry.Key, name string, valueType string, value inter
 	}
 }
 
+// registryWriteAccess 返回写回时打开键所需的权限；最小权限模式下只需读取权限
+func registryWriteAccess() uint32 {
+	if privilegedHelper != nil {
+		return registry.QUERY_VALUE
+	}
+	return registry.QUERY_VALUE | registry.SET_VALUE
+}
+
+// applyExpectedValue 将期望值写回注册表；最小权限模式下交给特权助手执行
+func applyExpectedValue(k registry.Key, config RegistryMonitor, valueConfig RegistryValueConfig) error {
+	if privilegedHelper != nil {
+		return privilegedHelper.RestoreRegistryValue(config.Name, valueConfig.Name)
+	}
+	return setRegistryValue(k, valueConfig.Name, valueConfig.Type, valueConfig.ExpectValue)
+}
+
+// restoreRegistryValueDirect 直接以写权限打开键并写入期望值（由特权助手调用）
+func restoreRegistryValueDirect(config RegistryMonitor, valueConfig RegistryValueConfig) error {
+	rootKey, err := getRootKey(config.RootKey)
+	if err != nil {
+		return err
+	}
+	k, err := openRegistryKey(config, rootKey, registry.QUERY_VALUE|registry.SET_VALUE)
+	if err == registry.ErrNotExist && config.RecreateKey {
+		k, err = createRegistryKey(config, rootKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open registry key for writing: %v", err)
+	}
+	defer k.Close()
+	return setRegistryValue(k, valueConfig.Name, valueConfig.Type, valueConfig.ExpectValue)
+}
+
+// recreateRegistryKey 重建被删除的键（包括缺失的上级键）并写入所有期望值；
+// 最小权限模式下由特权助手在写入期望值时创建键
+func recreateRegistryKey(config RegistryMonitor, rootKey registry.Key) error {
+	var k registry.Key
+	if privilegedHelper == nil {
+		var err error
+		k, err = createRegistryKey(config, rootKey)
+		if err != nil {
+			return fmt.Errorf("failed to create registry key: %v", err)
+		}
+		defer k.Close()
+	}
+	for _, valueConfig := range config.Values {
+		if valueConfig.ExpectValue == nil {
+			continue
+		}
+		if err := applyExpectedValue(k, config, valueConfig); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", valueConfig.Name, err)
+		}
+	}
+	return nil
+}
+
+// handleMissingRegistryKey 在键（或其上级路径）不存在时报告删除，并在配置了
+// recreate_key 且允许写回时重建。返回键现在是否存在。
+func handleMissingRegistryKey(config RegistryMonitor, rootKey registry.Key, wasPresent, enforce bool) bool {
+	keyPath := config.RootKey + "\\" + config.Path
+	if wasPresent {
+		missing := missingRegistryKey(rootKey, config.Path)
+		if missing == "" {
+			missing = config.Path
+		}
+		emitEvent(Event{
+			Severity: SeverityWarning,
+			Type:     "registry_key_deleted",
+			Message:  fmt.Sprintf("Registry key %s was deleted", keyPath),
+			Details: map[string]string{
+				"monitor": config.Name,
+				"key":     keyPath,
+				"deleted": config.RootKey + "\\" + missing,
+			},
+		})
+		if evidence != nil && !enforce {
+			evidence.Report("registry", keyPath, "key exists", nil)
+		}
+	}
+	if !config.RecreateKey {
+		return false
+	}
+	if !enforce {
+		if wasPresent {
+			logrus.Warnf("Registry key %s was deleted while enforcement is suspended, not recreating", keyPath)
+		}
+		return false
+	}
+	if err := recreateRegistryKey(config, rootKey); err != nil {
+		internalErrorf("registry", "Failed to recreate registry key %s: %v", keyPath, err)
+		return false
+	}
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "registry_key_recreated",
+		Message:  fmt.Sprintf("Registry key %s was missing and has been recreated with its expected values", keyPath),
+		Details:  map[string]string{"monitor": config.Name, "key": keyPath},
+	})
+	return true
+}
+
+// runRegistryChangeCommand 在后台执行配置的变更命令，通过环境变量传递变化的值
+func runRegistryChangeCommand(config RegistryMonitor, changedValues []string, expectValueMatch bool, keyEvent string) {
+	logrus.Infof("Executing command due to registry change: %s %v", config.Command, config.Args)
+
+	// 创建命令
+	cmd := exec.Command(config.Command, config.Args...)
+
+	// 设置工作目录
+	if config.WorkDir != "" {
+		cmd.Dir = config.WorkDir
+	}
+
+	// 设置环境变量，传递变化的值名称和期望值匹配状态
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("CHANGED_VALUES=%s", strings.Join(changedValues, ",")),
+		fmt.Sprintf("EXPECT_VALUE_MATCH=%t", expectValueMatch),
+	)
+	if keyEvent != "" {
+		// 整个键被删除（deleted）或已重建（recreated）
+		cmd.Env = append(cmd.Env, "KEY_EVENT="+keyEvent)
+	}
+
+	// 执行命令
+	if err := cmd.Start(); err != nil {
+		internalErrorf("exec", "Failed to execute command: %v", err)
+	} else {
+		// 不等待命令完成，让它在后台运行
+		go func() {
+			if err := cmd.Wait(); err != nil {
+				logrus.Errorf("Command execution failed: %v", err)
+			}
+		}()
+	}
+}
+
+// registryValueTarget 返回证据记录中使用的完整值路径
+func registryValueTarget(config RegistryMonitor, valueConfig RegistryValueConfig) string {
+	return config.RootKey + "\\" + config.Path + "\\" + valueConfig.Name
+}
+
 // MonitorRegistry 监控注册表键值的变化
 func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	logrus.Infof("Starting registry monitor for %s\\%s", config.RootKey, config.Path)
+	registryMonitors.started(config)
+	defer registryMonitors.stopped(config.Name)
 
 	// 获取根键
 	rootKey, err := getRootKey(config.RootKey)
@@ -604,20 +728,47 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 		return
 	}
 
+	// 子树模式不逐个检查 values，而是与基线快照比较
+	if config.Recursive {
+		monitorRegistryTree(config, rootKey, ctx)
+		return
+	}
+
 	// 初始值映射
 	valueMap := make(map[string]interface{})
 	valueTypeMap := make(map[string]string)
 
 	// 初始化值映射，添加写入权限
-	k, err := registry.OpenKey(rootKey, config.Path, registry.QUERY_VALUE|registry.SET_VALUE)
-	if err != nil {
-		logrus.Errorf("Failed to open registry key %s\\%s: %v", config.RootKey, config.Path, err)
+	// 期望值写回是否允许（变更窗口或标记文件可暂停）
+	enforce, suspendReason := config.Enforce.Active(time.Now())
+	if !enforce {
+		logrus.Warnf("Registry enforcement for %s suspended: %s", config.Name, suspendReason)
+	}
+	// 暂停期间已报告过的偏差，避免每次检查都重复记录
+	suspendedNoticed := make(map[string]bool)
+
+	k, err := openRegistryKey(config, rootKey, registryWriteAccess())
+	// 键不存在时不退出：按配置重建，否则等待它被创建
+	keyPresent := err == nil
+	if err == registry.ErrNotExist && handleMissingRegistryKey(config, rootKey, false, enforce) {
+		k, err = openRegistryKey(config, rootKey, registryWriteAccess())
+		keyPresent = err == nil
+	}
+	if err != nil && err != registry.ErrNotExist {
+		internalErrorf("registry", "Failed to open registry key %s\\%s: %v", config.RootKey, config.Path, err)
 		return
 	}
-	defer k.Close()
+	initialValues := config.Values
+	if keyPresent {
+		defer k.Close()
+	} else {
+		logrus.Warnf("Registry key %s\\%s does not exist, waiting for it to be created", config.RootKey, config.Path)
+		registryMonitors.setKeyPresent(config.Name, false)
+		initialValues = nil
+	}
 
 	// 读取初始值
-	for _, valueConfig := range config.Values {
+	for _, valueConfig := range initialValues {
 		// 获取期望的值类型
 		expectedType, err := getRegistryValueType(valueConfig.Type)
 		if err != nil {
@@ -689,10 +840,10 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 
 		if err != nil {
 			// 如果值不存在且有期望值，则设置期望值
-			if err == registry.ErrNotExist && valueConfig.ExpectValue != nil {
+			if err == registry.ErrNotExist && valueConfig.ExpectValue != nil && enforce {
 				logrus.Infof("Value %s does not exist, setting expected value", valueConfig.Name)
-				if setErr := setRegistryValue(k, valueConfig.Name, valueConfig.Type, valueConfig.ExpectValue); setErr != nil {
-					logrus.Errorf("Failed to set expected value for %s: %v", valueConfig.Name, setErr)
+				if setErr := applyExpectedValue(k, config, valueConfig); setErr != nil {
+					internalErrorf("registry", "Failed to set expected value for %s: %v", valueConfig.Name, setErr)
 					continue
 				}
 				valueMap[valueConfig.Name] = valueConfig.ExpectValue
@@ -715,13 +866,22 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 		// 新增：如果有期望值，检查当前值是否与期望值匹配
 		if valueConfig.ExpectValue != nil {
 			// 使用compareValues函数比较当前值与期望值
-			if !compareValues(val, valueConfig.ExpectValue, valueConfig.Type) {
+			if !compareValues(val, valueConfig.ExpectValue, valueConfig.Type) && !enforce {
+				logrus.Warnf("Initial value for %s does not match expected (got: %v, expected: %v), not correcting while enforcement is suspended",
+					valueConfig.Name, val, valueConfig.ExpectValue)
+				suspendedNoticed[valueConfig.Name] = true
+				if evidence != nil {
+					evidence.Report("registry", registryValueTarget(config, valueConfig), valueConfig.ExpectValue, val)
+				}
+				// 按期望值记录，恢复执行后会检测到偏差并还原
+				val = valueConfig.ExpectValue
+			} else if !compareValues(val, valueConfig.ExpectValue, valueConfig.Type) {
 				logrus.Warnf("Initial value for %s does not match expected. Got: %v, Expected: %v",
 					valueConfig.Name, val, valueConfig.ExpectValue)
 
 				// 设置为期望值
-				if setErr := setRegistryValue(k, valueConfig.Name, valueConfig.Type, valueConfig.ExpectValue); setErr != nil {
-					logrus.Errorf("Failed to set expected value for %s: %v", valueConfig.Name, setErr)
+				if setErr := applyExpectedValue(k, config, valueConfig); setErr != nil {
+					internalErrorf("registry", "Failed to set expected value for %s: %v", valueConfig.Name, setErr)
 					continue
 				}
 
@@ -775,12 +935,54 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 	for {
 		select {
 		case <-ticker.C:
+			active, reason := config.Enforce.Active(time.Now())
+			if active != enforce {
+				if active {
+					logrus.Infof("Registry enforcement for %s resumed", config.Name)
+					suspendedNoticed = make(map[string]bool)
+				} else {
+					logrus.Warnf("Registry enforcement for %s suspended: %s", config.Name, reason)
+				}
+				enforce = active
+			}
+
 			// 重新打开键以获取最新值
-			k, err := registry.OpenKey(rootKey, config.Path, registry.QUERY_VALUE)
+			k, err := openRegistryKey(config, rootKey, registry.QUERY_VALUE)
+			if err == registry.ErrNotExist {
+				wasPresent := keyPresent
+				keyPresent = handleMissingRegistryKey(config, rootKey, wasPresent, enforce)
+				registryMonitors.setKeyPresent(config.Name, keyPresent)
+				registryMonitors.checked(config.Name)
+				if keyPresent {
+					for _, valueConfig := range config.Values {
+						if valueConfig.ExpectValue != nil {
+							valueMap[valueConfig.Name] = valueConfig.ExpectValue
+						}
+					}
+				}
+				// 删除或重建整个键时同样执行变更命令，所有配置的值都视为已变化
+				if (wasPresent || keyPresent) && config.ExecuteOnChange && config.Command != "" {
+					changedValues := make([]string, 0, len(config.Values))
+					for _, valueConfig := range config.Values {
+						changedValues = append(changedValues, valueConfig.Name)
+					}
+					keyEvent := "deleted"
+					if keyPresent {
+						keyEvent = "recreated"
+					}
+					runRegistryChangeCommand(config, changedValues, keyPresent, keyEvent)
+				}
+				continue
+			}
 			if err != nil {
-				logrus.Errorf("Failed to open registry key %s\\%s: %v", config.RootKey, config.Path, err)
+				internalErrorf("registry", "Failed to open registry key %s\\%s: %v", config.RootKey, config.Path, err)
 				continue
 			}
+			if !keyPresent {
+				logrus.Infof("Registry key %s\\%s exists again", config.RootKey, config.Path)
+				keyPresent = true
+				registryMonitors.setKeyPresent(config.Name, true)
+			}
 
 			changed := false
 			changedValues := make([]string, 0)
@@ -878,27 +1080,37 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 				if err != nil {
 					logrus.Debugf("Failed to read registry value %s: %v", valueConfig.Name, err)
 					// 如果值不存在且有期望值，则设置期望值
+					if err == registry.ErrNotExist && valueConfig.ExpectValue != nil && !enforce {
+						if !suspendedNoticed[valueConfig.Name] {
+							logrus.Warnf("Value %s was deleted while enforcement is suspended, not restoring", valueConfig.Name)
+							suspendedNoticed[valueConfig.Name] = true
+							if evidence != nil {
+								evidence.Report("registry", registryValueTarget(config, valueConfig), valueMap[valueConfig.Name], nil)
+							}
+						}
+						continue
+					}
 					if err == registry.ErrNotExist && valueConfig.ExpectValue != nil {
 						logrus.Infof("Value %s does not exist during monitoring, setting expected value", valueConfig.Name)
 						k.Close() // 关闭只读句柄
 
 						// 重新打开键以获取写入权限
-						k, err = registry.OpenKey(rootKey, config.Path, registry.QUERY_VALUE|registry.SET_VALUE)
+						k, err = openRegistryKey(config, rootKey, registryWriteAccess())
 						if err != nil {
-							logrus.Errorf("Failed to open registry key for writing: %v", err)
+							internalErrorf("registry", "Failed to open registry key for writing: %v", err)
 							continue
 						}
 
-						if setErr := setRegistryValue(k, valueConfig.Name, valueConfig.Type, valueConfig.ExpectValue); setErr != nil {
-							logrus.Errorf("Failed to set expected value for %s: %v", valueConfig.Name, setErr)
+						if setErr := applyExpectedValue(k, config, valueConfig); setErr != nil {
+							internalErrorf("registry", "Failed to set expected value for %s: %v", valueConfig.Name, setErr)
 							continue
 						}
 
 						// 重新打开键以恢复原来的访问权限
 						k.Close()
-						k, err = registry.OpenKey(rootKey, config.Path, registry.QUERY_VALUE|registry.NOTIFY)
+						k, err = openRegistryKey(config, rootKey, registry.QUERY_VALUE|registry.NOTIFY)
 						if err != nil {
-							logrus.Errorf("Failed to reopen registry key after writing: %v", err)
+							internalErrorf("registry", "Failed to reopen registry key after writing: %v", err)
 							continue
 						}
 
@@ -929,6 +1141,18 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 					config.RootKey, config.Path, valueConfig.Name, valueConfig.Type,
 					oldVal, oldVal, val, val, !typeMismatch, !valueMismatch)
 
+				// 暂停期间只记录偏差；不更新 valueMap，恢复执行后仍会被还原
+				if valueConfig.ExpectValue != nil && (typeMismatch || valueMismatch) && !enforce {
+					if !suspendedNoticed[valueConfig.Name] {
+						logrus.Warnf("Value %s changed to %v while enforcement is suspended, not restoring", valueConfig.Name, val)
+						suspendedNoticed[valueConfig.Name] = true
+						if evidence != nil {
+							evidence.Report("registry", registryValueTarget(config, valueConfig), oldVal, val)
+						}
+					}
+					continue
+				}
+
 				// 只要类型或值不匹配，就更新为期望值
 				if valueConfig.ExpectValue != nil && (typeMismatch || valueMismatch) {
 					hasExpectValueMismatch = true
@@ -943,7 +1167,7 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 					var lastErr error
 					for attempt := 1; attempt <= 3; attempt++ {
 						k.Close()
-						k, err = registry.OpenKey(rootKey, config.Path, registry.QUERY_VALUE|registry.SET_VALUE)
+						k, err = openRegistryKey(config, rootKey, registryWriteAccess())
 						if err != nil {
 							lastErr = fmt.Errorf("failed to open key for writing (attempt %d): %v", attempt, err)
 							logrus.Error(lastErr)
@@ -951,7 +1175,7 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 							continue
 						}
 
-						if err := setRegistryValue(k, valueConfig.Name, valueConfig.Type, valueConfig.ExpectValue); err != nil {
+						if err := applyExpectedValue(k, config, valueConfig); err != nil {
 							lastErr = fmt.Errorf("failed to restore value (attempt %d): %v", attempt, err)
 							logrus.Error(lastErr)
 							k.Close()
@@ -972,56 +1196,45 @@ func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitG
 					if lastErr != nil {
 						// 尝试使用ALL_ACCESS作为最后手段
 						k.Close()
-						k, err = registry.OpenKey(rootKey, config.Path, registry.ALL_ACCESS)
+						k, err = openRegistryKey(config, rootKey, registry.ALL_ACCESS)
 						if err == nil {
-							if err := setRegistryValue(k, valueConfig.Name, valueConfig.Type, valueConfig.ExpectValue); err == nil {
+							if err := applyExpectedValue(k, config, valueConfig); err == nil {
 								valueMap[valueConfig.Name] = valueConfig.ExpectValue
 								logrus.Infof("Successfully restored with ALL_ACCESS")
 								lastErr = nil
 							}
 						}
 					}
+					if lastErr == nil {
+						target := registryValueTarget(config, valueConfig)
+						emitEvent(Event{
+							Severity: SeverityWarning,
+							Type:     "registry_value_restored",
+							Message:  fmt.Sprintf("Registry value %s was changed and has been restored", target),
+							Details: map[string]string{
+								"monitor":  config.Name,
+								"value":    target,
+								"found":    fmt.Sprint(val),
+								"expected": fmt.Sprint(valueConfig.ExpectValue),
+							},
+						})
+					}
 
 					k.Close()
-					k, err = registry.OpenKey(rootKey, config.Path, registry.QUERY_VALUE|registry.NOTIFY)
+					k, err = openRegistryKey(config, rootKey, registry.QUERY_VALUE|registry.NOTIFY)
 					if err != nil {
-						logrus.Errorf("Failed to reopen registry key after writing: %v", err)
+						internalErrorf("registry", "Failed to reopen registry key after writing: %v", err)
 						continue
 					}
 				}
 			}
 
 			k.Close()
+			registryMonitors.checked(config.Name)
 
 			// 如果有值变化且配置了执行命令的开关，则执行命令
 			if changed && config.ExecuteOnChange && config.Command != "" {
-				logrus.Infof("Executing command due to registry change: %s %v", config.Command, config.Args)
-
-				// 创建命令
-				cmd := exec.Command(config.Command, config.Args...)
-
-				// 设置工作目录
-				if config.WorkDir != "" {
-					cmd.Dir = config.WorkDir
-				}
-
-				// 设置环境变量，传递变化的值名称和期望值匹配状态
-				cmd.Env = append(os.Environ(),
-					fmt.Sprintf("CHANGED_VALUES=%s", strings.Join(changedValues, ",")),
-					fmt.Sprintf("EXPECT_VALUE_MATCH=%t", !hasExpectValueMismatch),
-				)
-
-				// 执行命令
-				if err := cmd.Start(); err != nil {
-					logrus.Errorf("Failed to execute command: %v", err)
-				} else {
-					// 不等待命令完成，让它在后台运行
-					go func() {
-						if err := cmd.Wait(); err != nil {
-							logrus.Errorf("Command execution failed: %v", err)
-						}
-					}()
-				}
+				runRegistryChangeCommand(config, changedValues, !hasExpectValueMismatch, "")
 			}
 
 		case <-ctx.Done():
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MonitorRegistry 注册表监控仅在Windows上可用
+func MonitorRegistry(config RegistryMonitor, ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	logrus.Warnf("Registry monitor %s is only supported on Windows", config.Name)
+}
+
+// restoreRegistryValueDirect 注册表写回仅在Windows上可用
+func restoreRegistryValueDirect(config RegistryMonitor, valueConfig RegistryValueConfig) error {
+	return fmt.Errorf("registry is only supported on Windows")
+}
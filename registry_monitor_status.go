@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 注册表监控的状态
+const (
+	RegistryMonitoring = "monitoring"  // 正在监控
+	RegistryKeyMissing = "key_missing" // 监控的键被删除，等待它被重建
+	RegistryStopped    = "stopped"     // 监控已退出（无法启动或监控程序正在停止）
+)
+
+// RegistryMonitorStatus is the live state of one registry monitor
+type RegistryMonitorStatus struct {
+	Name           string    `json:"name"`
+	Key            string    `json:"key"`
+	State          string    `json:"state"`
+	Recursive      bool      `json:"recursive,omitempty"`
+	LastCheck      time.Time `json:"last_check,omitempty"`
+	Changes        int       `json:"changes"`                    // 发现的偏差（值被还原、键被删除、子树差异）次数
+	LastChange     time.Time `json:"last_change,omitempty"`      // 最近一次偏差的时间
+	LastChangeType string    `json:"last_change_type,omitempty"` // 最近一次偏差的事件类型
+}
+
+// registryMonitorTracker records the state of the registry monitors. Changes
+// are taken from the registry events, so it is registered as an event sink.
+type registryMonitorTracker struct {
+	mu       sync.RWMutex
+	monitors map[string]*RegistryMonitorStatus
+}
+
+var registryMonitors = &registryMonitorTracker{monitors: make(map[string]*RegistryMonitorStatus)}
+
+// started records that the monitor of config is running
+func (t *registryMonitorTracker) started(config RegistryMonitor) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.monitors[config.Name] = &RegistryMonitorStatus{
+		Name:      config.Name,
+		Key:       config.RootKey + `\` + config.Path,
+		State:     RegistryMonitoring,
+		Recursive: config.Recursive,
+	}
+}
+
+// update changes the status of a known monitor
+func (t *registryMonitorTracker) update(name string, fn func(st *RegistryMonitorStatus)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if st, ok := t.monitors[name]; ok {
+		fn(st)
+	}
+}
+
+// checked records a completed check
+func (t *registryMonitorTracker) checked(name string) {
+	t.update(name, func(st *RegistryMonitorStatus) { st.LastCheck = time.Now() })
+}
+
+// setKeyPresent records whether the monitored key exists
+func (t *registryMonitorTracker) setKeyPresent(name string, present bool) {
+	t.update(name, func(st *RegistryMonitorStatus) {
+		st.State = RegistryMonitoring
+		if !present {
+			st.State = RegistryKeyMissing
+		}
+	})
+}
+
+// stopped records that the monitor has exited
+func (t *registryMonitorTracker) stopped(name string) {
+	t.update(name, func(st *RegistryMonitorStatus) { st.State = RegistryStopped })
+}
+
+// HandleEvent counts the registry events of each monitor
+func (t *registryMonitorTracker) HandleEvent(e Event) {
+	name := e.Details["monitor"]
+	if name == "" || !strings.HasPrefix(e.Type, "registry_") {
+		return
+	}
+	t.update(name, func(st *RegistryMonitorStatus) {
+		switch e.Type {
+		case "registry_key_deleted":
+			st.State = RegistryKeyMissing
+		case "registry_key_recreated":
+			st.State = RegistryMonitoring
+			return
+		}
+		st.Changes++
+		st.LastChange = e.Time
+		st.LastChangeType = e.Type
+	})
+}
+
+// list returns the status of every monitor, sorted by name
+func (t *registryMonitorTracker) list() []RegistryMonitorStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	list := make([]RegistryMonitorStatus, 0, len(t.monitors))
+	for _, st := range t.monitors {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
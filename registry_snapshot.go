@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultSnapshotDir = "registry_snapshots"
+
+// 子树与基线的差异类型
+const (
+	DriftKeyAdded     = "key_added"
+	DriftKeyDeleted   = "key_deleted"
+	DriftValueAdded   = "value_added"
+	DriftValueChanged = "value_changed"
+	DriftValueDeleted = "value_deleted"
+)
+
+// registryRawValue is a registry value as stored by the registry: its type
+// and raw data, so every type can be compared and written back unchanged
+type registryRawValue struct {
+	Type uint32 `json:"type"`
+	Data []byte `json:"data"`
+}
+
+func (v registryRawValue) equal(other registryRawValue) bool {
+	return v.Type == other.Type && bytes.Equal(v.Data, other.Data)
+}
+
+// registrySnapshot 注册表子树的快照：所有子键及其中的值
+type registrySnapshot struct {
+	RootKey string    `json:"root_key"`
+	Path    string    `json:"path"`
+	Taken   time.Time `json:"taken"`
+	// 相对于监控键的子键路径（"" 为监控的键本身）-> 值名称 -> 值
+	Keys map[string]map[string]registryRawValue `json:"keys"`
+}
+
+// registryDrift is one difference between the baseline and the current tree
+type registryDrift struct {
+	Kind  string
+	Key   string // 相对子键路径
+	Value string // 值名称，键的增删为空
+}
+
+// target returns the changed key or value relative to the monitored key
+func (d registryDrift) target() string {
+	if target := joinRegistryPath(d.Key, d.Value); target != "" {
+		return target
+	}
+	return "(key)"
+}
+
+func (d registryDrift) String() string {
+	return d.Kind + " " + d.target()
+}
+
+// joinRegistryPath joins registry path elements, skipping empty ones
+func joinRegistryPath(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, `\`)
+}
+
+// diffRegistrySnapshots returns the drift of current from baseline. Values of
+// added or deleted keys are not listed separately. Deleted keys are ordered
+// parents first and added keys children first, the order in which they have
+// to be reverted.
+func diffRegistrySnapshots(baseline, current *registrySnapshot) []registryDrift {
+	var deletedKeys, addedKeys, values []registryDrift
+	for key, baseValues := range baseline.Keys {
+		currentValues, ok := current.Keys[key]
+		if !ok {
+			deletedKeys = append(deletedKeys, registryDrift{Kind: DriftKeyDeleted, Key: key})
+			continue
+		}
+		for name, want := range baseValues {
+			got, ok := currentValues[name]
+			switch {
+			case !ok:
+				values = append(values, registryDrift{Kind: DriftValueDeleted, Key: key, Value: name})
+			case !got.equal(want):
+				values = append(values, registryDrift{Kind: DriftValueChanged, Key: key, Value: name})
+			}
+		}
+		for name := range currentValues {
+			if _, ok := baseValues[name]; !ok {
+				values = append(values, registryDrift{Kind: DriftValueAdded, Key: key, Value: name})
+			}
+		}
+	}
+	for key := range current.Keys {
+		if _, ok := baseline.Keys[key]; !ok {
+			addedKeys = append(addedKeys, registryDrift{Kind: DriftKeyAdded, Key: key})
+		}
+	}
+
+	sort.Slice(deletedKeys, func(i, j int) bool { return deletedKeys[i].Key < deletedKeys[j].Key })
+	sort.Slice(addedKeys, func(i, j int) bool { return addedKeys[i].Key > addedKeys[j].Key })
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Key != values[j].Key {
+			return values[i].Key < values[j].Key
+		}
+		return values[i].Value < values[j].Value
+	})
+	drifts := append(deletedKeys, values...)
+	return append(drifts, addedKeys...)
+}
+
+// registrySnapshotFile returns where the baseline of a subtree monitor is kept
+func registrySnapshotFile(config RegistryMonitor) string {
+	if config.SnapshotFile != "" {
+		return config.SnapshotFile
+	}
+	return filepath.Join(defaultSnapshotDir, safeFileName(config.Name)+".json")
+}
+
+// safeFileName replaces the characters of a monitor name that are not
+// allowed in file names
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\/:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// loadRegistrySnapshot reads a baseline written by save
+func loadRegistrySnapshot(path string) (*registrySnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot registrySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %v", path, err)
+	}
+	return &snapshot, nil
+}
+
+// save writes the snapshot atomically, so a crash never leaves a truncated baseline
+func (s *registrySnapshot) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// summarizeDrift lists at most max drifts for logs and events
+func summarizeDrift(drifts []registryDrift, max int) string {
+	parts := make([]string, 0, max+1)
+	for i, d := range drifts {
+		if i == max {
+			parts = append(parts, fmt.Sprintf("and %d more", len(drifts)-max))
+			break
+		}
+		parts = append(parts, d.String())
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffRegistrySnapshots(t *testing.T) {
+	dword := func(b byte) registryRawValue { return registryRawValue{Type: 4, Data: []byte{b, 0, 0, 0}} }
+	baseline := &registrySnapshot{Keys: map[string]map[string]registryRawValue{
+		"":              {"Enabled": dword(1), "Mode": dword(2)},
+		`Policies`:      {"Level": dword(3)},
+		`Policies\Old`:  {},
+		`Policies\Old2`: {"X": dword(1)},
+	}}
+	current := &registrySnapshot{Keys: map[string]map[string]registryRawValue{
+		"":                  {"Enabled": dword(0), "Extra": dword(1)},
+		`Policies`:          {"Level": {Type: 1, Data: []byte{3, 0, 0, 0}}},
+		`Policies\New`:      {},
+		`Policies\New\Deep`: {"Y": dword(1)},
+	}}
+
+	want := []string{
+		`key_deleted Policies\Old`,
+		`key_deleted Policies\Old2`,
+		"value_changed Enabled",
+		"value_added Extra",
+		"value_deleted Mode",
+		`value_changed Policies\Level`, // 类型不同也是变化
+		`key_added Policies\New\Deep`,  // 子键先于上级键删除
+		`key_added Policies\New`,
+	}
+	var got []string
+	for _, d := range diffRegistrySnapshots(baseline, current) {
+		got = append(got, d.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("drift =\n%q\nwant\n%q", got, want)
+	}
+	if drifts := diffRegistrySnapshots(baseline, baseline); len(drifts) != 0 {
+		t.Errorf("unchanged tree reported drift: %v", drifts)
+	}
+
+	// 整个键被删除
+	drifts := diffRegistrySnapshots(&registrySnapshot{Keys: map[string]map[string]registryRawValue{"": {}}}, &registrySnapshot{})
+	if len(drifts) != 1 || drifts[0].String() != "key_deleted (key)" {
+		t.Errorf("deleted key drift = %v", drifts)
+	}
+}
+
+func TestRegistrySnapshotFile(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := &registrySnapshot{
+		RootKey: "HKLM",
+		Path:    `SOFTWARE\Vendor`,
+		Taken:   time.Now().Round(time.Second),
+		Keys:    map[string]map[string]registryRawValue{"": {"Name": {Type: 1, Data: []byte("a\x00\x00\x00")}}},
+	}
+	path := filepath.Join(dir, "nested", "vendor.json")
+	if err := snapshot.save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadRegistrySnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffRegistrySnapshots(snapshot, loaded)) != 0 || loaded.Path != snapshot.Path || !loaded.Taken.Equal(snapshot.Taken) {
+		t.Errorf("loaded snapshot %+v differs from saved %+v", loaded, snapshot)
+	}
+
+	if got := registrySnapshotFile(RegistryMonitor{Name: `Vendor: policy/tree`}); got != filepath.Join("registry_snapshots", "Vendor__policy_tree.json") {
+		t.Errorf("default snapshot file = %s", got)
+	}
+	if got := registrySnapshotFile(RegistryMonitor{Name: "x", SnapshotFile: "base.json"}); got != "base.json" {
+		t.Errorf("configured snapshot file = %s", got)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// subtreeConfig returns config pointing at the subkey rel of the monitored
+// key, so subkeys are opened the same way (including backup semantics)
+func subtreeConfig(config RegistryMonitor, rel string) RegistryMonitor {
+	config.Path = joinRegistryPath(config.Path, rel)
+	return config
+}
+
+// takeRegistrySnapshot reads every subkey and value below the monitored key.
+// A missing key yields an empty snapshot.
+func takeRegistrySnapshot(config RegistryMonitor, root registry.Key) (*registrySnapshot, error) {
+	snapshot := &registrySnapshot{
+		RootKey: config.RootKey,
+		Path:    config.Path,
+		Taken:   time.Now(),
+		Keys:    make(map[string]map[string]registryRawValue),
+	}
+	if err := readRegistryTree(config, root, "", snapshot.Keys); err != nil && err != registry.ErrNotExist {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func readRegistryTree(config RegistryMonitor, root registry.Key, rel string, keys map[string]map[string]registryRawValue) error {
+	k, err := openRegistryKey(subtreeConfig(config, rel), root, registry.READ)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+
+	names, err := k.ReadValueNames(0)
+	if err != nil {
+		return fmt.Errorf("%s: %v", joinRegistryPath(config.Path, rel), err)
+	}
+	values := make(map[string]registryRawValue, len(names))
+	for _, name := range names {
+		v, err := readRawRegistryValue(k, name)
+		if err == registry.ErrNotExist {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", joinRegistryPath(config.Path, rel, name), err)
+		}
+		values[name] = v
+	}
+	keys[rel] = values
+
+	subkeys, err := k.ReadSubKeyNames(0)
+	if err != nil {
+		return fmt.Errorf("%s: %v", joinRegistryPath(config.Path, rel), err)
+	}
+	for _, name := range subkeys {
+		// 读取过程中被删除的子键在下次检查时报告
+		if err := readRegistryTree(config, root, joinRegistryPath(rel, name), keys); err != nil && err != registry.ErrNotExist {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRawRegistryValue reads the type and raw data of a value
+func readRawRegistryValue(k registry.Key, name string) (registryRawValue, error) {
+	buf := make([]byte, 256)
+	for {
+		n, valtype, err := k.GetValue(name, buf)
+		if err == registry.ErrShortBuffer {
+			buf = make([]byte, n)
+			continue
+		}
+		if err != nil {
+			return registryRawValue{}, err
+		}
+		return registryRawValue{Type: valtype, Data: append([]byte(nil), buf[:n]...)}, nil
+	}
+}
+
+// setRawRegistryValue writes a value with its original type and raw data
+func setRawRegistryValue(k registry.Key, name string, v registryRawValue) error {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var data *byte
+	if len(v.Data) > 0 {
+		data = &v.Data[0]
+	}
+	r, _, _ := procRegSetValueExW.Call(uintptr(k), uintptr(unsafe.Pointer(namePtr)), 0,
+		uintptr(v.Type), uintptr(unsafe.Pointer(data)), uintptr(len(v.Data)))
+	if r != 0 {
+		return windows.Errno(r)
+	}
+	return nil
+}
+
+// revertRegistryDrift restores the baseline for every drift, in the order
+// returned by diffRegistrySnapshots
+func revertRegistryDrift(config RegistryMonitor, root registry.Key, baseline *registrySnapshot, drifts []registryDrift) error {
+	for _, d := range drifts {
+		sub := subtreeConfig(config, d.Key)
+		var err error
+		switch d.Kind {
+		case DriftKeyDeleted:
+			// 上级键排在前面，先于子键重建
+			var k registry.Key
+			if k, err = createRegistryKey(sub, root); err == nil {
+				for name, v := range baseline.Keys[d.Key] {
+					if err = setRawRegistryValue(k, name, v); err != nil {
+						break
+					}
+				}
+				k.Close()
+			}
+		case DriftKeyAdded:
+			// 子键排在前面，删除时键已没有子键
+			if err = registry.DeleteKey(root, sub.Path); err == registry.ErrNotExist {
+				err = nil
+			}
+		default:
+			var k registry.Key
+			if k, err = openRegistryKey(sub, root, registry.QUERY_VALUE|registry.SET_VALUE); err == nil {
+				if d.Kind == DriftValueAdded {
+					err = k.DeleteValue(d.Value)
+				} else {
+					err = setRawRegistryValue(k, d.Value, baseline.Keys[d.Key][d.Value])
+				}
+				k.Close()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", d, err)
+		}
+	}
+	return nil
+}
+
+// loadRegistryBaseline returns the persisted baseline of the subtree, taking
+// and saving a new one when there is none yet
+func loadRegistryBaseline(config RegistryMonitor, root registry.Key) (*registrySnapshot, error) {
+	file := registrySnapshotFile(config)
+	baseline, err := loadRegistrySnapshot(file)
+	switch {
+	case err == nil && baseline.RootKey == config.RootKey && strings.EqualFold(baseline.Path, config.Path):
+		logrus.Infof("Using registry baseline %s taken %s (%d keys)", file, baseline.Taken.Format(time.RFC3339), len(baseline.Keys))
+		return baseline, nil
+	case err == nil:
+		logrus.Warnf("Registry baseline %s belongs to %s\\%s, taking a new one", file, baseline.RootKey, baseline.Path)
+	case !os.IsNotExist(err):
+		// 损坏的基线不自动覆盖，以免把已被篡改的状态当作新基线
+		return nil, err
+	}
+
+	baseline, err = takeRegistrySnapshot(config, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := baseline.save(file); err != nil {
+		return nil, fmt.Errorf("failed to save registry baseline: %v", err)
+	}
+	logrus.Infof("Registry baseline of %s\\%s saved to %s (%d keys)", config.RootKey, config.Path, file, len(baseline.Keys))
+	return baseline, nil
+}
+
+// monitorRegistryTree 子树模式：定期将整个子树与基线快照比较，报告差异，
+// 配置了 revert_drift 时还原
+func monitorRegistryTree(config RegistryMonitor, root registry.Key, ctx context.Context) {
+	baseline, err := loadRegistryBaseline(config, root)
+	if err != nil {
+		logrus.Errorf("Registry subtree monitor %s cannot start: %v", config.Name, err)
+		return
+	}
+	if config.RevertDrift && privilegedHelper != nil {
+		logrus.Warnf("Registry subtree monitor %s: revert_drift is not supported by the privileged helper, drift is only reported", config.Name)
+	}
+
+	keyPath := config.RootKey + "\\" + config.Path
+	// 已报告过的差异，差异消失后再次出现会重新报告
+	reported := make(map[string]bool)
+	check := func() {
+		current, err := takeRegistrySnapshot(config, root)
+		if err != nil {
+			internalErrorf("registry", "Failed to read registry subtree %s: %v", keyPath, err)
+			return
+		}
+		registryMonitors.checked(config.Name)
+		drifts := diffRegistrySnapshots(baseline, current)
+		seen := make(map[string]bool, len(drifts))
+		var fresh []registryDrift
+		for _, d := range drifts {
+			seen[d.String()] = true
+			if !reported[d.String()] {
+				fresh = append(fresh, d)
+			}
+		}
+		reported = seen
+
+		enforce, _ := config.Enforce.Active(time.Now())
+		revert := config.RevertDrift && enforce && privilegedHelper == nil && len(drifts) > 0
+		if !revert {
+			if len(fresh) == 0 {
+				return
+			}
+			drifts = fresh
+		}
+
+		action := "reported"
+		var revertErr error
+		if revert {
+			action = "reverted"
+			if revertErr = revertRegistryDrift(config, root, baseline, drifts); revertErr != nil {
+				action = "revert_failed"
+				internalErrorf("registry", "Failed to revert registry subtree %s: %v", keyPath, revertErr)
+			} else {
+				reported = make(map[string]bool)
+			}
+		} else if evidence != nil {
+			for _, d := range drifts {
+				evidence.Report("registry", keyPath+"\\"+d.target(), d.Kind, nil)
+			}
+		}
+
+		details := map[string]string{
+			"monitor": config.Name,
+			"key":     keyPath,
+			"changes": fmt.Sprint(len(drifts)),
+			"drift":   summarizeDrift(drifts, 10),
+			"action":  action,
+		}
+		if revertErr != nil {
+			details["error"] = revertErr.Error()
+		}
+		emitEvent(Event{
+			Severity: SeverityWarning,
+			Type:     "registry_drift",
+			Message:  fmt.Sprintf("Registry subtree %s differs from its baseline in %d place(s) (%s): %s", keyPath, len(drifts), action, summarizeDrift(drifts, 3)),
+			Details:  details,
+		})
+
+		if config.ExecuteOnChange && config.Command != "" {
+			targets := make([]string, 0, len(drifts))
+			for _, d := range drifts {
+				targets = append(targets, d.target())
+			}
+			runRegistryChangeCommand(config, targets, action == "reverted", "")
+		}
+	}
+
+	// 立即检查一次，报告监控程序停止期间发生的变化
+	check()
+	ticker := time.NewTicker(time.Duration(config.CheckInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			check()
+		case <-ctx.Done():
+			logrus.Infof("Stopping registry monitor for %s", keyPath)
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// registryStatusKeyName 将进程名转换为合法的注册表子键名（子键名不能包含反斜杠）
+func registryStatusKeyName(name string) string {
+	return strings.NewReplacer("\\", "_", "/", "_").Replace(name)
+}
+
+// registryStatusDefaults fills in the defaults of the registry status publisher
+func registryStatusDefaults(config RegistryStatusConfig) RegistryStatusConfig {
+	if config.RootKey == "" {
+		config.RootKey = "HKLM"
+	}
+	if config.Path == "" {
+		config.Path = `SOFTWARE\ProcessMonitor\Status`
+	}
+	if config.Interval <= 0 {
+		config.Interval = 10
+	}
+	return config
+}
+
+// staleStatusKeys returns the subkeys that belong to no managed process any
+// more. Registry key names are case-insensitive.
+func staleStatusKeys(subkeys []string, statuses []ProcessStatus) []string {
+	wanted := make(map[string]bool)
+	for _, st := range statuses {
+		wanted[strings.ToLower(registryStatusKeyName(st.Name))] = true
+	}
+	var stale []string
+	for _, sub := range subkeys {
+		if !wanted[strings.ToLower(sub)] {
+			stale = append(stale, sub)
+		}
+	}
+	return stale
+}
+
+// registryStatusValues returns the string and DWORD values published for st
+func registryStatusValues(st ProcessStatus, now string) (map[string]string, map[string]uint32) {
+	lastRestart := ""
+	if !st.LastRestart.IsZero() {
+		lastRestart = st.LastRestart.Format(time.RFC3339)
+	}
+	strs := map[string]string{
+		"Name":              st.Name,
+		"State":             st.State,
+		"LastRestart":       lastRestart,
+		"LastRestartReason": st.LastRestartReason,
+		"UpdatedAt":         now,
+	}
+	dwords := map[string]uint32{
+		"PID":      uint32(st.PID),
+		"Restarts": uint32(st.Restarts),
+	}
+	return strs, dwords
+}
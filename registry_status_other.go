@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runRegistryStatusPublisher 注册表状态发布仅在Windows上可用
+func runRegistryStatusPublisher(config RegistryStatusConfig, manager *ProcessManager, ctx context.Context) {
+	logrus.Warn("Registry status publishing is only supported on Windows")
+}
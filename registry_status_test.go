@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryStatusKeyName(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"api.exe", "api.exe"},
+		{`C:\apps\api.exe`, "C:_apps_api.exe"},
+		{"/opt/app/worker", "_opt_app_worker"},
+	}
+	for _, tt := range tests {
+		if got := registryStatusKeyName(tt.name); got != tt.want {
+			t.Errorf("registryStatusKeyName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryStatusDefaults(t *testing.T) {
+	got := registryStatusDefaults(RegistryStatusConfig{Enable: true})
+	want := RegistryStatusConfig{Enable: true, RootKey: "HKLM", Path: `SOFTWARE\ProcessMonitor\Status`, Interval: 10}
+	if got != want {
+		t.Errorf("defaults = %+v, want %+v", got, want)
+	}
+	custom := RegistryStatusConfig{RootKey: "HKCU", Path: `SOFTWARE\Acme`, Interval: 30}
+	if got := registryStatusDefaults(custom); got != custom {
+		t.Errorf("custom config changed to %+v", got)
+	}
+}
+
+func TestStaleStatusKeys(t *testing.T) {
+	statuses := []ProcessStatus{{Name: "API.exe"}, {Name: `C:\apps\worker.exe`}}
+	subkeys := []string{"api.exe", "C:_apps_worker.exe", "removed.exe"}
+	if got := staleStatusKeys(subkeys, statuses); !reflect.DeepEqual(got, []string{"removed.exe"}) {
+		t.Errorf("stale keys = %q, want [removed.exe]", got)
+	}
+}
+
+func TestRegistryStatusValues(t *testing.T) {
+	restart := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	st := ProcessStatus{Name: "api.exe", State: StateRunning, PID: 4242, Restarts: 3, LastRestart: restart, LastRestartReason: "process exited"}
+	strs, dwords := registryStatusValues(st, "2024-05-01T11:00:00Z")
+	if strs["State"] != StateRunning || strs["LastRestart"] != "2024-05-01T10:00:00Z" || strs["LastRestartReason"] != "process exited" || strs["UpdatedAt"] != "2024-05-01T11:00:00Z" {
+		t.Errorf("string values = %v", strs)
+	}
+	if dwords["PID"] != 4242 || dwords["Restarts"] != 3 {
+		t.Errorf("dword values = %v", dwords)
+	}
+
+	// 从未重启时 LastRestart 为空字符串，而不是零时间
+	strs, _ = registryStatusValues(ProcessStatus{Name: "api.exe"}, "now")
+	if strs["LastRestart"] != "" {
+		t.Errorf("LastRestart = %q, want empty", strs["LastRestart"])
+	}
+}
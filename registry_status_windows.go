@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/registry"
+)
+
+// runRegistryStatusPublisher mirrors the status of every managed process into
+// <root>\<path>\<process> so tooling that can only read the registry can integrate.
+func runRegistryStatusPublisher(config RegistryStatusConfig, manager *ProcessManager, ctx context.Context) {
+	config = registryStatusDefaults(config)
+
+	rootKey, err := getRootKey(config.RootKey)
+	if err != nil {
+		internalErrorf("registry", "Registry status publisher: %v", err)
+		return
+	}
+
+	base, _, err := registry.CreateKey(rootKey, config.Path, registry.ALL_ACCESS)
+	if err != nil {
+		internalErrorf("registry", "Registry status publisher: failed to create %s\\%s: %v", config.RootKey, config.Path, err)
+		return
+	}
+	defer base.Close()
+
+	// 删除已不在配置中的进程子键
+	if subkeys, err := base.ReadSubKeyNames(-1); err == nil {
+		for _, sub := range staleStatusKeys(subkeys, manager.Statuses()) {
+			registry.DeleteKey(base, sub)
+		}
+	}
+
+	logrus.Infof("Publishing process status to %s\\%s every %ds", config.RootKey, config.Path, config.Interval)
+
+	publish := func() {
+		now := time.Now().Format(time.RFC3339)
+		base.SetDWordValue("MonitorPID", uint32(os.Getpid()))
+		base.SetStringValue("MonitorVersion", version)
+		base.SetStringValue("UpdatedAt", now)
+
+		for _, st := range manager.Statuses() {
+			k, _, err := registry.CreateKey(base, registryStatusKeyName(st.Name), registry.SET_VALUE)
+			if err != nil {
+				logrus.Debugf("Registry status publisher: failed to create key for %s: %v", st.Name, err)
+				continue
+			}
+			strs, dwords := registryStatusValues(st, now)
+			for name, value := range strs {
+				k.SetStringValue(name, value)
+			}
+			for name, value := range dwords {
+				k.SetDWordValue(name, value)
+			}
+			k.Close()
+		}
+	}
+
+	publish()
+	ticker := time.NewTicker(time.Duration(config.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			publish()
+		case <-ctx.Done():
+			// 退出时标记监控已停止，避免旧工具读到过期的运行状态
+			base.SetDWordValue("MonitorPID", 0)
+			base.SetStringValue("UpdatedAt", time.Now().Format(time.RFC3339))
+			return
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+// reloadSignals returns nil: Windows has no reload signal, use reload_interval
+func reloadSignals() <-chan os.Signal {
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// resource_action 取值
+const (
+	ResourceActionRestart = "restart" // 超过阈值持续 sustained_seconds 后重启（默认）
+	ResourceActionAlert   = "alert"   // 只发出 resource_limit_exceeded 事件
+)
+
+// resourceWatch tracks how long a process has stayed above max_cpu_percent
+// or max_memory_mb
+type resourceWatch struct {
+	maxCPU    float64
+	maxMemory float64
+	sustained time.Duration
+
+	proc       *process.Process
+	cpuAbove   time.Time
+	memAbove   time.Time
+	alerted    bool    // alert 模式下已经告警，回落到阈值以下之前不再重复
+	lastCPU    float64 // 最近一次采样，用于事件详情
+	lastMemory float64
+}
+
+// newResourceWatch returns nil when no resource threshold is configured
+func newResourceWatch(config ProcessConfig) *resourceWatch {
+	if config.MaxCPUPercent <= 0 && config.MaxMemoryMB <= 0 {
+		return nil
+	}
+	// 在容器中按 cgroup 限制调整阈值
+	container := detectContainer()
+	w := &resourceWatch{
+		sustained: time.Duration(defaultInt(config.SustainedSeconds, 60)) * time.Second,
+	}
+	if config.MaxCPUPercent > 0 {
+		w.maxCPU = container.adjustCPUThreshold(config.MaxCPUPercent)
+	}
+	if config.MaxMemoryMB > 0 {
+		w.maxMemory = container.adjustMemoryThreshold(config.MaxMemoryMB)
+	}
+	return w
+}
+
+// check samples pid and returns a reason once a threshold has been exceeded
+// for the sustained period
+func (w *resourceWatch) check(pid int32) string {
+	if w.proc == nil || w.proc.Pid != pid {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			logrus.Debugf("Resource check: cannot open PID %d: %v", pid, err)
+			return ""
+		}
+		w.proc = p
+		w.reset()
+		// 第一次调用只建立CPU基准
+		w.proc.Percent(0)
+		return ""
+	}
+
+	var cpu, memory float64
+	if w.maxCPU > 0 {
+		percent, err := w.proc.Percent(0)
+		if err != nil {
+			logrus.Debugf("Resource check: failed to read CPU of PID %d: %v", pid, err)
+			return ""
+		}
+		cpu = percent
+	}
+	if w.maxMemory > 0 {
+		mem, err := w.proc.MemoryInfo()
+		if err != nil {
+			logrus.Debugf("Resource check: failed to read memory of PID %d: %v", pid, err)
+			return ""
+		}
+		memory = float64(mem.RSS) / 1024 / 1024
+	}
+	return w.observe(cpu, memory, time.Now())
+}
+
+// observe records one sample and returns the exceeded limit, if any
+func (w *resourceWatch) observe(cpu, memory float64, now time.Time) string {
+	w.lastCPU, w.lastMemory = cpu, memory
+
+	cpuHigh := w.maxCPU > 0 && cpu > w.maxCPU
+	memHigh := w.maxMemory > 0 && memory > w.maxMemory
+	if !cpuHigh {
+		w.cpuAbove = time.Time{}
+	} else if w.cpuAbove.IsZero() {
+		w.cpuAbove = now
+	}
+	if !memHigh {
+		w.memAbove = time.Time{}
+	} else if w.memAbove.IsZero() {
+		w.memAbove = now
+	}
+	if !cpuHigh && !memHigh {
+		w.alerted = false
+		return ""
+	}
+
+	if memHigh && now.Sub(w.memAbove) >= w.sustained {
+		return fmt.Sprintf("memory %.0fMB above max_memory_mb %.0fMB for %v", memory, w.maxMemory, now.Sub(w.memAbove).Round(time.Second))
+	}
+	if cpuHigh && now.Sub(w.cpuAbove) >= w.sustained {
+		return fmt.Sprintf("CPU %.1f%% above max_cpu_percent %.1f%% for %v", cpu, w.maxCPU, now.Sub(w.cpuAbove).Round(time.Second))
+	}
+	return ""
+}
+
+// reset forgets the time spent above the thresholds (new PID, restart)
+func (w *resourceWatch) reset() {
+	w.cpuAbove = time.Time{}
+	w.memAbove = time.Time{}
+	w.alerted = false
+}
+
+// checkResources applies max_cpu_percent / max_memory_mb to pid and
+// returns true when the process has to be restarted
+func (s *ProcessSupervisor) checkResources(pid int32) (bool, string) {
+	reason := s.resources.check(pid)
+	if reason == "" {
+		return false, ""
+	}
+	restart := s.config.ResourceAction != ResourceActionAlert
+	if !restart && s.resources.alerted {
+		return false, ""
+	}
+	s.resources.alerted = true
+
+	action := "restarting"
+	if !restart {
+		action = "alert only"
+	}
+	emitCount("resource_limit.exceeded", 1, processTags(s.config.Name))
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "resource_limit_exceeded",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Process %s exceeded its resource limit: %s (%s)", s.config.Name, reason, action),
+		Details: map[string]string{
+			"reason":      reason,
+			"cpu_percent": fmt.Sprintf("%.1f", s.resources.lastCPU),
+			"memory_mb":   fmt.Sprintf("%.0f", s.resources.lastMemory),
+			"action":      s.config.ResourceAction,
+		},
+	})
+	if restart {
+		s.resources.reset()
+	}
+	return restart, reason
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceWatchSustained(t *testing.T) {
+	w := &resourceWatch{maxCPU: 80, maxMemory: 500, sustained: time.Minute}
+	base := time.Now()
+	steps := []struct {
+		offset time.Duration
+		cpu    float64
+		memory float64
+		reason string // 期望原因中包含的文字，为空表示未超限
+	}{
+		{0, 95, 100, ""},
+		{30 * time.Second, 95, 100, ""},
+		{45 * time.Second, 10, 100, ""}, // 回落，重新计时
+		{50 * time.Second, 95, 100, ""},
+		{111 * time.Second, 95, 100, "CPU 95.0% above max_cpu_percent"},
+		{120 * time.Second, 10, 600, ""},
+		{181 * time.Second, 10, 650, "memory 650MB above max_memory_mb 500MB"},
+	}
+	for i, step := range steps {
+		got := w.observe(step.cpu, step.memory, base.Add(step.offset))
+		if step.reason == "" && got != "" || step.reason != "" && !strings.Contains(got, step.reason) {
+			t.Errorf("step %d: reason = %q, want %q", i, got, step.reason)
+		}
+	}
+}
+
+func TestNewResourceWatchDisabled(t *testing.T) {
+	if w := newResourceWatch(ProcessConfig{Name: "a"}); w != nil {
+		t.Error("no thresholds configured, watch should be nil")
+	}
+	w := newResourceWatch(ProcessConfig{Name: "a", MaxMemoryMB: 100})
+	if w == nil || w.sustained != 60*time.Second || w.maxCPU != 0 {
+		t.Errorf("unexpected watch: %+v", w)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// releaseFileLocks is a no-op outside Windows: a running executable can be
+// replaced by rename, so other processes never block the swap.
+func releaseFileLocks(paths []string) (func(), error) {
+	return func() {}, nil
+}
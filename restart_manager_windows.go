@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	rstrtmgr                = windows.NewLazySystemDLL("rstrtmgr.dll")
+	procRmStartSession      = rstrtmgr.NewProc("RmStartSession")
+	procRmRegisterResources = rstrtmgr.NewProc("RmRegisterResources")
+	procRmGetList           = rstrtmgr.NewProc("RmGetList")
+	procRmShutdown          = rstrtmgr.NewProc("RmShutdown")
+	procRmRestart           = rstrtmgr.NewProc("RmRestart")
+	procRmEndSession        = rstrtmgr.NewProc("RmEndSession")
+)
+
+const (
+	rmSessionKeyLen  = 32 // CCH_RM_SESSION_KEY
+	rmForceShutdown  = 0x1
+	errorMoreData    = 234
+	rmMaxAppNameLen  = 255
+	rmMaxSvcNameLen  = 63
+	rmMaxListRetries = 3
+)
+
+// rmProcessInfo mirrors RM_PROCESS_INFO
+type rmProcessInfo struct {
+	ProcessID        uint32
+	StartTime        windows.Filetime
+	AppName          [rmMaxAppNameLen + 1]uint16
+	ServiceShortName [rmMaxSvcNameLen + 1]uint16
+	ApplicationType  uint32
+	AppStatus        uint32
+	TSSessionID      uint32
+	Restartable      int32
+}
+
+func rmError(op string, r uintptr) error {
+	return fmt.Errorf("%s failed: %v", op, windows.Errno(r))
+}
+
+// releaseFileLocks uses the Restart Manager to close every process that
+// holds one of paths open. Applications are asked to close first (the same
+// way an installer does) and are only forced when they refuse. The returned
+// function restarts the applications that registered for restart and ends
+// the session; it must always be called.
+func releaseFileLocks(paths []string) (func(), error) {
+	noop := func() {}
+	if err := rstrtmgr.Load(); err != nil {
+		return noop, fmt.Errorf("restart manager not available: %v", err)
+	}
+
+	var session uint32
+	key := make([]uint16, rmSessionKeyLen+1)
+	if r, _, _ := procRmStartSession.Call(uintptr(unsafe.Pointer(&session)), 0, uintptr(unsafe.Pointer(&key[0]))); r != 0 {
+		return noop, rmError("RmStartSession", r)
+	}
+	end := func() { procRmEndSession.Call(uintptr(session)) }
+
+	files := make([]*uint16, 0, len(paths))
+	for _, p := range paths {
+		ptr, err := windows.UTF16PtrFromString(p)
+		if err != nil {
+			end()
+			return noop, err
+		}
+		files = append(files, ptr)
+	}
+	if r, _, _ := procRmRegisterResources.Call(uintptr(session),
+		uintptr(len(files)), uintptr(unsafe.Pointer(&files[0])), 0, 0, 0, 0); r != 0 {
+		end()
+		return noop, rmError("RmRegisterResources", r)
+	}
+
+	lockers, err := rmList(session)
+	if err != nil {
+		end()
+		return noop, err
+	}
+	if len(lockers) == 0 {
+		end()
+		return noop, nil
+	}
+	for _, p := range lockers {
+		logrus.Warnf("Closing %s (PID: %d) which holds %v open", windows.UTF16ToString(p.AppName[:]), p.ProcessID, paths)
+	}
+
+	if r, _, _ := procRmShutdown.Call(uintptr(session), 0, 0); r != 0 {
+		logrus.Warnf("Restart Manager could not close all applications gracefully (%v), forcing shutdown", windows.Errno(r))
+		if r, _, _ := procRmShutdown.Call(uintptr(session), rmForceShutdown, 0); r != 0 {
+			end()
+			return noop, rmError("RmShutdown", r)
+		}
+	}
+
+	return func() {
+		if r, _, _ := procRmRestart.Call(uintptr(session), 0, 0); r != 0 {
+			logrus.Warnf("Restart Manager failed to restart closed applications: %v", windows.Errno(r))
+		}
+		end()
+	}, nil
+}
+
+// rmList returns the processes using the resources registered in session
+func rmList(session uint32) ([]rmProcessInfo, error) {
+	var needed, count, reasons uint32
+	var infos []rmProcessInfo
+	for i := 0; i < rmMaxListRetries; i++ {
+		var ptr *rmProcessInfo
+		if len(infos) > 0 {
+			ptr = &infos[0]
+		}
+		count = uint32(len(infos))
+		r, _, _ := procRmGetList.Call(uintptr(session),
+			uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&count)),
+			uintptr(unsafe.Pointer(ptr)), uintptr(unsafe.Pointer(&reasons)))
+		switch r {
+		case 0:
+			return infos[:count], nil
+		case errorMoreData:
+			// 两次调用之间可能又有进程打开了文件，按新的数量重试
+			infos = make([]rmProcessInfo, needed)
+		default:
+			return nil, rmError("RmGetList", r)
+		}
+	}
+	return nil, fmt.Errorf("RmGetList: list of applications kept changing")
+}
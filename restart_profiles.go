@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 自动重启的失败类型，用于 restart_profiles 的 on
+const (
+	FailureExit        = "exit"         // 进程退出、被关闭或未运行
+	FailureHealthCheck = "health_check" // 端口或健康检查失败
+	FailureResource    = "resource"     // CPU或内存持续超过上限
+	FailureProtocol    = "protocol"     // 监督协议报告不健康或心跳超时
+	FailureLogTrigger  = "log_trigger"  // 日志触发器要求重启
+	FailureWindow      = "window"       // 主窗口无响应
+	FailureCrashLoop   = "crash_loop"   // 检测到崩溃循环（优先于上面的类型）
+)
+
+var failureTypes = map[string]bool{
+	FailureExit: true, FailureHealthCheck: true, FailureResource: true, FailureProtocol: true,
+	FailureLogTrigger: true, FailureWindow: true, FailureCrashLoop: true,
+}
+
+// defaultProfile 手动重启时选择不使用任何重启配置
+const defaultProfile = "default"
+
+// RestartProfile 一组替代的启动命令、参数和钩子，按失败类型自动选用或手动重启时指定
+type RestartProfile struct {
+	Name           string            `yaml:"name"`            // 配置名，手动重启时通过 profile 指定
+	On             []string          `yaml:"on"`              // 自动选用的失败类型：exit、health_check、resource、protocol、log_trigger、window、crash_loop
+	RestartCommand string            `yaml:"restart_command"` // 启动的程序（为空则与进程相同）
+	Args           []string          `yaml:"args"`            // 启动参数（为空则与进程相同）
+	WorkDir        string            `yaml:"work_dir"`        // 工作目录（为空则与进程相同）
+	Env            map[string]string `yaml:"env"`             // 附加的环境变量，如切换到备用数据源
+	BeforeStart    string            `yaml:"before_start"`    // 停止旧实例后、启动前执行的命令
+	AfterStart     string            `yaml:"after_start"`     // 启动成功后执行的命令
+	Sticky         bool              `yaml:"sticky"`          // 之后的自动重启继续使用此配置，直到手动重启指定其他配置
+}
+
+// validateRestartProfiles checks the restart profiles of a process
+func validateRestartProfiles(profiles []RestartProfile) []error {
+	var errs []error
+	names := make(map[string]bool)
+	on := make(map[string]string)
+	for _, p := range profiles {
+		switch {
+		case p.Name == "":
+			errs = append(errs, fmt.Errorf("restart profile without name"))
+			continue
+		case p.Name == defaultProfile:
+			errs = append(errs, fmt.Errorf("restart profile name %q is reserved", defaultProfile))
+		case names[p.Name]:
+			errs = append(errs, fmt.Errorf("duplicate restart profile %s", p.Name))
+		}
+		names[p.Name] = true
+		for _, failure := range p.On {
+			if !failureTypes[failure] {
+				errs = append(errs, fmt.Errorf("restart profile %s: unknown failure type %q", p.Name, failure))
+			} else if other, ok := on[failure]; ok {
+				errs = append(errs, fmt.Errorf("restart profiles %s and %s are both selected on %s", other, p.Name, failure))
+			} else {
+				on[failure] = p.Name
+			}
+		}
+	}
+	return errs
+}
+
+// findRestartProfile returns the profile called name
+func findRestartProfile(config ProcessConfig, name string) (*RestartProfile, error) {
+	for i := range config.RestartProfiles {
+		if config.RestartProfiles[i].Name == name {
+			return &config.RestartProfiles[i], nil
+		}
+	}
+	names := make([]string, 0, len(config.RestartProfiles))
+	for _, p := range config.RestartProfiles {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("process %s has no restart profile %q (profiles: %v)", config.Name, name, names)
+}
+
+// profileFor returns the profile selected automatically for failure
+func profileFor(config ProcessConfig, failure string) *RestartProfile {
+	for i, p := range config.RestartProfiles {
+		for _, on := range p.On {
+			if on == failure {
+				return &config.RestartProfiles[i]
+			}
+		}
+	}
+	return nil
+}
+
+// selectProfile chooses the profile of the next start: the requested one
+// ("default" for none), the one selected by the failure type, or the
+// current sticky profile
+func (s *ProcessSupervisor) selectProfile(requested, failure string) error {
+	var profile *RestartProfile
+	switch {
+	case requested == defaultProfile:
+	case requested != "":
+		p, err := findRestartProfile(s.config, requested)
+		if err != nil {
+			return err
+		}
+		profile = p
+	case failure != "" && profileFor(s.config, failure) != nil:
+		profile = profileFor(s.config, failure)
+	case s.profile != nil && s.profile.Sticky:
+		profile = s.profile
+	}
+
+	s.profile = profile
+	name := ""
+	if profile != nil {
+		name = profile.Name
+		logrus.Infof("Process %s will be started with restart profile %s", s.config.Name, name)
+	}
+	s.updateStatus(func(st *ProcessStatus) { st.RestartProfile = name })
+	return nil
+}
+
+// startConfig returns the config used to start the process: the process
+// config with the current restart profile applied
+func (s *ProcessSupervisor) startConfig() ProcessConfig {
+	config := s.config
+	p := s.profile
+	if p == nil {
+		return config
+	}
+	if p.RestartCommand != "" {
+		config.RestartCommand = p.RestartCommand
+	}
+	if p.Args != nil {
+		config.Args = p.Args
+	}
+	if p.WorkDir != "" {
+		config.WorkDir = p.WorkDir
+	}
+	return config
+}
+
+// profileEnv returns the environment variables of the current profile
+func (s *ProcessSupervisor) profileEnv() []string {
+	if s.profile == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(s.profile.Env))
+	for k := range s.profile.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+s.profile.Env[k])
+	}
+	return env
+}
+
+// runProfileHook runs the before_start or after_start command of the
+// current profile; a failing hook is logged and does not stop the restart
+func (s *ProcessSupervisor) runProfileHook(event, reason string) {
+	if s.profile == nil {
+		return
+	}
+	command := s.profile.BeforeStart
+	if event == "after_start" {
+		command = s.profile.AfterStart
+	}
+	if command == "" {
+		return
+	}
+	if err := runHook(s.startConfig(), event, command, reason, s.Status().PID); err != nil {
+		logrus.Errorf("Process %s: restart profile %s: %v", s.config.Name, s.profile.Name, err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectProfile(t *testing.T) {
+	s := NewProcessSupervisor(ProcessConfig{
+		Name: "app",
+		Args: []string{"-db", "primary"},
+		RestartProfiles: []RestartProfile{
+			{Name: "warm", On: []string{FailureHealthCheck}, Args: []string{"-db", "primary", "-warm"}},
+			{Name: "failover", On: []string{FailureCrashLoop}, Env: map[string]string{"DB_HOST": "standby"}, Sticky: true},
+		},
+	})
+
+	// 每一步：手动指定的配置、失败类型和应选用的配置
+	steps := []struct {
+		requested string
+		failure   string
+		want      string
+	}{
+		{"", FailureExit, ""},
+		{"", FailureHealthCheck, "warm"},
+		{"", FailureExit, ""}, // warm 不是 sticky
+		{"", FailureCrashLoop, "failover"},
+		{"", FailureExit, "failover"}, // sticky 保持
+		{"", FailureHealthCheck, "warm"},
+		{"failover", "", "failover"},
+		{"", "", "failover"}, // 手动重启不指定配置时保留 sticky 配置
+		{defaultProfile, "", ""},
+	}
+	for i, step := range steps {
+		if err := s.selectProfile(step.requested, step.failure); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got := s.Status().RestartProfile; got != step.want {
+			t.Errorf("step %d: profile = %q, want %q", i, got, step.want)
+		}
+	}
+
+	if err := s.selectProfile("missing", ""); err == nil {
+		t.Error("unknown profile was accepted")
+	}
+}
+
+func TestStartConfigWithProfile(t *testing.T) {
+	s := NewProcessSupervisor(ProcessConfig{
+		Name:    "app",
+		Args:    []string{"-db", "primary"},
+		WorkDir: "/srv/app",
+		RestartProfiles: []RestartProfile{
+			{Name: "failover", RestartCommand: "/srv/app/start-standby.sh", Env: map[string]string{"DB_PORT": "5433", "DB_HOST": "standby"}},
+		},
+	})
+	if err := s.selectProfile("failover", ""); err != nil {
+		t.Fatal(err)
+	}
+	config := s.startConfig()
+	if config.RestartCommand != "/srv/app/start-standby.sh" || config.WorkDir != "/srv/app" || !reflect.DeepEqual(config.Args, []string{"-db", "primary"}) {
+		t.Errorf("start config = %+v", config)
+	}
+	if env := s.profileEnv(); !reflect.DeepEqual(env, []string{"DB_HOST=standby", "DB_PORT=5433"}) {
+		t.Errorf("env = %v", env)
+	}
+}
+
+func TestValidateRestartProfiles(t *testing.T) {
+	tests := []struct {
+		profiles []RestartProfile
+		errs     int
+	}{
+		{[]RestartProfile{{Name: "warm", On: []string{FailureHealthCheck}}, {Name: "failover", On: []string{FailureCrashLoop}}}, 0},
+		{[]RestartProfile{{Name: "warm"}, {Name: "warm"}}, 1},
+		{[]RestartProfile{{Name: defaultProfile}}, 1},
+		{[]RestartProfile{{Name: "a", On: []string{"oom"}}}, 1},
+		{[]RestartProfile{{Name: "a", On: []string{FailureExit}}, {Name: "b", On: []string{FailureExit}}}, 1},
+		{[]RestartProfile{{On: []string{FailureExit}}}, 1},
+	}
+	for i, tt := range tests {
+		if errs := validateRestartProfiles(tt.profiles); len(errs) != tt.errs {
+			t.Errorf("case %d: errors = %v, want %d", i, errs, tt.errs)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/sirupsen/logrus"
+)
+
+// SafeModeConfig 安全模式：主配置无效或主机资源严重不足时，只监控关键进程
+type SafeModeConfig struct {
+	Config        string  `yaml:"config"`         // 安全模式配置文件（只包含关键进程）
+	CPUPercent    float64 `yaml:"cpu_percent"`    // 主机CPU使用率持续高于该值时进入安全模式（0表示不检测）
+	MemoryPercent float64 `yaml:"memory_percent"` // 主机内存使用率持续高于该值时进入安全模式（0表示不检测）
+	Duration      int     `yaml:"duration"`       // 资源压力持续多久后进入安全模式（秒，默认60）
+	RecoverAfter  int     `yaml:"recover_after"`  // 资源压力消除多久后恢复主配置（秒，默认300）
+	CheckInterval int     `yaml:"check_interval"` // 资源检查间隔（秒，默认10）
+}
+
+var (
+	safeModeMu     sync.RWMutex
+	safeModeReason string // 非空表示正在使用安全模式配置
+)
+
+// currentSafeMode returns why the monitor runs the safe mode config, or "" when it does not
+func currentSafeMode() string {
+	safeModeMu.RLock()
+	defer safeModeMu.RUnlock()
+	return safeModeReason
+}
+
+func setSafeMode(reason string) {
+	safeModeMu.Lock()
+	defer safeModeMu.Unlock()
+	safeModeReason = reason
+}
+
+// loadValidConfig loads, normalizes and validates a config file
+func loadValidConfig(path string) (Config, error) {
+	config, err := loadConfig(path)
+	if err != nil {
+		return config, err
+	}
+	applyConfigDefaults(&config)
+	if err := validateConfig(config); err != nil {
+		return config, err
+	}
+	// 重新加载的配置同样按启动时检测到的平台能力降级
+	for _, note := range degradeConfig(&config, hostCapabilities) {
+		logrus.Debugf("Config %s: %s", path, note)
+	}
+	return config, nil
+}
+
+// configController reloads the primary config and decides whether the
+// processes of the primary or of the safe mode config are supervised
+type configController struct {
+	path    string
+	manager *ProcessManager
+
+	primary    Config // 最近一次通过校验的主配置
+	primaryErr error  // 主配置当前的问题，nil 表示有效
+	modTime    time.Time
+	pending    bool // 主配置已更新但尚未应用
+
+	safe     *Config // nil 表示没有可用的安全模式配置
+	settings SafeModeConfig
+
+	pressure   string // 非空表示主机处于资源压力下
+	highSince  time.Time
+	lowSince   time.Time
+	activeSafe bool
+
+	reloadRequests chan chan error // 控制通道请求的重新加载，回复校验结果
+}
+
+// newConfigController prepares the controller for the primary config at
+// path. primaryErr is the validation error of primary at startup, if any.
+func newConfigController(path string, primary Config, primaryErr error, manager *ProcessManager) *configController {
+	c := &configController{path: path, manager: manager, primary: primary, primaryErr: primaryErr, reloadRequests: make(chan chan error)}
+	if info, err := os.Stat(path); err == nil {
+		c.modTime = info.ModTime()
+	}
+	c.loadSafeConfig(primary.SafeMode)
+	return c
+}
+
+func (c *configController) loadSafeConfig(settings SafeModeConfig) {
+	c.settings = settings
+	if settings.Config == "" {
+		c.safe = nil
+		return
+	}
+	safe, err := loadValidConfig(settings.Config)
+	if err != nil {
+		// 保留之前可用的安全模式配置
+		logrus.Errorf("Safe mode config %s is not usable: %v", settings.Config, err)
+		return
+	}
+	c.safe = &safe
+}
+
+// initialConfig returns the config the manager should start with
+func (c *configController) initialConfig() (Config, error) {
+	if c.primaryErr == nil {
+		return c.primary, nil
+	}
+	if c.safe == nil {
+		return c.primary, c.primaryErr
+	}
+	c.activeSafe = true
+	c.enterSafeMode(fmt.Sprintf("primary config invalid: %v", c.primaryErr))
+	return *c.safe, nil
+}
+
+// Run watches the config file and host resources until ctx is cancelled
+func (c *configController) Run(ctx context.Context, reloadInterval int, reloadSignals <-chan os.Signal) {
+	var reloadTick, pressureTick <-chan time.Time
+	if reloadInterval > 0 {
+		ticker := time.NewTicker(time.Duration(reloadInterval) * time.Second)
+		defer ticker.Stop()
+		reloadTick = ticker.C
+	}
+	if c.settings.CPUPercent > 0 || c.settings.MemoryPercent > 0 {
+		ticker := time.NewTicker(time.Duration(defaultInt(c.settings.CheckInterval, 10)) * time.Second)
+		defer ticker.Stop()
+		pressureTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-reloadTick:
+			if info, err := os.Stat(c.path); err == nil && !info.ModTime().Equal(c.modTime) {
+				c.reload()
+			}
+		case <-reloadSignals:
+			logrus.Infof("Received reload signal")
+			c.reload()
+		case reply := <-c.reloadRequests:
+			logrus.Infof("Reload requested over the control channel")
+			err := c.reload()
+			c.apply(ctx)
+			reply <- err
+			continue
+		case <-pressureTick:
+			c.checkPressure(ctx)
+		case <-ctx.Done():
+			return
+		}
+		c.apply(ctx)
+	}
+}
+
+// reload re-reads and validates the primary config file
+func (c *configController) reload() error {
+	if info, err := os.Stat(c.path); err == nil {
+		c.modTime = info.ModTime()
+	}
+	config, err := loadValidConfig(c.path)
+	if err != nil {
+		c.primaryErr = err
+		emitEvent(Event{
+			Severity: SeverityCritical,
+			Type:     "config_invalid",
+			Message:  fmt.Sprintf("Reloaded config %s is invalid: %v", c.path, err),
+		})
+		return err
+	}
+	logrus.Infof("Reloaded config from %s (%d processes)", c.path, len(config.Processes))
+	c.primary = config
+	c.primaryErr = nil
+	c.pending = true
+	c.loadSafeConfig(config.SafeMode)
+	return nil
+}
+
+// requestReload asks Run to reload the config now and waits for the result
+func (c *configController) requestReload(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case c.reloadRequests <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// checkPressure samples host CPU and memory usage and updates c.pressure
+// once the thresholds have been exceeded (or cleared) long enough
+func (c *configController) checkPressure(ctx context.Context) {
+	var over []string
+	if c.settings.CPUPercent > 0 {
+		if percents, err := cpu.PercentWithContext(ctx, 0, false); err == nil && len(percents) > 0 && percents[0] >= c.settings.CPUPercent {
+			over = append(over, fmt.Sprintf("cpu %.0f%%", percents[0]))
+		}
+	}
+	if c.settings.MemoryPercent > 0 {
+		if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil && vm.UsedPercent >= c.settings.MemoryPercent {
+			over = append(over, fmt.Sprintf("memory %.0f%%", vm.UsedPercent))
+		}
+	}
+
+	now := time.Now()
+	if len(over) > 0 {
+		c.lowSince = time.Time{}
+		if c.highSince.IsZero() {
+			c.highSince = now
+		}
+		if c.pressure == "" && now.Sub(c.highSince) >= time.Duration(defaultInt(c.settings.Duration, 60))*time.Second {
+			c.pressure = fmt.Sprintf("host under resource pressure: %v", over)
+		}
+		return
+	}
+	c.highSince = time.Time{}
+	if c.pressure == "" {
+		return
+	}
+	if c.lowSince.IsZero() {
+		c.lowSince = now
+	}
+	if now.Sub(c.lowSince) >= time.Duration(defaultInt(c.settings.RecoverAfter, 300))*time.Second {
+		logrus.Infof("Host resource pressure is over")
+		c.pressure = ""
+	}
+}
+
+// apply switches the supervised processes when the desired config changed
+func (c *configController) apply(ctx context.Context) {
+	reason := ""
+	if c.primaryErr != nil {
+		reason = fmt.Sprintf("primary config invalid: %v", c.primaryErr)
+	} else if c.pressure != "" {
+		reason = c.pressure
+	}
+
+	if reason != "" {
+		if c.safe == nil {
+			if c.primaryErr != nil && !c.activeSafe {
+				logrus.Warnf("No safe mode config available, keeping the current processes")
+			}
+			return
+		}
+		if !c.activeSafe {
+			c.activeSafe = true
+			c.enterSafeMode(reason)
+			c.manager.Replace(ctx, *c.safe)
+		}
+		return
+	}
+
+	if c.activeSafe {
+		c.activeSafe = false
+		c.pending = false
+		setSafeMode("")
+		emitEvent(Event{
+			Severity: SeverityInfo,
+			Type:     "safe_mode_exited",
+			Message:  "Primary config restored, leaving safe mode",
+		})
+		c.manager.Replace(ctx, c.primary)
+		return
+	}
+	if c.pending {
+		c.pending = false
+		c.manager.Replace(ctx, c.primary)
+	}
+}
+
+func (c *configController) enterSafeMode(reason string) {
+	setSafeMode(reason)
+	emitEvent(Event{
+		Severity: SeverityCritical,
+		Type:     "safe_mode_entered",
+		Message:  fmt.Sprintf("Switching to safe mode config %s: %s", c.settings.Config, reason),
+		Details:  map[string]string{"reason": reason, "config": c.settings.Config},
+	})
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StateOffSchedule 当前不在运行时间段内（或是节假日），进程已停止，时间段开始时自动启动
+const StateOffSchedule = "off_schedule"
+
+const (
+	calendarDateLayout  = "2006-01-02"
+	defaultScheduleDays = 7
+	maxScheduleDays     = 366
+)
+
+// ScheduleConfig 限制进程的运行时间，如只在交易时段运行、节假日不运行
+type ScheduleConfig struct {
+	RunDuring []TimeWindow `yaml:"run_during"` // 只在这些时间段内运行，之外停止进程（为空表示任何时间）
+	Holidays  string       `yaml:"holidays"`   // 节假日日历文件：列出的日期不运行或按特殊时间段运行
+}
+
+// enabled reports whether the process has a schedule at all
+func (c ScheduleConfig) enabled() bool {
+	return len(c.RunDuring) > 0 || c.Holidays != ""
+}
+
+// validate checks the run windows and that the holiday calendar can be read
+func (c ScheduleConfig) validate() error {
+	for _, w := range c.RunDuring {
+		if _, err := w.Contains(time.Now()); err != nil {
+			return fmt.Errorf("schedule.run_during: %v", err)
+		}
+		if _, err := w.matchesDay(time.Sunday); err != nil {
+			return fmt.Errorf("schedule.run_during: %v", err)
+		}
+	}
+	if c.Holidays != "" {
+		if _, err := loadHolidayCalendar(c.Holidays); err != nil {
+			return fmt.Errorf("schedule.holidays: %v", err)
+		}
+	}
+	return nil
+}
+
+// calendar returns the holiday calendar of the schedule; a calendar that
+// cannot be read counts as empty, so the run windows still apply
+func (c ScheduleConfig) calendar() *holidayCalendar {
+	if c.Holidays == "" {
+		return nil
+	}
+	cal, err := loadHolidayCalendar(c.Holidays)
+	if err != nil {
+		return nil
+	}
+	return cal
+}
+
+// allowed reports whether the process may run at now, and if not, why
+func (c ScheduleConfig) allowed(now time.Time) (bool, string) {
+	cal := c.calendar()
+	today, exception := cal.lookup(now)
+	if exception && len(today.Windows) > 0 {
+		for _, w := range today.Windows {
+			if in, _ := w.Contains(now); in {
+				return true, ""
+			}
+		}
+	}
+
+	if len(c.RunDuring) == 0 {
+		if exception {
+			return false, today.describe(now)
+		}
+		return true, ""
+	}
+	holiday := ""
+	for _, w := range c.RunDuring {
+		opened, in, err := w.occurrence(now)
+		if err != nil {
+			return false, "invalid run_during window: " + err.Error()
+		}
+		if !in {
+			continue
+		}
+		// 窗口按开始的日期判断是否为节假日，跨午夜的后半段属于前一天
+		if day, ok := cal.lookup(opened); ok {
+			holiday = day.describe(opened)
+			continue
+		}
+		return true, ""
+	}
+	if holiday != "" {
+		return false, holiday
+	}
+	if exception {
+		return false, today.describe(now)
+	}
+	return false, "outside run_during windows"
+}
+
+// ScheduleWindow is one period in which a scheduled process runs
+type ScheduleWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ScheduleDay is the effective schedule of one date
+type ScheduleDay struct {
+	Date    string           `json:"date"`
+	Weekday string           `json:"weekday"`
+	Holiday string           `json:"holiday,omitempty"` // 节假日名称，日历中没有名称时为 "holiday"
+	Windows []ScheduleWindow `json:"windows"`           // 当天开始的运行时间段，为空表示全天不运行
+}
+
+// ScheduleResponse is returned by GET /processes/{name}/schedule
+type ScheduleResponse struct {
+	Process        string        `json:"process"`
+	Scheduled      bool          `json:"scheduled"` // 是否配置了 schedule，未配置时任何时间都运行
+	RunningAllowed bool          `json:"running_allowed"`
+	Reason         string        `json:"reason,omitempty"`
+	Days           []ScheduleDay `json:"days"`
+}
+
+// effective lists the run windows of n days starting with the date of from,
+// with the holiday calendar applied
+func (c ScheduleConfig) effective(from time.Time, n int) []ScheduleDay {
+	cal := c.calendar()
+	days := make([]ScheduleDay, 0, n)
+	for i := 0; i < n; i++ {
+		date := from.AddDate(0, 0, i)
+		day := ScheduleDay{
+			Date:    date.Format(calendarDateLayout),
+			Weekday: strings.ToLower(date.Weekday().String()[:3]),
+			Windows: []ScheduleWindow{},
+		}
+		if exception, ok := cal.lookup(date); ok {
+			day.Holiday = exception.Name
+			if day.Holiday == "" {
+				day.Holiday = "holiday"
+			}
+			for _, w := range exception.Windows {
+				day.Windows = append(day.Windows, ScheduleWindow{w.Start, w.End})
+			}
+		} else if len(c.RunDuring) == 0 {
+			day.Windows = append(day.Windows, ScheduleWindow{"00:00", "24:00"})
+		} else {
+			for _, w := range c.RunDuring {
+				if ok, _ := w.matchesDay(date.Weekday()); ok {
+					day.Windows = append(day.Windows, ScheduleWindow{w.Start, w.End})
+				}
+			}
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// scheduleResponse describes the schedule of config for the n days from now
+func scheduleResponse(config ProcessConfig, now time.Time, n int) ScheduleResponse {
+	resp := ScheduleResponse{Process: config.Name, Scheduled: config.Schedule.enabled(), RunningAllowed: true}
+	if resp.Scheduled {
+		resp.RunningAllowed, resp.Reason = config.Schedule.allowed(now)
+	}
+	resp.Days = config.Schedule.effective(now, n)
+	return resp
+}
+
+// calendarDay is an exception in the holiday calendar: a day without runs,
+// or with special hours replacing the run windows (e.g. an early close)
+type calendarDay struct {
+	Name    string
+	Windows []TimeWindow
+}
+
+func (d calendarDay) describe(date time.Time) string {
+	name := d.Name
+	if name == "" {
+		name = "holiday"
+	}
+	return fmt.Sprintf("%s on %s", name, date.Format(calendarDateLayout))
+}
+
+// holidayCalendar maps dates (YYYY-MM-DD) to their exception
+type holidayCalendar struct {
+	days map[string]calendarDay
+}
+
+// lookup returns the exception for the date of t, if any. A nil calendar
+// has no exceptions.
+func (c *holidayCalendar) lookup(t time.Time) (calendarDay, bool) {
+	if c == nil {
+		return calendarDay{}, false
+	}
+	day, ok := c.days[t.Format(calendarDateLayout)]
+	return day, ok
+}
+
+// parseHolidayCalendar reads a calendar with one exception per line:
+//
+//	# 注释
+//	2026-01-01                元旦
+//	2026-02-16..2026-02-20    春节
+//	2026-12-24 09:30-12:00    平安夜提前收市
+//
+// A date (or an inclusive range) alone closes the whole day; HH:MM-HH:MM
+// fields after it replace the run windows of that day. The rest is the name.
+func parseHolidayCalendar(r io.Reader) (*holidayCalendar, error) {
+	cal := &holidayCalendar{days: make(map[string]calendarDay)}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		first, last, err := parseDateRange(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		var day calendarDay
+		rest := fields[1:]
+		for len(rest) > 0 && strings.Contains(rest[0], ":") && strings.Contains(rest[0], "-") {
+			w, err := parseSpecialHours(rest[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			day.Windows = append(day.Windows, w)
+			rest = rest[1:]
+		}
+		day.Name = strings.Join(rest, " ")
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			cal.days[d.Format(calendarDateLayout)] = day
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cal, nil
+}
+
+// parseDateRange parses YYYY-MM-DD or YYYY-MM-DD..YYYY-MM-DD
+func parseDateRange(s string) (time.Time, time.Time, error) {
+	from, to, isRange := strings.Cut(s, "..")
+	first, err := time.Parse(calendarDateLayout, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", from)
+	}
+	if !isRange {
+		return first, first, nil
+	}
+	last, err := time.Parse(calendarDateLayout, to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", to)
+	}
+	if last.Before(first) {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range %s ends before it starts", s)
+	}
+	if last.Sub(first) > maxScheduleDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range %s is longer than a year", s)
+	}
+	return first, last, nil
+}
+
+// parseSpecialHours parses HH:MM-HH:MM; special hours may not cross midnight
+func parseSpecialHours(s string) (TimeWindow, error) {
+	start, end, _ := strings.Cut(s, "-")
+	w := TimeWindow{Start: start, End: end}
+	from, err := parseClock(start)
+	if err != nil {
+		return w, err
+	}
+	to, err := parseClock(end)
+	if err != nil {
+		return w, err
+	}
+	if to <= from {
+		return w, fmt.Errorf("special hours %s must end after they start on the same day", s)
+	}
+	return w, nil
+}
+
+type cachedCalendar struct {
+	modTime  time.Time
+	calendar *holidayCalendar
+	err      string // 上次报告的读取错误，相同的错误只记录一次
+}
+
+var (
+	calendarsMu sync.Mutex
+	calendars   = make(map[string]*cachedCalendar)
+)
+
+// loadHolidayCalendar returns the calendar in path, reading it again when
+// the file changes. If a changed file cannot be read the last good version
+// stays in use.
+func loadHolidayCalendar(path string) (*holidayCalendar, error) {
+	calendarsMu.Lock()
+	defer calendarsMu.Unlock()
+	cached := calendars[path]
+
+	cal, modTime, err := readHolidayCalendar(path, cached)
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		if cached.err != err.Error() {
+			cached.err = err.Error()
+			logrus.Errorf("Failed to read holiday calendar %s, keeping the previous version: %v", path, err)
+		}
+		return cached.calendar, nil
+	}
+	if cal == nil {
+		// 文件未修改
+		return cached.calendar, nil
+	}
+	if cached != nil {
+		logrus.Infof("Reloaded holiday calendar %s (%d days)", path, len(cal.days))
+	}
+	calendars[path] = &cachedCalendar{modTime: modTime, calendar: cal}
+	return cal, nil
+}
+
+// readHolidayCalendar parses path unless it has not changed since cached
+// was read, in which case it returns a nil calendar
+func readHolidayCalendar(path string, cached *cachedCalendar) (*holidayCalendar, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if cached != nil && info.ModTime().Equal(cached.modTime) {
+		return nil, info.ModTime(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+	cal, err := parseHolidayCalendar(f)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return cal, info.ModTime(), nil
+}
+
+// checkSchedule stops the process when its schedule does not allow it to
+// run and starts it again when the next window opens. It reports whether
+// the process is outside its schedule, so the other checks are skipped.
+func (s *ProcessSupervisor) checkSchedule() bool {
+	config := s.config
+	if !config.Schedule.enabled() {
+		return false
+	}
+	allowed, reason := config.Schedule.allowed(time.Now())
+	if allowed {
+		if !s.offSchedule {
+			return false
+		}
+		s.offSchedule = false
+		if running, _ := s.adoptRunning(); running {
+			s.updateStatus(func(st *ProcessStatus) { st.State = StateRunning })
+			return true
+		}
+		logrus.Infof("Process %s is inside its schedule again, starting it", config.Name)
+		if s.start(false) == nil {
+			s.emitStarted("schedule window opened")
+		}
+		return true
+	}
+	if s.offSchedule {
+		return true
+	}
+
+	s.offSchedule = true
+	running, _ := isConfigRunning(config)
+	pid := s.Status().PID
+	if running {
+		logrus.Infof("Process %s is outside its schedule (%s), stopping it", config.Name, reason)
+	} else {
+		logrus.Infof("Process %s is outside its schedule (%s), not starting it", config.Name, reason)
+	}
+	s.kill()
+	s.stopStandby()
+	s.closeFirewall()
+	s.updateStatus(func(st *ProcessStatus) {
+		st.State = StateOffSchedule
+		st.PID = 0
+	})
+	if running {
+		emitEvent(Event{
+			Severity: SeverityInfo,
+			Type:     "process_stopped",
+			Process:  config.Name,
+			Message:  fmt.Sprintf("Stopped process %s (PID: %d) outside its schedule", config.Name, pid),
+			Details:  map[string]string{"reason": "outside schedule: " + reason, "pid": strconv.Itoa(pid)},
+		})
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCalendar = `
+# 交易所休市安排
+2024-03-08                 停市
+2024-04-04..2024-04-05     清明节
+2024-03-14 09:30-11:30     提前收市
+`
+
+func writeTestCalendar(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "holidays.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseHolidayCalendar(t *testing.T) {
+	cal, err := parseHolidayCalendar(strings.NewReader(testCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cal.days) != 4 {
+		t.Errorf("calendar has %d days, want 4", len(cal.days))
+	}
+	day, ok := cal.lookup(time.Date(2024, 4, 5, 12, 0, 0, 0, time.Local))
+	if !ok || day.Name != "清明节" || len(day.Windows) != 0 {
+		t.Errorf("2024-04-05 = %+v, %v; want closed 清明节", day, ok)
+	}
+	day, ok = cal.lookup(time.Date(2024, 3, 14, 0, 0, 0, 0, time.Local))
+	if !ok || len(day.Windows) != 1 || day.Windows[0].End != "11:30" {
+		t.Errorf("2024-03-14 = %+v, %v; want special hours until 11:30", day, ok)
+	}
+
+	for _, bad := range []string{
+		"2024-13-01",
+		"2024-04-05..2024-04-04",
+		"2024-03-14 15:00-09:30 overnight",
+		"2024-03-14 9:30-25:00",
+	} {
+		if _, err := parseHolidayCalendar(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseHolidayCalendar(%q) should fail", bad)
+		}
+	}
+}
+
+func TestScheduleAllowed(t *testing.T) {
+	// 2024-03-08 是星期五
+	at := func(month, day, hour, minute int) time.Time {
+		return time.Date(2024, time.Month(month), day, hour, minute, 0, 0, time.Local)
+	}
+	schedule := ScheduleConfig{
+		RunDuring: []TimeWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "15:30"},
+			{Days: []string{"thu"}, Start: "21:00", End: "02:30"},
+		},
+		Holidays: writeTestCalendar(t, testCalendar),
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"trading day", at(3, 7, 10, 0), true},
+		{"after close", at(3, 7, 16, 0), false},
+		{"weekend", at(3, 9, 10, 0), false},
+		{"holiday", at(3, 8, 10, 0), false},
+		// 星期四夜盘跨午夜，后半段属于星期四，星期五是节假日不影响
+		{"night session of the day before a holiday", at(3, 8, 1, 0), true},
+		{"night session opened on a holiday", at(4, 5, 1, 0), false},
+		{"special hours", at(3, 14, 10, 0), true},
+		{"after early close", at(3, 14, 13, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := schedule.allowed(tt.now)
+			if got != tt.want {
+				t.Errorf("allowed(%v) = %v (%s), want %v", tt.now, got, reason, tt.want)
+			}
+		})
+	}
+
+	// 只配置日历：节假日之外任何时间都运行
+	holidaysOnly := ScheduleConfig{Holidays: schedule.Holidays}
+	if ok, _ := holidaysOnly.allowed(at(3, 9, 3, 0)); !ok {
+		t.Error("holidays-only schedule should allow a normal day")
+	}
+	if ok, reason := holidaysOnly.allowed(at(3, 8, 3, 0)); ok || !strings.Contains(reason, "停市") {
+		t.Errorf("holidays-only schedule on a holiday = %v (%s)", ok, reason)
+	}
+}
+
+func TestScheduleEffective(t *testing.T) {
+	schedule := ScheduleConfig{
+		RunDuring: []TimeWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "15:30"}},
+		Holidays:  writeTestCalendar(t, testCalendar),
+	}
+	days := schedule.effective(time.Date(2024, 3, 8, 12, 0, 0, 0, time.Local), 3)
+	if len(days) != 3 {
+		t.Fatalf("got %d days, want 3", len(days))
+	}
+	if d := days[0]; d.Date != "2024-03-08" || d.Weekday != "fri" || d.Holiday != "停市" || len(d.Windows) != 0 {
+		t.Errorf("holiday = %+v", d)
+	}
+	if d := days[1]; d.Holiday != "" || len(d.Windows) != 0 {
+		t.Errorf("saturday = %+v, want no windows", d)
+	}
+	if d := days[2]; d.Weekday != "sun" {
+		t.Errorf("third day = %+v", d)
+	}
+	monday := schedule.effective(time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local), 1)[0]
+	if len(monday.Windows) != 1 || monday.Windows[0] != (ScheduleWindow{"09:00", "15:30"}) {
+		t.Errorf("monday = %+v", monday)
+	}
+}
+
+func TestHolidayCalendarReload(t *testing.T) {
+	path := writeTestCalendar(t, "2024-03-08 停市\n")
+	cal, err := loadHolidayCalendar(path)
+	if err != nil || len(cal.days) != 1 {
+		t.Fatalf("load = %v, %v", cal, err)
+	}
+
+	// 修改后的日历重新读取；读取失败时保留上一个版本
+	os.WriteFile(path, []byte("2024-03-08 停市\n2024-03-11 停市\n"), 0644)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if cal, _ = loadHolidayCalendar(path); len(cal.days) != 2 {
+		t.Errorf("reloaded calendar has %d days, want 2", len(cal.days))
+	}
+	os.WriteFile(path, []byte("not a date\n"), 0644)
+	later := future.Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if cal, err = loadHolidayCalendar(path); err != nil || len(cal.days) != 2 {
+		t.Errorf("broken calendar: %v, %v; want the previous version", cal, err)
+	}
+}
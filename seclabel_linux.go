@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// execSecurityLabel returns the /proc attribute file and value that make the
+// next exec of the calling thread transition into the configured SELinux
+// context or AppArmor profile. An empty path means no label is configured.
+func execSecurityLabel(config ProcessConfig) (string, string, error) {
+	if config.SELinuxContext == "" && config.AppArmorProfile == "" {
+		return "", "", nil
+	}
+	enabled, _ := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	// 新内核为每个 LSM 提供独立的属性目录
+	apparmorAttr := "/proc/thread-self/attr/apparmor/exec"
+	if !fileExists(apparmorAttr) {
+		apparmorAttr = "/proc/thread-self/attr/exec"
+	}
+	return securityLabel(config, fileExists("/sys/fs/selinux/enforce"), strings.TrimSpace(string(enabled)) == "Y", apparmorAttr)
+}
+
+// securityLabel is execSecurityLabel for a host with the given LSMs enabled
+// and AppArmor exec attribute file
+func securityLabel(config ProcessConfig, selinux, apparmor bool, apparmorAttr string) (string, string, error) {
+	switch {
+	case config.SELinuxContext != "" && config.AppArmorProfile != "":
+		return "", "", fmt.Errorf("selinux_context and apparmor_profile cannot both be set")
+	case config.SELinuxContext != "":
+		if !selinux {
+			return "", "", fmt.Errorf("selinux_context is set but SELinux is not enabled on this host")
+		}
+		return "/proc/thread-self/attr/exec", config.SELinuxContext, nil
+	case config.AppArmorProfile != "":
+		if !apparmor {
+			return "", "", fmt.Errorf("apparmor_profile is set but AppArmor is not enabled on this host")
+		}
+		return apparmorAttr, "exec " + config.AppArmorProfile, nil
+	}
+	return "", "", nil
+}
+
+// startWithExecLabel runs start on a dedicated OS thread whose exec attribute
+// has been set, so only the forked child transitions into the label.
+func startWithExecLabel(path, value string, start func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		// 不解锁线程：goroutine 结束时该线程随之退出，标签不会影响其他 goroutine
+		runtime.LockOSThread()
+
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			errc <- fmt.Errorf("failed to open %s: %v", path, err)
+			return
+		}
+		_, err = f.WriteString(value)
+		f.Close()
+		if err != nil {
+			errc <- fmt.Errorf("failed to set exec security label %q: %v", value, err)
+			return
+		}
+		errc <- start()
+	}()
+	return <-errc
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecurityLabel(t *testing.T) {
+	const apparmorAttr = "/proc/thread-self/attr/apparmor/exec"
+	tests := []struct {
+		config              ProcessConfig
+		selinux, apparmor   bool
+		wantPath, wantLabel string
+		wantErr             string
+	}{
+		{ProcessConfig{Name: "plain"}, false, false, "", "", ""},
+		{ProcessConfig{SELinuxContext: "system_u:system_r:httpd_t:s0"}, true, false, "/proc/thread-self/attr/exec", "system_u:system_r:httpd_t:s0", ""},
+		{ProcessConfig{SELinuxContext: "system_u:system_r:httpd_t:s0"}, false, true, "", "", "SELinux is not enabled"},
+		{ProcessConfig{AppArmorProfile: "webapp"}, false, true, apparmorAttr, "exec webapp", ""},
+		{ProcessConfig{AppArmorProfile: "webapp"}, true, false, "", "", "AppArmor is not enabled"},
+		{ProcessConfig{SELinuxContext: "x", AppArmorProfile: "webapp"}, true, true, "", "", "cannot both be set"},
+	}
+	for _, tt := range tests {
+		path, label, err := securityLabel(tt.config, tt.selinux, tt.apparmor, apparmorAttr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%+v: err = %v, want %q", tt.config, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || path != tt.wantPath || label != tt.wantLabel {
+			t.Errorf("%+v: = %q, %q, %v, want %q, %q", tt.config, path, label, err, tt.wantPath, tt.wantLabel)
+		}
+	}
+}
+
+func TestStartWithExecLabel(t *testing.T) {
+	// 用普通文件代替 /proc 属性文件，确认先写入标签再启动
+	attr := filepath.Join(t.TempDir(), "exec")
+	if err := os.WriteFile(attr, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var labelAtStart string
+	err := startWithExecLabel(attr, "exec webapp", func() error {
+		data, _ := os.ReadFile(attr)
+		labelAtStart = string(data)
+		return errors.New("start failed")
+	})
+	if err == nil || err.Error() != "start failed" {
+		t.Errorf("err = %v, want the start error", err)
+	}
+	if labelAtStart != "exec webapp" {
+		t.Errorf("label at start = %q", labelAtStart)
+	}
+
+	started := false
+	err = startWithExecLabel(filepath.Join(t.TempDir(), "missing", "exec"), "exec webapp", func() error {
+		started = true
+		return nil
+	})
+	if err == nil || started {
+		t.Errorf("unwritable attribute: err = %v, started = %v", err, started)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// execSecurityLabel rejects SELinux/AppArmor options outside Linux
+func execSecurityLabel(config ProcessConfig) (string, string, error) {
+	if config.SELinuxContext != "" || config.AppArmorProfile != "" {
+		return "", "", fmt.Errorf("selinux_context and apparmor_profile are only supported on Linux")
+	}
+	return "", "", nil
+}
+
+func startWithExecLabel(path, value string, start func() error) error {
+	return start()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// SelfMonitorConfig 监控程序自身资源占用的配置
+type SelfMonitorConfig struct {
+	CheckInterval int     `yaml:"check_interval"`  // 采样间隔（秒，默认30）
+	MaxCPUPercent float64 `yaml:"max_cpu_percent"` // CPU告警阈值（百分比，默认50）
+	MaxMemoryMB   float64 `yaml:"max_memory_mb"`   // 内存告警阈值（MB，默认500）
+	MaxGoroutines int     `yaml:"max_goroutines"`  // goroutine数量告警阈值（默认1000）
+
+	ErrorDigestInterval int `yaml:"error_digest_interval"` // 内部错误汇总事件（internal_errors）的最短间隔（秒，默认3600，-1表示不发送）
+}
+
+// SelfUsage is a snapshot of the monitor's own resource usage
+type SelfUsage struct {
+	Timestamp  time.Time `json:"timestamp"`
+	CPUPercent float64   `json:"cpu_percent"`
+	MemoryMB   float64   `json:"memory_mb"`
+	Goroutines int       `json:"goroutines"`
+	PeakCPU    float64   `json:"peak_cpu_percent"`
+	PeakMemory float64   `json:"peak_memory_mb"`
+}
+
+var (
+	selfUsageMu sync.RWMutex
+	selfUsage   SelfUsage
+)
+
+// currentSelfUsage returns the latest recorded self-usage snapshot
+func currentSelfUsage() SelfUsage {
+	selfUsageMu.RLock()
+	defer selfUsageMu.RUnlock()
+	return selfUsage
+}
+
+// recordSelfUsage stores usage as the latest snapshot, carrying over and
+// raising the peaks, and returns the stored snapshot
+func recordSelfUsage(usage SelfUsage) SelfUsage {
+	selfUsageMu.Lock()
+	defer selfUsageMu.Unlock()
+	usage.PeakCPU = selfUsage.PeakCPU
+	usage.PeakMemory = selfUsage.PeakMemory
+	if usage.CPUPercent > usage.PeakCPU {
+		usage.PeakCPU = usage.CPUPercent
+	}
+	if usage.MemoryMB > usage.PeakMemory {
+		usage.PeakMemory = usage.MemoryMB
+	}
+	selfUsage = usage
+	return usage
+}
+
+// selfUsageWarnings returns a message for every threshold usage exceeds
+func selfUsageWarnings(config SelfMonitorConfig, usage SelfUsage) []string {
+	var warnings []string
+	if usage.CPUPercent > config.MaxCPUPercent {
+		warnings = append(warnings, fmt.Sprintf("Monitor CPU usage %.1f%% exceeds threshold %.1f%%", usage.CPUPercent, config.MaxCPUPercent))
+	}
+	if usage.MemoryMB > config.MaxMemoryMB {
+		warnings = append(warnings, fmt.Sprintf("Monitor memory usage %.1fMB exceeds threshold %.1fMB", usage.MemoryMB, config.MaxMemoryMB))
+	}
+	if usage.Goroutines > config.MaxGoroutines {
+		warnings = append(warnings, fmt.Sprintf("Monitor goroutine count %d exceeds threshold %d", usage.Goroutines, config.MaxGoroutines))
+	}
+	return warnings
+}
+
+// runSelfMonitor periodically records the monitor's CPU, memory and goroutine
+// counts and warns when any of them exceeds its threshold.
+func runSelfMonitor(config SelfMonitorConfig, ctx context.Context) {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 30
+	}
+	if config.MaxCPUPercent <= 0 {
+		config.MaxCPUPercent = 50
+	}
+	if config.MaxMemoryMB <= 0 {
+		config.MaxMemoryMB = 500
+	}
+	if config.MaxGoroutines <= 0 {
+		config.MaxGoroutines = 1000
+	}
+
+	// 在容器中按 cgroup 限制调整阈值
+	container := detectContainer()
+	config.MaxCPUPercent = container.adjustCPUThreshold(config.MaxCPUPercent)
+	config.MaxMemoryMB = container.adjustMemoryThreshold(config.MaxMemoryMB)
+
+	self, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		logrus.Errorf("Self monitor: failed to open own process: %v", err)
+		return
+	}
+	// 第一次调用只建立CPU基准
+	self.Percent(0)
+
+	ticker := time.NewTicker(time.Duration(config.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			usage := SelfUsage{
+				Timestamp:  time.Now(),
+				Goroutines: runtime.NumGoroutine(),
+			}
+			if cpu, err := self.Percent(0); err == nil {
+				usage.CPUPercent = cpu
+			}
+			if mem, err := self.MemoryInfo(); err == nil {
+				usage.MemoryMB = float64(mem.RSS) / 1024 / 1024
+			}
+
+			usage = recordSelfUsage(usage)
+
+			logrus.Debugf("Self usage - CPU: %.1f%%, Memory: %.1fMB, Goroutines: %d",
+				usage.CPUPercent, usage.MemoryMB, usage.Goroutines)
+
+			for _, warning := range selfUsageWarnings(config, usage) {
+				logrus.Warn(warning)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecordSelfUsagePeaks(t *testing.T) {
+	saved := currentSelfUsage()
+	defer func() {
+		selfUsageMu.Lock()
+		selfUsage = saved
+		selfUsageMu.Unlock()
+	}()
+	selfUsageMu.Lock()
+	selfUsage = SelfUsage{}
+	selfUsageMu.Unlock()
+
+	recordSelfUsage(SelfUsage{CPUPercent: 40, MemoryMB: 100})
+	recordSelfUsage(SelfUsage{CPUPercent: 10, MemoryMB: 300})
+	got := recordSelfUsage(SelfUsage{CPUPercent: 20, MemoryMB: 200, Goroutines: 12})
+	if got.CPUPercent != 20 || got.MemoryMB != 200 || got.PeakCPU != 40 || got.PeakMemory != 300 {
+		t.Errorf("usage = %+v, want current 20%%/200MB with peaks 40%%/300MB", got)
+	}
+	if healthz := buildHealthz(); healthz.Self != got {
+		t.Errorf("/healthz self = %+v, want %+v", healthz.Self, got)
+	}
+}
+
+func TestSelfUsageWarnings(t *testing.T) {
+	config := SelfMonitorConfig{MaxCPUPercent: 50, MaxMemoryMB: 500, MaxGoroutines: 1000}
+	tests := []struct {
+		usage SelfUsage
+		want  []string
+	}{
+		{SelfUsage{CPUPercent: 50, MemoryMB: 500, Goroutines: 1000}, nil},
+		{SelfUsage{CPUPercent: 75}, []string{"CPU usage 75.0%"}},
+		{SelfUsage{MemoryMB: 800, Goroutines: 5000}, []string{"memory usage 800.0MB", "goroutine count 5000"}},
+	}
+	for _, tt := range tests {
+		got := selfUsageWarnings(config, tt.usage)
+		if len(got) != len(tt.want) {
+			t.Errorf("%+v: warnings = %q, want %q", tt.usage, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !strings.Contains(got[i], tt.want[i]) {
+				t.Errorf("%+v: warning %q, want %q", tt.usage, got[i], tt.want[i])
+			}
+		}
+	}
+}
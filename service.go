@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// setAllPaused pauses or resumes the supervision of every process (service pause/continue)
+func setAllPaused(manager *ProcessManager, paused bool) {
+	action := "resume"
+	if paused {
+		action = "pause"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for name := range manager.ConfigsByName() {
+		if err := manager.ProcessAction(ctx, name, action, "service "+action); err != nil {
+			logrus.Errorf("Failed to %s %s: %v", action, name, err)
+		}
+	}
+}
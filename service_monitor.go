@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 服务监控强制的启动类型
+const (
+	ServiceStartAutomatic        = "automatic"
+	ServiceStartDelayedAutomatic = "delayed_automatic"
+	ServiceStartManual           = "manual"
+	ServiceStartDisabled         = "disabled"
+)
+
+const defaultServiceCheckInterval = 30
+
+// ServiceMonitor 监控一个 Windows 服务：停止时启动它，启动类型被修改时改回
+type ServiceMonitor struct {
+	Name          string          `yaml:"name"`           // 服务名（不是显示名），如 "Spooler"
+	CheckInterval int             `yaml:"check_interval"` // 检查间隔（秒，默认30）
+	Restart       bool            `yaml:"restart"`        // 服务停止时启动它
+	RestartDelay  int             `yaml:"restart_delay"`  // 发现停止后等待多久再启动（秒，默认0）
+	StartType     string          `yaml:"start_type"`     // 强制的启动类型：automatic、delayed_automatic、manual、disabled（为空不检查）
+	Enforce       EnforcementGate `yaml:"enforce"`        // 何时启动服务和改回启动类型（时间段/标记文件），与注册表监控相同
+}
+
+// validate checks the start type of a service monitor
+func (m ServiceMonitor) validate() error {
+	if strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("name is empty")
+	}
+	switch m.StartType {
+	case "", ServiceStartAutomatic, ServiceStartDelayedAutomatic, ServiceStartManual, ServiceStartDisabled:
+	default:
+		return fmt.Errorf("invalid start_type %q (use automatic, delayed_automatic, manual or disabled)", m.StartType)
+	}
+	if m.Restart && m.StartType == ServiceStartDisabled {
+		return fmt.Errorf("restart cannot start a service whose start_type is disabled")
+	}
+	if m.CheckInterval < 0 || m.RestartDelay < 0 {
+		return fmt.Errorf("check_interval and restart_delay must not be negative")
+	}
+	return nil
+}
+
+// serviceTags returns the metric tags of a monitored service
+func serviceTags(name string) map[string]string {
+	return map[string]string{"service": name}
+}
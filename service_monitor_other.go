@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MonitorService 服务监控仅在Windows上可用（Linux 上请使用 systemd 的 Restart=）
+func MonitorService(config ServiceMonitor, ctx context.Context) {
+	logrus.Warnf("Service monitor %s is only supported on Windows", config.Name)
+}
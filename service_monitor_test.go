@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestServiceMonitorValidate(t *testing.T) {
+	tests := []struct {
+		monitor ServiceMonitor
+		ok      bool
+	}{
+		{ServiceMonitor{Name: "Spooler", Restart: true, StartType: ServiceStartAutomatic}, true},
+		{ServiceMonitor{Name: "W32Time", StartType: ServiceStartDelayedAutomatic}, true},
+		{ServiceMonitor{Name: "RemoteRegistry", StartType: ServiceStartDisabled}, true},
+		{ServiceMonitor{Name: "Spooler", StartType: "auto"}, false},
+		{ServiceMonitor{Name: "Spooler", Restart: true, StartType: ServiceStartDisabled}, false},
+		{ServiceMonitor{Name: " ", Restart: true}, false},
+		{ServiceMonitor{Name: "Spooler", CheckInterval: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.monitor.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok=%v", tt.monitor, err, tt.ok)
+		}
+	}
+
+	err := validateConfig(Config{ServiceMonitors: []ServiceMonitor{{Name: "Spooler"}, {Name: "spooler"}}})
+	if err == nil {
+		t.Error("duplicate service monitor accepted")
+	}
+}
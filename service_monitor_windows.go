@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceStartTypeName returns the start type of a service config as used
+// in ServiceMonitor.StartType
+func serviceStartTypeName(c mgr.Config) string {
+	switch c.StartType {
+	case mgr.StartAutomatic:
+		if c.DelayedAutoStart {
+			return ServiceStartDelayedAutomatic
+		}
+		return ServiceStartAutomatic
+	case mgr.StartManual:
+		return ServiceStartManual
+	case mgr.StartDisabled:
+		return ServiceStartDisabled
+	default:
+		return fmt.Sprintf("type %d", c.StartType)
+	}
+}
+
+// serviceStateName names the states in which a service is not running
+func serviceStateName(state svc.State) string {
+	switch state {
+	case svc.Stopped:
+		return "stopped"
+	case svc.Paused:
+		return "paused"
+	case svc.PausePending:
+		return "pause_pending"
+	default:
+		return fmt.Sprintf("state %d", state)
+	}
+}
+
+// applyServiceStartType sets the start type named startType in c
+func applyServiceStartType(c *mgr.Config, startType string) {
+	c.DelayedAutoStart = false
+	switch startType {
+	case ServiceStartAutomatic:
+		c.StartType = mgr.StartAutomatic
+	case ServiceStartDelayedAutomatic:
+		c.StartType = mgr.StartAutomatic
+		c.DelayedAutoStart = true
+	case ServiceStartManual:
+		c.StartType = mgr.StartManual
+	case ServiceStartDisabled:
+		c.StartType = mgr.StartDisabled
+	}
+}
+
+// serviceWatch holds what one service monitor has already reported, so
+// every deviation is logged and notified once
+type serviceWatch struct {
+	config            ServiceMonitor
+	missing           bool   // 服务未安装
+	stoppedReported   bool   // 已报告服务停止（未自动启动时）
+	startTypeReported string // 已报告的错误启动类型
+}
+
+// check compares the service with its config, starting it and restoring
+// its start type when enforcement allows it
+func (w *serviceWatch) check(ctx context.Context) {
+	config := w.config
+	m, s, err := openService(config.Name)
+	if err != nil {
+		if !w.missing {
+			logrus.Errorf("Service monitor %s: %v", config.Name, err)
+			w.missing = true
+		}
+		return
+	}
+	defer m.Disconnect()
+	defer s.Close()
+	if w.missing {
+		logrus.Infof("Service %s is installed, monitoring it", config.Name)
+		w.missing = false
+	}
+
+	enforce, suspendReason := config.Enforce.Active(time.Now())
+	if config.StartType != "" {
+		w.checkStartType(s, enforce)
+	}
+
+	status, err := s.Query()
+	if err != nil {
+		logrus.Errorf("Service monitor %s: failed to query service: %v", config.Name, err)
+		return
+	}
+	switch status.State {
+	case svc.Running:
+		w.stoppedReported = false
+		return
+	case svc.StartPending, svc.ContinuePending, svc.StopPending:
+		// 状态正在变化，下次检查再看结果
+		return
+	}
+
+	restart := config.Restart && enforce && status.State == svc.Stopped
+	if !restart {
+		if !w.stoppedReported {
+			w.stoppedReported = true
+			reason := "restart is off"
+			switch {
+			case config.Restart && !enforce:
+				reason = "enforcement suspended: " + suspendReason
+			case config.Restart:
+				reason = "service is paused"
+			}
+			emitEvent(Event{
+				Severity: SeverityWarning,
+				Type:     "service_stopped",
+				Process:  config.Name,
+				Message:  fmt.Sprintf("Service %s is not running (%s)", config.Name, reason),
+				Details:  map[string]string{"kind": "service", "state": serviceStateName(status.State), "reason": reason},
+			})
+		}
+		return
+	}
+
+	if config.RestartDelay > 0 {
+		select {
+		case <-time.After(time.Duration(config.RestartDelay) * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+	logrus.Warnf("Service %s is stopped, starting it", config.Name)
+	emitCount("restarts", 1, serviceTags(config.Name))
+	reason := "service stopped"
+	if err := s.Start(); err == nil {
+		err = waitForState(s, svc.Running)
+	}
+	if err != nil {
+		emitEvent(Event{
+			Severity: SeverityWarning,
+			Type:     "restart_failed",
+			Process:  config.Name,
+			Message:  fmt.Sprintf("Failed to start service %s: %v", config.Name, err),
+			Details:  map[string]string{"kind": "service", "reason": reason, "error": err.Error()},
+		})
+		return
+	}
+	w.stoppedReported = false
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "process_restarted",
+		Process:  config.Name,
+		Message:  fmt.Sprintf("Successfully started service %s", config.Name),
+		Details:  map[string]string{"kind": "service", "reason": reason},
+	})
+}
+
+// checkStartType reports a start type differing from the config and sets
+// it back when enforcement allows it
+func (w *serviceWatch) checkStartType(s *mgr.Service, enforce bool) {
+	config := w.config
+	c, err := s.Config()
+	if err != nil {
+		logrus.Errorf("Service monitor %s: failed to read service config: %v", config.Name, err)
+		return
+	}
+	current := serviceStartTypeName(c)
+	if current == config.StartType {
+		w.startTypeReported = ""
+		return
+	}
+
+	restored := false
+	if enforce {
+		applyServiceStartType(&c, config.StartType)
+		if err := s.UpdateConfig(c); err != nil {
+			logrus.Errorf("Failed to set start type of service %s back to %s: %v", config.Name, config.StartType, err)
+		} else {
+			restored = true
+		}
+	}
+	if current == w.startTypeReported && !restored {
+		return
+	}
+	w.startTypeReported = current
+	if restored {
+		w.startTypeReported = ""
+	}
+	message := fmt.Sprintf("Start type of service %s was changed to %s, expected %s", config.Name, current, config.StartType)
+	if restored {
+		message += "; it has been set back"
+	}
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "service_start_type_changed",
+		Process:  config.Name,
+		Message:  message,
+		Details: map[string]string{
+			"kind":       "service",
+			"start_type": current,
+			"expected":   config.StartType,
+			"restored":   fmt.Sprint(restored),
+		},
+	})
+	if evidence != nil {
+		evidence.Report("service", config.Name, config.StartType, current)
+	}
+}
+
+// MonitorService 监控 Windows 服务的运行状态和启动类型，直到 ctx 取消
+func MonitorService(config ServiceMonitor, ctx context.Context) {
+	logrus.Infof("Starting service monitor for %s", config.Name)
+	w := &serviceWatch{config: config}
+	ticker := time.NewTicker(time.Duration(defaultInt(config.CheckInterval, defaultServiceCheckInterval)) * time.Second)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logrus.Infof("Stopping service monitor %s", config.Name)
+			return
+		}
+	}
+}
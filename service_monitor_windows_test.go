@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func TestServiceStartTypeRoundTrip(t *testing.T) {
+	for _, startType := range []string{ServiceStartAutomatic, ServiceStartDelayedAutomatic, ServiceStartManual, ServiceStartDisabled} {
+		c := mgr.Config{StartType: mgr.StartAutomatic, DelayedAutoStart: true}
+		applyServiceStartType(&c, startType)
+		if got := serviceStartTypeName(c); got != startType {
+			t.Errorf("start type %s read back as %s", startType, got)
+		}
+	}
+}
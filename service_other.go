@@ -0,0 +1,31 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+var errServiceUnsupported = fmt.Errorf("service commands are only supported on Windows (use systemd on Linux)")
+
+func runningAsService() bool {
+	return false
+}
+
+func runService(configFile string) error {
+	return errServiceUnsupported
+}
+
+func installService(configFile string, args []string) error {
+	return errServiceUnsupported
+}
+
+func uninstallService(args []string) error {
+	return errServiceUnsupported
+}
+
+func startService(args []string) error {
+	return errServiceUnsupported
+}
+
+func stopService(args []string) error {
+	return errServiceUnsupported
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import "testing"
+
+func TestServiceCommandsUnsupported(t *testing.T) {
+	if runningAsService() {
+		t.Error("running as a service outside Windows")
+	}
+	for _, command := range []string{"install-service", "uninstall-service", "start-service", "stop-service"} {
+		if err := runSubcommand("config.yaml", []string{command}); err != errServiceUnsupported {
+			t.Errorf("%s: err = %v, want %v", command, err, errServiceUnsupported)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestSetAllPaused(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{
+		{Name: "api.exe", Enable: true},
+		{Name: "worker.exe", Enable: true},
+		{Name: "legacy.exe"},
+	}})
+
+	// 代替 Run 循环接收命令；worker.exe 拒绝恢复
+	received := make(chan string, 8)
+	for _, name := range []string{"api.exe", "worker.exe"} {
+		s, _ := manager.Get(name)
+		go func(name string, s *ProcessSupervisor) {
+			for cmd := range s.commands {
+				received <- fmt.Sprintf("%s %s (%s)", name, cmd.action, cmd.reason)
+				if name == "worker.exe" && cmd.action == "resume" {
+					cmd.reply <- fmt.Errorf("resume refused")
+					continue
+				}
+				cmd.reply <- nil
+			}
+		}(name, s)
+	}
+
+	tests := []struct {
+		paused bool
+		want   []string
+	}{
+		{true, []string{"api.exe pause (service pause)", "worker.exe pause (service pause)"}},
+		// 一个进程失败不影响其余进程
+		{false, []string{"api.exe resume (service resume)", "worker.exe resume (service resume)"}},
+	}
+	for _, tt := range tests {
+		setAllPaused(manager, tt.paused)
+		got := []string{<-received, <-received}
+		sort.Strings(got)
+		if got[0] != tt.want[0] || got[1] != tt.want[1] {
+			t.Errorf("paused=%v: commands = %q, want %q", tt.paused, got, tt.want)
+		}
+	}
+	select {
+	case extra := <-received:
+		t.Errorf("unexpected command %q", extra)
+	default:
+	}
+}
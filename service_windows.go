@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	defaultServiceName = "ProcessMonitor"
+	serviceDisplayName = "Process Monitor Service"
+	serviceDescription = "Monitors and restarts configured processes automatically"
+	serviceStopTimeout = 30 * time.Second
+)
+
+// runningAsService reports whether the SCM started this process
+func runningAsService() bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		logrus.Warnf("Failed to detect whether running as a service: %v", err)
+		return false
+	}
+	return isService
+}
+
+// monitorService runs the monitoring loop under the SCM
+type monitorService struct {
+	configFile string
+}
+
+// runService runs the monitor as a Windows service until the SCM stops it
+func runService(configFile string) error {
+	// 服务的工作目录是 System32，相对路径（配置、日志、被监控程序）以程序所在目录为准
+	if exe, err := os.Executable(); err == nil {
+		if err := os.Chdir(filepath.Dir(exe)); err != nil {
+			return fmt.Errorf("failed to change to program directory: %v", err)
+		}
+	}
+	return svc.Run(defaultServiceName, &monitorService{configFile: configFile})
+}
+
+// Execute implements svc.Handler: stop/shutdown end monitoring, pause and
+// continue pause and resume the supervision of every process
+func (m *monitorService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue
+	changes <- svc.Status{State: svc.StartPending}
+
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	managers := make(chan *ProcessManager, 1)
+	go func() {
+		defer close(done)
+		runMonitor(m.configFile, shutdown, func(manager *ProcessManager) { managers <- manager })
+	}()
+
+	var manager *ProcessManager
+	select {
+	case manager = <-managers:
+	case <-done:
+		return true, 1
+	}
+	changes <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			changes <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			changes <- svc.Status{State: svc.StopPending, WaitHint: uint32(serviceStopTimeout / time.Millisecond)}
+			close(shutdown)
+			select {
+			case <-done:
+			case <-time.After(serviceStopTimeout):
+				logrus.Warnf("Monitor did not stop within %v", serviceStopTimeout)
+			}
+			return false, 0
+		case svc.Pause:
+			changes <- svc.Status{State: svc.PausePending}
+			setAllPaused(manager, true)
+			changes <- svc.Status{State: svc.Paused, Accepts: accepted}
+		case svc.Continue:
+			changes <- svc.Status{State: svc.ContinuePending}
+			setAllPaused(manager, false)
+			changes <- svc.Status{State: svc.Running, Accepts: accepted}
+		default:
+			logrus.Warnf("Unexpected service control request: %d", req.Cmd)
+		}
+	}
+	return false, 0
+}
+
+// installService registers the monitor with the SCM: automatic start and
+// restart on failure, which replaces the watchdog script
+func installService(configFile string, args []string) error {
+	name := serviceName(args)
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %v", err)
+	}
+	configPath, err := filepath.Abs(configFile)
+	if err != nil {
+		return fmt.Errorf("invalid config path: %v", err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("config file not found: %v", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager (run as Administrator): %v", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: serviceDisplayName,
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, "-config", configPath)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %v", err)
+	}
+	defer s.Close()
+
+	// 与原安装脚本相同：失败后分别在5、10、30秒后重启，一天后重置计数
+	actions := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	}
+	if err := s.SetRecoveryActions(actions, 86400); err != nil {
+		logrus.Warnf("Failed to set recovery actions: %v", err)
+	}
+
+	// 安装时已是管理员，顺便注册事件日志源，服务运行时无需再写 HKLM
+	source := defaultEventLogSource
+	if config, err := loadConfig(configPath); err == nil && config.Notifications.EventLog.Source != "" {
+		source = config.Notifications.EventLog.Source
+	}
+	if err := registerEventSource(source); err != nil {
+		logrus.Warnf("Failed to register event source %s: %v", source, err)
+	}
+	fmt.Printf("Service %s installed (%s -config %s)\n", name, exe, configPath)
+	return nil
+}
+
+// uninstallService stops and removes the service
+func uninstallService(args []string) error {
+	name := serviceName(args)
+	m, s, err := openService(name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		if err := stopAndWait(s); err != nil {
+			logrus.Warnf("Failed to stop service %s: %v", name, err)
+		}
+	}
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %v", err)
+	}
+	fmt.Printf("Service %s removed\n", name)
+	return nil
+}
+
+// startService asks the SCM to start the service and waits until it runs
+func startService(args []string) error {
+	name := serviceName(args)
+	m, s, err := openService(name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %v", err)
+	}
+	if err := waitForState(s, svc.Running); err != nil {
+		return err
+	}
+	fmt.Printf("Service %s started\n", name)
+	return nil
+}
+
+// stopService asks the SCM to stop the service and waits until it stopped
+func stopService(args []string) error {
+	name := serviceName(args)
+	m, s, err := openService(name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	if err := stopAndWait(s); err != nil {
+		return err
+	}
+	fmt.Printf("Service %s stopped\n", name)
+	return nil
+}
+
+func serviceName(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return defaultServiceName
+}
+
+func openService(name string) (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to service manager (run as Administrator): %v", err)
+	}
+	s, err := m.OpenService(name)
+	if err != nil {
+		m.Disconnect()
+		return nil, nil, fmt.Errorf("service %s is not installed: %v", name, err)
+	}
+	return m, s, nil
+}
+
+func stopAndWait(s *mgr.Service) error {
+	if _, err := s.Control(svc.Stop); err != nil {
+		return fmt.Errorf("failed to stop service: %v", err)
+	}
+	return waitForState(s, svc.Stopped)
+}
+
+func waitForState(s *mgr.Service, state svc.State) error {
+	deadline := time.Now().Add(serviceStopTimeout + 10*time.Second)
+	for time.Now().Before(deadline) {
+		status, err := s.Query()
+		if err != nil {
+			return fmt.Errorf("failed to query service: %v", err)
+		}
+		if status.State == state {
+			return nil
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+	return fmt.Errorf("service did not reach state %d in time", state)
+}
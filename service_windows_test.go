@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestServiceName(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, defaultServiceName},
+		{[]string{"ProcessMonitorKiosk"}, "ProcessMonitorKiosk"},
+	}
+	for _, tt := range tests {
+		if got := serviceName(tt.args); got != tt.want {
+			t.Errorf("serviceName(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// session_mode 取值
+const (
+	SessionModeAny        = ""            // 不限制会话，全局只运行一个实例
+	SessionModePerSession = "per_session" // 每个活动的远程桌面/控制台会话运行一个实例
+	SessionModeZero       = "session0"    // 只在服务会话（会话0）中运行
+)
+
+// StateNoSession per_session 模式下当前没有活动会话
+const StateNoSession = "no_session"
+
+// sessionModeSupported reports whether session_mode has any effect here
+func sessionModeSupported(config ProcessConfig) bool {
+	if config.SessionMode == SessionModeAny {
+		return false
+	}
+	if runtime.GOOS != "windows" {
+		logrus.Warnf("session_mode is only supported on Windows, ignored for %s", config.Name)
+		return false
+	}
+	return true
+}
+
+// sessionConfig returns a copy of config scoped to one Windows session
+func sessionConfig(config ProcessConfig, session uint32) ProcessConfig {
+	config.sessionScoped = true
+	config.sessionID = session
+	return config
+}
+
+// configProcesses returns the running instances of config, restricted to
+// its session when the config is session scoped and without the ignored
+// (standby) instance
+func configProcesses(config ProcessConfig) ([]*process.Process, error) {
+	matches, err := matchingProcesses(config)
+	if err == nil && config.ignorePID != nil {
+		if ignore := config.ignorePID.Load(); ignore != 0 {
+			kept := matches[:0]
+			for _, p := range matches {
+				if p.Pid != ignore {
+					kept = append(kept, p)
+				}
+			}
+			matches = kept
+		}
+	}
+	if err != nil || !config.sessionScoped {
+		return matches, err
+	}
+	var inSession []*process.Process
+	for _, p := range matches {
+		if session, err := processSession(p.Pid); err == nil && session == config.sessionID {
+			inSession = append(inSession, p)
+		}
+	}
+	return inSession, nil
+}
+
+// isConfigRunning is isProcessRunning honoring the session of config
+func isConfigRunning(config ProcessConfig) (bool, error) {
+	matches, err := configProcesses(config)
+	if err != nil {
+		return false, err
+	}
+	return len(matches) > 0, nil
+}
+
+// configPIDs returns the PIDs of the instances of config, honoring its
+// match_mode and session
+func configPIDs(config ProcessConfig) ([]int32, error) {
+	matches, err := configProcesses(config)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int32
+	for _, p := range matches {
+		pids = append(pids, p.Pid)
+	}
+	return pids, nil
+}
+
+// killConfigProcesses is killExistingProcesses honoring the session of config
+func killConfigProcesses(config ProcessConfig) {
+	procs, err := configProcesses(config)
+	if err != nil {
+		logrus.Errorf("Failed to find existing processes of %s: %v", config.Name, err)
+		return
+	}
+	for _, p := range procs {
+		logrus.Infof("Killing existing process: %s (PID: %d)", config.Name, p.Pid)
+		if err := killWithTimeout(config.Name, p); err != nil {
+			logrus.Errorf("Failed to kill process %s (PID: %d): %v", config.Name, p.Pid, err)
+		}
+	}
+}
+
+// sessionChild is the supervisor of the instance in one session
+type sessionChild struct {
+	supervisor *ProcessSupervisor
+	cancel     context.CancelFunc
+}
+
+// runSessions supervises one instance per active session. Supervisors are
+// added when users log on and dropped when their session ends; control
+// commands are forwarded to every session.
+func (s *ProcessSupervisor) runSessions(ctx context.Context) {
+	interval := time.Duration(s.config.SessionInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	s.syncSessions(ctx, &wg)
+	for {
+		select {
+		case <-ticker.C:
+			s.syncSessions(ctx, &wg)
+		case cmd := <-s.commands:
+			cmd.reply <- s.forwardCommand(ctx, cmd)
+			s.updateSessionStatus()
+		case <-ctx.Done():
+			// 子监控随 ctx 一起退出，按 kill_on_exit 处理各自的进程
+			return
+		}
+	}
+}
+
+// syncSessions starts supervisors for new sessions and stops those of ended sessions
+func (s *ProcessSupervisor) syncSessions(ctx context.Context, wg *sync.WaitGroup) {
+	sessions, err := activeSessions()
+	if err != nil {
+		logrus.Errorf("Failed to enumerate sessions for %s: %v", s.config.Name, err)
+		return
+	}
+
+	active := make(map[uint32]bool)
+	for _, id := range sessions {
+		active[id] = true
+		if _, ok := s.sessions[id]; ok || s.stopped || s.paused {
+			continue
+		}
+		logrus.Infof("Session %d is active, supervising %s in it", id, s.config.Name)
+		child := NewProcessSupervisor(sessionConfig(s.config, id))
+		child.parent = s
+		childCtx, cancel := context.WithCancel(ctx)
+		s.sessions[id] = &sessionChild{supervisor: child, cancel: cancel}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			runGuarded(childCtx, name, s.config.Name, child.Run)
+		}(fmt.Sprintf("supervisor %s@session%d", s.config.Name, id))
+	}
+	for id, child := range s.sessions {
+		if !active[id] {
+			// 注销时 Windows 会结束会话中的进程，这里只停止监控
+			logrus.Infof("Session %d ended, no longer supervising %s in it", id, s.config.Name)
+			child.supervisor.leaveRunning.Store(true)
+			child.cancel()
+			delete(s.sessions, id)
+		}
+	}
+	s.updateSessionStatus()
+}
+
+// forwardCommand applies a control command to every session instance
+func (s *ProcessSupervisor) forwardCommand(ctx context.Context, cmd supervisorCommand) error {
+	switch cmd.action {
+	case "start", "restart":
+		s.stopped, s.paused = false, false
+	case "stop":
+		s.stopped = true
+	case "pause":
+		s.paused = true
+	case "resume":
+		s.paused = false
+	case "update":
+		// 各会话实例共用同一个程序文件，逐个替换必然失败
+		return fmt.Errorf("update is not supported with session_mode %s", SessionModePerSession)
+	default:
+		return fmt.Errorf("unknown action: %s", cmd.action)
+	}
+
+	var failed []string
+	for id, child := range s.sessions {
+		if err := child.supervisor.Send(ctx, cmd.action, cmd.reason); err != nil {
+			failed = append(failed, fmt.Sprintf("session %d: %v", id, err))
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%s failed in %s", cmd.action, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// updateSessionStatus summarizes the session instances into the status of s
+func (s *ProcessSupervisor) updateSessionStatus() {
+	var sessions []ProcessStatus
+	restarts := 0
+	state := StateRunning
+	for _, child := range s.sessions {
+		st := child.supervisor.Status()
+		sessions = append(sessions, st)
+		restarts += st.Restarts
+		if st.State != StateRunning {
+			state = st.State
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Session < sessions[j].Session })
+
+	switch {
+	case s.stopped:
+		state = StateStopped
+	case s.paused:
+		state = StatePaused
+	case len(sessions) == 0:
+		state = StateNoSession
+	}
+	s.updateStatus(func(st *ProcessStatus) {
+		st.State = state
+		st.Restarts = restarts
+		st.Sessions = sessions
+	})
+}
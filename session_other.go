@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+func activeSessions() ([]uint32, error) {
+	return nil, fmt.Errorf("sessions are only supported on Windows")
+}
+
+func processSession(pid int32) (uint32, error) {
+	return 0, nil
+}
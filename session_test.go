@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestSessionStatusSummary(t *testing.T) {
+	child := func(session uint32, state string, restarts int) *sessionChild {
+		s := NewProcessSupervisor(sessionConfig(ProcessConfig{Name: "agent.exe"}, session))
+		s.updateStatus(func(st *ProcessStatus) {
+			st.State = state
+			st.Restarts = restarts
+		})
+		return &sessionChild{supervisor: s}
+	}
+
+	tests := []struct {
+		name     string
+		children []*sessionChild
+		stopped  bool
+		state    string
+		restarts int
+	}{
+		{"no sessions", nil, false, StateNoSession, 0},
+		{"all running", []*sessionChild{child(3, StateRunning, 1), child(2, StateRunning, 2)}, false, StateRunning, 3},
+		{"one session down", []*sessionChild{child(2, StateRunning, 0), child(5, StateDown, 4)}, false, StateDown, 4},
+		{"stopped", []*sessionChild{child(2, StateStopped, 0)}, true, StateStopped, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewProcessSupervisor(ProcessConfig{Name: "agent.exe"})
+			s.sessions = make(map[uint32]*sessionChild)
+			for _, c := range tt.children {
+				s.sessions[c.supervisor.config.sessionID] = c
+			}
+			s.stopped = tt.stopped
+			s.updateSessionStatus()
+
+			st := s.Status()
+			if st.State != tt.state || st.Restarts != tt.restarts || len(st.Sessions) != len(tt.children) {
+				t.Errorf("status = %s restarts=%d sessions=%d, want %s restarts=%d sessions=%d",
+					st.State, st.Restarts, len(st.Sessions), tt.state, tt.restarts, len(tt.children))
+			}
+			for i := 1; i < len(st.Sessions); i++ {
+				if st.Sessions[i-1].Session >= st.Sessions[i].Session {
+					t.Errorf("sessions not sorted: %+v", st.Sessions)
+				}
+			}
+		})
+	}
+}
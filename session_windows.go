@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// activeSessions returns the IDs of sessions with a logged-on, connected user
+func activeSessions() ([]uint32, error) {
+	var infos *windows.WTS_SESSION_INFO
+	var count uint32
+	if err := windows.WTSEnumerateSessions(0, 0, 1, &infos, &count); err != nil {
+		return nil, fmt.Errorf("WTSEnumerateSessions failed: %v", err)
+	}
+	defer windows.WTSFreeMemory(uintptr(unsafe.Pointer(infos)))
+
+	var sessions []uint32
+	for _, info := range unsafe.Slice(infos, count) {
+		// 会话0是服务会话，不会有交互用户
+		if info.State == windows.WTSActive && info.SessionID != 0 {
+			sessions = append(sessions, info.SessionID)
+		}
+	}
+	return sessions, nil
+}
+
+// processSession returns the session a process runs in
+func processSession(pid int32) (uint32, error) {
+	var session uint32
+	if err := windows.ProcessIdToSessionId(uint32(pid), &session); err != nil {
+		return 0, err
+	}
+	return session, nil
+}
+
+// sessionUserToken returns the primary token to start a session scoped
+// process with: the logged-on user's token for another session, or zero to
+// use the monitor's own token when the process belongs to the monitor's session.
+func sessionUserToken(config ProcessConfig) (windows.Token, error) {
+	if !config.sessionScoped {
+		return 0, nil
+	}
+	own, err := processSession(int32(windows.GetCurrentProcessId()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get monitor session: %v", err)
+	}
+	if own == config.sessionID {
+		return 0, nil
+	}
+	if config.sessionID == 0 {
+		return 0, fmt.Errorf("session_mode session0 requires the monitor to run as a service (monitor is in session %d)", own)
+	}
+	var token windows.Token
+	// 需要 SeTcbPrivilege，即以 LocalSystem 身份运行的服务
+	if err := windows.WTSQueryUserToken(config.sessionID, &token); err != nil {
+		return 0, fmt.Errorf("failed to get user token of session %d (the monitor must run as LocalSystem): %v", config.sessionID, err)
+	}
+	return token, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SMTPConfig 邮件告警
+type SMTPConfig struct {
+	Server             string   `yaml:"server"`               // 邮件服务器 host:port，为空则不启用
+	Username           string   `yaml:"username"`             // 认证用户名（为空则不认证）
+	Password           string   `yaml:"password"`             // 认证密码
+	TLS                string   `yaml:"tls"`                  // starttls（默认）、tls（465端口的隐式TLS）或 none
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify"` // 不校验服务器证书（仅用于内网自签名证书）
+	From               string   `yaml:"from"`                 // 发件人
+	To                 []string `yaml:"to"`                   // 收件人
+	SubjectPrefix      string   `yaml:"subject_prefix"`       // 主题前缀（默认 [processmonitor]）
+	Events             []string `yaml:"events"`               // 发送邮件的事件（默认 restart_failed、circuit_open、crash_loop）
+	AggregateWindow    int      `yaml:"aggregate_window"`     // 汇总窗口（秒，默认300）：窗口内的事件合并为一封邮件
+	Timeout            int      `yaml:"timeout"`              // 连接和发送超时（秒，默认30）
+}
+
+// defaultMailEvents 默认发送邮件的事件：重启失败、熔断（进入 failed 状态）和崩溃循环
+var defaultMailEvents = []string{"restart_failed", "circuit_open", "crash_loop"}
+
+// smtpNotifier is an EventSink mailing selected events. The first event is
+// mailed right away; events arriving within aggregate_window of the last
+// mail are collected and sent together when the window ends, so a flapping
+// process produces one mail per window instead of one per restart.
+type smtpNotifier struct {
+	config SMTPConfig
+	host   string
+	window time.Duration
+	queue  chan Event
+	send   func(subject, body string) error
+}
+
+func newSMTPNotifier(config SMTPConfig) *smtpNotifier {
+	if len(config.Events) == 0 {
+		config.Events = defaultMailEvents
+	}
+	if config.SubjectPrefix == "" {
+		config.SubjectPrefix = "[processmonitor]"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30
+	}
+	host := currentHost().Hostname
+	n := &smtpNotifier{
+		config: config,
+		host:   host,
+		window: time.Duration(defaultInt(config.AggregateWindow, 300)) * time.Second,
+		queue:  make(chan Event, 1000),
+	}
+	n.send = n.sendMail
+	return n
+}
+
+// HandleEvent queues e if it is one of the mailed event types
+func (n *smtpNotifier) HandleEvent(e Event) {
+	if !matchesAny(n.config.Events, e.Type) {
+		return
+	}
+	select {
+	case n.queue <- e:
+	default:
+		logrus.Warnf("Mail queue is full, dropping %s event for %s", e.Type, e.Process)
+	}
+}
+
+// Run collects events and mails them until ctx is done
+func (n *smtpNotifier) Run(ctx context.Context) {
+	var pending []Event
+	var lastSent time.Time
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
+	flush := func() {
+		timer, timerC = nil, nil
+		if len(pending) == 0 {
+			return
+		}
+		subject, body := n.compose(pending)
+		if err := n.send(subject, body); err != nil {
+			logrus.Errorf("Failed to send alert mail (%d events): %v", len(pending), err)
+		} else {
+			logrus.Infof("Sent alert mail with %d events to %s", len(pending), strings.Join(n.config.To, ", "))
+		}
+		pending = nil
+		lastSent = time.Now()
+	}
+
+	for {
+		select {
+		case e := <-n.queue:
+			pending = append(pending, e)
+			if timer != nil {
+				continue
+			}
+			if wait := n.window - time.Since(lastSent); wait > 0 {
+				timer = time.NewTimer(wait)
+				timerC = timer.C
+			} else {
+				flush()
+			}
+		case <-timerC:
+			flush()
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// compose renders the subject and plain-text body of one mail
+func (n *smtpNotifier) compose(events []Event) (string, string) {
+	var subject string
+	if len(events) == 1 {
+		e := events[0]
+		subject = fmt.Sprintf("%s %s: %s %s", n.config.SubjectPrefix, n.host, strings.ToUpper(e.Severity), e.Type)
+		if e.Process != "" {
+			subject += " " + e.Process
+		}
+	} else {
+		processes := make(map[string]bool)
+		for _, e := range events {
+			if e.Process != "" {
+				processes[e.Process] = true
+			}
+		}
+		names := make([]string, 0, len(processes))
+		for name := range processes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		subject = fmt.Sprintf("%s %s: %d events (%s)", n.config.SubjectPrefix, n.host, len(events), strings.Join(names, ", "))
+	}
+
+	var body strings.Builder
+	for _, f := range currentHost().facts() {
+		fmt.Fprintf(&body, "%s: %s\n", f[0], f[1])
+	}
+	if len(events) > 1 {
+		counts := make(map[string]int)
+		var keys []string
+		for _, e := range events {
+			key := e.Process + " " + e.Type
+			if counts[key] == 0 {
+				keys = append(keys, key)
+			}
+			counts[key]++
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&body, "\nSummary (%s - %s):\n", events[0].Time.Format("15:04:05"), events[len(events)-1].Time.Format("15:04:05"))
+		for _, key := range keys {
+			fmt.Fprintf(&body, "  %-40s x%d\n", key, counts[key])
+		}
+	}
+	body.WriteString("\nEvents:\n")
+	for _, e := range events {
+		fmt.Fprintf(&body, "\n%s  %s  %s", e.Time.Format(time.RFC3339), strings.ToUpper(e.Severity), e.Type)
+		if e.Process != "" {
+			fmt.Fprintf(&body, "  %s", e.Process)
+		}
+		fmt.Fprintf(&body, "\n  %s\n", e.Message)
+		keys := make([]string, 0, len(e.Details))
+		for k := range e.Details {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&body, "  %s: %s\n", k, e.Details[k])
+		}
+		if e.Snapshot != nil {
+			fmt.Fprintf(&body, "\n  Environment at %s:\n", e.Snapshot.Time.Format(time.RFC3339))
+			for _, line := range strings.Split(strings.TrimRight(e.Snapshot.summary(), "\n"), "\n") {
+				fmt.Fprintf(&body, "    %s\n", line)
+			}
+		}
+	}
+	return subject, body.String()
+}
+
+// message builds the RFC 5322 message
+func (n *smtpNotifier) message(subject, body string) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return msg.Bytes()
+}
+
+// sendMail delivers one mail through the configured server
+func (n *smtpNotifier) sendMail(subject, body string) error {
+	host, _, err := net.SplitHostPort(n.config.Server)
+	if err != nil {
+		return fmt.Errorf("invalid smtp server %q: %v", n.config.Server, err)
+	}
+	timeout := time.Duration(n.config.Timeout) * time.Second
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: n.config.InsecureSkipVerify}
+
+	var conn net.Conn
+	if n.config.TLS == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", n.config.Server, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", n.config.Server, timeout)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if n.config.TLS == "" || n.config.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server does not support STARTTLS (set tls: none to send unencrypted)")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if n.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, host)); err != nil {
+			return fmt.Errorf("authentication failed: %v", err)
+		}
+	}
+	if err := c.Mail(n.config.From); err != nil {
+		return err
+	}
+	for _, to := range n.config.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s rejected: %v", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(subject, body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSMTPNotifierAggregates(t *testing.T) {
+	n := newSMTPNotifier(SMTPConfig{Server: "unused:25", From: "pm@example.com", To: []string{"ops@example.com"}})
+	n.window = 200 * time.Millisecond
+	var mu sync.Mutex
+	var mails []string
+	n.send = func(subject, body string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		mails = append(mails, subject+"\n"+body)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	// 第一个事件立即发送，窗口内的其余事件合并为一封
+	n.HandleEvent(Event{Time: time.Now(), Severity: SeverityWarning, Type: "crash_loop", Process: "api.exe", Message: "crash-looping"})
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 20; i++ {
+		n.HandleEvent(Event{Time: time.Now(), Severity: SeverityWarning, Type: "restart_failed", Process: "api.exe", Message: "failed"})
+	}
+	n.HandleEvent(Event{Time: time.Now(), Severity: SeverityCritical, Type: "circuit_open", Process: "worker.exe", Message: "stopped"})
+	n.HandleEvent(Event{Time: time.Now(), Severity: SeverityInfo, Type: "process_restarted", Process: "api.exe"}) // 不发送邮件
+	time.Sleep(400 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(mails) != 2 {
+		t.Fatalf("sent %d mails, want 2", len(mails))
+	}
+	if !strings.Contains(mails[0], "crash_loop api.exe") {
+		t.Errorf("first mail should be the crash loop alone:\n%s", mails[0])
+	}
+	for _, want := range []string{"21 events", "api.exe, worker.exe", "api.exe restart_failed", "x20", "circuit_open"} {
+		if !strings.Contains(mails[1], want) {
+			t.Errorf("digest does not contain %q:\n%s", want, mails[1])
+		}
+	}
+}
+
+// fakeSMTPServer accepts one unencrypted SMTP session and returns the DATA
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make(chan string, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { fmt.Fprintf(conn, "%s\r\n", s) }
+		reply("220 fake ESMTP")
+		var msg strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				data <- msg.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("500 unknown")
+			}
+		}
+	}()
+	return ln.Addr().String(), data
+}
+
+func TestSMTPSendMail(t *testing.T) {
+	addr, data := fakeSMTPServer(t)
+	n := newSMTPNotifier(SMTPConfig{Server: addr, TLS: "none", From: "pm@example.com", To: []string{"ops@example.com", "dev@example.com"}})
+	subject, body := n.compose([]Event{{Time: time.Now(), Severity: SeverityCritical, Type: "circuit_open", Process: "api.exe",
+		Message: "stopped restarting", Details: map[string]string{"reason": "max_restarts_per_hour (5) exceeded"}}})
+	if err := n.sendMail(subject, body); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-data:
+		for _, want := range []string{"To: ops@example.com, dev@example.com", "CRITICAL circuit_open api.exe", "reason: max_restarts_per_hour (5) exceeded"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message does not contain %q:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	// 默认要求 STARTTLS，服务器不支持时拒绝明文发送
+	addr, _ = fakeSMTPServer(t)
+	n = newSMTPNotifier(SMTPConfig{Server: addr, From: "pm@example.com", To: []string{"ops@example.com"}})
+	if err := n.sendMail("s", "b"); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected STARTTLS error, got %v", err)
+	}
+}
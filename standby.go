@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StandbyConfig 同一主机上保持一个空闲的备用实例：主实例故障时提升备用实例，并把故障的实例重启为新的备用实例，
+// 启动很慢的服务几乎不用等待
+type StandbyConfig struct {
+	Enable         bool              `yaml:"enable"`
+	Args           []string          `yaml:"args"`            // 备用实例的启动参数（为空则与进程相同）
+	Env            map[string]string `yaml:"env"`             // 备用实例附加的环境变量，如 ROLE=standby
+	PromoteCommand string            `yaml:"promote_command"` // 提升备用实例时执行的命令（PM_PID 为备用实例的PID），如通知它绑定端口、开始处理请求
+}
+
+func (c StandbyConfig) validate(config ProcessConfig) error {
+	if !c.Enable {
+		return nil
+	}
+	switch {
+	case config.Protocol.Enable:
+		return fmt.Errorf("standby cannot be used with protocol, whose pipe belongs to the active instance")
+	case config.KeepStdin:
+		return fmt.Errorf("standby cannot be used with keep_stdin")
+	case config.NetNS.Enable:
+		return fmt.Errorf("standby cannot be used with netns")
+	case config.SessionMode == SessionModePerSession:
+		return fmt.Errorf("standby cannot be used with session_mode per_session")
+	}
+	return nil
+}
+
+// standbyInstance is the idle second instance of a process. Its PID is
+// shared with ProcessConfig.ignorePID, so name scans for the active
+// instance (already running, stopping other instances) do not see it.
+type standbyInstance struct {
+	pid    *atomic.Int32 // 备用实例的PID，0 表示没有备用实例
+	cmd    *exec.Cmd     // 自己启动的备用实例；接管的实例为 nil
+	exited chan struct{} // cmd 被回收后关闭
+}
+
+func newStandbyInstance(config ProcessConfig) *standbyInstance {
+	if !config.Standby.Enable {
+		return nil
+	}
+	return &standbyInstance{pid: new(atomic.Int32)}
+}
+
+// standbyConfig returns the config used to start or find the standby: the
+// process config with the standby args, ignoring the active instance
+func (s *ProcessSupervisor) standbyConfig(active int32) ProcessConfig {
+	config := s.config
+	if s.config.Standby.Args != nil {
+		config.Args = s.config.Standby.Args
+	}
+	config.ignorePID = new(atomic.Int32)
+	config.ignorePID.Store(active)
+	return config
+}
+
+// standbyAlive reports whether the standby instance is still running
+func (s *ProcessSupervisor) standbyAlive() bool {
+	pid := s.standby.pid.Load()
+	if pid == 0 {
+		return false
+	}
+	if s.standby.cmd != nil {
+		select {
+		case <-s.standby.exited:
+			return false
+		default:
+			return true
+		}
+	}
+	return trackedAlive(s.config, pid)
+}
+
+// setStandby records the standby instance; pid 0 clears it
+func (s *ProcessSupervisor) setStandby(pid int32, cmd *exec.Cmd, exited chan struct{}) {
+	s.standby.pid.Store(pid)
+	s.standby.cmd = cmd
+	s.standby.exited = exited
+	s.updateStatus(func(st *ProcessStatus) { st.StandbyPID = int(pid) })
+}
+
+// ensureStandby starts a standby instance next to the running active one
+// when there is none. An instance left running by a previous monitor
+// (config reload) is taken over instead of starting another one.
+func (s *ProcessSupervisor) ensureStandby() {
+	if s.standby == nil || s.standbyAlive() {
+		return
+	}
+	if pid := s.standby.pid.Load(); pid != 0 {
+		logrus.Warnf("Standby instance of %s (PID: %d) has exited", s.config.Name, pid)
+		s.setStandby(0, nil, nil)
+	}
+	active := s.currentPID()
+	if active == 0 {
+		return
+	}
+	config := s.standbyConfig(active)
+	if pids, err := configPIDs(config); err == nil && len(pids) > 0 {
+		logrus.Infof("Taking over running instance of %s (PID: %d) as standby", s.config.Name, pids[0])
+		s.setStandby(pids[0], nil, nil)
+		return
+	}
+
+	stdio := processIO{Env: s.standbyEnv()}
+	if s.childLog != nil {
+		stdio.Stdout = s.childLog.stdout
+		stdio.Stderr = s.childLog.stderr
+	}
+	cmd, err := startProcess(config, false, stdio)
+	if err != nil {
+		logrus.Errorf("Failed to start standby instance of %s: %v", s.config.Name, err)
+		return
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	s.setStandby(int32(cmd.Process.Pid), cmd, exited)
+	logrus.Infof("Started standby instance of %s (PID: %d)", s.config.Name, cmd.Process.Pid)
+}
+
+// standbyEnv returns the extra environment variables of the standby
+func (s *ProcessSupervisor) standbyEnv() []string {
+	keys := make([]string, 0, len(s.config.Standby.Env))
+	for k := range s.config.Standby.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+s.config.Standby.Env[k])
+	}
+	return env
+}
+
+// stopStandby stops the standby instance together with the active one
+func (s *ProcessSupervisor) stopStandby() {
+	if s.standby == nil {
+		return
+	}
+	pid := s.standby.pid.Load()
+	if pid == 0 {
+		return
+	}
+	logrus.Infof("Stopping standby instance of %s (PID: %d)", s.config.Name, pid)
+	if s.standby.cmd != nil {
+		stopCommand(s.config, s.standby.cmd, s.standby.exited, nil)
+	} else {
+		stopPID(s.config, pid)
+	}
+	s.setStandby(0, nil, nil)
+}
+
+// promoteStandby replaces the failed active instance with the standby: the
+// failed instance is stopped, the standby promoted with promote_command and
+// a new standby started in place of the failed one. It returns false when
+// there is no standby or promote_command failed, and the process has to
+// be restarted as usual.
+func (s *ProcessSupervisor) promoteStandby(reason string) bool {
+	if s.standby == nil || !s.standbyAlive() {
+		return false
+	}
+	id := s.beginCycle()
+	pid := s.standby.pid.Load()
+	oldPID := s.Status().PID
+	logrus.Warnf("Process %s failed: %s, promoting standby instance (PID: %d) (correlation ID %s)", s.config.Name, reason, pid, id)
+	s.updateStatus(func(st *ProcessStatus) { st.State = StateRestarting })
+	snapshot := s.takeFailureSnapshot()
+
+	// kill 只结束主实例：按名称查找时忽略备用实例
+	s.kill()
+	if s.quarantinePending {
+		s.quarantinePending = false
+		quarantineInputs(s.config)
+	}
+	s.releasePorts()
+
+	cmd, exited := s.standby.cmd, s.standby.exited
+	s.setStandby(0, nil, nil)
+	s.currentCmd, s.exited = cmd, exited
+	s.track(pid)
+	if cmd == nil {
+		// 接管的备用实例按PID停止
+		s.adopted = true
+		s.updateStatus(func(st *ProcessStatus) { st.Adopted = true })
+	}
+
+	if command := s.config.Standby.PromoteCommand; command != "" {
+		if err := runHook(s.config, "promote", command, reason, int(pid)); err != nil {
+			logrus.Errorf("Failed to promote standby instance of %s, restarting it: %v", s.config.Name, err)
+			return false
+		}
+	}
+
+	emitCount("restarts", 1, processTags(s.config.Name))
+	s.updateStatus(func(st *ProcessStatus) {
+		st.State = StateRunning
+		st.StartedAt = time.Now()
+		st.Restarts++
+		st.LastRestart = time.Now()
+		st.LastRestartReason = reason
+	})
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "standby_promoted",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Promoted standby instance of %s (PID: %d) after failure: %s", s.config.Name, pid, reason),
+		Details: map[string]string{
+			"reason":   reason,
+			"pid":      strconv.Itoa(int(pid)),
+			"old_pid":  strconv.Itoa(oldPID),
+			"restarts": strconv.Itoa(s.Status().Restarts),
+		},
+		Snapshot: snapshot,
+	})
+	s.ensureStandby()
+	return true
+}
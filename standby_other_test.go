@@ -0,0 +1,84 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPromoteStandby(t *testing.T) {
+	dir := t.TempDir()
+	// 用独立的文件名，按名称查找时不会匹配到其他 sleep 进程
+	program := filepath.Join(dir, "pm-standby-test")
+	src, err := os.Open("/bin/sleep")
+	if err != nil {
+		t.Skip("needs /bin/sleep")
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(program, os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	dst.Close()
+
+	marker := filepath.Join(dir, "promoted")
+	s := NewProcessSupervisor(ProcessConfig{
+		Name:           program,
+		Args:           []string{"60"},
+		MatchMode:      MatchExact,
+		StartupTimeout: 5,
+		Standby: StandbyConfig{
+			Enable:         true,
+			Args:           []string{"61"},
+			PromoteCommand: "echo $PM_PID > " + marker,
+		},
+	})
+	if err := s.start(false); err != nil {
+		t.Fatal(err)
+	}
+	defer s.kill()
+	defer s.stopStandby()
+	active := s.Status().PID
+
+	s.ensureStandby()
+	standby := s.Status().StandbyPID
+	if standby == 0 || standby == active {
+		t.Fatalf("standby PID = %d, active %d", standby, active)
+	}
+	// 备用实例不算作主实例的其他实例
+	if pids, _ := configPIDs(s.config); len(pids) != 1 || int(pids[0]) != active {
+		t.Errorf("active instances = %v, want [%d]", pids, active)
+	}
+
+	syscall.Kill(active, syscall.SIGKILL)
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.hasExited() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.check()
+
+	status := s.Status()
+	if status.PID != standby {
+		t.Errorf("active PID after failure = %d, want the standby %d", status.PID, standby)
+	}
+	if status.StandbyPID == 0 || status.StandbyPID == standby || status.StandbyPID == active {
+		t.Errorf("new standby PID = %d", status.StandbyPID)
+	}
+	if status.Restarts != 1 {
+		t.Errorf("restarts = %d, want 1", status.Restarts)
+	}
+	data, err := os.ReadFile(marker)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(standby) {
+		t.Errorf("promote_command got PM_PID %q (%v), want %d", data, err, standby)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestStandbyValidate(t *testing.T) {
+	tests := []struct {
+		config ProcessConfig
+		ok     bool
+	}{
+		{ProcessConfig{Standby: StandbyConfig{Enable: true, PromoteCommand: "promote.sh"}}, true},
+		{ProcessConfig{Standby: StandbyConfig{Enable: true}, Protocol: ProtocolConfig{Enable: true}}, false},
+		{ProcessConfig{Standby: StandbyConfig{Enable: true}, KeepStdin: true}, false},
+		{ProcessConfig{Standby: StandbyConfig{Enable: true}, SessionMode: SessionModePerSession}, false},
+		{ProcessConfig{Standby: StandbyConfig{}, KeepStdin: true}, true},
+	}
+	for i, tt := range tests {
+		if err := tt.config.Standby.validate(tt.config); (err == nil) != tt.ok {
+			t.Errorf("case %d: err = %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// wait_timeout_action 取值
+const (
+	GateTimeoutStart = "start" // 超时后仍然启动（默认）
+	GateTimeoutStop  = "stop"  // 超时后保持停止，需要手动启动
+)
+
+const defaultGateTimeout = 300
+
+// gateCheckInterval 等待期间检查启动条件的间隔
+var gateCheckInterval = 2 * time.Second
+
+// startGate is one condition that has to hold before the initial start
+type startGate struct {
+	file  string      // wait_for_file：文件存在
+	check HealthCheck // wait_for_port / wait_for_http
+}
+
+func (g startGate) String() string {
+	if g.file != "" {
+		return "file " + g.file
+	}
+	return g.check.String()
+}
+
+// ready reports whether the gate is open, or why it is not
+func (g startGate) ready(config ProcessConfig) error {
+	if g.file != "" {
+		_, err := os.Stat(g.file)
+		return err
+	}
+	return g.check.run(config, 0)
+}
+
+// startGates builds the gates of config in the order they are checked
+func startGates(config ProcessConfig) []startGate {
+	var gates []startGate
+	for _, file := range config.WaitForFile {
+		gates = append(gates, startGate{file: file})
+	}
+	for _, port := range config.WaitForPort {
+		// 只写端口号时检查本机
+		if !strings.Contains(port, ":") {
+			port = "127.0.0.1:" + port
+		}
+		gates = append(gates, startGate{check: HealthCheck{Type: CheckTCP, Address: port}})
+	}
+	for _, url := range config.WaitForHTTP {
+		gates = append(gates, startGate{check: HealthCheck{Type: CheckHTTP, URL: url}})
+	}
+	return gates
+}
+
+// waitForGates blocks the initial start until every wait_for_* condition
+// holds. Control commands are still handled while waiting; a manual start
+// or stop ends the wait. It returns false when the process must not be
+// started now.
+func (s *ProcessSupervisor) waitForGates(ctx context.Context) bool {
+	gates := startGates(s.config)
+	if len(gates) == 0 {
+		return true
+	}
+	timeout := time.Duration(defaultInt(s.config.WaitTimeout, defaultGateTimeout)) * time.Second
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(gateCheckInterval)
+	defer ticker.Stop()
+	defer s.updateStatus(func(st *ProcessStatus) { st.StartGate = "" })
+
+	var lastLogged string
+	for {
+		pending := ""
+		var reason error
+		for _, gate := range gates {
+			if err := gate.ready(s.config); err != nil {
+				pending, reason = gate.String(), err
+				break
+			}
+		}
+		if pending == "" {
+			logrus.Infof("Start gates of %s are open", s.config.Name)
+			return true
+		}
+		s.updateStatus(func(st *ProcessStatus) {
+			st.State = StateWaiting
+			st.StartGate = pending
+		})
+		if pending != lastLogged {
+			logrus.Infof("Process %s is waiting for %s before starting: %v", s.config.Name, pending, reason)
+			lastLogged = pending
+		}
+
+		if time.Now().After(deadline) {
+			stop := s.config.WaitTimeoutAction == GateTimeoutStop
+			action := "starting anyway"
+			if stop {
+				action = "leaving it stopped"
+			}
+			emitEvent(Event{
+				Severity: SeverityWarning,
+				Type:     "start_gate_timeout",
+				Process:  s.config.Name,
+				Message:  fmt.Sprintf("Process %s waited %v for %s, %s", s.config.Name, timeout, pending, action),
+				Details:  map[string]string{"gate": pending, "error": reason.Error()},
+			})
+			if stop {
+				s.stopped = true
+				s.updateStatus(func(st *ProcessStatus) { st.State = StateStopped })
+				return false
+			}
+			return true
+		}
+
+		select {
+		case <-ticker.C:
+		case cmd := <-s.commands:
+			cmd.reply <- s.handleCommand(cmd)
+			// 手动启动或停止后不再等待
+			if s.stopped || s.currentCmd != nil || s.trackedPID != 0 {
+				return false
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
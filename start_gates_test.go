@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitForGates(t *testing.T) {
+	gateCheckInterval = 20 * time.Millisecond
+	defer func() { gateCheckInterval = 2 * time.Second }()
+	ctx := context.Background()
+
+	// 文件出现后放行
+	license := filepath.Join(t.TempDir(), "license.lic")
+	s := NewProcessSupervisor(ProcessConfig{Name: "app", WaitForFile: []string{license}, WaitTimeout: 5})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		os.WriteFile(license, []byte("ok"), 0644)
+	}()
+	if !s.waitForGates(ctx) {
+		t.Fatal("gate should open once the file exists")
+	}
+	if st := s.Status(); st.StartGate != "" {
+		t.Errorf("start_gate not cleared: %q", st.StartGate)
+	}
+
+	// 超时且 wait_timeout_action 为 stop 时保持停止
+	s = NewProcessSupervisor(ProcessConfig{Name: "app", WaitForPort: []string{"127.0.0.1:1"}, WaitTimeout: 1, WaitTimeoutAction: GateTimeoutStop})
+	start := time.Now()
+	if s.waitForGates(ctx) {
+		t.Fatal("gate should time out")
+	}
+	if time.Since(start) < time.Second || !s.stopped || s.Status().State != StateStopped {
+		t.Errorf("unexpected state after timeout: stopped=%v status=%+v", s.stopped, s.Status())
+	}
+}
+
+func TestStartGates(t *testing.T) {
+	gates := startGates(ProcessConfig{
+		WaitForFile: []string{"/etc/app/license"},
+		WaitForPort: []string{"5432", "db:3306"},
+		WaitForHTTP: []string{"http://config/ready"},
+	})
+	want := []string{"file /etc/app/license", "tcp:127.0.0.1:5432", "tcp:db:3306", "http://config/ready"}
+	if len(gates) != len(want) {
+		t.Fatalf("got %d gates, want %d", len(gates), len(want))
+	}
+	for i, g := range gates {
+		if g.String() != want[i] {
+			t.Errorf("gate %d = %s, want %s", i, g, want[i])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// readinessPollInterval 启动期间两轮就绪检查之间的间隔
+var readinessPollInterval = time.Second
+
+// readinessChecks returns the checks a new instance has to pass within
+// startup_timeout: readiness_checks, or the port and health checks when
+// none are configured
+func readinessChecks(config ProcessConfig, pid int32) []namedCheck {
+	var checks []namedCheck
+	if len(config.ReadinessChecks) == 0 {
+		checks = portChecks(config)
+	}
+	list := config.ReadinessChecks
+	if len(list) == 0 {
+		list = config.HealthChecks
+	}
+	for _, check := range list {
+		check := check
+		checks = append(checks, namedCheck{name: check.String(), run: func() error { return check.run(config, pid) }})
+	}
+	return checks
+}
+
+// waitReady polls the readiness checks of the instance just started until
+// all of them pass, the process exits or startup_timeout expires
+func (s *ProcessSupervisor) waitReady() error {
+	config := s.config
+	timeout := time.Duration(config.StartupTimeout) * time.Second
+	started := time.Now()
+	deadline := started.Add(timeout)
+	checks := readinessChecks(config, s.currentPID())
+
+	for {
+		if s.hasExited() {
+			return fmt.Errorf("process exited during startup")
+		}
+		var failed error
+		if remaining := time.Until(deadline); remaining > 0 {
+			for _, r := range runChecks(checks, config.CheckConcurrency, remaining) {
+				if r.err != nil {
+					failed = fmt.Errorf("%s: %v", r.name, r.err)
+					break
+				}
+			}
+			if failed == nil && !s.protocolReady() {
+				failed = fmt.Errorf("process has not reported ready")
+			}
+			if failed == nil {
+				logrus.Infof("Process %s is ready after %s", config.Name, time.Since(started).Round(time.Millisecond))
+				return nil
+			}
+			logrus.Debugf("Process %s is not ready yet: %v", config.Name, failed)
+		}
+		if time.Until(deadline) <= readinessPollInterval {
+			if failed == nil {
+				failed = fmt.Errorf("no check finished in time")
+			}
+			return fmt.Errorf("not ready within %ds (%v)", config.StartupTimeout, failed)
+		}
+		time.Sleep(readinessPollInterval)
+	}
+}
+
+// failedStart stops an instance that did not become ready and counts the
+// failed start toward crash-loop detection
+func (s *ProcessSupervisor) failedStart(err error) {
+	logrus.Errorf("Process %s failed to start: %v", s.config.Name, err)
+	pid := 0
+	if s.currentCmd != nil && s.currentCmd.Process != nil {
+		pid = s.currentCmd.Process.Pid
+	}
+	s.kill()
+	s.closeFirewall()
+	s.updateStatus(func(st *ProcessStatus) {
+		st.State = StateDown
+		st.PID = 0
+	})
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "startup_failed",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Process %s failed to become ready: %v", s.config.Name, err),
+		Details:  map[string]string{"error": err.Error(), "pid": strconv.Itoa(pid)},
+	})
+	s.countCrash(err.Error())
+}
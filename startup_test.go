@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitReady(t *testing.T) {
+	defer func(d time.Duration) { readinessPollInterval = d }(readinessPollInterval)
+	readinessPollInterval = 10 * time.Millisecond
+
+	// 第三次请求起才就绪
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewProcessSupervisor(ProcessConfig{
+		Name:            "api",
+		StartupTimeout:  5,
+		HealthChecks:    []HealthCheck{parseHealthCheck("tcp:127.0.0.1:1")},
+		ReadinessChecks: []HealthCheck{parseHealthCheck(srv.URL)},
+	})
+	if err := s.waitReady(); err != nil {
+		t.Fatalf("waitReady: %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("readiness was checked %d times, want 3", n)
+	}
+}
+
+func TestFailedStartCountsTowardCrashLoop(t *testing.T) {
+	defer func(d time.Duration) { readinessPollInterval = d }(readinessPollInterval)
+	readinessPollInterval = 10 * time.Millisecond
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := NewProcessSupervisor(ProcessConfig{
+		Name:           "pm-startup-test-missing",
+		StartupTimeout: 1,
+		HealthChecks:   []HealthCheck{parseHealthCheck(srv.URL)},
+		CrashLoop:      CrashLoopConfig{Restarts: 2},
+	})
+	for i := 0; i < 2; i++ {
+		err := s.waitReady()
+		if err == nil || !strings.Contains(err.Error(), "not ready within 1s") {
+			t.Fatalf("waitReady = %v", err)
+		}
+		s.failedStart(err)
+	}
+
+	var types []string
+	for _, e := range sink.events {
+		if e.Process == "pm-startup-test-missing" {
+			types = append(types, e.Type)
+		}
+	}
+	if got := strings.Join(types, ","); got != "startup_failed,startup_failed,crash_loop" {
+		t.Errorf("events = %s", got)
+	}
+	if s.Status().State != StateDown {
+		t.Errorf("state = %s, want down", s.Status().State)
+	}
+}
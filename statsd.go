@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// StatsdConfig 推送指标到 statsd / DogStatsD（如 Datadog agent）
+type StatsdConfig struct {
+	Address   string            `yaml:"address"`   // agent 地址，如 127.0.0.1:8125，为空则不启用
+	Prefix    string            `yaml:"prefix"`    // 指标名前缀（默认 processmonitor.）
+	DogStatsD bool              `yaml:"dogstatsd"` // 使用 DogStatsD 标签格式（|#key:value）
+	Tags      map[string]string `yaml:"tags"`      // 附加到每个指标的全局标签
+	Interval  int               `yaml:"interval"`  // 进程CPU/内存采样间隔（秒，默认10）
+}
+
+// statsdSink sends metrics as UDP datagrams; errors are ignored as with any
+// fire-and-forget statsd client.
+type statsdSink struct {
+	config StatsdConfig
+	conn   net.Conn
+	mu     sync.Mutex
+}
+
+func newStatsdSink(config StatsdConfig) (*statsdSink, error) {
+	if config.Prefix == "" {
+		config.Prefix = "processmonitor."
+	}
+	// 主机名和主机静态标签附加到每个指标，tags 中的同名标签优先
+	tags := currentHost().metricTags()
+	for k, v := range config.Tags {
+		tags[k] = v
+	}
+	config.Tags = tags
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open statsd connection to %s: %v", config.Address, err)
+	}
+	return &statsdSink{config: config, conn: conn}, nil
+}
+
+func (s *statsdSink) Count(name string, value int64, tags map[string]string) {
+	s.send(name, fmt.Sprintf("%d|c", value), tags)
+}
+
+func (s *statsdSink) Gauge(name string, value float64, tags map[string]string) {
+	s.send(name, fmt.Sprintf("%g|g", value), tags)
+}
+
+func (s *statsdSink) Timing(name string, d time.Duration, tags map[string]string) {
+	s.send(name, fmt.Sprintf("%d|ms", d.Milliseconds()), tags)
+}
+
+// send formats one metric line. Plain statsd has no tags, so they are
+// appended to the metric name instead (name.key_value).
+func (s *statsdSink) send(name, value string, tags map[string]string) {
+	all := make(map[string]string, len(s.config.Tags)+len(tags))
+	for k, v := range s.config.Tags {
+		all[k] = v
+	}
+	for k, v := range tags {
+		all[k] = v
+	}
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	metric := s.config.Prefix + name
+	var line string
+	if s.config.DogStatsD {
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k+":"+all[k])
+		}
+		line = metric + ":" + value
+		if len(pairs) > 0 {
+			line += "|#" + strings.Join(pairs, ",")
+		}
+	} else {
+		for _, k := range keys {
+			metric += "." + sanitizeStatsdName(k) + "_" + sanitizeStatsdName(all[k])
+		}
+		line = metric + ":" + value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.Write([]byte(line))
+}
+
+// sanitizeStatsdName replaces characters that have a meaning in the statsd protocol
+func sanitizeStatsdName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '.', ' ', '\\', '/':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// runStatsd registers the statsd sink and periodically emits per-process
+// CPU and memory gauges until ctx is cancelled.
+func runStatsd(config StatsdConfig, manager *ProcessManager, ctx context.Context) {
+	sink, err := newStatsdSink(config)
+	if err != nil {
+		logrus.Errorf("Statsd: %v", err)
+		return
+	}
+	defer sink.conn.Close()
+	registerMetricsSink(sink)
+	logrus.Infof("Sending statsd metrics to %s", config.Address)
+
+	if config.Interval <= 0 {
+		config.Interval = 10
+	}
+	ticker := time.NewTicker(time.Duration(config.Interval) * time.Second)
+	defer ticker.Stop()
+
+	procs := make(map[int]*process.Process)
+	for {
+		select {
+		case <-ticker.C:
+			seen := make(map[int]bool)
+			for _, st := range manager.Statuses() {
+				tags := processTags(st.Name)
+				up := 0.0
+				if st.State == StateRunning {
+					up = 1
+				}
+				emitGauge("process.up", up, tags)
+				if st.PID == 0 {
+					continue
+				}
+				seen[st.PID] = true
+				p, ok := procs[st.PID]
+				if !ok {
+					if p, err = process.NewProcess(int32(st.PID)); err != nil {
+						continue
+					}
+					procs[st.PID] = p
+					// 第一次调用只建立CPU基准
+					p.Percent(0)
+					continue
+				}
+				if cpu, err := p.Percent(0); err == nil {
+					emitGauge("process.cpu_percent", cpu, tags)
+				}
+				if mem, err := p.MemoryInfo(); err == nil {
+					emitGauge("process.memory_mb", float64(mem.RSS)/1024/1024, tags)
+				}
+			}
+			for pid := range procs {
+				if !seen[pid] {
+					delete(procs, pid)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsdSinkFormat(t *testing.T) {
+	defer func(orig *HostInfo) { hostInfo = orig }(hostInfo)
+	hostInfo = &HostInfo{Hostname: "web01", OS: "linux", Tags: map[string]string{"site": "sh01", "env": "test"}}
+
+	tests := []struct {
+		name      string
+		dogstatsd bool
+		send      func(s *statsdSink)
+		want      string
+	}{
+		{
+			name:      "dogstatsd count with tags",
+			dogstatsd: true,
+			send:      func(s *statsdSink) { s.Count("restarts", 1, processTags("api.exe")) },
+			want:      "pm.restarts:1|c|#env:prod,host:web01,process:api.exe,site:sh01",
+		},
+		{
+			name: "plain statsd tags in name",
+			send: func(s *statsdSink) { s.Gauge("process.up", 1, processTags("api.exe")) },
+			want: "pm.process.up.env_prod.host_web01.process_api_exe.site_sh01:1|g",
+		},
+		{
+			name:      "timing in milliseconds",
+			dogstatsd: true,
+			send:      func(s *statsdSink) { s.Timing("check.latency", 1500*time.Millisecond, nil) },
+			want:      "pm.check.latency:1500|ms|#env:prod,host:web01,site:sh01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			sink, err := newStatsdSink(StatsdConfig{
+				Address:   listener.LocalAddr().String(),
+				Prefix:    "pm.",
+				DogStatsD: tt.dogstatsd,
+				Tags:      map[string]string{"env": "prod"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sink.conn.Close()
+
+			tt.send(sink)
+			buf := make([]byte, 512)
+			listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, _, err := listener.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf[:n]); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatusPageConfig 只读公开状态页：单独的端口，无需认证，只显示选定进程的健康状态，
+// 可以提供给客户的运维团队而不暴露控制接口
+type StatusPageConfig struct {
+	Listen       string              `yaml:"listen"`        // 监听地址，如 "0.0.0.0:9600"（为空则不启动）
+	Title        string              `yaml:"title"`         // 页面标题（默认 Service Status）
+	Processes    []StatusPageProcess `yaml:"processes"`     // 显示的进程，未列出的进程不会出现在状态页上
+	ShowUptime   bool                `yaml:"show_uptime"`   // 显示已运行时长
+	ShowRestarts bool                `yaml:"show_restarts"` // 显示重启次数和最近一次重启时间
+	Refresh      int                 `yaml:"refresh"`       // 页面自动刷新间隔（秒，默认30）
+}
+
+// StatusPageProcess 状态页上显示的一个进程
+type StatusPageProcess struct {
+	Name        string `yaml:"name"`         // processes 中的进程名
+	DisplayName string `yaml:"display_name"` // 页面上显示的名称（默认为进程名）
+}
+
+// 状态页上的公开状态，不区分内部的各种状态
+const (
+	PublicOperational = "operational" // 运行中且健康
+	PublicDegraded    = "degraded"    // 健康检查失败或正在启动/重启
+	PublicDown        = "down"        // 未运行或已熔断
+	PublicMaintenance = "maintenance" // 手动停止或暂停监控
+	PublicUnknown     = "unknown"     // 进程未被监控（未启用或已从配置中删除）
+)
+
+// PublicStatus is one process as shown on the status page
+type PublicStatus struct {
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	UptimeSeconds *int64     `json:"uptime_seconds,omitempty"`
+	Restarts      *int       `json:"restarts,omitempty"`
+	LastRestart   *time.Time `json:"last_restart,omitempty"`
+}
+
+// StatusPageResponse is the body returned by GET /status.json of the status page
+type StatusPageResponse struct {
+	Title     string         `json:"title"`
+	Status    string         `json:"status"` // 所有进程中最差的状态
+	Updated   time.Time      `json:"updated"`
+	Processes []PublicStatus `json:"processes"`
+}
+
+// publicState maps the internal state of a process to its public status
+func publicState(status ProcessStatus) string {
+	switch status.State {
+	case StateRunning:
+		if status.Health == HealthUnhealthy {
+			return PublicDegraded
+		}
+		return PublicOperational
+	case StateStarting, StateRestarting, StateWaiting:
+		return PublicDegraded
+	case StateStopped, StatePaused:
+		return PublicMaintenance
+	default:
+		return PublicDown
+	}
+}
+
+// publicRank orders public statuses from best to worst
+var publicRank = map[string]int{
+	PublicOperational: 0,
+	PublicMaintenance: 1,
+	PublicUnknown:     2,
+	PublicDegraded:    3,
+	PublicDown:        4,
+}
+
+// StatusPageServer serves the read-only status page
+type StatusPageServer struct {
+	config  StatusPageConfig
+	manager *ProcessManager
+	mux     *http.ServeMux
+}
+
+// NewStatusPageServer creates the status page server; it has no routes
+// other than the page and its JSON form
+func NewStatusPageServer(config StatusPageConfig, manager *ProcessManager) *StatusPageServer {
+	if config.Title == "" {
+		config.Title = "Service Status"
+	}
+	config.Refresh = defaultInt(config.Refresh, 30)
+	s := &StatusPageServer{config: config, manager: manager, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/", s.handlePage)
+	s.mux.HandleFunc("/status.json", s.handleJSON)
+	return s
+}
+
+// Run serves the status page until ctx is cancelled
+func (s *StatusPageServer) Run(ctx context.Context) {
+	server := &http.Server{
+		Addr:              s.config.Listen,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logrus.Infof("Status page listening on %s", s.config.Listen)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logrus.Errorf("Status page server failed: %v", err)
+	}
+}
+
+// ServeHTTP only allows reading; every response forbids caching and framing
+func (s *StatusPageServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	s.mux.ServeHTTP(w, r)
+}
+
+// snapshot returns the public status of the selected processes
+func (s *StatusPageServer) snapshot(now time.Time) StatusPageResponse {
+	resp := StatusPageResponse{Title: s.config.Title, Status: PublicOperational, Updated: now}
+	for _, p := range s.config.Processes {
+		entry := PublicStatus{Name: p.DisplayName, Status: PublicUnknown}
+		if entry.Name == "" {
+			entry.Name = p.Name
+		}
+		if sup, ok := s.manager.Get(p.Name); ok {
+			status := sup.Status()
+			entry.Status = publicState(status)
+			if s.config.ShowUptime && status.State == StateRunning && !status.StartedAt.IsZero() {
+				uptime := int64(now.Sub(status.StartedAt).Seconds())
+				entry.UptimeSeconds = &uptime
+			}
+			if s.config.ShowRestarts {
+				entry.Restarts = &status.Restarts
+				if !status.LastRestart.IsZero() {
+					entry.LastRestart = &status.LastRestart
+				}
+			}
+		}
+		if publicRank[entry.Status] > publicRank[resp.Status] {
+			resp.Status = entry.Status
+		}
+		resp.Processes = append(resp.Processes, entry)
+	}
+	if resp.Processes == nil {
+		resp.Processes = []PublicStatus{}
+	}
+	return resp
+}
+
+func (s *StatusPageServer) handleJSON(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.snapshot(time.Now()))
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"uptime": func(seconds int64) string { return formatUptime(time.Duration(seconds) * time.Second) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{.Title}}</title>
+<style>
+body{font-family:Segoe UI,Helvetica,Arial,sans-serif;max-width:760px;margin:40px auto;color:#222}
+.summary{padding:14px 18px;border-radius:4px;color:#fff;font-size:18px}
+table{width:100%;border-collapse:collapse;margin-top:20px}
+td,th{padding:10px 8px;border-bottom:1px solid #ddd;text-align:left}
+.dot{display:inline-block;width:10px;height:10px;border-radius:50%;margin-right:6px}
+.operational{background:#3ba55c}.degraded{background:#dfb317}.down{background:#e05d44}
+.maintenance{background:#5b8dd6}.unknown{background:#9f9f9f}
+footer{margin-top:16px;color:#888;font-size:12px}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="summary {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else}}Some services are {{.Status}}{{end}}</div>
+<table>
+<tr><th>Service</th><th>Status</th>{{if .ShowUptime}}<th>Uptime</th>{{end}}{{if .ShowRestarts}}<th>Restarts</th><th>Last restart</th>{{end}}</tr>
+{{range .Processes}}<tr><td>{{.Name}}</td><td><span class="dot {{.Status}}"></span>{{.Status}}</td>
+{{- if $.ShowUptime}}<td>{{with .UptimeSeconds}}{{uptime .}}{{else}}-{{end}}</td>{{end}}
+{{- if $.ShowRestarts}}<td>{{with .Restarts}}{{.}}{{end}}</td><td>{{with .LastRestart}}{{.Format "2006-01-02 15:04:05 MST"}}{{else}}-{{end}}</td>{{end}}</tr>
+{{end}}</table>
+<footer>Updated {{.Updated.Format "2006-01-02 15:04:05 MST"}}</footer>
+</body>
+</html>
+`))
+
+func (s *StatusPageServer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	data := struct {
+		StatusPageResponse
+		Refresh      int
+		ShowUptime   bool
+		ShowRestarts bool
+	}{s.snapshot(time.Now()), s.config.Refresh, s.config.ShowUptime, s.config.ShowRestarts}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusPageTemplate.Execute(w, data); err != nil {
+		logrus.Debugf("Failed to render status page: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicState(t *testing.T) {
+	tests := []struct {
+		status ProcessStatus
+		want   string
+	}{
+		{ProcessStatus{State: StateRunning}, PublicOperational},
+		{ProcessStatus{State: StateRunning, Health: HealthUnhealthy}, PublicDegraded},
+		{ProcessStatus{State: StateRestarting}, PublicDegraded},
+		{ProcessStatus{State: StateDown}, PublicDown},
+		{ProcessStatus{State: StateFailed}, PublicDown},
+		{ProcessStatus{State: StatePaused}, PublicMaintenance},
+	}
+	for _, tt := range tests {
+		if got := publicState(tt.status); got != tt.want {
+			t.Errorf("publicState(%+v) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	manager := NewProcessManager(Config{Processes: []ProcessConfig{
+		{Name: "api_server.exe", Enable: true},
+		{Name: "internal_tool.exe", Enable: true},
+	}})
+	server := NewStatusPageServer(StatusPageConfig{
+		Processes: []StatusPageProcess{
+			{Name: "api_server.exe", DisplayName: "Customer <API>"},
+			{Name: "removed.exe"},
+		},
+	}, manager)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/", http.StatusOK},
+		{http.MethodHead, "/", http.StatusOK},
+		{http.MethodGet, "/status.json", http.StatusOK},
+		{http.MethodGet, "/processes/api_server.exe", http.StatusNotFound},
+		{http.MethodPost, "/", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/processes/api_server.exe/restart", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status.json", nil))
+	var resp StatusPageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Processes) != 2 || resp.Processes[0].Name != "Customer <API>" || resp.Processes[1].Status != PublicUnknown {
+		t.Errorf("unexpected processes: %+v", resp.Processes)
+	}
+	if resp.Processes[0].Restarts != nil || resp.Processes[0].UptimeSeconds != nil {
+		t.Errorf("restarts and uptime shown without show_restarts/show_uptime: %+v", resp.Processes[0])
+	}
+	if strings.Contains(rec.Body.String(), "internal_tool") {
+		t.Errorf("unselected process exposed: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Customer &lt;API&gt;") || strings.Contains(body, "api_server.exe") {
+		t.Errorf("page should show the escaped display name only:\n%s", body)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultStopTimeout 配置了优雅停止但未设置 stop_timeout 时的等待时间（秒）
+const defaultStopTimeout = 10
+
+// gracefulStopConfigured reports whether the process asks for a graceful stop
+func gracefulStopConfigured(config ProcessConfig) bool {
+	return config.StopSignal != "" || config.StopCommand != "" || config.StopTimeout > 0
+}
+
+func stopTimeout(config ProcessConfig) time.Duration {
+	if config.StopTimeout > 0 {
+		return seconds(config.StopTimeout)
+	}
+	return seconds(defaultStopTimeout)
+}
+
+// requestStop asks pid to shut down: stop_command if configured, otherwise
+// stop_signal (or the platform default for a graceful close).
+func requestStop(config ProcessConfig, pid int) {
+	if config.StopCommand != "" {
+		logrus.Infof("Running stop command for %s (PID: %d)", config.Name, pid)
+		if err := runHook(config, "stop", config.StopCommand, "stop requested", pid); err != nil {
+			logrus.Warnf("Process %s: %v", config.Name, err)
+		}
+		return
+	}
+	logrus.Infof("Sending %s to %s (PID: %d)", stopSignalName(config), config.Name, pid)
+	if err := sendStopSignal(config, pid); err != nil {
+		logrus.Warnf("Failed to send stop signal to %s (PID: %d): %v", config.Name, pid, err)
+	}
+}
+
+// stopCommand stops a child started by the monitor: it first requests a
+// graceful shutdown and waits up to stop_timeout, then falls back to Kill.
+// exited is closed by the goroutine that reaps cmd. With kill_process_tree
+// the descendants still running afterwards are killed as well.
+func stopCommand(config ProcessConfig, cmd *exec.Cmd, exited <-chan struct{}, ack <-chan struct{}) {
+	defer killProcessTree(config, cmd.Process.Pid)
+	if (gracefulStopConfigured(config) || ack != nil) && waitForStop(config, cmd, exited, ack) {
+		return
+	}
+
+	cmd.Process.Kill()
+	select {
+	case <-exited:
+	case <-time.After(seconds(opTimeouts.Kill)):
+		// 无法结束的进程（如卡在不可中断的I/O中）被放弃
+		reportDeadlineExceeded("wait for "+config.Name, seconds(opTimeouts.Kill))
+		logrus.Errorf("Process %s (PID: %d) did not exit after kill", config.Name, cmd.Process.Pid)
+	}
+}
+
+// waitForStop requests a graceful shutdown and reports whether the child
+// exited within stop_timeout. With the supervision protocol (ack not nil)
+// a child that does not acknowledge within stop_ack_timeout is considered
+// hung and killed right away.
+func waitForStop(config ProcessConfig, cmd *exec.Cmd, exited <-chan struct{}, ack <-chan struct{}) bool {
+	requestStop(config, cmd.Process.Pid)
+	timeout := time.After(stopTimeout(config))
+	if ack != nil {
+		ackTimeout := seconds(defaultInt(config.Protocol.StopAckTimeout, defaultStopAckTimeout))
+		select {
+		case <-exited:
+			logrus.Infof("Process %s (PID: %d) stopped gracefully", config.Name, cmd.Process.Pid)
+			return true
+		case <-ack:
+			logrus.Infof("Process %s (PID: %d) acknowledged the stop request", config.Name, cmd.Process.Pid)
+		case <-time.After(ackTimeout):
+			logrus.Warnf("Process %s (PID: %d) did not acknowledge the stop request within %v, killing it",
+				config.Name, cmd.Process.Pid, ackTimeout)
+			return false
+		}
+	}
+	select {
+	case <-exited:
+		logrus.Infof("Process %s (PID: %d) stopped gracefully", config.Name, cmd.Process.Pid)
+		return true
+	case <-timeout:
+		logrus.Warnf("Process %s (PID: %d) did not stop within %v, killing it",
+			config.Name, cmd.Process.Pid, stopTimeout(config))
+		return false
+	}
+}
+
+// stopPID stops an adopted instance the way stopCommand stops a child:
+// graceful stop first, then kill once stop_timeout has passed. Without a
+// graceful stop configured it is killed right away.
+func stopPID(config ProcessConfig, pid int32) {
+	if gracefulStopConfigured(config) {
+		requestStop(config, int(pid))
+		if waitForPIDExit(config, pid, stopTimeout(config)) {
+			logrus.Infof("Process %s (PID: %d) stopped gracefully", config.Name, pid)
+			return
+		}
+		logrus.Warnf("Process %s (PID: %d) did not stop within %v, killing it", config.Name, pid, stopTimeout(config))
+	}
+
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return
+	}
+	if err := killWithTimeout(config.Name, p); err != nil {
+		logrus.Errorf("Failed to kill process %s (PID: %d): %v", config.Name, pid, err)
+		return
+	}
+	if !waitForPIDExit(config, pid, seconds(opTimeouts.Kill)) {
+		logrus.Errorf("Process %s (PID: %d) did not exit after kill", config.Name, pid)
+	}
+}
+
+// waitForPIDExit reports whether pid stopped being an instance of config
+// within timeout
+func waitForPIDExit(config ProcessConfig, pid int32, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for trackedAlive(config, pid) {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return true
+}
+
+// stopExistingProcesses gracefully stops instances not started by this
+// monitor (found by name), then kills whatever is left after stop_timeout.
+func stopExistingProcesses(config ProcessConfig) {
+	if gracefulStopConfigured(config) {
+		pids, err := configPIDs(config)
+		if err == nil && len(pids) > 0 {
+			for _, pid := range pids {
+				requestStop(config, int(pid))
+			}
+			deadline := time.Now().Add(stopTimeout(config))
+			for time.Now().Before(deadline) {
+				if running, err := isConfigRunning(config); err == nil && !running {
+					logrus.Infof("Process %s stopped gracefully", config.Name)
+					return
+				}
+				time.Sleep(500 * time.Millisecond)
+			}
+			logrus.Warnf("Process %s did not stop within %v, killing it", config.Name, stopTimeout(config))
+		}
+	}
+	killConfigProcesses(config)
+}
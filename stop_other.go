@@ -0,0 +1,43 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+var stopSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+func stopSignalName(config ProcessConfig) string {
+	if config.StopSignal == "" {
+		return "SIGTERM"
+	}
+	name := strings.ToUpper(config.StopSignal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	return name
+}
+
+// sendStopSignal sends stop_signal (default SIGTERM) to pid. When pid leads
+// its own process group (process_group group or session), the whole group
+// gets the signal, like a terminal sends Ctrl+C to a foreground job.
+func sendStopSignal(config ProcessConfig, pid int) error {
+	sig, ok := stopSignals[stopSignalName(config)]
+	if !ok {
+		return fmt.Errorf("unsupported stop_signal %q", config.StopSignal)
+	}
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return syscall.Kill(-pid, sig)
+	}
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,94 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startStopTarget starts a shell script and returns a channel closed once it
+// has been reaped, like the supervisor does for its children
+func startStopTarget(t *testing.T, script string) (*exec.Cmd, <-chan struct{}) {
+	cmd := exec.Command("sh", "-c", script)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	// 等待脚本设置好 trap
+	time.Sleep(200 * time.Millisecond)
+	return cmd, exited
+}
+
+func TestStopCommandGraceful(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ProcessConfig
+		script string
+	}{
+		{"stop signal", ProcessConfig{Name: "sig", StopSignal: "INT", StopTimeout: 5}, "trap 'exit 0' INT; trap '' TERM; while :; do sleep 0.1; done"},
+		{"stop command", ProcessConfig{Name: "cmd", StopCommand: "kill -USR1 $PM_PID", StopTimeout: 5}, "trap 'exit 0' USR1; trap '' TERM; while :; do sleep 0.1; done"},
+	}
+	for _, tt := range tests {
+		cmd, exited := startStopTarget(t, tt.script)
+		stopCommand(tt.config, cmd, exited, nil)
+		select {
+		case <-exited:
+		default:
+			t.Fatalf("%s: process still running", tt.name)
+		}
+		// 优雅退出时退出码为 0，而不是被 SIGKILL 结束
+		if !cmd.ProcessState.Success() {
+			t.Errorf("%s: exit = %v, want graceful exit", tt.name, cmd.ProcessState)
+		}
+	}
+}
+
+func TestStopCommandFallsBackToKill(t *testing.T) {
+	cmd, exited := startStopTarget(t, "trap '' TERM; while :; do sleep 0.1; done")
+	started := time.Now()
+	stopCommand(ProcessConfig{Name: "stubborn", StopTimeout: 1}, cmd, exited, nil)
+	if elapsed := time.Since(started); elapsed < time.Second || elapsed > 4*time.Second {
+		t.Errorf("stop took %v, want about stop_timeout", elapsed)
+	}
+	if cmd.ProcessState == nil || cmd.ProcessState.String() != "signal: killed" {
+		t.Errorf("exit = %v, want killed", cmd.ProcessState)
+	}
+}
+
+func TestStopCommandWithoutAck(t *testing.T) {
+	// 监督协议下未确认停止请求的进程在 stop_ack_timeout 后立即被结束
+	cmd, exited := startStopTarget(t, "trap '' TERM; while :; do sleep 0.1; done")
+	config := ProcessConfig{Name: "silent", StopTimeout: 30}
+	config.Protocol.StopAckTimeout = 1
+	started := time.Now()
+	stopCommand(config, cmd, exited, make(chan struct{}))
+	if elapsed := time.Since(started); elapsed > 4*time.Second {
+		t.Errorf("stop took %v, want stop_ack_timeout instead of stop_timeout", elapsed)
+	}
+	if cmd.ProcessState == nil || cmd.ProcessState.String() != "signal: killed" {
+		t.Errorf("exit = %v, want killed", cmd.ProcessState)
+	}
+}
+
+func TestStopSignalName(t *testing.T) {
+	tests := []struct{ signal, want string }{
+		{"", "SIGTERM"},
+		{"int", "SIGINT"},
+		{"SIGHUP", "SIGHUP"},
+	}
+	for _, tt := range tests {
+		if got := stopSignalName(ProcessConfig{StopSignal: tt.signal}); got != tt.want {
+			t.Errorf("stopSignalName(%q) = %q, want %q", tt.signal, got, tt.want)
+		}
+	}
+	if err := sendStopSignal(ProcessConfig{StopSignal: "SIGSEGV"}, 1); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("SIGSEGV: err = %v, want unsupported", err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGracefulStopConfigured(t *testing.T) {
+	tests := []struct {
+		config  ProcessConfig
+		want    bool
+		timeout time.Duration
+	}{
+		{ProcessConfig{}, false, 10 * time.Second},
+		{ProcessConfig{StopSignal: "SIGINT"}, true, 10 * time.Second},
+		{ProcessConfig{StopCommand: "app --shutdown"}, true, 10 * time.Second},
+		{ProcessConfig{StopTimeout: 30}, true, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := gracefulStopConfigured(tt.config); got != tt.want {
+			t.Errorf("%+v: graceful = %v, want %v", tt.config, got, tt.want)
+		}
+		if got := stopTimeout(tt.config); got != tt.timeout {
+			t.Errorf("%+v: stop timeout = %v, want %v", tt.config, got, tt.timeout)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+var procPostMessageW = user32.NewProc("PostMessageW")
+
+const wmClose = 0x0010
+
+func stopSignalName(config ProcessConfig) string {
+	switch strings.ToUpper(config.StopSignal) {
+	case "WM_CLOSE":
+		return "WM_CLOSE"
+	case "":
+		return "WM_CLOSE/CTRL_BREAK"
+	default:
+		return "CTRL_BREAK"
+	}
+}
+
+// sendStopSignal asks pid to shut down. GUI programs get WM_CLOSE on their
+// main windows; console programs get CTRL_BREAK (SIGINT/SIGTERM are mapped
+// to it), which reaches the child because it runs in its own process group.
+func sendStopSignal(config ProcessConfig, pid int) error {
+	signal := strings.ToUpper(config.StopSignal)
+	switch signal {
+	case "", "WM_CLOSE":
+		hwnds, err := mainWindows([]int32{int32(pid)})
+		if err == nil && len(hwnds) > 0 {
+			for _, hwnd := range hwnds {
+				procPostMessageW.Call(uintptr(hwnd), wmClose, 0, 0)
+			}
+			return nil
+		}
+		if signal == "WM_CLOSE" {
+			return fmt.Errorf("process has no main window to close")
+		}
+		return generateCtrlBreak(pid)
+	case "SIGINT", "SIGTERM", "CTRL_BREAK", "CTRL_C":
+		return generateCtrlBreak(pid)
+	default:
+		return fmt.Errorf("unsupported stop_signal %q", config.StopSignal)
+	}
+}
+
+// generateCtrlBreak sends CTRL_BREAK to the process group pid. CTRL_C cannot
+// be used: it is disabled for processes created with CREATE_NEW_PROCESS_GROUP.
+func generateCtrlBreak(pid int) error {
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(pid))
+}
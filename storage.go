@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 持久化后端
+const (
+	StorageMemory = "memory" // 只保存在内存中（默认，监控程序重启后丢失）
+	StorageFile   = "file"   // 目录中的 JSON 文件，适合嵌入式设备等精简环境
+	StorageBolt   = "bolt"   // 嵌入式数据库（bbolt），适合服务器
+	StorageHTTP   = "http"   // 远程存储服务，多台主机集中保存
+)
+
+const (
+	defaultStorageRetention       = 30
+	defaultStorageMetricsInterval = 60
+	defaultStorageTimeout         = 5
+)
+
+// StorageConfig 选择事件历史、进程状态和指标历史的持久化后端
+type StorageConfig struct {
+	Backend         string `yaml:"backend"`          // memory、file、bolt 或 http；为空时使用 event_history（bolt）或内存
+	Path            string `yaml:"path"`             // file：数据目录；bolt：数据库文件
+	URL             string `yaml:"url"`              // http：存储服务地址，如 https://monitor-store.corp/api/hosts/web01
+	Token           string `yaml:"token"`            // http：Bearer 令牌
+	Timeout         int    `yaml:"timeout"`          // http：单次请求超时（秒，默认5）
+	RetentionDays   int    `yaml:"retention_days"`   // 事件和指标的保留天数（默认30；http 由服务端清理）
+	MaxEvents       int    `yaml:"max_events"`       // 最多保留的事件数量（0表示不限）
+	MetricsInterval int    `yaml:"metrics_interval"` // 指标快照的保存间隔（秒，默认60；-1不保存）
+}
+
+// Storage is a persistence backend of the monitor. HandleEvent, SaveState
+// and RecordMetrics must not block for long; remote backends queue internally.
+type Storage interface {
+	eventRecorder
+	// LoadState returns the state saved under key, nil when there is none
+	LoadState(key string) ([]byte, error)
+	SaveState(key string, data []byte) error
+	RecordMetrics(samples []MetricSample) error
+	// Run maintains the backend (retention, queued writes) until ctx is
+	// cancelled, then closes it
+	Run(ctx context.Context)
+}
+
+func (c StorageConfig) validate() error {
+	switch c.Backend {
+	case "":
+		if c.Path != "" || c.URL != "" {
+			return fmt.Errorf("storage.backend is required with storage.path or storage.url")
+		}
+		return nil
+	case StorageMemory:
+	case StorageFile, StorageBolt:
+		if c.Path == "" {
+			return fmt.Errorf("storage backend %s requires path", c.Backend)
+		}
+	case StorageHTTP:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("storage backend http requires an http:// or https:// url")
+		}
+	default:
+		return fmt.Errorf("unknown storage.backend %q: use memory, file, bolt or http", c.Backend)
+	}
+	if c.RetentionDays < 0 || c.MaxEvents < 0 || c.Timeout < 0 {
+		return fmt.Errorf("storage: retention_days, max_events and timeout must not be negative")
+	}
+	if c.MetricsInterval < -1 {
+		return fmt.Errorf("storage: metrics_interval must be -1 or more")
+	}
+	return nil
+}
+
+// storageConfig returns the effective storage settings: event_history.path
+// is the older way to select the bolt backend
+func storageConfig(config Config) StorageConfig {
+	if config.Storage.Backend == "" && config.EventHistory.Path != "" {
+		return StorageConfig{
+			Backend:       StorageBolt,
+			Path:          config.EventHistory.Path,
+			RetentionDays: config.EventHistory.RetentionDays,
+			MaxEvents:     config.EventHistory.MaxEvents,
+		}
+	}
+	return config.Storage
+}
+
+// openStorage opens the backend of config; eventBuffer is the number of
+// events the memory backend keeps
+func openStorage(config StorageConfig, eventBuffer int) (Storage, error) {
+	switch config.Backend {
+	case StorageFile:
+		return openFileStorage(config)
+	case StorageBolt:
+		return openBoltEventStore(EventHistoryConfig{Path: config.Path, RetentionDays: config.RetentionDays, MaxEvents: config.MaxEvents})
+	case StorageHTTP:
+		return newHTTPStorage(config), nil
+	default:
+		return newMemoryStorage(eventBuffer), nil
+	}
+}
+
+// stateStorage keeps the process state between runs of the monitor; it is
+// the same backend as eventHistory
+var stateStorage Storage = newMemoryStorage(defaultEventBuffer)
+
+// memoryStorage keeps the recent events and the state in memory only
+type memoryStorage struct {
+	*memoryEventStore
+	mu    sync.Mutex
+	state map[string][]byte
+}
+
+func newMemoryStorage(capacity int) *memoryStorage {
+	return &memoryStorage{memoryEventStore: newMemoryEventStore(capacity), state: make(map[string][]byte)}
+}
+
+func (m *memoryStorage) LoadState(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state[key], nil
+}
+
+func (m *memoryStorage) SaveState(key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state[key] = data
+	return nil
+}
+
+// RecordMetrics discards the samples: the current values are available
+// from /metrics
+func (m *memoryStorage) RecordMetrics([]MetricSample) error { return nil }
+
+func (m *memoryStorage) Run(ctx context.Context) { <-ctx.Done() }
+
+// MetricSample is one metric of a snapshot saved by the metrics recorder:
+// the sum of a counter over the interval, the last value of a gauge or the
+// mean of a timing
+type MetricSample struct {
+	Time  time.Time         `json:"time"`
+	Name  string            `json:"name"`
+	Kind  string            `json:"kind"` // count, gauge, timing
+	Value float64           `json:"value"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+// metricsRecorder is a MetricsSink that aggregates the metrics of an
+// interval and saves them to the storage backend as one snapshot
+type metricsRecorder struct {
+	storage Storage
+	mu      sync.Mutex
+	samples map[string]*MetricSample
+	timings map[string]int // timing 的样本数，用于计算平均值
+}
+
+func newMetricsRecorder(storage Storage) *metricsRecorder {
+	return &metricsRecorder{storage: storage, samples: make(map[string]*MetricSample), timings: make(map[string]int)}
+}
+
+func metricKey(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + tags[k])
+	}
+	return b.String()
+}
+
+func (r *metricsRecorder) sample(kind, name string, tags map[string]string) (*MetricSample, string) {
+	key := kind + "\x00" + metricKey(name, tags)
+	s := r.samples[key]
+	if s == nil {
+		s = &MetricSample{Name: name, Kind: kind, Tags: tags}
+		r.samples[key] = s
+	}
+	return s, key
+}
+
+// Count implements MetricsSink
+func (r *metricsRecorder) Count(name string, value int64, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, _ := r.sample("count", name, tags)
+	s.Value += float64(value)
+}
+
+// Gauge implements MetricsSink
+func (r *metricsRecorder) Gauge(name string, value float64, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, _ := r.sample("gauge", name, tags)
+	s.Value = value
+}
+
+// Timing implements MetricsSink
+func (r *metricsRecorder) Timing(name string, d time.Duration, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, key := r.sample("timing", name, tags)
+	n := r.timings[key]
+	// 以毫秒保存平均值
+	s.Value = (s.Value*float64(n) + float64(d)/float64(time.Millisecond)) / float64(n+1)
+	r.timings[key] = n + 1
+}
+
+// flush saves the snapshot of the interval ending at now and starts a new one
+func (r *metricsRecorder) flush(now time.Time) {
+	r.mu.Lock()
+	samples := make([]MetricSample, 0, len(r.samples))
+	for _, s := range r.samples {
+		s.Time = now
+		samples = append(samples, *s)
+	}
+	r.samples, r.timings = make(map[string]*MetricSample), make(map[string]int)
+	r.mu.Unlock()
+	if len(samples) == 0 {
+		return
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Name != samples[j].Name {
+			return samples[i].Name < samples[j].Name
+		}
+		return metricKey("", samples[i].Tags) < metricKey("", samples[j].Tags)
+	})
+	if err := r.storage.RecordMetrics(samples); err != nil {
+		internalErrorf("storage", "Failed to save metrics: %v", err)
+	}
+}
+
+// Run saves a snapshot every interval until ctx is cancelled
+func (r *metricsRecorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.flush(now)
+		case <-ctx.Done():
+			r.flush(time.Now())
+			return
+		}
+	}
+}
+
+// persistedStatus is the part of ProcessStatus kept in the storage backend,
+// so restart counters and the last exit code survive a restart of the monitor
+type persistedStatus struct {
+	Restarts          int       `json:"restarts"`
+	LastRestart       time.Time `json:"last_restart,omitempty"`
+	LastRestartReason string    `json:"last_restart_reason,omitempty"`
+	LastExitCode      *int      `json:"last_exit_code,omitempty"`
+}
+
+func processStateKey(config ProcessConfig) string {
+	if config.sessionScoped {
+		return fmt.Sprintf("process/%s.%d", config.Name, config.sessionID)
+	}
+	return "process/" + config.Name
+}
+
+// loadProcessState restores the persisted part of the status of a process
+func loadProcessState(config ProcessConfig, status *ProcessStatus) {
+	data, err := stateStorage.LoadState(processStateKey(config))
+	if err != nil {
+		logrus.Warnf("Failed to load the saved state of %s: %v", config.Name, err)
+		return
+	}
+	if data == nil {
+		return
+	}
+	var saved persistedStatus
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logrus.Warnf("Ignoring the invalid saved state of %s: %v", config.Name, err)
+		return
+	}
+	status.Restarts = saved.Restarts
+	status.LastRestart = saved.LastRestart
+	status.LastRestartReason = saved.LastRestartReason
+	status.LastExitCode = saved.LastExitCode
+}
+
+// saveState persists the restart counters and last exit code of the process
+func (s *ProcessSupervisor) saveState() {
+	st := s.Status()
+	data, _ := json.Marshal(persistedStatus{
+		Restarts:          st.Restarts,
+		LastRestart:       st.LastRestart,
+		LastRestartReason: st.LastRestartReason,
+		LastExitCode:      st.LastExitCode,
+	})
+	if err := stateStorage.SaveState(processStateKey(s.config), data); err != nil {
+		internalErrorf("storage", "Failed to save the state of %s: %v", s.config.Name, err)
+	}
+}
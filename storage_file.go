@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fileStorage is the file storage backend for small installations: the
+// events are lines of events.jsonl, each state is a file under state/ and
+// the metric snapshots are appended to one file per day under metrics/
+type fileStorage struct {
+	dir       string
+	retention time.Duration
+	maxEvents int
+
+	mu     sync.Mutex // 保护 events.jsonl 和 nextID
+	nextID uint64
+}
+
+func openFileStorage(config StorageConfig) (*fileStorage, error) {
+	for _, dir := range []string{config.Path, filepath.Join(config.Path, "state"), filepath.Join(config.Path, "metrics")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %v", err)
+		}
+	}
+	f := &fileStorage{
+		dir:       config.Path,
+		retention: time.Duration(defaultInt(config.RetentionDays, defaultStorageRetention)) * 24 * time.Hour,
+		maxEvents: config.MaxEvents,
+	}
+	events, err := f.readEvents()
+	if err != nil {
+		return nil, err
+	}
+	f.nextID = 1
+	if len(events) > 0 {
+		f.nextID = events[len(events)-1].ID + 1
+	}
+	return f, nil
+}
+
+func (f *fileStorage) eventsPath() string {
+	return filepath.Join(f.dir, "events.jsonl")
+}
+
+// readEvents returns the events of events.jsonl, oldest first. A line cut
+// short by a crash is skipped.
+func (f *fileStorage) readEvents() ([]Event, error) {
+	data, err := os.ReadFile(f.eventsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event history: %v", err)
+	}
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
+
+func appendLines(path string, lines ...[]byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	_, err = file.Write(buf.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// HandleEvent implements EventSink
+func (f *fileStorage) HandleEvent(e Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e.ID = f.nextID
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = appendLines(f.eventsPath(), data)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to record event %s in history: %v", e.Type, err)
+		return
+	}
+	f.nextID++
+}
+
+// Query implements EventStore
+func (f *fileStorage) Query(filter EventFilter) ([]Event, bool) {
+	f.mu.Lock()
+	events, err := f.readEvents()
+	f.mu.Unlock()
+	if err != nil {
+		logrus.Errorf("Failed to query event history: %v", err)
+		return nil, false
+	}
+	var result []Event
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if filter.Before > 0 && e.ID >= filter.Before {
+			continue
+		}
+		if !filter.matches(e) {
+			continue
+		}
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			return result, true
+		}
+		result = append(result, e)
+	}
+	return result, false
+}
+
+func (f *fileStorage) statePath(key string) string {
+	return filepath.Join(f.dir, "state", safeFileName(key)+".json")
+}
+
+// LoadState implements Storage
+func (f *fileStorage) LoadState(key string) ([]byte, error) {
+	data, err := os.ReadFile(f.statePath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// SaveState implements Storage
+func (f *fileStorage) SaveState(key string, data []byte) error {
+	return writeFileAtomic(f.statePath(key), data)
+}
+
+// RecordMetrics implements Storage, one line per sample
+func (f *fileStorage) RecordMetrics(samples []MetricSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	lines := make([][]byte, 0, len(samples))
+	for _, s := range samples {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, data)
+	}
+	name := samples[0].Time.Format("20060102") + ".jsonl"
+	return appendLines(filepath.Join(f.dir, "metrics", name), lines...)
+}
+
+// prune rewrites events.jsonl without the events past the retention or
+// beyond max_events and deletes the metric files past the retention
+func (f *fileStorage) prune(now time.Time) (int, error) {
+	cutoff := now.Add(-f.retention)
+	entries, err := os.ReadDir(filepath.Join(f.dir, "metrics"))
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		day, err := time.ParseInLocation("20060102", strings.TrimSuffix(entry.Name(), ".jsonl"), time.Local)
+		if err == nil && day.AddDate(0, 0, 1).Before(cutoff) {
+			os.Remove(filepath.Join(f.dir, "metrics", entry.Name()))
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	events, err := f.readEvents()
+	if err != nil {
+		return 0, err
+	}
+	keep := 0
+	for keep < len(events) && events[keep].Time.Before(cutoff) {
+		keep++
+	}
+	if f.maxEvents > 0 && len(events)-keep > f.maxEvents {
+		keep = len(events) - f.maxEvents
+	}
+	if keep == 0 {
+		return 0, nil
+	}
+	var buf bytes.Buffer
+	for _, e := range events[keep:] {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return keep, writeFileAtomic(f.eventsPath(), buf.Bytes())
+}
+
+// Run prunes the files at startup and every hour until ctx is cancelled
+func (f *fileStorage) Run(ctx context.Context) {
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+	for {
+		if removed, err := f.prune(time.Now()); err != nil {
+			logrus.Errorf("Failed to prune event history: %v", err)
+		} else if removed > 0 {
+			logrus.Infof("Removed %d event(s) past retention from the event history", removed)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	httpStorageQueue    = 1000
+	httpStorageMaxRetry = time.Minute
+)
+
+// httpStorage is the remote storage backend. Writes are queued and sent in
+// order, retried until the service accepts them; queries and state loads
+// are answered by the service directly:
+//
+//	POST {url}/events          事件（JSON），ID 由服务端分配
+//	GET  {url}/events?...      参数同 GET /events（before 为ID），返回 {"events": [...], "more": bool}
+//	GET  {url}/state/{key}     状态，不存在时返回404
+//	PUT  {url}/state/{key}
+//	POST {url}/metrics         指标快照（MetricSample 数组）
+type httpStorage struct {
+	url     string
+	token   string
+	client  *http.Client
+	queue   chan httpStorageWrite
+	mu      sync.Mutex
+	pending map[string][]byte // 已排队但尚未发送的状态，LoadState 优先返回
+}
+
+// httpStorageWrite is a queued write; the body of a state write is taken
+// from pending when it is sent, so only the latest state is sent
+type httpStorageWrite struct {
+	method, path string
+	body         []byte
+	state        string
+}
+
+func newHTTPStorage(config StorageConfig) *httpStorage {
+	return &httpStorage{
+		url:     strings.TrimSuffix(config.URL, "/"),
+		token:   config.Token,
+		client:  &http.Client{Timeout: seconds(defaultInt(config.Timeout, defaultStorageTimeout))},
+		queue:   make(chan httpStorageWrite, httpStorageQueue),
+		pending: make(map[string][]byte),
+	}
+}
+
+func (h *httpStorage) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.url+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		resp.Body.Close()
+		return nil, &storageStatusError{fmt.Sprintf("%s %s", method, path), resp.StatusCode, strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// storageStatusError is an error response of the storage service
+type storageStatusError struct {
+	request string
+	code    int
+	msg     string
+}
+
+func (e *storageStatusError) Error() string {
+	return strings.TrimSpace(fmt.Sprintf("%s: HTTP %d %s", e.request, e.code, e.msg))
+}
+
+// rejected reports whether the service refused the write itself, so
+// sending it again cannot succeed
+func (e *storageStatusError) rejected() bool {
+	return e.code >= 400 && e.code < 500 && e.code != http.StatusRequestTimeout && e.code != http.StatusTooManyRequests
+}
+
+func (h *httpStorage) enqueue(w httpStorageWrite) {
+	select {
+	case h.queue <- w:
+	default:
+		internalErrorf("storage", "Storage queue is full, dropped %s %s", w.method, w.path)
+	}
+}
+
+// HandleEvent implements EventSink
+func (h *httpStorage) HandleEvent(e Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		logrus.Errorf("Failed to record event %s in history: %v", e.Type, err)
+		return
+	}
+	h.enqueue(httpStorageWrite{method: http.MethodPost, path: "/events", body: data})
+}
+
+// Query implements EventStore
+func (h *httpStorage) Query(filter EventFilter) ([]Event, bool) {
+	query := url.Values{}
+	for key, values := range map[string][]string{"process": filter.Processes, "severity": filter.Severities, "type": filter.Types} {
+		if len(values) > 0 {
+			query.Set(key, strings.Join(values, ","))
+		}
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	if filter.Before > 0 {
+		query.Set("before", strconv.FormatUint(filter.Before, 10))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	resp, err := h.do(context.Background(), http.MethodGet, "/events?"+query.Encode(), nil)
+	if err != nil {
+		logrus.Errorf("Failed to query event history: %v", err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Events []Event `json:"events"`
+		More   bool    `json:"more"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		logrus.Errorf("Failed to query event history: HTTP %d", resp.StatusCode)
+		return nil, false
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logrus.Errorf("Failed to query event history: invalid response: %v", err)
+		return nil, false
+	}
+	return result.Events, result.More
+}
+
+func statePath(key string) string {
+	return "/state/" + url.PathEscape(key)
+}
+
+// LoadState implements Storage
+func (h *httpStorage) LoadState(key string) ([]byte, error) {
+	h.mu.Lock()
+	data, ok := h.pending[key]
+	h.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	resp, err := h.do(context.Background(), http.MethodGet, statePath(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// SaveState implements Storage
+func (h *httpStorage) SaveState(key string, data []byte) error {
+	h.mu.Lock()
+	_, queued := h.pending[key]
+	h.pending[key] = data
+	h.mu.Unlock()
+	if !queued {
+		h.enqueue(httpStorageWrite{method: http.MethodPut, path: statePath(key), state: key})
+	}
+	return nil
+}
+
+// RecordMetrics implements Storage
+func (h *httpStorage) RecordMetrics(samples []MetricSample) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+	h.enqueue(httpStorageWrite{method: http.MethodPost, path: "/metrics", body: data})
+	return nil
+}
+
+// send delivers one write; a state write takes the latest state and leaves
+// pending only when nothing newer was saved meanwhile
+func (h *httpStorage) send(ctx context.Context, w httpStorageWrite) error {
+	body := w.body
+	if w.state != "" {
+		h.mu.Lock()
+		body = h.pending[w.state]
+		h.mu.Unlock()
+	}
+	resp, err := h.do(ctx, w.method, w.path, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return &storageStatusError{fmt.Sprintf("%s %s", w.method, w.path), resp.StatusCode, ""}
+	}
+	if w.state != "" {
+		h.mu.Lock()
+		if bytes.Equal(h.pending[w.state], body) {
+			delete(h.pending, w.state)
+		} else {
+			// 发送期间又保存了新状态，重新排队
+			h.mu.Unlock()
+			h.enqueue(httpStorageWrite{method: w.method, path: w.path, state: w.state})
+			return nil
+		}
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// Run sends the queued writes in order, retrying each with backoff while
+// the service is unreachable. At shutdown the queue is flushed once.
+func (h *httpStorage) Run(ctx context.Context) {
+	for {
+		select {
+		case w := <-h.queue:
+			delay := time.Second
+			for {
+				err := h.send(ctx, w)
+				if err == nil {
+					break
+				}
+				if statusErr, ok := err.(*storageStatusError); ok && statusErr.rejected() {
+					internalErrorf("storage", "Storage %s rejected a write: %v", h.url, err)
+					break
+				}
+				if ctx.Err() != nil {
+					h.flush(&w)
+					return
+				}
+				internalErrorf("storage", "Failed to write to storage %s, retrying in %v: %v", h.url, delay, err)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					h.flush(&w)
+					return
+				}
+				if delay *= 2; delay > httpStorageMaxRetry {
+					delay = httpStorageMaxRetry
+				}
+			}
+		case <-ctx.Done():
+			h.flush(nil)
+			return
+		}
+	}
+}
+
+// flush tries to send first (an unsent write, if any) and the writes still
+// queued at shutdown, within the request timeout
+func (h *httpStorage) flush(first *httpStorageWrite) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.client.Timeout)
+	defer cancel()
+	for {
+		var w httpStorageWrite
+		if first != nil {
+			w, first = *first, nil
+		} else {
+			select {
+			case w = <-h.queue:
+			default:
+				return
+			}
+		}
+		if err := h.send(ctx, w); err != nil {
+			logrus.Warnf("Storage %s: %d write(s) not sent at shutdown: %v", h.url, len(h.queue)+1, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStorageService implements the protocol of the http storage backend
+type fakeStorageService struct {
+	mu      sync.Mutex
+	events  []Event
+	state   map[string][]byte
+	metrics [][]MetricSample
+	down    bool
+}
+
+func (f *fakeStorageService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer t0ken" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.URL.Path == "/events" && r.Method == http.MethodPost:
+		var e Event
+		json.Unmarshal(body, &e)
+		e.ID = uint64(len(f.events) + 1)
+		f.events = append(f.events, e)
+	case r.URL.Path == "/events":
+		filter := EventFilter{Processes: queryList(r.URL.Query(), "process")}
+		filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+		filter.Before, _ = strconv.ParseUint(r.URL.Query().Get("before"), 10, 64)
+		store := newMemoryEventStore(100)
+		for _, e := range f.events {
+			store.HandleEvent(e)
+		}
+		events, more := store.Query(filter)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"events": events, "more": more})
+	case strings.HasPrefix(r.URL.Path, "/state/") && r.Method == http.MethodPut:
+		f.state[strings.TrimPrefix(r.URL.Path, "/state/")] = body
+	case strings.HasPrefix(r.URL.Path, "/state/"):
+		data, ok := f.state[strings.TrimPrefix(r.URL.Path, "/state/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.URL.Path == "/metrics":
+		var samples []MetricSample
+		json.Unmarshal(body, &samples)
+		f.metrics = append(f.metrics, samples)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// received reports whether the metrics, the last write of testStorage, arrived
+func (f *fakeStorageService) received() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.metrics) > 0
+}
+
+// testStorage exercises the behavior every backend shares: events are
+// numbered and queried newest first, states are saved and loaded
+func testStorage(t *testing.T, storage Storage, sync func()) {
+	now := time.Now()
+	for _, e := range []Event{
+		{Time: now.Add(-2 * time.Hour), Type: "process_started", Process: "web.exe"},
+		{Time: now.Add(-time.Hour), Type: "process_restarted", Process: "web.exe"},
+		{Time: now, Type: "process_stopped", Process: "worker.exe"},
+	} {
+		storage.HandleEvent(e)
+	}
+	if data, err := storage.LoadState("process/web.exe"); data != nil || err != nil {
+		t.Errorf("LoadState before save = %q, %v", data, err)
+	}
+	if err := storage.SaveState("process/web.exe", []byte(`{"restarts":3}`)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := storage.LoadState("process/web.exe"); string(data) != `{"restarts":3}` || err != nil {
+		t.Errorf("LoadState = %q, %v", data, err)
+	}
+	if err := storage.RecordMetrics([]MetricSample{{Time: now, Name: "restarts", Kind: "count", Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+
+	events, more := storage.Query(EventFilter{Processes: []string{"web.exe"}, Limit: 1})
+	if len(events) != 1 || !more || events[0].Type != "process_restarted" || events[0].ID != 2 {
+		t.Fatalf("first page = %+v, more=%v", events, more)
+	}
+	events, more = storage.Query(EventFilter{Processes: []string{"web.exe"}, Limit: 1, Before: events[0].ID})
+	if len(events) != 1 || more || events[0].Type != "process_started" {
+		t.Errorf("second page = %+v, more=%v", events, more)
+	}
+	if data, _ := storage.LoadState("process/web.exe"); string(data) != `{"restarts":3}` {
+		t.Errorf("LoadState after sync = %q", data)
+	}
+}
+
+func TestStorageBackends(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testStorage(t, newMemoryStorage(10), func() {})
+	})
+	t.Run("file", func(t *testing.T) {
+		config := StorageConfig{Backend: StorageFile, Path: filepath.Join(t.TempDir(), "data"), MaxEvents: 2}
+		storage, err := openStorage(config, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testStorage(t, storage, func() {})
+
+		// 重新打开后事件和状态仍在，ID 继续递增；超过 max_events 的旧事件被清理
+		storage, err = openStorage(config, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		storage.HandleEvent(Event{Time: time.Now(), Type: "process_started", Process: "worker.exe"})
+		if removed, err := storage.(*fileStorage).prune(time.Now()); removed != 2 || err != nil {
+			t.Errorf("prune removed %d (%v), want 2", removed, err)
+		}
+		events, _ := storage.Query(EventFilter{})
+		if len(events) != 2 || events[0].ID != 4 || events[1].Type != "process_stopped" {
+			t.Errorf("after reopen: %+v", events)
+		}
+		if data, _ := storage.LoadState("process/web.exe"); string(data) != `{"restarts":3}` {
+			t.Errorf("state after reopen = %q", data)
+		}
+	})
+	t.Run("bolt", func(t *testing.T) {
+		storage, err := openStorage(StorageConfig{Backend: StorageBolt, Path: filepath.Join(t.TempDir(), "events.db")}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer storage.(*boltEventStore).db.Close()
+		testStorage(t, storage, func() {})
+		if removed, err := storage.(*boltEventStore).pruneMetrics(time.Now().Add(31 * 24 * time.Hour)); removed != 1 || err != nil {
+			t.Errorf("pruneMetrics removed %d (%v), want 1", removed, err)
+		}
+	})
+	t.Run("http", func(t *testing.T) {
+		service := &fakeStorageService{state: make(map[string][]byte)}
+		server := httptest.NewServer(service)
+		defer server.Close()
+		storage, err := openStorage(StorageConfig{Backend: StorageHTTP, URL: server.URL + "/", Token: "t0ken"}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			storage.Run(ctx)
+			close(done)
+		}()
+		testStorage(t, storage, func() {
+			deadline := time.Now().Add(5 * time.Second)
+			for !service.received() {
+				if time.Now().After(deadline) {
+					t.Fatal("queued writes were not sent")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+
+		// 服务不可用期间写入排队，停止时尽量发送
+		service.mu.Lock()
+		service.down = true
+		service.mu.Unlock()
+		storage.SaveState("process/web.exe", []byte(`{"restarts":4}`))
+		time.Sleep(100 * time.Millisecond)
+		service.mu.Lock()
+		service.down = false
+		service.mu.Unlock()
+		cancel()
+		<-done
+		if got := string(service.state["process/web.exe"]); got != `{"restarts":4}` {
+			t.Errorf("state after shutdown = %v", service.state)
+		}
+	})
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	var recorded []MetricSample
+	recorder := newMetricsRecorder(recordingStorage{newMemoryStorage(10), &recorded})
+	tags := processTags("web.exe")
+	recorder.Count("restarts", 1, tags)
+	recorder.Count("restarts", 2, tags)
+	recorder.Gauge("memory_mb", 100, tags)
+	recorder.Gauge("memory_mb", 120, tags)
+	recorder.Timing("health_check", 10*time.Millisecond, tags)
+	recorder.Timing("health_check", 30*time.Millisecond, tags)
+	now := time.Now()
+	recorder.flush(now)
+
+	want := map[string]float64{"health_check": 20, "memory_mb": 120, "restarts": 3}
+	if len(recorded) != len(want) {
+		t.Fatalf("recorded %+v", recorded)
+	}
+	for _, s := range recorded {
+		if s.Value != want[s.Name] || !s.Time.Equal(now) || s.Tags["process"] != "web.exe" {
+			t.Errorf("sample %+v, want value %v", s, want[s.Name])
+		}
+	}
+	recorded = nil
+	recorder.flush(now)
+	if len(recorded) != 0 {
+		t.Errorf("empty interval recorded %+v", recorded)
+	}
+}
+
+// recordingStorage keeps the metric snapshots it is given
+type recordingStorage struct {
+	*memoryStorage
+	samples *[]MetricSample
+}
+
+func (r recordingStorage) RecordMetrics(samples []MetricSample) error {
+	*r.samples = append(*r.samples, samples...)
+	return nil
+}
+
+func TestProcessStatePersisted(t *testing.T) {
+	orig := stateStorage
+	stateStorage = newMemoryStorage(10)
+	defer func() { stateStorage = orig }()
+
+	config := ProcessConfig{Name: "persisted.exe"}
+	s := NewProcessSupervisor(config)
+	code := 3
+	s.updateStatus(func(st *ProcessStatus) {
+		st.Restarts = 2
+		st.LastRestartReason = "health check failed"
+		st.LastExitCode = &code
+	})
+	s.saveState()
+
+	// 监控程序重启后新的监督者恢复计数
+	status := NewProcessSupervisor(config).Status()
+	if status.Restarts != 2 || status.LastRestartReason != "health check failed" || status.LastExitCode == nil || *status.LastExitCode != 3 {
+		t.Errorf("restored status = %+v", status)
+	}
+	if status.State != StateStarting {
+		t.Errorf("state = %s, want it not restored", status.State)
+	}
+}
+
+func TestStorageConfigValidate(t *testing.T) {
+	tests := []struct {
+		config  StorageConfig
+		wantErr string
+	}{
+		{StorageConfig{}, ""},
+		{StorageConfig{Backend: StorageMemory}, ""},
+		{StorageConfig{Backend: StorageFile, Path: "data"}, ""},
+		{StorageConfig{Backend: StorageBolt, Path: "data/monitor.db", MetricsInterval: -1}, ""},
+		{StorageConfig{Backend: StorageHTTP, URL: "https://store.corp/hosts/web01"}, ""},
+		{StorageConfig{Path: "data"}, "storage.backend is required"},
+		{StorageConfig{Backend: StorageFile}, "requires path"},
+		{StorageConfig{Backend: StorageHTTP, URL: "store.corp"}, "http:// or https://"},
+		{StorageConfig{Backend: "sqlite", Path: "data.db"}, "unknown storage.backend"},
+		{StorageConfig{Backend: StorageFile, Path: "data", RetentionDays: -1}, "must not be negative"},
+		{StorageConfig{Backend: StorageFile, Path: "data", MetricsInterval: -2}, "metrics_interval"},
+	}
+	for _, tt := range tests {
+		err := tt.config.validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%+v: err = %v, want %q", tt.config, err, tt.wantErr)
+		}
+	}
+
+	legacy := Config{EventHistory: EventHistoryConfig{Path: "data/events.db", MaxEvents: 100}}
+	if got := storageConfig(legacy); got.Backend != StorageBolt || got.Path != "data/events.db" || got.MaxEvents != 100 {
+		t.Errorf("storageConfig(event_history) = %+v", got)
+	}
+}
@@ -0,0 +1,244 @@
+//go:build stress
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// 压力测试：go test -tags stress -run Stress -timeout 30m -v . （或 make stress）
+var (
+	stressProcs         = flag.Int("stress.procs", 200, "number of synthetic processes in the farm")
+	stressKills         = flag.Int("stress.kills", 20, "processes killed for the detection latency sample")
+	stressWindow        = flag.Duration("stress.window", 10*time.Second, "steady-state window for CPU measurement")
+	stressMaxCPU        = flag.Float64("stress.max-cpu", 100, "max monitor CPU in steady state (percent of one core)")
+	stressMaxP95        = flag.Duration("stress.max-p95", 5*time.Second, "max p95 detection latency")
+	stressMinThroughput = flag.Float64("stress.min-throughput", 5, "min restarts per second when the whole farm dies")
+	stressOut           = flag.String("stress.out", "", "write the results as JSON to this file")
+)
+
+// stressChildEnv makes the test binary act as a synthetic managed process;
+// its value is the PID of the test process that owns the farm
+const stressChildEnv = "PM_STRESS_CHILD"
+
+func init() {
+	if parent := os.Getenv(stressChildEnv); parent != "" {
+		// 测试进程退出（包括失败或超时）后自行退出，不留下孤儿进程
+		for strconv.Itoa(os.Getppid()) == parent {
+			time.Sleep(time.Second)
+		}
+		os.Exit(0)
+	}
+}
+
+// StressResult is the summary written to -stress.out
+type StressResult struct {
+	Processes        int     `json:"processes"`
+	StartupSeconds   float64 `json:"startup_seconds"`
+	CPUPercent       float64 `json:"cpu_percent"`
+	DetectP50Seconds float64 `json:"detect_p50_seconds"`
+	DetectP95Seconds float64 `json:"detect_p95_seconds"`
+	DetectMaxSeconds float64 `json:"detect_max_seconds"`
+	RecoverySeconds  float64 `json:"recovery_seconds"`
+	RestartsPerSec   float64 `json:"restarts_per_second"`
+}
+
+// buildFarm links the test binary under n distinct names so every synthetic
+// process is matched by its own supervisor
+func buildFarm(t *testing.T, n int) []ProcessConfig {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	configs := make([]ProcessConfig, 0, n)
+	for i := 0; i < n; i++ {
+		// 定宽编号，避免 stressapp_0001 被当作 stressapp_00010 的子串匹配
+		name := fmt.Sprintf("stressapp_%04d", i)
+		path := filepath.Join(dir, name)
+		if err := os.Link(exe, path); err != nil {
+			if err := os.Symlink(exe, path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		configs = append(configs, ProcessConfig{
+			Name:           name,
+			Enable:         true,
+			RestartCommand: path,
+			CheckInterval:  1,
+			KillOnExit:     true,
+		})
+	}
+	return configs
+}
+
+func cpuSeconds(t *testing.T) float64 {
+	self, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	times, err := self.Times()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return times.User + times.System
+}
+
+// waitFor polls cond until it holds or timeout expires
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+func allRunning(m *ProcessManager, exclude map[string]int) bool {
+	for _, st := range m.Statuses() {
+		if st.State != StateRunning || st.PID == 0 || st.PID == exclude[st.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// stateCounts summarizes the farm for failure messages
+func stateCounts(m *ProcessManager) map[string]int {
+	counts := make(map[string]int)
+	for _, st := range m.Statuses() {
+		counts[st.State]++
+	}
+	return counts
+}
+
+func killPID(pid int) {
+	if p, err := os.FindProcess(pid); err == nil {
+		p.Kill()
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+func TestStressProcessFarm(t *testing.T) {
+	level := logrus.GetLevel()
+	output := logrus.StandardLogger().Out
+	logrus.SetLevel(logrus.ErrorLevel)
+	logrus.SetOutput(io.Discard)
+	defer func() {
+		logrus.SetLevel(level)
+		logrus.SetOutput(output)
+	}()
+	os.Setenv(stressChildEnv, strconv.Itoa(os.Getpid()))
+	defer os.Unsetenv(stressChildEnv)
+
+	n := *stressProcs
+	manager := NewProcessManager(Config{Processes: buildFarm(t, n)})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		// 等待 kill_on_exit 结束所有子进程
+		waitFor(time.Minute, func() bool {
+			for _, st := range manager.Statuses() {
+				if running, _ := isProcessRunning(st.Name); running {
+					return false
+				}
+			}
+			return true
+		})
+	}()
+	result := StressResult{Processes: n}
+
+	// 1. 启动整个进程农场
+	start := time.Now()
+	manager.Start(ctx)
+	if !waitFor(5*time.Minute, func() bool { return allRunning(manager, nil) }) {
+		t.Fatalf("farm of %d processes did not come up: %v", n, stateCounts(manager))
+	}
+	result.StartupSeconds = time.Since(start).Seconds()
+
+	// 2. 稳态下监控程序自身的CPU占用
+	cpuStart, wallStart := cpuSeconds(t), time.Now()
+	time.Sleep(*stressWindow)
+	result.CPUPercent = (cpuSeconds(t) - cpuStart) / time.Since(wallStart).Seconds() * 100
+
+	// 3. 外部结束部分进程，测量从结束到开始重启的检测延迟
+	statuses := manager.Statuses()
+	rand.Shuffle(len(statuses), func(i, j int) { statuses[i], statuses[j] = statuses[j], statuses[i] })
+	sample := statuses
+	if *stressKills < len(sample) {
+		sample = sample[:*stressKills]
+	}
+	killedAt := time.Now()
+	for _, st := range sample {
+		killPID(st.PID)
+	}
+	var latencies []time.Duration
+	for _, st := range sample {
+		s, _ := manager.Get(st.Name)
+		if !waitFor(time.Minute, func() bool { return s.Status().LastRestart.After(killedAt) }) {
+			t.Fatalf("kill of %s was not detected", st.Name)
+		}
+		latencies = append(latencies, s.Status().LastRestart.Sub(killedAt))
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p95 := percentile(latencies, 0.95)
+	result.DetectP50Seconds = percentile(latencies, 0.5).Seconds()
+	result.DetectP95Seconds = p95.Seconds()
+	result.DetectMaxSeconds = latencies[len(latencies)-1].Seconds()
+	if !waitFor(5*time.Minute, func() bool { return allRunning(manager, nil) }) {
+		t.Fatalf("farm did not recover after sample kill: %v", stateCounts(manager))
+	}
+
+	// 4. 整个农场同时退出时的重启吞吐量
+	pids := make(map[string]int)
+	for _, st := range manager.Statuses() {
+		pids[st.Name] = st.PID
+		killPID(st.PID)
+	}
+	killedAt = time.Now()
+	if !waitFor(10*time.Minute, func() bool { return allRunning(manager, pids) }) {
+		t.Fatalf("farm did not recover after killing every process: %v", stateCounts(manager))
+	}
+	result.RecoverySeconds = time.Since(killedAt).Seconds()
+	result.RestartsPerSec = float64(n) / result.RecoverySeconds
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	t.Logf("stress results:\n%s", data)
+	if *stressOut != "" {
+		if err := os.WriteFile(*stressOut, data, 0644); err != nil {
+			t.Errorf("failed to write results: %v", err)
+		}
+	}
+
+	if result.CPUPercent > *stressMaxCPU {
+		t.Errorf("monitor CPU %.1f%% exceeds threshold %.1f%%", result.CPUPercent, *stressMaxCPU)
+	}
+	if p95 > *stressMaxP95 {
+		t.Errorf("p95 detection latency %v exceeds threshold %v", p95, *stressMaxP95)
+	}
+	if result.RestartsPerSec < *stressMinThroughput {
+		t.Errorf("restart throughput %.1f/s below threshold %.1f/s", result.RestartsPerSec, *stressMinThroughput)
+	}
+}
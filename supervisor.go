@@ -0,0 +1,894 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 被监控进程的状态
+const (
+	StateStarting   = "starting"
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	StateStopped    = "stopped" // 已被手动停止，不会自动重启
+	StatePaused     = "paused"  // 暂停监控，进程保持原状
+	StateDown       = "down"    // 未运行，等待下一次检查重启
+	StateFailed     = "failed"  // 熔断：反复崩溃后停止自动重启
+	StateWaiting    = "waiting" // 首次启动前等待 wait_for_* 条件满足
+	StateExited     = "exited"  // 以 ignore_exit_codes 中的退出码有意退出，不会自动重启
+)
+
+// ProcessStatus is the externally visible state of a managed process
+type ProcessStatus struct {
+	Name              string             `json:"name"`
+	State             string             `json:"state"`
+	PID               int                `json:"pid"`
+	StartedAt         time.Time          `json:"started_at,omitempty"`
+	Restarts          int                `json:"restarts"`
+	LastRestart       time.Time          `json:"last_restart,omitempty"`
+	LastRestartReason string             `json:"last_restart_reason,omitempty"`
+	RestartProfile    string             `json:"restart_profile,omitempty"`   // 当前实例使用的重启配置（restart_profiles）
+	CorrelationID     string             `json:"correlation_id,omitempty"`    // 当前启动/重启周期的关联ID
+	Health            string             `json:"health,omitempty"`            // healthy, unhealthy，未检查时为空
+	Breaker           string             `json:"breaker,omitempty"`           // 熔断器状态：open, half_open，正常时为空
+	Session           uint32             `json:"session,omitempty"`           // 所在的Windows会话（per_session 模式）
+	Sessions          []ProcessStatus    `json:"sessions,omitempty"`          // per_session 模式下各会话实例的状态
+	StartGate         string             `json:"start_gate,omitempty"`        // 正在等待的启动条件（waiting 状态）
+	Protocol          string             `json:"protocol,omitempty"`          // 监督协议状态：starting, ready, unhealthy, stopping
+	HealthLatencyMs   float64            `json:"health_latency_ms,omitempty"` // 最近一轮端口和健康检查的总耗时（毫秒）
+	CheckLatencyMs    map[string]float64 `json:"check_latency_ms,omitempty"`  // 最近一轮各项检查（port:80、检查地址等）的耗时（毫秒）
+	Adopted           bool               `json:"adopted,omitempty"`           // 当前实例不是监控程序启动的，而是 adopt 模式接管的
+	LastExitCode      *int               `json:"last_exit_code,omitempty"`    // 自己启动的实例最近一次退出的退出码（被信号结束时为 -1）
+	DiskUsageMB       float64            `json:"disk_usage_mb,omitempty"`     // disk_quota 统计的目录总大小（MB）
+	PortsReserved     bool               `json:"ports_reserved,omitempty"`    // 进程停止期间监控程序正在占用它的端口（port_reserve）
+	StandbyPID        int                `json:"standby_pid,omitempty"`       // standby 备用实例的PID
+}
+
+// supervisorCommand is a control request delivered to a running supervisor
+type supervisorCommand struct {
+	action string // start, stop, restart, pause, resume, update
+	reason string
+	arg    string // update: 新程序文件的路径；restart: 重启配置名（可选）
+	reply  chan error
+}
+
+// ProcessSupervisor monitors one process and accepts control commands
+type ProcessSupervisor struct {
+	config   ProcessConfig
+	commands chan supervisorCommand
+
+	// 以下字段只在 Run 所在的 goroutine 中访问
+	currentCmd *exec.Cmd
+	exited     chan struct{} // currentCmd 被回收（Wait 返回）后关闭
+	stopped    bool
+	paused     bool
+	profiler   *cpuProfileTrigger // CPU持续过高时自动采样（未启用时为nil）
+
+	hungWindowChecks  int         // 连续检测到窗口无响应的次数
+	crashTimes        []time.Time // 崩溃循环检测窗口内的自动重启时间
+	quarantinePending bool        // 下一次重启前隔离输入文件
+	trackedPID        int32       // 当前实例的PID（自己启动或接管的），不为0时按PID检查
+	adopted           bool        // trackedPID 是 adopt 模式接管的实例，停止时按PID优雅停止
+	offSchedule       bool        // 不在 schedule 的运行时间内，进程已停止
+	firewallOpen      bool        // open_firewall 的放行规则已创建
+	backoff           *restartTracker
+	resources         *resourceWatch     // 配置了 max_cpu_percent / max_memory_mb 时检查资源占用
+	checks            checkCounter       // 健康检查连续失败/成功次数
+	protocol          *protocolMonitor   // 启用监督协议时子进程报告的状态
+	proxy             *portProxy         // netns 模式下主机端口到命名空间内端口的转发
+	failureSnapshot   *EnvSnapshot       // 本次故障重启前采集的环境快照，附加到重启事件
+	triggers          *logTriggerMonitor // 配置了 log_triggers 时匹配子进程输出
+	profile           *RestartProfile    // 当前实例使用的重启配置，nil 表示使用进程本身的配置
+	reserver          *portReserver      // 配置了 port_reserve 时在进程停止期间占用端口
+	standby           *standbyInstance   // 启用 standby 时的备用实例
+	timeline          *checkTimeline     // 最近各轮检查的时间、耗时和结论
+	roundChecks       []CheckOutcome     // 本轮端口和健康检查的结果，记录到 timeline
+
+	stdinMu sync.Mutex
+	stdin   *os.File // keep_stdin 时子进程标准输入的写端
+
+	childLog *childLog // 配置了 log_file 时捕获子进程输出
+
+	sessions     map[uint32]*sessionChild // per_session 模式下各会话的实例，只在 Run 中访问
+	leaveRunning atomic.Bool              // 停止监控时不按 kill_on_exit 结束进程（会话结束、重新加载配置）
+	parent       *ProcessSupervisor       // per_session 模式下各会话实例的上级
+
+	mu     sync.RWMutex
+	status ProcessStatus
+}
+
+// NewProcessSupervisor creates a supervisor for the given process config
+func NewProcessSupervisor(config ProcessConfig) *ProcessSupervisor {
+	s := &ProcessSupervisor{
+		config:    config,
+		commands:  make(chan supervisorCommand),
+		backoff:   newRestartTracker(config.RestartPolicy),
+		resources: newResourceWatch(config),
+		proxy:     newPortProxy(config),
+		protocol:  newProtocolMonitor(config),
+		triggers:  newLogTriggerMonitor(config),
+		reserver:  newPortReserver(config),
+		standby:   newStandbyInstance(config),
+		timeline:  &checkTimeline{},
+		status: ProcessStatus{
+			Name:  config.Name,
+			State: StateStarting,
+		},
+	}
+	if config.LogFile != "" {
+		s.childLog = newChildLog(config)
+	}
+	if sessionModeSupported(config) {
+		switch config.SessionMode {
+		case SessionModePerSession:
+			if !config.sessionScoped {
+				s.sessions = make(map[uint32]*sessionChild)
+			}
+		case SessionModeZero:
+			s.config = sessionConfig(config, 0)
+		}
+	}
+	s.status.Session = s.config.sessionID
+	loadProcessState(s.config, &s.status)
+	if s.standby != nil {
+		// 按名称查找主实例时忽略备用实例
+		s.config.ignorePID = s.standby.pid
+	}
+	return s
+}
+
+// Status returns a copy of the current process status
+func (s *ProcessSupervisor) Status() ProcessStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+func (s *ProcessSupervisor) updateStatus(update func(status *ProcessStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(&s.status)
+}
+
+// Send delivers a control command and waits for it to complete
+func (s *ProcessSupervisor) Send(ctx context.Context, action, reason string) error {
+	return s.send(ctx, supervisorCommand{action: action, reason: reason})
+}
+
+// Restart restarts the process with the named restart profile ("" selects
+// the profile as for an automatic restart, "default" none)
+func (s *ProcessSupervisor) Restart(ctx context.Context, profile, reason string) error {
+	return s.send(ctx, supervisorCommand{action: "restart", reason: reason, arg: profile})
+}
+
+// send delivers cmd to the Run goroutine and waits for its result
+func (s *ProcessSupervisor) send(ctx context.Context, cmd supervisorCommand) error {
+	cmd.reply = make(chan error, 1)
+	select {
+	case s.commands <- cmd:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-cmd.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run monitors the process and restarts it if necessary
+func (s *ProcessSupervisor) Run(ctx context.Context) {
+	// 目录大小按进程统计一次，per_session 模式下不在每个会话实例中重复
+	if s.config.DiskQuota.enabled() && s.parent == nil {
+		go runGuarded(ctx, "disk quota of "+s.config.Name, s.config.Name, s.watchDiskQuota)
+	}
+	if s.sessions != nil {
+		s.runSessions(ctx)
+		return
+	}
+	config := s.config
+	ticker := time.NewTicker(time.Duration(config.CheckInterval) * time.Second)
+	defer ticker.Stop()
+	// 监控停止（退出、重新加载配置）时不再占用端口
+	defer s.releasePorts()
+
+	if config.CPUProfile.Enable {
+		s.profiler = newCPUProfileTrigger(config.CPUProfile)
+	}
+
+	// 端口转发在整个监控期间保持监听，重启时不释放主机端口
+	if s.proxy != nil {
+		if err := s.proxy.Listen(ctx); err != nil {
+			logrus.Errorf("Failed to start port proxy for %s: %v", config.Name, err)
+		}
+	}
+
+	// Check if process is already running before initial start
+	running, err := s.adoptRunning()
+	if s.checkSchedule() {
+		// 不在运行时间内：已在运行的实例被停止，时间段开始时由 check 启动
+	} else if err != nil {
+		logrus.Errorf("Failed to check if process %s is running: %v", config.Name, err)
+	} else if running {
+		logrus.Infof("Process %s is already running, skipping initial start", config.Name)
+		s.updateStatus(func(st *ProcessStatus) { st.State = StateRunning })
+	} else if s.waitForGates(ctx) {
+		// Start the process initially only if it's not already running
+		logrus.Infof("Starting initial process: %s", config.Name)
+		if s.start(false) == nil {
+			s.emitStarted("initial start")
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			s.check()
+
+		case <-s.protocol.wakeup():
+			// 子进程通过监督协议报告状态变化时立即检查
+			s.check()
+
+		case <-s.triggers.wakeup():
+			// 子进程输出匹配了 restart 动作的日志触发器
+			s.check()
+
+		case cmd := <-s.commands:
+			cmd.reply <- s.handleCommand(cmd)
+
+		case <-ctx.Done():
+			leave := s.leaveRunning.Load() || (s.parent != nil && s.parent.leaveRunning.Load())
+			if config.KillOnExit && !leave && s.currentCmd != nil && s.currentCmd.Process != nil {
+				logrus.Infof("Stopping process %s (PID: %d)", config.Name, s.currentCmd.Process.Pid)
+				stopCommand(config, s.currentCmd, s.exited, s.stopAck())
+				removePIDFile(config)
+				s.closeFirewall()
+			} else if config.KillOnExit && !leave && s.adopted {
+				logrus.Infof("Stopping adopted process %s (PID: %d)", config.Name, s.trackedPID)
+				stopPID(config, s.trackedPID)
+				removePIDFile(config)
+				s.closeFirewall()
+			} else if config.endsWithMonitor() {
+				// 监控程序退出时由 PDEATHSIG 或作业对象结束；重新加载配置时作业对象保留给新的监控循环
+				if !leave {
+					logrus.Infof("Process %s will end with the monitor (on_monitor_death: %s)", config.Name, config.OnMonitorDeath)
+				}
+			} else if s.currentCmd != nil && s.currentCmd.Process != nil {
+				logrus.Infof("Leaving process %s (PID: %d) running", config.Name, s.currentCmd.Process.Pid)
+				detachProcessTree(s.currentCmd.Process.Pid)
+			}
+			if config.KillOnExit && !leave {
+				s.stopStandby()
+			} else if s.standby != nil && s.standby.cmd != nil && !config.endsWithMonitor() {
+				detachProcessTree(s.standby.cmd.Process.Pid)
+			}
+			return
+		}
+	}
+}
+
+// check runs one round of liveness and health checks and restarts the
+// process when needed
+func (s *ProcessSupervisor) check() {
+	config := s.config
+	entry := TimelineEntry{Time: time.Now(), PID: s.Status().PID, Result: TimelineSkipped}
+	s.roundChecks = nil
+	defer func() {
+		entry.Checks = s.roundChecks
+		s.timeline.add(entry)
+	}()
+	// 手动停止或暂停后不再检查和重启
+	if s.stopped || s.paused {
+		entry.Reason = s.Status().State
+		return
+	}
+	if s.checkSchedule() {
+		entry.Reason = "outside schedule"
+		return
+	}
+
+	needRestart := false
+	processRunning := false
+	reason := ""
+	failure := FailureExit // 重启原因的类型，用于选择 restart_profiles
+
+	// Check if current command is still running
+	if s.currentCmd != nil && s.currentCmd.Process != nil {
+		// Check if process is still alive using process state
+		if s.hasExited() {
+			code := exitCode(s.currentCmd)
+			logrus.Warnf("Managed process %s (PID: %d) has exited with code %d", config.Name, s.currentCmd.Process.Pid, code)
+			s.updateStatus(func(st *ProcessStatus) { st.LastExitCode = &code })
+			s.saveState()
+			if !restartOnExitCode(config, code) {
+				s.exitedIntentionally(code)
+				entry.Result, entry.Reason = TimelineExited, fmt.Sprintf("exited with code %d", code)
+				return
+			}
+			needRestart = true
+			reason = fmt.Sprintf("process exited with code %d", code)
+		} else {
+			// 即使子进程尚未退出，也通过名称再次检查
+			running, _ := isConfigRunning(config)
+			if !running {
+				logrus.Warnf("Process %s (PID: %d) was manually closed", config.Name, s.currentCmd.Process.Pid)
+				needRestart = true
+				reason = "process closed"
+			} else {
+				processRunning = true
+				logrus.Debugf("Process %s (PID: %d) is running", config.Name, s.currentCmd.Process.Pid)
+			}
+		}
+	} else if s.trackedPID != 0 && trackedAlive(config, s.trackedPID) {
+		// 已接管的进程按PID检查，不再按名称扫描
+		processRunning = true
+	} else {
+		// No current command, check if process exists by name
+		if s.trackedPID != 0 {
+			logrus.Warnf("Tracked process %s (PID: %d) has exited", config.Name, s.trackedPID)
+			s.untrack()
+		}
+		running, _ := s.adoptRunning()
+		if !running {
+			logrus.Warnf("Process %s is not running", config.Name)
+			needRestart = true
+			reason = "process not running"
+		} else {
+			processRunning = true
+		}
+	}
+
+	// Only check ports and health if process is running
+	if processRunning {
+		// CPU持续过高时自动采样
+		if s.profiler != nil {
+			if pid := s.currentPID(); pid != 0 {
+				s.profiler.check(config.Name, pid)
+			}
+		}
+
+		// CPU或内存持续超过上限时重启或告警
+		if s.resources != nil {
+			if pid := s.currentPID(); pid != 0 {
+				needRestart, reason = s.checkResources(pid)
+				failure = FailureResource
+			}
+		}
+
+		// 监督协议报告的就绪、健康和心跳（只适用于自己启动的实例）
+		if !needRestart && s.protocol != nil && s.currentCmd != nil {
+			needRestart, reason = s.checkProtocol()
+			failure = FailureProtocol
+		}
+
+		// 子进程输出匹配了 restart 动作的日志触发器
+		if !needRestart {
+			if r := s.triggers.takeRestart(); r != "" {
+				needRestart, reason, failure = true, r, FailureLogTrigger
+			}
+		}
+
+		// 端口和健康检查并发执行
+		if !needRestart {
+			needRestart, reason = s.checkEndpoints()
+			failure = FailureHealthCheck
+		}
+
+		// 图形界面程序主窗口无响应检查
+		if !needRestart && config.WindowCheck.Enable {
+			needRestart, reason = s.checkWindow()
+			failure = FailureWindow
+		}
+	}
+
+	// If process needs restart
+	if needRestart {
+		// 先触发 on_unhealthy（如摘除负载均衡），再重启
+		s.setHealth(false, reason)
+		s.checks = checkCounter{}
+		entry.Result, entry.Reason = TimelinePostponed, reason
+		if config.NetworkDependent && !networkAvailable() {
+			// 网络中断时重启依赖网络的服务没有意义
+			logrus.Warnf("Network is down, postponing restart of %s (%s)", config.Name, reason)
+			s.updateStatus(func(st *ProcessStatus) { st.State = StateDown })
+			entry.Reason += " (network down)"
+			return
+		}
+		if !s.allowRestart(reason) {
+			entry.Reason += " (held back by restart policy)"
+			return
+		}
+		entry.Result = TimelineRestart
+		if s.countCrash(reason) {
+			failure = FailureCrashLoop
+		}
+		s.captureFailureSnapshot(reason)
+		s.selectProfile("", failure)
+		// 有备用实例时提升它，故障的实例重启为新的备用实例
+		if !s.promoteStandby(reason) {
+			s.restart(reason)
+		}
+	} else if processRunning {
+		entry.Result = TimelineOK
+		if s.checks.failures > 0 {
+			entry.Result, entry.Reason = TimelineDegraded, fmt.Sprintf("%d consecutive check failures", s.checks.failures)
+		} else if !s.protocolReady() {
+			entry.Result, entry.Reason = TimelineDegraded, "process has not reported ready"
+		}
+		s.updateStatus(func(st *ProcessStatus) { st.State = StateRunning })
+		// 健康检查失败时已立即标记为不健康（未达到 failure_threshold 不重启）；
+		// 不健康后需要连续成功 success_threshold 次才恢复
+		if s.checks.failures == 0 && s.protocolReady() {
+			s.checks.successes++
+			if threshold := defaultInt(config.SuccessThreshold, 1); s.Status().Health != HealthUnhealthy || s.checks.successes >= threshold {
+				s.setHealth(true, "checks passed")
+			} else {
+				logrus.Infof("Process %s passed checks %d/%d times, not yet considered healthy", config.Name, s.checks.successes, threshold)
+			}
+		}
+		if s.backoff.healthy(time.Now(), s.Status().StartedAt) {
+			s.updateStatus(func(st *ProcessStatus) { st.Breaker = BreakerClosed })
+			emitEvent(Event{
+				Severity: SeverityInfo,
+				Type:     "circuit_closed",
+				Process:  config.Name,
+				Message:  fmt.Sprintf("Process %s is stable again, automatic restarts resumed", config.Name),
+			})
+		}
+		logrus.Debugf("Process %s is healthy", config.Name)
+		s.ensureStandby()
+	}
+}
+
+// handleCommand executes a control command inside the Run goroutine
+func (s *ProcessSupervisor) handleCommand(cmd supervisorCommand) error {
+	logrus.Infof("Received %s command for process %s (%s)", cmd.action, s.config.Name, cmd.reason)
+
+	switch cmd.action {
+	case "start", "restart", "update":
+		if s.offSchedule {
+			return fmt.Errorf("process %s is outside its schedule", s.config.Name)
+		}
+	}
+
+	switch cmd.action {
+	case "start", "restart":
+		// 手动启动或重启视为人工处理过，关闭熔断器并清除退避
+		wasOpen := s.backoff.breaker != BreakerClosed
+		s.backoff.reset()
+		s.updateStatus(func(st *ProcessStatus) { st.Breaker = BreakerClosed })
+		if wasOpen {
+			emitEvent(Event{
+				Severity: SeverityInfo,
+				Type:     "circuit_closed",
+				Process:  s.config.Name,
+				Message:  fmt.Sprintf("Circuit breaker of %s reset by manual %s", s.config.Name, cmd.action),
+			})
+		}
+	}
+
+	switch cmd.action {
+	case "start":
+		s.stopped = false
+		s.paused = false
+		if running, _ := isConfigRunning(s.config); running {
+			s.updateStatus(func(st *ProcessStatus) { st.State = StateRunning })
+			return nil
+		}
+		if err := s.start(false); err != nil {
+			return err
+		}
+		s.emitStarted(cmd.reason)
+		return nil
+	case "stop":
+		s.stopped = true
+		pid := s.Status().PID
+		s.kill()
+		s.stopStandby()
+		s.closeFirewall()
+		s.updateStatus(func(st *ProcessStatus) {
+			st.State = StateStopped
+			st.PID = 0
+		})
+		emitEvent(Event{
+			Severity: SeverityInfo,
+			Type:     "process_stopped",
+			Process:  s.config.Name,
+			Message:  fmt.Sprintf("Stopped process %s (PID: %d)", s.config.Name, pid),
+			Details:  map[string]string{"reason": cmd.reason, "pid": strconv.Itoa(pid)},
+		})
+		return nil
+	case "restart":
+		if err := s.selectProfile(cmd.arg, ""); err != nil {
+			return err
+		}
+		s.stopped = false
+		s.paused = false
+		return s.restart(cmd.reason)
+	case "pause":
+		s.paused = true
+		s.updateStatus(func(st *ProcessStatus) { st.State = StatePaused })
+		return nil
+	case "resume":
+		s.paused = false
+		if s.stopped {
+			s.updateStatus(func(st *ProcessStatus) { st.State = StateStopped })
+			return nil
+		}
+		// 暂停期间进程可能已退出：立即检查一次以恢复真实状态（需要时按重启策略重启）
+		s.check()
+		return nil
+	case "update":
+		s.stopped = false
+		s.paused = false
+		return s.update(cmd.arg, cmd.reason)
+	default:
+		return fmt.Errorf("unknown action: %s", cmd.action)
+	}
+}
+
+// start launches the process and records the new PID
+func (s *ProcessSupervisor) start(isRestart bool) error {
+	if err := s.resolvePortConflicts(); err != nil {
+		logrus.Errorf("Failed to start process %s: %v", s.config.Name, err)
+		s.currentCmd = nil
+		s.updateStatus(func(st *ProcessStatus) {
+			st.State = StateDown
+			st.PID = 0
+		})
+		s.reservePorts()
+		return err
+	}
+
+	stdinReader, err := s.openStdin()
+	if err != nil {
+		logrus.Errorf("Failed to create stdin pipe for %s: %v", s.config.Name, err)
+		return err
+	}
+
+	stdio := processIO{}
+	if stdinReader != nil {
+		stdio.Stdin = stdinReader
+	}
+	if s.childLog != nil {
+		s.childLog.Flush()
+		stdio.Stdout = s.childLog.stdout
+		stdio.Stderr = s.childLog.stderr
+	}
+	if s.triggers != nil {
+		s.triggers.reset()
+		stdio = s.triggers.wrap(stdio, func() int { return s.Status().PID })
+	}
+
+	// 重启周期在 restart 中开始；首次启动和手动启动在这里开始新的周期
+	if !isRestart {
+		s.beginCycle()
+	}
+	stdio.Env = append(stdio.Env, correlationEnv+"="+correlationID(s.config.Name))
+	stdio.Env = append(stdio.Env, s.profileEnv()...)
+
+	stdio, protocolPipe, err := s.openProtocol(stdio)
+	if err != nil {
+		logrus.Errorf("Failed to create protocol pipe for %s: %v", s.config.Name, err)
+		if stdinReader != nil {
+			stdinReader.Close()
+		}
+		s.closeStdin()
+		return err
+	}
+
+	s.releasePorts()
+	cmd, err := startProcess(s.startConfig(), isRestart, stdio)
+	// 子进程已继承协议管道的写端，父进程关闭后子进程退出时读取方才能收到 EOF
+	if protocolPipe != nil {
+		protocolPipe.Close()
+	}
+	// 子进程已继承读端，父进程不再需要
+	if stdinReader != nil {
+		stdinReader.Close()
+	}
+	if err != nil {
+		s.closeStdin()
+		if strings.Contains(err.Error(), "exclude processes found") {
+			logrus.Infof("Skipping start of %s due to exclude processes", s.config.Name)
+		} else if isExecError(err) {
+			internalErrorf("exec", "Failed to start process %s: %v", s.config.Name, err)
+		} else {
+			logrus.Errorf("Failed to start process %s: %v", s.config.Name, err)
+		}
+		s.currentCmd = nil
+		s.updateStatus(func(st *ProcessStatus) {
+			st.State = StateDown
+			st.PID = 0
+		})
+		if !strings.Contains(err.Error(), "is already running") {
+			s.reservePorts()
+		}
+		return err
+	}
+
+	// 立即回收子进程：否则被外部结束的进程在 Linux 上成为僵尸进程，按名称仍能匹配到
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	s.currentCmd = cmd
+	s.exited = exited
+	s.track(int32(cmd.Process.Pid))
+	s.updateStatus(func(st *ProcessStatus) {
+		st.State = StateRunning
+		st.PID = cmd.Process.Pid
+		st.Adopted = false
+		st.StartedAt = time.Now()
+	})
+	s.openFirewall()
+	if s.config.StartupTimeout <= 0 {
+		// Give the process some time to start up
+		time.Sleep(2 * time.Second)
+		return nil
+	}
+	// 在 startup_timeout 内通过就绪检查才算启动成功
+	if err := s.waitReady(); err != nil {
+		s.failedStart(err)
+		return err
+	}
+	return nil
+}
+
+// currentPID returns the PID of the running instance: the child started by
+// this supervisor, the adopted PID, or the first instance found by name
+func (s *ProcessSupervisor) currentPID() int32 {
+	if s.currentCmd != nil && s.currentCmd.Process != nil {
+		return int32(s.currentCmd.Process.Pid)
+	}
+	if s.trackedPID != 0 {
+		return s.trackedPID
+	}
+	if pids, err := configPIDs(s.config); err == nil && len(pids) > 0 {
+		return pids[0]
+	}
+	return 0
+}
+
+// hasExited reports whether the current child has exited and been reaped
+func (s *ProcessSupervisor) hasExited() bool {
+	select {
+	case <-s.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill terminates the managed process and any other instance with the same name
+func (s *ProcessSupervisor) kill() {
+	// Kill current process if it exists
+	if s.currentCmd != nil && s.currentCmd.Process != nil {
+		logrus.Infof("Terminating current process %s (PID: %d)", s.config.Name, s.currentCmd.Process.Pid)
+		stopCommand(s.config, s.currentCmd, s.exited, s.stopAck())
+		s.currentCmd = nil
+	} else if s.adopted {
+		logrus.Infof("Terminating adopted process %s (PID: %d)", s.config.Name, s.trackedPID)
+		stopPID(s.config, s.trackedPID)
+	}
+	s.closeStdin()
+
+	// Stop any other instances of the process
+	stopExistingProcesses(s.config)
+	s.untrack()
+	s.reservePorts()
+}
+
+// beginCycle starts a new correlation ID for the process; the restart
+// events, hooks and the new instance all carry it
+func (s *ProcessSupervisor) beginCycle() string {
+	id := beginCycle(s.config.Name)
+	s.updateStatus(func(st *ProcessStatus) { st.CorrelationID = id })
+	return id
+}
+
+// restart kills the process, waits for the restart delay and starts it again
+func (s *ProcessSupervisor) restart(reason string) error {
+	id := s.beginCycle()
+	logrus.Warnf("Process %s needs to be restarted: %s (correlation ID %s)", s.config.Name, reason, id)
+	s.updateStatus(func(st *ProcessStatus) { st.State = StateRestarting })
+	snapshot := s.takeFailureSnapshot()
+
+	s.kill()
+
+	// 进程退出后文件不再被占用，此时隔离导致崩溃的输入
+	if s.quarantinePending {
+		s.quarantinePending = false
+		quarantineInputs(s.config)
+	}
+
+	// Wait for restart delay
+	if s.config.RestartDelay > 0 {
+		logrus.Infof("Waiting %d seconds before restart", s.config.RestartDelay)
+		time.Sleep(time.Duration(s.config.RestartDelay) * time.Second)
+	}
+
+	emitCount("restarts", 1, processTags(s.config.Name))
+	s.updateStatus(func(st *ProcessStatus) {
+		st.Restarts++
+		st.LastRestart = time.Now()
+		st.LastRestartReason = reason
+	})
+	s.saveState()
+	s.runProfileHook("before_start", reason)
+
+	// Start new process
+	if err := s.start(true); err != nil {
+		if strings.Contains(err.Error(), "exclude processes found") {
+			return err
+		}
+		emitEvent(Event{
+			Severity: SeverityWarning,
+			Type:     "restart_failed",
+			Process:  s.config.Name,
+			Message:  fmt.Sprintf("Failed to restart process %s: %v", s.config.Name, err),
+			Details:  map[string]string{"reason": reason, "error": err.Error()},
+			Snapshot: snapshot,
+		})
+		return err
+	}
+	details := map[string]string{
+		"reason":   reason,
+		"pid":      strconv.Itoa(s.currentCmd.Process.Pid),
+		"restarts": strconv.Itoa(s.Status().Restarts),
+	}
+	if s.profile != nil {
+		details["profile"] = s.profile.Name
+	}
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "process_restarted",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Successfully restarted process %s (PID: %d)", s.config.Name, s.currentCmd.Process.Pid),
+		Details:  details,
+		Snapshot: snapshot,
+	})
+	s.runProfileHook("after_start", reason)
+	return nil
+}
+
+// emitStarted records a start of the process that is not a restart
+func (s *ProcessSupervisor) emitStarted(reason string) {
+	pid := s.Status().PID
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "process_started",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Started process %s (PID: %d)", s.config.Name, pid),
+		Details:  map[string]string{"reason": reason, "pid": strconv.Itoa(pid)},
+	})
+}
+
+// ProcessManager owns the supervisors of all enabled processes. The process
+// set can be replaced at runtime (config reload, safe mode).
+type ProcessManager struct {
+	mu          sync.RWMutex
+	supervisors map[string]*ProcessSupervisor
+	order       []string
+	groups      []GroupConfig
+	configs     map[string]ProcessConfig
+
+	cancel context.CancelFunc // 停止当前这一组监控
+	wg     sync.WaitGroup
+}
+
+// NewProcessManager creates supervisors for every enabled process in config
+func NewProcessManager(config Config) *ProcessManager {
+	m := &ProcessManager{}
+	m.load(config)
+	return m
+}
+
+// load builds the supervisors of config; callers hold m.mu or own m exclusively
+func (m *ProcessManager) load(config Config) {
+	m.supervisors = make(map[string]*ProcessSupervisor)
+	m.configs = make(map[string]ProcessConfig)
+	m.order = nil
+	m.groups = config.Groups
+	for _, processConfig := range config.Processes {
+		// 检查是否启用此配置
+		if !processConfig.Enable {
+			logrus.Infof("Skipping disabled process monitor: %s", processConfig.Name)
+			continue
+		}
+		m.supervisors[processConfig.Name] = NewProcessSupervisor(processConfig)
+		m.configs[processConfig.Name] = processConfig
+		m.order = append(m.order, processConfig.Name)
+	}
+}
+
+// Start launches a monitor goroutine for each supervisor
+func (m *ProcessManager) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runCtx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+	for _, name := range m.order {
+		m.wg.Add(1)
+		go func(name string, s *ProcessSupervisor) {
+			defer m.wg.Done()
+			runGuarded(runCtx, "supervisor "+name, name, s.Run)
+		}(name, m.supervisors[name])
+	}
+}
+
+// Replace stops supervising the current processes, leaving them running,
+// and starts supervising the processes of config instead. Processes present
+// in both configs are picked up again by name.
+func (m *ProcessManager) Replace(ctx context.Context, config Config) {
+	m.mu.Lock()
+	for _, s := range m.supervisors {
+		s.leaveRunning.Store(true)
+	}
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+	// 等待旧的监控退出，避免新旧监控同时启动同一个进程
+	m.wg.Wait()
+
+	m.mu.Lock()
+	m.load(config)
+	m.mu.Unlock()
+	m.Start(ctx)
+}
+
+// Get returns the supervisor for name
+func (m *ProcessManager) Get(name string) (*ProcessSupervisor, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.supervisors[name]
+	return s, ok
+}
+
+// ConfigsByName returns the configs of the supervised processes
+func (m *ProcessManager) ConfigsByName() map[string]ProcessConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	configs := make(map[string]ProcessConfig, len(m.configs))
+	for name, config := range m.configs {
+		configs[name] = config
+	}
+	return configs
+}
+
+// ProcessAction sends a control action to the named process
+func (m *ProcessManager) ProcessAction(ctx context.Context, name, action, reason string) error {
+	s, ok := m.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown process: %s", name)
+	}
+	return s.Send(ctx, action, reason)
+}
+
+// Statuses returns the status of every managed process sorted by name
+func (m *ProcessManager) Statuses() []ProcessStatus {
+	m.mu.RLock()
+	statuses := make([]ProcessStatus, 0, len(m.supervisors))
+	for _, s := range m.supervisors {
+		statuses = append(statuses, s.Status())
+	}
+	m.mu.RUnlock()
+	if networkHealth != nil {
+		statuses = append(statuses, networkHealth.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
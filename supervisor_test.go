@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResumeRestoresState(t *testing.T) {
+	// 手动停止的进程暂停后再恢复，仍然是 stopped
+	s := NewProcessSupervisor(ProcessConfig{Name: "pm-resume-test"})
+	s.stopped = true
+	for _, action := range []string{"pause", "resume"} {
+		if err := s.handleCommand(supervisorCommand{action: action}); err != nil {
+			t.Fatalf("%s: %v", action, err)
+		}
+	}
+	if state := s.Status().State; state != StateStopped {
+		t.Errorf("state after resuming a stopped process = %s, want %s", state, StateStopped)
+	}
+
+	// 暂停期间进程没有运行：恢复时立即检查，而不是直接报告 running
+	s = NewProcessSupervisor(ProcessConfig{Name: "pm-resume-test", RestartCommand: filepath.Join(t.TempDir(), "missing")})
+	for _, action := range []string{"pause", "resume"} {
+		if err := s.handleCommand(supervisorCommand{action: action}); err != nil {
+			t.Fatalf("%s: %v", action, err)
+		}
+	}
+	if state := s.Status().State; state == StateRunning || state == StatePaused {
+		t.Errorf("state after resuming a process that is not running = %s", state)
+	}
+	if entries := s.Timeline(TimelineFilter{}); len(entries) != 1 || entries[0].Result == TimelineOK {
+		t.Errorf("resume did not run a check round: %+v", entries)
+	}
+}
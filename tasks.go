@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// taskOutputLimit 失败事件中附带的任务输出（最后的部分）的最大长度
+const taskOutputLimit = 4096
+
+// TaskConfig 按 cron 表达式定时执行、或在监控程序启动时执行一次的命令
+type TaskConfig struct {
+	Name         string   `yaml:"name"`
+	Command      string   `yaml:"command"`
+	Args         []string `yaml:"args"`
+	WorkDir      string   `yaml:"work_dir"`
+	Schedule     string   `yaml:"schedule"`       // cron 表达式（分 时 日 月 星期），如 "30 2 * * *"，或 @hourly、@daily 等
+	RunAtStartup bool     `yaml:"run_at_startup"` // 监控程序启动时执行一次（没有 schedule 时只执行这一次）
+	Timeout      int      `yaml:"timeout"`        // 超时（秒），超时后结束任务并记为失败（0表示不限制）
+	AllowOverlap bool     `yaml:"allow_overlap"`  // 上一次还在执行时也开始新的一次（默认跳过）
+	Holidays     string   `yaml:"holidays"`       // 节假日日历文件（与进程的 schedule.holidays 格式相同），列出的日期不执行
+}
+
+// validate checks one task
+func (t TaskConfig) validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if t.Command == "" {
+		return fmt.Errorf("command is empty")
+	}
+	if t.Schedule == "" && !t.RunAtStartup {
+		return fmt.Errorf("schedule or run_at_startup is required")
+	}
+	if t.Schedule != "" {
+		if _, err := parseCron(t.Schedule); err != nil {
+			return err
+		}
+	}
+	if t.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if t.Holidays != "" {
+		if _, err := loadHolidayCalendar(t.Holidays); err != nil {
+			return fmt.Errorf("holidays: %v", err)
+		}
+	}
+	return nil
+}
+
+// taskTags returns the metric tags of a task
+func taskTags(name string) map[string]string {
+	return map[string]string{"task": name}
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
+}
+
+// taskRunner runs one task and prevents overlapping runs
+type taskRunner struct {
+	config  TaskConfig
+	running atomic.Int32 // 正在执行的次数
+	wg      sync.WaitGroup
+}
+
+// trigger starts a run in the background unless the previous one is still
+// running and overlap is not allowed
+func (r *taskRunner) trigger(ctx context.Context, reason string) {
+	if !r.config.AllowOverlap && r.running.Load() > 0 {
+		logrus.Warnf("Task %s is still running, skipping the %s run", r.config.Name, reason)
+		emitCount("task.skipped", 1, taskTags(r.config.Name))
+		return
+	}
+	r.running.Add(1)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.running.Add(-1)
+		r.run(ctx, reason)
+	}()
+}
+
+// run executes the task once and logs and reports the result
+func (r *taskRunner) run(ctx context.Context, reason string) error {
+	config := r.config
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.Timeout)*time.Second)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, config.Command, config.Args...)
+	cmd.Dir = config.WorkDir
+	cmd.Env = append(os.Environ(), "PM_TASK="+config.Name, "PM_TASK_REASON="+reason)
+	output := &tailBuffer{max: taskOutputLimit}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	logrus.Infof("Running task %s (%s): %s %v", config.Name, reason, config.Command, config.Args)
+	started := time.Now()
+	err := cmd.Run()
+	duration := time.Since(started)
+	emitTiming("task.duration", duration, taskTags(config.Name))
+
+	if err == nil {
+		logrus.Infof("Task %s finished successfully in %v", config.Name, duration.Round(time.Millisecond))
+		logrus.Debugf("Task %s output: %s", config.Name, output)
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %ds", config.Timeout)
+	} else if ctx.Err() != nil {
+		err = fmt.Errorf("cancelled: monitor is stopping")
+	}
+	logrus.Warnf("Task %s failed after %v: %v", config.Name, duration.Round(time.Millisecond), err)
+	emitCount("task.failures", 1, taskTags(config.Name))
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "task_failed",
+		Process:  config.Name,
+		Message:  fmt.Sprintf("Task %s failed: %v", config.Name, err),
+		Details: map[string]string{
+			"kind":        "task",
+			"reason":      reason,
+			"error":       err.Error(),
+			"exit_code":   strconv.Itoa(exitCode(cmd)),
+			"duration_ms": strconv.FormatInt(duration.Milliseconds(), 10),
+			"output":      strings.Join(lastLines([]byte(output.String()), 20), "\n"),
+		},
+	})
+	return err
+}
+
+// holiday reports whether t is on a date listed in the holiday calendar
+func (r *taskRunner) holiday(t time.Time) (string, bool) {
+	if r.config.Holidays == "" {
+		return "", false
+	}
+	cal, err := loadHolidayCalendar(r.config.Holidays)
+	if err != nil {
+		return "", false
+	}
+	day, ok := cal.lookup(t)
+	if !ok {
+		return "", false
+	}
+	return day.describe(t), true
+}
+
+// RunTask 执行一个任务：启动时执行一次和/或按 cron 表达式定时执行，直到 ctx 取消。
+// 返回前等待正在执行的任务结束（ctx 取消时任务被结束）。
+func RunTask(config TaskConfig, ctx context.Context) {
+	r := &taskRunner{config: config}
+	defer r.wg.Wait()
+
+	if config.RunAtStartup {
+		r.trigger(ctx, "startup")
+	}
+	if config.Schedule == "" {
+		return
+	}
+	schedule, err := parseCron(config.Schedule)
+	if err != nil {
+		logrus.Errorf("Task %s: %v", config.Name, err)
+		return
+	}
+	logrus.Infof("Scheduled task %s: %s", config.Name, config.Schedule)
+	for {
+		next := schedule.next(time.Now())
+		if next.IsZero() {
+			logrus.Warnf("Task %s: schedule %q never matches, not running it", config.Name, config.Schedule)
+			return
+		}
+		logrus.Debugf("Next run of task %s at %s", config.Name, next.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			if holiday, ok := r.holiday(next); ok {
+				logrus.Infof("Skipping task %s: %s", config.Name, holiday)
+				continue
+			}
+			r.trigger(ctx, "schedule")
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTaskHelperProcess is run as the task command by the tests below; the
+// mode follows "--" on the command line
+func TestTaskHelperProcess(t *testing.T) {
+	args := flag.Args()
+	if len(args) == 0 {
+		return
+	}
+	switch args[0] {
+	case "fail":
+		fmt.Println("disk is full")
+		os.Exit(3)
+	case "sleep":
+		time.Sleep(5 * time.Second)
+	}
+	os.Exit(0)
+}
+
+// helperTask returns a task running TestTaskHelperProcess in mode. The mode
+// is passed as an argument, not in the environment, so tasks still running
+// in the background do not race with the next helperTask call.
+func helperTask(name, mode string) TaskConfig {
+	return TaskConfig{Name: name, Command: os.Args[0], Args: []string{"-test.run=^TestTaskHelperProcess$", "--", mode}, RunAtStartup: true}
+}
+
+func TestTaskRun(t *testing.T) {
+	sink := &recordingSink{}
+	registerEventSink(sink)
+
+	r := &taskRunner{config: helperTask("backup-ok", "ok")}
+	if err := r.run(context.Background(), "startup"); err != nil {
+		t.Errorf("successful task: %v", err)
+	}
+
+	r = &taskRunner{config: helperTask("backup-fail", "fail")}
+	if err := r.run(context.Background(), "schedule"); err == nil {
+		t.Error("failing task reported success")
+	}
+	var failed *Event
+	for i := range sink.events {
+		if sink.events[i].Process == "backup-fail" {
+			failed = &sink.events[i]
+		}
+	}
+	if failed == nil || failed.Type != "task_failed" || failed.Details["exit_code"] != "3" || !strings.Contains(failed.Details["output"], "disk is full") {
+		t.Errorf("failure event = %+v", failed)
+	}
+
+	config := helperTask("backup-slow", "sleep")
+	config.Timeout = 1
+	r = &taskRunner{config: config}
+	started := time.Now()
+	if err := r.run(context.Background(), "schedule"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("slow task: %v, want timeout", err)
+	}
+	if time.Since(started) > 4*time.Second {
+		t.Error("timeout did not end the task")
+	}
+}
+
+func TestTaskOverlap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &taskRunner{config: helperTask("report", "sleep")}
+	r.trigger(ctx, "schedule")
+	r.trigger(ctx, "schedule")
+	if n := r.running.Load(); n != 1 {
+		t.Errorf("running = %d, want 1 (overlapping run skipped)", n)
+	}
+	cancel()
+	r.wg.Wait()
+
+	r = &taskRunner{config: helperTask("report-overlap", "sleep")}
+	r.config.AllowOverlap = true
+	ctx, cancel = context.WithCancel(context.Background())
+	r.trigger(ctx, "schedule")
+	r.trigger(ctx, "schedule")
+	if n := r.running.Load(); n != 2 {
+		t.Errorf("running = %d, want 2 with allow_overlap", n)
+	}
+	cancel()
+	r.wg.Wait()
+}
+
+func TestTaskValidate(t *testing.T) {
+	tests := []struct {
+		task TaskConfig
+		ok   bool
+	}{
+		{TaskConfig{Name: "a", Command: "backup.cmd", Schedule: "30 2 * * *"}, true},
+		{TaskConfig{Name: "a", Command: "warmup.cmd", RunAtStartup: true}, true},
+		{TaskConfig{Name: "a", Command: "backup.cmd"}, false},
+		{TaskConfig{Name: "a", Command: "backup.cmd", Schedule: "daily"}, false},
+		{TaskConfig{Name: "a", Schedule: "@daily"}, false},
+		{TaskConfig{Command: "backup.cmd", Schedule: "@daily"}, false},
+		{TaskConfig{Name: "a", Command: "backup.cmd", Schedule: "@daily", Timeout: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.task.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok=%v", tt.task, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TeamsConfig 通过 Microsoft Teams 传入 Webhook / Workflows 发送自适应卡片通知
+type TeamsConfig struct {
+	WebhookURL   string   `yaml:"webhook_url"`   // Teams 频道的 Webhook 地址，为空则不启用
+	MinSeverity  string   `yaml:"min_severity"`  // 发送的最低事件级别：info、warning（默认）或 critical
+	Events       []string `yaml:"events"`        // 只发送这些类型的事件（为空表示全部）
+	DashboardURL string   `yaml:"dashboard_url"` // Web 界面地址（如 http://monitor01:9500），卡片上的按钮链接到这里
+	Timeout      int      `yaml:"timeout"`       // 单次请求超时（秒，默认10）
+}
+
+// teamsQueueSize 待发送卡片的上限，Teams 不可用时丢弃更多的事件而不是阻塞监控
+const teamsQueueSize = 100
+
+// teamsNotifier is an EventSink posting adaptive cards to a Teams channel
+type teamsNotifier struct {
+	config TeamsConfig
+	client *http.Client
+	queue  chan Event
+}
+
+func newTeamsNotifier(config TeamsConfig) *teamsNotifier {
+	if config.MinSeverity == "" {
+		config.MinSeverity = SeverityWarning
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10
+	}
+	return &teamsNotifier{
+		config: config,
+		client: &http.Client{Timeout: time.Duration(config.Timeout) * time.Second},
+		queue:  make(chan Event, teamsQueueSize),
+	}
+}
+
+// HandleEvent queues e if it passes the severity and type filters
+func (t *teamsNotifier) HandleEvent(e Event) {
+	if severityRank(e.Severity) < severityRank(t.config.MinSeverity) {
+		return
+	}
+	if !matchesAny(t.config.Events, e.Type) {
+		return
+	}
+	select {
+	case t.queue <- e:
+	default:
+		logrus.Warnf("Teams notification queue is full, dropping %s event for %s", e.Type, e.Process)
+	}
+}
+
+// Run posts queued events until ctx is done
+func (t *teamsNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case e := <-t.queue:
+			if err := postJSON(ctx, t.client, t.config.WebhookURL, nil, t.card(e)); err != nil {
+				logrus.Errorf("Failed to send Teams notification for %s: %v", e.Type, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// teamsColor maps severities to adaptive card text colors
+func teamsColor(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "attention"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "good"
+	}
+}
+
+// card renders e as a Teams message carrying one adaptive card
+func (t *teamsNotifier) card(e Event) map[string]interface{} {
+	title := strings.ToUpper(e.Severity) + ": " + e.Type
+	if e.Process != "" {
+		title += " - " + e.Process
+	}
+
+	type fact struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+	}
+	var facts []fact
+	for _, f := range eventHost(e).facts() {
+		facts = append(facts, fact{f[0], f[1]})
+	}
+	if e.Process != "" {
+		facts = append(facts, fact{"Process", e.Process})
+	}
+	if e.CorrelationID != "" {
+		facts = append(facts, fact{"Correlation ID", e.CorrelationID})
+	}
+	facts = append(facts, fact{"Event", e.Type}, fact{"Time", e.Time.Format(time.RFC3339)})
+	keys := make([]string, 0, len(e.Details))
+	for k := range e.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		facts = append(facts, fact{k, e.Details[k]})
+	}
+
+	content := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"msteams": map[string]string{"width": "Full"},
+		"body": []interface{}{
+			map[string]interface{}{
+				"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium",
+				"color": teamsColor(e.Severity), "wrap": true,
+			},
+			map[string]interface{}{"type": "TextBlock", "text": e.Message, "wrap": true},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+		},
+	}
+	if actions := t.actions(e); len(actions) > 0 {
+		content["actions"] = actions
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content":     content,
+			},
+		},
+	}
+}
+
+// actions links the card to the monitor's web UI, when dashboard_url is set
+func (t *teamsNotifier) actions(e Event) []interface{} {
+	base := strings.TrimRight(t.config.DashboardURL, "/")
+	if base == "" {
+		return nil
+	}
+	link := func(title, target string) interface{} {
+		return map[string]string{"type": "Action.OpenUrl", "title": title, "url": base + target}
+	}
+	actions := []interface{}{link("Open dashboard", "/")}
+	if e.Process != "" {
+		actions = append(actions,
+			link("Process status", "/processes/"+url.PathEscape(e.Process)),
+			link("Recent events", "/events?process="+url.QueryEscape(e.Process)))
+	}
+	return actions
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTeamsNotifierFilters(t *testing.T) {
+	n := newTeamsNotifier(TeamsConfig{WebhookURL: "http://unused", Events: []string{"circuit_open", "restart"}})
+	tests := []struct {
+		event  Event
+		queued bool
+	}{
+		{Event{Severity: SeverityCritical, Type: "circuit_open"}, true},
+		{Event{Severity: SeverityInfo, Type: "restart"}, false}, // 低于默认的 warning
+		{Event{Severity: SeverityCritical, Type: "config_invalid"}, false},
+	}
+	for _, tt := range tests {
+		n.HandleEvent(tt.event)
+		queued := len(n.queue) == 1
+		if queued != tt.queued {
+			t.Errorf("%s/%s: queued = %v, want %v", tt.event.Severity, tt.event.Type, queued, tt.queued)
+		}
+		for len(n.queue) > 0 {
+			<-n.queue
+		}
+	}
+}
+
+func TestTeamsNotifierPostsAdaptiveCard(t *testing.T) {
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer srv.Close()
+
+	n := newTeamsNotifier(TeamsConfig{WebhookURL: srv.URL, DashboardURL: "http://monitor01:9500/"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.HandleEvent(Event{
+		Time:     time.Now(),
+		Severity: SeverityCritical,
+		Type:     "circuit_open",
+		Process:  "api server.exe",
+		Message:  "Automatic restarts stopped",
+		Details:  map[string]string{"reason": "process exited"},
+	})
+
+	var body string
+	select {
+	case body = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no card was posted")
+	}
+
+	var msg struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type    string `json:"type"`
+				Actions []struct {
+					URL string `json:"url"`
+				} `json:"actions"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "message" || len(msg.Attachments) != 1 ||
+		msg.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" ||
+		msg.Attachments[0].Content.Type != "AdaptiveCard" {
+		t.Fatalf("unexpected payload: %s", body)
+	}
+	for _, want := range []string{"api server.exe", "process exited", `"attention"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("card does not contain %s", want)
+		}
+	}
+	actions := msg.Attachments[0].Content.Actions
+	if len(actions) != 3 || actions[1].URL != "http://monitor01:9500/processes/api%20server.exe" {
+		t.Errorf("unexpected actions: %+v", actions)
+	}
+}
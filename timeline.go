@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// timelineSize 每个进程保留的最近检查记录数
+const timelineSize = 500
+
+// 一轮检查的结论
+const (
+	TimelineOK        = "ok"        // 进程在运行，检查全部通过
+	TimelineDegraded  = "degraded"  // 进程在运行，有检查失败但未达到 failure_threshold，或尚未报告就绪
+	TimelineRestart   = "restart"   // 决定重启（或提升备用实例）
+	TimelinePostponed = "postponed" // 需要重启，但因网络中断、退避或熔断推迟
+	TimelineExited    = "exited"    // 进程以 ignore_exit_codes 中的退出码有意退出
+	TimelineSkipped   = "skipped"   // 已停止、暂停或不在 schedule 运行时间内，未检查
+)
+
+// CheckOutcome is the result of one port or health check within a round
+type CheckOutcome struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// TimelineEntry records one round of checks of a process: when the monitor
+// looked at it, how long that took and what it concluded
+type TimelineEntry struct {
+	Time       time.Time      `json:"time"`
+	DurationMs float64        `json:"duration_ms"`
+	PID        int            `json:"pid,omitempty"`
+	Result     string         `json:"result"`
+	Reason     string         `json:"reason,omitempty"`
+	Checks     []CheckOutcome `json:"checks,omitempty"`
+}
+
+// checkTimeline keeps the most recent timelineSize rounds of a process
+type checkTimeline struct {
+	mu      sync.Mutex
+	entries []TimelineEntry
+	next    int // 缓冲区满后下一条记录写入的位置
+}
+
+func (t *checkTimeline) add(e TimelineEntry) {
+	e.DurationMs = float64(time.Since(e.Time).Microseconds()) / 1000
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.entries) < timelineSize {
+		t.entries = append(t.entries, e)
+		return
+	}
+	t.entries[t.next] = e
+	t.next = (t.next + 1) % timelineSize
+}
+
+// TimelineFilter selects timeline entries; zero values match everything
+type TimelineFilter struct {
+	Since time.Time
+	Check string // 只返回包含这项检查的记录，且只保留这项检查的结果
+	Limit int
+}
+
+// query returns the entries matching f, newest first
+func (t *checkTimeline) query(f TimelineFilter) []TimelineEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := []TimelineEntry{}
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		e := t.entries[(t.next+i)%len(t.entries)]
+		if e.Time.Before(f.Since) {
+			break
+		}
+		if f.Check != "" {
+			var matched []CheckOutcome
+			for _, c := range e.Checks {
+				if c.Name == f.Check {
+					matched = append(matched, c)
+				}
+			}
+			if matched == nil {
+				continue
+			}
+			e.Checks = matched
+		}
+		result = append(result, e)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// Timeline returns the recent check rounds of the process, newest first
+func (s *ProcessSupervisor) Timeline(f TimelineFilter) []TimelineEntry {
+	return s.timeline.query(f)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckTimeline(t *testing.T) {
+	tl := &checkTimeline{}
+	start := time.Now().Add(-time.Hour)
+	// 超过容量后只保留最近的记录
+	for i := 0; i < timelineSize+10; i++ {
+		e := TimelineEntry{Time: start.Add(time.Duration(i) * time.Second), Result: TimelineOK, PID: i}
+		if i%2 == 0 {
+			e.Checks = []CheckOutcome{{Name: "port:80"}, {Name: "http://localhost/health", Error: "status 503"}}
+		}
+		tl.add(e)
+	}
+
+	all := tl.query(TimelineFilter{})
+	if len(all) != timelineSize || all[0].PID != timelineSize+9 || all[len(all)-1].PID != 10 {
+		t.Fatalf("kept %d entries, newest PID %d, oldest PID %d", len(all), all[0].PID, all[len(all)-1].PID)
+	}
+
+	recent := tl.query(TimelineFilter{Since: start.Add(time.Duration(timelineSize) * time.Second), Limit: 5})
+	if len(recent) != 5 || recent[0].PID != timelineSize+9 {
+		t.Errorf("limited query = %d entries", len(recent))
+	}
+	if since := tl.query(TimelineFilter{Since: start.Add(time.Duration(timelineSize+5) * time.Second)}); len(since) != 5 {
+		t.Errorf("since query = %d entries, want 5", len(since))
+	}
+
+	checks := tl.query(TimelineFilter{Check: "http://localhost/health", Limit: 3})
+	for _, e := range checks {
+		if e.PID%2 != 0 || len(e.Checks) != 1 || e.Checks[0].Error != "status 503" {
+			t.Errorf("check query returned %+v", e)
+		}
+	}
+	if len(checks) != 3 {
+		t.Errorf("check query = %d entries, want 3", len(checks))
+	}
+}
+
+func TestCheckRecordsTimeline(t *testing.T) {
+	s := NewProcessSupervisor(ProcessConfig{Name: "pm-timeline-test"})
+	s.stopped = true
+	s.check()
+	entries := s.Timeline(TimelineFilter{})
+	if len(entries) != 1 || entries[0].Result != TimelineSkipped {
+		t.Fatalf("timeline = %+v", entries)
+	}
+	if len(entries[0].Checks) != 0 {
+		t.Errorf("skipped round ran %d checks", len(entries[0].Checks))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// TimeoutConfig 各类操作的超时，防止WMI提供程序挂起或无法结束的僵尸进程卡住监控循环
+type TimeoutConfig struct {
+	ProcessScan int `yaml:"process_scan"` // 枚举进程并读取路径/命令行（秒，默认30）
+	Kill        int `yaml:"kill"`         // 结束进程并等待其退出（秒，默认15）
+	Command     int `yaml:"command"`      // 外部命令，如采样工具（秒，默认600）
+}
+
+// opTimeouts is set from the config at startup
+var opTimeouts = timeoutDefaults(TimeoutConfig{})
+
+func timeoutDefaults(config TimeoutConfig) TimeoutConfig {
+	if config.ProcessScan <= 0 {
+		config.ProcessScan = 30
+	}
+	if config.Kill <= 0 {
+		config.Kill = 15
+	}
+	if config.Command <= 0 {
+		config.Command = 600
+	}
+	return config
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+var (
+	timedOutMu  sync.Mutex
+	timedOutOps = make(map[string]int64)
+)
+
+// timedOutOperations returns how often each operation exceeded its deadline
+func timedOutOperations() map[string]int64 {
+	timedOutMu.Lock()
+	defer timedOutMu.Unlock()
+	result := make(map[string]int64, len(timedOutOps))
+	for op, n := range timedOutOps {
+		result[op] = n
+	}
+	return result
+}
+
+// reportDeadlineExceeded records and reports an operation that ran too long
+func reportDeadlineExceeded(op string, timeout time.Duration) {
+	timedOutMu.Lock()
+	timedOutOps[op]++
+	timedOutMu.Unlock()
+	emitCount("operation.timeouts", 1, map[string]string{"operation": op})
+	emitEvent(Event{
+		Severity: SeverityWarning,
+		Type:     "operation_timeout",
+		Message:  fmt.Sprintf("%s exceeded its %v deadline and was abandoned", op, timeout),
+		Details:  map[string]string{"operation": op},
+	})
+}
+
+// runWithTimeout runs fn with a context that expires after timeout. Calls
+// that do not honour the context (e.g. a hung WMI query) are abandoned: the
+// caller gets an error immediately and the goroutine finishes on its own.
+func runWithTimeout(op string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		// 被放弃的 goroutine 中的 panic 无法被调用方的保护捕获
+		defer func() {
+			if r := recover(); r != nil {
+				internalErrors.record("panic", fmt.Sprintf("%s panicked: %v", op, r))
+				done <- fmt.Errorf("%s panicked: %v", op, r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		reportDeadlineExceeded(op, timeout)
+		return fmt.Errorf("%s timed out after %v", op, timeout)
+	}
+}
+
+// matchingProcesses returns all instances of config according to its
+// match_mode, bounded by the process_scan timeout
+func matchingProcesses(config ProcessConfig) ([]*process.Process, error) {
+	matcher, err := newProcessMatcher(config)
+	if err != nil {
+		return nil, fmt.Errorf("process %s: %v", config.Name, err)
+	}
+	var matches []*process.Process
+	err = runWithTimeout("process scan", seconds(opTimeouts.ProcessScan), func(ctx context.Context) error {
+		entries, err := procTable.snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		var found []*process.Process
+		for _, e := range entries {
+			if matcher.matchesEntry(e) && processAlive(ctx, e) {
+				found = append(found, e.proc)
+			}
+		}
+		matches = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// processAlive reports whether the process of a possibly outdated process
+// table entry is still running
+func processAlive(ctx context.Context, e processEntry) bool {
+	running, err := e.proc.IsRunningWithContext(ctx)
+	return err == nil && running
+}
+
+// killWithTimeout kills p, bounded by the kill timeout
+func killWithTimeout(name string, p *process.Process) error {
+	defer procTable.invalidate()
+	return runWithTimeout("kill "+name, seconds(opTimeouts.Kill), func(ctx context.Context) error {
+		return killProcessWithHelper(name, p)
+	})
+}
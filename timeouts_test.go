@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunWithTimeout(t *testing.T) {
+	// 不理会 context 的操作也必须在超时后返回
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	err := runWithTimeout("test hung op", 50*time.Millisecond, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("runWithTimeout did not return promptly")
+	}
+	if timedOutOperations()["test hung op"] != 1 {
+		t.Error("timed out operation was not recorded")
+	}
+
+	err = runWithTimeout("test panic op", time.Second, func(ctx context.Context) error {
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("expected panic to be converted to an error, got %v", err)
+	}
+
+	if err := runWithTimeout("test fast op", time.Second, func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
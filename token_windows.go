@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// x/sys/windows 未封装 CreateRestrictedToken 和 LogonUserW
+var (
+	procCreateRestrictedToken = windows.NewLazySystemDLL("advapi32.dll").NewProc("CreateRestrictedToken")
+	procLogonUserW            = windows.NewLazySystemDLL("advapi32.dll").NewProc("LogonUserW")
+)
+
+// LogonUser 的登录类型
+var logonTypes = map[string]uintptr{
+	"":            2, // LOGON32_LOGON_INTERACTIVE
+	"interactive": 2,
+	"batch":       4, // LOGON32_LOGON_BATCH，需要"作为批处理作业登录"权限
+	"service":     5, // LOGON32_LOGON_SERVICE，需要"作为服务登录"权限
+}
+
+const (
+	disableMaxPrivilege = 0x1 // 删除除 SeChangeNotifyPrivilege 外的所有特权
+	luaToken            = 0x4 // 生成与 UAC 过滤令牌相同的受限令牌
+)
+
+// launchToken builds the primary token a child is started with when it sets
+// integrity_level or restricted_token, runs in another user's session or
+// as run_as_user. A zero token means inherit the monitor's. The caller must
+// close a non-zero token after the process has started.
+func launchToken(config ProcessConfig) (windows.Token, error) {
+	sessionToken, err := sessionUserToken(config)
+	if err != nil {
+		return 0, err
+	}
+	if sessionToken == 0 && config.RunAsUser != "" {
+		if sessionToken, err = logonUserToken(config); err != nil {
+			return 0, err
+		}
+	}
+	if !config.RestrictedToken && config.IntegrityLevel == "" {
+		return sessionToken, nil
+	}
+
+	labelType, err := integrityLabel(config.IntegrityLevel)
+	if err != nil {
+		if sessionToken != 0 {
+			sessionToken.Close()
+		}
+		return 0, err
+	}
+
+	// 在会话用户令牌或监控程序自身令牌的基础上降低权限
+	self := sessionToken
+	if self == 0 {
+		access := uint32(windows.TOKEN_DUPLICATE | windows.TOKEN_QUERY | windows.TOKEN_ASSIGN_PRIMARY | windows.TOKEN_ADJUST_DEFAULT)
+		if err := windows.OpenProcessToken(windows.CurrentProcess(), access, &self); err != nil {
+			return 0, fmt.Errorf("failed to open monitor token: %v", err)
+		}
+	}
+	defer self.Close()
+
+	var token windows.Token
+	if config.RestrictedToken {
+		adminSid, err := windows.CreateWellKnownSid(windows.WinBuiltinAdministratorsSid)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create Administrators SID: %v", err)
+		}
+		// Administrators 组变为仅拒绝（deny-only），子进程无法再使用管理员权限
+		disable := []windows.SIDAndAttributes{{Sid: adminSid}}
+		r, _, e := procCreateRestrictedToken.Call(
+			uintptr(self),
+			disableMaxPrivilege|luaToken,
+			uintptr(len(disable)), uintptr(unsafe.Pointer(&disable[0])),
+			0, 0,
+			0, 0,
+			uintptr(unsafe.Pointer(&token)))
+		if r == 0 {
+			return 0, fmt.Errorf("CreateRestrictedToken failed: %v", e)
+		}
+	} else {
+		if err := windows.DuplicateTokenEx(self, windows.MAXIMUM_ALLOWED, nil, windows.SecurityImpersonation, windows.TokenPrimary, &token); err != nil {
+			return 0, fmt.Errorf("failed to duplicate monitor token: %v", err)
+		}
+	}
+
+	if labelType != 0 {
+		labelSid, err := windows.CreateWellKnownSid(labelType)
+		if err != nil {
+			token.Close()
+			return 0, fmt.Errorf("failed to create integrity SID: %v", err)
+		}
+		// 降低完整性级别不需要额外特权
+		label := windows.Tokenmandatorylabel{
+			Label: windows.SIDAndAttributes{Sid: labelSid, Attributes: windows.SE_GROUP_INTEGRITY},
+		}
+		if err := windows.SetTokenInformation(token, windows.TokenIntegrityLevel, (*byte)(unsafe.Pointer(&label)), label.Size()); err != nil {
+			token.Close()
+			return 0, fmt.Errorf("failed to set integrity level %s: %v", config.IntegrityLevel, err)
+		}
+	}
+
+	return token, nil
+}
+
+// integrityLabel returns the mandatory label SID type of integrity_level;
+// zero when no level is configured
+func integrityLabel(level string) (windows.WELL_KNOWN_SID_TYPE, error) {
+	switch strings.ToLower(level) {
+	case "":
+		return 0, nil
+	case "low":
+		return windows.WinLowLabelSid, nil
+	case "medium":
+		return windows.WinMediumLabelSid, nil
+	}
+	return 0, fmt.Errorf("invalid integrity_level %q: must be low or medium", level)
+}
+
+// logonUserToken logs on run_as_user with its password and returns the
+// primary token of the account. Starting a process with it requires
+// SeAssignPrimaryTokenPrivilege, i.e. the monitor running as LocalSystem.
+func logonUserToken(config ProcessConfig) (windows.Token, error) {
+	logonType, ok := logonTypes[strings.ToLower(config.RunAsLogon)]
+	if !ok {
+		return 0, fmt.Errorf("invalid run_as_logon %q: must be interactive, batch or service", config.RunAsLogon)
+	}
+	domain, user := splitAccount(config.RunAsUser)
+	userPtr, err := windows.UTF16PtrFromString(user)
+	if err != nil {
+		return 0, err
+	}
+	var domainPtr *uint16
+	if domain != "" {
+		if domainPtr, err = windows.UTF16PtrFromString(domain); err != nil {
+			return 0, err
+		}
+	}
+	passwordPtr, err := windows.UTF16PtrFromString(config.RunAsPassword)
+	if err != nil {
+		return 0, err
+	}
+	var token windows.Token
+	r, _, e := procLogonUserW.Call(
+		uintptr(unsafe.Pointer(userPtr)),
+		uintptr(unsafe.Pointer(domainPtr)),
+		uintptr(unsafe.Pointer(passwordPtr)),
+		logonType,
+		0, // LOGON32_PROVIDER_DEFAULT
+		uintptr(unsafe.Pointer(&token)))
+	if r == 0 {
+		return 0, fmt.Errorf("failed to log on as %s: %v", config.RunAsUser, e)
+	}
+	return token, nil
+}
+
+// splitAccount splits DOMAIN\user; a UPN (user@domain) is passed as the user
+// name without domain, a bare name is a local account (domain ".")
+func splitAccount(account string) (domain, user string) {
+	if i := strings.Index(account, `\`); i >= 0 {
+		return account[:i], account[i+1:]
+	}
+	if strings.Contains(account, "@") {
+		return "", account
+	}
+	return ".", account
+}
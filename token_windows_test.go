@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestIntegrityLabel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  windows.WELL_KNOWN_SID_TYPE
+		ok    bool
+	}{
+		{"", 0, true},
+		{"low", windows.WinLowLabelSid, true},
+		{"Medium", windows.WinMediumLabelSid, true},
+		{"high", 0, false},
+		{"system", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := integrityLabel(tt.level)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("integrityLabel(%q) = %v, %v, want %v ok=%v", tt.level, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLaunchToken(t *testing.T) {
+	// 没有配置令牌选项时继承监控程序的令牌
+	token, err := launchToken(ProcessConfig{Name: "api.exe"})
+	if err != nil || token != 0 {
+		t.Errorf("no options: token = %v, err = %v", token, err)
+	}
+	if _, err := launchToken(ProcessConfig{Name: "api.exe", IntegrityLevel: "high"}); err == nil || !strings.Contains(err.Error(), "integrity_level") {
+		t.Errorf("invalid level: err = %v", err)
+	}
+
+	// 受限令牌不再是提升的令牌
+	token, err = launchToken(ProcessConfig{Name: "api.exe", RestrictedToken: true, IntegrityLevel: "low"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer token.Close()
+	if token.IsElevated() {
+		t.Error("restricted token is elevated")
+	}
+}
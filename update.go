@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// executablePath returns the program file started for config, resolved the
+// same way startProcess does (relative to work_dir when one is set).
+func executablePath(config ProcessConfig) (string, error) {
+	name := config.Name
+	if config.RestartCommand != "" {
+		name = config.RestartCommand
+	}
+	if !filepath.IsAbs(name) && config.WorkDir != "" {
+		name = filepath.Join(config.WorkDir, name)
+	}
+	return filepath.Abs(name)
+}
+
+// update replaces the program file of the process with newBinary and starts
+// the new version. Other processes still holding the file open (e.g. a
+// crash reporter or a second instance started outside the monitor) are
+// closed and restarted through the Restart Manager on Windows, so the swap
+// does not fail with "file in use". The old file is kept as <name>.old and
+// restored if the new version cannot be installed or started.
+func (s *ProcessSupervisor) update(newBinary, reason string) error {
+	target, err := executablePath(s.config)
+	if err != nil {
+		return fmt.Errorf("failed to resolve executable of %s: %v", s.config.Name, err)
+	}
+	info, err := os.Stat(newBinary)
+	if err != nil {
+		return fmt.Errorf("new binary for %s: %v", s.config.Name, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("new binary for %s is a directory: %s", s.config.Name, newBinary)
+	}
+	if abs, err := filepath.Abs(newBinary); err == nil && strings.EqualFold(abs, target) {
+		return fmt.Errorf("new binary for %s is the current executable", s.config.Name)
+	}
+
+	logrus.Warnf("Updating %s: replacing %s with %s (%s)", s.config.Name, target, newBinary, reason)
+	s.updateStatus(func(st *ProcessStatus) { st.State = StateRestarting })
+	// 备用实例也在使用旧的程序文件，更新后由下一次检查重新启动
+	s.stopStandby()
+	s.kill()
+
+	// 被监控进程已停止，剩下仍占用文件的是其他进程
+	restartLockers, err := releaseFileLocks([]string{target})
+	if err != nil {
+		logrus.Warnf("Failed to release file locks on %s, trying to replace it anyway: %v", target, err)
+	}
+	defer restartLockers()
+
+	backup := target + ".old"
+	if err := replaceFile(target, backup, newBinary); err != nil {
+		s.start(true)
+		return fmt.Errorf("failed to update %s: %v", s.config.Name, err)
+	}
+
+	emitCount("restarts", 1, processTags(s.config.Name))
+	s.updateStatus(func(st *ProcessStatus) {
+		st.Restarts++
+		st.LastRestart = time.Now()
+		st.LastRestartReason = "update: " + reason
+	})
+	if err := s.start(true); err != nil {
+		// 新版本启动失败，恢复旧程序
+		logrus.Errorf("Updated %s failed to start, rolling back: %v", s.config.Name, err)
+		if rbErr := restoreFile(target, backup); rbErr != nil {
+			return fmt.Errorf("update of %s failed to start (%v) and rollback failed: %v", s.config.Name, err, rbErr)
+		}
+		s.start(true)
+		emitEvent(Event{
+			Severity: SeverityCritical,
+			Type:     "update_failed",
+			Process:  s.config.Name,
+			Message:  fmt.Sprintf("Updated binary of %s failed to start, previous version restored", s.config.Name),
+			Details:  map[string]string{"binary": newBinary, "error": err.Error()},
+		})
+		return fmt.Errorf("updated %s failed to start, rolled back: %v", s.config.Name, err)
+	}
+
+	emitEvent(Event{
+		Severity: SeverityInfo,
+		Type:     "binary_updated",
+		Process:  s.config.Name,
+		Message:  fmt.Sprintf("Process %s updated from %s", s.config.Name, newBinary),
+		Details:  map[string]string{"binary": newBinary, "backup": backup},
+	})
+	return nil
+}
+
+// replaceFile moves target aside to backup and copies src into its place,
+// putting the old file back if the copy fails.
+func replaceFile(target, backup, src string) error {
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+		os.Remove(backup)
+		if err := os.Rename(target, backup); err != nil {
+			return fmt.Errorf("failed to move %s aside: %v", target, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := copyFile(src, target, mode); err != nil {
+		os.Remove(target)
+		if _, statErr := os.Stat(backup); statErr == nil {
+			os.Rename(backup, target)
+		}
+		return err
+	}
+	return nil
+}
+
+// restoreFile puts backup back in place of target
+func restoreFile(target, backup string) error {
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(backup, target)
+}
+
+// copyFile copies src to dest, creating dest with mode
+func copyFile(src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Update asks the supervisor to replace its program file with newBinary
+func (s *ProcessSupervisor) Update(ctx context.Context, newBinary, reason string) error {
+	return s.send(ctx, supervisorCommand{action: "update", reason: reason, arg: newBinary})
+}
+
+// runUpdateCommand implements "processmonitor update <process> <new binary>"
+// over the control channel. The update runs the new binary with the
+// monitor's rights, so it is not offered on the HTTP API.
+func runUpdateCommand(config Config, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: processmonitor update <process> <new binary>")
+	}
+	binary, err := filepath.Abs(args[1])
+	if err != nil {
+		return err
+	}
+	if _, err := controlRoundTrip(config, controlRequest{Command: "update", Process: args[0], Binary: binary}); err != nil {
+		return err
+	}
+	fmt.Printf("updated %s\n", args[0])
+	return nil
+}
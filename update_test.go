@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecutablePath(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		config ProcessConfig
+		want   string
+	}{
+		{"relative to work_dir", ProcessConfig{Name: "app", WorkDir: dir}, filepath.Join(dir, "app")},
+		{"restart command wins", ProcessConfig{Name: "app", RestartCommand: "launcher", WorkDir: dir}, filepath.Join(dir, "launcher")},
+		{"absolute name", ProcessConfig{Name: filepath.Join(dir, "abs"), WorkDir: "/elsewhere"}, filepath.Join(dir, "abs")},
+	}
+	for _, tt := range tests {
+		got, err := executablePath(tt.config)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceFileKeepsBackup(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app")
+	backup := target + ".old"
+	src := filepath.Join(dir, "app.new")
+	os.WriteFile(target, []byte("v1"), 0755)
+	os.WriteFile(src, []byte("v2"), 0644)
+
+	if err := replaceFile(target, backup, src); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "v2" {
+		t.Errorf("target = %q, want v2", data)
+	}
+	if data, _ := os.ReadFile(backup); string(data) != "v1" {
+		t.Errorf("backup = %q, want v1", data)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0755 {
+		t.Errorf("target mode = %v, want the mode of the old file", info.Mode().Perm())
+	}
+
+	if err := restoreFile(target, backup); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "v1" {
+		t.Errorf("restored target = %q, want v1", data)
+	}
+}
+
+func TestReplaceFileRollsBackOnMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app")
+	os.WriteFile(target, []byte("v1"), 0755)
+
+	if err := replaceFile(target, target+".old", filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected an error for a missing source")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "v1" {
+		t.Errorf("target = %q after failed replace, want v1", data)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configProblem is one finding of the validate command
+type configProblem struct {
+	line    int // 配置文件中的行号，0 表示无法确定
+	msg     string
+	warning bool // 警告不影响校验结果
+}
+
+func (p configProblem) format(path string) string {
+	kind := "error"
+	if p.warning {
+		kind = "warning"
+	}
+	if p.line > 0 {
+		path += ":" + strconv.Itoa(p.line)
+	}
+	return fmt.Sprintf("%s: %s: %s", path, kind, p.msg)
+}
+
+// runValidateCommand implements "processmonitor validate [-config config.yaml]":
+// it checks the config file without starting anything and prints every
+// problem with its line
+func runValidateCommand(configFile string, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	path := fs.String("config", configFile, "config file to check")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: processmonitor validate [-config config.yaml]")
+	}
+
+	problems, err := checkConfigFile(*path)
+	if err != nil {
+		return err
+	}
+	errors, warnings := 0, 0
+	for _, p := range problems {
+		fmt.Println(p.format(*path))
+		if p.warning {
+			warnings++
+		} else {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("%s: %d error(s), %d warning(s)", *path, errors, warnings)
+	}
+	fmt.Printf("%s: valid, %d warning(s)\n", *path, warnings)
+	return nil
+}
+
+// checkConfigFile parses the config file strictly and returns its problems
+// in the order of their lines: YAML errors, unknown fields, the problems of
+// validateConfig and those of the machine (see environmentProblems)
+func checkConfigFile(path string) ([]configProblem, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		// 语法错误时无法继续检查
+		return []configProblem{yamlProblem(err.Error())}, nil
+	}
+	if len(root.Content) == 0 {
+		return []configProblem{{msg: "config file is empty"}}, nil
+	}
+
+	var problems []configProblem
+	var config Config
+	if err := root.Decode(&config); err != nil {
+		typeErr, ok := err.(*yaml.TypeError)
+		if !ok {
+			return []configProblem{yamlProblem(err.Error())}, nil
+		}
+		for _, msg := range typeErr.Errors {
+			problems = append(problems, yamlProblem(msg))
+		}
+	}
+	problems = append(problems, unknownFields(root.Content[0], reflect.TypeOf(config), "")...)
+
+	applyConfigDefaults(&config)
+	index := configIndex{root.Content[0]}
+	errors, warnings := environmentProblems(config)
+	for _, msg := range append(configProblems(config), errors...) {
+		problems = append(problems, configProblem{line: index.locate(msg), msg: msg})
+	}
+	for _, msg := range warnings {
+		problems = append(problems, configProblem{line: index.locate(msg), msg: msg, warning: true})
+	}
+
+	// 无法确定行号的问题排在最后
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i].line, problems[j].line
+		return a != 0 && (b == 0 || a < b)
+	})
+	return problems, nil
+}
+
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlProblem turns a YAML error such as "yaml: line 3: ..." into a problem
+// at that line
+func yamlProblem(msg string) configProblem {
+	if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return configProblem{line: line, msg: m[2]}
+	}
+	return configProblem{msg: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+// yamlFields returns the types of the fields of struct t by YAML key
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		switch {
+		case name == "-":
+		case strings.Contains(opts, "inline"):
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+		case name == "":
+			fields[strings.ToLower(f.Name)] = f.Type
+		default:
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+// unknownFields reports the keys at any depth of node that no field of t
+// takes, which yaml.Unmarshal silently ignores. A misspelt option would
+// otherwise keep its default without notice.
+func unknownFields(node *yaml.Node, t reflect.Type, path string) []configProblem {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var problems []configProblem
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown field %q", key.Value)
+				if path != "" {
+					msg = path + ": " + msg
+				}
+				problems = append(problems, configProblem{line: key.Line, msg: msg})
+				continue
+			}
+			problems = append(problems, unknownFields(value, field, joinConfigPath(path, key.Value))...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			problems = append(problems, unknownFields(node.Content[i+1], t.Elem(), joinConfigPath(path, node.Content[i].Value))...)
+		}
+	case node.Kind == yaml.SequenceNode && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		for i, item := range node.Content {
+			problems = append(problems, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return problems
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// configIndex finds the lines of the problems of validateConfig
+type configIndex struct {
+	root *yaml.Node
+}
+
+// 以名称开头的问题（如 "process web.exe: ..."）所在的配置节
+var namedConfigSections = []struct{ prefix, path string }{
+	{"process ", "processes"},
+	{"group ", "groups"},
+	{"task ", "tasks"},
+	{"file monitor ", "file_monitors"},
+	{"service monitor ", "service_monitors"},
+	{"iis app pool ", "iis.app_pools"},
+	{"COM+ monitor ", "complus_apps"},
+}
+
+var (
+	indexedProblem    = regexp.MustCompile(`^([a-z_]+(?:\.[a-z_]+)*)\[(\d+)\]`)
+	sectionProblem    = regexp.MustCompile(`^[a-z_]+(?:\.[a-z_]+)*`)
+	configPathMention = regexp.MustCompile(`\b[a-z_]+(?:\.[a-z_]+)+\b`)
+)
+
+// mappingValue returns the key and value nodes of key in a mapping node
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// lookup returns the node at a dotted path and the deepest key found on
+// the way
+func (x configIndex) lookup(path string) (value, lastKey *yaml.Node) {
+	value = x.root
+	for _, key := range strings.Split(path, ".") {
+		k, v := mappingValue(value, key)
+		if k == nil {
+			return nil, lastKey
+		}
+		value, lastKey = v, k
+	}
+	return value, lastKey
+}
+
+// locate returns the line a problem is about, 0 when it cannot be told: the
+// line of the named or numbered item or of the section the problem starts
+// with, refined to the key of that item the problem mentions first
+func (x configIndex) locate(msg string) int {
+	for _, section := range namedConfigSections {
+		if !strings.HasPrefix(msg, section.prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(msg, section.prefix)
+		items, _ := x.lookup(section.path)
+		if items == nil || items.Kind != yaml.SequenceNode {
+			continue
+		}
+		// 同名的项中优先选择含有问题所提到的键的项；重复定义的问题指向最后一个同名项
+		line := 0
+		for _, item := range items.Content {
+			_, name := mappingValue(item, "name")
+			if name == nil || len(rest) < len(name.Value)+2 || !strings.EqualFold(rest[:len(name.Value)], name.Value) || !strings.HasPrefix(rest[len(name.Value):], ": ") {
+				continue
+			}
+			itemLine := refineLine(item, rest[len(name.Value)+2:])
+			if itemLine != item.Line {
+				return itemLine
+			}
+			if line == 0 || strings.Contains(rest, "defined more than once") {
+				line = itemLine
+			}
+		}
+		if line > 0 {
+			return line
+		}
+	}
+	if m := indexedProblem.FindStringSubmatch(msg); m != nil {
+		items, _ := x.lookup(m[1])
+		i, _ := strconv.Atoi(m[2])
+		if items != nil && items.Kind == yaml.SequenceNode && i < len(items.Content) {
+			return refineLine(items.Content[i], msg[len(m[0]):])
+		}
+	}
+	if path := sectionProblem.FindString(msg); path != "" {
+		value, key := x.lookup(path)
+		if value != nil {
+			return refineLine(value, msg[len(path):])
+		} else if key != nil {
+			return key.Line
+		}
+	}
+	// 问题中间提到的配置项，如 "unknown storage.backend ..."
+	for _, path := range configPathMention.FindAllString(msg, -1) {
+		if _, key := x.lookup(path); key != nil {
+			return key.Line
+		}
+	}
+	return 0
+}
+
+// refineLine returns the line of the key of node mentioned first in detail,
+// or the line of node itself. Keys of lists may be written out in the
+// singular, as in "health check ..." for health_checks.
+func refineLine(node *yaml.Node, detail string) int {
+	line, first := node.Line, len(detail)
+	if node.Kind != yaml.MappingNode {
+		return line
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		pattern := regexp.QuoteMeta(key.Value)
+		if spelled := strings.ReplaceAll(key.Value, "_", " "); spelled != key.Value {
+			pattern += "|" + regexp.QuoteMeta(strings.TrimSuffix(spelled, "s"))
+		}
+		loc := regexp.MustCompile(`\b(?:` + pattern + `)\b`).FindStringIndex(detail)
+		if loc != nil && loc[0] < first {
+			line, first = key.Line, loc[0]
+		}
+	}
+	return line
+}
+
+// environmentProblems checks what only the machine the config is for can
+// tell: the program and work directory of every process exist, and the
+// HTTP API is not exposed to the network without a token. The warnings
+// are check timeouts that do not fit in a check round.
+func environmentProblems(config Config) (errors, warnings []string) {
+	for _, p := range config.Processes {
+		if p.Name == "" || !p.Enable {
+			continue
+		}
+		if p.WorkDir != "" {
+			if info, err := os.Stat(p.WorkDir); err != nil {
+				errors = append(errors, fmt.Sprintf("process %s: work_dir %s does not exist", p.Name, p.WorkDir))
+			} else if !info.IsDir() {
+				errors = append(errors, fmt.Sprintf("process %s: work_dir %s is not a directory", p.Name, p.WorkDir))
+			}
+		}
+		// 与启动进程时相同：相对路径相对于 work_dir
+		program, key := p.Name, "program"
+		if p.RestartCommand != "" {
+			program, key = p.RestartCommand, "restart_command"
+		}
+		path := program
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.WorkDir, path)
+		}
+		if !programExists(path) {
+			where := "the current directory"
+			if p.WorkDir != "" {
+				where = p.WorkDir
+			}
+			if filepath.IsAbs(program) {
+				errors = append(errors, fmt.Sprintf("process %s: %s %s not found", p.Name, key, program))
+			} else {
+				errors = append(errors, fmt.Sprintf("process %s: %s %s not found in %s", p.Name, key, program, where))
+			}
+		}
+
+		deadline, deadlineKey := p.CheckDeadline, "check_deadline"
+		if deadline == 0 {
+			deadline, deadlineKey = p.CheckInterval, "check_interval"
+		}
+		if p.CheckDeadline > 0 && p.CheckInterval > 0 && p.CheckDeadline > p.CheckInterval {
+			warnings = append(warnings, fmt.Sprintf("process %s: check_deadline %ds is longer than check_interval %ds, rounds of checks delay each other", p.Name, p.CheckDeadline, p.CheckInterval))
+		}
+		for _, check := range p.HealthChecks {
+			if timeout := defaultInt(check.Timeout, defaultCheckTimeout); deadline > 0 && timeout > deadline {
+				warnings = append(warnings, fmt.Sprintf("process %s: health check %s: timeout %ds is longer than %s %ds, the check is cut short", p.Name, check, timeout, deadlineKey, deadline))
+			}
+		}
+	}
+	if listen := config.API.Listen; listen != "" && config.API.AdminToken == "" && !loopbackListen(listen) {
+		errors = append(errors, fmt.Sprintf("api: listen %s is not a loopback address and requires admin_token, the HTTP API is not started", listen))
+	}
+	return errors, warnings
+}
+
+// programExists reports whether path is a file; on Windows a path without
+// extension is started as path.exe
+func programExists(path string) bool {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return true
+	}
+	if runtime.GOOS == "windows" && filepath.Ext(path) == "" {
+		return programExists(path + ".exe")
+	}
+	return false
+}
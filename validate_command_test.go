@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "api")
+	if err := os.WriteFile(program, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`api:
+  listen: "127.0.0.1:9090"
+processs: []
+processes:
+  - name: api
+    work_dir: %q
+    check_interval: 5
+    health_checks:
+      - type: http
+        url: "http://localhost/health"
+        expect_code: 200
+        timeout: 10
+  - name: worker
+    restart_command: %q
+    work_dir: %q
+    check_interval: abc
+  - name: api
+    check_interval: 5
+    depends_on: [db]
+groups:
+  - name: web
+  - name: web
+storage:
+  backend: sqlite
+`, dir, program, filepath.Join(dir, "missing"))
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := checkConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		line    int
+		msg     string
+		warning bool
+	}{
+		{3, `unknown field "processs"`, false},
+		// 同名的进程无法区分时指向第一个
+		{5, "process api: program api not found in the current directory", false},
+		{8, "health check http://localhost/health: timeout 10s is longer than check_interval 5s", true},
+		{11, `processes[0].health_checks[0]: unknown field "expect_code"`, false},
+		{15, "process worker: work_dir", false},
+		{16, "cannot unmarshal", false},
+		{16, "process worker: check_interval must be greater than 0", false},
+		{17, "process api: defined more than once", false},
+		{19, "process api: depends_on unknown process db", false},
+		{22, "group web: defined more than once", false},
+		{24, `unknown storage.backend "sqlite"`, false},
+	}
+	if len(problems) != len(want) {
+		t.Errorf("got %d problems, want %d:", len(problems), len(want))
+		for _, p := range problems {
+			t.Log(p.format(path))
+		}
+		return
+	}
+	for i, w := range want {
+		if p := problems[i]; p.line != w.line || !strings.Contains(p.msg, w.msg) || p.warning != w.warning {
+			t.Errorf("problem %d = %s, want line %d %q (warning=%v)", i, p.format(path), w.line, w.msg, w.warning)
+		}
+	}
+}
+
+func TestCheckConfigFileSyntaxError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("processes:\n  - name: api\n    args: [\"-v\"\n"), 0600)
+	problems, err := checkConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].line == 0 || problems[0].warning {
+		t.Errorf("problems = %+v", problems)
+	}
+}
+
+func TestConfigIndexLocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`event_history:
+  path: events.db
+  retention_days: -1
+service_monitors:
+  - name: Spooler
+  - name: spooler
+notifications:
+  webhooks:
+    - url: "ftp://hooks"
+`), 0600)
+	problems, err := checkConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make(map[string]int)
+	for _, p := range problems {
+		lines[p.msg] = p.line
+	}
+	for msg, want := range map[string]int{
+		"event_history: retention_days and max_events must not be negative": 3,
+		"service monitor spooler: defined more than once":                   6,
+		"notifications.webhooks[0]: url must be an http:// or https:// URL": 9,
+	} {
+		if got, ok := lines[msg]; !ok || got != want {
+			t.Errorf("%q at line %d (found %v), want %d", msg, got, ok, want)
+		}
+	}
+}
+
+func TestExposedAPIWithoutToken(t *testing.T) {
+	tests := []struct {
+		api  APIConfig
+		want bool
+	}{
+		{APIConfig{Listen: "127.0.0.1:9500"}, false},
+		{APIConfig{Listen: "0.0.0.0:9500", AdminToken: "s3cret"}, false},
+		{APIConfig{Listen: ":9500"}, true},
+	}
+	for _, tt := range tests {
+		errors, _ := environmentProblems(Config{API: tt.api})
+		if got := len(errors) == 1 && strings.Contains(errors[0], "requires admin_token"); got != tt.want {
+			t.Errorf("%+v: errors = %q", tt.api, errors)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultNotifyQueueMaxAge    = 24  // 小时
+	defaultNotifyQueueMaxDelay  = 300 // 秒
+	defaultNotifyQueueMaxEvents = 10000
+)
+
+// NotifyQueueConfig 持久化的 Webhook 发送队列
+type NotifyQueueConfig struct {
+	Dir           string `yaml:"dir"`             // 队列和死信文件所在目录，设置后启用
+	MaxAge        int    `yaml:"max_age"`         // 事件最多等待多久（小时，默认24），超过后转入死信文件
+	MaxRetryDelay int    `yaml:"max_retry_delay"` // 重试间隔的上限（秒，默认300）
+	MaxEvents     int    `yaml:"max_events"`      // 每个 Webhook 最多排队的事件数（默认10000），超出时最旧的转入死信文件
+}
+
+func (c NotifyQueueConfig) validate() error {
+	if c.MaxAge < 0 || c.MaxRetryDelay < 0 || c.MaxEvents < 0 {
+		return fmt.Errorf("notifications.queue: max_age, max_retry_delay and max_events must not be negative")
+	}
+	if c.Dir == "" && (c.MaxAge > 0 || c.MaxRetryDelay > 0 || c.MaxEvents > 0) {
+		return fmt.Errorf("notifications.queue: dir is required")
+	}
+	return nil
+}
+
+// queuedEvent is a line of a queue or dead letter file
+type queuedEvent struct {
+	Queued   time.Time  `json:"queued"`
+	Attempts int        `json:"attempts,omitempty"`
+	Error    string     `json:"error,omitempty"` // 最后一次发送失败的原因
+	Dead     *time.Time `json:"dead,omitempty"`  // 转入死信文件的时间
+	Event    Event      `json:"event"`
+}
+
+// webhookQueuePaths returns the queue and dead letter files of a webhook
+func webhookQueuePaths(dir, name string) (queue, dead string) {
+	base := filepath.Join(dir, safeFileName(name))
+	return base + ".queue.jsonl", base + ".dead.jsonl"
+}
+
+// readQueuedEvents reads a queue or dead letter file; a line cut short by a
+// crash is skipped
+func readQueuedEvents(path string) ([]queuedEvent, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []queuedEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry queuedEvent
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func marshalQueuedEvents(entries []queuedEvent) ([][]byte, error) {
+	lines := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, data)
+	}
+	return lines, nil
+}
+
+// webhookQueue keeps the undelivered events of a webhook in a file, so they
+// survive outages of the receiver and restarts of the monitor. Events that
+// cannot be delivered go to the dead letter file, from which the
+// replay-notifications command sends them again.
+type webhookQueue struct {
+	path, deadPath string
+	maxAge         time.Duration
+	maxDelay       time.Duration
+	maxEvents      int
+
+	mu      sync.Mutex
+	entries []queuedEvent // 文件内容，最旧的在前
+	wake    chan struct{}
+}
+
+func openWebhookQueue(config NotifyQueueConfig, name string) (*webhookQueue, error) {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create notification queue directory: %v", err)
+	}
+	q := &webhookQueue{
+		maxAge:    time.Duration(defaultInt(config.MaxAge, defaultNotifyQueueMaxAge)) * time.Hour,
+		maxDelay:  seconds(defaultInt(config.MaxRetryDelay, defaultNotifyQueueMaxDelay)),
+		maxEvents: defaultInt(config.MaxEvents, defaultNotifyQueueMaxEvents),
+		wake:      make(chan struct{}, 1),
+	}
+	q.path, q.deadPath = webhookQueuePaths(config.Dir, name)
+	entries, err := readQueuedEvents(q.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification queue: %v", err)
+	}
+	q.entries = entries
+	if len(entries) > 0 {
+		q.wake <- struct{}{}
+	}
+	return q, nil
+}
+
+// save rewrites the queue file; the caller holds mu
+func (q *webhookQueue) save() error {
+	lines, err := marshalQueuedEvents(q.entries)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(q.path, buf.Bytes())
+}
+
+// push adds e to the end of the queue
+func (q *webhookQueue) push(e Event) error {
+	entry := queuedEvent{Queued: time.Now(), Event: e}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	q.entries = append(q.entries, entry)
+	var overflow []queuedEvent
+	if len(q.entries) > q.maxEvents {
+		overflow = append(overflow, q.entries[:len(q.entries)-q.maxEvents]...)
+		q.entries = append([]queuedEvent(nil), q.entries[len(q.entries)-q.maxEvents:]...)
+		err = q.save()
+	} else {
+		err = appendLines(q.path, data)
+	}
+	q.mu.Unlock()
+	for _, entry := range overflow {
+		q.deadLetter(entry, fmt.Sprintf("queue is full (%d events)", q.maxEvents))
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// head returns the oldest queued event
+func (q *webhookQueue) head() (queuedEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) == 0 {
+		return queuedEvent{}, false
+	}
+	return q.entries[0], true
+}
+
+// update replaces the oldest event, sent, by entry or removes it when entry
+// is nil. Nothing changes if sent was moved out of a full queue meanwhile.
+func (q *webhookQueue) update(sent queuedEvent, entry *queuedEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) == 0 || !q.entries[0].Queued.Equal(sent.Queued) {
+		return
+	}
+	if entry == nil {
+		q.entries = q.entries[1:]
+	} else {
+		q.entries[0] = *entry
+	}
+	if err := q.save(); err != nil {
+		logrus.Errorf("Failed to update notification queue %s: %v", q.path, err)
+	}
+}
+
+// len returns the number of queued events
+func (q *webhookQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// deadLetter appends entry to the dead letter file
+func (q *webhookQueue) deadLetter(entry queuedEvent, reason string) {
+	now := time.Now()
+	entry.Dead, entry.Error = &now, reason
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = appendLines(q.deadPath, data)
+	}
+	if err != nil {
+		internalErrorf("notifications", "Failed to write %s event to dead letter file %s: %v", entry.Event.Type, q.deadPath, err)
+		return
+	}
+	internalErrorf("notifications", "Notification of %s event moved to dead letter file %s: %s", entry.Event.Type, q.deadPath, reason)
+}
+
+// runQueue delivers the queued events of w in order. While the receiver is
+// unreachable the oldest event is retried with backoff up to max_retry_delay,
+// keeping the later events waiting. Events the receiver refuses, that cannot
+// be rendered or that waited longer than max_age go to the dead letter file.
+func (w *webhookNotifier) runQueue(ctx context.Context) {
+	q := w.durable
+	delay := w.retry.delay
+	for {
+		entry, ok := q.head()
+		if !ok {
+			select {
+			case <-q.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if time.Since(entry.Queued) > q.maxAge {
+			reason := fmt.Sprintf("not delivered within %v", q.maxAge)
+			if entry.Error != "" {
+				reason += ": " + entry.Error
+			}
+			q.deadLetter(entry, reason)
+			q.update(entry, nil)
+			continue
+		}
+		body, err := w.render(entry.Event)
+		if err != nil {
+			q.deadLetter(entry, fmt.Sprintf("failed to render: %v", err))
+			q.update(entry, nil)
+			continue
+		}
+		err = sendWithRetry(ctx, w.client, retryPolicy{attempts: 1}, w.config.Method, w.config.URL, w.config.Headers, body)
+		if err == nil {
+			q.update(entry, nil)
+			delay = w.retry.delay
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if statusErr, ok := err.(*notifyStatusError); ok && !statusErr.retryable() {
+			q.deadLetter(entry, err.Error())
+			q.update(entry, nil)
+			continue
+		}
+		retried := entry
+		retried.Attempts++
+		retried.Error = err.Error()
+		q.update(entry, &retried)
+		logrus.Warnf("Webhook %s: %d event(s) queued, retrying in %v: %v", w.config.Name, q.len(), delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		if delay *= 2; delay > q.maxDelay {
+			delay = q.maxDelay
+		}
+	}
+}
+
+// runReplayNotificationsCommand implements
+// "processmonitor replay-notifications [-webhook name] [-list]"
+func runReplayNotificationsCommand(config Config, args []string) error {
+	fs := flag.NewFlagSet("replay-notifications", flag.ContinueOnError)
+	only := fs.String("webhook", "", "only the dead letters of this webhook")
+	list := fs.Bool("list", false, "list the dead letters without sending them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dir := config.Notifications.Queue.Dir
+	if dir == "" {
+		return fmt.Errorf("notifications.queue.dir is not set, undeliverable notifications are not kept")
+	}
+
+	found, failed := false, 0
+	for _, wc := range config.Notifications.Webhooks {
+		w, err := newWebhookNotifier(wc)
+		if err != nil {
+			return err
+		}
+		if *only != "" && w.config.Name != *only {
+			continue
+		}
+		found = true
+		_, deadPath := webhookQueuePaths(dir, w.config.Name)
+		if *list {
+			entries, err := readQueuedEvents(deadPath)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Webhook %s: %d dead letter(s)\n", w.config.Name, len(entries))
+			for _, entry := range entries {
+				fmt.Printf("  %s  %-22s %-16s %s\n", entry.Event.Time.Format("2006-01-02 15:04:05"), entry.Event.Type, entry.Event.Process, entry.Error)
+			}
+			continue
+		}
+		sent, kept, err := w.replay(context.Background(), deadPath)
+		if err != nil {
+			return fmt.Errorf("webhook %s: %v", w.config.Name, err)
+		}
+		fmt.Printf("Webhook %s: %d event(s) sent, %d left in %s\n", w.config.Name, sent, kept, deadPath)
+		failed += kept
+	}
+	if !found {
+		return fmt.Errorf("unknown webhook %q", *only)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d event(s) could not be delivered", failed)
+	}
+	return nil
+}
+
+// replay sends the events of the dead letter file deadPath again, oldest
+// first, and returns how many were sent and how many are left in the file.
+// The file is renamed first, so that the events the running monitor moves
+// to it meanwhile are kept; the events not sent are appended back. Replay
+// stops at the first event that fails while the receiver is unreachable.
+func (w *webhookNotifier) replay(ctx context.Context, deadPath string) (sent, kept int, err error) {
+	replaying := deadPath + ".replay"
+	// 存在 .replay 文件时继续上次中断的重放
+	if _, err := os.Stat(replaying); os.IsNotExist(err) {
+		if err := os.Rename(deadPath, replaying); os.IsNotExist(err) {
+			return 0, 0, nil
+		} else if err != nil {
+			return 0, 0, err
+		}
+	}
+	entries, err := readQueuedEvents(replaying)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var left []queuedEvent
+	for i, entry := range entries {
+		body, err := w.render(entry.Event)
+		if err == nil {
+			err = sendWithRetry(ctx, w.client, w.retry, w.config.Method, w.config.URL, w.config.Headers, body)
+		}
+		if err == nil {
+			sent++
+			continue
+		}
+		entry.Attempts++
+		entry.Error = err.Error()
+		left = append(left, entry)
+		if statusErr, ok := err.(*notifyStatusError); !ok || statusErr.retryable() {
+			left = append(left, entries[i+1:]...)
+			break
+		}
+	}
+	if len(left) > 0 {
+		lines, err := marshalQueuedEvents(left)
+		if err == nil {
+			err = appendLines(deadPath, lines...)
+		}
+		if err != nil {
+			return sent, len(left), fmt.Errorf("failed to write back %d event(s), they are kept in %s: %v", len(left), replaying, err)
+		}
+	}
+	return sent, len(left), os.Remove(replaying)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the event types it accepts; it answers 503 while
+// down and 400 for events of type "rejected"
+type webhookReceiver struct {
+	mu       sync.Mutex
+	down     bool
+	received []string
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var e Event
+	data, _ := io.ReadAll(req.Body)
+	json.Unmarshal(data, &e)
+	switch {
+	case r.down:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case e.Type == "rejected":
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		r.received = append(r.received, e.Type)
+	}
+}
+
+func (r *webhookReceiver) setDown(down bool) {
+	r.mu.Lock()
+	r.down = down
+	r.mu.Unlock()
+}
+
+func (r *webhookReceiver) got() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.received...)
+}
+
+func queuedNotifier(t *testing.T, url string, config NotifyQueueConfig) *webhookNotifier {
+	w, err := newWebhookNotifier(WebhookConfig{Name: "ops bot", URL: url, Events: map[string]bool{"a": true, "b": true, "c": true, "rejected": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.retry.delay = 10 * time.Millisecond
+	if w.durable, err = openWebhookQueue(config, w.config.Name); err != nil {
+		t.Fatal(err)
+	}
+	w.durable.maxDelay = 50 * time.Millisecond
+	return w
+}
+
+func TestWebhookQueue(t *testing.T) {
+	receiver := &webhookReceiver{down: true}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+	config := NotifyQueueConfig{Dir: t.TempDir()}
+
+	// 接收方不可用期间的事件保存在文件中，监控程序重启后继续发送
+	w := queuedNotifier(t, srv.URL, config)
+	for _, eventType := range []string{"a", "rejected", "b"} {
+		w.HandleEvent(Event{Type: eventType, Process: "api.exe"})
+	}
+	w = queuedNotifier(t, srv.URL, config)
+	if n := w.durable.len(); n != 3 {
+		t.Fatalf("reopened queue has %d events, want 3", n)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if entry, _ := w.durable.head(); entry.Attempts == 0 || !strings.Contains(entry.Error, "503") {
+		t.Errorf("head while down = %+v", entry)
+	}
+	receiver.setDown(false)
+	w.HandleEvent(Event{Type: "c"})
+
+	// 发送方在收到响应后才从文件中删除事件，等待队列清空而不只是接收方收到
+	deadline := time.Now().Add(5 * time.Second)
+	for (len(receiver.got()) < 3 || w.durable.len() > 0) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if got := strings.Join(receiver.got(), ","); got != "a,b,c" {
+		t.Errorf("delivered %s, want a,b,c", got)
+	}
+	if entries, _ := readQueuedEvents(w.durable.path); len(entries) != 0 {
+		t.Errorf("queue file still has %+v", entries)
+	}
+	// 被拒绝（4xx）的事件转入死信文件
+	dead, _ := readQueuedEvents(w.durable.deadPath)
+	if len(dead) != 1 || dead[0].Event.Type != "rejected" || !strings.Contains(dead[0].Error, "400") || dead[0].Dead == nil {
+		t.Errorf("dead letters = %+v", dead)
+	}
+}
+
+func TestWebhookQueueLimits(t *testing.T) {
+	config := NotifyQueueConfig{Dir: t.TempDir(), MaxEvents: 2}
+	w := queuedNotifier(t, "http://unused", config)
+	for _, eventType := range []string{"a", "b", "c"} {
+		w.HandleEvent(Event{Type: eventType})
+	}
+	dead, _ := readQueuedEvents(w.durable.deadPath)
+	if w.durable.len() != 2 || len(dead) != 1 || dead[0].Event.Type != "a" || !strings.Contains(dead[0].Error, "queue is full") {
+		t.Errorf("queue %d, dead letters %+v", w.durable.len(), dead)
+	}
+
+	// 超过 max_age 的事件不再发送
+	w.durable.mu.Lock()
+	w.durable.entries[0].Queued = time.Now().Add(-25 * time.Hour)
+	w.durable.entries[0].Error = "connection refused"
+	w.durable.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	go w.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(dead) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		dead, _ = readQueuedEvents(w.durable.deadPath)
+	}
+	cancel()
+	if len(dead) < 2 || dead[1].Event.Type != "b" || !strings.Contains(dead[1].Error, "not delivered within 24h0m0s: connection refused") {
+		t.Errorf("dead letters = %+v", dead)
+	}
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	receiver := &webhookReceiver{down: true}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+	w := queuedNotifier(t, srv.URL, NotifyQueueConfig{Dir: t.TempDir()})
+	w.retry.attempts = 1
+	for _, eventType := range []string{"a", "rejected", "b"} {
+		w.durable.deadLetter(queuedEvent{Queued: time.Now(), Event: Event{Type: eventType}}, "connection refused")
+	}
+
+	// 接收方仍不可用时在第一个事件处停止，全部保留
+	sent, kept, err := w.replay(context.Background(), w.durable.deadPath)
+	if sent != 0 || kept != 3 || err != nil {
+		t.Errorf("replay while down = %d sent, %d kept, %v", sent, kept, err)
+	}
+	receiver.setDown(false)
+	sent, kept, err = w.replay(context.Background(), w.durable.deadPath)
+	if sent != 2 || kept != 1 || err != nil {
+		t.Errorf("replay = %d sent, %d kept, %v", sent, kept, err)
+	}
+	if got := strings.Join(receiver.got(), ","); got != "a,b" {
+		t.Errorf("delivered %s, want a,b", got)
+	}
+	dead, _ := readQueuedEvents(w.durable.deadPath)
+	if len(dead) != 1 || dead[0].Event.Type != "rejected" || dead[0].Attempts != 1 {
+		t.Errorf("dead letters after replay = %+v", dead)
+	}
+}
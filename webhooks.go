@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationsConfig 事件通知
+type NotificationsConfig struct {
+	Webhooks []WebhookConfig   `yaml:"webhooks"`  // 通用 HTTP Webhook
+	Queue    NotifyQueueConfig `yaml:"queue"`     // Webhook 的持久化发送队列和死信文件
+	SMTP     SMTPConfig        `yaml:"smtp"`      // 邮件告警
+	EventLog EventLogConfig    `yaml:"event_log"` // Windows 应用程序事件日志
+}
+
+// WebhookConfig 一个通用 HTTP Webhook
+type WebhookConfig struct {
+	Name       string            `yaml:"name"`        // 名称，用于日志
+	URL        string            `yaml:"url"`         // 接收地址
+	Method     string            `yaml:"method"`      // HTTP 方法（默认 POST）
+	Headers    map[string]string `yaml:"headers"`     // 附加的请求头，如 Authorization
+	Template   string            `yaml:"template"`    // 请求体模板（Go text/template，结果必须是JSON），为空则发送事件本身
+	Events     map[string]bool   `yaml:"events"`      // 按事件类型开关；未列出的类型使用默认值（见 defaultWebhookEvents）
+	Retries    int               `yaml:"retries"`     // 失败后的重试次数（默认3）
+	RetryDelay int               `yaml:"retry_delay"` // 第一次重试前的等待时间（秒，默认2，之后每次翻倍）
+	Timeout    int               `yaml:"timeout"`     // 单次请求超时（秒，默认10）
+}
+
+// defaultWebhookEvents 没有在 events 中设置时默认发送的事件
+var defaultWebhookEvents = map[string]bool{
+	"process_restarted":          true,
+	"restart_failed":             true,
+	"startup_failed":             true,
+	"standby_promoted":           true,
+	"port_conflict":              true,
+	"health_check_failed":        true,
+	"registry_value_restored":    true,
+	"registry_key_deleted":       true,
+	"registry_drift":             true,
+	"log_trigger":                true,
+	"file_changed":               true,
+	"service_stopped":            true,
+	"service_start_type_changed": true,
+	"app_pool_queue_high":        true,
+	"task_failed":                true,
+	"disk_quota_exceeded":        true,
+	"disk_health_warning":        true,
+	"config_drift":               true,
+	"config_restored":            true,
+	"internal_errors":            true,
+}
+
+// webhookPayload is the data available to webhook templates
+type webhookPayload struct {
+	Event
+	Host string `json:"host"`
+}
+
+// webhookFuncs are the functions available to webhook templates
+var webhookFuncs = template.FuncMap{
+	// json 将值编码为JSON，用于在模板中安全地嵌入字符串：{"text": {{json .Message}}}
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// parseWebhookTemplate compiles the payload template of a webhook
+func parseWebhookTemplate(config WebhookConfig) (*template.Template, error) {
+	if config.Template == "" {
+		return nil, nil
+	}
+	return template.New(config.Name).Funcs(webhookFuncs).Option("missingkey=zero").Parse(config.Template)
+}
+
+// webhookNotifier is an EventSink delivering events to one webhook
+type webhookNotifier struct {
+	config WebhookConfig
+	tmpl   *template.Template
+	host   string
+	client *http.Client
+	retry  retryPolicy
+	queue  chan Event
+
+	durable *webhookQueue // 设置了 notifications.queue.dir 时代替 queue
+}
+
+func newWebhookNotifier(config WebhookConfig) (*webhookNotifier, error) {
+	if config.Name == "" {
+		config.Name = config.URL
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10
+	}
+	retries := config.Retries
+	if retries <= 0 {
+		retries = 3
+	}
+	tmpl, err := parseWebhookTemplate(config)
+	if err != nil {
+		return nil, fmt.Errorf("webhook %s: invalid template: %v", config.Name, err)
+	}
+	host := currentHost().Hostname
+	return &webhookNotifier{
+		config: config,
+		tmpl:   tmpl,
+		host:   host,
+		client: &http.Client{Timeout: time.Duration(config.Timeout) * time.Second},
+		retry:  retryPolicy{attempts: retries + 1, delay: time.Duration(defaultInt(config.RetryDelay, 2)) * time.Second},
+		queue:  make(chan Event, 100),
+	}, nil
+}
+
+// enabled reports whether events of type eventType are sent
+func (w *webhookNotifier) enabled(eventType string) bool {
+	if on, ok := w.config.Events[eventType]; ok {
+		return on
+	}
+	return defaultWebhookEvents[eventType]
+}
+
+// HandleEvent queues e if its type is enabled for this webhook
+func (w *webhookNotifier) HandleEvent(e Event) {
+	if !w.enabled(e.Type) {
+		return
+	}
+	if w.durable != nil {
+		if err := w.durable.push(e); err != nil {
+			logrus.Errorf("Webhook %s: failed to queue %s event: %v", w.config.Name, e.Type, err)
+		}
+		return
+	}
+	select {
+	case w.queue <- e:
+	default:
+		logrus.Warnf("Webhook %s queue is full, dropping %s event", w.config.Name, e.Type)
+	}
+}
+
+// Run delivers queued events until ctx is done
+func (w *webhookNotifier) Run(ctx context.Context) {
+	if w.durable != nil {
+		w.runQueue(ctx)
+		return
+	}
+	for {
+		select {
+		case e := <-w.queue:
+			body, err := w.render(e)
+			if err != nil {
+				logrus.Errorf("Webhook %s: failed to render %s event: %v", w.config.Name, e.Type, err)
+				continue
+			}
+			if err := sendWithRetry(ctx, w.client, w.retry, w.config.Method, w.config.URL, w.config.Headers, body); err != nil {
+				logrus.Errorf("Webhook %s: failed to deliver %s event: %v", w.config.Name, e.Type, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// render builds the request body of e
+func (w *webhookNotifier) render(e Event) ([]byte, error) {
+	payload := webhookPayload{Event: e, Host: w.host}
+	if w.tmpl == nil {
+		return json.Marshal(payload)
+	}
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	body := bytes.TrimSpace(buf.Bytes())
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("template output is not valid JSON: %s", strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
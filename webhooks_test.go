@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookEventFlags(t *testing.T) {
+	w, err := newWebhookNotifier(WebhookConfig{
+		URL:    "http://unused",
+		Events: map[string]bool{"health_check_failed": false, "circuit_open": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		event   string
+		enabled bool
+	}{
+		{"process_restarted", true},
+		{"restart_failed", true},
+		{"registry_value_restored", true},
+		{"registry_key_deleted", true},
+		{"registry_drift", true},
+		{"log_trigger", true},
+		{"health_check_failed", false}, // 显式关闭
+		{"circuit_open", true},         // 显式开启
+		{"safe_mode_entered", false},
+	}
+	for _, tt := range tests {
+		if got := w.enabled(tt.event); got != tt.enabled {
+			t.Errorf("enabled(%s) = %v, want %v", tt.event, got, tt.enabled)
+		}
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	w, err := newWebhookNotifier(WebhookConfig{
+		URL:      "http://unused",
+		Template: `{"text": {{json .Message}}, "process": {{json .Process}}, "reason": {{json (index .Details "reason")}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := w.render(Event{Type: "process_restarted", Process: "api.exe", Message: `restarted "api"`,
+		Details: map[string]string{"reason": "process exited"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", body, err)
+	}
+	if got["text"] != `restarted "api"` || got["process"] != "api.exe" || got["reason"] != "process exited" {
+		t.Errorf("unexpected payload: %v", got)
+	}
+
+	bad, _ := newWebhookNotifier(WebhookConfig{URL: "http://unused", Template: `{"text": {{.Message}}}`})
+	if _, err := bad.render(Event{Message: "not quoted"}); err == nil {
+		t.Error("expected an error for a template producing invalid JSON")
+	}
+	if _, err := newWebhookNotifier(WebhookConfig{URL: "http://unused", Template: "{{"}); err == nil {
+		t.Error("expected an error for an unparsable template")
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	var calls int32
+	received := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	w, err := newWebhookNotifier(WebhookConfig{URL: srv.URL, Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.retry.delay = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	w.HandleEvent(Event{Type: "restart_failed", Process: "api.exe", Message: "failed"})
+	select {
+	case body := <-received:
+		if body["type"] != "restart_failed" || body["process"] != "api.exe" || body["host"] == "" {
+			t.Errorf("unexpected default payload: %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("webhook not delivered after %d calls", atomic.LoadInt32(&calls))
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("calls = %d, want 3", n)
+	}
+}
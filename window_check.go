@@ -0,0 +1,86 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WindowCheckConfig 检查图形界面程序主窗口是否存在并响应（仅Windows，用于Kiosk等场景）
+type WindowCheckConfig struct {
+	Enable        bool `yaml:"enable"`         // 是否启用
+	Timeout       int  `yaml:"timeout"`        // 等待窗口响应的超时（毫秒，默认5000）
+	HungChecks    int  `yaml:"hung_checks"`    // 连续多少次无响应后重启（默认2）
+	RequireWindow bool `yaml:"require_window"` // 没有可见主窗口时也重启
+	StartupGrace  int  `yaml:"startup_grace"`  // 启动后多少秒内不检查（默认30）
+}
+
+// windowState is the result of inspecting the main windows of a process
+type windowState struct {
+	Found bool // 找到至少一个可见的顶层窗口
+	Hung  bool // 有窗口处于"未响应"状态
+}
+
+// windowCheckDefaults fills in the defaults of a window check
+func windowCheckDefaults(config WindowCheckConfig) WindowCheckConfig {
+	if config.Timeout <= 0 {
+		config.Timeout = 5000
+	}
+	if config.HungChecks <= 0 {
+		config.HungChecks = 2
+	}
+	if config.StartupGrace <= 0 {
+		config.StartupGrace = 30
+	}
+	return config
+}
+
+// checkWindow inspects the main window of the managed process and returns a
+// restart reason once it has been missing or not responding long enough.
+func (s *ProcessSupervisor) checkWindow() (bool, string) {
+	config := windowCheckDefaults(s.config.WindowCheck)
+	if started := s.Status().StartedAt; !started.IsZero() && time.Since(started) < time.Duration(config.StartupGrace)*time.Second {
+		return false, ""
+	}
+
+	var pids []int32
+	if s.currentCmd != nil && s.currentCmd.Process != nil {
+		pids = []int32{int32(s.currentCmd.Process.Pid)}
+	} else if found, err := configPIDs(s.config); err == nil {
+		pids = found
+	}
+	if len(pids) == 0 {
+		return false, ""
+	}
+
+	state, err := inspectWindows(pids, config.Timeout)
+	if err != nil {
+		logrus.Debugf("Window check for %s failed: %v", s.config.Name, err)
+		return false, ""
+	}
+	return s.evaluateWindow(config, state)
+}
+
+// evaluateWindow counts consecutive hung results and decides on a restart
+func (s *ProcessSupervisor) evaluateWindow(config WindowCheckConfig, state windowState) (bool, string) {
+	if !state.Found {
+		s.hungWindowChecks = 0
+		if config.RequireWindow {
+			logrus.Warnf("Process %s has no visible main window", s.config.Name)
+			return true, "main window missing"
+		}
+		return false, ""
+	}
+	if !state.Hung {
+		s.hungWindowChecks = 0
+		return false, ""
+	}
+
+	s.hungWindowChecks++
+	logrus.Warnf("Main window of %s is not responding (%d/%d)", s.config.Name, s.hungWindowChecks, config.HungChecks)
+	if s.hungWindowChecks < config.HungChecks {
+		return false, ""
+	}
+	s.hungWindowChecks = 0
+	return true, "window not responding"
+}
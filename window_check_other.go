@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// inspectWindows is only implemented on Windows
+func inspectWindows(pids []int32, timeoutMs int) (windowState, error) {
+	return windowState{}, fmt.Errorf("window checks are only supported on Windows")
+}
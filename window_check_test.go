@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCheckDefaults(t *testing.T) {
+	got := windowCheckDefaults(WindowCheckConfig{Enable: true})
+	if got.Timeout != 5000 || got.HungChecks != 2 || got.StartupGrace != 30 {
+		t.Errorf("defaults = %+v", got)
+	}
+}
+
+func TestEvaluateWindow(t *testing.T) {
+	hung := windowState{Found: true, Hung: true}
+	ok := windowState{Found: true}
+	missing := windowState{}
+
+	tests := []struct {
+		name    string
+		config  WindowCheckConfig
+		states  []windowState
+		restart []bool
+	}{
+		{"hung twice", WindowCheckConfig{HungChecks: 2}, []windowState{hung, hung, hung, hung}, []bool{false, true, false, true}},
+		{"responding resets", WindowCheckConfig{HungChecks: 2}, []windowState{hung, ok, hung, missing, hung}, []bool{false, false, false, false, false}},
+		{"missing ignored", WindowCheckConfig{HungChecks: 2}, []windowState{missing, missing}, []bool{false, false}},
+		{"missing required", WindowCheckConfig{HungChecks: 2, RequireWindow: true}, []windowState{missing, ok, missing}, []bool{true, false, true}},
+	}
+	for _, tt := range tests {
+		s := NewProcessSupervisor(ProcessConfig{Name: "kiosk.exe"})
+		for i, state := range tt.states {
+			if restart, reason := s.evaluateWindow(tt.config, state); restart != tt.restart[i] {
+				t.Errorf("%s: step %d: restart = %v (%s), want %v", tt.name, i, restart, reason, tt.restart[i])
+			}
+		}
+	}
+}
+
+func TestCheckWindowStartupGrace(t *testing.T) {
+	s := NewProcessSupervisor(ProcessConfig{Name: "kiosk.exe", WindowCheck: WindowCheckConfig{Enable: true, RequireWindow: true}})
+	s.hungWindowChecks = 1
+	s.updateStatus(func(st *ProcessStatus) { st.StartedAt = time.Now() })
+	// 启动宽限期内不检查，也不改变计数
+	if restart, _ := s.checkWindow(); restart || s.hungWindowChecks != 1 {
+		t.Errorf("checked within startup grace: restart = %v, hung checks = %d", restart, s.hungWindowChecks)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32                  = windows.NewLazySystemDLL("user32.dll")
+	procEnumWindows         = user32.NewProc("EnumWindows")
+	procIsWindowVisible     = user32.NewProc("IsWindowVisible")
+	procGetWindow           = user32.NewProc("GetWindow")
+	procIsHungAppWindow     = user32.NewProc("IsHungAppWindow")
+	procSendMessageTimeoutW = user32.NewProc("SendMessageTimeoutW")
+)
+
+const (
+	gwOwner          = 4
+	wmNull           = 0x0000
+	smtoAbortIfHung  = 0x0002
+	smtoErrorOnExit  = 0x0020
+	windowCheckBlock = smtoAbortIfHung | smtoErrorOnExit
+)
+
+// syscall.NewCallback 创建的回调数量有限且不会释放，因此只创建一次，
+// 通过包级变量传递状态
+var (
+	enumMu              sync.Mutex
+	enumWanted          map[uint32]bool
+	enumFound           []windows.HWND
+	enumWindowsCallback = syscall.NewCallback(func(hwnd windows.HWND, _ uintptr) uintptr {
+		var pid uint32
+		windows.GetWindowThreadProcessId(hwnd, &pid)
+		if !enumWanted[pid] {
+			return 1
+		}
+		if visible, _, _ := procIsWindowVisible.Call(uintptr(hwnd)); visible == 0 {
+			return 1
+		}
+		// 只检查主窗口（没有所有者的顶层窗口）
+		if owner, _, _ := procGetWindow.Call(uintptr(hwnd), gwOwner); owner != 0 {
+			return 1
+		}
+		enumFound = append(enumFound, hwnd)
+		return 1
+	})
+)
+
+// mainWindows returns the visible, unowned top-level windows of pids. Only
+// windows on the monitor's own desktop are visible, so a monitor running as
+// a service in session 0 cannot see a kiosk app in the interactive session.
+func mainWindows(pids []int32) ([]windows.HWND, error) {
+	wanted := make(map[uint32]bool, len(pids))
+	for _, pid := range pids {
+		wanted[uint32(pid)] = true
+	}
+
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	enumWanted = wanted
+	enumFound = nil
+	if r, _, err := procEnumWindows.Call(enumWindowsCallback, 0); r == 0 {
+		return nil, err
+	}
+	return enumFound, nil
+}
+
+// inspectWindows checks that each main window of pids responds within timeoutMs
+func inspectWindows(pids []int32, timeoutMs int) (windowState, error) {
+	hwnds, err := mainWindows(pids)
+	if err != nil {
+		return windowState{}, err
+	}
+
+	state := windowState{Found: len(hwnds) > 0}
+	for _, hwnd := range hwnds {
+		// 系统已判定为"未响应"（5秒内未处理消息）
+		if hung, _, _ := procIsHungAppWindow.Call(uintptr(hwnd)); hung != 0 {
+			state.Hung = true
+			break
+		}
+		var result uintptr
+		r, _, _ := procSendMessageTimeoutW.Call(uintptr(hwnd), wmNull, 0, 0,
+			windowCheckBlock, uintptr(timeoutMs), uintptr(unsafe.Pointer(&result)))
+		if r == 0 {
+			state.Hung = true
+			break
+		}
+	}
+	return state, nil
+}